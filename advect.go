@@ -0,0 +1,141 @@
+//go:build js && wasm
+// +build js,wasm
+
+// advect.go - In-place advection
+//
+// advectPositions moves particles along velocities the caller already has,
+// e.g. from updateVelocities, and writes the result straight back into the
+// positions array: one bulk read of each buffer and one bulk write per
+// frame, through pooled scratch buffers (see pool.go). This is the fast
+// path; the copy option returns a new array instead.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// advectPositions(positions, velocities, count, dt[, options])
+//
+// Advances the first count particles of positions (a Float32Array or
+// Float64Array of x,y,z triples) by one explicit Euler step of length dt
+// along velocities (a typed array or plain array of count*3), writing the
+// result into positions itself and returning it. Options:
+//   - target: a typed array of the precision of positions, of at least
+//     count*3 elements, to write into instead; positions is left untouched
+//   - copy: if true, returns the result in a new typed array instead
+//   - mask: a Uint8Array of count bytes; particles whose byte is 0 are not
+//     moved, so their positions are written out unchanged (see mask.go)
+//   - layout: "planar" takes positions, velocities and target as three
+//     component arrays or one array of the x, y and z blocks, all in the
+//     same form (see planar.go); the default is "interleaved"
+//
+// Both buffers are read in full before anything is written, so positions,
+// velocities and target may be the same array: passing positions as
+// velocities gives p + p*dt, and a target aliasing velocities replaces the
+// velocities with the advanced positions. positions and velocities are in
+// the length and velocity units of setUnits, dt in seconds. All arguments are validated
+// first; on an error no buffer has been written.
+func advectPositions(args []js.Value) (interface{}, error) {
+	if err := checkArgs("advectPositions", args, 4); err != nil {
+		return nil, err
+	}
+	layout, err := layoutOption(optionalArg(args, 4))
+	if err != nil {
+		return nil, err
+	}
+	positions, err := vectorsArg("positions", args[0], layout)
+	if err != nil {
+		return nil, err
+	}
+	if !positions.typed() {
+		return nil, flow.Errorf(flow.ErrBadArguments, "positions must be a Float32Array or Float64Array")
+	}
+	velocities, err := vectorsArg("velocities", args[1], layout)
+	if err != nil {
+		return nil, err
+	}
+	if err := positions.sameForm("positions", velocities, "velocities"); err != nil {
+		return nil, err
+	}
+	count, err := countArg(args[2])
+	if err != nil {
+		return nil, err
+	}
+	dt, err := floatArg("dt", args[3])
+	if err != nil {
+		return nil, err
+	}
+	// Moving by v·dt in API units is moving by v·dt·(velocity/length unit)
+	dt *= units.Factor(flow.DimRate)
+	if err := positions.check("positions", count); err != nil {
+		return nil, err
+	}
+	if err := velocities.check("velocities", count); err != nil {
+		return nil, err
+	}
+
+	target, copied := positions, false
+	if opts := optionalArg(args, 4); opts.Type() == js.TypeObject {
+		if v := opts.Get("target"); !v.IsUndefined() {
+			if target, err = vectorsArg("target", v, layout); err != nil {
+				return nil, err
+			}
+			if err := positions.sameForm("positions", target, "target"); err != nil {
+				return nil, err
+			}
+			if !target.typed() || target.precision() != positions.precision() {
+				return nil, flow.Errorf(flow.ErrBadArguments, "target must be a typed array of the precision of positions")
+			}
+			if err := target.check("target", count); err != nil {
+				return nil, err
+			}
+		}
+		copied = opts.Get("copy").Truthy()
+	}
+	mask, err := maskOption(optionalArg(args, 4), count)
+	if err != nil {
+		return nil, err
+	}
+
+	if positions.precision() == float64Precision {
+		return advectAs[float64](positions, velocities, count, dt, target, copied, mask)
+	}
+	return advectAs[float32](positions, velocities, count, dt, target, copied, mask)
+}
+
+// advectAs advects P-precision positions, dispatching on the precision of
+// velocities, and writes them to target, or to new arrays of its form if
+// copied
+func advectAs[P flow.Float](positions, velocities vectors, count int, dt float64, target vectors, copied bool, mask flow.Mask) (js.Value, error) {
+	p, err := vectorsFromJS[P]("positions", positions, count)
+	if err != nil {
+		return js.Value{}, err
+	}
+	defer putBuffer(p)
+	if velocities.precision() == float64Precision {
+		err = advectBy[P, float64](p, velocities, count, dt, mask)
+	} else {
+		err = advectBy[P, float32](p, velocities, count, dt, mask)
+	}
+	if err != nil {
+		return js.Value{}, err
+	}
+	recordActive(count, &mask)
+	if copied {
+		return vectorsToJS(target, p, count), nil
+	}
+	copyVectorsToJS(target, p, count)
+	return target.v, nil
+}
+
+// advectBy advances p along the V-precision velocities array
+func advectBy[P, V flow.Float](p []P, velocities vectors, count int, dt float64, mask flow.Mask) error {
+	v, err := vectorsFromJS[V]("velocities", velocities, count)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(v)
+	return timed("advect", func() error { return flow.AdvectMasked(p, v, count, dt, mask) })
+}
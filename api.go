@@ -0,0 +1,225 @@
+//go:build js && wasm
+// +build js,wasm
+
+// api.go - Registration of the functions exported to JavaScript
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// binding is the Go side of an exported function. It returns the value
+// handed to JavaScript, or an error that becomes a JavaScript Error.
+type binding func(args []js.Value) (interface{}, error)
+
+// export describes one function exported to JavaScript
+type export struct {
+	name string
+	fn   binding
+
+	// async functions return a Promise and run on a separate goroutine, so
+	// heavyweight work never blocks the caller inside js.FuncOf
+	async bool
+}
+
+// exports lists every function registered on globalThis
+var exports = []export{
+	{name: "updateVelocities", fn: updateVelocities},
+	{name: "calculatePressure", fn: calculatePressure},
+	{name: "calculateIsentropic", fn: calculateIsentropic},
+	{name: "updateVelocitiesChunked", fn: updateVelocitiesChunked, async: true},
+	{name: "advectPositions", fn: advectPositions},
+	{name: "attachBuffers", fn: attachBuffers},
+	{name: "detachBuffers", fn: detachBuffers},
+	{name: "stepShared", fn: stepShared},
+	{name: "configure", fn: configure},
+	{name: "getConfig", fn: getConfig},
+	{name: "registerParticles", fn: registerParticles},
+	{name: "seedParticles", fn: seedParticles},
+	{name: "setSeed", fn: setSeed},
+	{name: "getParticles", fn: getParticles},
+	{name: "step", fn: step},
+	{name: "stepMany", fn: stepMany},
+	{name: "updatePartial", fn: updatePartial},
+	{name: "pause", fn: pause},
+	{name: "resume", fn: resume},
+	{name: "reset", fn: reset},
+	{name: "setTimeScale", fn: setTimeScale},
+	{name: "registerEventCallback", fn: registerEventCallback},
+	{name: "setFreeStream", fn: setFreeStream},
+	{name: "setFreeStreamShear", fn: setFreeStreamShear},
+	{name: "setFreeStreamProfile", fn: setFreeStreamProfile},
+	{name: "setFreeStreamSwirl", fn: setFreeStreamSwirl},
+	{name: "setFreeStreamSchedule", fn: setFreeStreamSchedule},
+	{name: "setUnits", fn: setUnits},
+	{name: "buildFlow", fn: buildFlow},
+	{name: "solveThinAirfoil", fn: solveThinAirfoil},
+	{name: "solveTandem", fn: solveTandem},
+	{name: "getPlateForces", fn: getPlateForces},
+	{name: "getDragReport", fn: getDragReport},
+	{name: "getCpDistribution", fn: getCpDistribution},
+	{name: "runAlphaSweep", fn: runAlphaSweep},
+	{name: "evaluateSensitivity", fn: evaluateSensitivity},
+	{name: "runParameterSweep", fn: runParameterSweep, async: true},
+	{name: "startForceHistory", fn: startForceHistory},
+	{name: "getForceHistory", fn: getForceHistory},
+	{name: "getTorusFit", fn: getTorusFit},
+	{name: "setFrame", fn: setFrame},
+	{name: "setArithmetic", fn: setArithmetic},
+	{name: "setRotatingFrame", fn: setRotatingFrame},
+	{name: "setBoundary", fn: setBoundary},
+	{name: "setChannel", fn: setChannel},
+	{name: "getChannelWalls", fn: getChannelWalls},
+	{name: "setBump", fn: setBump},
+	{name: "getBumpSurface", fn: getBumpSurface},
+	{name: "setRotor", fn: setRotor},
+	{name: "setCollision", fn: setCollision},
+	{name: "setObjectPosition", fn: setObjectPosition},
+	{name: "setObjectMotion", fn: setObjectMotion},
+	{name: "setObjectTarget", fn: setObjectTarget},
+	{name: "setObjectsPacked", fn: setObjectsPacked},
+	{name: "getObjectsPacked", fn: getObjectsPacked},
+	{name: "setFarFieldCutoff", fn: setFarFieldCutoff},
+	{name: "setLOD", fn: setLOD},
+	{name: "setSymmetry", fn: setSymmetry},
+	{name: "setDensityControl", fn: setDensityControl},
+	{name: "setTurbulence", fn: setTurbulence},
+	{name: "getParticleRemap", fn: getParticleRemap},
+	{name: "defineInjector", fn: defineInjector},
+	{name: "updateInjector", fn: updateInjector},
+	{name: "removeInjector", fn: removeInjector},
+	{name: "setParticleBudget", fn: setParticleBudget},
+	{name: "defineFlowProbe", fn: defineFlowProbe},
+	{name: "removeFlowProbe", fn: removeFlowProbe},
+	{name: "getProbeHistory", fn: getProbeHistory},
+	{name: "getState", fn: getState},
+	{name: "exportScenario", fn: exportScenario},
+	{name: "importScenario", fn: importScenario},
+	{name: "exportSnapshot", fn: exportSnapshot},
+	{name: "importSnapshot", fn: importSnapshot},
+	{name: "traceStreamlines", fn: traceStreamlines},
+	{name: "computeSurfaceStreamlines", fn: computeSurfaceStreamlines},
+	{name: "getDividingStreamline", fn: getDividingStreamline},
+	{name: "exportCSV", fn: exportCSV},
+	{name: "exportVTK", fn: exportVTK},
+	{name: "exportVTKStreamlines", fn: exportVTKStreamlines},
+	{name: "exportOBJ", fn: exportOBJ},
+	{name: "getObjectMesh", fn: getObjectMesh},
+	{name: "getObjectVersion", fn: getObjectVersion},
+	{name: "getSliceTexture", fn: getSliceTexture},
+	{name: "getVortexCriterion", fn: getVortexCriterion},
+	{name: "extractVortexCores", fn: extractVortexCores},
+	{name: "computeDensity", fn: computeDensity},
+	{name: "computeMassFlux", fn: computeMassFlux},
+	{name: "computeTangencyResidual", fn: computeTangencyResidual},
+	{name: "computeArithmeticError", fn: computeArithmeticError},
+	{name: "computeBernoulliResidual", fn: computeBernoulliResidual},
+	{name: "sortParticlesByDepth", fn: sortParticlesByDepth},
+	{name: "buildNeighborGrid", fn: buildNeighborGrid},
+	{name: "queryNeighbors", fn: queryNeighbors},
+	{name: "queryNeighborsBatch", fn: queryNeighborsBatch},
+	{name: "renderSliceImage", fn: renderSliceImage},
+	{name: "getInterleavedLayout", fn: getInterleavedLayout},
+	{name: "generateShader", fn: generateShader},
+	{name: "importSTL", fn: importSTL},
+	{name: "removePanelBody", fn: removePanelBody},
+	{name: "startRecording", fn: startRecording},
+	{name: "stopRecording", fn: stopRecording},
+	{name: "clearRecording", fn: clearRecording},
+	{name: "getRecordingInfo", fn: getRecordingInfo},
+	{name: "getRecordedFrame", fn: getRecordedFrame},
+	{name: "getCapabilities", fn: getCapabilities},
+	{name: "getBuildInfo", fn: getBuildInfo},
+	{name: "runBenchmark", fn: runBenchmark},
+	{name: "enableTiming", fn: enableTiming},
+	{name: "getTimings", fn: getTimings},
+	{name: "setLogLevel", fn: setLogLevel},
+	{name: "getLogs", fn: getLogs},
+	{name: "setWorkerCount", fn: setWorkerCount},
+	{name: "releaseBuffers", fn: releaseBuffers},
+}
+
+// call runs fn, turning a panic into an ErrInternal error so a bug in one
+// call doesn't take the whole Go runtime down, and logs the failure. While
+// timing is enabled it also records the call's heap allocations.
+func call(name string, fn binding, args []js.Value) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, flow.Errorf(flow.ErrInternal, "%s: %v", name, r)
+			flow.Log(flow.LogError, name, err.Error())
+		} else if err != nil && flow.LogEnabled(flow.LogInfo) {
+			flow.Log(flow.LogInfo, name, err.Error())
+		}
+	}()
+	if timing.enabled {
+		measureAllocations(name, func() { result, err = fn(args) })
+		return result, err
+	}
+	return fn(args)
+}
+
+// syncFunc wraps a binding that returns its result (or an Error) directly
+func syncFunc(name string, fn binding) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		result, err := call(name, fn, args)
+		if err != nil {
+			return jsError(err)
+		}
+		return result
+	})
+}
+
+// asyncFunc wraps a binding that returns a Promise resolving with its
+// result or rejecting with its Error
+func asyncFunc(name string, fn binding) js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		// Copy the arguments: the slice is only valid during the callback
+		args = append([]js.Value(nil), args...)
+		var executor js.Func
+		executor = js.FuncOf(func(this js.Value, p []js.Value) interface{} {
+			resolve, reject := p[0], p[1]
+			executor.Release()
+			go func() {
+				result, err := call(name, fn, args)
+				if err != nil {
+					reject.Invoke(jsError(err))
+					return
+				}
+				resolve.Invoke(result)
+			}()
+			return nil
+		})
+		return js.Global().Get("Promise").New(executor)
+	})
+}
+
+// Register functions to be callable from JavaScript
+//
+// Every function is set on globalThis and collected into an API object;
+// handleMessage (see dispatch.go) serves the same functions to Workers.
+// Once registration is done, globalThis.fluidSimReady is a Promise resolving
+// with that object, and a globalThis.onFluidSimReady callback set before the
+// module started is invoked with it.
+func registerCallbacks() {
+	global := js.Global()
+	api := global.Get("Object").New()
+	for _, e := range exports {
+		var f js.Func
+		if e.async {
+			f = asyncFunc(e.name, e.fn)
+		} else {
+			f = syncFunc(e.name, e.fn)
+		}
+		global.Set(e.name, f)
+		api.Set(e.name, f)
+	}
+
+	global.Set("handleMessage", js.FuncOf(handleMessage))
+
+	global.Set("fluidSimReady", global.Get("Promise").Call("resolve", api))
+	if onReady := global.Get("onFluidSimReady"); onReady.Type() == js.TypeFunction {
+		onReady.Invoke(api)
+	}
+}
@@ -0,0 +1,282 @@
+//go:build js && wasm
+// +build js,wasm
+
+// bench.go - Benchmark, kernel timings and worker count
+//
+// Every measurement uses performance.now and brackets only the Go kernels:
+// converting arguments and copying buffers between JavaScript and Go is
+// excluded, so the numbers show what the device can sustain once the
+// buffers are in place.
+//
+// setWorkerCount splits the particle kernels across goroutines. That only
+// runs them in parallel on builds with threads; on a plain wasm build the
+// goroutines share one thread and a single worker is fastest.
+package main
+
+import (
+	"math"
+	"sort"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// now returns performance.now() in milliseconds, falling back to Date.now
+func now() float64 {
+	if p := js.Global().Get("performance"); p.Type() == js.TypeObject {
+		return p.Call("now").Float()
+	}
+	return js.Global().Get("Date").Call("now").Float()
+}
+
+// timingStat accumulates the durations of one kernel
+type timingStat struct {
+	calls                   int
+	total, min, max, latest float64
+}
+
+// timing holds the per-kernel timings collected while enabled
+var timing struct {
+	enabled bool
+	kernels map[string]*timingStat
+}
+
+// timed runs fn, adding its duration to kernel's timings if timing is on
+func timed(kernel string, fn func() error) error {
+	if !timing.enabled {
+		return fn()
+	}
+	start := now()
+	err := fn()
+	d := now() - start
+	st := timing.kernels[kernel]
+	if st == nil {
+		st = &timingStat{min: d, max: d}
+		timing.kernels[kernel] = st
+	}
+	st.calls++
+	st.total += d
+	st.min, st.max, st.latest = math.Min(st.min, d), math.Max(st.max, d), d
+	return err
+}
+
+// enableTiming(on)
+//
+// Turns kernel timing on or off. Turning it on clears the timings
+// collected so far.
+func enableTiming(args []js.Value) (interface{}, error) {
+	if err := checkArgs("enableTiming", args, 1); err != nil {
+		return nil, err
+	}
+	timing.enabled = args[0].Truthy()
+	if timing.enabled {
+		timing.kernels = map[string]*timingStat{}
+	}
+	return nil, nil
+}
+
+// getTimings() returns the timings collected since enableTiming(true), as
+// {kernel: {calls, totalMs, meanMs, minMs, maxMs, lastMs}}. Kernels are
+// "velocities", "pressures", "interleave" and "advect" for the one-shot
+// functions, "step" and "partial" for the stateful API and "stepShared";
+// chunked evaluations count one velocities call per chunk. The heap
+// allocations of the last call are reported by
+// getState().allocations.lastCall while timing is on.
+func getTimings(args []js.Value) (interface{}, error) {
+	out := map[string]interface{}{}
+	for name, st := range timing.kernels {
+		out[name] = map[string]interface{}{
+			"calls":   st.calls,
+			"totalMs": st.total,
+			"meanMs":  st.total / float64(st.calls),
+			"minMs":   st.min,
+			"maxMs":   st.max,
+			"lastMs":  st.latest,
+		}
+	}
+	return out, nil
+}
+
+// setWorkerCount(n) sets the number of workers the particle kernels are
+// split across, 0 for the default (GOMAXPROCS, 1 without threads), and
+// returns the effective count. Results don't depend on it.
+func setWorkerCount(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setWorkerCount", args, 1); err != nil {
+		return nil, err
+	}
+	n, err := intArg("n", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := flow.SetWorkers(n); err != nil {
+		return nil, err
+	}
+	workerSetting = n
+	return flow.Workers(), nil
+}
+
+// workerSetting is the worker count last passed to setWorkerCount
+var workerSetting int
+
+// runBenchmark(counts, iterations[, options])
+//
+// Times the velocity kernel and the advection step on a synthetic scene, a
+// unit sphere in a unit free stream along +x with particles seeded
+// uniformly in [-4, 4]³, for each particle count in the counts array. The
+// stateful simulation is left untouched. Returns one entry per count:
+//
+//	{count, iterations,
+//	 velocities: {medianMs, p95Ms}, advect: {medianMs, p95Ms},
+//	 medianMs, p95Ms,     // velocities and advection together
+//	 particlesPerSecond,  // count / median, for both kernels
+//	 workers}             // the worker count used
+//
+// With options.workers, an array of worker counts, each entry also gets
+// scaling: [{workers, medianMs, p95Ms, particlesPerSecond, speedup}], one
+// measurement per worker count, speedup relative to the first; the worker
+// count set by setWorkerCount is restored afterwards.
+//
+// It runs synchronously and blocks for the whole measurement; call it from
+// a Worker for large counts.
+func runBenchmark(args []js.Value) (interface{}, error) {
+	if err := checkArgs("runBenchmark", args, 2); err != nil {
+		return nil, err
+	}
+	if !js.Global().Get("Array").Call("isArray", args[0]).Bool() {
+		return nil, flow.Errorf(flow.ErrBadArguments, "counts must be an array")
+	}
+	counts := make([]int, args[0].Length())
+	for i := range counts {
+		var err error
+		if counts[i], err = countArg(args[0].Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	iterations, err := intArg("iterations", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if iterations < 1 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "iterations must be positive, got %d", iterations)
+	}
+
+	var workers []int
+	if opts := optionalArg(args, 2); opts.Type() == js.TypeObject {
+		if v := opts.Get("workers"); !v.IsUndefined() {
+			if !js.Global().Get("Array").Call("isArray", v).Bool() || v.Length() == 0 {
+				return nil, flow.Errorf(flow.ErrBadArguments, "workers must be a non-empty array")
+			}
+			workers = make([]int, v.Length())
+			for i := range workers {
+				if workers[i], err = intArg("workers", v.Index(i)); err != nil {
+					return nil, err
+				}
+				if workers[i] < 1 || workers[i] > flow.MaxWorkers {
+					return nil, flow.Errorf(flow.ErrBadArguments, "workers must be between 1 and %d, got %d", flow.MaxWorkers, workers[i])
+				}
+			}
+		}
+	}
+
+	results := make([]interface{}, len(counts))
+	for i, n := range counts {
+		r, err := benchmark(n, iterations)
+		if err != nil {
+			return nil, err
+		}
+		if workers != nil {
+			if r["scaling"], err = scaling(n, iterations, workers); err != nil {
+				return nil, err
+			}
+		}
+		results[i] = r
+	}
+	return results, nil
+}
+
+// scaling benchmarks count particles once per worker count, restoring the
+// configured worker count afterwards
+func scaling(count, iterations int, workers []int) ([]interface{}, error) {
+	defer flow.SetWorkers(workerSetting)
+	out := make([]interface{}, len(workers))
+	var base float64
+	for i, w := range workers {
+		flow.SetWorkers(w)
+		r, err := benchmark(count, iterations)
+		if err != nil {
+			return nil, err
+		}
+		median := r["medianMs"].(float64)
+		if i == 0 {
+			base = median
+		}
+		speedup := 1.0
+		if median > 0 {
+			speedup = base / median
+		}
+		out[i] = map[string]interface{}{
+			"workers":            w,
+			"medianMs":           median,
+			"p95Ms":              r["p95Ms"],
+			"particlesPerSecond": r["particlesPerSecond"],
+			"speedup":            speedup,
+		}
+	}
+	return out, nil
+}
+
+// benchmark measures count particles over the given number of iterations,
+// after one untimed warm-up step
+func benchmark(count, iterations int) (map[string]interface{}, error) {
+	c := flow.DefaultConfig()
+	f := c.Flow()
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: count, Seed: 1,
+		Grid: flow.Grid{Min: [3]float64{-4, -4, -4}, Max: [3]float64{4, 4, 4}}}
+	positions, err := seed.Positions()
+	if err != nil {
+		return nil, err
+	}
+	velocities := make([]float32, count*3)
+	if err := flow.VelocitiesInto(velocities, positions, count, f); err != nil {
+		return nil, err
+	}
+
+	vel, adv, total := make([]float64, iterations), make([]float64, iterations), make([]float64, iterations)
+	for k := 0; k < iterations; k++ {
+		t0 := now()
+		flow.VelocitiesInto(velocities, positions, count, f)
+		t1 := now()
+		flow.Advect(positions, velocities, count, flow.DefaultDT)
+		t2 := now()
+		vel[k], adv[k], total[k] = t1-t0, t2-t1, t2-t0
+	}
+
+	summary := func(d []float64) map[string]interface{} {
+		return map[string]interface{}{"medianMs": percentile(d, 0.5), "p95Ms": percentile(d, 0.95)}
+	}
+	median := percentile(total, 0.5)
+	rate := math.Inf(1)
+	if median > 0 {
+		rate = float64(count) / (median / 1000)
+	}
+	return map[string]interface{}{
+		"count":              count,
+		"iterations":         iterations,
+		"velocities":         summary(vel),
+		"advect":             summary(adv),
+		"medianMs":           median,
+		"p95Ms":              percentile(total, 0.95),
+		"particlesPerSecond": rate,
+		"workers":            flow.Workers(),
+	}, nil
+}
+
+// percentile returns the nearest-rank q quantile of d, sorting d in place
+func percentile(d []float64, q float64) float64 {
+	sort.Float64s(d)
+	k := int(math.Ceil(q*float64(len(d)))) - 1
+	if k < 0 {
+		k = 0
+	}
+	return d[k]
+}
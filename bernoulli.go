@@ -0,0 +1,84 @@
+//go:build js && wasm
+// +build js,wasm
+
+// bernoulli.go - Energy consistency of the flow
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// computeBernoulliResidual(where[, freeStreamVelocity, fluidDensity, objectX, objectY, objectZ, objectType, objectRadius])
+//
+// Measures where the flow breaks Bernoulli's equation: the deviation of
+// the total pressure p + ½ρ|v|² from its value upstream, over ½ρU∞², with
+// p the pressure of the steady momentum equation, integrated over the
+// Lamb vector v × ω along the free stream line through each point from 20
+// object radii upstream. It vanishes wherever the flow is irrotational and
+// lights up the terms that aren't: the cylinder's spanwise flow off z = 0,
+// the airfoil's lift model, swirl, cored vortex elements. where is a grid
+// {min, max, resolution}, as for exportVTK, with x varying fastest, or an
+// array of x, y, z positions. The seven flow parameters are those of
+// updateVelocities; without them the flow is the stateful API's. Returns
+// {residuals, max, rms, worst, samples, excluded, unsteady}: a
+// Float32Array of the signed residual of each point, 0 out of the fluid,
+// the largest and RMS magnitude over the samples points in the fluid, the
+// point [x, y, z] of the largest, the number of points out of the fluid,
+// and whether the stateful API's flow is unsteady, its free stream
+// following a schedule or its object accelerating: the residuals are
+// those of the flow at the instant, without the ∂φ/∂t of the unsteady
+// Bernoulli equation, and don't measure its energy balance then.
+// Positions are in the length unit of setUnits; see
+// flow.BernoulliResidual.
+func computeBernoulliResidual(args []js.Value) (interface{}, error) {
+	if err := checkArgs("computeBernoulliResidual", args, 1); err != nil {
+		return nil, err
+	}
+	f, unsteady := sim.Flow(), sim.Unsteady()
+	if len(args) > 1 {
+		if err := checkArgs("computeBernoulliResidual", args, 8); err != nil {
+			return nil, err
+		}
+		var err error
+		if f, err = flowArgs(args[1:8]); err != nil {
+			return nil, err
+		}
+		unsteady = false
+	}
+	var b flow.BernoulliResiduals
+	var err error
+	switch where := args[0]; {
+	case where.Type() != js.TypeObject:
+		return nil, flow.Errorf(flow.ErrBadArguments, "computeBernoulliResidual: where must be a grid or an array of positions")
+	case where.Get("length").Type() == js.TypeNumber:
+		n := where.Length() / 3
+		var p []float64
+		if p, err = floatsFromJS[float64]("positions", where, n, 3); err != nil {
+			return nil, err
+		}
+		defer putBuffer(p)
+		toSI(p, flow.DimLength)
+		b, err = flow.BernoulliResidual(p, n, f)
+	default:
+		var g flow.Grid
+		if g, err = flow.DecodeGrid(goValueSI(where, flow.GridDims)); err != nil {
+			return nil, err
+		}
+		b, err = flow.BernoulliResidual(g.Positions(), g.Len(), f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	w := b.Summary.Worst
+	return map[string]interface{}{
+		"residuals": floatsToJS(b.Values),
+		"max":       b.Summary.Max,
+		"rms":       b.Summary.RMS,
+		"worst":     []interface{}{units.FromSI(flow.DimLength, w[0]), units.FromSI(flow.DimLength, w[1]), units.FromSI(flow.DimLength, w[2])},
+		"samples":   b.Summary.Samples,
+		"excluded":  b.Excluded,
+		"unsteady":  unsteady,
+	}, nil
+}
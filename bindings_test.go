@@ -0,0 +1,339 @@
+//go:build js && wasm
+// +build js,wasm
+
+// bindings_test.go - Tests of the JavaScript bindings
+//
+// Run under Node with
+//
+//	GOOS=js GOARCH=wasm go test -exec="$(go env GOROOT)/lib/wasm/go_js_wasm_exec" .
+package main
+
+import (
+	"strings"
+	"syscall/js"
+	"testing"
+	"time"
+
+	"fluid_simulation/internal/flow"
+)
+
+// sphereArgs are the positional flow parameters of updateVelocities for a
+// unit sphere at the origin in a unit free stream
+var sphereArgs = []interface{}{1, 1, 0, 0, 0, int(flow.Sphere), 1}
+
+// invoke calls the binding fn with args converted by js.ValueOf
+func invoke(t *testing.T, fn binding, args ...interface{}) js.Value {
+	t.Helper()
+	v := make([]js.Value, len(args))
+	for i, a := range args {
+		v[i] = js.ValueOf(a)
+	}
+	r, err := fn(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return js.ValueOf(r)
+}
+
+// fails reports whether fn rejects args with the error code
+func fails(fn binding, code string, args ...interface{}) bool {
+	v := make([]js.Value, len(args))
+	for i, a := range args {
+		v[i] = js.ValueOf(a)
+	}
+	_, err := fn(v)
+	e, ok := err.(*flow.Error)
+	return ok && e.Code == code
+}
+
+// float32s returns the first n elements of a typed array
+func float32s(v js.Value, n int) []float32 {
+	out := make([]float32, n)
+	for i := range out {
+		out[i] = float32(v.Index(i).Float())
+	}
+	return out
+}
+
+func TestPlanarLayout(t *testing.T) {
+	const n = 4
+	p := []float32{-3, 0.5, 0.2, 2, 1, 0, 0, 2, 0.3, 5, -1, 1}
+	blocks := make([]float32, 3*n)
+	flow.ToPlanar(blocks, p, n)
+	xs, ys, zs := floatsToJS(blocks[:n]), floatsToJS(blocks[n:2*n]), floatsToJS(blocks[2*n:])
+	split := []interface{}{xs, ys, zs}
+	planar := map[string]interface{}{"layout": flow.VectorsPlanar}
+
+	v := float32s(invoke(t, updateVelocities, append([]interface{}{floatsToJS(p), n}, sphereArgs...)...), 3*n)
+	vs := invoke(t, updateVelocities, append(append([]interface{}{split, n}, sphereArgs...), planar)...)
+	vc := float32s(invoke(t, updateVelocities, append(append([]interface{}{floatsToJS(blocks), n}, sphereArgs...), planar)...), 3*n)
+	for i := 0; i < n; i++ {
+		for a := 0; a < 3; a++ {
+			if got := float32(vs.Index(a).Index(i).Float()); got != v[i*3+a] {
+				t.Errorf("split velocity %d.%d = %g, want %g", i, a, got, v[i*3+a])
+			}
+			if vc[a*n+i] != v[i*3+a] {
+				t.Errorf("concatenated velocity %d.%d = %g, want %g", i, a, vc[a*n+i], v[i*3+a])
+			}
+		}
+	}
+
+	p0 := invoke(t, calculatePressure, floatsToJS(v), n, 1, 1)
+	p1 := invoke(t, calculatePressure, vs, n, 1, 1, planar)
+	for i := 0; i < n; i++ {
+		if p0.Index(i).Float() != p1.Index(i).Float() {
+			t.Errorf("pressure %d = %g, want %g", i, p1.Index(i).Float(), p0.Index(i).Float())
+		}
+	}
+
+	if !fails(updateVelocities, flow.ErrBadArguments, append([]interface{}{split, n}, sphereArgs...)...) {
+		t.Error("component arrays without layout planar accepted")
+	}
+	if !fails(advectPositions, flow.ErrBadArguments, split, floatsToJS(vc), n, 0.1, planar) {
+		t.Error("positions and velocities of different planar forms accepted")
+	}
+}
+
+func TestPackedObjects(t *testing.T) {
+	defer func(c flow.Config) { sim.SetConfig(c) }(sim.Config)
+	packed := []float32{float32(flow.Cylinder), 0, 0.5, 0, 2, 1.5, 0, 0, 0}
+	invoke(t, setObjectsPacked, floatsToJS(packed), 1)
+	got := float32s(invoke(t, getObjectsPacked), flow.ObjectStride)
+	for i := range packed {
+		if got[i] != packed[i] {
+			t.Errorf("packed[%d] = %g, want %g", i, got[i], packed[i])
+		}
+	}
+	if o := sim.Config.Object; o.Type != flow.Cylinder || o.Splitter != 1.5 {
+		t.Errorf("object %+v, want a cylinder with a splitter of 1.5", o)
+	}
+
+	packed[0] = 9
+	if !fails(setObjectsPacked, flow.ErrBadArguments, floatsToJS(packed), 1) {
+		t.Error("unknown type code accepted")
+	}
+	if !fails(setObjectsPacked, flow.ErrUnsupported, floatsToJS(make([]float32, 2*flow.ObjectStride)), 2) {
+		t.Error("two objects accepted")
+	}
+}
+
+func TestStepManyGrowing(t *testing.T) {
+	defer func(s *flow.Simulation) { sim = s }(sim)
+	sim = flow.NewSimulation(flow.DefaultConfig())
+	if err := sim.SetParticles([]float32{-4, -3, 2.5, -3, -3, 2.5}, 2); err != nil {
+		t.Fatal(err)
+	}
+	invoke(t, defineInjector, 1, []interface{}{-4, 1, 1}, 0.2, 128, []interface{}{1, 0, 0})
+	frames := float32Array.New(4 * 2 * 3)
+	counts := js.Global().Get("Uint32Array").New(4)
+	invoke(t, stepMany, 8, 1.0/64, map[string]interface{}{"every": 2, "frames": frames, "counts": counts})
+	if sim.Count() != 2+16 {
+		t.Fatalf("count %d after the steps, want 18", sim.Count())
+	}
+	for f := 0; f < 4; f++ {
+		if c := counts.Index(f).Int(); c != 2 {
+			t.Errorf("frame %d counts %d particles, want 2", f, c)
+		}
+	}
+	if x := frames.Index(3*2*3 + 3).Float(); x <= -3 {
+		t.Errorf("the last frame holds x = %g for the second particle, which started at -3", x)
+	}
+}
+
+// TestRegisteredCapacity injects into registered arrays with room for the
+// budget: they must be kept and receive every particle, while arrays
+// without room are dropped once the count outgrows them
+func TestRegisteredCapacity(t *testing.T) {
+	defer func(s *flow.Simulation) { sim = s }(sim)
+	sim = flow.NewSimulation(flow.DefaultConfig())
+	positions, velocities := float32Array.New(20*3), float32Array.New(20*3)
+	copyToJS(positions, []float32{-4, -3, 2.5, -3, -3, 2.5})
+	if n := invoke(t, registerParticles, positions, velocities, js.Undefined(), 2).Int(); n != 2 {
+		t.Fatalf("registered %d particles, want 2", n)
+	}
+	invoke(t, setParticleBudget, 20)
+	invoke(t, defineInjector, 1, []interface{}{-4, 1, 1}, 0.2, 320, []interface{}{1, 0, 0})
+	for i := 0; i < 4; i++ {
+		invoke(t, step, 1.0/64)
+		if !registered.positions.Equal(positions) || !registered.velocities.Equal(velocities) {
+			t.Fatalf("the registered arrays were dropped at %d particles, within their capacity of 20", sim.Count())
+		}
+	}
+	if sim.Count() != 20 {
+		t.Fatalf("count %d after the steps, want the budget of 20", sim.Count())
+	}
+	got := float32s(positions, 20*3)
+	for i, x := range sim.Positions {
+		if got[i] != x {
+			t.Fatalf("positions[%d] = %g in the registered array, want %g", i, got[i], x)
+		}
+	}
+
+	if !fails(registerParticles, flow.ErrBufferLength, positions, velocities, js.Undefined(), 21) {
+		t.Error("count past the capacity of positions accepted")
+	}
+	invoke(t, registerParticles, float32Array.New(2*3))
+	invoke(t, step, 1.0/64)
+	if !registered.positions.IsUndefined() {
+		t.Errorf("arrays of 2 particles kept at %d particles", sim.Count())
+	}
+}
+
+func TestDegenerateArguments(t *testing.T) {
+	p := floatsToJS([]float32{-2, 0.5, 0, 1, 1, 0})
+	v := floatsToJS([]float32{1, 0, 0, 0.5, 0.5, 0})
+	tests := []struct {
+		name string
+		fn   binding
+		args []interface{}
+	}{
+		{"negative radius", updateVelocities, []interface{}{p, 2, 1, 1, 0, 0, 0, int(flow.Sphere), -1}},
+		{"negative density", updateVelocities, []interface{}{p, 2, 1, -1.2, 0, 0, 0, int(flow.Sphere), 1}},
+		{"unknown object type", updateVelocities, []interface{}{p, 2, 1, 1, 0, 0, 0, 9, 1}},
+		{"negative pressure density", calculatePressure, []interface{}{v, 2, 1, -1.2}},
+		{"U∞ = 0 isentropic ratios", calculateIsentropic, []interface{}{v, 2, 0, 0.3}},
+	}
+	for _, tt := range tests {
+		if !fails(tt.fn, flow.ErrBadArguments, tt.args...) {
+			t.Errorf("%s accepted", tt.name)
+		}
+	}
+
+	for _, args := range [][]interface{}{
+		{p, 2, 1, 1, 0, 0, 0, int(flow.Sphere), 0},
+		{p, 2, 0, 1, 0, 0, 0, int(flow.Sphere), 1},
+	} {
+		u := args[2].(int)
+		got := float32s(invoke(t, updateVelocities, args...), 6)
+		for i := 0; i < 2; i++ {
+			if got[i*3] != float32(u) || got[i*3+1] != 0 || got[i*3+2] != 0 {
+				t.Errorf("radius %v, U∞ %d: velocity %d = %v, want the free stream", args[8], u, i, got[i*3:i*3+3])
+			}
+		}
+	}
+}
+
+func TestEventNames(t *testing.T) {
+	fn := js.FuncOf(func(js.Value, []js.Value) interface{} { return nil })
+	defer fn.Release()
+	for _, name := range []string{"particleRespawned", "particleExitedDomain", "stagnationPointsMerged"} {
+		if !fails(registerEventCallback, flow.ErrBadArguments, name, fn) {
+			t.Errorf("%s accepted, but never fires", name)
+		}
+	}
+	invoke(t, registerEventCallback, flow.EventEnteredObject, fn)
+	invoke(t, registerEventCallback, flow.EventEnteredObject, nil)
+}
+
+func TestImportSTLFlagged(t *testing.T) {
+	const stl = `solid tetra
+facet normal 0 0 -1
+outer loop
+vertex 0 0 0
+vertex 0 1 0
+vertex 1 0 0
+endloop
+endfacet
+facet normal 0 -1 0
+outer loop
+vertex 0 0 0
+vertex 1 0 0
+vertex 0 0 1
+endloop
+endfacet
+facet normal -1 0 0
+outer loop
+vertex 0 0 0
+vertex 0 0 1
+vertex 0 1 0
+endloop
+endfacet
+facet normal 1 1 1
+outer loop
+vertex 1 0 0
+vertex 0 1 0
+vertex 0 0 1
+endloop
+endfacet
+endsolid tetra
+`
+	b := uint8sToJS([]byte(stl))
+	r := invoke(t, importSTL, b)
+	defer invoke(t, removePanelBody, r.Get("id").Int())
+	if r.Get("affectsFlow").Bool() {
+		t.Error("an imported body reported as affecting the flow")
+	}
+	if w := r.Get("warnings"); w.Length() == 0 || !strings.Contains(w.Index(0).String(), "don't affect the flow") {
+		t.Error("no warning that the body doesn't affect the flow")
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	c := invoke(t, getCapabilities)
+	f := c.Get("features")
+	if !f.Get("panelSolver").Bool() {
+		t.Error("panelSolver false; the box panels are built in")
+	}
+	if f.Get("multiObject").Bool() {
+		t.Error("multiObject true without a registry of objects to cull")
+	}
+	if n := c.Get("maxObjects").Int(); n != 1 {
+		t.Errorf("maxObjects %d, want the one analytic object", n)
+	}
+	if n := c.Get("maxElements").Int(); n != flow.MaxElements {
+		t.Errorf("maxElements %d, want %d", n, flow.MaxElements)
+	}
+}
+
+// messagePort returns a port whose postMessage clones the message with its
+// transfer list, throwing as a real one does, and the array it collects
+// the messages in
+func messagePort() (port, messages js.Value) {
+	messages = js.Global().Get("Array").New()
+	port = js.Global().Get("Function").New("messages", `return {postMessage(m, transfer) {
+		structuredClone(m, {transfer});
+		messages.push(m);
+	}}`).Invoke(messages)
+	return port, messages
+}
+
+func TestDispatchShared(t *testing.T) {
+	sab := js.Global().Get("SharedArrayBuffer").New(6 * 4)
+	positions := float32Array.New(sab)
+	copyToJS(positions, []float32{-2, 0.5, 0, 1, 1, 0})
+	velocities := floatsToJS([]float32{1, 0, 0, 0.5, 0.5, 0})
+	port, messages := messagePort()
+	handleMessage(js.Undefined(), []js.Value{js.ValueOf(map[string]interface{}{
+		"id": 7, "method": "advectPositions", "args": []interface{}{positions, velocities, 2, 0.1},
+	}), port})
+	for i := 0; i < 100 && messages.Length() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if messages.Length() != 1 {
+		t.Fatalf("%d replies, want 1", messages.Length())
+	}
+	m := messages.Index(0)
+	if e := m.Get("error"); !e.IsUndefined() {
+		t.Fatalf("error reply %s", e.Get("message"))
+	}
+	if !m.Get("result").Get("buffer").InstanceOf(js.Global().Get("SharedArrayBuffer")) {
+		t.Error("the result is not the shared positions")
+	}
+	if x := positions.Index(0).Float(); x != -1.9 && float32(x) != -1.9 {
+		t.Errorf("x = %g after the step, want -1.9", x)
+	}
+
+	// A postMessage that throws gets an error reply in its place
+	messages = js.Global().Get("Array").New()
+	port = js.Global().Get("Function").New("messages", `let calls = 0; return {postMessage(m) {
+		if (calls++ == 0) throw new TypeError("cannot post");
+		messages.push(m);
+	}}`).Invoke(messages)
+	handleMessage(js.Undefined(), []js.Value{js.ValueOf(map[string]interface{}{"id": 8, "method": "getCapabilities"}), port})
+	for i := 0; i < 100 && messages.Length() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if messages.Length() != 1 || messages.Index(0).Get("error").Get("code").String() != flow.ErrInternal {
+		t.Errorf("no %s reply after postMessage threw", flow.ErrInternal)
+	}
+}
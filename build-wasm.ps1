@@ -1,4 +1,34 @@
 # Build script for 3D Fluid Dynamics WebAssembly module on Windows
+#
+# -WasmExport also builds wasm/fluid_sim_exports.wasm, the go:wasmexport
+# reactor module used by js/wasm_exports.js (requires Go 1.24+)
+#
+# -TinyGo also builds wasm/fluid_sim_tinygo.wasm with TinyGo and copies
+# TinyGo's wasm_exec.js to js/wasm_exec_tinygo.js. TinyGo's runtime glue is
+# not interchangeable with Go's: pages loading the TinyGo module must include
+# js/wasm_exec_tinygo.js instead of js/wasm_exec.js and set
+# window.FLUID_SIM_WASM_URL = 'wasm/fluid_sim_tinygo.wasm'. The kernels are
+# checked against the golden snapshots (within 4 ulp, since TinyGo's math
+# routines may round differently) and the raw and gzipped sizes of both
+# builds are reported.
+#
+# -Version sets the version reported by getBuildInfo (default "dev"); the
+# git revision of the checkout is always stamped alongside it.
+param(
+    [switch]$WasmExport,
+    [switch]$TinyGo,
+    [string]$Version = "dev"
+)
+
+# Size of a file after gzip compression, as served by most hosts
+function Get-GzipSize($path) {
+    $bytes = [System.IO.File]::ReadAllBytes($path)
+    $stream = New-Object System.IO.MemoryStream
+    $gzip = New-Object System.IO.Compression.GZipStream($stream, [System.IO.Compression.CompressionLevel]::Optimal)
+    $gzip.Write($bytes, 0, $bytes.Length)
+    $gzip.Close()
+    return $stream.ToArray().Length
+}
 
 # Check Go version first
 $goVersion = (go version) -replace "go version go([0-9]+\.[0-9]+\.[0-9]+).*", '$1'
@@ -97,7 +127,7 @@ if ($LASTEXITCODE -ne 0) {
 }
 
 # Build the Go code to WebAssembly
-Write-Host "Compiling fluid_sim.go to WebAssembly..." -ForegroundColor Cyan
+Write-Host "Compiling the fluid_simulation package to WebAssembly..." -ForegroundColor Cyan
 
 # Add WebAssembly build tag to fluid_sim.go if not already present
 $fluidSimContent = Get-Content -Path "fluid_sim.go" -Raw
@@ -112,8 +142,12 @@ $fluidSimContent
     Write-Host "Added WebAssembly build tags to fluid_sim.go" -ForegroundColor Green
 }
 
-# Try the build
-go build -o .\wasm\fluid_sim.wasm fluid_sim.go
+# Version and revision reported by getBuildInfo
+$revision = (git rev-parse HEAD 2>$null)
+$ldflags = "-X main.version=$Version -X main.revision=$revision"
+
+# Try the build (the whole package: bindings plus the internal/flow core)
+go build -ldflags $ldflags -o .\wasm\fluid_sim.wasm .
 
 if ($LASTEXITCODE -eq 0) {
     Write-Host "Build successful!" -ForegroundColor Green
@@ -133,6 +167,47 @@ if ($LASTEXITCODE -eq 0) {
     Write-Host "  python -m http.server 8000" -ForegroundColor Yellow
     Write-Host "Then open http://localhost:8000 in your browser" -ForegroundColor Yellow
     
+    if ($WasmExport) {
+        Write-Host "Building go:wasmexport module..." -ForegroundColor Cyan
+        $env:GOOS = "wasip1"
+        go build -buildmode=c-shared -o .\wasm\fluid_sim_exports.wasm .
+        $env:GOOS = "js"
+        if ($LASTEXITCODE -ne 0) {
+            Write-Host "go:wasmexport build failed (Go 1.24+ is required)" -ForegroundColor Red
+            exit 1
+        }
+        Write-Host "  - wasm\fluid_sim_exports.wasm" -ForegroundColor Green
+    }
+    
+    if ($TinyGo) {
+        if (-not (Get-Command tinygo -ErrorAction SilentlyContinue)) {
+            Write-Host "TinyGo not found in PATH" -ForegroundColor Red
+            exit 1
+        }
+        Write-Host "Building TinyGo module..." -ForegroundColor Cyan
+        tinygo build -target wasm -no-debug -ldflags $ldflags -o .\wasm\fluid_sim_tinygo.wasm .
+        if ($LASTEXITCODE -ne 0) {
+            Write-Host "TinyGo build failed!" -ForegroundColor Red
+            exit 1
+        }
+        $tinyGoRoot = tinygo env TINYGOROOT
+        Copy-Item (Join-Path $tinyGoRoot "targets\wasm_exec.js") -Destination ".\js\wasm_exec_tinygo.js" -Force
+
+        Write-Host "Checking TinyGo kernels against the golden snapshots..." -ForegroundColor Cyan
+        tinygo test -target wasip1 ./internal/flow -run TestGolden -args -ulps 4
+        if ($LASTEXITCODE -ne 0) {
+            Write-Host "TinyGo kernels do not match the golden snapshots!" -ForegroundColor Red
+            exit 1
+        }
+
+        $gcGzip = Get-GzipSize ".\wasm\fluid_sim.wasm"
+        $tinySize = (Get-Item ".\wasm\fluid_sim_tinygo.wasm").Length
+        $tinyGzip = Get-GzipSize ".\wasm\fluid_sim_tinygo.wasm"
+        Write-Host "Go build:     $($wasmSize) bytes ($($gcGzip) gzipped)" -ForegroundColor Green
+        Write-Host "TinyGo build: $($tinySize) bytes ($($tinyGzip) gzipped)" -ForegroundColor Green
+        Write-Host ("TinyGo build is {0:P0} of the Go build when gzipped" -f ($tinyGzip / $gcGzip)) -ForegroundColor Green
+    }
+    
     # Check for netlify.toml file and create if doesn't exist
     if (-not (Test-Path ".\netlify.toml")) {
         $netlifyContent = @'
@@ -0,0 +1,32 @@
+//go:build js && wasm
+// +build js,wasm
+
+// bump.go - Outline of the wall and its bump
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// getBumpSurface([n])
+//
+// Returns n points, 128 by default, of the wall set with setBump as a flat
+// Float64Array of x, y pairs, over the paneled stretch of five widths
+// either side of the bump's crest. Beyond it the wall is flat at y = 0.
+func getBumpSurface(args []js.Value) (interface{}, error) {
+	n := 128
+	if v := optionalArg(args, 0); !v.IsUndefined() {
+		var err error
+		if n, err = intArg("n", v); err != nil {
+			return nil, err
+		}
+	}
+	b := sim.Config.Bump
+	points, err := b.Surface(n)
+	if err != nil {
+		return nil, err
+	}
+	return floatsToJSIn(points, flow.DimLength), nil
+}
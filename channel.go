@@ -0,0 +1,33 @@
+//go:build js && wasm
+// +build js,wasm
+
+// channel.go - Outline of the converging-diverging channel
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// getChannelWalls([n])
+//
+// Returns n points, 128 by default, of the upper wall of the channel set
+// with setChannel as a flat Float64Array of x, y pairs, from the start of
+// its inlet to one inlet length past its diverging section. The lower wall
+// is its mirror image in y = 0.
+func getChannelWalls(args []js.Value) (interface{}, error) {
+	n := 128
+	if v := optionalArg(args, 0); !v.IsUndefined() {
+		var err error
+		if n, err = intArg("n", v); err != nil {
+			return nil, err
+		}
+	}
+	c := sim.Config.Channel
+	points, err := c.Walls(n)
+	if err != nil {
+		return nil, err
+	}
+	return floatsToJSIn(points, flow.DimLength), nil
+}
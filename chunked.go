@@ -0,0 +1,145 @@
+//go:build js && wasm
+// +build js,wasm
+
+// chunked.go - Chunked execution of long-running work
+//
+// A chunked call processes its work in slices of chunkSize items. After
+// each slice it reports progress and yields to the JavaScript event loop,
+// so the page stays responsive; the remaining state lives on the Go
+// goroutine in between. Chunked functions are async exports: they return a
+// Promise for the final result.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// defaultChunkSize is used when the options don't give one
+const defaultChunkSize = 50000
+
+// chunkOptions controls a chunked call
+//
+// JavaScript passes them as {chunkSize, onProgress, signal}: onProgress is
+// called with (done, total) after every chunk, and signal is an AbortSignal
+// (or any object with an "aborted" property) checked between chunks.
+type chunkOptions struct {
+	chunkSize  int
+	onProgress js.Value
+	signal     js.Value
+}
+
+// chunkOptionsArg reads chunk options from an optional argument
+func chunkOptionsArg(v js.Value) (chunkOptions, error) {
+	opts := chunkOptions{chunkSize: defaultChunkSize}
+	if v.IsUndefined() || v.IsNull() {
+		return opts, nil
+	}
+	if v.Type() != js.TypeObject {
+		return opts, flow.Errorf(flow.ErrBadArguments, "options must be an object")
+	}
+	if size := v.Get("chunkSize"); !size.IsUndefined() {
+		n, err := intArg("chunkSize", size)
+		if err != nil {
+			return opts, err
+		}
+		if n < 1 {
+			return opts, flow.Errorf(flow.ErrBadArguments, "chunkSize must be at least 1, got %d", n)
+		}
+		opts.chunkSize = n
+	}
+	if cb := v.Get("onProgress"); !cb.IsUndefined() {
+		if cb.Type() != js.TypeFunction {
+			return opts, flow.Errorf(flow.ErrBadArguments, "onProgress must be a function")
+		}
+		opts.onProgress = cb
+	}
+	opts.signal = v.Get("signal")
+	return opts, nil
+}
+
+// cancelled reports whether the caller aborted the call
+func (o *chunkOptions) cancelled() bool {
+	return o.signal.Type() == js.TypeObject && o.signal.Get("aborted").Truthy()
+}
+
+// yield blocks the calling goroutine until the JS event loop has had a turn
+func yield() {
+	done := make(chan struct{})
+	var cb js.Func
+	cb = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		cb.Release()
+		close(done)
+		return nil
+	})
+	js.Global().Call("setTimeout", cb, 0)
+	<-done
+}
+
+// runChunked calls step for consecutive [from, to) ranges covering total
+// items, reporting progress and yielding between them. It must run on its
+// own goroutine (as async exports do). Cancellation abandons the remaining
+// work and returns an ErrCancelled error.
+func runChunked(total int, opts chunkOptions, step func(from, to int) error) error {
+	for from := 0; from < total || from == 0; from += opts.chunkSize {
+		if opts.cancelled() {
+			return flow.Errorf(flow.ErrCancelled, "cancelled after %d of %d items", from, total)
+		}
+		to := from + opts.chunkSize
+		if to > total {
+			to = total
+		}
+		if err := step(from, to); err != nil {
+			return err
+		}
+		if opts.onProgress.Type() == js.TypeFunction {
+			opts.onProgress.Invoke(to, total)
+		}
+		if to >= total {
+			break
+		}
+		yield()
+	}
+	return nil
+}
+
+// updateVelocitiesChunked is updateVelocities processed in chunks
+//
+// It takes the same nine arguments followed by chunk options (which may also
+// carry outputPrecision and layout) and returns a Promise for the typed array of
+// velocities. The positions are copied when the call starts, so later
+// changes to the array don't affect the result.
+func updateVelocitiesChunked(args []js.Value) (interface{}, error) {
+	if err := checkArgs("updateVelocitiesChunked", args, 9); err != nil {
+		return nil, err
+	}
+	count, f, err := velocityArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	options := optionalArg(args, 9)
+	opts, err := chunkOptionsArg(options)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := layoutOption(options)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := vectorsArg("positions", args[0], layout)
+	if err != nil {
+		return nil, err
+	}
+	precision, err := precisionOption(options, positions.precision())
+	if err != nil {
+		return nil, err
+	}
+
+	return evalVelocities(positions, count, f, precision, velocityOutputs{speeds: js.Undefined()}, func(total int, step func(from, to int) error) error {
+		return runChunked(total, opts, step)
+	})
+}
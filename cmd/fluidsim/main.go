@@ -0,0 +1,244 @@
+// Command fluidsim runs the flow kernels offline, without a browser.
+//
+// It reads a JSON scenario (object, free stream, particle seeding, dt and
+// number of steps) and writes either the velocity and pressure fields
+// sampled on a structured grid or the particle trajectories. It drives the
+// same internal/flow kernels as the WebAssembly module, so browser results
+// can be reproduced offline.
+//
+// Usage:
+//
+//	fluidsim -scenario scene.json -mode field -grid 64x32x1 -o field.csv
+//	fluidsim -scenario scene.json -mode trajectories -steps 500 -every 10 -o traj.csv
+//	fluidsim -scenario scene.json -mode image -plane xy -size 512x512 -field cp -range=-3,1 -streamlines 12 -o cp.png
+//
+// The image mode renders a PNG heatmap of a field on a plane slice, with
+// axis extents, a colorbar and optionally streamlines seeded evenly along
+// the slice's left edge.
+//
+// -workers splits the particle kernels across that many goroutines
+// (default GOMAXPROCS); the output is identical for any worker count.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"fluid_simulation/internal/flow"
+)
+
+func main() {
+	scenarioPath := flag.String("scenario", "", "scenario JSON file (required)")
+	mode := flag.String("mode", "field", `what to do: "field", "trajectories" or "image"`)
+	format := flag.String("format", "csv", `output format: "csv"`)
+	output := flag.String("o", "-", `output file, "-" for stdout`)
+	gridFlag := flag.String("grid", "", "grid resolution NXxNYxNZ, overrides the scenario")
+	steps := flag.Int("steps", -1, "number of steps, overrides the scenario")
+	every := flag.Int("every", 1, "write trajectories every N steps")
+	var img imageFlags
+	flag.StringVar(&img.plane, "plane", "xy", `slice plane "xy", "xz" or "yz" (image mode)`)
+	flag.Float64Var(&img.offset, "offset", 0, "slice coordinate along the plane normal (image mode)")
+	flag.StringVar(&img.size, "size", "512x512", "plot size WxH in pixels (image mode)")
+	flag.StringVar(&img.field, "field", flow.FieldSpeed, "speed, pressure, cp, vx, vy, vz, q or lambda2 (image mode)")
+	flag.StringVar(&img.colorRange, "range", "0,2", "color range LO,HI (image mode)")
+	flag.StringVar(&img.colormap, "colormap", "viridis", "viridis, coolwarm or grayscale (image mode)")
+	flag.IntVar(&img.streamlines, "streamlines", 0, "number of streamlines to overlay (image mode)")
+	workers := flag.Int("workers", 0, "goroutines the particle kernels are split across, 0 for GOMAXPROCS")
+	flag.Parse()
+	if err := flow.SetWorkers(*workers); err != nil {
+		fmt.Fprintln(os.Stderr, "fluidsim:", err)
+		os.Exit(1)
+	}
+
+	if err := run(*scenarioPath, *mode, *format, *output, *gridFlag, *steps, *every, img); err != nil {
+		fmt.Fprintln(os.Stderr, "fluidsim:", err)
+		os.Exit(1)
+	}
+}
+
+func run(scenarioPath, mode, format, output, gridFlag string, steps, every int, img imageFlags) error {
+	if scenarioPath == "" {
+		return fmt.Errorf("-scenario is required")
+	}
+	if format != "csv" && mode != "image" {
+		return fmt.Errorf("unsupported format %q", format)
+	}
+	sc, err := loadScenario(scenarioPath)
+	if err != nil {
+		return err
+	}
+	if gridFlag != "" {
+		if _, err := fmt.Sscanf(gridFlag, "%dx%dx%d", &sc.Grid.Resolution[0], &sc.Grid.Resolution[1], &sc.Grid.Resolution[2]); err != nil {
+			return fmt.Errorf("invalid -grid %q, want NXxNYxNZ", gridFlag)
+		}
+	}
+	if steps >= 0 {
+		sc.Steps = steps
+	}
+	if every < 1 {
+		return fmt.Errorf("-every must be at least 1")
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+
+	switch mode {
+	case "field":
+		err = writeField(w, sc)
+	case "trajectories":
+		err = writeTrajectories(w, sc, every)
+	case "image":
+		err = writeImage(w, sc, img)
+	default:
+		err = fmt.Errorf("unknown mode %q", mode)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeField samples velocity and pressure on the scenario grid
+func writeField(w *bufio.Writer, sc *scenario) error {
+	f, err := sc.flow()
+	if err != nil {
+		return err
+	}
+	g := sc.grid()
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	positions := g.Positions()
+	count := g.Len()
+	velocities, err := flow.Velocities(positions, count, f)
+	if err != nil {
+		return err
+	}
+	pressures, err := flow.Pressures(velocities, count, f.FreeStream, f.Density)
+	if err != nil {
+		return err
+	}
+
+	w.WriteString("x,y,z,vx,vy,vz,p\n")
+	var buf []byte
+	for i := 0; i < count; i++ {
+		buf = appendRow(buf[:0], positions[i*3:i*3+3], velocities[i*3:i*3+3], pressures[i:i+1])
+		w.Write(buf)
+	}
+	return nil
+}
+
+// writeTrajectories advects the seeded particles and records their state
+func writeTrajectories(w *bufio.Writer, sc *scenario, every int) error {
+	f, err := sc.flow()
+	if err != nil {
+		return err
+	}
+	seed := sc.seedSpec()
+	positions, err := seed.Positions()
+	if err != nil {
+		return err
+	}
+	count := len(positions) / 3
+
+	w.WriteString("step,t,id,x,y,z,vx,vy,vz\n")
+	var buf []byte
+	for step := 0; step <= sc.Steps; step++ {
+		velocities, err := flow.Velocities(positions, count, f)
+		if err != nil {
+			return err
+		}
+		if step%every == 0 {
+			t := float64(step) * sc.Dt
+			for i := 0; i < count; i++ {
+				buf = strconv.AppendInt(buf[:0], int64(step), 10)
+				buf = append(buf, ',')
+				buf = strconv.AppendFloat(buf, t, 'g', -1, 64)
+				buf = append(buf, ',')
+				buf = strconv.AppendInt(buf, int64(i), 10)
+				buf = append(buf, ',')
+				buf = appendRow(buf, positions[i*3:i*3+3], velocities[i*3:i*3+3])
+				w.Write(buf)
+			}
+		}
+		if step < sc.Steps {
+			if err := flow.Advect(positions, velocities, count, sc.Dt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// imageFlags are the options of the image mode
+type imageFlags struct {
+	plane, size, field, colorRange, colormap string
+	offset                                   float64
+	streamlines                              int
+}
+
+// writeImage renders a field slice as a PNG
+func writeImage(w *bufio.Writer, sc *scenario, img imageFlags) error {
+	f, err := sc.flow()
+	if err != nil {
+		return err
+	}
+	s := flow.Slice{Plane: img.plane, Offset: img.offset}
+	if _, err := fmt.Sscanf(img.size, "%dx%d", &s.Width, &s.Height); err != nil {
+		return fmt.Errorf("invalid -size %q, want WxH", img.size)
+	}
+	var lo, hi float64
+	if _, err := fmt.Sscanf(img.colorRange, "%g,%g", &lo, &hi); err != nil {
+		return fmt.Errorf("invalid -range %q, want LO,HI", img.colorRange)
+	}
+	s.Min, s.Max = f.Object.SliceExtent(s.Plane)
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	// Seed the streamlines at the centers of equal parts of the left edge
+	var lines []flow.Polyline
+	for i := 0; i < img.streamlines; i++ {
+		x, y, z := s.At(s.Min[0], s.Min[1]+(float64(i)+0.5)*(s.Max[1]-s.Min[1])/float64(img.streamlines))
+		l, err := f.Streamline([3]float64{x, y, z}, 0.05, 1000)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, l)
+	}
+
+	data, err := flow.RenderSliceImage(f, s, img.field, lo, hi, img.colormap, lines, flow.SIUnits())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// appendRow appends the values as one comma-separated CSV line
+func appendRow(buf []byte, cols ...[]float32) []byte {
+	first := true
+	for _, col := range cols {
+		for _, v := range col {
+			if !first {
+				buf = append(buf, ',')
+			}
+			first = false
+			buf = strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+		}
+	}
+	return append(buf, '\n')
+}
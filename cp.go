@@ -0,0 +1,61 @@
+//go:build js && wasm
+// +build js,wasm
+
+// cp.go - Chordwise pressure distributions of airfoil sections
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// getCpDistribution(objectId[, nPoints])
+//
+// Samples the pressure coefficient on the upper and lower surfaces of the
+// simulation's airfoil section at nPoints stations, 100 by default,
+// cosine spaced along the chord so they crowd toward the edges without
+// reaching either: the thin airfoil of solveThinAirfoil while its flow is
+// the simulation's, else a plate or an elliptic cylinder, whose Cp comes
+// from the velocity just off its surface. Returns {x, cpUpper, cpLower,
+// cl, cm, leadingEdgeSingular}: parallel Float64Arrays of x/c from the
+// leading edge and the Cp there, the lift coefficient and the moment
+// coefficient about the quarter chord, nose up positive, integrated from
+// the same samples, and whether the leading edge Cp is singular, which the
+// stations beside it only approach. The thin airfoil's cl and cm are its
+// solution's; the elliptic cylinder's converge on the Kutta-Joukowski
+// lift; the plate's miss the leading edge suction, see flow.CpDistribution.
+func getCpDistribution(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getCpDistribution", args, 1); err != nil {
+		return nil, err
+	}
+	if err := objectArg("getCpDistribution", args[0]); err != nil {
+		return nil, err
+	}
+	n := flow.DefaultCpPoints
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		var err error
+		if n, err = intArg("nPoints", v); err != nil {
+			return nil, err
+		}
+	}
+	var d flow.CpDistribution
+	var err error
+	if airfoil, _ := builtSections(); airfoil != nil {
+		d, err = airfoil.CpDistribution(n)
+	} else {
+		f := sim.Flow()
+		d, err = f.CpDistribution(n)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"x":                   floatsToJS(d.X),
+		"cpUpper":             floatsToJS(d.Upper),
+		"cpLower":             floatsToJS(d.Lower),
+		"cl":                  d.CL,
+		"cm":                  d.CM,
+		"leadingEdgeSingular": d.LeadingEdge,
+	}, nil
+}
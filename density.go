@@ -0,0 +1,109 @@
+//go:build js && wasm
+// +build js,wasm
+
+// density.go - Particle density control
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// setDensityControl(options)
+//
+// Turns on the particle density control of step (see flow.DensityControl),
+// or turns it off if options is null or false. Every every steps the
+// particles are counted on a coarse grid over their bounding box; pairs
+// are merged in cells holding more than maxPerCell and particles split,
+// jittered about their parent with the local flow velocity, in cells
+// holding fewer than minPerCell. Options:
+//   - every = 10: the steps between passes
+//   - resolution = [16, 16, 16]: the cells of the grid
+//   - minPerCell = 2, maxPerCell = 32
+//   - count = "fixed": "fixed" matches merges and splits one for one,
+//     "floating" lets the count change within minCount = 0 and maxCount =
+//     0, no bound
+//   - jitter = 0.5: the largest offset of a split particle, in cells
+//
+// The particle arrays are compacted in place, survivors first, so after
+// a pass per-particle attributes kept in JavaScript follow through
+// getParticleRemap. Registered arrays stay registered while the count
+// holds and are dropped when a floating count changes; read the particles
+// back with getParticles. Returns getState().density.
+func setDensityControl(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setDensityControl", args, 1); err != nil {
+		return nil, err
+	}
+	opts := args[0]
+	if !opts.Truthy() {
+		sim.Density = nil
+		return js.ValueOf(sim.State()["density"]), nil
+	}
+	if opts.Type() != js.TypeObject {
+		return nil, flow.Errorf(flow.ErrBadArguments, "setDensityControl: options must be an object, null or false")
+	}
+	d := flow.DefaultDensityControl
+	for _, e := range []struct {
+		key string
+		dst *int
+	}{{"every", &d.Every}, {"minPerCell", &d.MinPerCell}, {"maxPerCell", &d.MaxPerCell}, {"minCount", &d.MinCount}, {"maxCount", &d.MaxCount}} {
+		if v := opts.Get(e.key); !v.IsUndefined() {
+			var err error
+			if *e.dst, err = intArg(e.key, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if v := opts.Get("jitter"); !v.IsUndefined() {
+		var err error
+		if d.Jitter, err = floatArg("jitter", v); err != nil {
+			return nil, err
+		}
+	}
+	if v := opts.Get("resolution"); !v.IsUndefined() {
+		if !js.Global().Get("Array").Call("isArray", v).Bool() || v.Length() != 3 {
+			return nil, flow.Errorf(flow.ErrBadArguments, "resolution must be an array of 3 integers")
+		}
+		for i := range d.Resolution {
+			var err error
+			if d.Resolution[i], err = intArg("resolution", v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	switch v := opts.Get("count"); {
+	case v.IsUndefined():
+	case v.Type() == js.TypeString && v.String() == "fixed":
+	case v.Type() == js.TypeString && v.String() == "floating":
+		d.Floating = true
+	default:
+		return nil, flow.Errorf(flow.ErrBadArguments, `count must be "fixed" or "floating"`)
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	sim.Density = &d
+	return js.ValueOf(sim.State()["density"]), nil
+}
+
+// getParticleRemap()
+//
+// Returns how the particles relate to those before the density control
+// passes since the last call, or since the particles were replaced, and
+// forgets it: {count, index, weight}, where index is an Int32Array giving
+// for each particle its index before, or its parent's if it was split off,
+// and weight a Float32Array of the number of particles before it stands
+// for, merges adding up, by which a renderer scales its size. Returns null
+// if there was no pass. See flow.Remap.
+func getParticleRemap(args []js.Value) (interface{}, error) {
+	r := sim.TakeRemap()
+	if r == nil {
+		return nil, nil
+	}
+	return map[string]interface{}{
+		"count":  len(r.Index),
+		"index":  int32sToJS(r.Index),
+		"weight": floatsToJS(r.Weight),
+	}, nil
+}
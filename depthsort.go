@@ -0,0 +1,109 @@
+//go:build js && wasm
+// +build js,wasm
+
+// depthsort.go - Back to front particle ordering
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// Scratch of sortParticlesByDepth, kept between frames
+var (
+	depthSorter flow.DepthSorter
+	depthOrder  []uint32
+)
+
+// sortParticlesByDepth(positions, count, cameraPos, cameraDir[, options])
+//
+// Orders count particles, an array of x, y, z positions, back to front
+// for alpha blending translucent sprites: their depth is the distance
+// from cameraPos = [x, y, z] along cameraDir, which need not be a unit
+// vector, and they are radix sorted on its float32 bits in linear time,
+// ties in index order. Particles with a NaN or infinite coordinate come
+// last. Options:
+//   - target: a Uint32Array of at least count elements to write the order
+//     to, in place of a new array
+//   - depths: true, or a Float32Array of at least count elements to write
+//     to, for the depth of each particle too, by particle index, NaN for
+//     those sorted last
+//
+// Returns {order, depths}, order the Uint32Array of particle indices
+// farthest first and depths the Float32Array, or undefined without the
+// option. Depths are in the length unit of positions and cameraPos. The
+// scratch buffers are kept from one call to the next, so with both
+// targets a frame loop allocates nothing once the count settles.
+func sortParticlesByDepth(args []js.Value) (interface{}, error) {
+	if err := checkArgs("sortParticlesByDepth", args, 4); err != nil {
+		return nil, err
+	}
+	n, err := countArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	camera, err := vectorArg("cameraPos", args[2])
+	if err != nil {
+		return nil, err
+	}
+	dir, err := vectorArg("cameraDir", args[3])
+	if err != nil {
+		return nil, err
+	}
+	var order, depths js.Value
+	if opts := optionalArg(args, 4); opts.Type() == js.TypeObject {
+		if v := opts.Get("target"); !v.IsUndefined() {
+			if !v.InstanceOf(js.Global().Get("Uint32Array")) {
+				return nil, flow.Errorf(flow.ErrBadArguments, "target must be a Uint32Array")
+			}
+			if err := flow.CheckBuffer("target", v.Length(), n, 1); err != nil {
+				return nil, err
+			}
+			order = v
+		}
+		if v := opts.Get("depths"); v.InstanceOf(float32Array) {
+			if err := flow.CheckBuffer("depths", v.Length(), n, 1); err != nil {
+				return nil, err
+			}
+			depths = v
+		} else if v.Truthy() {
+			depths = float32Array.New(n)
+		}
+	}
+
+	if cap(depthOrder) < n {
+		depthOrder = make([]uint32, n)
+	}
+	var d []float32
+	if !depths.IsUndefined() {
+		d = getBuffer[float32](n)
+		defer putBuffer(d)
+	}
+	if precisionOf(args[0]) == float64Precision {
+		err = sortByDepthAs[float64](args[0], n, camera, dir, depthOrder[:n], d)
+	} else {
+		err = sortByDepthAs[float32](args[0], n, camera, dir, depthOrder[:n], d)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if order.IsUndefined() {
+		order = js.Global().Get("Uint32Array").New(n)
+	}
+	copyUint32sToJS(order, depthOrder[:n])
+	if d != nil {
+		copyToJS(depths, d)
+	}
+	return map[string]interface{}{"order": order, "depths": depths}, nil
+}
+
+// sortByDepthAs runs flow.SortByDepth on positions read in T's precision
+func sortByDepthAs[T flow.Float](positions js.Value, n int, camera, dir [3]float64, order []uint32, depths []float32) error {
+	p, err := floatsFromJS[T]("positions", positions, n, 3)
+	if err != nil {
+		return err
+	}
+	defer putBuffer(p)
+	return flow.SortByDepth(&depthSorter, p, n, camera, dir, order, depths)
+}
@@ -0,0 +1,114 @@
+//go:build js && wasm
+// +build js,wasm
+
+// dispatch.go - postMessage protocol for running the module in a Worker
+//
+// handleMessage accepts {id, method, args} requests and answers each with a
+// {id, result} or {id, error: {code, message}} message. Every entry of the
+// exports table is reachable by name, so new functions need no separate
+// registration. Typed-array results are transferred, not copied, unless
+// they live in a SharedArrayBuffer.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// bindings maps export names to their Go implementation
+var bindings = map[string]binding{}
+
+func init() {
+	for _, e := range exports {
+		bindings[e.name] = e.fn
+	}
+}
+
+// handleMessage dispatches one request. Its first argument is the request
+// (or the MessageEvent carrying it); the optional second argument is the
+// port to reply on, defaulting to globalThis.postMessage.
+func handleMessage(this js.Value, args []js.Value) interface{} {
+	if len(args) == 0 || args[0].Type() != js.TypeObject {
+		return jsError(flow.Errorf(flow.ErrBadArguments, "handleMessage: expected a request object"))
+	}
+	msg := args[0]
+	if data := msg.Get("data"); data.Type() == js.TypeObject && !msg.Get("method").Truthy() {
+		msg = data
+	}
+	port := js.Global()
+	if p := optionalArg(args, 1); p.Type() == js.TypeObject {
+		port = p
+	}
+
+	id := msg.Get("id")
+	method := msg.Get("method")
+	var callArgs []js.Value
+	if a := msg.Get("args"); a.Type() == js.TypeObject {
+		for i := 0; i < a.Length(); i++ {
+			callArgs = append(callArgs, a.Index(i))
+		}
+	}
+
+	// Run on a goroutine like async exports, so chunked and blocking
+	// functions work through the dispatcher too
+	go func() {
+		var result interface{}
+		err := flow.Errorf(flow.ErrBadArguments, "handleMessage: unknown method %q", method.String())
+		if fn, ok := bindings[method.String()]; ok && method.Type() == js.TypeString {
+			result, err = call(method.String(), fn, callArgs)
+		}
+		reply(port, id, result, err)
+	}()
+	return nil
+}
+
+// reply posts the response to a request, transferring typed-array buffers.
+// If postMessage throws, an error reply without transfers is posted in its
+// place, so the request still gets an answer and the runtime survives.
+func reply(port js.Value, id js.Value, result interface{}, err error) {
+	resp, transfer := response(id, result, err)
+	if err := post(port, resp, transfer); err != nil {
+		resp, _ = response(id, nil, err)
+		post(port, resp, js.Global().Get("Array").New())
+	}
+}
+
+// response builds the {id, result} or {id, error} message of a request
+// and its transfer list: the buffer of a typed-array result if it is a
+// plain ArrayBuffer. SharedArrayBuffers, which advectPositions returns when
+// it works in place on shared positions, can't be transferred.
+func response(id js.Value, result interface{}, err error) (resp, transfer js.Value) {
+	resp = js.Global().Get("Object").New()
+	resp.Set("id", id)
+	transfer = js.Global().Get("Array").New()
+	if err != nil {
+		e := js.Global().Get("Object").New()
+		e.Set("code", flow.ErrBadArguments)
+		if fe, ok := err.(*flow.Error); ok {
+			e.Set("code", fe.Code)
+		}
+		e.Set("message", err.Error())
+		resp.Set("error", e)
+		return resp, transfer
+	}
+	value := js.ValueOf(result)
+	resp.Set("result", value)
+	if value.Type() == js.TypeObject && js.Global().Get("ArrayBuffer").Call("isView", value).Bool() {
+		if b := value.Get("buffer"); b.InstanceOf(js.Global().Get("ArrayBuffer")) {
+			transfer.Call("push", b)
+		}
+	}
+	return resp, transfer
+}
+
+// post calls port.postMessage, returning what it throws as an error
+func post(port, resp, transfer js.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = flow.Errorf(flow.ErrInternal, "handleMessage: postMessage failed: %v", r)
+		}
+	}()
+	port.Call("postMessage", resp, transfer)
+	return nil
+}
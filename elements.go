@@ -0,0 +1,169 @@
+//go:build js && wasm
+// +build js,wasm
+
+// elements.go - User-built flows from elementary flows
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// buildFlow(elements)
+//
+// Replaces the object solution by a superposition of elementary flows on
+// the free stream: elements is an array of {kind, position, orientation,
+// strength, coreRadius} with kind "uniform", "source", "sink", "doublet"
+// (a line doublet along z), "vortex" (an infinite line vortex),
+// "vortexLine" (a vortex segment from position to position + orientation)
+// or "doublet3d"; see flow.Element for the conventions and defaults. The
+// list is compiled once and used from then on by step and the field
+// queries of the simulation, and by updateVelocities, its chunked and
+// shared-buffer forms, whatever object they are given. The object only
+// bounds the region of zero velocity, so give it a radius of 0 for a flow
+// without a body. Pressures keep the free stream as their reference.
+//
+// "preset" builds the elements of the configured object instead, the same
+// field outside it: a doublet3d for a sphere and a doublet for a cylinder,
+// plus a source or vortex for the strengths it overrides; airfoils have no
+// preset. null goes back to the object solution, as does reset(false).
+// Scenarios and snapshots save the elements; generateShader doesn't
+// support them.
+//
+// Returns the elements built, normalized, or null. Positions and core
+// radii are in the length unit of setUnits; strengths, whose dimensions
+// depend on the kind, are always SI.
+func buildFlow(args []js.Value) (interface{}, error) {
+	if err := checkArgs("buildFlow", args, 1); err != nil {
+		return nil, err
+	}
+	v := args[0]
+	if v.IsNull() || v.IsUndefined() {
+		sim.Elements = nil
+		return nil, nil
+	}
+	var elements []flow.Element
+	if v.Type() == js.TypeString {
+		if v.String() != "preset" {
+			return nil, flow.Errorf(flow.ErrBadArguments, "buildFlow: unknown preset %q", v.String())
+		}
+		f := sim.Flow()
+		f.Elements = nil
+		var err error
+		if elements, err = f.Preset(); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if elements, err = flow.DecodeElements(goValueSI(v, flow.ElementDims)); err != nil {
+			return nil, err
+		}
+	}
+	sp, err := flow.NewSuperposition(elements)
+	if err != nil {
+		return nil, err
+	}
+	sim.Elements = sp
+	return js.ValueOf(units.Convert(sp.Encode(), flow.ElementDims, false)), nil
+}
+
+// sections holds the last thin airfoil solution of solveThinAirfoil or
+// tandem of solveTandem, with the elements built from it
+var sections struct {
+	airfoil  *flow.ThinAirfoilSolution
+	tandem   *flow.Tandem
+	elements *flow.Superposition
+}
+
+// builtSections returns the thin airfoil or the tandem in sections while
+// its elements are still the simulation's flow, nil otherwise
+func builtSections() (*flow.ThinAirfoilSolution, *flow.Tandem) {
+	if sections.elements == nil || sim.Elements != sections.elements {
+		return nil, nil
+	}
+	return sections.airfoil, sections.tandem
+}
+
+// solveThinAirfoil(airfoil)
+//
+// Solves thin-airfoil theory for a 2D section in the free stream and
+// builds the flow from it as buildFlow does: airfoil is {chord = 1,
+// position: [x, y, z] = [0, 0, 0] (the leading edge), alpha: degrees = 0,
+// camber: [[x/c, z/c], ...] or naca: "2412", vortices = 50, coreRadius =
+// chord/vortices, terms = 40}, see flow.DecodeThinAirfoil. The chord runs
+// along the cross-flow part of the free stream, pitched nose up by alpha.
+// The camber line's Glauert coefficients give the section's lift and
+// quarter-chord moment, and the vortex sheet, truncated to terms Fourier
+// terms, is lumped into vortices point vortices along the camber line
+// whose cores keep particles skimming the chord from being flung away.
+// The configured object keeps bounding a region of zero velocity, so give
+// it a radius of 0. buildFlow(null) removes the airfoil.
+//
+// Returns {cl, cmQuarter, circulation, zeroLiftAlpha, coefficients,
+// elements}: the section lift coefficient, the moment coefficient about
+// the quarter chord (nose up positive), the circulation per unit span
+// (clockwise, SI), the angle of zero lift in degrees, the Glauert
+// coefficients A0, A1, ... and the vortices. Lengths are in the length
+// unit of setUnits.
+func solveThinAirfoil(args []js.Value) (interface{}, error) {
+	if err := checkArgs("solveThinAirfoil", args, 1); err != nil {
+		return nil, err
+	}
+	a, err := flow.DecodeThinAirfoil(goValueSI(args[0], flow.ThinAirfoilDims))
+	if err != nil {
+		return nil, err
+	}
+	f := sim.Flow()
+	s, err := a.Solve(&f)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := flow.NewSuperposition(s.Elements)
+	if err != nil {
+		return nil, err
+	}
+	sim.Elements = sp
+	sections.airfoil, sections.tandem, sections.elements = s, nil, sp
+	return js.ValueOf(units.Convert(s.Encode(), flow.ThinAirfoilDims, false)), nil
+}
+
+// solveTandem(tandem)
+//
+// Solves the mutual interference of several lifting sections, flat plates
+// or cambered airfoils, in the free stream and builds the flow from their
+// bound vortices as buildFlow does: tandem is {sections: [{type: "plate" |
+// "airfoil", chord, position, alpha: degrees, camber | naca, coreRadius},
+// ...], panels = 1, gap, stagger}, see flow.DecodeTandem. Each section is
+// split into panels lumped vortices whose circulations are solved for
+// together, a 2×2 system for two sections of one panel. gap and stagger
+// place the second of two sections normal to and along the cross-flow
+// from the first; the solve is cheap enough to call again every frame
+// they change. Far apart, every section recovers its isolated lift.
+//
+// Returns {sections: [{circulation, isolatedCirculation, cl, isolatedCl,
+// lift, interference}, ...], elements}: per section the circulation
+// (clockwise) and lift per unit span, SI, the lift coefficient, the same
+// for the section alone, and the interference factor Γ/Γ_isolated.
+// Lengths are in the length unit of setUnits.
+func solveTandem(args []js.Value) (interface{}, error) {
+	if err := checkArgs("solveTandem", args, 1); err != nil {
+		return nil, err
+	}
+	t, err := flow.DecodeTandem(goValueSI(args[0], flow.TandemDims))
+	if err != nil {
+		return nil, err
+	}
+	f := sim.Flow()
+	s, err := t.Solve(&f)
+	if err != nil {
+		return nil, err
+	}
+	sp, err := flow.NewSuperposition(s.Elements)
+	if err != nil {
+		return nil, err
+	}
+	sim.Elements = sp
+	sections.airfoil, sections.tandem, sections.elements = nil, &t, sp
+	return js.ValueOf(units.Convert(s.Encode(), flow.TandemDims, false)), nil
+}
@@ -0,0 +1,95 @@
+//go:build js && wasm
+// +build js,wasm
+
+// events.go - Simulation event callbacks
+//
+// The steps of the stateful API only count events (see flow.EventLog);
+// step, stepMany and updatePartial then call the registered callbacks once
+// per event type that occurred, after the particles have moved and the
+// registered arrays are written. stepMany dispatches once for all its
+// substeps.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// eventCallbacks maps event names to their registered callbacks
+var eventCallbacks = map[string]js.Value{}
+
+// registerEventCallback(eventName, callback)
+//
+// Calls callback(payload) at the end of every step in which eventName
+// occurred, with payload {event, count, exampleIndices, time}: the number
+// of occurrences, the indices of up to flow.MaxEventExamples of the
+// particles involved and the simulation time. The one event is
+// "particleEnteredObject" (getCapabilities().events lists them); other
+// names fail. Registering another callback replaces the previous one;
+// null removes it.
+func registerEventCallback(args []js.Value) (interface{}, error) {
+	if err := checkArgs("registerEventCallback", args, 2); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeString || !flow.KnownEvent(args[0].String()) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "eventName must be one of %v", flow.Events())
+	}
+	name, fn := args[0].String(), args[1]
+	switch {
+	case fn.IsNull() || fn.IsUndefined():
+		delete(eventCallbacks, name)
+	case fn.Type() == js.TypeFunction:
+		eventCallbacks[name] = fn
+	default:
+		return nil, flow.Errorf(flow.ErrBadArguments, "callback must be a function or null")
+	}
+	switch {
+	case len(eventCallbacks) == 0:
+		sim.Events = nil
+	case sim.Events == nil:
+		sim.Events = &flow.EventLog{}
+	}
+	return nil, nil
+}
+
+// dispatchEvents drains the event log and calls the callbacks. Every
+// callback runs even if an earlier one throws; the first exception is then
+// returned, after the step itself has completed.
+func dispatchEvents() error {
+	if sim.Events == nil {
+		return nil
+	}
+	var first error
+	for _, ev := range sim.Events.Drain() {
+		fn, ok := eventCallbacks[ev.Name]
+		if !ok {
+			continue
+		}
+		examples := make([]interface{}, len(ev.Examples))
+		for i, x := range ev.Examples {
+			examples[i] = x
+		}
+		payload := map[string]interface{}{
+			"event":          ev.Name,
+			"count":          ev.Count,
+			"exampleIndices": examples,
+			"time":           sim.Time,
+		}
+		if err := invokeCallback(fn, js.ValueOf(payload)); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// invokeCallback calls fn, turning a JavaScript exception into an error
+func invokeCallback(fn, payload js.Value) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = flow.Errorf(flow.ErrInternal, "event callback threw: %v", r)
+		}
+	}()
+	fn.Invoke(payload)
+	return nil
+}
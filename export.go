@@ -0,0 +1,157 @@
+//go:build js && wasm
+// +build js,wasm
+
+// export.go - Data export for external tools
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// bytesToJS copies b into a new Uint8Array
+func bytesToJS(b []byte) js.Value {
+	out := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(out, b)
+	return out
+}
+
+// exportCSV(kind[, spec])
+//
+// Generates a CSV file with a header line for the current state of the
+// stateful API. kind is "particles", "grid", "surface" or "rake"; see
+// flow.DecodeCSVSpec for the spec of each. Lengths in spec and the values
+// written are in the units of setUnits, which the header names, e.g.
+// "x [mm]". The result is a Uint8Array of UTF-8 text, or a string with
+// spec.output set to "string".
+func exportCSV(args []js.Value) (interface{}, error) {
+	if err := checkArgs("exportCSV", args, 1); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeString {
+		return nil, flow.Errorf(flow.ErrBadArguments, "exportCSV: kind must be a string")
+	}
+	opts := optionalArg(args, 1)
+	asString := false
+	if opts.Type() == js.TypeObject {
+		switch out := opts.Get("output"); {
+		case out.IsUndefined():
+		case out.Type() == js.TypeString && out.String() == "string":
+			asString = true
+		case out.Type() == js.TypeString && out.String() == "bytes":
+		default:
+			return nil, flow.Errorf(flow.ErrBadArguments, `exportCSV: output must be "bytes" or "string"`)
+		}
+	}
+	spec, err := flow.DecodeCSVSpec(args[0].String(), goValueSI(opts, flow.CSVDims, "output"))
+	if err != nil {
+		return nil, err
+	}
+	spec.Units = units
+	b, err := flow.ExportCSV(spec, sim)
+	if err != nil {
+		return nil, err
+	}
+	if asString {
+		return string(b), nil
+	}
+	return bytesToJS(b), nil
+}
+
+// exportVTK(gridSpec[, fields])
+//
+// Samples the flow of the stateful API on the grid {min, max, resolution}
+// and returns an ASCII legacy VTK STRUCTURED_POINTS file as a Uint8Array,
+// loadable in ParaView. fields lists the point data to write, from
+// "velocity", "pressure" and "inside" (the body mask); all by default.
+// The grid bounds and the file are in the units of setUnits, which the
+// title line names.
+func exportVTK(args []js.Value) (interface{}, error) {
+	if err := checkArgs("exportVTK", args, 1); err != nil {
+		return nil, err
+	}
+	g, err := flow.DecodeGrid(goValueSI(args[0], flow.GridDims))
+	if err != nil {
+		return nil, err
+	}
+	var fields []string
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		if !js.Global().Get("Array").Call("isArray", v).Bool() {
+			return nil, flow.Errorf(flow.ErrBadArguments, "exportVTK: fields must be an array of names")
+		}
+		for i := 0; i < v.Length(); i++ {
+			fields = append(fields, v.Index(i).String())
+		}
+	}
+	b, err := flow.VTKStructuredPoints(sim.Flow(), g, fields, units)
+	if err != nil {
+		return nil, err
+	}
+	return bytesToJS(b), nil
+}
+
+// exportVTKStreamlines() returns the lines of the last traceStreamlines
+// call as an ASCII legacy VTK POLYDATA file of polylines, as a Uint8Array,
+// in the length unit of setUnits
+func exportVTKStreamlines(args []js.Value) (interface{}, error) {
+	return bytesToJS(flow.VTKPolyLines(streamlines, units)), nil
+}
+
+// exportOBJ(what[, params])
+//
+// Returns Wavefront OBJ text as a Uint8Array. what is
+//   - "surface": the current object triangulated with params.resolution
+//     segments around it (default 32) and vertex normals; cylinder and
+//     airfoil sections are extruded over params.span (default 4 radii)
+//   - "streamlines": the lines of the last traceStreamlines call as OBJ
+//     line elements
+//
+// Coordinates and span are in the length unit of setUnits, named in a
+// comment line.
+func exportOBJ(args []js.Value) (interface{}, error) {
+	if err := checkArgs("exportOBJ", args, 1); err != nil {
+		return nil, err
+	}
+	switch what := args[0].String(); {
+	case args[0].Type() == js.TypeString && what == "surface":
+		m, err := surfaceMesh(optionalArg(args, 1))
+		if err != nil {
+			return nil, err
+		}
+		return bytesToJS(flow.OBJ(m, units)), nil
+	case args[0].Type() == js.TypeString && what == "streamlines":
+		return bytesToJS(flow.OBJLines(streamlines, units)), nil
+	}
+	return nil, flow.Errorf(flow.ErrBadArguments, `exportOBJ: what must be "surface" or "streamlines"`)
+}
+
+// surfaceMesh builds the object surface mesh for the {resolution, span}
+// parameters
+func surfaceMesh(params js.Value) (*flow.Mesh, error) {
+	o := sim.Config.Object
+	resolution, span, err := meshParams(params, o)
+	if err != nil {
+		return nil, err
+	}
+	return o.SurfaceMesh(resolution, span)
+}
+
+// meshParams reads the {resolution, span} mesh parameters, defaulting to
+// 32 segments and a span of 4 radii, converting span to SI
+func meshParams(params js.Value, o flow.ObjectSpec) (resolution int, span float64, err error) {
+	resolution, span = 32, 4*o.Radius
+	if params.Type() == js.TypeObject {
+		if v := params.Get("resolution"); !v.IsUndefined() {
+			if resolution, err = intArg("resolution", v); err != nil {
+				return 0, 0, err
+			}
+		}
+		if v := params.Get("span"); !v.IsUndefined() {
+			if span, err = dimArg("span", flow.DimLength, v); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+	return resolution, span, nil
+}
@@ -0,0 +1,139 @@
+//go:build wasip1 && go1.24
+
+// exports.go - go:wasmexport entry points for the reactor build
+//
+// Build with:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o wasm/fluid_sim_exports.wasm .
+//
+// The kernels take raw pointers into linear memory instead of js.Value
+// arguments, so there is no per-element syscall/js overhead and the module
+// runs without wasm_exec.js. js/wasm_exports.js provides the JS glue:
+// buffers are allocated with alloc, filled through Float32Array views over
+// the exported memory, and passed back by pointer.
+package main
+
+import (
+	"unsafe"
+
+	"fluid_simulation/internal/flow"
+)
+
+// Status codes returned by the exported kernels
+const (
+	statusOK           = 0
+	statusBadArguments = 1
+	statusBufferLength = 2
+)
+
+// buffers keeps allocated float32 buffers reachable, keyed by address
+var buffers = map[unsafe.Pointer][]float32{}
+
+// lastError holds the message of the most recent failed call
+var lastError []byte
+
+// status records err and converts it to a status code
+func status(err error) int32 {
+	if err == nil {
+		return statusOK
+	}
+	lastError = []byte(err.Error())
+	if fe, ok := err.(*flow.Error); ok && fe.Code == flow.ErrBufferLength {
+		return statusBufferLength
+	}
+	return statusBadArguments
+}
+
+// buffer looks up an allocated buffer by address
+func buffer(name string, ptr unsafe.Pointer) ([]float32, error) {
+	buf, ok := buffers[ptr]
+	if !ok {
+		return nil, flow.Errorf(flow.ErrBadArguments, "%s is not a buffer returned by alloc", name)
+	}
+	return buf, nil
+}
+
+// alloc reserves a buffer of n float32 values and returns its address.
+// Go's collector does not move objects, so the address stays valid until
+// release is called.
+//
+//go:wasmexport alloc
+func alloc(n int32) unsafe.Pointer {
+	if n <= 0 {
+		n = 1
+	}
+	buf := make([]float32, n)
+	ptr := unsafe.Pointer(&buf[0])
+	buffers[ptr] = buf
+	return ptr
+}
+
+// release frees a buffer returned by alloc
+//
+//go:wasmexport release
+func release(ptr unsafe.Pointer) {
+	delete(buffers, ptr)
+}
+
+// lastErrorPtr returns the address of the last error message
+//
+//go:wasmexport lastErrorPtr
+func lastErrorPtr() unsafe.Pointer {
+	if len(lastError) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&lastError[0])
+}
+
+// lastErrorLen returns the length in bytes of the last error message
+//
+//go:wasmexport lastErrorLen
+func lastErrorLen() int32 {
+	return int32(len(lastError))
+}
+
+// exportUpdateVelocities is updateVelocities writing into the buffer at out
+//
+//go:wasmexport updateVelocities
+func exportUpdateVelocities(positions unsafe.Pointer, count int32, freeStreamVelocity, fluidDensity,
+	objectX, objectY, objectZ float64, objectType int32, objectRadius float64, out unsafe.Pointer) int32 {
+	pos, err := buffer("positions", positions)
+	if err != nil {
+		return status(err)
+	}
+	dst, err := buffer("output", out)
+	if err != nil {
+		return status(err)
+	}
+	f := flow.Flow{
+		FreeStream: freeStreamVelocity,
+		Density:    fluidDensity,
+		Object: flow.ObjectSpec{
+			Type:   flow.ObjectType(objectType),
+			X:      objectX,
+			Y:      objectY,
+			Z:      objectZ,
+			Radius: objectRadius,
+		},
+	}
+	return status(flow.VelocitiesInto(dst, pos, int(count), f))
+}
+
+// exportCalculatePressure is calculatePressure writing into the buffer at out
+//
+//go:wasmexport calculatePressure
+func exportCalculatePressure(velocities unsafe.Pointer, count int32, freeStreamVelocity, fluidDensity float64, out unsafe.Pointer) int32 {
+	vel, err := buffer("velocities", velocities)
+	if err != nil {
+		return status(err)
+	}
+	dst, err := buffer("output", out)
+	if err != nil {
+		return status(err)
+	}
+	return status(flow.PressuresInto(dst, vel, int(count), freeStreamVelocity, fluidDensity))
+}
+
+// main is unused in the c-shared reactor build; the runtime is initialized
+// by the exported _initialize.
+func main() {}
@@ -2,176 +2,570 @@
 // +build js,wasm
 
 // fluid_sim.go - Velocity Potential-Based Fluid Simulation
+//
+// This file only binds the kernels in internal/flow to JavaScript; the
+// physics itself lives there so it can be tested natively.
 package main
 
 import (
-	"math"
 	"syscall/js"
-)
 
-// Global constants
-const (
-	SPHERE   = 0
-	CYLINDER = 1
-	AIRFOIL  = 2
+	"fluid_simulation/internal/flow"
 )
 
 // updateVelocities calculates velocities based on velocity potential
 //
 // Parameters:
-// - positions: Float32Array of particle positions [x1,y1,z1,x2,y2,z2,...]
+// - positions: Float32Array or Float64Array of particle positions [x1,y1,z1,x2,y2,z2,...]
 // - count: Number of particles
 // - freeStreamVelocity: Velocity of the free stream
 // - fluidDensity: Density of the fluid
 // - objectX, objectY, objectZ: Position of the object
-// - objectType: Type of the object (0=sphere, 1=cylinder, 2=airfoil)
-// - objectRadius: Radius or characteristic length of the object
+// - objectType: Type of the object (0=sphere, 1=cylinder, 2=airfoil, 3=plate at α = 0, 4=circular elliptic cylinder, 5=torus, 6=cube)
+// - objectRadius: Radius or characteristic length of the object, half the chord of a plate
+// - options (optional): {outputPrecision: "float32" | "float64"} or the interleave options
 //
 // Returns:
-// - Float32Array of updated velocities [vx1,vy1,vz1,vx2,vy2,vz2,...]
-func updateVelocities(this js.Value, args []js.Value) interface{} {
-	positionsJS := args[0]
-	count := args[1].Int()
-	freeStreamVelocity := args[2].Float()
-	fluidDensity := args[3].Float()
-	objectX := args[4].Float()
-	objectY := args[5].Float()
-	objectZ := args[6].Float()
-	objectType := args[7].Int()
-	objectRadius := args[8].Float()
-
-	// Create output array
-	resultJS := js.Global().Get("Float32Array").New(count * 3)
-
-	// Process each particle
-	for i := 0; i < count; i++ {
-		idx := i * 3
-
-		// Extract position relative to object
-		x := positionsJS.Index(idx).Float() - objectX
-		y := positionsJS.Index(idx+1).Float() - objectY
-		z := positionsJS.Index(idx+2).Float() - objectZ
-
-		// Calculate distance from object center
-		r := math.Sqrt(x*x + y*y + z*z)
-
-		// Default to free stream velocity
-		vx := freeStreamVelocity
-		vy := 0.0
-		vz := 0.0
-
-		// Only calculate potential flow if outside the object
-		if r > objectRadius {
-			switch objectType {
-			case SPHERE:
-				// Velocity potential flow around sphere
-				factor := math.Pow(objectRadius, 3) / math.Pow(r, 3)
-				vx = freeStreamVelocity * (1 - factor*(3*x*x/(2*r*r)-0.5))
-				vy = freeStreamVelocity * (-factor * 3 * x * y / (2 * r * r))
-				vz = freeStreamVelocity * (-factor * 3 * x * z / (2 * r * r))
-
-			case CYLINDER:
-				// Velocity potential flow around cylinder (2D in XY plane)
-				rxy := math.Sqrt(x*x + y*y)
-				if rxy > objectRadius {
-					factor := math.Pow(objectRadius/rxy, 2)
-					vx = freeStreamVelocity * (1 - factor*(2*x*x/(rxy*rxy)-1))
-					vy = freeStreamVelocity * (-factor * 2 * x * y / (rxy * rxy))
-
-					// Apply pressure gradient from Bernoulli's equation
-					pressure := fluidDensity * (0.5*freeStreamVelocity*freeStreamVelocity - 0.5*(vx*vx+vy*vy))
-
-					// Z-component adjustment based on pressure gradient
-					vz += z * pressure * 0.01
-				} else {
-					// Inside the cylinder but outside core
-					vx = 0
-					vy = 0
-					vz = 0
-				}
-
-			case AIRFOIL:
-				// Simplified airfoil model using doublet and vortex
-				rxy := math.Sqrt(x*x + y*y)
-				angle := math.Atan2(y, x)
-
-				// Add circulation for lift (using Kutta condition)
-				circulation := freeStreamVelocity * 4 * math.Pi * objectRadius * math.Sin(angle)
-
-				if rxy > objectRadius {
-					// Combine doublet and vortex flow
-					factor := math.Pow(objectRadius/rxy, 2)
-					vx = freeStreamVelocity * (1 - factor*math.Cos(2*angle))
-					vy = freeStreamVelocity*(-factor*math.Sin(2*angle)) + circulation/(2*math.Pi*rxy)
-
-					// Scale z velocity based on xz plane
-					vz = 0.1 * z * (vx*vx + vy*vy) / (objectRadius * freeStreamVelocity)
-				} else {
-					// Inside airfoil
-					vx = 0
-					vy = 0
-					vz = 0
-				}
-			}
-		} else {
-			// Inside object, zero velocity
-			vx = 0
-			vy = 0
-			vz = 0
+//   - typed array of updated velocities [vx1,vy1,vz1,vx2,vy2,vz2,...], with
+//     the precision of positions unless outputPrecision says otherwise
+//   - an Error (with a code property) if the arguments are invalid
+//
+// With options.interleave set to a layout name ("position-color",
+// "position-speed" or "position-color8", see getInterleavedLayout), the
+// result is instead one Float32Array interleaving each particle's position
+// with its speed or a speed color, mapped from options.colorRange (default
+// [0, 2*freeStreamVelocity]) through options.colormap (default "viridis").
+// Pass options.target, a Float32Array of count*strideFloats, to reuse the
+// same buffer across frames; it is filled and returned.
+//
+// options.speeds, a Float32Array of at least count floats, is filled with
+// each particle's speed |v| in the same pass as the velocities, sparing a
+// second traversal in JavaScript. It can't be combined with interleave,
+// whose colors are computed from the speed directly; use the
+// "position-speed" layout to get the speeds alongside the positions.
+//
+// options.mask, a Uint8Array of count bytes, skips the particles whose
+// byte is 0, and options.zeroMasked zeroes their slots; see mask.go.
+//
+// options.farFieldCutoff, in object radii, gives particles farther than
+// that from the object the free stream velocity without evaluating the
+// full kernel (see flow.Flow.Cutoff); setFarFieldCutoff estimates the
+// error. getState().farField.lastCall reports how many particles of the
+// last evaluation took that path.
+//
+// options.frame: "lab" returns the velocities in the lab frame, where the
+// fluid is at rest far away and the object moves through it: the
+// disturbance alone, the free stream subtracted. The default is "body".
+//
+// options.layout: "planar" takes positions as three component arrays
+// [xs, ys, zs] or one array of the x, y and z blocks, at offsets 0, count
+// and 2*count, and returns the velocities in the same form; see planar.go.
+// The interleave layouts still write the interleaved vertices they
+// describe. The default is "interleaved".
+//
+// positions must hold at least count*3 floats; any extra trailing data is
+// ignored and only the first count particles are processed.
+//
+// An objectRadius of 0 means no object: every particle gets the free stream
+// velocity. A freeStreamVelocity of 0 is allowed and yields a fluid at rest.
+// Negative radii and densities are rejected. Once buildFlow has built a
+// flow from elementary flows, it replaces the object solution here too.
+//
+// Instead of the positional flow parameters, a configuration object can be
+// passed, either as updateVelocities(positions, config) or as
+// updateVelocities(config) with the positions inside it. Besides the flow
+// schema documented on flow.Config it takes:
+//   - positions: the positions array, when not passed separately
+//   - count (optional): number of particles, default positions.length/3
+//   - outputPrecision (optional): as in the options argument
+//   - interleave, colorRange, colormap, target (optional): likewise
+//   - farFieldCutoff, speeds, mask, zeroMasked, layout (optional): likewise
+//
+// The config form takes the frame from the config's frame key.
+//
+// Positions, flow parameters, velocities, speeds and colorRange are in the
+// units of setUnits.
+func updateVelocities(args []js.Value) (interface{}, error) {
+	if positions, config, ok := configForm(args); ok {
+		return updateVelocitiesConfig(positions, config)
+	}
+	if err := checkArgs("updateVelocities", args, 9); err != nil {
+		return nil, err
+	}
+	count, f, err := velocityArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	if f.Cutoff, err = cutoffOption(optionalArg(args, 9)); err != nil {
+		return nil, err
+	}
+	if f.Frame, err = frameOption(optionalArg(args, 9)); err != nil {
+		return nil, err
+	}
+	out, err := outputsOption(optionalArg(args, 9), count)
+	if err != nil {
+		return nil, err
+	}
+	layout, err := layoutOption(optionalArg(args, 9))
+	if err != nil {
+		return nil, err
+	}
+	positions, err := vectorsArg("positions", args[0], layout)
+	if err != nil {
+		return nil, err
+	}
+	if o, ok, err := interleaveOption(optionalArg(args, 9), f); ok || err != nil {
+		if err != nil {
+			return nil, err
 		}
+		o.mask = out.mask
+		return evalInterleaved(positions, count, f, o)
+	}
+	precision, err := precisionOption(optionalArg(args, 9), positions.precision())
+	if err != nil {
+		return nil, err
+	}
+	return evalVelocities(positions, count, f, precision, out, runAll)
+}
 
-		// Set velocities in result array
-		resultJS.SetIndex(idx, vx)
-		resultJS.SetIndex(idx+1, vy)
-		resultJS.SetIndex(idx+2, vz)
+// configForm recognizes the configuration-object forms of updateVelocities
+func configForm(args []js.Value) (positions, config js.Value, ok bool) {
+	switch {
+	case len(args) >= 2 && args[1].Type() == js.TypeObject:
+		return args[0], args[1], true
+	case len(args) == 1 && args[0].Type() == js.TypeObject && args[0].Get("length").IsUndefined():
+		return args[0].Get("positions"), args[0], true
 	}
+	return js.Value{}, js.Value{}, false
+}
 
-	return resultJS
+// updateVelocitiesConfig is updateVelocities for a configuration object
+func updateVelocitiesConfig(v, config js.Value) (interface{}, error) {
+	if v.IsUndefined() {
+		return nil, flow.Errorf(flow.ErrBadArguments, "updateVelocities: positions is required")
+	}
+	layout, err := layoutOption(config)
+	if err != nil {
+		return nil, err
+	}
+	positions, err := vectorsArg("positions", v, layout)
+	if err != nil {
+		return nil, err
+	}
+	count := positions.size()
+	if v := config.Get("count"); !v.IsUndefined() {
+		if count, err = countArg(v); err != nil {
+			return nil, err
+		}
+	}
+	precision, err := precisionOption(config, positions.precision())
+	if err != nil {
+		return nil, err
+	}
+	c, err := flow.DecodeConfig(goValueSI(config, flow.ConfigDims, append([]string{"positions", "count", "outputPrecision", "farFieldCutoff", "speeds", "mask", "zeroMasked", "layout"}, interleaveKeys...)...))
+	if err != nil {
+		return nil, err
+	}
+	f := c.Flow()
+	f.Elements = sim.Elements
+	if f.Cutoff, err = cutoffOption(config); err != nil {
+		return nil, err
+	}
+	out, err := outputsOption(config, count)
+	if err != nil {
+		return nil, err
+	}
+	if o, ok, err := interleaveOption(config, f); ok || err != nil {
+		if err != nil {
+			return nil, err
+		}
+		o.mask = out.mask
+		return evalInterleaved(positions, count, f, o)
+	}
+	return evalVelocities(positions, count, f, precision, out, runAll)
 }
 
-// Calculate pressure field based on velocities (Bernoulli's equation)
-func calculatePressure(this js.Value, args []js.Value) interface{} {
-	velocitiesJS := args[0]
-	count := args[1].Int()
-	freeStreamVelocity := args[2].Float()
-	fluidDensity := args[3].Float()
+// velocityArgs reads the count and flow parameters of the nine positional
+// updateVelocities arguments
+func velocityArgs(args []js.Value) (int, flow.Flow, error) {
+	count, err := countArg(args[1])
+	if err != nil {
+		return 0, flow.Flow{}, err
+	}
+	f, err := flowArgs(args[2:9])
+	return count, f, err
+}
+
+// flowArgs reads the seven positional flow parameters (freeStreamVelocity,
+// fluidDensity, objectX, objectY, objectZ, objectType, objectRadius),
+// converted to SI, with the elements of buildFlow
+func flowArgs(args []js.Value) (flow.Flow, error) {
+	params := [...]struct {
+		name string
+		dim  flow.Dim
+	}{
+		{"freeStreamVelocity", flow.DimVelocity}, {"fluidDensity", flow.DimDensity},
+		{"objectX", flow.DimLength}, {"objectY", flow.DimLength}, {"objectZ", flow.DimLength},
+		{"objectType", flow.DimNone}, {"objectRadius", flow.DimLength},
+	}
+	var v [7]float64
+	for i, p := range params {
+		x, err := dimArg(p.name, p.dim, args[i])
+		if err != nil {
+			return flow.Flow{}, err
+		}
+		v[i] = x
+	}
+	return flow.Flow{
+		FreeStream: v[0],
+		Density:    v[1],
+		Object: flow.ObjectSpec{
+			Type:   flow.ObjectType(int(v[5])),
+			X:      v[2],
+			Y:      v[3],
+			Z:      v[4],
+			Radius: v[6],
+		},
+		Elements: sim.Elements,
+	}, nil
+}
+
+// runner drives step over [from, to) ranges covering total items
+type runner func(total int, step func(from, to int) error) error
+
+// runAll processes everything in a single range
+func runAll(total int, step func(from, to int) error) error {
+	return step(0, total)
+}
+
+// evalVelocities evaluates the velocities of the positions array (of either
+// precision) into a new typed array of the requested precision, filling
+// the optional outputs, converting both ways between the API units and SI
+func evalVelocities(positions vectors, count int, f flow.Flow, precision string, out velocityOutputs, run runner) (js.Value, error) {
+	if positions.precision() == float64Precision {
+		p, err := vectorsFromJS[float64]("positions", positions, count)
+		if err != nil {
+			return js.Value{}, err
+		}
+		defer putBuffer(p)
+		toSI(p, flow.DimLength)
+		return velocitiesAs(p, positions, count, f, precision, out, run)
+	}
+	p, err := vectorsFromJS[float32]("positions", positions, count)
+	if err != nil {
+		return js.Value{}, err
+	}
+	defer putBuffer(p)
+	toSI(p, flow.DimLength)
+	return velocitiesAs(p, positions, count, f, precision, out, run)
+}
+
+// velocitiesAs dispatches on the output precision
+func velocitiesAs[In flow.Float](positions []In, form vectors, count int, f flow.Flow, precision string, out velocityOutputs, run runner) (js.Value, error) {
+	if precision == float64Precision {
+		return velocitiesTo[float64](positions, form, count, f, out, run)
+	}
+	return velocitiesTo[float32](positions, form, count, f, out, run)
+}
+
+// velocitiesTo evaluates velocities into new Out-precision typed arrays in
+// the layout and form of the positions
+func velocitiesTo[Out, In flow.Float](positions []In, form vectors, count int, f flow.Flow, out velocityOutputs, run runner) (js.Value, error) {
+	dst := getBuffer[Out](count * 3)
+	defer putBuffer(dst)
+	if out.mask.Active != nil {
+		clear(dst)
+	}
+	var sp []float32
+	if !out.speeds.IsUndefined() {
+		sp = getBuffer[float32](count)
+		defer putBuffer(sp)
+		preserve(sp, out.speeds, &out.mask)
+		toSI(sp, flow.DimVelocity)
+	}
+	far := 0
+	err := run(count, func(from, to int) error {
+		return timed("velocities", func() error {
+			var s []float32
+			if sp != nil {
+				s = sp[from:to]
+			}
+			n, err := flow.VelocitiesMasked(dst[from*3:to*3], s, positions[from*3:to*3], to-from, f, maskRange(out.mask, from, to))
+			far += n
+			return err
+		})
+	})
+	if err != nil {
+		return js.Value{}, err
+	}
+	farFieldLast = far
+	recordActive(count, &out.mask)
+	if sp != nil {
+		copyToJSIn(out.speeds, sp, flow.DimVelocity)
+	}
+	fromSI(dst, flow.DimVelocity)
+	return vectorsToJS(form, dst, count), nil
+}
 
-	// Create output array
-	resultJS := js.Global().Get("Float32Array").New(count)
+// farFieldLast is the number of particles beyond the far-field cutoff in
+// the last velocity evaluation
+var farFieldLast int
 
-	// Use Bernoulli's equation: p + 0.5*rho*v^2 = constant
-	// Assuming p_infinity + 0.5*rho*V_infinity^2 is our reference
-	pRef := 0.5 * fluidDensity * freeStreamVelocity * freeStreamVelocity
+// velocityOutputs are the optional outputs of a velocity evaluation
+type velocityOutputs struct {
+	speeds js.Value // Float32Array filled with the speeds, or undefined
+	mask   flow.Mask
+}
 
-	for i := 0; i < count; i++ {
-		idx := i * 3
-		vx := velocitiesJS.Index(idx).Float()
-		vy := velocitiesJS.Index(idx + 1).Float()
-		vz := velocitiesJS.Index(idx + 2).Float()
+// outputsOption reads the speeds and mask options of opts
+func outputsOption(opts js.Value, count int) (velocityOutputs, error) {
+	var out velocityOutputs
+	var err error
+	if out.speeds, err = speedsOption(opts, count); err != nil {
+		return out, err
+	}
+	out.mask, err = maskOption(opts, count)
+	return out, err
+}
 
-		// Velocity magnitude squared
-		v2 := vx*vx + vy*vy + vz*vz
+// speedsOption reads the optional speeds buffer of opts, undefined if
+// absent, rejecting it alongside interleave
+func speedsOption(opts js.Value, count int) (js.Value, error) {
+	if opts.Type() != js.TypeObject {
+		return js.Undefined(), nil
+	}
+	v := opts.Get("speeds")
+	if v.IsUndefined() {
+		return v, nil
+	}
+	if !opts.Get("interleave").IsUndefined() {
+		return js.Value{}, flow.Errorf(flow.ErrBadArguments, `speeds can't be combined with interleave; use the "position-speed" layout`)
+	}
+	if err := float32View("speeds", v, count); err != nil {
+		return js.Value{}, err
+	}
+	return v, nil
+}
 
-		// Pressure from Bernoulli (p = pRef - 0.5*rho*v^2)
-		pressure := pRef - 0.5*fluidDensity*v2
+// cutoffOption reads the optional farFieldCutoff of opts
+func cutoffOption(opts js.Value) (float64, error) {
+	if opts.Type() != js.TypeObject {
+		return 0, nil
+	}
+	v := opts.Get("farFieldCutoff")
+	if v.IsUndefined() {
+		return 0, nil
+	}
+	return floatArg("farFieldCutoff", v)
+}
 
-		resultJS.SetIndex(i, pressure)
+// frameOption reads the optional frame of opts
+func frameOption(opts js.Value) (string, error) {
+	if opts.Type() != js.TypeObject {
+		return "", nil
+	}
+	v := opts.Get("frame")
+	if v.IsUndefined() {
+		return "", nil
+	}
+	if v.Type() != js.TypeString {
+		return "", flow.Errorf(flow.ErrBadArguments, "frame must be a string")
+	}
+	return v.String(), flow.CheckFrame(v.String())
+}
+
+// Calculate pressure field based on velocities (Bernoulli's equation)
+//
+// velocities (Float32Array or Float64Array) must hold at least count*3
+// floats; as with updateVelocities, extra trailing data is ignored. The
+// optional fifth argument {outputPrecision} chooses the precision of the
+// result, which defaults to that of velocities, and takes mask and
+// zeroMasked as updateVelocities does; masked particles get a pressure of
+// 0. For lab frame velocities pass options.frame: "lab" and, unless the
+// free stream runs along +x, options.direction: the unsteady term of the
+// moving body is then included, so the pressures equal those of the body
+// frame. options.layout: "planar" takes velocities in the planar layout of
+// updateVelocities. Velocities, freeStreamVelocity and fluidDensity are taken and
+// pressures returned in the units of setUnits. Returns an Error on invalid
+// arguments.
+func calculatePressure(args []js.Value) (interface{}, error) {
+	if err := checkArgs("calculatePressure", args, 4); err != nil {
+		return nil, err
+	}
+	count, err := countArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	freeStream, err := dimArg("freeStreamVelocity", flow.DimVelocity, args[2])
+	if err != nil {
+		return nil, err
+	}
+	density, err := dimArg("fluidDensity", flow.DimDensity, args[3])
+	if err != nil {
+		return nil, err
+	}
+	layout, err := layoutOption(optionalArg(args, 4))
+	if err != nil {
+		return nil, err
+	}
+	velocities, err := vectorsArg("velocities", args[0], layout)
+	if err != nil {
+		return nil, err
+	}
+	precision, err := precisionOption(optionalArg(args, 4), velocities.precision())
+	if err != nil {
+		return nil, err
+	}
+	mask, err := maskOption(optionalArg(args, 4), count)
+	if err != nil {
+		return nil, err
+	}
+	f := flow.Flow{FreeStream: freeStream, Density: density}
+	if f.Frame, err = frameOption(optionalArg(args, 4)); err != nil {
+		return nil, err
+	}
+	if opts := optionalArg(args, 4); opts.Type() == js.TypeObject {
+		if v := opts.Get("direction"); !v.IsUndefined() {
+			if f.Direction, err = directionArg(v); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	return resultJS
+	if velocities.precision() == float64Precision {
+		v, err := vectorsFromJS[float64]("velocities", velocities, count)
+		if err != nil {
+			return nil, err
+		}
+		defer putBuffer(v)
+		toSI(v, flow.DimVelocity)
+		return pressuresAs(v, count, &f, precision, mask)
+	}
+	v, err := vectorsFromJS[float32]("velocities", velocities, count)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(v)
+	toSI(v, flow.DimVelocity)
+	return pressuresAs(v, count, &f, precision, mask)
+}
+
+// pressuresAs evaluates pressures into a new typed array of the requested
+// precision
+func pressuresAs[In flow.Float](velocities []In, count int, f *flow.Flow, precision string, mask flow.Mask) (js.Value, error) {
+	if precision == float64Precision {
+		return pressuresTo[float64](velocities, count, f, mask)
+	}
+	return pressuresTo[float32](velocities, count, f, mask)
+}
+
+// pressuresTo evaluates pressures into a new Out-precision typed array
+func pressuresTo[Out, In flow.Float](velocities []In, count int, f *flow.Flow, mask flow.Mask) (js.Value, error) {
+	dst := getBuffer[Out](count)
+	defer putBuffer(dst)
+	if mask.Active != nil {
+		clear(dst)
+	}
+	if err := timed("pressures", func() error {
+		return flow.PressuresFor(dst, velocities, count, f, mask)
+	}); err != nil {
+		return js.Value{}, err
+	}
+	recordActive(count, &mask)
+	fromSI(dst, flow.DimPressure)
+	return floatsToJS(dst), nil
+}
+
+// calculateIsentropic(velocities, count, freeStreamVelocity, mach[, options])
+//
+// Returns the isentropic ratios of the local static pressure, density and
+// temperature to the free stream values, {pressure, density, temperature}
+// as Float32Arrays of count values, for velocities (Float32Array or
+// Float64Array) given in the body frame. mach is the free stream Mach
+// number, in [0, 1), and options.gamma the ratio of specific heats,
+// default 1.4, and options.layout, as for calculatePressure, the layout of
+// velocities. Particles whose local Mach number exceeds 1 are flagged
+// with a 1 in the Uint8Array supersonic, and counted in supersonicCount,
+// and get the ratios of the sonic state. The ratios only depend on the
+// speeds relative to freeStreamVelocity, so any consistent velocity unit
+// works. Returns an Error on invalid arguments.
+func calculateIsentropic(args []js.Value) (interface{}, error) {
+	if err := checkArgs("calculateIsentropic", args, 4); err != nil {
+		return nil, err
+	}
+	count, err := countArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	freeStream, err := floatArg("freeStreamVelocity", args[2])
+	if err != nil {
+		return nil, err
+	}
+	mach, err := floatArg("mach", args[3])
+	if err != nil {
+		return nil, err
+	}
+	gamma := flow.DefaultGamma
+	if opts := optionalArg(args, 4); opts.Type() == js.TypeObject {
+		if v := opts.Get("gamma"); !v.IsUndefined() {
+			if gamma, err = floatArg("gamma", v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	layout, err := layoutOption(optionalArg(args, 4))
+	if err != nil {
+		return nil, err
+	}
+	velocities, err := vectorsArg("velocities", args[0], layout)
+	if err != nil {
+		return nil, err
+	}
+	r, err := isentropicOf(velocities, count, freeStream, mach, gamma)
+	if err != nil {
+		return nil, err
+	}
+	flags := make([]byte, count)
+	for i, s := range r.Supersonic {
+		if s {
+			flags[i] = 1
+		}
+	}
+	supersonic := uint8Array.New(count)
+	js.CopyBytesToJS(supersonic, flags)
+	return map[string]interface{}{
+		"pressure":        floatsToJS(r.Pressure),
+		"density":         floatsToJS(r.Density),
+		"temperature":     floatsToJS(r.Temperature),
+		"supersonic":      supersonic,
+		"supersonicCount": r.Count,
+	}, nil
 }
 
-// Register functions to be callable from JavaScript
-func registerCallbacks() {
-	js.Global().Set("updateVelocities", js.FuncOf(updateVelocities))
-	js.Global().Set("calculatePressure", js.FuncOf(calculatePressure))
+// isentropicOf evaluates the isentropic ratios of a velocities array of
+// either precision
+func isentropicOf(velocities vectors, count int, freeStream, mach, gamma float64) (flow.Isentropic, error) {
+	if velocities.precision() == float64Precision {
+		v, err := vectorsFromJS[float64]("velocities", velocities, count)
+		if err != nil {
+			return flow.Isentropic{}, err
+		}
+		defer putBuffer(v)
+		return flow.IsentropicRatios(v, count, freeStream, mach, gamma)
+	}
+	v, err := vectorsFromJS[float32]("velocities", velocities, count)
+	if err != nil {
+		return flow.Isentropic{}, err
+	}
+	defer putBuffer(v)
+	return flow.IsentropicRatios(v, count, freeStream, mach, gamma)
 }
 
 func main() {
 	// Register functions
 	registerCallbacks()
 
-	// Keep the Go program running
-	<-make(chan bool)
+	// Keep the Go program running (works with both gc and TinyGo)
+	select {}
 }
-
@@ -0,0 +1,105 @@
+//go:build js && wasm
+// +build js,wasm
+
+// forces.go - Force and moment time histories
+//
+// While a force history is active, every sampled step of the stateful API
+// integrates the surface pressure on the object in Go, so the response of
+// the forces to gusts, schedules and motions is recorded on the simulation
+// clock without a round trip per step.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// startForceHistory(objectId, everyNSteps, maxSamples[, options])
+//
+// Starts sampling the pressure force and moment on the object, a sphere or
+// cylinder, every everyNSteps steps, discarding any previous history.
+// Options:
+//   - rolling: once maxSamples are held keep the latest ones instead of
+//     stopping (default false)
+//   - resolution: surface cells from the front stagnation point to the
+//     shoulder (default 16; see getDragReport)
+//
+// Samples are taken at the steps that move the clock, the first one
+// included, with the free stream and object motion of the sampled time,
+// so they keep their phase with a schedule or a motion. objectId is 0,
+// the only object.
+func startForceHistory(args []js.Value) (interface{}, error) {
+	if err := checkArgs("startForceHistory", args, 3); err != nil {
+		return nil, err
+	}
+	if err := objectArg("startForceHistory", args[0]); err != nil {
+		return nil, err
+	}
+	every, err := intArg("everyNSteps", args[1])
+	if err != nil {
+		return nil, err
+	}
+	maxSamples, err := intArg("maxSamples", args[2])
+	if err != nil {
+		return nil, err
+	}
+	resolution, rolling := 0, false
+	if opts := optionalArg(args, 3); opts.Type() == js.TypeObject {
+		if v := opts.Get("rolling"); !v.IsUndefined() {
+			rolling = v.Truthy()
+		}
+		if v := opts.Get("resolution"); !v.IsUndefined() {
+			if resolution, err = intArg("resolution", v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	h, err := flow.NewForceHistory(every, maxSamples, resolution, rolling)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.Check(sim); err != nil {
+		return nil, err
+	}
+	sim.Forces = h
+	return nil, nil
+}
+
+// getForceHistory(objectId)
+//
+// Returns the force history as {time, fx, fy, fz, m, active, everyNSteps,
+// rolling, error}: parallel Float64Arrays of the sample times, in seconds,
+// the force components and the moment about the object center along z,
+// oldest first, whether sampling goes on, and the reason it stopped early,
+// or null. Forces and moments are in newtons and newton metres, per metre
+// of span for cylinders, whatever the units. Sampling goes on after the
+// call; null if no history was started.
+func getForceHistory(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getForceHistory", args, 1); err != nil {
+		return nil, err
+	}
+	if err := objectArg("getForceHistory", args[0]); err != nil {
+		return nil, err
+	}
+	h := sim.Forces
+	if h == nil {
+		return nil, nil
+	}
+	var stopped interface{}
+	if h.Err != nil {
+		stopped = jsError(h.Err)
+	}
+	t, fx, fy, fz, m := h.Samples()
+	return map[string]interface{}{
+		"time":        floatsToJS(t),
+		"fx":          floatsToJS(fx),
+		"fy":          floatsToJS(fy),
+		"fz":          floatsToJS(fz),
+		"m":           floatsToJS(m),
+		"active":      h.Active,
+		"everyNSteps": h.Every,
+		"rolling":     h.Rolling,
+		"error":       stopped,
+	}, nil
+}
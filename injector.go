@@ -0,0 +1,162 @@
+//go:build js && wasm
+// +build js,wasm
+
+// injector.go - Dye injection
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// idArg reads an injector id, 1 to 255
+func idArg(v js.Value) (uint8, error) {
+	id, err := intArg("id", v)
+	if err != nil {
+		return 0, err
+	}
+	if id < 1 || id > 255 {
+		return 0, flow.Errorf(flow.ErrBadArguments, "injector id must be between 1 and 255, got %d", id)
+	}
+	return uint8(id), nil
+}
+
+// injectionState returns getState().injection
+func injectionState() js.Value {
+	st := units.Convert(map[string]interface{}{"injection": sim.State()["injection"]}, flow.StateDims, false)
+	return js.ValueOf(st.(map[string]interface{})["injection"])
+}
+
+// defineInjector(id, position, radius, rate, color)
+//
+// Adds dye injector id, 1 to 255, or replaces it: from the next step on,
+// each step of dt spawns rate·dt particles, fractions carrying over,
+// uniformly in the sphere of radius about position = [x, y, z] and outside
+// the object and walls, with the local flow velocity. Their entry in
+// getParticles().sources is id; particles from no injector have 0. color
+// is [r, g, b] from 0 to 1, kept for the renderer. rate is in particles
+// per second. The count grows with the injected particles up to the budget
+// of setParticleBudget; past it, new particles take the slots of the
+// oldest injected ones, and particles from no injector are never evicted
+// (see flow.Injection). Registered arrays with room for the budget keep
+// receiving the particles; smaller ones are dropped once the count
+// outgrows them (see registerParticles). Returns getState().injection.
+func defineInjector(args []js.Value) (interface{}, error) {
+	if err := checkArgs("defineInjector", args, 5); err != nil {
+		return nil, err
+	}
+	id, err := idArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	in := flow.Injector{ID: id, Enabled: true}
+	if in.Position, err = vectorArg("position", args[1]); err != nil {
+		return nil, err
+	}
+	in.Position = vectorToSI(in.Position, flow.DimLength)
+	if in.Radius, err = dimArg("radius", flow.DimLength, args[2]); err != nil {
+		return nil, err
+	}
+	if in.Rate, err = floatArg("rate", args[3]); err != nil {
+		return nil, err
+	}
+	if in.Color, err = vectorArg("color", args[4]); err != nil {
+		return nil, err
+	}
+	if err := sim.Injection.Define(in); err != nil {
+		return nil, err
+	}
+	return injectionState(), nil
+}
+
+// updateInjector(id, changes)
+//
+// Changes injector id while the simulation runs: changes may set position,
+// radius, rate and color as in defineInjector, and enabled, false pausing
+// the injector. Returns getState().injection.
+func updateInjector(args []js.Value) (interface{}, error) {
+	if err := checkArgs("updateInjector", args, 2); err != nil {
+		return nil, err
+	}
+	id, err := idArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	old := sim.Injection.Injector(id)
+	if old == nil {
+		return nil, flow.Errorf(flow.ErrBadArguments, "no injector %d", id)
+	}
+	changes := args[1]
+	if changes.Type() != js.TypeObject {
+		return nil, flow.Errorf(flow.ErrBadArguments, "updateInjector: changes must be an object")
+	}
+	in := *old
+	if v := changes.Get("position"); !v.IsUndefined() {
+		if in.Position, err = vectorArg("position", v); err != nil {
+			return nil, err
+		}
+		in.Position = vectorToSI(in.Position, flow.DimLength)
+	}
+	if v := changes.Get("radius"); !v.IsUndefined() {
+		if in.Radius, err = dimArg("radius", flow.DimLength, v); err != nil {
+			return nil, err
+		}
+	}
+	if v := changes.Get("rate"); !v.IsUndefined() {
+		if in.Rate, err = floatArg("rate", v); err != nil {
+			return nil, err
+		}
+	}
+	if v := changes.Get("color"); !v.IsUndefined() {
+		if in.Color, err = vectorArg("color", v); err != nil {
+			return nil, err
+		}
+	}
+	if v := changes.Get("enabled"); !v.IsUndefined() {
+		in.Enabled = v.Truthy()
+	}
+	if err := sim.Injection.Define(in); err != nil {
+		return nil, err
+	}
+	return injectionState(), nil
+}
+
+// removeInjector(id)
+//
+// Removes injector id; its particles keep their source. Returns whether
+// there was one.
+func removeInjector(args []js.Value) (interface{}, error) {
+	if err := checkArgs("removeInjector", args, 1); err != nil {
+		return nil, err
+	}
+	id, err := idArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return sim.Injection.Remove(id), nil
+}
+
+// setParticleBudget(count)
+//
+// Sets the particle count injection grows to before recycling injected
+// particles, 100000 by default; null restores it. Lowering it below the
+// count removes nothing: injection then only recycles. Returns
+// getState().injection.
+func setParticleBudget(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setParticleBudget", args, 1); err != nil {
+		return nil, err
+	}
+	budget := 0
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if budget, err = countArg(v); err != nil {
+			return nil, err
+		}
+		if budget < 1 {
+			return nil, flow.Errorf(flow.ErrBadArguments, "the particle budget must be positive, got %d", budget)
+		}
+	}
+	sim.Injection.Budget = budget
+	return injectionState(), nil
+}
@@ -0,0 +1,164 @@
+//go:build js && wasm
+// +build js,wasm
+
+// interleave.go - Interleaved vertex buffers
+//
+// With the interleave option, updateVelocities writes positions and a speed
+// color into one Float32Array in a single pass, ready to back a Three.js
+// InterleavedBuffer. getInterleavedLayout describes each layout so the
+// attributes can be set up once:
+//
+//	const l = getInterleavedLayout("position-color");
+//	const buf = new THREE.InterleavedBuffer(data, l.strideFloats);
+//	for (const a of l.attributes) geometry.setAttribute(a.name,
+//	    new THREE.InterleavedBufferAttribute(buf, a.size, a.offset / 4));
+//
+// The uint8 color of "position-color8" needs a Uint8Array view of the same
+// buffer (InterleavedBuffer over new Uint8Array(data.buffer), stride
+// l.stride, offset a.offset, normalized).
+package main
+
+import (
+	"math"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// interleaveOptions are the interleave options of updateVelocities
+type interleaveOptions struct {
+	layout   flow.Layout
+	lo, hi   float64
+	colormap string
+	target   js.Value
+	mask     flow.Mask
+}
+
+// interleaveKeys are the updateVelocities options read by interleaveOption
+var interleaveKeys = []string{"interleave", "colorRange", "colormap", "target"}
+
+// interleaveOption reads {interleave, colorRange = [0, 2*|freeStream|],
+// colormap = "viridis", target} from opts, colorRange in the velocity unit
+// of setUnits; ok is false without interleave
+func interleaveOption(opts js.Value, f flow.Flow) (o interleaveOptions, ok bool, err error) {
+	if opts.Type() != js.TypeObject || opts.Get("interleave").IsUndefined() {
+		return o, false, nil
+	}
+	if o.layout, err = flow.LookupLayout(opts.Get("interleave").String()); err != nil {
+		return o, false, err
+	}
+	o.lo, o.hi, o.colormap = 0, units.FromSI(flow.DimVelocity, 2*math.Abs(f.FreeStream)), "viridis"
+	if o.hi == 0 {
+		o.hi = 1
+	}
+	if v := opts.Get("colorRange"); !v.IsUndefined() {
+		if !js.Global().Get("Array").Call("isArray", v).Bool() || v.Length() != 2 {
+			return o, false, flow.Errorf(flow.ErrBadArguments, "colorRange must be an array of 2 numbers")
+		}
+		if o.lo, err = floatArg("colorRange", v.Index(0)); err != nil {
+			return o, false, err
+		}
+		if o.hi, err = floatArg("colorRange", v.Index(1)); err != nil {
+			return o, false, err
+		}
+	}
+	if v := opts.Get("colormap"); !v.IsUndefined() {
+		o.colormap = v.String()
+	}
+	o.target = opts.Get("target")
+	return o, true, nil
+}
+
+// evalInterleaved evaluates the velocities of positions and returns the
+// interleaved buffer, written into o.target if given
+func evalInterleaved(positions vectors, count int, f flow.Flow, o interleaveOptions) (js.Value, error) {
+	if !o.target.IsUndefined() {
+		if err := float32View("target", o.target, 0); err != nil {
+			return js.Value{}, err
+		}
+		if err := flow.CheckBuffer("target", o.target.Length(), count, o.layout.Stride/4); err != nil {
+			return js.Value{}, err
+		}
+	}
+	var dst []float32
+	var err error
+	if positions.precision() == float64Precision {
+		dst, err = interleaved[float64](positions, count, f, o)
+	} else {
+		dst, err = interleaved[float32](positions, count, f, o)
+	}
+	if err != nil {
+		return js.Value{}, err
+	}
+	defer putBuffer(dst)
+	if o.target.IsUndefined() {
+		return floatsToJS(dst), nil
+	}
+	copyToJS(o.target, dst)
+	return o.target, nil
+}
+
+// interleaved builds the interleaved buffer from T-precision positions,
+// in a pooled buffer the caller returns with putBuffer
+func interleaved[T flow.Float](positions vectors, count int, f flow.Flow, o interleaveOptions) ([]float32, error) {
+	p, err := vectorsFromJS[T]("positions", positions, count)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(p)
+	toSI(p, flow.DimLength)
+	v := getBuffer[float32](count * 3)
+	defer putBuffer(v)
+	dst := getBuffer[float32](count * o.layout.Stride / 4)
+	if o.target.IsUndefined() {
+		if o.mask.Active != nil {
+			clear(dst)
+		}
+	} else {
+		preserve(dst, o.target, &o.mask)
+	}
+	err = timed("interleave", func() error {
+		far, err := flow.VelocitiesMasked(v, nil, p, count, f, o.mask)
+		if err != nil {
+			return err
+		}
+		farFieldLast = far
+		fromSI(p, flow.DimLength)
+		fromSI(v, flow.DimVelocity)
+		return flow.InterleaveMasked(dst, o.layout, p, v, count, o.lo, o.hi, o.colormap, o.mask)
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordActive(count, &o.mask)
+	return dst, nil
+}
+
+// getInterleavedLayout(name) describes an interleaved layout as
+// {name, stride, strideFloats, attributes: [{name, offset, size, type,
+// normalized}]}, with stride and offsets in bytes
+func getInterleavedLayout(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getInterleavedLayout", args, 1); err != nil {
+		return nil, err
+	}
+	l, err := flow.LookupLayout(args[0].String())
+	if err != nil {
+		return nil, err
+	}
+	attrs := make([]interface{}, len(l.Attributes))
+	for i, a := range l.Attributes {
+		attrs[i] = map[string]interface{}{
+			"name":       a.Name,
+			"offset":     a.Offset,
+			"size":       a.Size,
+			"type":       a.Type,
+			"normalized": a.Normalized,
+		}
+	}
+	return map[string]interface{}{
+		"name":         l.Name,
+		"stride":       l.Stride,
+		"strideFloats": l.Stride / 4,
+		"attributes":   attrs,
+	}, nil
+}
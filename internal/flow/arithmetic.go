@@ -0,0 +1,267 @@
+package flow
+
+import "math"
+
+// Arithmetic of the velocity kernels. The accurate kernels work in
+// float64; the fast ones in float32, which is cheaper on mobile devices and
+// plenty for visualization, about 1e-7 of the free stream off the accurate
+// velocities away from the object and a few 1e-6 near its surface.
+// ArithmeticError measures the difference. The fast kernels cover the
+// analytic sphere, cylinder and airfoil section and the uniform free stream
+// without an object, in any frame and with the far-field cutoff, the object
+// moving or not; every other flow, with an overlay, strengths, elements, a
+// splitter or another object type, is evaluated accurately whatever the
+// arithmetic. Both kernels are instantiations of the same generic code.
+const (
+	ArithmeticAccurate = "accurate"
+	ArithmeticFast     = "fast"
+)
+
+// fastSurfaceOffset is the relative distance outside the body at which the
+// surface samples of the fast kernels are taken: surfaceOffset rounds into
+// the body in float32
+const fastSurfaceOffset = 1e-6
+
+// CheckArithmetic rejects unknown arithmetics; the empty string is
+// ArithmeticAccurate
+func CheckArithmetic(a string) error {
+	if a != "" && a != ArithmeticAccurate && a != ArithmeticFast {
+		return Errorf(ErrBadArguments, "arithmetic must be %q or %q, got %q", ArithmeticAccurate, ArithmeticFast, a)
+	}
+	return nil
+}
+
+// sqrt is math.Sqrt in the precision of T, exactly rounded in float32
+// as well, float64 holding more than twice its digits
+func sqrt[T Float](x T) T {
+	return T(math.Sqrt(float64(x)))
+}
+
+// sphereFlow returns the potential flow past a sphere of radius³ r3 at
+// (x, y, z) from its center, r2 and r its squared and plain distance
+// outside it, for the free stream u along +x
+func sphereFlow[T Float](u, r3, r2, r, x, y, z T) (vx, vy, vz T) {
+	factor := r3 / (r2 * r)
+	c := 1.5 * factor / r2
+	vx = u * (1 - c*x*x + 0.5*factor)
+	vy = -u * c * x * y
+	vz = -u * c * x * z
+	return vx, vy, vz
+}
+
+// cylinderFlow returns the potential flow past a cylinder of radius² r2
+// at (x, y) from its axis, rxy2 the squared distance outside it, for the
+// free stream u along +x
+func cylinderFlow[T Float](u, r2, rxy2, x, y T) (vx, vy T) {
+	factor := r2 / rxy2
+	c := 2 * factor / rxy2
+	return u * (1 - c*x*x + factor), -u * c * x * y
+}
+
+// cylinderSpan returns the spanwise velocity of the cylinder model at
+// height z for the in-plane velocity (vx, vy): a z component following
+// the Bernoulli pressure ρ(pRef - |v|²/2)
+func cylinderSpan[T Float](rho, pRef, vx, vy, z T) T {
+	// Apply pressure gradient from Bernoulli's equation
+	pressure := rho * (pRef - 0.5*(vx*vx+vy*vy))
+
+	// Z-component adjustment based on pressure gradient
+	return z * pressure * 0.01
+}
+
+// airfoilFlow returns the flow of the simplified airfoil model, a doublet
+// of radius² r2 and a vortex of strength Γ/2π vortex, at (x, y) from its
+// axis, rxy2 the squared distance outside it, for the free stream u
+// along +x
+func airfoilFlow[T Float](u, r2, vortex, rxy2, x, y T) (vx, vy T) {
+	// The angle θ = atan2(y, x) only enters through sin θ = y/rxy,
+	// cos 2θ = (x²-y²)/rxy² and sin 2θ = 2xy/rxy², so no trig is
+	// needed. The circulation for lift (using Kutta condition) is
+	// circ·sin θ, and its vortex term circ·sin θ/(2π·rxy) becomes
+	// circ/(2π)·y/rxy².
+	cos2, sin2 := (x*x-y*y)/rxy2, 2*x*y/rxy2
+
+	// Combine doublet and vortex flow
+	factor := r2 / rxy2
+	return u * (1 - factor*cos2), u*(-factor*sin2) + vortex*y/rxy2
+}
+
+// intoCrossFlow turns (x, y) about z by the cross-flow rotation of cosine
+// c and sine s, taking the cross-flow to +x; outOfCrossFlow turns it back
+func intoCrossFlow[T Float](c, s, x, y T) (T, T) {
+	return c*x + s*y, -s*x + c*y
+}
+
+func outOfCrossFlow[T Float](c, s, x, y T) (T, T) {
+	return c*x - s*y, s*x + c*y
+}
+
+// intoFrame returns the components of (x, y, z) along the axes e;
+// outOfFrame takes them back
+func intoFrame[T Float](e *[3][3]T, x, y, z T) (T, T, T) {
+	e1, e2, e3 := e[0], e[1], e[2]
+	return e1[0]*x + e1[1]*y + e1[2]*z, e2[0]*x + e2[1]*y + e2[2]*z, e3[0]*x + e3[1]*y + e3[2]*z
+}
+
+func outOfFrame[T Float](e *[3][3]T, x, y, z T) (T, T, T) {
+	e1, e2, e3 := e[0], e[1], e[2]
+	return x*e1[0] + y*e2[0] + z*e3[0], x*e1[1] + y*e2[1] + z*e3[1], x*e1[2] + y*e2[2] + z*e3[2]
+}
+
+// leanKernel is a kernel reduced to the flows the fast arithmetic covers,
+// in the precision of T: the same evaluation, through the same generic
+// object solutions, as kernel.velocityAt
+type leanKernel[T Float] struct {
+	kind   ObjectType
+	empty  bool // No object: the free stream
+	planar bool
+	mode   int
+
+	ox, oy, oz                T
+	u, r2, r3, body, rho, ref T
+	vortex                    T
+	c, s, axial               T
+	e                         [3][3]T
+	cut2                      T
+	free, shift               [3]T
+	moving                    bool
+}
+
+// lean returns the kernel k in the precision of T, or nil if k needs more
+// than the lean kernels cover
+func lean[T Float](k *kernel) *leanKernel[T] {
+	o := &k.obj
+	empty := o.Radius == 0
+	if !empty && o.Type != Sphere && o.Type != Cylinder && o.Type != Airfoil || k.strengths || k.split ||
+		k.elements != nil || k.image != 0 || k.channel.enabled() || k.bump.enabled() || k.rotor.enabled() ||
+		!k.profile.Uniform() || k.swirl.Number != 0 {
+		return nil
+	}
+	l := &leanKernel[T]{
+		kind: o.Type, empty: empty, planar: o.planar(), mode: k.mode,
+		ox: T(o.X), oy: T(o.Y), oz: T(o.Z),
+		u: T(k.u), r2: T(k.r2), r3: T(k.r3), body: T(k.body), rho: T(k.rho), ref: T(k.pRef),
+		vortex: T(k.vortex),
+		c:      T(k.c), s: T(k.s), axial: T(k.axial),
+		cut2: T(k.cut2), moving: k.moving,
+	}
+	for a := 0; a < 3; a++ {
+		l.free[a], l.shift[a] = T(k.free[a]), T(k.shift[a])
+		for b := 0; b < 3; b++ {
+			l.e[a][b] = T(k.e[a][b])
+		}
+	}
+	return l
+}
+
+// velocity is kernel.velocityAt, also reporting whether (px, py, pz) is
+// beyond the far-field cutoff
+func (l *leanKernel[T]) velocity(px, py, pz T) (vx, vy, vz T, far bool) {
+	x, y, z := px-l.ox, py-l.oy, pz-l.oz
+	d2 := x*x + y*y
+	if !l.planar {
+		d2 += z * z
+	}
+	if far = l.cut2 != 0 && d2 > l.cut2; far {
+		vx, vy, vz = l.free[0], l.free[1], l.free[2]
+	} else {
+		switch l.mode {
+		case frameCrossFlow:
+			if !l.empty && sqrt(x*x+y*y) <= l.body {
+				vx, vy, vz = 0, 0, 0
+				break
+			}
+			lx, ly := intoCrossFlow(l.c, l.s, x, y)
+			lx, ly, lz := l.local(lx, ly, z)
+			vx, vy = outOfCrossFlow(l.c, l.s, lx, ly)
+			vz = lz + l.axial
+		case frameRotated:
+			lx, ly, lz := intoFrame(&l.e, x, y, z)
+			lx, ly, lz = l.local(lx, ly, lz)
+			vx, vy, vz = outOfFrame(&l.e, lx, ly, lz)
+		default:
+			vx, vy, vz = l.local(x, y, z)
+		}
+	}
+	if l.moving {
+		vx, vy, vz = vx+l.shift[0], vy+l.shift[1], vz+l.shift[2]
+	}
+	return vx, vy, vz, far
+}
+
+// local is kernel.local for the objects of the lean kernels
+func (l *leanKernel[T]) local(x, y, z T) (vx, vy, vz T) {
+	if l.empty {
+		return l.u, 0, 0
+	}
+	switch l.kind {
+	case Sphere:
+		r2 := x*x + y*y + z*z
+		r := sqrt(r2)
+		if r <= l.body {
+			return 0, 0, 0
+		}
+		return sphereFlow(l.u, l.r3, r2, r, x, y, z)
+	case Cylinder:
+		rxy2 := x*x + y*y
+		if sqrt(rxy2) <= l.body {
+			return 0, 0, 0
+		}
+		vx, vy = cylinderFlow(l.u, l.r2, rxy2, x, y)
+		return vx, vy, cylinderSpan(l.rho, l.ref, vx, vy, z)
+	}
+	rxy2 := x*x + y*y
+	if sqrt(rxy2) <= l.body {
+		return 0, 0, 0
+	}
+	vx, vy = airfoilFlow(l.u, l.r2, l.vortex, rxy2, x, y)
+	return vx, vy, 0
+}
+
+// Fast reports whether f is evaluated in float32: with ArithmeticFast, for
+// a flow the fast kernels cover
+func (f *Flow) Fast() bool {
+	if f.Arithmetic != ArithmeticFast {
+		return false
+	}
+	k := f.kernel()
+	return k.lean != nil
+}
+
+// ArithmeticError measures the error of the fast arithmetic against the
+// accurate one for f: n points are sampled on shells round the object, from
+// just outside it to ten radii away, and |v_fast - v_accurate|/U∞ is
+// evaluated there, U∞ the onset speed. It is zero where the fast kernels
+// don't cover f, which is then evaluated accurately either way.
+func (f *Flow) ArithmeticError(n int) (Residual, error) {
+	var res Residual
+	if err := f.Validate(); err != nil {
+		return res, err
+	}
+	u := math.Sqrt(f.onset2())
+	if err := checkFreeStream(u); err != nil {
+		return res, err
+	}
+	if n > MaxTangencySamples {
+		return res, Errorf(ErrBadArguments, "at most %d arithmetic samples are taken, got %d", MaxTangencySamples, n)
+	}
+	fast, accurate := *f, *f
+	fast.Arithmetic, accurate.Arithmetic = ArithmeticFast, ArithmeticAccurate
+	kf, ka := fast.kernel(), accurate.kernel()
+	o := f.Object
+	r := o.Radius
+	if r == 0 {
+		r = 1
+	}
+	shells := []float64{1 + 1e-3, 1.1, 1.5, 3, 10}
+	for i := 0; i < n; i++ {
+		sphere := ObjectSpec{Type: Sphere, X: o.X, Y: o.Y, Z: o.Z, Radius: shells[i%len(shells)] * r}
+		p, _ := sphere.SurfacePoint(i, n)
+		ax, ay, az := kf.velocityAt(p[0], p[1], p[2])
+		bx, by, bz := ka.velocityAt(p[0], p[1], p[2])
+		dx, dy, dz := ax-bx, ay-by, az-bz
+		res.add(math.Sqrt(dx*dx+dy*dy+dz*dz)/u, p[0], p[1], p[2])
+	}
+	res.finish()
+	return res, nil
+}
@@ -0,0 +1,86 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestArithmetic(t *testing.T) {
+	run(t, (*checker).checkArithmetic)
+}
+
+// checkArithmetic measures the fast arithmetic against the accurate one
+// past the objects it covers, aligned and oblique, with a cutoff and a
+// moving body, checks that the flows it doesn't cover are evaluated
+// accurately, and steps a simulation in both arithmetics.
+func (c *checker) checkArithmetic() error {
+	worst, covered := 0.0, true
+	for _, t := range []flow.ObjectType{flow.Sphere, flow.Cylinder, flow.Airfoil} {
+		for k := 0; k < 3; k++ {
+			f := checkFlow(t)
+			f.Arithmetic = flow.ArithmeticFast
+			switch k {
+			case 1:
+				f.Direction = [3]float64{0.8, 0.36, 0.48}
+				f.Object.X, f.Object.Y = 0.3, -0.2
+			case 2:
+				f.Cutoff, f.Frame, f.Motion = 20, flow.FrameLab, [3]float64{0.25, 0, 0.1}
+			}
+			res, err := f.ArithmeticError(flow.MaxTangencySamples)
+			if err != nil {
+				return err
+			}
+			worst = math.Max(worst, res.Max)
+			covered = covered && f.Fast()
+		}
+	}
+
+	accurate := 0
+	for k := 0; k < 2; k++ {
+		f := checkFlow(flow.Sphere)
+		f.Arithmetic = flow.ArithmeticFast
+		if k == 0 {
+			f.Object.Type = flow.Box
+		} else {
+			f.Object.Strengths = flow.Strengths{Doublet: 3, Set: flow.StrengthDoublet}
+		}
+		res, err := f.ArithmeticError(64)
+		if err != nil {
+			return err
+		}
+		if !f.Fast() && res.Max == 0 {
+			accurate++
+		}
+	}
+
+	var start []float32
+	for i := 0; i < 300; i++ {
+		start = append(start, float32(-6+12*math.Mod(float64(i)*0.6180339887, 1)), float32(-3+6*math.Mod(float64(i)*0.7548776662, 1)), float32(-2+4*math.Mod(float64(i)*0.5698402910, 1)))
+	}
+	var steps [2]*flow.Simulation
+	for j, a := range []string{flow.ArithmeticAccurate, flow.ArithmeticFast} {
+		config := flow.DefaultConfig()
+		config.Arithmetic = a
+		s := flow.NewSimulation(config)
+		if err := s.SetParticles(start, len(start)/3); err != nil {
+			return err
+		}
+		for n := 0; n < 8; n++ {
+			if err := s.Step(0.05); err != nil {
+				return err
+			}
+		}
+		steps[j] = s
+	}
+	drift := 0.0
+	for i := range start {
+		drift = math.Max(drift, math.Abs(float64(steps[1].Positions[i]-steps[0].Positions[i])))
+	}
+
+	c.report("fast arithmetic", worst < fastTolerance && covered && accurate == 2 && drift < 1e-4,
+		"max |Δv|/U = %.3g; covered %v; uncovered flows accurate %d of 2; particles %.3g apart after 8 steps",
+		worst, covered, accurate, drift)
+	return nil
+}
@@ -0,0 +1,117 @@
+package flow
+
+import "math"
+
+// BernoulliReach is the distance upstream, in object radii, or in metres
+// without an object, from which BernoulliResidual integrates the momentum
+// equation
+const BernoulliReach = 20
+
+// bernoulliSamples is the number of midpoint samples of the integral of
+// BernoulliResidual
+const bernoulliSamples = 400
+
+// BernoulliResiduals are the values of BernoulliResidual with their
+// summary over the points in the fluid
+type BernoulliResiduals struct {
+	Values   []float32 // (H - H∞)/(½ρU²) per point, 0 out of the fluid
+	Summary  Residual  // Of |Values| over the points in the fluid
+	Excluded int       // Points out of the fluid
+}
+
+// BernoulliResidual measures how far the flow of f breaks Bernoulli's
+// equation at count positions: the deviation of the total pressure H =
+// p + ½ρ|v|² from its value upstream, over ½ρU², U the onset speed, with p
+// the pressure the steady momentum equation gives rather than the one the
+// simulator derives from Bernoulli's equation. By Crocco's theorem ∇H =
+// ρ(v × ω), so H is integrated from BernoulliReach upstream, along the
+// free stream line through each point, over the Lamb vector v × ω, with ω
+// from VelocityGradient and v relative to the object, whose frame is the
+// steady one for a body moving at a constant velocity. The residual
+// vanishes, up to the roundoff of the differences, wherever the flow is
+// irrotational, whatever the path; the non-potential terms light up: the
+// spanwise flow of the cylinder off z = 0, the lift model of the airfoil
+// section, the swirl of the onset flow, vortex elements with a core. The
+// flow is taken as it is at the instant; the ∂φ/∂t of an accelerating
+// body or a changing free stream is not included (see
+// Simulation.Unsteady). Samples of the path out of the fluid add nothing,
+// and points out of the fluid get 0 and are counted as Excluded.
+func BernoulliResidual[T Float](positions []T, count int, f Flow) (BernoulliResiduals, error) {
+	var b BernoulliResiduals
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return b, err
+	}
+	if err := f.Validate(); err != nil {
+		return b, err
+	}
+	u2 := f.onset2()
+	if err := checkFreeStream(math.Sqrt(u2)); err != nil {
+		return b, err
+	}
+	g := f.onset()
+	d := g.direction()
+	reach := float64(BernoulliReach)
+	if f.Object.Radius != 0 {
+		reach *= f.Object.Radius
+	}
+	ds := reach / bernoulliSamples
+	shift := f.ObjectVelocity()
+	k := f.kernel()
+	b.Values = make([]float32, count)
+	inside := make([]bool, count)
+	_, err := parallel(count, func(from, to int) (int, error) {
+		for i := from; i < to; i++ {
+			x, y, z := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+			if inside[i] = f.solid(x, y, z); inside[i] {
+				continue
+			}
+			h := 0.0
+			for j := 0; j < bernoulliSamples; j++ {
+				s := reach - (float64(j)+0.5)*ds
+				px, py, pz := x-s*d[0], y-s*d[1], z-s*d[2]
+				if f.solid(px, py, pz) {
+					continue
+				}
+				vx, vy, vz := k.velocityAt(px, py, pz)
+				v := [3]float64{vx - shift[0], vy - shift[1], vz - shift[2]}
+				gr := f.VelocityGradient(px, py, pz)
+				w := [3]float64{gr[2][1] - gr[1][2], gr[0][2] - gr[2][0], gr[1][0] - gr[0][1]}
+				l := cross(v, w)
+				h += (l[0]*d[0] + l[1]*d[1] + l[2]*d[2]) * ds
+			}
+			b.Values[i] = float32(h / (u2 / 2))
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return b, err
+	}
+	for i := 0; i < count; i++ {
+		if inside[i] {
+			b.Excluded++
+			continue
+		}
+		b.Summary.add(math.Abs(float64(b.Values[i])), float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2]))
+	}
+	b.Summary.finish()
+	return b, nil
+}
+
+// Unsteady reports whether the flow of s changes in the frame of its
+// object in a way the quasi-steady kernels don't see, leaving out the
+// ∂φ/∂t of the unsteady Bernoulli equation: the free stream following a
+// Schedule of more than one speed, or a Motion accelerating the object,
+// linear with an acceleration, circular or along waypoints
+func (s *Simulation) Unsteady() bool {
+	if sc := s.Schedule; sc != nil {
+		for _, p := range sc.Points {
+			if p.U != sc.Points[0].U {
+				return true
+			}
+		}
+	}
+	if m := s.Motion; m != nil {
+		return m.Type != MotionLinear || m.Acceleration != [3]float64{}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestBernoulliResidual(t *testing.T) {
+	run(t, (*checker).checkBernoulliResidual)
+}
+
+// checkBernoulliResidual finds the total pressure of potential flow
+// around a sphere, moving or not, and of a cylinder in its plane of
+// symmetry the same as upstream, and not that of the airfoil's lift model
+// or of the cylinder's spanwise flow off that plane; schedules and
+// accelerating motions flag the flow unsteady
+func (c *checker) checkBernoulliResidual() error {
+	points := []float64{2, 0.5, 0, 3, -1, 0, -1.5, 0.8, 0, 0.2, 1.2, -0.4, 0, 0, 0}
+	worst := 0.0
+	for _, t := range []flow.ObjectType{flow.Sphere, flow.Cylinder, flow.EllipticCylinder} {
+		f := checkFlow(t)
+		if t == flow.Sphere {
+			f.Frame, f.Motion = flow.FrameLab, [3]float64{0.3, 0.1, 0}
+		}
+		b, err := flow.BernoulliResidual(points[:9], 3, f)
+		if err != nil {
+			return err
+		}
+		worst = math.Max(worst, b.Summary.Max)
+	}
+	airfoil, err := flow.BernoulliResidual(points, 5, checkFlow(flow.Airfoil))
+	if err != nil {
+		return err
+	}
+	spanwise, err := flow.BernoulliResidual([]float64{2, 0.5, 2}, 1, checkFlow(flow.Cylinder))
+	if err != nil {
+		return err
+	}
+	lit := airfoil.Summary.Max > 0.1 && airfoil.Excluded == 1 && airfoil.Summary.Samples == 4 && airfoil.Values[4] == 0 && spanwise.Values[0] != 0 &&
+		math.Abs(float64(spanwise.Values[0])) > 1e3*worst
+
+	s := flow.NewSimulation(flow.DefaultConfig())
+	steady := !s.Unsteady()
+	s.Schedule = &flow.Schedule{Points: []flow.SchedulePoint{{T: 0, U: 1}, {T: 1, U: 1}}}
+	steady = steady && !s.Unsteady()
+	s.Schedule.Points[1].U = 2
+	unsteady := s.Unsteady()
+	s.Schedule = nil
+	s.Motion = &flow.Motion{Type: flow.MotionLinear, Velocity: [3]float64{1, 0, 0}}
+	steady = steady && !s.Unsteady()
+	s.Motion.Acceleration[1] = 0.5
+	unsteady = unsteady && s.Unsteady()
+
+	c.report("bernoulli residual", worst < 1e-9 && lit && steady && unsteady,
+		"potential flows off by %.3g; airfoil lift model %.3g, cylinder at z = 2 %.3g; steady flows %v, unsteady flagged %v",
+		worst, airfoil.Summary.Max, spanwise.Values[0], steady, unsteady)
+	return nil
+}
@@ -0,0 +1,154 @@
+package flow
+
+import "math"
+
+// Boundary modes. A wall or free surface is the plane z = Height above a
+// sphere, modelled by the sphere's image reflected in it: an image of the
+// same sign for a rigid wall, whose normal velocity then vanishes on the
+// plane, and of the opposite sign for a free surface in the high Froude
+// number limit, where the disturbance potential, and with it the
+// disturbance pressure to first order, vanishes on the plane instead.
+const (
+	BoundaryNone        = "none"
+	BoundaryWall        = "wall"
+	BoundaryFreeSurface = "freeSurface"
+)
+
+// BoundaryModes lists the boundary modes
+var BoundaryModes = []string{BoundaryNone, BoundaryWall, BoundaryFreeSurface}
+
+// Boundary is the plane bounding the fluid from above. Above it there is
+// no fluid: velocities are zero there, so particles crossing it stop,
+// unless Clamp holds them on the plane, where they slide along it.
+type Boundary struct {
+	Mode   string // One of BoundaryModes; "" means BoundaryNone
+	Height float64
+	Clamp  bool
+}
+
+// image returns the sign of the image of b: 1 for a wall, -1 for a free
+// surface and 0 without a boundary
+func (b *Boundary) image() float64 {
+	switch b.Mode {
+	case BoundaryWall:
+		return 1
+	case BoundaryFreeSurface:
+		return -1
+	}
+	return 0
+}
+
+// Validate checks the parameters of b
+func (b *Boundary) Validate() error {
+	switch b.Mode {
+	case "", BoundaryNone, BoundaryWall, BoundaryFreeSurface:
+	default:
+		return Errorf(ErrBadArguments, "boundaries.mode must be one of %v, got %q", BoundaryModes, b.Mode)
+	}
+	if math.IsNaN(b.Height) || math.IsInf(b.Height, 0) {
+		return Errorf(ErrBadArguments, "boundaries.height must be finite, got %g", b.Height)
+	}
+	return nil
+}
+
+// validateBoundary checks that the boundary of f can be imaged: the body a
+// sphere below the plane and the onset flow parallel to it
+func (f *Flow) validateBoundary() error {
+	b := &f.Boundary
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	if b.image() == 0 {
+		return nil
+	}
+	o := &f.Object
+	if o.Radius != 0 {
+		if o.Type != Sphere {
+			return Errorf(ErrUnsupported, "a %v extends along z through the %s; only spheres have an image", o.Type, b.Mode)
+		}
+		if o.Z+o.Radius >= b.Height {
+			return Errorf(ErrBadArguments, "the sphere must be below the %s at z = %g, its top is at %g", b.Mode, b.Height, o.Z+o.Radius)
+		}
+	}
+	if d := f.direction(); d[2] != 0 || f.Motion[2] != 0 {
+		return Errorf(ErrBadArguments, "the free stream and object motion must be parallel to the %s, got direction %v and motion %v", b.Mode, d, f.Motion)
+	}
+	return nil
+}
+
+// Encode returns b in the generic form accepted by DecodeConfig under
+// boundaries
+func (b Boundary) Encode() map[string]interface{} {
+	mode := b.Mode
+	if mode == "" {
+		mode = BoundaryNone
+	}
+	return map[string]interface{}{"mode": mode, "height": b.Height, "clamp": b.Clamp}
+}
+
+// DecodeBoundary decodes the boundaries section of a configuration,
+//
+//	{mode: "none" | "wall" | "freeSurface" = "none", height: number = 0,
+//	 clamp: bool = false}
+func DecodeBoundary(v interface{}) (Boundary, error) {
+	b := Boundary{Mode: BoundaryNone}
+	m, err := section(v, "boundaries", "mode", "height", "clamp")
+	if err != nil {
+		return b, err
+	}
+	if v, ok := m["mode"]; ok {
+		if b.Mode, ok = v.(string); !ok {
+			return b, Errorf(ErrBadArguments, "boundaries.mode must be a string")
+		}
+	}
+	if err := numberKey(m, "boundaries", "height", &b.Height); err != nil {
+		return b, err
+	}
+	if v, ok := m["clamp"]; ok {
+		if b.Clamp, ok = v.(bool); !ok {
+			return b, Errorf(ErrBadArguments, "boundaries.clamp must be a boolean")
+		}
+	}
+	return b, b.Validate()
+}
+
+// imaged is direct with the image of the disturbance in the boundary
+// plane added: the disturbance at the mirror point, reflected. Above the
+// plane there is no fluid and the velocity is zero.
+func (k *kernel) imaged(px, py, pz float64) (vx, vy, vz float64) {
+	if k.excluded(px, py, pz) {
+		return 0, 0, 0
+	}
+	vx, vy, vz = k.direct(px, py, pz)
+	ix, iy, iz := k.direct(px, py, 2*k.surface-pz)
+	s, u := k.image, k.free
+	return vx + s*(ix-u[0]), vy + s*(iy-u[1]), vz - s*(iz-u[2])
+}
+
+// clampBoundary moves the particles above the boundary plane of s back
+// onto it, if the boundary clamps them, counting them in Stats.Clamped
+func (s *Simulation) clampBoundary(count int) {
+	b := &s.Config.Boundary
+	if b.image() == 0 || !b.Clamp {
+		return
+	}
+	h := float32(b.Height)
+	for i := 0; i < count; i++ {
+		if z := &s.Positions[i*3+2]; *z > h {
+			*z = h
+			s.Stats.Clamped++
+		}
+	}
+}
+
+// SetBoundary replaces the boundary plane, checking it against the
+// current flow
+func (s *Simulation) SetBoundary(b Boundary) error {
+	f := s.Flow()
+	f.Boundary = b
+	if err := f.validateBoundary(); err != nil {
+		return err
+	}
+	s.Config.Boundary = b
+	return nil
+}
@@ -0,0 +1,73 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestBoundary(t *testing.T) {
+	run(t, (*checker).checkBoundary)
+}
+
+// checkBoundary verifies the image of a sphere in the plane above it: no
+// flow through a wall, no disturbance along a free surface, no velocity
+// above the plane, a faster flow over the sphere under a wall than under a
+// free surface, an image that fades far from the plane, and the clamping
+// of particles onto the plane through a step and a snapshot
+func (c *checker) checkBoundary() error {
+	const height = 2
+	f := checkFlow(flow.Sphere)
+	wall, free := f, f
+	wall.Boundary = flow.Boundary{Mode: flow.BoundaryWall, Height: height}
+	free.Boundary = flow.Boundary{Mode: flow.BoundaryFreeSurface, Height: height}
+	if err := wall.Validate(); err != nil {
+		return err
+	}
+	normal, surface := 0.0, 0.0
+	for j := 0; j < 100; j++ {
+		x, y := float64(j%10)/2-2.2, float64(j/10)/2-2.3
+		_, _, vz := wall.VelocityAt(x, y, height)
+		normal = math.Max(normal, math.Abs(vz))
+		vx, vy, _ := free.VelocityAt(x, y, height)
+		surface = math.Max(surface, math.Hypot(vx-1, vy))
+	}
+	ax, ay, az := wall.VelocityAt(0.5, 0, height+0.1)
+	bx, by, bz := free.VelocityAt(0.5, 0, height+0.1)
+	above := ax == 0 && ay == 0 && az == 0 && bx == 0 && by == 0 && bz == 0
+	gapWall, _, _ := wall.VelocityAt(0, 0, 1.5)
+	gapFree, _, _ := free.VelocityAt(0, 0, 1.5)
+	gap, _, _ := f.VelocityAt(0, 0, 1.5)
+	ux, _, _ := wall.VelocityAt(0, 0, -30)
+	u0, _, _ := f.VelocityAt(0, 0, -30)
+	fade := math.Abs(ux - u0)
+
+	// Ahead of the sphere the flow rises through the free surface, whose
+	// image doesn't stop it; clamped particles end up on the plane
+	config := flow.DefaultConfig()
+	config.Boundary = flow.Boundary{Mode: flow.BoundaryFreeSurface, Height: height, Clamp: true}
+	s := flow.NewSimulation(config)
+	if err := s.SetParticles([]float32{-1.2, 0, 1.95, 3, 0, -1}, 2); err != nil {
+		return err
+	}
+	if err := s.Step(1); err != nil {
+		return err
+	}
+	snapshot, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	restored := flow.NewSimulation(flow.DefaultConfig())
+	if err := restored.UnmarshalBinary(snapshot); err != nil {
+		return err
+	}
+	clamped := s.Positions[2] == height && s.Positions[5] < height && restored.Config.Boundary == config.Boundary &&
+		s.Stats.Clamped == 1 && restored.Stats.Clamped == 1
+
+	ok := normal < strengthTolerance && surface < strengthTolerance && above &&
+		gapWall > gap && gap > gapFree && fade < imageTolerance && clamped
+	c.report("boundary images", ok, "wall max |vz| %.3g; free surface max |v - U| %.3g; zero above %v; gap u wall %.4f, none %.4f, free surface %.4f; 30 radii below %.3g; clamped %v",
+		normal, surface, above, gapWall, gap, gapFree, fade, clamped)
+	return nil
+}
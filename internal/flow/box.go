@@ -0,0 +1,306 @@
+package flow
+
+import (
+	"math"
+	"sync"
+)
+
+// Box panel parameters
+const (
+	DefaultBoxPanels       = 8 // Panels along the longest edge of a box, when Panels is 0
+	MinBoxPanels           = 2
+	MaxBoxPanels           = 16
+	DefaultBoxEdgeFraction = 0.05 // Edge radius of a box, as a fraction of its shortest edge, when EdgeRadius is 0
+	boxFarPanel            = 4    // Distance, in panel diagonals, beyond which a panel acts as a point source
+)
+
+// validateBox checks the box parameters of o, and that objects other than
+// boxes don't set them
+func (o *ObjectSpec) validateBox() error {
+	for _, l := range o.Size {
+		if l < 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+			return Errorf(ErrBadArguments, "size must be non-negative and finite, got %v", o.Size)
+		}
+	}
+	if n := o.Panels; n != 0 && (n < MinBoxPanels || n > MaxBoxPanels) {
+		return Errorf(ErrBadArguments, "panels must be 0 (default %d) or between %d and %d, got %d", DefaultBoxPanels, MinBoxPanels, MaxBoxPanels, n)
+	}
+	if o.Type != Box {
+		if o.Size != [3]float64{} || o.Panels != 0 {
+			return Errorf(ErrBadArguments, "size and panels only apply to boxes, not to a %v", o.Type)
+		}
+		return nil
+	}
+	if s := o.Size; s != [3]float64{} && (s[0] == 0 || s[1] == 0 || s[2] == 0) {
+		return Errorf(ErrBadArguments, "a box needs all three edges, got size %v", s)
+	}
+	if o.Strengths.Set != 0 {
+		return Errorf(ErrUnsupported, "a box has no strength overrides: its panel sources are solved for")
+	}
+	if o.CollisionRadius != 0 {
+		return Errorf(ErrBadArguments, "a box has no collision radius: its inside is the box")
+	}
+	h := o.halfSize()
+	if e := o.EdgeRadius; e != 0 && e >= math.Min(h[0], math.Min(h[1], h[2])) {
+		return Errorf(ErrBadArguments, "a box needs an edgeRadius below half its shortest edge, got %g", e)
+	}
+	return nil
+}
+
+// halfSize returns the half edges of a box along its axes: Size, or a
+// cube, scaled so the half diagonal is Radius
+func (o *ObjectSpec) halfSize() [3]float64 {
+	s := o.Size
+	if s == [3]float64{} {
+		s = [3]float64{1, 1, 1}
+	}
+	k := o.Radius / math.Sqrt(s[0]*s[0]+s[1]*s[1]+s[2]*s[2])
+	return [3]float64{k * s[0], k * s[1], k * s[2]}
+}
+
+// boxEdgeRadius returns the edge regularization radius of a box
+func (o *ObjectSpec) boxEdgeRadius() float64 {
+	if o.EdgeRadius != 0 {
+		return o.EdgeRadius
+	}
+	h := o.halfSize()
+	return DefaultBoxEdgeFraction * 2 * math.Min(h[0], math.Min(h[1], h[2]))
+}
+
+// boxAxes returns the unit axes of a box: its first edge along the chord
+// of alpha, (cos α, -sin α, 0), the second normal to it in the xy plane
+// and the third along z
+func (o *ObjectSpec) boxAxes() [3][3]float64 {
+	t, n := o.chord()
+	return [3][3]float64{{t[0], t[1], 0}, {n[0], n[1], 0}, {0, 0, 1}}
+}
+
+// toBox returns the offset (x, y, z) from the center of a box along its
+// axes
+func (o *ObjectSpec) toBox(x, y, z float64) [3]float64 {
+	t, n := o.chord()
+	return [3]float64{x*t[0] + y*t[1], x*n[0] + y*n[1], z}
+}
+
+// insideBox reports whether the offset (x, y, z) from the center of a box
+// is inside it
+func (o *ObjectSpec) insideBox(x, y, z float64) bool {
+	p, h := o.toBox(x, y, z), o.halfSize()
+	return math.Abs(p[0]) <= h[0] && math.Abs(p[1]) <= h[1] && math.Abs(p[2]) <= h[2]
+}
+
+// boxPanel is a rectangle of a face of a box, in radii along the box axes
+type boxPanel struct {
+	axis int        // Axis of the face normal; the panel spans the next two
+	side float64    // Sign of the outward normal along axis
+	c    [3]float64 // Center, the collocation point
+	h    [2]float64 // Half widths along the next two axes
+	far2 float64    // Squared distance beyond which it acts as a point source
+}
+
+// boxSolution is the potential flow past a box: the free stream plus the
+// flow of constant strength source rectangles covering its faces, their
+// strengths solved so the normal velocity vanishes at every panel center.
+// Panels are bunched toward the edges like the cosine of a uniform angle,
+// where the flow round the sharp edges varies fastest, and the collocation
+// points nearest an edge are inset from it by half the smallest panel. The
+// logarithmic singularity of the velocity along the panel edges is
+// regularized over the edge radius ε, R² becoming R² + ε² in the panel
+// velocity. The solution is kept for a unit onset along each box axis, so
+// any onset direction combines them.
+type boxSolution struct {
+	half [3]float64 // Half edges, in radii
+	n    int        // Panels along the longest edge
+	edge float64    // Edge radius, in radii
+
+	panels []boxPanel
+	sigma  [3][]float64 // Strengths for a unit onset along each axis
+}
+
+// lastBox holds the last box solution, since boxes differing only in
+// size, orientation and speed share it and a flow's kernel is prepared
+// often
+var lastBox struct {
+	sync.Mutex
+	b *boxSolution
+}
+
+// boxPanels returns the panel solution of a box of half edges half and
+// edge radius edge, in radii, with n panels along its longest edge
+func boxPanels(half [3]float64, n int, edge float64) (*boxSolution, error) {
+	lastBox.Lock()
+	defer lastBox.Unlock()
+	if b := lastBox.b; b != nil && b.half == half && b.n == n && b.edge == edge {
+		return b, nil
+	}
+	b := &boxSolution{half: half, n: n, edge: edge}
+	longest := math.Max(half[0], math.Max(half[1], half[2]))
+	// Cosine-spaced panel boundaries along each edge
+	var cuts [3][]float64
+	for a, h := range half {
+		m := int(math.Ceil(float64(n) * h / longest))
+		if m < MinBoxPanels {
+			m = MinBoxPanels
+		}
+		cuts[a] = make([]float64, m+1)
+		for i := range cuts[a] {
+			cuts[a][i] = -h * math.Cos(math.Pi*float64(i)/float64(m))
+		}
+	}
+	for a := 0; a < 3; a++ {
+		t1, t2 := (a+1)%3, (a+2)%3
+		for _, side := range []float64{-1, 1} {
+			for i := 1; i < len(cuts[t1]); i++ {
+				for j := 1; j < len(cuts[t2]); j++ {
+					p := boxPanel{axis: a, side: side}
+					p.c[a] = side * half[a]
+					p.c[t1], p.h[0] = (cuts[t1][i]+cuts[t1][i-1])/2, (cuts[t1][i]-cuts[t1][i-1])/2
+					p.c[t2], p.h[1] = (cuts[t2][j]+cuts[t2][j-1])/2, (cuts[t2][j]-cuts[t2][j-1])/2
+					p.far2 = 4 * boxFarPanel * boxFarPanel * (p.h[0]*p.h[0] + p.h[1]*p.h[1])
+					b.panels = append(b.panels, p)
+				}
+			}
+		}
+	}
+	count := len(b.panels)
+	m := make([][]float64, count)
+	eps2 := edge * edge
+	for i, p := range b.panels {
+		m[i] = make([]float64, count)
+		for j := range b.panels {
+			v := b.panels[j].velocity(p.c, eps2)
+			m[i][j] = p.side * v[p.axis]
+		}
+	}
+	for a := range b.sigma {
+		b.sigma[a] = make([]float64, count)
+		for i, p := range b.panels {
+			if p.axis == a {
+				b.sigma[a][i] = -p.side
+			}
+		}
+	}
+	if !eliminate(m, b.sigma[:]) {
+		return nil, Errorf(ErrBadArguments, "box: the panel system is singular")
+	}
+	lastBox.b = b
+	return b, nil
+}
+
+// velocity returns the velocity at q, along the box axes, of the panel p
+// of unit source strength. Over the rectangle [ξ1, ξ2]×[η1, η2] of its
+// plane, at offset (x, y, z) from its center along its axes and
+// normal, it is the sum over the corners of
+//
+//	u_x = ±ln(R + y - η)/4π,  u_y = ±ln(R + x - ξ)/4π,
+//	u_z = ∓atan((x - ξ)(y - η)/(z·R))/4π
+//
+// with R the distance to the corner and the upper sign at (ξ2, η1) and
+// (ξ1, η2). Beyond far2 it is that of a point source.
+func (p *boxPanel) velocity(q [3]float64, eps2 float64) [3]float64 {
+	t1, t2 := (p.axis+1)%3, (p.axis+2)%3
+	x, y, z := q[t1]-p.c[t1], q[t2]-p.c[t2], p.side*(q[p.axis]-p.c[p.axis])
+	var ux, uy, uz float64
+	if d2 := x*x + y*y + z*z; d2 > p.far2 {
+		k := p.h[0] * p.h[1] / (math.Pi * d2 * math.Sqrt(d2))
+		ux, uy, uz = k*x, k*y, k*z
+	} else {
+		for _, sx := range []float64{-1, 1} {
+			dx := x - sx*p.h[0]
+			for _, sy := range []float64{-1, 1} {
+				dy := y - sy*p.h[1]
+				r := math.Sqrt(dx*dx + dy*dy + z*z + eps2)
+				s := sx * sy
+				ux -= s * logSum(r, dy, dx*dx+z*z+eps2)
+				uy -= s * logSum(r, dx, dy*dy+z*z+eps2)
+				if z != 0 {
+					uz += s * math.Atan(dx*dy/(z*r))
+				} else if dx*dy != 0 {
+					// In the plane: the limit from outside
+					uz += s * math.Copysign(math.Pi/2, dx*dy)
+				}
+			}
+		}
+		const scale = 1 / (4 * math.Pi)
+		ux, uy, uz = scale*ux, scale*uy, scale*uz
+	}
+	var v [3]float64
+	v[t1], v[t2], v[p.axis] = ux, uy, p.side*uz
+	return v
+}
+
+// logSum returns ln(r + d), where r² = d² + rest, without cancellation
+// when d is negative
+func logSum(r, d, rest float64) float64 {
+	if d >= 0 {
+		return math.Log(r + d)
+	}
+	return math.Log(rest / (r - d))
+}
+
+// boxFrame prepares the panels of k and their strengths for the onset
+// speed, along the box axes, with the map between the kernel frame and
+// the box axes. A solution that fails, which Validate reports, leaves
+// none.
+func (k *kernel) boxFrame() {
+	o := &k.obj
+	if o.Radius == 0 {
+		return
+	}
+	h := o.halfSize()
+	r := o.Radius
+	b, err := boxPanels([3]float64{h[0] / r, h[1] / r, h[2] / r}, o.panels(), o.boxEdgeRadius()/r)
+	if err != nil {
+		return
+	}
+	e := [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if k.mode == frameRotated {
+		e = k.e
+	}
+	axes := o.boxAxes()
+	for i := range axes {
+		for j := range e {
+			k.boxMap[i][j] = axes[i][0]*e[j][0] + axes[i][1]*e[j][1] + axes[i][2]*e[j][2]
+		}
+	}
+	k.panels, k.edge2 = b.panels, b.edge*b.edge
+	k.sigma = make([]float64, len(b.panels))
+	for a := range b.sigma {
+		u := k.u * k.boxMap[a][0]
+		for j, s := range b.sigma[a] {
+			k.sigma[j] += u * s
+		}
+	}
+}
+
+// panels returns the number of panels along the longest edge of a box
+func (o *ObjectSpec) panels() int {
+	if o.Panels != 0 {
+		return o.Panels
+	}
+	return DefaultBoxPanels
+}
+
+// box is local for a box: the free stream plus its panel sources
+func (k *kernel) box(x, y, z float64) (vx, vy, vz float64) {
+	m := &k.boxMap
+	var q [3]float64
+	for i := range q {
+		q[i] = m[i][0]*x + m[i][1]*y + m[i][2]*z
+	}
+	h := k.obj.halfSize()
+	if math.Abs(q[0]) <= h[0] && math.Abs(q[1]) <= h[1] && math.Abs(q[2]) <= h[2] {
+		return 0, 0, 0
+	}
+	r := k.obj.Radius
+	q = [3]float64{q[0] / r, q[1] / r, q[2] / r}
+	v := [3]float64{k.u * m[0][0], k.u * m[1][0], k.u * m[2][0]}
+	for j := range k.panels {
+		w := k.panels[j].velocity(q, k.edge2)
+		s := k.sigma[j]
+		v[0], v[1], v[2] = v[0]+s*w[0], v[1]+s*w[1], v[2]+s*w[2]
+	}
+	return m[0][0]*v[0] + m[1][0]*v[1] + m[2][0]*v[2],
+		m[0][1]*v[0] + m[1][1]*v[1] + m[2][1]*v[2],
+		m[0][2]*v[0] + m[1][2]*v[1] + m[2][2]*v[2]
+}
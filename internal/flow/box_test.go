@@ -0,0 +1,45 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestBox(t *testing.T) {
+	run(t, (*checker).checkBox)
+}
+
+// checkBox verifies the panel solution of a 2×1×1 box: the stagnation
+// point at the center of the face it meets the stream with, Cp = 1, the
+// tangency over its faces, that turning box and stream together turns the
+// flow, and the turned box as the inside
+func (c *checker) checkBox() error {
+	f := checkFlow(flow.Box)
+	f.Object.Size, f.Object.Radius = [3]float64{2, 1, 1}, math.Sqrt(6)/2
+	vx, vy, vz := f.VelocityAt(-1-1e-9, 0, 0)
+	cp := 1 - (vx*vx+vy*vy+vz*vz)/(f.FreeStream*f.FreeStream)
+	res, err := f.TangencyResidual(1200)
+	if err != nil {
+		return err
+	}
+	turned := f
+	turned.Object.Alpha = math.Pi / 6
+	sa, ca := math.Sincos(turned.Object.Alpha)
+	turned.Direction = [3]float64{ca, -sa, 0}
+	turn := 0.0
+	for _, p := range [][3]float64{{-1.5, 0.3, 0.2}, {0.4, 0.7, -0.1}, {1.2, -0.2, 0.6}, {3, 2, 1}} {
+		ux, uy, uz := f.VelocityAt(p[0], p[1], p[2])
+		tx, ty, tz := turned.VelocityAt(p[0]*ca+p[1]*sa, -p[0]*sa+p[1]*ca, p[2])
+		dx, dy, dz := tx-(ux*ca+uy*sa), ty-(-ux*sa+uy*ca), tz-uz
+		turn = math.Max(turn, math.Sqrt(dx*dx+dy*dy+dz*dz))
+	}
+	o := turned.Object
+	inside := o.Contains(0.99*ca, -0.99*sa, 0) && !o.Contains(1.01*ca, -1.01*sa, 0) && o.Contains(0.49*sa, 0.49*ca, 0.49) && !o.Contains(0.51*sa, 0.51*ca, 0)
+
+	ok := math.Abs(cp-1) < boxCpTolerance && res.RMS < boxTangencyRMS && turn < boxTurnTolerance && inside
+	c.report("box", ok, "face center Cp %.6f; |v·n|/U max %.3g, rms %.3g; turned - unturned %.3g; inside %v",
+		cp, res.Max, res.RMS, turn, inside)
+	return nil
+}
@@ -0,0 +1,354 @@
+package flow
+
+import (
+	"math"
+	"sync"
+)
+
+// Bump panel counts and extent
+const (
+	DefaultBumpPanels = 101 // Source panels along a bump, when Panels is 0; an odd count centers one on the crest
+	MinBumpPanels     = 9
+	MaxBumpPanels     = 401
+	bumpExtent        = 5    // Half-length of the paneled stretch of wall, in widths; the bump is 4e-6 of its height there
+	bumpEdge          = 1e-6 // Regularization of the panel end singularities, in widths
+)
+
+// Bump is a flat wall along y = 0 with a Gaussian bump, the fluid lying
+// above y = Height·exp(-(x - X)²/2Width²). It extends along z like a
+// cylinder. The flow is the free stream along +x plus that of constant
+// strength source panels covering the bump, from X - 5·Width to X +
+// 5·Width, and their images in the wall, which keep the flow along it;
+// the strengths are solved so the flow runs along the bump at every
+// panel center. The wall there is the panels themselves, straight
+// between points of the Gaussian. It speeds up over the crest, where Bernoulli's equation
+// gives the pressure minimum, and slows down at the feet. Height 0 is the
+// plain wall with the free stream over it; Width 0 means no wall.
+type Bump struct {
+	Height float64
+	Width  float64
+	X      float64
+	Panels int // Source panels along the bump, 0 for DefaultBumpPanels
+}
+
+// enabled reports whether b is a wall
+func (b *Bump) enabled() bool {
+	return b.Width != 0
+}
+
+// Validate checks the parameters of b
+func (b *Bump) Validate() error {
+	for _, v := range []struct {
+		name string
+		v    float64
+	}{{"height", b.Height}, {"width", b.Width}, {"x", b.X}} {
+		if math.IsNaN(v.v) || math.IsInf(v.v, 0) {
+			return Errorf(ErrBadArguments, "bump.%s must be finite, got %g", v.name, v.v)
+		}
+	}
+	if b.Height < 0 || b.Width < 0 {
+		return Errorf(ErrBadArguments, "bump.height and width must be non-negative, got %g and %g", b.Height, b.Width)
+	}
+	if n := b.Panels; n != 0 && (n < MinBumpPanels || n > MaxBumpPanels) {
+		return Errorf(ErrBadArguments, "bump.panels must be 0 (default %d) or between %d and %d, got %d", DefaultBumpPanels, MinBumpPanels, MaxBumpPanels, n)
+	}
+	if !b.enabled() && (b.Height != 0 || b.Panels != 0) {
+		return Errorf(ErrBadArguments, "a bump needs a positive width")
+	}
+	return nil
+}
+
+// validateBump checks the bump of f against the rest of the flow: a free
+// stream along the wall, and no object or other onset flow model
+func (f *Flow) validateBump() error {
+	b := &f.Bump
+	if err := b.Validate(); err != nil {
+		return err
+	}
+	if !b.enabled() {
+		return nil
+	}
+	if !f.aligned() {
+		return Errorf(ErrBadArguments, "the free stream must run along +x over the bump, got direction %v", f.Direction)
+	}
+	switch {
+	case f.Object.Radius != 0:
+		return Errorf(ErrUnsupported, "a bump has no object; set its radius to 0")
+	case !f.Profile.Uniform():
+		return Errorf(ErrUnsupported, "a bump has no free stream profile")
+	case f.Swirl.Number != 0:
+		return Errorf(ErrUnsupported, "a bump has no free stream swirl")
+	case f.Elements != nil:
+		return Errorf(ErrUnsupported, "a bump has no elementary flows")
+	case f.Boundary.image() != 0:
+		return Errorf(ErrUnsupported, "a bump has no %s boundary", f.Boundary.Mode)
+	case f.Channel.enabled():
+		return Errorf(ErrUnsupported, "a bump has no channel")
+	}
+	_, err := bumpPanels(b.Height/b.Width, b.panels())
+	return err
+}
+
+// panels returns the number of source panels along b
+func (b *Bump) panels() int {
+	if b.Panels != 0 {
+		return b.Panels
+	}
+	return DefaultBumpPanels
+}
+
+// height returns the height of the Gaussian of b at x
+func (b *Bump) height(x float64) float64 {
+	s := (x - b.X) / b.Width
+	return b.Height * math.Exp(-s*s/2)
+}
+
+// wall returns the height of the wall of b at x and its slope. Over the
+// paneled stretch it runs straight between the panel ends, as the panels
+// do, so the fluid the flow is solved for is exactly that above it.
+func (b *Bump) wall(x float64) (h, slope float64) {
+	if b.Height == 0 {
+		return 0, 0
+	}
+	n := b.panels()
+	l := 2 * bumpExtent * b.Width / float64(n)
+	x0 := b.X - bumpExtent*b.Width
+	i := math.Floor((x - x0) / l)
+	if i < 0 || i >= float64(n) {
+		return 0, 0
+	}
+	xa := x0 + i*l
+	ha, hb := b.height(xa), b.height(xa+l)
+	slope = (hb - ha) / l
+	return ha + slope*(x-xa), slope
+}
+
+// under reports whether (x, y) is below the wall of b
+func (b *Bump) under(x, y float64) bool {
+	if !b.enabled() {
+		return false
+	}
+	h, _ := b.wall(x)
+	return y < h
+}
+
+// Surface returns n points (x, y) of the Gaussian of b, over the paneled
+// stretch from X - 5·Width to X + 5·Width; with n one more than the
+// panels they are the panel ends
+func (b *Bump) Surface(n int) ([]float64, error) {
+	if !b.enabled() {
+		return nil, Errorf(ErrBadArguments, "no bump")
+	}
+	if n < 2 {
+		return nil, Errorf(ErrBadArguments, "a bump surface needs at least 2 points, got %d", n)
+	}
+	out := make([]float64, 0, 2*n)
+	for i := 0; i < n; i++ {
+		x := b.X + b.Width*bumpExtent*(2*float64(i)/float64(n-1)-1)
+		out = append(out, x, b.height(x))
+	}
+	return out, nil
+}
+
+// bumpPanel is a straight source panel of a bump, in widths from its
+// center, from a to b with the fluid on its left
+type bumpPanel struct {
+	a, c [2]float64 // Start and center, the collocation point
+	t, n [2]float64 // Unit tangent and normal, into the fluid
+	l    float64    // Length
+}
+
+// velocity returns the velocity at q of p and of its image in the wall,
+// for a unit source strength per length. At (ξ, η) along p from its start
+// and along its normal that of p is
+//
+//	u_ξ = ln(r_a²/r_b²)/4π,  u_η = (θ_b - θ_a)/2π
+//
+// with r and θ the distance and angle from either end, their squares
+// regularized over eps2. The image adds the same at q mirrored in y = 0,
+// with v mirrored back.
+func (p *bumpPanel) velocity(q [2]float64, eps2 float64) (vx, vy float64) {
+	for _, side := range []float64{1, -1} {
+		dx, dy := q[0]-p.a[0], side*q[1]-p.a[1]
+		xi, eta := dx*p.t[0]+dy*p.t[1], dx*p.n[0]+dy*p.n[1]
+		if math.Abs(eta) < bumpEdge*p.l {
+			// On the panel, as at its own center: the limit from the
+			// fluid, below the panel for the image
+			eta = math.Copysign(0, side)
+		}
+		ra2 := xi*xi + eta*eta + eps2
+		rb2 := (xi-p.l)*(xi-p.l) + eta*eta + eps2
+		ut := math.Log(ra2/rb2) / (4 * math.Pi)
+		un := (math.Atan2(eta, xi-p.l) - math.Atan2(eta, xi)) / (2 * math.Pi)
+		vx += ut*p.t[0] + un*p.n[0]
+		vy += side * (ut*p.t[1] + un*p.n[1])
+	}
+	return vx, vy
+}
+
+// bumpSolution is the panel solution of a bump of height ratio times its
+// width, in widths from its center, for a unit free stream
+type bumpSolution struct {
+	ratio  float64
+	n      int
+	panels []bumpPanel
+	sigma  []float64
+}
+
+// lastBump holds the last bump solution, since bumps differing only in
+// size, position and speed share it and a flow's kernel is prepared often
+var lastBump struct {
+	sync.Mutex
+	b *bumpSolution
+}
+
+// bumpPanels returns the panel solution of a bump of height ratio times
+// its width with n panels, evenly spaced along x
+func bumpPanels(ratio float64, n int) (*bumpSolution, error) {
+	lastBump.Lock()
+	defer lastBump.Unlock()
+	if b := lastBump.b; b != nil && b.ratio == ratio && b.n == n {
+		return b, nil
+	}
+	b := &bumpSolution{ratio: ratio, n: n, panels: make([]bumpPanel, n)}
+	node := func(i int) [2]float64 {
+		x := bumpExtent * (2*float64(i)/float64(n) - 1)
+		return [2]float64{x, ratio * math.Exp(-x*x/2)}
+	}
+	for i := range b.panels {
+		a, e := node(i), node(i+1)
+		p := &b.panels[i]
+		p.a, p.c = a, [2]float64{(a[0] + e[0]) / 2, (a[1] + e[1]) / 2}
+		p.l = math.Hypot(e[0]-a[0], e[1]-a[1])
+		p.t = [2]float64{(e[0] - a[0]) / p.l, (e[1] - a[1]) / p.l}
+		p.n = [2]float64{-p.t[1], p.t[0]}
+	}
+	m := make([][]float64, n)
+	b.sigma = make([]float64, n)
+	for i, p := range b.panels {
+		m[i] = make([]float64, n)
+		for j := range b.panels {
+			vx, vy := b.panels[j].velocity(p.c, bumpEdge*bumpEdge)
+			m[i][j] = vx*p.n[0] + vy*p.n[1]
+		}
+		b.sigma[i] = -p.n[0]
+	}
+	if !eliminate(m, [][]float64{b.sigma}) {
+		return nil, Errorf(ErrBadArguments, "bump: the panel system is singular")
+	}
+	lastBump.b = b
+	return b, nil
+}
+
+// bumpFrame prepares the panel solution of k's bump. A solution that
+// fails, which Validate reports, leaves none.
+func (k *kernel) bumpFrame(b Bump) {
+	if !b.enabled() || b.Height == 0 {
+		k.bump = b
+		return
+	}
+	s, err := bumpPanels(b.Height/b.Width, b.panels())
+	if err != nil {
+		return
+	}
+	k.bump, k.bumpSolution = b, s
+}
+
+// bumped adds the flow of the bump's panels to (vx, vy, vz), evaluated at
+// (px, py, pz); below the wall the velocity is zero
+func (k *kernel) bumped(px, py, pz, vx, vy, vz float64) (float64, float64, float64) {
+	if !k.bump.enabled() {
+		return vx, vy, vz
+	}
+	if k.excluded(px, py, pz) {
+		return 0, 0, 0
+	}
+	s := k.bumpSolution
+	if s == nil {
+		return vx, vy, vz
+	}
+	w := k.bump.Width
+	q := [2]float64{(px - k.bump.X) / w, py / w}
+	for j := range s.panels {
+		ux, uy := s.panels[j].velocity(q, bumpEdge*bumpEdge)
+		vx += k.u * s.sigma[j] * ux
+		vy += k.u * s.sigma[j] * uy
+	}
+	return vx, vy, vz
+}
+
+// reflectBump mirrors the particles of s that went below the wall back
+// across the straight piece of it they are under. Those still below,
+// having crossed where the wall bends, go onto the wall above them.
+func (s *Simulation) reflectBump(count int) {
+	b := &s.Config.Bump
+	if !b.enabled() {
+		return
+	}
+	for i := 0; i < count; i++ {
+		p := s.Positions[i*3 : i*3+2]
+		x, y := float64(p[0]), float64(p[1])
+		if !b.under(x, y) {
+			continue
+		}
+		h, slope := b.wall(x)
+		// Twice the depth below the wall, along its normal (-slope, 1)
+		d := 2 * (h - y) / (1 + slope*slope)
+		x, y = x-d*slope, y+d
+		if b.under(x, y) {
+			y, _ = b.wall(x)
+		}
+		p[0], p[1] = float32(x), float32(y)
+	}
+}
+
+// Encode returns b in the generic form accepted by DecodeConfig under
+// bump
+func (b Bump) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"height": b.Height,
+		"width":  b.Width,
+		"x":      b.X,
+		"panels": float64(b.Panels),
+	}
+}
+
+// DecodeBump decodes the bump section of a configuration,
+//
+//	{height: number = 0, width: number = 0, x: number = 0, panels: int = 0}
+func DecodeBump(v interface{}) (Bump, error) {
+	var b Bump
+	m, err := section(v, "bump", "height", "width", "x", "panels")
+	if err != nil {
+		return b, err
+	}
+	for _, k := range []struct {
+		key string
+		dst *float64
+	}{{"height", &b.Height}, {"width", &b.Width}, {"x", &b.X}} {
+		if err := numberKey(m, "bump", k.key, k.dst); err != nil {
+			return b, err
+		}
+	}
+	panels := 0.0
+	if err := numberKey(m, "bump", "panels", &panels); err != nil {
+		return b, err
+	}
+	if panels != math.Trunc(panels) || math.Abs(panels) > MaxBumpPanels {
+		return b, Errorf(ErrBadArguments, "bump.panels must be an integer up to %d, got %g", MaxBumpPanels, panels)
+	}
+	b.Panels = int(panels)
+	return b, b.Validate()
+}
+
+// SetBump replaces the wall and its bump, checking it against the current
+// flow
+func (s *Simulation) SetBump(b Bump) error {
+	f := s.Flow()
+	f.Bump = b
+	if err := f.validateBump(); err != nil {
+		return err
+	}
+	s.Config.Bump = b
+	return nil
+}
@@ -0,0 +1,102 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestBump(t *testing.T) {
+	run(t, (*checker).checkBump)
+}
+
+// checkBump verifies the wall with a Gaussian bump: the crest speed
+// U·(1 + √(2/π)·h/σ) of thin-bump theory for a low bump, flow along every
+// panel and the wall and the pressure lowest on the crest of a high one,
+// the free stream over a plain wall, none below it, particles kept above,
+// objects rejected, and the bump surviving a snapshot
+func (c *checker) checkBump() error {
+	bump := func(height float64) flow.Flow {
+		return flow.Flow{FreeStream: 1, Density: 1, Object: flow.ObjectSpec{Type: flow.Sphere},
+			Bump: flow.Bump{Height: height, Width: 2, X: 1}}
+	}
+	low := bump(0.02)
+	lx, ly, _ := low.VelocityAt(1, 0.02, 0)
+	crest := math.Abs(math.Hypot(lx, ly) - (1 + math.Sqrt(2/math.Pi)*0.01))
+
+	// Just above the center of every panel, the nodes being the surface
+	// points at the panel ends; the crest is the center of the middle one
+	f := bump(1)
+	nodes, err := f.Bump.Surface(flow.DefaultBumpPanels + 1)
+	if err != nil {
+		return err
+	}
+	normal, cpCrest, cpMin := 0.0, 0.0, math.Inf(1)
+	for i := 0; i < flow.DefaultBumpPanels; i++ {
+		a, b := nodes[2*i:2*i+2], nodes[2*i+2:2*i+4]
+		l := math.Hypot(b[0]-a[0], b[1]-a[1])
+		n := [2]float64{(a[1] - b[1]) / l, (b[0] - a[0]) / l}
+		vx, vy, _ := f.VelocityAt((a[0]+b[0])/2+1e-9*n[0], (a[1]+b[1])/2+1e-9*n[1], 0)
+		normal = math.Max(normal, math.Abs(vx*n[0]+vy*n[1]))
+		cp := 1 - vx*vx - vy*vy
+		if i == flow.DefaultBumpPanels/2 {
+			cpCrest = cp
+		}
+		cpMin = math.Min(cpMin, cp)
+	}
+	for _, x := range []float64{-20, -9.5, 11.5, 40} {
+		_, vy, _ := f.VelocityAt(x, 0, 0)
+		normal = math.Max(normal, math.Abs(vy))
+	}
+	plain := bump(0)
+	px, py, pz := plain.VelocityAt(0.3, 0.5, 0)
+	bx, by, bz := f.VelocityAt(1, 0.9, 0)
+	free := px == 1 && py == 0 && pz == 0
+	below := bx == 0 && by == 0 && bz == 0
+
+	// Particles ahead of the crest and low over the bump's lee, where the
+	// flow runs into and away from it
+	config := flow.DefaultConfig()
+	config.Object.Radius, config.Bump = 0, f.Bump
+	s := flow.NewSimulation(config)
+	const n = 40
+	start := make([]float32, 0, 3*n)
+	for i := 0; i < n; i++ {
+		x := -3 + 8*float64(i)/n
+		start = append(start, float32(x), float32(1.01*math.Exp(-(x-1)*(x-1)/8)), 0)
+	}
+	if err := s.SetParticles(start, n); err != nil {
+		return err
+	}
+	for i := 0; i < 20; i++ {
+		if err := s.Step(0.1); err != nil {
+			return err
+		}
+	}
+	kept := true
+	for i := 0; i < n; i++ {
+		vx, vy, _ := f.VelocityAt(float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), 0)
+		kept = kept && (vx != 0 || vy != 0)
+	}
+	snapshot, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	restored := flow.NewSimulation(flow.DefaultConfig())
+	if err := restored.UnmarshalBinary(snapshot); err != nil {
+		return err
+	}
+	kept = kept && restored.Config.Bump == f.Bump
+
+	object := f
+	object.Object.Radius = 1
+	e, rejected := object.Validate().(*flow.Error)
+	rejected = rejected && e.Code == flow.ErrUnsupported
+
+	ok := crest < bumpTolerance && normal < tangencyTolerance && cpCrest == cpMin && cpCrest < 0 &&
+		free && below && kept && rejected
+	c.report("bump", ok, "low crest |v| - thin-bump theory %.3g; |v·n|/U panels and wall %.3g; crest Cp %.4f, lowest %v; plain wall %v; zero below %v; particles kept %v; object rejected %v",
+		crest, normal, cpCrest, cpCrest == cpMin, free, below, kept, rejected)
+	return nil
+}
@@ -0,0 +1,100 @@
+package flow
+
+// Param describes a numeric configuration parameter. Min and Max are nil
+// when the range is unbounded on that side.
+type Param struct {
+	Name     string
+	Default  float64
+	Min, Max interface{}
+}
+
+// encode returns p in generic form
+func (p Param) encode() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    p.Name,
+		"default": p.Default,
+		"min":     p.Min,
+		"max":     p.Max,
+	}
+}
+
+// objectParams lists the parameters of the object section of a Config,
+// shared by every object type
+var objectParams = []Param{
+	{Name: "radius", Default: 1, Min: 0.0},
+}
+
+// collisionParam is the collision radius, which every object type but the
+// plate has
+var collisionParam = Param{Name: "collisionRadius", Default: 0, Min: 0.0}
+
+// typeParams lists the parameters of the object section that only apply
+// to some object types
+var typeParams = map[ObjectType][]Param{
+	Sphere:   {collisionParam},
+	Cylinder: {collisionParam, {Name: "splitter", Default: 0, Min: 0.0}},
+	Airfoil:  {collisionParam},
+	Plate: {
+		{Name: "alpha", Default: 0},
+		{Name: "edgeRadius", Default: 0, Min: 0.0},
+		{Name: "chord", Default: 2, Min: 0.0},
+	},
+	EllipticCylinder: {
+		{Name: "alpha", Default: 0},
+		{Name: "semiMinor", Default: 0, Min: 0.0},
+	},
+	Torus: {
+		{Name: "minorRadius", Default: 0, Min: 0.0},
+		{Name: "collocation", Default: DefaultTorusCollocation, Min: float64(MinTorusCollocation), Max: float64(MaxTorusCollocation)},
+	},
+	Box: {
+		{Name: "alpha", Default: 0},
+		{Name: "edgeRadius", Default: 0, Min: 0.0},
+		{Name: "panels", Default: DefaultBoxPanels, Min: float64(MinBoxPanels), Max: float64(MaxBoxPanels)},
+	},
+}
+
+// configParams lists the numeric parameters outside the object section
+var configParams = []Param{
+	{Name: "freeStream.speed", Default: 1},
+	{Name: "fluid.density", Default: 1, Min: 0.0},
+}
+
+// Capabilities describes the object types and configuration parameters
+// this build understands, in the generic form used by Config.Encode
+func Capabilities() map[string]interface{} {
+	types := make([]interface{}, 0, len(objectTypeNames))
+	for t := Sphere; t <= Box; t++ {
+		params := make([]interface{}, 0, len(objectParams)+len(typeParams[t]))
+		for _, p := range append(objectParams, typeParams[t]...) {
+			params = append(params, p.encode())
+		}
+		types = append(types, map[string]interface{}{
+			"name":   t.String(),
+			"id":     int(t),
+			"params": params,
+		})
+	}
+	params := make([]interface{}, len(configParams))
+	for i, p := range configParams {
+		params[i] = p.encode()
+	}
+	kinds := make([]interface{}, len(elementKinds))
+	for i, k := range elementKinds {
+		kinds[i] = k.name
+	}
+	return map[string]interface{}{
+		"objectTypes":      types,
+		"elementKinds":     kinds,
+		"params":           params,
+		"freeStreamModels": []interface{}{"uniform"},
+		"boundaryModes":    []interface{}{BoundaryNone, BoundaryWall, BoundaryFreeSurface},
+		"precisions":       []interface{}{"float32", "float64"},
+		"arithmetics":      []interface{}{ArithmeticAccurate, ArithmeticFast},
+		"objectStride":     ObjectStride,
+		"maxObjects":       1,
+		"maxElements":      MaxElements,
+		"maxSections":      MaxSections,
+		"vectorLayouts":    []interface{}{VectorsInterleaved, VectorsPlanar},
+	}
+}
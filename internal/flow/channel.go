@@ -0,0 +1,295 @@
+package flow
+
+import "math"
+
+// Channel is a converging-diverging channel, a Venturi, along x and
+// symmetric about the x axis. From Start its walls run straight at
+// InletHeight apart for InletLength, close in to ThroatHeight over
+// ConvergeLength, run straight for ThroatLength and open out again over
+// DivergeLength; outside that the inlet and outlet run on straight at
+// InletHeight. The walls bend like a half period of a cosine, so their
+// slope is continuous. Channels extend along z like cylinders.
+//
+// The flow inside is one-dimensional by continuity, U(x) = U·A_in/A(x)
+// with the free stream speed U at the inlet, plus the wall-normal
+// component U(x)·y·h'(x)/h(x) for the half-height h, which keeps it
+// divergence-free and along the walls. It speeds up through the throat,
+// where Bernoulli's equation with the inlet as reference gives the
+// suction. Outside the walls there is no fluid. An object in the channel
+// is solved for the onset speed at its center, and the channel flow's
+// difference from it added outside the object, as for a Profile: the
+// disturbance doesn't see the walls. InletHeight 0 means no channel.
+type Channel struct {
+	InletHeight    float64
+	ThroatHeight   float64
+	Start          float64
+	InletLength    float64
+	ConvergeLength float64
+	ThroatLength   float64
+	DivergeLength  float64
+}
+
+// enabled reports whether c is a channel
+func (c *Channel) enabled() bool {
+	return c.InletHeight != 0
+}
+
+// Validate checks the parameters of c
+func (c *Channel) Validate() error {
+	for _, v := range []struct {
+		name string
+		v    float64
+	}{
+		{"inletHeight", c.InletHeight}, {"throatHeight", c.ThroatHeight}, {"start", c.Start},
+		{"inletLength", c.InletLength}, {"convergeLength", c.ConvergeLength},
+		{"throatLength", c.ThroatLength}, {"divergeLength", c.DivergeLength},
+	} {
+		if math.IsNaN(v.v) || math.IsInf(v.v, 0) {
+			return Errorf(ErrBadArguments, "channel.%s must be finite, got %g", v.name, v.v)
+		}
+		if v.v < 0 && v.name != "start" {
+			return Errorf(ErrBadArguments, "channel.%s must be non-negative, got %g", v.name, v.v)
+		}
+	}
+	if !c.enabled() {
+		return nil
+	}
+	if c.ThroatHeight == 0 {
+		return Errorf(ErrBadArguments, "channel.throatHeight must be positive")
+	}
+	if c.ThroatHeight != c.InletHeight && (c.ConvergeLength == 0 || c.DivergeLength == 0) {
+		return Errorf(ErrBadArguments, "channel.convergeLength and divergeLength must be positive when the throat is narrower or wider than the inlet, got %g and %g", c.ConvergeLength, c.DivergeLength)
+	}
+	return nil
+}
+
+// validateChannel checks the channel of f against the rest of the flow:
+// a free stream along it, the object between its walls, and no other
+// onset flow model
+func (f *Flow) validateChannel() error {
+	c := &f.Channel
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	if !c.enabled() {
+		return nil
+	}
+	if !f.aligned() {
+		return Errorf(ErrBadArguments, "the free stream must run along +x through the channel, got direction %v", f.Direction)
+	}
+	switch {
+	case !f.Profile.Uniform():
+		return Errorf(ErrUnsupported, "a channel has no free stream profile")
+	case f.Swirl.Number != 0:
+		return Errorf(ErrUnsupported, "a channel has no free stream swirl")
+	case f.Elements != nil:
+		return Errorf(ErrUnsupported, "a channel has no elementary flows")
+	case f.Boundary.image() != 0:
+		return Errorf(ErrUnsupported, "a channel has no %s boundary", f.Boundary.Mode)
+	case f.Motion != [3]float64{}:
+		return Errorf(ErrUnsupported, "an object in a channel has no prescribed motion")
+	}
+	o := &f.Object
+	if o.Radius == 0 {
+		return nil
+	}
+	reach := o.Radius
+	if o.Type == Torus {
+		reach += o.minorRadius()
+	}
+	const samples = 64
+	for i := 0; i <= samples; i++ {
+		x := o.X - reach + 2*reach*float64(i)/samples
+		if h, _, _ := c.halfHeight(x); math.Abs(o.Y)+reach > h {
+			return Errorf(ErrBadArguments, "the object must lie between the channel walls, its %g radius at y = %g reaches past the half-height %g at x = %g", reach, o.Y, h, x)
+		}
+	}
+	return nil
+}
+
+// halfHeight returns the half-height of c at x and its first two
+// derivatives
+func (c *Channel) halfHeight(x float64) (h, dh, d2h float64) {
+	in, throat := c.InletHeight/2, c.ThroatHeight/2
+	x1 := c.Start + c.InletLength
+	x2 := x1 + c.ConvergeLength
+	x3 := x2 + c.ThroatLength
+	x4 := x3 + c.DivergeLength
+	// a + (b - a)·(1 - cos πs)/2 for s from 0 to 1 over length l
+	bend := func(a, b, s, l float64) (float64, float64, float64) {
+		sn, cs := math.Sincos(math.Pi * s)
+		k := math.Pi / l
+		return a + (b-a)*(1-cs)/2, (b - a) * k * sn / 2, (b - a) * k * k * cs / 2
+	}
+	switch {
+	case x < x1 || x >= x4:
+		return in, 0, 0
+	case x < x2:
+		return bend(in, throat, (x-x1)/c.ConvergeLength, c.ConvergeLength)
+	case x < x3:
+		return throat, 0, 0
+	}
+	return bend(throat, in, (x-x3)/c.DivergeLength, c.DivergeLength)
+}
+
+// speed returns the channel flow speed at x for the inlet speed u
+func (c *Channel) speed(u, x float64) float64 {
+	h, _, _ := c.halfHeight(x)
+	return u * c.InletHeight / (2 * h)
+}
+
+// velocity returns the channel flow at (x, y) for the inlet speed u
+func (c *Channel) velocity(u, x, y float64) (vx, vy float64) {
+	h, dh, _ := c.halfHeight(x)
+	vx = u * c.InletHeight / (2 * h)
+	return vx, vx * y * dh / h
+}
+
+// outside reports whether (x, y) is beyond the walls of c
+func (c *Channel) outside(x, y float64) bool {
+	if !c.enabled() {
+		return false
+	}
+	h, _, _ := c.halfHeight(x)
+	return math.Abs(y) > h
+}
+
+// wallDistance returns the signed distance of (x, y) from the nearer wall
+// of c, positive beyond it, the nearest point of the wall and the wall's
+// normal there, pointing out of the channel. Newton's method finds the
+// point (s, h(s)) where the offset is normal to the wall, starting from
+// the point of the wall at x.
+func (c *Channel) wallDistance(x, y float64) (d float64, q, n [2]float64) {
+	side := 1.0
+	if y < 0 {
+		side = -1
+	}
+	ay := math.Abs(y)
+	s := x
+	for i := 0; i < 8; i++ {
+		h, dh, d2h := c.halfHeight(s)
+		g := s - x + (h-ay)*dh
+		dg := 1 + dh*dh + (h-ay)*d2h
+		if dg <= 0 {
+			break
+		}
+		step := g / dg
+		s -= step
+		if math.Abs(step) < 1e-12 {
+			break
+		}
+	}
+	h, dh, _ := c.halfHeight(s)
+	l := math.Hypot(1, dh)
+	q = [2]float64{s, side * h}
+	n = [2]float64{-dh / l, side / l}
+	return (x-q[0])*n[0] + (y-q[1])*n[1], q, n
+}
+
+// Walls returns n points (x, y) of the upper wall of c, from the start of
+// its inlet to one inlet length past its diverging section; the lower wall
+// is its mirror image in the x axis
+func (c *Channel) Walls(n int) ([]float64, error) {
+	if !c.enabled() {
+		return nil, Errorf(ErrBadArguments, "no channel")
+	}
+	if n < 2 {
+		return nil, Errorf(ErrBadArguments, "a channel wall needs at least 2 points, got %d", n)
+	}
+	l := 2*c.InletLength + c.ConvergeLength + c.ThroatLength + c.DivergeLength
+	out := make([]float64, 0, 2*n)
+	for i := 0; i < n; i++ {
+		x := c.Start + l*float64(i)/float64(n-1)
+		h, _, _ := c.halfHeight(x)
+		out = append(out, x, h)
+	}
+	return out, nil
+}
+
+// channeled adds the channel flow's difference from the onset at the
+// object's center to (vx, vy, vz), evaluated at (px, py, pz), outside the
+// object; beyond the walls the velocity is zero
+func (k *kernel) channeled(px, py, pz, vx, vy, vz float64) (float64, float64, float64) {
+	if !k.channel.enabled() {
+		return vx, vy, vz
+	}
+	if k.excluded(px, py, pz) {
+		return 0, 0, 0
+	}
+	u, v := k.channel.velocity(k.cu, px, py)
+	return vx + u - k.u, vy + v, vz
+}
+
+// reflectChannel mirrors the particles of s that left the channel back
+// across the nearer wall. Those still outside, having crossed where the
+// wall bends or jumped past the far wall, go onto the wall.
+func (s *Simulation) reflectChannel(count int) {
+	c := &s.Config.Channel
+	if !c.enabled() {
+		return
+	}
+	for i := 0; i < count; i++ {
+		p := s.Positions[i*3 : i*3+2]
+		x, y := float64(p[0]), float64(p[1])
+		if !c.outside(x, y) {
+			continue
+		}
+		d, q, n := c.wallDistance(x, y)
+		x, y = x-2*d*n[0], y-2*d*n[1]
+		if c.outside(x, y) {
+			x, y = q[0], q[1]
+		}
+		p[0], p[1] = float32(x), float32(y)
+	}
+}
+
+// Encode returns c in the generic form accepted by DecodeConfig under
+// channel
+func (c Channel) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"inletHeight":    c.InletHeight,
+		"throatHeight":   c.ThroatHeight,
+		"start":          c.Start,
+		"inletLength":    c.InletLength,
+		"convergeLength": c.ConvergeLength,
+		"throatLength":   c.ThroatLength,
+		"divergeLength":  c.DivergeLength,
+	}
+}
+
+// DecodeChannel decodes the channel section of a configuration,
+//
+//	{inletHeight: number = 0, throatHeight: number = 0, start: number = 0,
+//	 inletLength: number = 0, convergeLength: number = 0,
+//	 throatLength: number = 0, divergeLength: number = 0}
+func DecodeChannel(v interface{}) (Channel, error) {
+	var c Channel
+	m, err := section(v, "channel", "inletHeight", "throatHeight", "start", "inletLength", "convergeLength", "throatLength", "divergeLength")
+	if err != nil {
+		return c, err
+	}
+	for _, k := range []struct {
+		key string
+		dst *float64
+	}{
+		{"inletHeight", &c.InletHeight}, {"throatHeight", &c.ThroatHeight}, {"start", &c.Start},
+		{"inletLength", &c.InletLength}, {"convergeLength", &c.ConvergeLength},
+		{"throatLength", &c.ThroatLength}, {"divergeLength", &c.DivergeLength},
+	} {
+		if err := numberKey(m, "channel", k.key, k.dst); err != nil {
+			return c, err
+		}
+	}
+	return c, c.Validate()
+}
+
+// SetChannel replaces the channel, checking it against the current flow
+func (s *Simulation) SetChannel(c Channel) error {
+	f := s.Flow()
+	f.Channel = c
+	if err := f.validateChannel(); err != nil {
+		return err
+	}
+	s.Config.Channel = c
+	return nil
+}
@@ -0,0 +1,90 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestChannel(t *testing.T) {
+	run(t, (*checker).checkChannel)
+}
+
+// checkChannel verifies a Venturi of throat half the inlet height: twice
+// the free stream and a Cp of -3 through the throat, flow along the walls
+// and without divergence, none beyond the walls, particles kept inside,
+// objects reaching past the walls rejected, and the channel surviving a
+// snapshot
+func (c *checker) checkChannel() error {
+	ch := flow.Channel{InletHeight: 4, ThroatHeight: 2, Start: -12, InletLength: 2,
+		ConvergeLength: 4, ThroatLength: 2, DivergeLength: 4}
+	f := flow.Flow{FreeStream: 1, Density: 1, Object: flow.ObjectSpec{Type: flow.Sphere}, Channel: ch}
+	tx, ty, _ := f.VelocityAt(-5, 0.5, 0)
+	throat := math.Abs(tx-2) + math.Abs(ty)
+	cp := 1 - tx*tx - ty*ty
+
+	walls, err := ch.Walls(16001)
+	if err != nil {
+		return err
+	}
+	along, div := 0.0, 0.0
+	for i := 80; i < len(walls)/2-1; i += 80 {
+		x, h := walls[2*i], walls[2*i+1]
+		slope := (walls[2*i+3] - walls[2*i-1]) / (walls[2*i+2] - walls[2*i-2])
+		vx, vy, _ := f.VelocityAt(x, h-1e-12, 0)
+		along = math.Max(along, math.Abs(vy-vx*slope)/math.Hypot(vx, vy))
+		for _, y := range []float64{0.1 * h, 0.6 * h} {
+			div = math.Max(div, math.Abs(f.Divergence(x, y, 0, 1e-5)))
+		}
+	}
+	bx, by, bz := f.VelocityAt(-5, 1.5, 0)
+	beyond := bx == 0 && by == 0 && bz == 0
+
+	// Particles close under the upper wall of the converging section,
+	// stepped with the default sphere in the inlet downstream of it, stay
+	// in the channel
+	config := flow.DefaultConfig()
+	config.Channel = ch
+	s := flow.NewSimulation(config)
+	const n = 40
+	start := make([]float32, 0, 3*n)
+	for i := 0; i < n; i++ {
+		x := -10 + 4*float64(i)/n
+		h := walls[2*int(math.Round((x-ch.Start)*16000/16))+1]
+		start = append(start, float32(x), float32(0.99*h), 0)
+	}
+	if err := s.SetParticles(start, n); err != nil {
+		return err
+	}
+	for i := 0; i < 20; i++ {
+		if err := s.Step(0.05); err != nil {
+			return err
+		}
+	}
+	kept := true
+	for i := 0; i < n; i++ {
+		vx, vy, _ := f.VelocityAt(float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), 0)
+		kept = kept && (vx != 0 || vy != 0)
+	}
+	snapshot, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	restored := flow.NewSimulation(flow.DefaultConfig())
+	if err := restored.UnmarshalBinary(snapshot); err != nil {
+		return err
+	}
+	kept = kept && restored.Config.Channel == ch
+
+	wide := checkFlow(flow.Sphere)
+	wide.Object.X, wide.Object.Y, wide.Channel = -5, 0.5, ch
+	s.SetObjectPosition(-5, 0.5, 0)
+	rejected := wide.Validate() != nil && s.Step(0.05) != nil
+
+	ok := throat < channelTolerance && math.Abs(cp+3) < channelTolerance && along < wallSlopeTolerance &&
+		div < divergenceTolerance && beyond && kept && rejected
+	c.report("channel", ok, "throat |v - 2U| %.3g, Cp %.6f; wall |v·n|/|v| %.3g; max |∇·v| %.3g; zero beyond %v; particles kept %v; wide object rejected %v",
+		throat, cp, along, div, beyond, kept, rejected)
+	return nil
+}
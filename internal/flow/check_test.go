@@ -0,0 +1,166 @@
+package flow_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+// Tolerances of the checks
+const (
+	tangencyTolerance   = 1e-6
+	decayTolerance      = 1e-2
+	cpTolerance         = 1e-6
+	divergenceTolerance = 1e-6
+	shaderTolerance     = 1e-9
+	trigTolerance       = 1e-12
+	frameTolerance      = 1e-12
+	profileTolerance    = 1e-6
+	swirlTolerance      = 1e-6
+	cutoffTolerance     = 1e-3
+	cutoffSlack         = 1e-3 // Relative; covers the cylinder's vz term over one radius of span
+	lodTolerance        = 1e-2
+	isentropicTolerance = 2e-3 // Relative to γM²/2·Cp at M = 0.01: the float32 ratios round at 1e-3
+	unitsTolerance      = 1e-6 // Relative; the CSV values are rounded to float32
+	strengthTolerance   = 1e-12
+	liftTolerance       = 1e-9 // Relative to ρUΓ
+	presetTolerance     = 1e-12
+	elementTolerance    = 1e-6 // Relative; covers the finite quadrature and segment length
+	airfoilTolerance    = 1e-12
+	zeroLiftTolerance   = 1e-3 // Degrees; the published α_L0 of the NACA 2412 is rounded
+	camberTolerance     = 1e-4 // Sampling the NACA camber line at 1001 points
+	sheetSpeedLimit     = 5    // Times the free stream, along the chord
+	plateTolerance      = 1e-9
+	tandemTolerance     = 1e-5 // Relative; the interference decays as chord/gap
+	kuttaTolerance      = 1e-4 // At 1e-9 from the edge; the speed approaches U·cos α as the root of the distance
+	imageTolerance      = 1e-4 // 30 radii below the plane, where the image adds about 1e-5
+	ellipseTolerance    = 1e-12
+	slenderTolerance    = 1e-4 // Relative; a semi-minor axis of 1e-6 chords against the plate
+	torusTolerance      = 2e-3 // Fit residual with the default collocation
+	torusFineTolerance  = 1e-6 // Tangency with 128 collocation points
+	boxCpTolerance      = 1e-3 // At 1e-9 upstream of the face center, with the default panels
+	boxTangencyRMS      = 0.1  // Constant panels leave |v·n| of the order of 1/panels between collocation points, more near the edges
+	boxTurnTolerance    = 1e-9
+	splitterTolerance   = 1e-12 // Along the stream, against the bare cylinder
+	channelTolerance    = 1e-12
+	wallSlopeTolerance  = 1e-6 // The wall slope by central differences over 1e-3
+	bumpTolerance       = 5e-4 // Crest speed against thin-bump theory at a height of 0.01 widths, with the default panels
+	rotorTolerance      = 0.03 // Relative; far-wake speed of the cored rings, 30 radii down a wake of 40
+	criterionTolerance  = 1e-6 // Relative; central differences over 1e-5
+	onSurfaceTolerance  = 1e-6 // Relative to the radius; the points are float32
+	dragTolerance       = 1e-3 // Relative; the midpoint quadrature at the default resolution
+	forceLiftTolerance  = 1e-8 // Relative; the surface quadrature samples 1e-9 radii outside the body
+	sweepTolerance      = 1e-12
+	derivativeTolerance = 1e-4 // Relative; central differences over 1e-5 of the parameter
+	fastTolerance       = 1e-5 // Relative to the free stream; float32 velocities, a few 1e-6 off near the surface
+	fastDivergence      = 1e-3 // Central differences of float32 velocities over fastStencil
+)
+
+// fastStencil is the step of the divergence check in the fast arithmetic
+const fastStencil = 1e-2
+
+// lodStep is the object displacement per step of the LOD check, in radii:
+// a body crossing its radius in 50 frames
+const lodStep = 0.02
+
+// workerParticles is the size of the worker check, large enough to be
+// split into several chunks
+const workerParticles = 20000
+
+// checker reports the checks of one test: each check is named, and logged
+// with its measurement whether it passes or not
+type checker struct {
+	t *testing.T
+
+	// arithmetic is that of the analytic property checks
+	arithmetic string
+}
+
+// newChecker returns a checker reporting to t
+func newChecker(t *testing.T) *checker {
+	return &checker{t: t}
+}
+
+// run runs check as the test t, failing it on an error
+func run(t *testing.T, check func(*checker) error) {
+	t.Helper()
+	if err := check(newChecker(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// forTypes runs check for each of types as a subtest of t
+func forTypes(t *testing.T, check func(*checker, flow.ObjectType) error, types ...flow.ObjectType) {
+	for _, ty := range types {
+		t.Run(ty.String(), func(t *testing.T) {
+			if err := check(newChecker(t), ty); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func (c *checker) report(name string, ok bool, format string, a ...interface{}) {
+	c.t.Helper()
+	if !ok {
+		c.t.Errorf("%s: %s", name, fmt.Sprintf(format, a...))
+		return
+	}
+	c.t.Logf("%s: %s", name, fmt.Sprintf(format, a...))
+}
+
+// note reports a check that is skipped or expected to be violated by the
+// current models, without failing the test
+func (c *checker) note(status, name string, format string, a ...interface{}) {
+	c.t.Helper()
+	c.t.Logf("%s %s: %s", status, name, fmt.Sprintf(format, a...))
+}
+
+// checkFlow returns the reference flow for an object type
+func checkFlow(t flow.ObjectType) flow.Flow {
+	return flow.Flow{FreeStream: 1, Density: 1, Object: flow.ObjectSpec{Type: t, Radius: 1}}
+}
+
+// flow returns the reference flow for an object type in the arithmetic of
+// the analytic property checks
+func (c *checker) flow(t flow.ObjectType) flow.Flow {
+	f := checkFlow(t)
+	f.Arithmetic = c.arithmetic
+	return f
+}
+
+// fast reports whether the analytic property checks run in the fast
+// arithmetic
+func (c *checker) fast() bool {
+	return c.arithmetic == flow.ArithmeticFast
+}
+
+// named returns the name of an analytic property check, marked in the
+// fast arithmetic
+func (c *checker) named(name string) string {
+	if c.fast() {
+		return name + " (fast)"
+	}
+	return name
+}
+
+// tolerance returns the tolerance of an analytic property check, at least
+// fastTolerance in the fast arithmetic
+func (c *checker) tolerance(t float64) float64 {
+	if c.fast() {
+		return math.Max(t, fastTolerance)
+	}
+	return t
+}
+
+// dot3 returns a · b
+func dot3(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// cross3 returns a × b
+func cross3(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
@@ -0,0 +1,282 @@
+package flow
+
+import "math"
+
+// Collision sets how particles meet the surface of the object. Disabled, a
+// particle stepping into a solid object stays there, where the velocity is
+// zero, and one crossing a plate or splitter plate goes back where it was.
+// Enabled, a particle whose step meets the surface stops on it and the
+// rest of its step goes on reflected: the part normal to the surface
+// reversed and scaled by Restitution, the tangential part scaled by 1 -
+// Friction. Restitution 0 and Friction 0 slide the particles along the
+// surface, and Restitution 1 bounces them specularly. The surface is
+// looked for along the whole step, from the position before it to the one
+// after, so fast particles can't pass through thin bodies. The particle's
+// velocity is reflected the same way, until the next step evaluates the
+// flow where it landed. Part of the configuration.
+type Collision struct {
+	Enabled     bool
+	Restitution float64
+	Friction    float64
+}
+
+// maxBounces bounds the reflections of a particle in one step; one still
+// meeting the surface after them stops where it last touched it
+const maxBounces = 4
+
+// collisionGap is the distance, relative to the object's radius plus the
+// coordinates, by which particles are put off the surface they hit: a
+// few float32 steps, so the rounded position is still outside
+const collisionGap = 1e-6
+
+// Validate checks the coefficients of c
+func (c *Collision) Validate() error {
+	for _, k := range []struct {
+		name string
+		x    float64
+	}{{"restitution", c.Restitution}, {"friction", c.Friction}} {
+		if !(k.x >= 0 && k.x <= 1) {
+			return Errorf(ErrBadArguments, "collision.%s must be between 0 and 1, got %g", k.name, k.x)
+		}
+	}
+	return nil
+}
+
+// Encode returns c in the generic form accepted by DecodeConfig under
+// collision
+func (c Collision) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"enabled":     c.Enabled,
+		"restitution": c.Restitution,
+		"friction":    c.Friction,
+	}
+}
+
+// DecodeCollision decodes the collision section of a configuration,
+//
+//	{enabled: bool = true, restitution: number = 0, friction: number = 0}
+//
+// Without the section collisions are disabled.
+func DecodeCollision(v interface{}) (Collision, error) {
+	c := Collision{Enabled: true}
+	m, err := section(v, "collision", "enabled", "restitution", "friction")
+	if err != nil {
+		return c, err
+	}
+	if v, ok := m["enabled"]; ok {
+		if c.Enabled, ok = v.(bool); !ok {
+			return c, Errorf(ErrBadArguments, "collision.enabled must be a boolean")
+		}
+	}
+	if err := numberKey(m, "collision", "restitution", &c.Restitution); err != nil {
+		return c, err
+	}
+	if err := numberKey(m, "collision", "friction", &c.Friction); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+// SetCollision replaces the collision response of s
+func (s *Simulation) SetCollision(c Collision) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	s.Config.Collision = c
+	return nil
+}
+
+// thickness returns the smallest half-thickness of a solid object, the
+// spacing its swept test samples a step at a quarter of
+func (o *ObjectSpec) thickness() float64 {
+	switch o.Type {
+	case EllipticCylinder:
+		return math.Min(o.Radius, o.semiMinor())
+	case Torus:
+		return o.minorRadius()
+	case Box:
+		h := o.halfSize()
+		return math.Min(h[0], math.Min(h[1], h[2]))
+	}
+	return o.bodyRadius()
+}
+
+// surfaceNormal returns the outward unit normal of the surface of a solid
+// object nearest to p, which lies close to it, or false where it has none
+func (o *ObjectSpec) surfaceNormal(p [3]float64) ([3]float64, bool) {
+	x, y, z := p[0]-o.X, p[1]-o.Y, p[2]-o.Z
+	var g [3]float64
+	switch o.Type {
+	case Cylinder, Airfoil:
+		g = [3]float64{x, y, 0}
+	case EllipticCylinder:
+		// The gradient of (s/a)² + (d/b)², as in section
+		t, n := o.chord()
+		a, b := o.Radius, o.semiMinor()
+		s, d := (x*t[0]+y*t[1])/(a*a), (x*n[0]+y*n[1])/(b*b)
+		g = [3]float64{s*t[0] + d*n[0], s*t[1] + d*n[1], 0}
+	case Torus:
+		// Away from the nearest point of the circle through the tube
+		rho := math.Sqrt(y*y + z*z)
+		if rho == 0 {
+			return g, false
+		}
+		g = [3]float64{x, y - o.Radius*y/rho, z - o.Radius*z/rho}
+	case Box:
+		// Along the axis of the face the point is nearest, relative to the
+		// half edges
+		q, h, axes := o.toBox(x, y, z), o.halfSize(), o.boxAxes()
+		a := 0
+		for i := 1; i < 3; i++ {
+			if math.Abs(q[i])/h[i] > math.Abs(q[a])/h[a] {
+				a = i
+			}
+		}
+		side := math.Copysign(1, q[a])
+		return [3]float64{side * axes[a][0], side * axes[a][1], side * axes[a][2]}, true
+	default:
+		g = [3]float64{x, y, z}
+	}
+	n, l := normalized(g)
+	return n, l > 0
+}
+
+// stripHit returns where the step from p0 to p1 crosses the strip of the
+// plane through the point (ox, oy) of unit normal n, extending along z,
+// between lo and hi along u: as for Crosses, steps starting in the plane
+// don't cross it. The normal returned faces p0.
+func stripHit(p0, p1 [3]float64, ox, oy float64, u, n [2]float64, lo, hi float64) (t float64, normal [3]float64, ok bool) {
+	x0, y0, x1, y1 := p0[0]-ox, p0[1]-oy, p1[0]-ox, p1[1]-oy
+	d0, d1 := x0*n[0]+y0*n[1], x1*n[0]+y1*n[1]
+	if d0 == 0 || d1 != 0 && (d0 > 0) == (d1 > 0) {
+		return 0, normal, false
+	}
+	t = d0 / (d0 - d1)
+	s0, s1 := x0*u[0]+y0*u[1], x1*u[0]+y1*u[1]
+	if s := s0 + (s1-s0)*t; s < lo || s > hi {
+		return 0, normal, false
+	}
+	side := math.Copysign(1, d0)
+	return t, [3]float64{side * n[0], side * n[1], 0}, true
+}
+
+// sweptHit returns where the step of a particle from p0 to p1 first meets
+// the surface of o, as a fraction t of the step, and the unit normal of
+// the surface there facing p0. A plate or splitter plate is met where the
+// step crosses it; a solid object where the step, sampled at a quarter of
+// its thickness, first goes inside, the surface then found by bisection.
+// Steps starting inside a solid object don't meet it.
+func (o *ObjectSpec) sweptHit(p0, p1 [3]float64) (t float64, n [3]float64, ok bool) {
+	if o.Radius == 0 {
+		return 0, n, false
+	}
+	t = math.Inf(1)
+	if o.Type == Plate {
+		u, normal := o.chord()
+		if ts, ns, hit := stripHit(p0, p1, o.X, o.Y, u, normal, -o.Radius, o.Radius); hit {
+			t, n, ok = ts, ns, true
+		}
+		return t, n, ok
+	}
+	if o.Splitter != 0 {
+		if ts, ns, hit := stripHit(p0, p1, o.X, o.Y, [2]float64{1, 0}, [2]float64{0, 1}, o.Radius, o.Radius+o.Splitter); hit {
+			t, n, ok = ts, ns, true
+		}
+	}
+	at := func(t float64) [3]float64 {
+		return [3]float64{p0[0] + (p1[0]-p0[0])*t, p0[1] + (p1[1]-p0[1])*t, p0[2] + (p1[2]-p0[2])*t}
+	}
+	inside := func(t float64) bool {
+		q := at(t)
+		return o.Contains(q[0], q[1], q[2])
+	}
+	if inside(0) {
+		return t, n, ok
+	}
+	_, length := normalized([3]float64{p1[0] - p0[0], p1[1] - p0[1], p1[2] - p0[2]})
+	samples := int(math.Min(math.Ceil(4*length/o.thickness()), 1024))
+	for k := 1; k <= samples; k++ {
+		lo, hi := float64(k-1)/float64(samples), float64(k)/float64(samples)
+		if lo >= t {
+			break
+		}
+		if !inside(hi) {
+			continue
+		}
+		for j := 0; j < 50 && hi-lo > 1e-12; j++ {
+			if mid := (lo + hi) / 2; inside(mid) {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		if lo >= t {
+			break
+		}
+		ns, has := o.surfaceNormal(at(lo))
+		if !has {
+			// Back along the step
+			ns, _ = normalized([3]float64{p0[0] - p1[0], p0[1] - p1[1], p0[2] - p1[2]})
+		}
+		return lo, ns, true
+	}
+	return t, n, ok
+}
+
+// collide moves the particles of s whose step from their positions in
+// from met the surface of the object off it, by Config.Collision, and
+// reflects their velocities. The object moves by shift over the step of
+// dt, as when dragged toward its Target: the steps are taken relative to
+// it, segment against moving body, so the particles it overruns end up
+// on its surface, pushed aside along it, and velocities reflect relative
+// to its own. With collisions disabled the dragged body pushes the
+// particles it meets aside as with Restitution 0 and Friction 0, leaving
+// their velocities to the next step.
+func (s *Simulation) collide(from []float32, count int, shift [3]float64, dt float64) {
+	o, c := &s.Config.Object, s.Config.Collision
+	if !c.Enabled {
+		c = Collision{}
+	}
+	var u [3]float64
+	for a := range u {
+		u[a] = shift[a] / dt
+	}
+	for i := 0; i < count; i++ {
+		p := s.Positions[i*3 : i*3+3]
+		p0 := [3]float64{float64(from[i*3]), float64(from[i*3+1]), float64(from[i*3+2])}
+		p1 := [3]float64{float64(p[0]) - shift[0], float64(p[1]) - shift[1], float64(p[2]) - shift[2]}
+		v := s.Velocities[i*3 : i*3+3]
+		moved := false
+		for bounce := 0; ; bounce++ {
+			t, n, ok := o.sweptHit(p0, p1)
+			if !ok {
+				break
+			}
+			if bounce == maxBounces {
+				p1 = p0
+				break
+			}
+			// The rest of the step, and the velocity, reflected
+			var hit, r [3]float64
+			for a := range hit {
+				hit[a] = p0[a] + (p1[a]-p0[a])*t
+				r[a] = p1[a] - hit[a]
+			}
+			rn := r[0]*n[0] + r[1]*n[1] + r[2]*n[2]
+			vn := (float64(v[0])-u[0])*n[0] + (float64(v[1])-u[1])*n[1] + (float64(v[2])-u[2])*n[2]
+			gap := collisionGap * (o.Radius + math.Max(math.Abs(hit[0]), math.Max(math.Abs(hit[1]), math.Abs(hit[2]))))
+			for a := range r {
+				p0[a] = hit[a] + gap*n[a]
+				p1[a] = p0[a] + (1-c.Friction)*(r[a]-rn*n[a]) - c.Restitution*rn*n[a]
+				if vn < 0 && c.Enabled {
+					w := float64(v[a]) - u[a]
+					v[a] = float32(u[a] + (1-c.Friction)*(w-vn*n[a]) - c.Restitution*vn*n[a])
+				}
+			}
+			moved = true
+		}
+		if moved {
+			p[0], p[1], p[2] = float32(p1[0]+shift[0]), float32(p1[1]+shift[1]), float32(p1[2]+shift[2])
+		}
+	}
+}
@@ -0,0 +1,529 @@
+package flow
+
+import (
+	"math"
+	"sort"
+)
+
+// Config is the structured form of a flow description, decoded from the
+// configuration objects accepted by the JavaScript API. The schema is
+//
+//	{
+//	  freeStream: {speed: number = 1, direction: [x, y, z] = [1, 0, 0],
+//	               profile: {type: "uniform"} | {type: "shear", rate, y0 = 0} |
+//	                        {type: "power", zref, alpha, floor = 0} |
+//	                        {type: "log", zref, z0, floor = 0},
+//	               shear: {rate: number = 0, y0: number = 0},
+//	               swirl: {type: "solid" | "free" = "solid", number: number = 0, radius: number = 1}},
+//	  fluid:      {density: number = 1},
+//	  object:     {type: name | number, position: [x, y, z] = [0, 0, 0], radius: number = 1,
+//	               strengths: {doublet, source, circulation} = {}, collisionRadius: number = 0,
+//	               alpha: degrees = 0, edgeRadius: number = 0, chord: number,
+//	               semiMinor: number = 0, semiAxes: [a, b], kutta: bool = false,
+//	               minorRadius: number = 0, collocation: number = 0,
+//	               size: [lx, ly, lz] = [0, 0, 0], panels: number = 0,
+//	               splitter: number = 0},
+//	  boundaries: {mode: "none" | "wall" | "freeSurface" = "none", height: number = 0,
+//	               clamp: bool = false},
+//	  channel:    {inletHeight: number = 0, throatHeight: number = 0, start: number = 0,
+//	               inletLength: number = 0, convergeLength: number = 0,
+//	               throatLength: number = 0, divergeLength: number = 0},
+//	  bump:       {height: number = 0, width: number = 0, x: number = 0, panels: int = 0},
+//	  rotor:      {radius: number = 0, inducedVelocity: number = 0, thrust: number = 0,
+//	               position: [x, y, z] = [0, 0, 0], ground: number = 0},
+//	  collision:  {enabled: bool = true, restitution: number = 0, friction: number = 0},
+//	  frame:      "body" | "lab" = "body",
+//	  precision:  "single" | "double" = "single",
+//	  arithmetic: "accurate" | "fast" = "accurate"
+//	}
+//
+// object and object.type are required; every other key has the default
+// shown. Unknown keys are rejected. direction is normalized and must not be
+// zero. shear is short for a shear profile, which Encode writes as
+// profile; the two are exclusive. Each strength given overrides its
+// radius-derived default (see Strengths), and a collisionRadius of 0
+// means radius. alpha and edgeRadius only apply to plates, whose radius is
+// half the chord; chord may be given instead of radius. alpha also turns
+// elliptic cylinders, whose radius and semiMinor are the semi-axes along
+// and normal to the chord, which semiAxes gives together instead, and
+// kutta gives them the circulation of the Kutta condition. A torus, about
+// the x axis, has the major radius radius and the tube radius minorRadius,
+// its flow being fitted at collocation points (see TorusFit). A box has the
+// edges size, turned by alpha about z, and the half diagonal radius; given
+// radius too, size only sets its proportions. Its faces are covered by
+// panels source panels along the longest edge, edgeRadius regularizing the
+// flow round its edges. A cylinder may carry a splitter plate of length
+// splitter behind it, along x, the flow leaving its tip smoothly. A wall or
+// free surface bounds the fluid at z = height above a sphere (see
+// Boundary), and a converging-diverging channel along x between y =
+// ±height/2 (see Channel), whose inletHeight 0 means none, or a wall
+// along y = 0 with a Gaussian bump (see Bump), whose width 0 means none.
+// A hovering rotor (see Rotor) blows its downwash through fluid at rest;
+// its radius 0 means none. collision, absent by default, bounces the
+// particles off the object (see Collision). precision "double" keeps the
+// particle positions of a Simulation in float64 (see Simulation.Positions),
+// and arithmetic "fast" evaluates the velocities in float32 where it can
+// (see ArithmeticFast).
+// New per-object
+// parameters are only added here, never to
+// the positional signatures.
+type Config struct {
+	FreeStream FreeStreamConfig
+	Density    float64
+	Object     ObjectSpec
+	Boundary   Boundary
+	Channel    Channel
+	Bump       Bump
+	Rotor      Rotor
+	Collision  Collision
+	Frame      string // FrameBody or FrameLab
+	Precision  string // PrecisionSingle or PrecisionDouble
+	Arithmetic string // ArithmeticAccurate or ArithmeticFast
+}
+
+// FreeStreamConfig is the freeStream section of a Config
+type FreeStreamConfig struct {
+	Speed     float64
+	Direction [3]float64
+	Profile   Profile
+	Swirl     Swirl
+}
+
+// DefaultConfig returns the configuration with every optional key at its
+// default, around a unit sphere at the origin
+func DefaultConfig() Config {
+	return Config{
+		FreeStream: FreeStreamConfig{Speed: 1, Direction: [3]float64{1, 0, 0}, Swirl: DefaultSwirl()},
+		Density:    1,
+		Object:     ObjectSpec{Type: Sphere, Radius: 1},
+		Boundary:   Boundary{Mode: BoundaryNone},
+		Frame:      FrameBody,
+		Precision:  PrecisionSingle,
+		Arithmetic: ArithmeticAccurate,
+	}
+}
+
+// Flow returns the flow parameters described by c
+func (c *Config) Flow() Flow {
+	return Flow{
+		FreeStream: c.FreeStream.Speed,
+		Direction:  c.FreeStream.Direction,
+		Profile:    c.FreeStream.Profile,
+		Swirl:      c.FreeStream.Swirl,
+		Density:    c.Density,
+		Object:     c.Object,
+		Boundary:   c.Boundary,
+		Channel:    c.Channel,
+		Bump:       c.Bump,
+		Rotor:      c.Rotor,
+		Frame:      c.Frame,
+		Arithmetic: c.Arithmetic,
+	}
+}
+
+// Encode returns c in the generic form accepted by DecodeConfig, with every
+// key filled in
+func (c *Config) Encode() map[string]interface{} {
+	d := c.FreeStream.Direction
+	o := c.Object
+	return map[string]interface{}{
+		"freeStream": map[string]interface{}{
+			"speed":     c.FreeStream.Speed,
+			"direction": []interface{}{d[0], d[1], d[2]},
+			"profile":   c.FreeStream.Profile.Encode(),
+			"swirl":     c.FreeStream.Swirl.Encode(),
+		},
+		"fluid": map[string]interface{}{
+			"density": c.Density,
+		},
+		"object": map[string]interface{}{
+			"type":            o.Type.String(),
+			"position":        []interface{}{o.X, o.Y, o.Z},
+			"radius":          o.Radius,
+			"strengths":       o.Strengths.Encode(),
+			"collisionRadius": o.CollisionRadius,
+			"alpha":           o.Alpha * 180 / math.Pi,
+			"edgeRadius":      o.EdgeRadius,
+			"semiMinor":       o.SemiMinor,
+			"kutta":           o.Kutta,
+			"minorRadius":     o.MinorRadius,
+			"collocation":     float64(o.Collocation),
+			"size":            []interface{}{o.Size[0], o.Size[1], o.Size[2]},
+			"panels":          float64(o.Panels),
+			"splitter":        o.Splitter,
+		},
+		"boundaries": c.Boundary.Encode(),
+		"channel":    c.Channel.Encode(),
+		"bump":       c.Bump.Encode(),
+		"rotor":      c.Rotor.Encode(),
+		"collision":  c.Collision.Encode(),
+		"frame":      c.Frame,
+		"precision":  c.Precision,
+		"arithmetic": c.Arithmetic,
+	}
+}
+
+// DecodeConfig decodes a configuration from generic values, as produced by
+// encoding/json or converted from JavaScript: maps with string keys,
+// []interface{}, float64 and string. Missing optional keys take their
+// defaults; errors name the offending key path.
+func DecodeConfig(v interface{}) (Config, error) {
+	c := DefaultConfig()
+	root, err := section(v, "config", "freeStream", "fluid", "object", "boundaries", "channel", "bump", "rotor", "collision", "frame", "precision", "arithmetic")
+	if err != nil {
+		return c, err
+	}
+
+	if v, ok := root["freeStream"]; ok {
+		fs, err := section(v, "freeStream", "speed", "direction", "profile", "shear", "swirl")
+		if err != nil {
+			return c, err
+		}
+		if err := numberKey(fs, "freeStream", "speed", &c.FreeStream.Speed); err != nil {
+			return c, err
+		}
+		if v, ok := fs["direction"]; ok {
+			d, err := vector(v, "freeStream.direction")
+			if err != nil {
+				return c, err
+			}
+			if c.FreeStream.Direction, err = unit(d, "freeStream.direction"); err != nil {
+				return c, err
+			}
+		}
+		if v, ok := fs["profile"]; ok {
+			if c.FreeStream.Profile, err = DecodeProfile(v); err != nil {
+				return c, err
+			}
+		}
+		if v, ok := fs["shear"]; ok {
+			if _, ok := fs["profile"]; ok {
+				return c, Errorf(ErrBadArguments, "freeStream: give either profile or shear, not both")
+			}
+			sh, err := section(v, "freeStream.shear", "rate", "y0")
+			if err != nil {
+				return c, err
+			}
+			p := Profile{Type: ProfileShear}
+			if err := numberKey(sh, "freeStream.shear", "rate", &p.Rate); err != nil {
+				return c, err
+			}
+			if err := numberKey(sh, "freeStream.shear", "y0", &p.Ref); err != nil {
+				return c, err
+			}
+			c.FreeStream.Profile = p
+		}
+		if v, ok := fs["swirl"]; ok {
+			if c.FreeStream.Swirl, err = DecodeSwirl(v); err != nil {
+				return c, err
+			}
+		}
+	}
+
+	if v, ok := root["fluid"]; ok {
+		fl, err := section(v, "fluid", "density")
+		if err != nil {
+			return c, err
+		}
+		if err := numberKey(fl, "fluid", "density", &c.Density); err != nil {
+			return c, err
+		}
+	}
+
+	if v, ok := root["boundaries"]; ok {
+		if c.Boundary, err = DecodeBoundary(v); err != nil {
+			return c, err
+		}
+	}
+
+	if v, ok := root["channel"]; ok {
+		if c.Channel, err = DecodeChannel(v); err != nil {
+			return c, err
+		}
+	}
+
+	if v, ok := root["bump"]; ok {
+		if c.Bump, err = DecodeBump(v); err != nil {
+			return c, err
+		}
+	}
+
+	if v, ok := root["rotor"]; ok {
+		if c.Rotor, err = DecodeRotor(v); err != nil {
+			return c, err
+		}
+	}
+
+	if v, ok := root["collision"]; ok {
+		if c.Collision, err = DecodeCollision(v); err != nil {
+			return c, err
+		}
+	}
+
+	if v, ok := root["frame"]; ok {
+		frame, ok := v.(string)
+		if !ok {
+			return c, Errorf(ErrBadArguments, "frame must be a string")
+		}
+		c.Frame = frame
+	}
+
+	if v, ok := root["precision"]; ok {
+		if c.Precision, _ = v.(string); c.Precision != PrecisionSingle && c.Precision != PrecisionDouble {
+			return c, Errorf(ErrBadArguments, "precision must be %q or %q", PrecisionSingle, PrecisionDouble)
+		}
+	}
+
+	if v, ok := root["arithmetic"]; ok {
+		if c.Arithmetic, _ = v.(string); c.Arithmetic != ArithmeticAccurate && c.Arithmetic != ArithmeticFast {
+			return c, Errorf(ErrBadArguments, "arithmetic must be %q or %q", ArithmeticAccurate, ArithmeticFast)
+		}
+	}
+
+	v, ok := root["object"]
+	if !ok {
+		return c, Errorf(ErrBadArguments, "config.object is required")
+	}
+	obj, err := section(v, "object", "type", "position", "radius", "strengths", "collisionRadius", "alpha", "edgeRadius", "chord", "semiMinor", "semiAxes", "kutta", "minorRadius", "collocation", "size", "panels", "splitter")
+	if err != nil {
+		return c, err
+	}
+	switch t := obj["type"].(type) {
+	case nil:
+		return c, Errorf(ErrBadArguments, "object.type is required")
+	case string:
+		if c.Object.Type, err = ParseObjectType(t); err != nil {
+			return c, Errorf(ErrBadArguments, "object.type: %v", err)
+		}
+	case float64:
+		if _, ok := objectTypeNames[ObjectType(t).String()]; !ok || t != math.Trunc(t) {
+			return c, Errorf(ErrBadArguments, "object.type: unknown object type %g", t)
+		}
+		c.Object.Type = ObjectType(t)
+	default:
+		return c, Errorf(ErrBadArguments, "object.type must be a name or a number")
+	}
+	if v, ok := obj["position"]; ok {
+		p, err := vector(v, "object.position")
+		if err != nil {
+			return c, err
+		}
+		c.Object.X, c.Object.Y, c.Object.Z = p[0], p[1], p[2]
+	}
+	if err := numberKey(obj, "object", "radius", &c.Object.Radius); err != nil {
+		return c, err
+	}
+	if err := numberKey(obj, "object", "collisionRadius", &c.Object.CollisionRadius); err != nil {
+		return c, err
+	}
+	if _, ok := obj["chord"]; ok {
+		if _, ok := obj["radius"]; ok {
+			return c, Errorf(ErrBadArguments, "object: radius and chord are exclusive")
+		}
+		if c.Object.Type != Plate {
+			return c, Errorf(ErrBadArguments, "object.chord only applies to plates")
+		}
+		chord := 0.0
+		if err := numberKey(obj, "object", "chord", &chord); err != nil {
+			return c, err
+		}
+		c.Object.Radius = chord / 2
+	}
+	if err := numberKey(obj, "object", "semiMinor", &c.Object.SemiMinor); err != nil {
+		return c, err
+	}
+	if v, ok := obj["semiAxes"]; ok {
+		_, radius := obj["radius"]
+		_, minor := obj["semiMinor"]
+		if radius || minor {
+			return c, Errorf(ErrBadArguments, "object: semiAxes is exclusive with radius and semiMinor")
+		}
+		if c.Object.Type != EllipticCylinder {
+			return c, Errorf(ErrBadArguments, "object.semiAxes only applies to elliptic cylinders")
+		}
+		a, ok := v.([]interface{})
+		if !ok || len(a) != 2 {
+			return c, Errorf(ErrBadArguments, "object.semiAxes must be an array of 2 numbers")
+		}
+		for i, p := range []*float64{&c.Object.Radius, &c.Object.SemiMinor} {
+			if *p, ok = a[i].(float64); !ok {
+				return c, Errorf(ErrBadArguments, "object.semiAxes[%d] must be a number", i)
+			}
+		}
+	}
+	if err := numberKey(obj, "object", "minorRadius", &c.Object.MinorRadius); err != nil {
+		return c, err
+	}
+	collocation := 0.0
+	if err := numberKey(obj, "object", "collocation", &collocation); err != nil {
+		return c, err
+	}
+	if collocation != math.Trunc(collocation) || math.Abs(collocation) > MaxTorusCollocation {
+		return c, Errorf(ErrBadArguments, "object.collocation must be an integer up to %d, got %g", MaxTorusCollocation, collocation)
+	}
+	c.Object.Collocation = int(collocation)
+	if v, ok := obj["size"]; ok {
+		if c.Object.Size, err = vector(v, "object.size"); err != nil {
+			return c, err
+		}
+		// Encode writes a zero size for every other type
+		if c.Object.Type != Box && c.Object.Size != [3]float64{} {
+			return c, Errorf(ErrBadArguments, "object.size only applies to boxes")
+		}
+		if _, ok := obj["radius"]; !ok && c.Object.Type == Box {
+			s := c.Object.Size
+			c.Object.Radius = math.Sqrt(s[0]*s[0]+s[1]*s[1]+s[2]*s[2]) / 2
+		}
+	}
+	panels := 0.0
+	if err := numberKey(obj, "object", "panels", &panels); err != nil {
+		return c, err
+	}
+	if panels != math.Trunc(panels) || math.Abs(panels) > MaxBoxPanels {
+		return c, Errorf(ErrBadArguments, "object.panels must be an integer up to %d, got %g", MaxBoxPanels, panels)
+	}
+	c.Object.Panels = int(panels)
+	if err := numberKey(obj, "object", "splitter", &c.Object.Splitter); err != nil {
+		return c, err
+	}
+	if v, ok := obj["kutta"]; ok {
+		if c.Object.Kutta, ok = v.(bool); !ok {
+			return c, Errorf(ErrBadArguments, "object.kutta must be a boolean")
+		}
+	}
+	alpha := 0.0
+	if err := numberKey(obj, "object", "alpha", &alpha); err != nil {
+		return c, err
+	}
+	c.Object.Alpha = alpha * math.Pi / 180
+	if err := numberKey(obj, "object", "edgeRadius", &c.Object.EdgeRadius); err != nil {
+		return c, err
+	}
+	if v, ok := obj["strengths"]; ok {
+		if c.Object.Strengths, err = decodeStrengths(v); err != nil {
+			return c, err
+		}
+	}
+
+	f := c.Flow()
+	return c, f.Validate()
+}
+
+// strengthKeys maps the keys of the object.strengths section to their bits
+// of Strengths.Set
+var strengthKeys = []struct {
+	key string
+	bit int
+}{{"doublet", StrengthDoublet}, {"source", StrengthSource}, {"circulation", StrengthCirculation}}
+
+// field returns the strength of bit
+func (st *Strengths) field(bit int) *float64 {
+	switch bit {
+	case StrengthDoublet:
+		return &st.Doublet
+	case StrengthSource:
+		return &st.Source
+	}
+	return &st.Circulation
+}
+
+// Encode returns the strengths set in st in the generic form accepted by
+// DecodeConfig under object.strengths
+func (st Strengths) Encode() map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, e := range strengthKeys {
+		if st.Set&e.bit != 0 {
+			m[e.key] = *st.field(e.bit)
+		}
+	}
+	return m
+}
+
+// decodeStrengths decodes the object.strengths section,
+//
+//	{doublet, source, circulation}
+//
+// where every key given overrides its default (see Strengths)
+func decodeStrengths(v interface{}) (Strengths, error) {
+	var st Strengths
+	m, err := section(v, "object.strengths", "doublet", "source", "circulation")
+	if err != nil {
+		return st, err
+	}
+	for _, e := range strengthKeys {
+		if _, ok := m[e.key]; ok {
+			if err := numberKey(m, "object.strengths", e.key, st.field(e.bit)); err != nil {
+				return st, err
+			}
+			st.Set |= e.bit
+		}
+	}
+	return st, nil
+}
+
+// section asserts that v is an object and that it has no keys besides the
+// allowed ones
+func section(v interface{}, path string, allowed ...string) (map[string]interface{}, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, Errorf(ErrBadArguments, "%s must be an object", path)
+	}
+	var unknown []string
+	for k := range m {
+		found := false
+		for _, a := range allowed {
+			if k == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, Errorf(ErrBadArguments, "%s: unknown key %q", path, unknown[0])
+	}
+	return m, nil
+}
+
+// numberKey stores m[key] in dst if present, leaving the default otherwise
+func numberKey(m map[string]interface{}, path, key string, dst *float64) error {
+	v, ok := m[key]
+	if !ok {
+		return nil
+	}
+	x, ok := v.(float64)
+	if !ok || math.IsNaN(x) {
+		return Errorf(ErrBadArguments, "%s.%s must be a number", path, key)
+	}
+	*dst = x
+	return nil
+}
+
+// unit normalizes a non-zero finite vector
+func unit(d [3]float64, path string) ([3]float64, error) {
+	n := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	if n == 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return d, Errorf(ErrBadArguments, "%s must be a non-zero finite vector", path)
+	}
+	return [3]float64{d[0] / n, d[1] / n, d[2] / n}, nil
+}
+
+// vector decodes a three-element array of numbers
+func vector(v interface{}, path string) ([3]float64, error) {
+	var p [3]float64
+	a, ok := v.([]interface{})
+	if !ok || len(a) != 3 {
+		return p, Errorf(ErrBadArguments, "%s must be an array of 3 numbers", path)
+	}
+	for i, e := range a {
+		x, ok := e.(float64)
+		if !ok || math.IsNaN(x) {
+			return p, Errorf(ErrBadArguments, "%s must be an array of 3 numbers", path)
+		}
+		p[i] = x
+	}
+	return p, nil
+}
@@ -0,0 +1,410 @@
+package flow
+
+import (
+	"math"
+	"sort"
+)
+
+// MaxCoreLines bounds the lines of CoreLines
+const MaxCoreLines = 256
+
+// DefaultCoreSteps is the default number of steps a core line takes each
+// way from its seed
+const DefaultCoreSteps = 256
+
+// coreFloor is the vorticity, relative to the largest velocity gradient
+// seen on the seeding grid, below which the default threshold of
+// CoreLines doesn't go, so that the roundoff of potential flow seeds no
+// line
+const coreFloor = 1e-3
+
+// coreCorrections is the number of corrector iterations of each step of a
+// core line, and of its seed
+const (
+	coreCorrections     = 3
+	coreSeedCorrections = 8
+)
+
+// circulationPoints is the number of points of the loop about each vertex
+// of a core line along which its circulation is integrated
+const circulationPoints = 32
+
+// CoreLineSpec sets how CoreLines looks for vortex cores. The zero values
+// of the optional fields select their defaults.
+type CoreLineSpec struct {
+	Grid         Grid    // Seeding grid, whose box bounds the lines
+	Step         float64 // Arc length of the steps; half the finest spacing of Grid
+	MaxSteps     int     // Steps each way from a seed; DefaultCoreSteps
+	MaxLines     int     // MaxCoreLines
+	MinVorticity float64 // |ω| ending a line, 1/s; a tenth of the largest on Grid
+	Radius       float64 // Of the circulation loops; the coarsest spacing of Grid
+}
+
+// Validate checks s and fills in its defaults
+func (s *CoreLineSpec) Validate() error {
+	if err := s.Grid.Validate(); err != nil {
+		return err
+	}
+	d := s.Grid.Spacing()
+	fine, coarse := math.Inf(1), 0.0
+	for _, x := range d {
+		if x > 0 {
+			fine, coarse = math.Min(fine, x), math.Max(coarse, x)
+		}
+	}
+	if s.Step == 0 && coarse > 0 {
+		s.Step = fine / 2
+	}
+	if s.Radius == 0 {
+		s.Radius = coarse
+	}
+	if !(s.Step > 0) || math.IsInf(s.Step, 0) {
+		return Errorf(ErrBadArguments, "core line step must be positive, got %g; give one for a grid of a single point", s.Step)
+	}
+	if !(s.Radius > 0) || math.IsInf(s.Radius, 0) {
+		return Errorf(ErrBadArguments, "core line radius must be positive, got %g; give one for a grid of a single point", s.Radius)
+	}
+	if s.MaxSteps == 0 {
+		s.MaxSteps = DefaultCoreSteps
+	}
+	if s.MaxSteps < 0 {
+		return Errorf(ErrBadArguments, "maxSteps must be non-negative, got %d", s.MaxSteps)
+	}
+	if s.MaxLines == 0 {
+		s.MaxLines = MaxCoreLines
+	}
+	if s.MaxLines < 0 || s.MaxLines > MaxCoreLines {
+		return Errorf(ErrBadArguments, "maxLines must be between 1 and %d, got %d", MaxCoreLines, s.MaxLines)
+	}
+	if !(s.MinVorticity >= 0) || math.IsInf(s.MinVorticity, 0) {
+		return Errorf(ErrBadArguments, "minVorticity must be non-negative and finite, got %g", s.MinVorticity)
+	}
+	return nil
+}
+
+// CoreLine is the core of a vortex traced by CoreLines, along the
+// vorticity, with the circulation about each of its points in m²/s. A
+// closed line, a vortex ring, ends with its first point.
+type CoreLine struct {
+	Points      Polyline
+	Circulation []float32
+	Closed      bool
+}
+
+// coreSample is the vorticity and λ2 at a point
+type coreSample struct {
+	w        [3]float64
+	wl, l2   float64 // |ω|, λ2
+	gradient float64 // |∇v|
+}
+
+// coreAt evaluates the vorticity of f and λ2 at p
+func (f *Flow) coreAt(p [3]float64) coreSample {
+	g := f.VelocityGradient(p[0], p[1], p[2])
+	var c coreSample
+	c.w = [3]float64{g[2][1] - g[1][2], g[0][2] - g[2][0], g[1][0] - g[0][1]}
+	_, c.wl = normalized(c.w)
+	c.l2 = vortexCriterion(FieldLambda2, g)
+	for i := range g {
+		for j := range g {
+			c.gradient += g[i][j] * g[i][j]
+		}
+	}
+	c.gradient = math.Sqrt(c.gradient)
+	return c
+}
+
+// CoreLines traces the core lines of the vortices of f, predictor-corrector
+// fashion. The seeds are the points of spec.Grid where |ω| is a local
+// maximum over their neighbours, at least MinVorticity, and λ2 negative,
+// strongest first. From each the line marches along the vorticity and
+// against it, a Heun step along ω̂ then corrector iterations moving the
+// point, within the plane normal to ω̂, to the minimum of λ2, the centre of
+// the core, by a parabola through λ2 a step apart on either side along
+// each axis of the plane. A direction ends after MaxSteps steps, where |ω|
+// drops below MinVorticity or λ2 turns non-negative, leaving the core,
+// outside the box of the grid, along its axes of more than one point, in
+// the object, or within a grid cell of a line traced before; a line coming
+// back within a step of its seed closes. Seeds within a cell of a line
+// are skipped, and at most MaxLines lines are traced. The circulation at
+// each point is ∮v·dl along the circle of Radius about it normal to the
+// line, right-handed about ω: for a Scully core of radius rc and
+// circulation Γ, Γ·R²/(R² + rc²).
+func (f *Flow) CoreLines(spec CoreLineSpec) ([]CoreLine, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+	g := spec.Grid
+	n := g.Len()
+	samples := make([]coreSample, n)
+	if _, err := parallel(n, func(from, to int) (int, error) {
+		for i := from; i < to; i++ {
+			x, y, z := g.Point(i%g.N[0], i/g.N[0]%g.N[1], i/(g.N[0]*g.N[1]))
+			samples[i] = f.coreAt([3]float64{x, y, z})
+		}
+		return 0, nil
+	}); err != nil {
+		return nil, err
+	}
+	threshold := spec.MinVorticity
+	if threshold == 0 {
+		strongest, steepest := 0.0, 0.0
+		for i := range samples {
+			strongest, steepest = math.Max(strongest, samples[i].wl), math.Max(steepest, samples[i].gradient)
+		}
+		threshold = math.Max(strongest/10, coreFloor*steepest)
+	}
+
+	// Local maxima of |ω|, ties going to the first point
+	var seeds []int
+	for i := range samples {
+		c := &samples[i]
+		if c.wl < threshold || !(c.l2 < 0) {
+			continue
+		}
+		ix, iy, iz := i%g.N[0], i/g.N[0]%g.N[1], i/(g.N[0]*g.N[1])
+		peak := true
+		for dz := -1; dz <= 1 && peak; dz++ {
+			for dy := -1; dy <= 1 && peak; dy++ {
+				for dx := -1; dx <= 1 && peak; dx++ {
+					jx, jy, jz := ix+dx, iy+dy, iz+dz
+					if jx < 0 || jy < 0 || jz < 0 || jx >= g.N[0] || jy >= g.N[1] || jz >= g.N[2] {
+						continue
+					}
+					j := (jz*g.N[1]+jy)*g.N[0] + jx
+					if w := samples[j].wl; w > c.wl || w == c.wl && j < i {
+						peak = false
+					}
+				}
+			}
+		}
+		if peak {
+			seeds = append(seeds, i)
+		}
+	}
+	sort.SliceStable(seeds, func(a, b int) bool { return samples[seeds[a]].wl > samples[seeds[b]].wl })
+
+	t := coreTracer{f: f, spec: &spec, threshold: threshold, owner: make([]int32, n)}
+	var lines []CoreLine
+	for _, i := range seeds {
+		if len(lines) == spec.MaxLines {
+			break
+		}
+		if t.owner[i] != 0 {
+			continue
+		}
+		x, y, z := g.Point(i%g.N[0], i/g.N[0]%g.N[1], i/(g.N[0]*g.N[1]))
+		if line, ok := t.trace([3]float64{x, y, z}, int32(len(lines)+1)); ok {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// coreTracer traces the lines of CoreLines, marking the grid points
+// within a cell of each with its number in owner
+type coreTracer struct {
+	f         *Flow
+	spec      *CoreLineSpec
+	threshold float64
+	owner     []int32
+}
+
+// direction returns the unit vorticity at p, and whether p is still in a
+// core
+func (t *coreTracer) direction(p [3]float64) ([3]float64, bool) {
+	c := t.f.coreAt(p)
+	w, _ := normalized(c.w)
+	return w, c.wl >= t.threshold && c.l2 < 0
+}
+
+// center moves p to the minimum of λ2 in the plane through it normal to
+// the unit vector w, in at most iterations parabola steps of at most a
+// step each along each axis
+func (t *coreTracer) center(p, w [3]float64, iterations int) [3]float64 {
+	_, u, v := normalAxes(w)
+	h := t.spec.Step
+	lambda2 := func(q [3]float64) float64 {
+		return vortexCriterion(FieldLambda2, t.f.VelocityGradient(q[0], q[1], q[2]))
+	}
+	for it := 0; it < iterations; it++ {
+		l0 := lambda2(p)
+		var shift [2]float64
+		for a, e := range [2][3]float64{u, v} {
+			lm := lambda2([3]float64{p[0] - h*e[0], p[1] - h*e[1], p[2] - h*e[2]})
+			lp := lambda2([3]float64{p[0] + h*e[0], p[1] + h*e[1], p[2] + h*e[2]})
+			if curvature := lm - 2*l0 + lp; curvature > 0 {
+				shift[a] = math.Max(-h, math.Min(h, h*(lm-lp)/(2*curvature)))
+			} else if lm < lp {
+				shift[a] = -h
+			} else if lp < lm {
+				shift[a] = h
+			}
+		}
+		for a := range p {
+			p[a] += shift[0]*u[a] + shift[1]*v[a]
+		}
+		if math.Abs(shift[0])+math.Abs(shift[1]) < 1e-6*h {
+			break
+		}
+	}
+	return p
+}
+
+// cell returns the index of the grid point nearest to p, or false outside
+// the box of the grid along its axes of more than one point
+func (t *coreTracer) cell(p [3]float64) (int, bool) {
+	g := &t.spec.Grid
+	d := g.Spacing()
+	var ix [3]int
+	for a := range p {
+		if g.N[a] == 1 {
+			continue
+		}
+		if p[a] < g.Min[a] || p[a] > g.Max[a] {
+			return 0, false
+		}
+		ix[a] = int(math.Round((p[a] - g.Min[a]) / d[a]))
+	}
+	return (ix[2]*g.N[1]+ix[1])*g.N[0] + ix[0], true
+}
+
+// claim marks the grid points within a cell of grid point i as owned by
+// line
+func (t *coreTracer) claim(i int, line int32) {
+	g := &t.spec.Grid
+	ix, iy, iz := i%g.N[0], i/g.N[0]%g.N[1], i/(g.N[0]*g.N[1])
+	for dz := -1; dz <= 1; dz++ {
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				jx, jy, jz := ix+dx, iy+dy, iz+dz
+				if jx < 0 || jy < 0 || jz < 0 || jx >= g.N[0] || jy >= g.N[1] || jz >= g.N[2] {
+					continue
+				}
+				if j := (jz*g.N[1]+jy)*g.N[0] + jx; t.owner[j] == 0 {
+					t.owner[j] = line
+				}
+			}
+		}
+	}
+}
+
+// march follows the core from p along the vorticity, or against it if
+// sign is negative, returning the points after p and whether it came back
+// to p
+func (t *coreTracer) march(p [3]float64, sign float64, line int32) (points [][3]float64, closed bool) {
+	h := t.spec.Step
+	seed := p
+	for step := 0; step < t.spec.MaxSteps; step++ {
+		w0, ok := t.direction(p)
+		if !ok {
+			break
+		}
+		var q [3]float64
+		for a := range q {
+			q[a] = p[a] + sign*h*w0[a]
+		}
+		w1, ok := t.direction(q)
+		if !ok {
+			break
+		}
+		w, _ := normalized([3]float64{w0[0] + w1[0], w0[1] + w1[1], w0[2] + w1[2]})
+		for a := range q {
+			q[a] = p[a] + sign*h*w[a]
+		}
+		q = t.center(q, w, coreCorrections)
+		if t.f.Object.Contains(q[0], q[1], q[2]) || t.f.Object.Crosses(p, q) {
+			break
+		}
+		i, inside := t.cell(q)
+		if !inside || t.owner[i] != 0 && t.owner[i] != line {
+			break
+		}
+		if step > 2 && dist3(q, seed) < h {
+			return points, true
+		}
+		t.claim(i, line)
+		points = append(points, q)
+		p = q
+	}
+	return points, false
+}
+
+// trace traces the line through the core at seed, numbered line, or
+// returns false if the seed isn't in a core
+func (t *coreTracer) trace(seed [3]float64, line int32) (CoreLine, bool) {
+	w, ok := t.direction(seed)
+	if !ok {
+		return CoreLine{}, false
+	}
+	p := t.center(seed, w, coreSeedCorrections)
+	if _, ok := t.direction(p); !ok {
+		return CoreLine{}, false
+	}
+	i, inside := t.cell(p)
+	if !inside || t.owner[i] != 0 {
+		return CoreLine{}, false
+	}
+	t.claim(i, line)
+	ahead, closed := t.march(p, 1, line)
+	var behind [][3]float64
+	if !closed {
+		behind, _ = t.march(p, -1, line)
+	}
+	points := make([][3]float64, 0, len(behind)+1+len(ahead)+1)
+	for j := len(behind) - 1; j >= 0; j-- {
+		points = append(points, behind[j])
+	}
+	points = append(points, p)
+	points = append(points, ahead...)
+	if closed {
+		points = append(points, p)
+	}
+	c := CoreLine{Points: make(Polyline, 0, len(points)*3), Circulation: make([]float32, len(points)), Closed: closed}
+	k := t.f.kernel()
+	for j, q := range points {
+		c.Points = append(c.Points, float32(q[0]), float32(q[1]), float32(q[2]))
+		// Along the line, or the vorticity where it has a single point
+		var d [3]float64
+		switch {
+		case len(points) == 1:
+			d, _ = t.direction(q)
+		case j == 0:
+			d = sub3(points[1], q)
+		case j == len(points)-1:
+			d = sub3(q, points[j-1])
+		default:
+			d = sub3(points[j+1], points[j-1])
+		}
+		c.Circulation[j] = float32(k.circulation(q, d, t.spec.Radius))
+	}
+	return c, true
+}
+
+// circulation returns ∮v·dl along the circle of radius r about p normal
+// to axis, right-handed about it
+func (k *kernel) circulation(p, axis [3]float64, r float64) float64 {
+	_, u, v := normalAxes(axis)
+	gamma := 0.0
+	for j := 0; j < circulationPoints; j++ {
+		s, c := math.Sincos(2 * math.Pi * (float64(j) + 0.5) / circulationPoints)
+		vx, vy, vz := k.velocityAt(p[0]+r*(c*u[0]+s*v[0]), p[1]+r*(c*u[1]+s*v[1]), p[2]+r*(c*u[2]+s*v[2]))
+		// Tangent -s·u + c·v, of length 2πr/circulationPoints
+		gamma += vx*(c*v[0]-s*u[0]) + vy*(c*v[1]-s*u[1]) + vz*(c*v[2]-s*u[2])
+	}
+	return gamma * 2 * math.Pi * r / circulationPoints
+}
+
+// sub3 returns a - b
+func sub3(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+// dist3 returns |a - b|
+func dist3(a, b [3]float64) float64 {
+	_, l := normalized(sub3(a, b))
+	return l
+}
@@ -0,0 +1,87 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestVortexCores(t *testing.T) {
+	run(t, (*checker).checkVortexCores)
+}
+
+// checkVortexCores traces the core of an oblique cored vortex in a
+// uniform stream along its axis, with the circulation of its Scully core
+// within the loops, and closes the core of a ring of vortex segments
+func (c *checker) checkVortexCores() error {
+	const gamma, rc = 1.3, 0.2
+	center, axis := [3]float64{0.1, -0.2, 0.1}, [3]float64{1.0 / 3, 2.0 / 3, 2.0 / 3}
+	sp, err := flow.NewSuperposition([]flow.Element{
+		{Kind: flow.ElementVortex, Position: center, Orientation: axis, Strength: gamma, CoreRadius: rc},
+		{Kind: flow.ElementUniform, Orientation: [3]float64{1, 0, 0}, Strength: 1}})
+	if err != nil {
+		return err
+	}
+	f := flow.Flow{Density: 1, Elements: sp}
+	spec := flow.CoreLineSpec{Grid: flow.Grid{Min: [3]float64{-1, -1, -1}, Max: [3]float64{1, 1, 1}, N: [3]int{9, 9, 9}}}
+	lines, err := f.CoreLines(spec)
+	if err != nil {
+		return err
+	}
+	off, circulation := math.Inf(1), math.Inf(1)
+	along := false
+	if len(lines) == 1 && lines[0].Points.Len() > 10 {
+		l := lines[0]
+		off, circulation = 0, 0
+		r := 0.25 // The coarsest spacing
+		for j := 0; j < l.Points.Len(); j++ {
+			var d [3]float64
+			for a := range d {
+				d[a] = float64(l.Points[j*3+a]) - center[a]
+			}
+			s := dot3(d, axis)
+			for a := range d {
+				d[a] -= s * axis[a]
+			}
+			off = math.Max(off, math.Sqrt(dot3(d, d)))
+			circulation = math.Max(circulation, math.Abs(float64(l.Circulation[j])/(gamma*r*r/(r*r+rc*rc))-1))
+		}
+		n := l.Points.Len() - 1
+		along = dot3([3]float64{float64(l.Points[n*3] - l.Points[0]), float64(l.Points[n*3+1] - l.Points[1]), float64(l.Points[n*3+2] - l.Points[2])}, axis) > 1.5
+	}
+	c.report("vortex core", off < 1e-3 && circulation < 1e-3 && along, "%d lines, off the axis by %.3g, circulation off Γ·R²/(R² + rc²) by %.3g, along the vorticity %v",
+		len(lines), off, circulation, along)
+
+	const segments = 64
+	var ring []flow.Element
+	for j := 0; j < segments; j++ {
+		s0, c0 := math.Sincos(2 * math.Pi * float64(j) / segments)
+		s1, c1 := math.Sincos(2 * math.Pi * float64(j+1) / segments)
+		ring = append(ring, flow.Element{Kind: flow.ElementVortexLine, Position: [3]float64{c0, s0, 0},
+			Orientation: [3]float64{c1 - c0, s1 - s0, 0}, Strength: 2, CoreRadius: 0.15})
+	}
+	if sp, err = flow.NewSuperposition(ring); err != nil {
+		return err
+	}
+	f.Elements = sp
+	spec = flow.CoreLineSpec{Grid: flow.Grid{Min: [3]float64{-1.5, -1.5, -0.5}, Max: [3]float64{1.5, 1.5, 0.5}, N: [3]int{13, 13, 5}}, MaxLines: 4}
+	if lines, err = f.CoreLines(spec); err != nil {
+		return err
+	}
+	off, positive := math.Inf(1), false
+	closed := len(lines) == 1 && lines[0].Closed
+	if closed {
+		l := lines[0]
+		off, positive = 0, true
+		for j := 0; j < l.Points.Len(); j++ {
+			x, y, z := float64(l.Points[j*3]), float64(l.Points[j*3+1]), float64(l.Points[j*3+2])
+			off = math.Max(off, math.Hypot(math.Hypot(x, y)-1, z))
+			positive = positive && l.Circulation[j] > 1 && l.Circulation[j] < 2
+		}
+		closed = l.Points[0] == l.Points[len(l.Points)-3] && l.Points[1] == l.Points[len(l.Points)-2]
+	}
+	c.report("vortex ring core", closed && off < 0.03 && positive, "%d lines, closed %v, off the ring by %.3g, circulation between 1 and Γ %v",
+		len(lines), closed, off, positive)
+	return nil
+}
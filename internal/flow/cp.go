@@ -0,0 +1,147 @@
+package flow
+
+import "math"
+
+// Bounds of the stations of a CpDistribution
+const (
+	DefaultCpPoints = 100
+	MaxCpPoints     = 4096
+)
+
+// CpDistribution is the pressure coefficient of an airfoil section on its
+// upper and lower surfaces at the same stations X along the chord, x/c
+// from the leading edge, with the section lift and quarter chord moment
+// coefficients integrated from them, the moment nose up positive. The
+// stations are cosine spaced, x/c = (1 - cos θ)/2 at the midpoints of n
+// equal steps in θ, so they bunch toward both edges without reaching
+// either, and the integrals are midpoint rules in θ. LeadingEdge reports a
+// singular Cp at the leading edge, which the stations next to it only
+// approach; the trailing edge of every section here is regular, held to
+// the Kutta condition or rounded.
+type CpDistribution struct {
+	X, Upper, Lower []float64
+	CL, CM          float64
+	LeadingEdge     bool
+}
+
+// newCpDistribution returns a distribution of n stations, with X and the
+// stations' θ filled in
+func newCpDistribution(n int) (CpDistribution, []float64, error) {
+	var d CpDistribution
+	if n < 1 || n > MaxCpPoints {
+		return d, nil, Errorf(ErrBadArguments, "nPoints must be between 1 and %d, got %d", MaxCpPoints, n)
+	}
+	d.X, d.Upper, d.Lower = make([]float64, n), make([]float64, n), make([]float64, n)
+	theta := make([]float64, n)
+	for i := range theta {
+		theta[i] = math.Pi * (float64(i) + 0.5) / float64(n)
+		d.X[i] = (1 - math.Cos(theta[i])) / 2
+	}
+	return d, theta, nil
+}
+
+// CpDistribution returns the Cp of the thin airfoil of s, -γ/U on the
+// upper surface and γ/U on the lower one to first order, γ the sheet
+// strength. Its lift and moment are those of the solution up to rounding
+// for n above Terms, the integrands being trigonometric polynomials. The
+// leading edge is singular unless the section is at its ideal angle of
+// attack, where A0 is 0; the trailing edge never is, by the Kutta
+// condition.
+func (s *ThinAirfoilSolution) CpDistribution(n int) (CpDistribution, error) {
+	d, theta, err := newCpDistribution(n)
+	if err != nil {
+		return d, err
+	}
+	h := math.Pi / float64(n)
+	for i, t := range theta {
+		st, ct := math.Sincos(t)
+		g := s.A[0] * (1 + ct) / st
+		for k := 1; k < len(s.A); k++ {
+			g += s.A[k] * math.Sin(float64(k)*t)
+		}
+		d.Upper[i], d.Lower[i] = -2*g, 2*g
+		// dx/c = sin θ/2·dθ
+		dl := 4 * g * st / 2 * h
+		d.CL += dl
+		d.CM -= dl * (d.X[i] - 0.25)
+	}
+	d.LeadingEdge = s.A[0] != 0
+	return d, nil
+}
+
+// CpDistribution returns the Cp on the section of f's plate or elliptic
+// cylinder, of chord 2·Radius along its major axis, from the velocity just
+// outside the surface, the upper surface being the suction side at
+// positive incidence. The lift and moment integrate the pressure over the
+// surface, normal and along the chord, relative to ½ρU²c with the onset
+// speed of the cross-flow. An elliptic cylinder has no singular edge, and
+// with Kutta set its lift converges on the Kutta-Joukowski value
+// 2π(1 + b/a)·sin α fast. A plate's leading edge is singular at any
+// incidence, and its suction, the force pulling the edge forward, acts on
+// the edge alone, out of reach of the stations: the lift comes out as the
+// normal force's share, 2π·sin α·cos²α, short of the exact 2π·sin α (see
+// PlateForces), and shorter with the regularized edge of a finite
+// EdgeRadius, which flattens the peak of Cp near the edge.
+func (f *Flow) CpDistribution(n int) (CpDistribution, error) {
+	d, theta, err := newCpDistribution(n)
+	if err != nil {
+		return d, err
+	}
+	if err := f.Validate(); err != nil {
+		return d, err
+	}
+	o := &f.Object
+	if f.Elements != nil {
+		return d, Errorf(ErrUnsupported, "a Cp distribution needs the object's own flow, not elements built over it")
+	}
+	if o.Type != Plate && o.Type != EllipticCylinder {
+		return d, Errorf(ErrUnsupported, "a Cp distribution needs a plate, an elliptic cylinder or a thin airfoil, not a %v", o.Type)
+	}
+	g := f.onset()
+	u := g.freeVelocity()
+	speed := math.Hypot(u[0], u[1])
+	if err := checkFreeStream(speed); err != nil {
+		return d, err
+	}
+	lift := [2]float64{-u[1] / speed, u[0] / speed}
+	t, nrm := o.chord()
+	a, b := o.Radius, 0.0
+	if o.Type == EllipticCylinder {
+		b = o.semiMinor()
+	}
+	h := math.Pi / float64(n)
+	u2 := f.onset2()
+	var force [2]float64
+	var moment float64
+	for i, th := range theta {
+		st, ct := math.Sincos(th)
+		for _, side := range []float64{1, -1} {
+			// The point at eccentric angle π ∓ θ, and the outward normal
+			// times the arc length per unit θ
+			s, e := -a*ct, side*b*st
+			ds := [2]float64{-b*ct*t[0] + side*a*st*nrm[0], -b*ct*t[1] + side*a*st*nrm[1]}
+			l := math.Hypot(ds[0], ds[1])
+			off := a * surfaceOffset / l
+			px := o.X + s*t[0] + e*nrm[0] + off*ds[0]
+			py := o.Y + s*t[1] + e*nrm[1] + off*ds[1]
+			vx, vy, vz := f.VelocityAt(px, py, o.Z)
+			cp := 1 - f.relative2(vx, vy, vz)/u2
+			if side > 0 {
+				d.Upper[i] = cp
+			} else {
+				d.Lower[i] = cp
+			}
+			fx, fy := -cp*ds[0]*h, -cp*ds[1]*h
+			force[0], force[1] = force[0]+fx, force[1]+fy
+			// About the quarter chord, at s = -a/2
+			rx, ry := (s+a/2)*t[0]+e*nrm[0], (s+a/2)*t[1]+e*nrm[1]
+			moment += rx*fy - ry*fx
+		}
+	}
+	c := 2 * a
+	d.CL = (force[0]*lift[0] + force[1]*lift[1]) / c
+	// Nose up is clockwise seen from +z with the stream along +x
+	d.CM = -moment / (c * c)
+	d.LeadingEdge = o.Type == Plate && math.Abs(t[0]*lift[0]+t[1]*lift[1]) > 0
+	return d, nil
+}
@@ -0,0 +1,66 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestCpDistribution(t *testing.T) {
+	run(t, (*checker).checkCpDistribution)
+}
+
+// checkCpDistribution verifies the chordwise Cp export: finite values at
+// every station, the NACA 2412's integrated cl and cm against its thin
+// airfoil solution, the elliptic cylinder's cl against Kutta-Joukowski,
+// and the sharp plate's against its normal force 2π·sin α·cos²α with no
+// quarter-chord moment, the leading edge suction being out of reach
+func (c *checker) checkCpDistribution() error {
+	alpha := 5 * math.Pi / 180
+	f := checkFlow(flow.Cylinder)
+	f.Object.Radius = 0
+	naca := flow.DefaultThinAirfoil()
+	naca.M, naca.P, naca.Alpha = 0.02, 0.4, alpha
+	s, err := naca.Solve(&f)
+	if err != nil {
+		return err
+	}
+	thin, err := s.CpDistribution(flow.DefaultCpPoints)
+	if err != nil {
+		return err
+	}
+
+	ellipse := checkFlow(flow.EllipticCylinder)
+	ellipse.Object.SemiMinor, ellipse.Object.Alpha, ellipse.Object.Kutta = 0.12, alpha, true
+	e, err := ellipse.CpDistribution(flow.DefaultCpPoints)
+	if err != nil {
+		return err
+	}
+	plate := checkFlow(flow.Plate)
+	plate.Object.Alpha, plate.Object.EdgeRadius = alpha, 1e-12
+	p, err := plate.CpDistribution(flow.DefaultCpPoints)
+	if err != nil {
+		return err
+	}
+
+	finite := true
+	for _, d := range []flow.CpDistribution{thin, e, p} {
+		for i := range d.X {
+			for _, v := range []float64{d.Upper[i], d.Lower[i]} {
+				finite = finite && !math.IsNaN(v) && !math.IsInf(v, 0)
+			}
+		}
+	}
+	sin, cos := math.Sincos(alpha)
+	thinErr := math.Max(math.Abs(thin.CL-s.CL), math.Abs(thin.CM-s.CMQuarter))
+	kj := 2 * math.Pi * (1 + 0.12) * sin
+	ellipseErr := math.Abs(e.CL-kj) / kj
+	normal := 2 * math.Pi * sin * cos * cos
+	plateErr := math.Max(math.Abs(p.CL-normal), math.Abs(p.CM))
+	edges := thin.LeadingEdge && p.LeadingEdge && !e.LeadingEdge
+	ok := finite && edges && thinErr < airfoilTolerance && ellipseErr < forceLiftTolerance && plateErr < plateTolerance
+	c.report("cp distribution", ok, "finite %v, singular edges %v; naca 2412 |Δ| %.3g; ellipse cl %.9f, want %.9f; plate |Δ| %.3g",
+		finite, edges, thinErr, e.CL, kj, plateErr)
+	return nil
+}
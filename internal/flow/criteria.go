@@ -0,0 +1,108 @@
+package flow
+
+import "math"
+
+// Vortex criterion fields, from the velocity gradient ∇v split into its
+// strain rate S and rotation Ω
+const (
+	FieldQ       = "q"       // Q = ½(|Ω|² - |S|²), positive where rotation dominates strain
+	FieldLambda2 = "lambda2" // Middle eigenvalue of S² + Ω², negative in vortex cores
+)
+
+// gradientStep is the central difference step of VelocityGradient, in
+// object radii, or in metres without an object
+const gradientStep = 1e-5
+
+// VelocityGradient returns ∇v at a point, g[i][j] = ∂v_i/∂x_j, by central
+// differences. Within a step of a wall or the surface of the object, where
+// the velocity drops to zero inside, the differences straddle it.
+func (f *Flow) VelocityGradient(x, y, z float64) (g [3][3]float64) {
+	h := gradientStep
+	if f.Object.Radius != 0 {
+		h *= f.Object.Radius
+	}
+	for j := 0; j < 3; j++ {
+		p, m := [3]float64{x, y, z}, [3]float64{x, y, z}
+		p[j] += h
+		m[j] -= h
+		ax, ay, az := f.VelocityAt(p[0], p[1], p[2])
+		bx, by, bz := f.VelocityAt(m[0], m[1], m[2])
+		g[0][j], g[1][j], g[2][j] = (ax-bx)/(2*h), (ay-by)/(2*h), (az-bz)/(2*h)
+	}
+	return g
+}
+
+// vortexCriterion evaluates FieldQ or FieldLambda2 from the velocity
+// gradient g
+func vortexCriterion(field string, g [3][3]float64) float64 {
+	var s, w [3][3]float64
+	for i := range g {
+		for j := range g {
+			s[i][j], w[i][j] = (g[i][j]+g[j][i])/2, (g[i][j]-g[j][i])/2
+		}
+	}
+	if field == FieldQ {
+		var q float64
+		for i := range g {
+			for j := range g {
+				q += w[i][j]*w[i][j] - s[i][j]*s[i][j]
+			}
+		}
+		return q / 2
+	}
+	// S² + Ω² is symmetric: Ω is antisymmetric, so Ω² is symmetric too
+	var a [3][3]float64
+	for i := range a {
+		for j := range a {
+			for k := range a {
+				a[i][j] += s[i][k]*s[k][j] + w[i][k]*w[k][j]
+			}
+		}
+	}
+	return symmetricEigenvalues(a)[1]
+}
+
+// symmetricEigenvalues returns the eigenvalues of the symmetric matrix a in
+// increasing order, from the trigonometric solution of its characteristic
+// polynomial
+func symmetricEigenvalues(a [3][3]float64) [3]float64 {
+	off := a[0][1]*a[0][1] + a[0][2]*a[0][2] + a[1][2]*a[1][2]
+	q := (a[0][0] + a[1][1] + a[2][2]) / 3
+	d0, d1, d2 := a[0][0]-q, a[1][1]-q, a[2][2]-q
+	p := math.Sqrt((d0*d0 + d1*d1 + d2*d2 + 2*off) / 6)
+	if p == 0 {
+		return [3]float64{q, q, q}
+	}
+	// det((a - qI)/p)/2, the cosine of three times the angle
+	r := (d0*(d1*d2-a[1][2]*a[1][2]) - a[0][1]*(a[0][1]*d2-a[1][2]*a[0][2]) + a[0][2]*(a[0][1]*a[1][2]-d1*a[0][2])) / (2 * p * p * p)
+	r = math.Max(-1, math.Min(1, r))
+	phi := math.Acos(r) / 3
+	hi, lo := q+2*p*math.Cos(phi), q+2*p*math.Cos(phi+2*math.Pi/3)
+	return [3]float64{lo, 3*q - hi - lo, hi}
+}
+
+// VortexCriterion evaluates FieldQ or FieldLambda2 at count positions. In
+// potential flow ∇v is symmetric, Ω is zero and S² has no negative
+// eigenvalue, so λ2 ≥ 0 and Q ≤ 0; vorticity brings λ2 below zero where
+// it dominates the strain, inside the core of a vortex. Values are SI,
+// per second squared.
+func VortexCriterion[T Float](positions []T, count int, f Flow, field string) ([]float32, error) {
+	if field != FieldQ && field != FieldLambda2 {
+		return nil, Errorf(ErrBadArguments, "unknown vortex criterion %q", field)
+	}
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	out := make([]float32, count)
+	_, err := parallel(count, func(from, to int) (int, error) {
+		for i := from; i < to; i++ {
+			x, y, z := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+			out[i] = float32(vortexCriterion(field, f.VelocityGradient(x, y, z)))
+		}
+		return 0, nil
+	})
+	return out, err
+}
@@ -0,0 +1,75 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestVortexCriterion(t *testing.T) {
+	run(t, (*checker).checkVortexCriterion)
+}
+
+// checkVortexCriterion verifies λ2 and Q: λ2 ≥ 0 and Q ≤ 0 around a
+// sphere in potential flow, and about an oblique cored vortex λ2 = -v·v'/r
+// and Q = v·v'/r, v(r) = Γ/2π·r/(r² + rc²) the swirl of its Scully core,
+// so that λ2 is negative inside the core radius only
+func (c *checker) checkVortexCriterion() error {
+	f := checkFlow(flow.Sphere)
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 2000, Seed: 173,
+		Grid: flow.Grid{Min: [3]float64{-3, -3, -3}, Max: [3]float64{3, 3, 3}}}
+	positions, _ := seed.Positions()
+	var outside []float32
+	for i := 0; i < seed.Count; i++ {
+		p := positions[i*3 : i*3+3]
+		if math.Sqrt(float64(p[0])*float64(p[0])+float64(p[1])*float64(p[1])+float64(p[2])*float64(p[2])) > 1.01 {
+			outside = append(outside, p...)
+		}
+	}
+	n := len(outside) / 3
+	lambda2, err := flow.VortexCriterion(outside, n, f, flow.FieldLambda2)
+	if err != nil {
+		return err
+	}
+	q, err := flow.VortexCriterion(outside, n, f, flow.FieldQ)
+	if err != nil {
+		return err
+	}
+	least, most := math.Inf(1), math.Inf(-1)
+	for i := range lambda2 {
+		least, most = math.Min(least, float64(lambda2[i])), math.Max(most, float64(q[i]))
+	}
+	c.report("potential lambda2", least > -criterionTolerance && most < criterionTolerance, "least λ2 %.3g, largest Q %.3g", least, most)
+
+	const gamma, rc = 1.3, 0.2
+	axis := [3]float64{1.0 / 3, 2.0 / 3, 2.0 / 3}
+	sp, err := flow.NewSuperposition([]flow.Element{{Kind: flow.ElementVortex, Position: [3]float64{0.5, -0.2, 0.1},
+		Orientation: axis, Strength: gamma, CoreRadius: rc}})
+	if err != nil {
+		return err
+	}
+	g := flow.Flow{Density: 1, Elements: sp}
+	worst := 0.0
+	core := true
+	for _, r := range []float64{0.05, 0.1, 0.19, 0.21, 0.4, 1} {
+		e := [3]float64{2 / math.Sqrt(5), -1 / math.Sqrt(5), 0}
+		p := []float64{0.5 + r*e[0] + 0.7*axis[0], -0.2 + r*e[1] + 0.7*axis[1], 0.1 + r*e[2] + 0.7*axis[2]}
+		l, err := flow.VortexCriterion(p, 1, g, flow.FieldLambda2)
+		if err != nil {
+			return err
+		}
+		qq, err := flow.VortexCriterion(p, 1, g, flow.FieldQ)
+		if err != nil {
+			return err
+		}
+		v := gamma / (2 * math.Pi) * r / (r*r + rc*rc)
+		dv := gamma / (2 * math.Pi) * (rc*rc - r*r) / ((r*r + rc*rc) * (r*r + rc*rc))
+		want := -v * dv / r
+		worst = math.Max(worst, math.Max(math.Abs(float64(l[0])/want-1), math.Abs(float64(qq[0])/want+1)))
+		core = core && (l[0] < 0) == (r < rc)
+	}
+	c.report("vortex lambda2", worst < criterionTolerance && core,
+		"worst relative error %.3g; negative in the core only %v", worst, core)
+	return nil
+}
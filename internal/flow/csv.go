@@ -0,0 +1,270 @@
+package flow
+
+import (
+	"math"
+	"strconv"
+)
+
+// CSV accumulates comma-separated rows in a single growing byte buffer, so
+// large exports don't allocate a string per value or per row
+type CSV struct {
+	Buf []byte
+
+	// Digits is the number of significant digits per value. A negative
+	// value writes the shortest string that reads back as the same float32.
+	Digits int
+
+	// factors holds the SI size of the unit of each column set by Columns,
+	// which Row divides the values by
+	factors []float64
+}
+
+// Column is a CSV column: its name and the dimension of its values
+type Column struct {
+	Name string
+	Dim  Dim
+}
+
+// Columns appends a header line labelling each column with its unit of u,
+// e.g. "x [mm]", and makes Row convert the values from SI to u
+func (c *CSV) Columns(u Units, cols ...Column) {
+	names := make([]string, len(cols))
+	c.factors = make([]float64, len(cols))
+	for i, col := range cols {
+		names[i], c.factors[i] = u.Label(col.Name, col.Dim), u.Factor(col.Dim)
+	}
+	c.Header(names...)
+}
+
+// Header appends a header line
+func (c *CSV) Header(cols ...string) {
+	for i, col := range cols {
+		if i > 0 {
+			c.Buf = append(c.Buf, ',')
+		}
+		c.Buf = append(c.Buf, col...)
+	}
+	c.Buf = append(c.Buf, '\n')
+}
+
+// Row appends the values as one line
+func (c *CSV) Row(vals ...float64) {
+	for i, v := range vals {
+		if i > 0 {
+			c.Buf = append(c.Buf, ',')
+		}
+		if i < len(c.factors) {
+			v /= c.factors[i]
+		}
+		if c.Digits < 0 {
+			c.Buf = strconv.AppendFloat(c.Buf, float64(float32(v)), 'g', -1, 32)
+		} else {
+			c.Buf = strconv.AppendFloat(c.Buf, v, 'g', c.Digits, 64)
+		}
+	}
+	c.Buf = append(c.Buf, '\n')
+}
+
+// CSV export kinds
+const (
+	CSVParticles = "particles" // Particle positions, velocities and pressures
+	CSVGrid      = "grid"      // A field sampled on a structured grid
+	CSVSurface   = "surface"   // Cp distribution over the object surface
+	CSVRake      = "rake"      // Samples along a probe line
+)
+
+// Grid fields of a CSV export
+const (
+	FieldAll      = "all" // Velocity and pressure
+	FieldVelocity = "velocity"
+	FieldCp       = "cp"
+)
+
+// CSVSpec describes a CSV export. Which fields apply depends on Kind.
+type CSVSpec struct {
+	Kind     string
+	Digits   int        // See CSV.Digits
+	Grid     Grid       // grid
+	Field    string     // grid: all, velocity, pressure, speed or cp
+	Samples  int        // surface, rake; s in rake output is the distance from From
+	From, To [3]float64 // rake
+	Units    Units      // Of the header labels and values; SI if zero
+}
+
+// DecodeCSVSpec decodes the options of a CSV export of the given kind:
+//
+//	all kinds: {precision: significant digits, default shortest float32}
+//	grid:      {min, max, resolution, field = "all"}
+//	surface:   {samples = 1000}
+//	rake:      {from, to, samples = 100}
+//
+// v may be nil when every option has a default. Lengths are in SI units;
+// see Units.Convert and CSVDims to take them in others.
+func DecodeCSVSpec(kind string, v interface{}) (CSVSpec, error) {
+	spec := CSVSpec{Kind: kind, Digits: -1, Field: FieldAll}
+	if v == nil {
+		v = map[string]interface{}{}
+	}
+	var keys []string
+	switch kind {
+	case CSVParticles:
+	case CSVGrid:
+		keys = []string{"min", "max", "resolution", "field"}
+	case CSVSurface:
+		keys = []string{"samples"}
+		spec.Samples = 1000
+	case CSVRake:
+		keys = []string{"from", "to", "samples"}
+		spec.Samples = 100
+	default:
+		return spec, Errorf(ErrBadArguments, "unknown CSV kind %q", kind)
+	}
+	m, err := section(v, kind, append(keys, "precision")...)
+	if err != nil {
+		return spec, err
+	}
+
+	digits, samples := -1.0, float64(spec.Samples)
+	if err := numberKey(m, kind, "precision", &digits); err != nil {
+		return spec, err
+	}
+	if err := numberKey(m, kind, "samples", &samples); err != nil {
+		return spec, err
+	}
+	spec.Digits, spec.Samples = int(digits), int(samples)
+	if _, ok := m["precision"]; ok && (spec.Digits < 1 || spec.Digits > 17) {
+		return spec, Errorf(ErrBadArguments, "%s.precision must be between 1 and 17", kind)
+	}
+	if spec.Samples < 1 && (kind == CSVSurface || kind == CSVRake) {
+		return spec, Errorf(ErrBadArguments, "%s.samples must be positive", kind)
+	}
+
+	for _, e := range []struct {
+		key string
+		dst *[3]float64
+	}{{"from", &spec.From}, {"to", &spec.To}} {
+		if v, ok := m[e.key]; ok {
+			if *e.dst, err = vector(v, kind+"."+e.key); err != nil {
+				return spec, err
+			}
+		}
+	}
+	if kind == CSVGrid {
+		if spec.Grid, err = gridKeys(m, "grid"); err != nil {
+			return spec, err
+		}
+		if f, ok := m["field"]; ok {
+			switch f {
+			case FieldAll, FieldVelocity, FieldPressure, FieldSpeed, FieldCp:
+				spec.Field = f.(string)
+			default:
+				return spec, Errorf(ErrBadArguments, "grid.field: unknown field %v", f)
+			}
+		}
+	}
+	return spec, nil
+}
+
+// CSVDims lists the dimensional keys of a CSV spec (see DecodeCSVSpec)
+var CSVDims = map[string]Dim{"min": DimLength, "max": DimLength, "from": DimLength, "to": DimLength}
+
+// ExportCSV writes the export described by spec for the state of s. The
+// header labels every dimensional column with its unit, and the values
+// are in the units of spec.
+func ExportCSV(spec CSVSpec, s *Simulation) ([]byte, error) {
+	c := CSV{Digits: spec.Digits}
+	u := spec.Units
+	if u == (Units{}) {
+		u = SIUnits()
+	}
+	f := s.Flow()
+	xyz := []Column{{"x", DimLength}, {"y", DimLength}, {"z", DimLength}}
+	vel := []Column{{"vx", DimVelocity}, {"vy", DimVelocity}, {"vz", DimVelocity}}
+	pressure := Column{"p", DimPressure}
+	switch spec.Kind {
+	case CSVParticles:
+		n := s.Count()
+		c.Buf = make([]byte, 0, n*64)
+		c.Columns(u, append(append(xyz, vel...), pressure)...)
+		p, v := s.Positions, s.Velocities
+		for i := 0; i < n; i++ {
+			c.Row(float64(p[i*3]), float64(p[i*3+1]), float64(p[i*3+2]),
+				float64(v[i*3]), float64(v[i*3+1]), float64(v[i*3+2]), float64(s.Pressures[i]))
+		}
+
+	case CSVGrid:
+		g := spec.Grid
+		if spec.Field == FieldCp {
+			if err := f.checkOnset(); err != nil {
+				return nil, err
+			}
+		}
+		c.Buf = make([]byte, 0, g.Len()*64)
+		cols := map[string][]Column{
+			FieldAll:      append(vel, pressure),
+			FieldVelocity: vel,
+			FieldPressure: {pressure},
+			FieldSpeed:    {{"speed", DimVelocity}},
+			FieldCp:       {{"cp", DimNone}},
+		}[spec.Field]
+		c.Columns(u, append(xyz, cols...)...)
+		u2 := f.onset2()
+		pRef := 0.5 * f.Density * u2
+		for k := 0; k < g.N[2]; k++ {
+			for j := 0; j < g.N[1]; j++ {
+				for i := 0; i < g.N[0]; i++ {
+					x, y, z := g.Point(i, j, k)
+					vx, vy, vz := f.VelocityAt(x, y, z)
+					v2, rel2 := vx*vx+vy*vy+vz*vz, f.relative2(vx, vy, vz)
+					p := pRef - 0.5*f.Density*rel2
+					switch spec.Field {
+					case FieldAll:
+						c.Row(x, y, z, vx, vy, vz, p)
+					case FieldVelocity:
+						c.Row(x, y, z, vx, vy, vz)
+					case FieldPressure:
+						c.Row(x, y, z, p)
+					case FieldSpeed:
+						c.Row(x, y, z, math.Sqrt(v2))
+					case FieldCp:
+						c.Row(x, y, z, 1-rel2/u2)
+					}
+				}
+			}
+		}
+
+	case CSVSurface:
+		if err := f.checkOnset(); err != nil {
+			return nil, err
+		}
+		c.Columns(u, append(xyz, Column{"nx", DimNone}, Column{"ny", DimNone}, Column{"nz", DimNone}, Column{"cp", DimNone})...)
+		for i := 0; i < spec.Samples; i++ {
+			p, n := f.Object.SurfacePoint(i, spec.Samples)
+			vx, vy, vz := f.VelocityAt(p[0], p[1], p[2])
+			cp := 1 - f.relative2(vx, vy, vz)/f.onset2()
+			c.Row(p[0], p[1], p[2], n[0], n[1], n[2], cp)
+		}
+
+	case CSVRake:
+		c.Columns(u, append(append(append([]Column{{"s", DimLength}}, xyz...), vel...), pressure)...)
+		pRef := 0.5 * f.Density * f.onset2()
+		d := [3]float64{spec.To[0] - spec.From[0], spec.To[1] - spec.From[1], spec.To[2] - spec.From[2]}
+		length := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+		for i := 0; i < spec.Samples; i++ {
+			t := 0.0
+			if spec.Samples > 1 {
+				t = float64(i) / float64(spec.Samples-1)
+			}
+			var q [3]float64
+			for a := range q {
+				q[a] = spec.From[a] + t*d[a]
+			}
+			vx, vy, vz := f.VelocityAt(q[0], q[1], q[2])
+			c.Row(t*length, q[0], q[1], q[2], vx, vy, vz, pRef-0.5*f.Density*f.relative2(vx, vy, vz))
+		}
+
+	default:
+		return nil, Errorf(ErrBadArguments, "unknown CSV kind %q", spec.Kind)
+	}
+	return c.Buf, nil
+}
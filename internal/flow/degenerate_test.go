@@ -0,0 +1,103 @@
+package flow_test
+
+import (
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+// TestDegenerate covers the degenerate inputs: negative radii and
+// densities are invalid, a zero radius, no object, has no surface to
+// integrate forces on, and the quantities normalized by U∞ refuse a zero
+// onset speed rather than divide by it. The velocities themselves stay
+// defined for both, a free stream and a fluid at rest.
+func TestDegenerate(t *testing.T) {
+	sphere := func(edit func(f *flow.Flow)) flow.Flow {
+		f := checkFlow(flow.Sphere)
+		edit(&f)
+		return f
+	}
+	noObject := sphere(func(f *flow.Flow) { f.Object.Radius = 0 })
+	atRest := sphere(func(f *flow.Flow) { f.FreeStream = 0 })
+	// The object moving with the stream: U∞ ≠ 0, but it meets no onset flow
+	carried := sphere(func(f *flow.Flow) { f.Motion = [3]float64{f.FreeStream, 0, 0} })
+	velocities := []float32{1, 0, 0, 0.5, 0.5, 0}
+	slice := flow.Slice{Plane: "xy", Min: [2]float64{-2, -2}, Max: [2]float64{2, 2}, Width: 4, Height: 4}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"negative radius", func() error {
+			f := sphere(func(f *flow.Flow) { f.Object.Radius = -1 })
+			return f.Validate()
+		}},
+		{"negative density", func() error {
+			f := sphere(func(f *flow.Flow) { f.Density = -1.2 })
+			return f.Validate()
+		}},
+		{"unknown object type", func() error {
+			f := sphere(func(f *flow.Flow) { f.Object.Type = flow.Box + 1 })
+			return f.Validate()
+		}},
+		{"zero radius drag", func() error {
+			_, err := noObject.DragReport(flow.MinForceResolution)
+			return err
+		}},
+		{"zero radius pressure force", func() error {
+			_, err := noObject.PressureForce(flow.MinForceResolution)
+			return err
+		}},
+		{"U∞ = 0 pressure coefficients", func() error {
+			cp, err := flow.PressureCoefficients(velocities, 2, 0)
+			if cp != nil {
+				t.Error("pressure coefficients computed for U∞ = 0")
+			}
+			return err
+		}},
+		{"U∞ = 0 isentropic ratios", func() error {
+			_, err := flow.IsentropicRatios(velocities, 2, 0, 0.3, 1.4)
+			return err
+		}},
+		{"U∞ = 0 tangency residual", func() error {
+			_, err := atRest.TangencyResidual(100)
+			return err
+		}},
+		{"U∞ = 0 drag", func() error {
+			_, err := atRest.DragReport(flow.MinForceResolution)
+			return err
+		}},
+		{"U∞ = 0 Cp texture", func() error {
+			cp, err := flow.SliceTextureFloat(atRest, slice, flow.FieldCp)
+			if cp != nil {
+				t.Error("Cp texture computed for U∞ = 0")
+			}
+			return err
+		}},
+		{"zero onset Cp texture", func() error {
+			cp, err := flow.SliceTextureFloat(carried, slice, flow.FieldCp)
+			if cp != nil {
+				t.Error("Cp texture computed for a zero onset speed")
+			}
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if e, ok := err.(*flow.Error); !ok || e.Code != flow.ErrBadArguments {
+				t.Errorf("got %v, want %s", err, flow.ErrBadArguments)
+			}
+		})
+	}
+
+	for _, f := range []flow.Flow{noObject, atRest} {
+		if err := f.Validate(); err != nil {
+			t.Errorf("radius %g, U∞ %g: %v", f.Object.Radius, f.FreeStream, err)
+		}
+		vx, vy, vz := f.VelocityAt(0.5, 0.2, 0.1)
+		if vx != f.FreeStream || vy != 0 || vz != 0 {
+			t.Errorf("radius %g, U∞ %g: velocity (%g, %g, %g), want the free stream", f.Object.Radius, f.FreeStream, vx, vy, vz)
+		}
+	}
+}
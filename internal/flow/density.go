@@ -0,0 +1,291 @@
+package flow
+
+import "math"
+
+// StreamDensity is the Random stream of the offsets of the particles
+// DensityControl splits off
+const StreamDensity = "density"
+
+// Limits of DensityControl
+const (
+	MaxDensityResolution = 256
+	MaxDensityCells      = 1 << 20
+)
+
+// DensityControl configures the particle density control of Simulation.Step.
+// Every Every steps the particles are counted on a grid of Resolution
+// cells spanning their bounding box. In a cell holding more than MaxPerCell
+// particles, pairs of them are merged, the second of each pair deleted and
+// the first standing for both, until MaxPerCell are left or half of them
+// merged; in a cell holding fewer than MinPerCell but at least one, its
+// particles are split, each new one placed at a random offset from its
+// parent of up to Jitter cells along each axis, outside the object and
+// walls, with the local flow velocity and pressure, until MinPerCell are
+// there. Cells are handled in order, x fastest. Unless Floating, merges
+// and splits are matched one for one and the rest dropped, keeping the
+// count; otherwise it stays within MinCount to MaxCount, 0 meaning no
+// upper bound. Survivors keep their order, and new particles follow them;
+// Simulation.TakeRemap relates them to the particles before.
+type DensityControl struct {
+	Every                  int
+	Resolution             [3]int
+	MinPerCell, MaxPerCell int
+	Floating               bool
+	MinCount, MaxCount     int
+	Jitter                 float64
+}
+
+// DefaultDensityControl is the density control used when it is enabled
+// without options
+var DefaultDensityControl = DensityControl{Every: 10, Resolution: [3]int{16, 16, 16}, MinPerCell: 2, MaxPerCell: 32, Jitter: 0.5}
+
+// Validate checks the parameters of d
+func (d *DensityControl) Validate() error {
+	if d.Every < 1 {
+		return Errorf(ErrBadArguments, "density: every must be positive, got %d", d.Every)
+	}
+	cells := 1
+	for _, n := range d.Resolution {
+		if n < 1 || n > MaxDensityResolution {
+			return Errorf(ErrBadArguments, "density: resolution must be between 1 and %d along each axis, got %v", MaxDensityResolution, d.Resolution)
+		}
+		cells *= n
+	}
+	if cells > MaxDensityCells {
+		return Errorf(ErrBadArguments, "density: %d cells are more than %d", cells, MaxDensityCells)
+	}
+	if d.MinPerCell < 0 || d.MaxPerCell < 1 || d.MinPerCell > d.MaxPerCell {
+		return Errorf(ErrBadArguments, "density: need 0 ≤ minPerCell ≤ maxPerCell and maxPerCell ≥ 1, got %d and %d", d.MinPerCell, d.MaxPerCell)
+	}
+	if d.MinCount < 0 || d.MaxCount < 0 || d.MaxCount != 0 && d.MaxCount < d.MinCount {
+		return Errorf(ErrBadArguments, "density: need 0 ≤ minCount ≤ maxCount, or maxCount 0, got %d and %d", d.MinCount, d.MaxCount)
+	}
+	if !(d.Jitter >= 0 && d.Jitter <= 1) {
+		return Errorf(ErrBadArguments, "density: jitter must be between 0 and 1, got %g", d.Jitter)
+	}
+	return nil
+}
+
+// Remap relates the particles after density control to those before:
+// Index[i] is the index before of particle i, or of its parent if it was
+// split off, and Weight[i] the number of particles before it stands for,
+// merges adding up, 1 for a particle split off. A renderer scales the
+// size of a particle by its weight and carries its other attributes over
+// through Index.
+type Remap struct {
+	Index  []int32
+	Weight []float32
+}
+
+// TakeRemap returns the remap of the density control passes since the
+// last call or since the particles were replaced, nil if there were none,
+// and clears it
+func (s *Simulation) TakeRemap() *Remap {
+	r := s.remap
+	s.remap = nil
+	return r
+}
+
+// merge is a pair of particles DensityControl merges
+type merge struct{ survivor, victim int32 }
+
+// controlDensity runs a density control pass if one is due after a step
+// that found the step counter at steps
+func (s *Simulation) controlDensity(steps int) error {
+	d := s.Density
+	if d == nil || s.Stats.Steps == steps || s.Stats.Steps%d.Every != 0 {
+		return nil
+	}
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	n := s.Count()
+	if n == 0 {
+		return nil
+	}
+
+	// Bin the particles, the non-finite ones in none
+	lo, hi := [3]float64{math.Inf(1), math.Inf(1), math.Inf(1)}, [3]float64{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for i := 0; i < n*3; i += 3 {
+		p := s.Positions[i : i+3]
+		if p[0]-p[0] != 0 || p[1]-p[1] != 0 || p[2]-p[2] != 0 {
+			continue
+		}
+		for a := range lo {
+			lo[a], hi[a] = math.Min(lo[a], float64(p[a])), math.Max(hi[a], float64(p[a]))
+		}
+	}
+	if lo[0] > hi[0] {
+		return nil
+	}
+	var size [3]float64
+	cells := 1
+	for a, r := range d.Resolution {
+		size[a] = (hi[a] - lo[a]) / float64(r)
+		cells *= r
+	}
+	cell := make([]int32, n)
+	start := make([]int32, cells+1)
+	for i := range cell {
+		p := s.Positions[i*3 : i*3+3]
+		if p[0]-p[0] != 0 || p[1]-p[1] != 0 || p[2]-p[2] != 0 {
+			cell[i] = -1
+			continue
+		}
+		c := 0
+		for a := 2; a >= 0; a-- {
+			k := 0
+			if size[a] > 0 {
+				k = min(int((float64(p[a])-lo[a])/size[a]), d.Resolution[a]-1)
+			}
+			c = c*d.Resolution[a] + k
+		}
+		cell[i] = int32(c)
+		start[c+1]++
+	}
+	for c := 0; c < cells; c++ {
+		start[c+1] += start[c]
+	}
+	members := make([]int32, start[cells])
+	next := append([]int32(nil), start[:cells]...)
+	for i, c := range cell {
+		if c >= 0 {
+			members[next[c]] = int32(i)
+			next[c]++
+		}
+	}
+
+	var merges []merge
+	var parents []int32
+	for c := 0; c < cells; c++ {
+		in := members[start[c]:start[c+1]]
+		m := len(in)
+		if m > d.MaxPerCell {
+			for j := 0; j < min(m-d.MaxPerCell, m/2); j++ {
+				merges = append(merges, merge{in[2*j], in[2*j+1]})
+			}
+		} else if m > 0 && m < d.MinPerCell {
+			for j := 0; j < d.MinPerCell-m; j++ {
+				parents = append(parents, in[j%m])
+			}
+		}
+	}
+
+	// Place the new particles, dropping those landing outside the fluid
+	f := s.Flow()
+	f.Cutoff = s.Cutoff
+	k := f.kernel()
+	stream := s.Random.Stream(StreamDensity)
+	base := s.precisePositions()
+	var children []float64
+	split := parents[:0]
+	for _, p := range parents {
+		var q [3]float64
+		for a := range q {
+			q[a] = base[int(p)*3+a] + d.Jitter*size[a]*(2*stream.Float64()-1)
+		}
+		if !k.excluded(q[0], q[1], q[2]) {
+			children = append(children, q[:]...)
+			split = append(split, p)
+		}
+	}
+	if d.Floating {
+		merges = merges[:min(len(merges), max(n-d.MinCount, 0))]
+		if d.MaxCount > 0 {
+			split = split[:min(len(split), max(d.MaxCount-n+len(merges), 0))]
+		}
+	} else {
+		m := min(len(merges), len(split))
+		merges, split = merges[:m], split[:m]
+	}
+	children = children[:len(split)*3]
+	if len(merges) == 0 && len(split) == 0 {
+		return nil
+	}
+
+	velocities := make([]float32, len(children))
+	pressures := make([]float32, len(split))
+	nc := len(split)
+	if err := VelocitiesInto(velocities, children, nc, f); err != nil {
+		return err
+	}
+	if err := PressuresFor(pressures, velocities, nc, &f, Mask{}); err != nil {
+		return err
+	}
+	if err := AddSwirlPressures(pressures, children, nc, &f, Mask{}); err != nil {
+		return err
+	}
+
+	// Compact the survivors and append the new particles
+	partner := make([]int32, n)
+	for i := range partner {
+		partner[i] = -1
+	}
+	for _, m := range merges {
+		partner[m.survivor], partner[m.victim] = m.victim, -2
+	}
+	count := n - len(merges) + nc
+	r := &Remap{Index: make([]int32, 0, count), Weight: make([]float32, 0, count)}
+	precise := len(s.precise) == n*3
+	s.syncSources()
+	sources := make([]uint8, len(split))
+	for i, p := range split {
+		sources[i] = s.Sources[p]
+	}
+	w := 0
+	for i := 0; i < n; i++ {
+		if partner[i] == -2 {
+			continue
+		}
+		copy(s.Positions[w*3:w*3+3], s.Positions[i*3:i*3+3])
+		copy(s.Velocities[w*3:w*3+3], s.Velocities[i*3:i*3+3])
+		s.Pressures[w] = s.Pressures[i]
+		s.Sources[w] = s.Sources[i]
+		if precise {
+			copy(s.precise[w*3:w*3+3], s.precise[i*3:i*3+3])
+		}
+		weight := float32(1)
+		if partner[i] >= 0 {
+			weight = 2
+		}
+		r.Index, r.Weight = append(r.Index, int32(i)), append(r.Weight, weight)
+		w++
+	}
+	s.Positions, s.Velocities, s.Pressures = s.Positions[:w*3], s.Velocities[:w*3], s.Pressures[:w]
+	for i, x := range children {
+		s.Positions = append(s.Positions, float32(x))
+		s.Velocities = append(s.Velocities, velocities[i])
+	}
+	s.Pressures = append(s.Pressures, pressures...)
+	s.Sources = append(s.Sources[:w], sources...)
+	if precise {
+		s.precise = append(s.precise[:w*3], children...)
+	} else {
+		s.precise = s.precise[:0]
+	}
+	for _, p := range split {
+		r.Index, r.Weight = append(r.Index, p), append(r.Weight, 1)
+	}
+
+	// Compose with the remap not taken yet
+	if prev := s.remap; prev != nil {
+		for i, j := range r.Index {
+			if i < w && partner[j] >= 0 {
+				r.Weight[i] = prev.Weight[j] + prev.Weight[partner[j]]
+			} else if i < w {
+				r.Weight[i] = prev.Weight[j]
+			}
+			r.Index[i] = prev.Index[j]
+		}
+	}
+	s.remap = r
+	s.Stats.Merged += len(merges)
+	s.Stats.Split += nc
+	s.lod.invalidate()
+	if s.Cursor >= count {
+		s.Cursor = 0
+	}
+	LogValue(LogDebug, "density", "particles merged", float64(len(merges)))
+	LogValue(LogDebug, "density", "particles split", float64(nc))
+	return nil
+}
@@ -0,0 +1,149 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestDensityControl(t *testing.T) {
+	run(t, (*checker).checkDensityControl)
+}
+
+func TestDensityEstimate(t *testing.T) {
+	run(t, (*checker).checkDensityEstimate)
+}
+
+// checkDensityControl steps a dense cluster and a sparse line of
+// particles once with density control and once without: with a fixed
+// count, the survivors must be the particles their remap names, in order,
+// standing together for every particle before, and the particles split
+// off must sit near their parent with the flow velocity there; with a
+// floating count and merging alone, two passes compose into one remap of
+// the original particles.
+func (c *checker) checkDensityControl() error {
+	var positions []float32
+	for i := 0; i < 64; i++ {
+		positions = append(positions, -4+0.001*float32(i%4), 0.5+0.001*float32(i/4%4), 0.5+0.001*float32(i/16))
+	}
+	for i := 0; i <= 10; i++ {
+		positions = append(positions, -4+0.8*float32(i), -3+0.6*float32(i), 2.5)
+	}
+	n := len(positions) / 3
+	newSim := func(d *flow.DensityControl) (*flow.Simulation, error) {
+		s := flow.NewSimulation(flow.DefaultConfig())
+		s.Density = d
+		return s, s.SetParticles(positions, n)
+	}
+	control := flow.DensityControl{Every: 1, Resolution: [3]int{8, 8, 4}, MinPerCell: 2, MaxPerCell: 8, Jitter: 0.5}
+	s, err := newSim(&control)
+	if err != nil {
+		return err
+	}
+	twin, err := newSim(nil)
+	if err != nil {
+		return err
+	}
+	if err := s.Step(0.01); err != nil {
+		return err
+	}
+	if err := twin.Step(0.01); err != nil {
+		return err
+	}
+	r := s.TakeRemap()
+	kept := n - s.Stats.Merged
+	fixed := r != nil && s.Count() == n && s.Stats.Merged == s.Stats.Split && s.Stats.Merged > 0 && len(r.Index) == n
+	var moved, offset, velocity, weight float64
+	for i := 0; fixed && i < n; i++ {
+		j := int(r.Index[i])
+		var v [3]float64
+		for a := 0; a < 3; a++ {
+			d := math.Abs(float64(s.Positions[i*3+a] - twin.Positions[j*3+a]))
+			if i < kept {
+				moved = math.Max(moved, d)
+			} else {
+				offset = math.Max(offset, d)
+			}
+		}
+		if i < kept {
+			weight += float64(r.Weight[i])
+			fixed = fixed && (i == 0 || r.Index[i] > r.Index[i-1])
+			continue
+		}
+		f := s.Flow()
+		v[0], v[1], v[2] = f.VelocityAt(float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), float64(s.Positions[i*3+2]))
+		for a := range v {
+			velocity = math.Max(velocity, math.Abs(float64(s.Velocities[i*3+a])-v[a]))
+		}
+	}
+	fixed = fixed && moved == 0 && weight == float64(n) && offset <= 1 && velocity < 1e-6
+
+	floating := control
+	floating.MinPerCell, floating.Floating, floating.MinCount = 0, true, 40
+	t, err := newSim(&floating)
+	if err != nil {
+		return err
+	}
+	for k := 0; k < 2; k++ {
+		if err := t.Step(0.01); err != nil {
+			return err
+		}
+	}
+	r = t.TakeRemap()
+	weight = 0
+	composed := r != nil && t.Count() == n-t.Stats.Merged && t.Count() >= floating.MinCount && t.Stats.Merged > 32
+	for i := 0; composed && i < t.Count(); i++ {
+		weight += float64(r.Weight[i])
+		composed = i == 0 || r.Index[i] > r.Index[i-1]
+	}
+	composed = composed && weight == float64(n) && t.TakeRemap() == nil
+	c.report("density control", fixed && composed, "fixed count: %d merged and split, survivors off by %.3g, split offsets up to %.3g, velocity |Δ| %.3g; floating: %d left of %d, weights adding up %v", s.Stats.Split, moved, offset, velocity, t.Count(), n, composed)
+	return nil
+}
+
+// checkDensityEstimate verifies the kernel density estimates: the per
+// particle densities of a random cloud, spread far enough to span many
+// cells, against the sum over every pair, a lone particle's against its
+// own contribution, and the grid density of a few particles integrated
+// over a fine grid against their count, the kernel having unit integral
+func (c *checker) checkDensityEstimate() error {
+	const h = 0.3
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 400, Seed: 7, Grid: flow.Grid{Min: [3]float64{-3, -2, -1}, Max: [3]float64{3, 2, 1}}}
+	p, err := seed.Positions()
+	if err != nil {
+		return err
+	}
+	p = append(p, 50, 50, 50)
+	n := len(p) / 3
+	got, err := flow.ParticleDensity(p, n, h)
+	if err != nil {
+		return err
+	}
+	pairs := 0.0
+	for i := 0; i < n; i++ {
+		rho := 0.0
+		for j := 0; j < n; j++ {
+			dx, dy, dz := float64(p[i*3]-p[j*3]), float64(p[i*3+1]-p[j*3+1]), float64(p[i*3+2]-p[j*3+2])
+			rho += flow.CubicSpline(math.Sqrt(dx*dx+dy*dy+dz*dz), h)
+		}
+		pairs = math.Max(pairs, math.Abs(float64(got[i])-rho)/rho)
+	}
+	alone := math.Abs(float64(got[n-1])*math.Pi*h*h*h - 1)
+
+	few := []float64{0, 0, 0, 0.2, -0.1, 0.05, -0.3, 0.25, 0.1}
+	g := flow.Grid{Min: [3]float64{-1.2, -1.2, -1.2}, Max: [3]float64{1.2, 1.2, 1.2}, N: [3]int{97, 97, 97}}
+	grid, err := flow.GridDensity(few, 3, h, g)
+	if err != nil {
+		return err
+	}
+	d := g.Spacing()
+	total := 0.0
+	for _, rho := range grid {
+		total += float64(rho) * d[0] * d[1] * d[2]
+	}
+	integral := math.Abs(total/3 - 1)
+	ok := pairs < 1e-6 && alone < 1e-6 && integral < 1e-3
+	c.report("density estimate", ok, "against all pairs %.3g relative; lone particle %.3g; grid integral %.3g from the count", pairs, alone, integral)
+	return nil
+}
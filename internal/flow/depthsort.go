@@ -0,0 +1,106 @@
+package flow
+
+import "math"
+
+// DepthSorter orders particles back to front for alpha blending. It keeps
+// its scratch buffers between calls, so sorting the same number of
+// particles every frame allocates nothing once it has run.
+type DepthSorter struct {
+	keys, scratchKeys, scratch []uint32
+}
+
+// depthKey maps a depth to a key that sorts in ascending order back to
+// front: the bits of the float32 depth, flipped so that they order as the
+// numbers do, then inverted for farthest first. Non-finite depths get the
+// largest key, last.
+func depthKey(d float64) uint32 {
+	if d-d != 0 || math.Abs(d) > math.MaxFloat32 {
+		return math.MaxUint32
+	}
+	bits := math.Float32bits(float32(d))
+	if bits>>31 != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 31
+	}
+	return ^bits
+}
+
+// SortByDepth writes to order the indices of count particles of
+// positions, farthest from the camera first along the unit view direction
+// dir from camera, by a stable least significant digit radix sort on the
+// bits of the float32 depth, a byte a pass, skipping the bytes all keys
+// share: O(count), exact to float32, ties in index order. Particles with a
+// non-finite position come last. If depths is not nil, it receives the
+// view-space depth of each particle, by particle index, NaN for the
+// non-finite ones. order must hold count indices and depths, if set,
+// count values.
+func SortByDepth[T Float](d *DepthSorter, positions []T, count int, camera, dir [3]float64, order []uint32, depths []float32) error {
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return err
+	}
+	if err := CheckBuffer("order", len(order), count, 1); err != nil {
+		return err
+	}
+	if depths != nil {
+		if err := CheckBuffer("depths", len(depths), count, 1); err != nil {
+			return err
+		}
+	}
+	n := math.Sqrt(dir[0]*dir[0] + dir[1]*dir[1] + dir[2]*dir[2])
+	if !(n > 0) || math.IsInf(n, 0) {
+		return Errorf(ErrBadArguments, "the camera direction must be a non-zero finite vector, got %v", dir)
+	}
+	for a := range dir {
+		dir[a] /= n
+		if math.IsNaN(camera[a]) || math.IsInf(camera[a], 0) {
+			return Errorf(ErrBadArguments, "the camera position must be finite, got %v", camera)
+		}
+	}
+	if cap(d.keys) < count {
+		d.keys, d.scratchKeys, d.scratch = make([]uint32, count), make([]uint32, count), make([]uint32, count)
+	}
+	keys, tmpKeys, tmp := d.keys[:count], d.scratchKeys[:count], d.scratch[:count]
+	order = order[:count]
+	for i := range keys {
+		p := positions[i*3 : i*3+3]
+		z := (float64(p[0])-camera[0])*dir[0] + (float64(p[1])-camera[1])*dir[1] + (float64(p[2])-camera[2])*dir[2]
+		keys[i], order[i] = depthKey(z), uint32(i)
+		if depths != nil {
+			depths[i] = float32(z)
+			if keys[i] == math.MaxUint32 {
+				depths[i] = float32(math.NaN())
+			}
+		}
+	}
+	// The passes alternate between order and tmp; an odd number of them
+	// leaves the result in tmp
+	src, dst, srcKeys, dstKeys := order, tmp, keys, tmpKeys
+	odd := false
+	var counts [256]int
+	for shift := uint(0); shift < 32; shift += 8 {
+		counts = [256]int{}
+		for _, k := range srcKeys {
+			counts[k>>shift&0xff]++
+		}
+		if count == 0 || counts[srcKeys[0]>>shift&0xff] == count {
+			continue
+		}
+		at := 0
+		for b, c := range counts {
+			counts[b] = at
+			at += c
+		}
+		for i, k := range srcKeys {
+			b := k >> shift & 0xff
+			dst[counts[b]], dstKeys[counts[b]] = src[i], k
+			counts[b]++
+		}
+		src, dst, srcKeys, dstKeys = dst, src, dstKeys, srcKeys
+		odd = !odd
+	}
+	if odd {
+		copy(order, src)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package flow_test
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestDepthSort(t *testing.T) {
+	run(t, (*checker).checkDepthSort)
+}
+
+// checkDepthSort sorts a random cloud with a few NaN and infinite
+// particles back to front against a comparison sort of the depths, the
+// non-finite particles last in index order and their depths NaN, then
+// sorts it again, which must not allocate
+func (c *checker) checkDepthSort() error {
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 20000, Seed: 3, Grid: flow.Grid{Min: [3]float64{-5, -5, -5}, Max: [3]float64{5, 5, 5}}}
+	p, err := seed.Positions()
+	if err != nil {
+		return err
+	}
+	n := len(p) / 3
+	for _, i := range []int{7, 100, 5000} {
+		p[i*3+1] = float32(math.NaN())
+	}
+	p[9000*3] = float32(math.Inf(-1))
+	camera, dir := [3]float64{10, 2, -3}, [3]float64{-2, -0.5, 0.5}
+	var d flow.DepthSorter
+	order := make([]uint32, n)
+	depths := make([]float32, n)
+	if err := flow.SortByDepth(&d, p, n, camera, dir, order, depths); err != nil {
+		return err
+	}
+	want := make([]int, n)
+	for i := range want {
+		want[i] = i
+	}
+	l := math.Sqrt(dir[0]*dir[0] + dir[1]*dir[1] + dir[2]*dir[2])
+	depth := func(i int) float64 {
+		return ((float64(p[i*3])-camera[0])*dir[0] + (float64(p[i*3+1])-camera[1])*dir[1] + (float64(p[i*3+2])-camera[2])*dir[2]) / l
+	}
+	finite := func(i int) bool { z := depth(i); return z-z == 0 }
+	sort.SliceStable(want, func(a, b int) bool {
+		i, j := want[a], want[b]
+		if !finite(i) || !finite(j) {
+			return finite(i) && !finite(j)
+		}
+		return float32(depth(i)) > float32(depth(j))
+	})
+	sorted := true
+	for k, i := range want {
+		sorted = sorted && order[k] == uint32(i)
+	}
+	worst := 0.0
+	for i := 0; i < n; i++ {
+		if !finite(i) {
+			sorted = sorted && math.IsNaN(float64(depths[i]))
+			continue
+		}
+		worst = math.Max(worst, math.Abs(float64(depths[i])-depth(i)))
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := flow.SortByDepth(&d, p, n, camera, dir, order, nil); err != nil {
+		return err
+	}
+	runtime.ReadMemStats(&after)
+	allocs := after.Mallocs - before.Mallocs
+	c.report("depth sort", sorted && worst < 1e-5 && allocs == 0, "%d particles back to front as a comparison sort %v, non-finite last; depths off by %.3g; %d allocations sorting again", n, sorted, worst, allocs)
+	return nil
+}
@@ -0,0 +1,273 @@
+package flow
+
+import "math"
+
+// surfaceOffset is the relative distance outside the body at which surface
+// samples are evaluated, since points exactly on the surface count as
+// inside the object.
+const surfaceOffset = 1e-9
+
+// Residual summarizes an error metric sampled over a set of points
+type Residual struct {
+	Max     float64
+	RMS     float64
+	Worst   [3]float64 // Location of the largest residual
+	Samples int
+}
+
+// add accumulates one sample
+func (r *Residual) add(v float64, x, y, z float64) {
+	if v > r.Max || r.Samples == 0 {
+		r.Max = v
+		r.Worst = [3]float64{x, y, z}
+	}
+	r.RMS += v * v
+	r.Samples++
+}
+
+// finish turns the accumulated sum of squares into the RMS
+func (r *Residual) finish() {
+	if r.Samples > 0 {
+		r.RMS = math.Sqrt(r.RMS / float64(r.Samples))
+	}
+}
+
+// checkFreeStream rejects a zero free stream for functions that normalize
+// by it (pressure coefficients, residuals).
+func checkFreeStream(freeStream float64) error {
+	if freeStream == 0 || math.IsNaN(freeStream) {
+		return Errorf(ErrBadArguments, "freeStreamVelocity must be non-zero for normalized quantities, got %g", freeStream)
+	}
+	return nil
+}
+
+// checkOnset is checkFreeStream for the onset speed of f, relative to the
+// object at its height, by which Cp is normalized
+func (f *Flow) checkOnset() error {
+	return checkFreeStream(math.Sqrt(f.onset2()))
+}
+
+// PressureCoefficients returns Cp = 1 - |v|²/U∞² for count particles. It
+// rejects U∞ = 0, for which Cp is undefined.
+func PressureCoefficients(velocities []float32, count int, freeStreamVelocity float64) ([]float32, error) {
+	if err := CheckBuffer("velocities", len(velocities), count, 3); err != nil {
+		return nil, err
+	}
+	if err := checkFreeStream(freeStreamVelocity); err != nil {
+		return nil, err
+	}
+	u2 := freeStreamVelocity * freeStreamVelocity
+	result := make([]float32, count)
+	for i := 0; i < count; i++ {
+		vx := float64(velocities[i*3])
+		vy := float64(velocities[i*3+1])
+		vz := float64(velocities[i*3+2])
+		result[i] = float32(1 - (vx*vx+vy*vy+vz*vz)/u2)
+	}
+	return result, nil
+}
+
+// SurfacePoint returns sample i of n on the object surface (just outside
+// it) together with the outward unit normal. Spheres are sampled with a
+// Fibonacci lattice; cylinders and airfoil sections on a helix over one
+// radius of span, since their cross-section is the circle rxy = R. Plates
+// are sampled on both faces alternately, along the chord with points
+// bunched toward the edges like the cosine of a uniform angle, and over
+// one half chord of span. Elliptic cylinders follow the helix around the
+// ellipse, scaled about its axis, and tori a helix winding around the tube
+// as it goes around the axis. Boxes are sampled on each face in turn, on a
+// Fibonacci lattice of the face.
+func (o *ObjectSpec) SurfacePoint(i, n int) (p, normal [3]float64) {
+	t := (float64(i) + 0.5) / float64(n)
+	r := o.Radius * (1 + surfaceOffset)
+	switch o.Type {
+	case Plate:
+		c, nrm := o.chord()
+		side := 1.0
+		if i%2 == 1 {
+			side = -1
+		}
+		s := -o.Radius * math.Cos(math.Pi*t)
+		h := o.Radius * surfaceOffset * side
+		p = [3]float64{o.X + s*c[0] + h*nrm[0], o.Y + s*c[1] + h*nrm[1], o.Z + (2*t-1)*o.Radius}
+		return p, [3]float64{side * nrm[0], side * nrm[1], 0}
+	case EllipticCylinder:
+		q, nrm := o.section(2 * math.Pi * t * 7)
+		s := 1 + surfaceOffset
+		p = [3]float64{o.X + s*q[0], o.Y + s*q[1], o.Z + (2*t-1)*o.Radius}
+		return p, [3]float64{nrm[0], nrm[1], 0}
+	case Torus:
+		a := o.minorRadius() * (1 + surfaceOffset)
+		st, ct := math.Sincos(2 * math.Pi * t)
+		sp, cp := math.Sincos(2 * math.Pi * t * 37)
+		p = [3]float64{o.X + a*cp, o.Y + (o.Radius+a*sp)*ct, o.Z + (o.Radius+a*sp)*st}
+		return p, [3]float64{cp, sp * ct, sp * st}
+	case Box:
+		// Face i%6, normal to axis a on side s, and the face's share of n
+		a, s, m := i%6/2, float64(i%2*2-1), (n+5-i%6)/6
+		j := float64(i / 6)
+		u, v := (j+0.5)/float64(m), math.Mod((j+0.5)*(math.Sqrt(5)-1)/2, 1)
+		h, e := o.halfSize(), o.boxAxes()
+		var q [3]float64
+		q[a] = s * (h[a] + o.Radius*surfaceOffset)
+		q[(a+1)%3], q[(a+2)%3] = (2*u-1)*h[(a+1)%3], (2*v-1)*h[(a+2)%3]
+		p = [3]float64{o.X, o.Y, o.Z}
+		for k := range e {
+			for c := range p {
+				p[c] += q[k] * e[k][c]
+			}
+		}
+		return p, [3]float64{s * e[a][0], s * e[a][1], s * e[a][2]}
+	case Sphere:
+		cz := 1 - 2*t
+		s := math.Sqrt(1 - cz*cz)
+		phi := float64(i) * math.Pi * (3 - math.Sqrt(5))
+		normal = [3]float64{s * math.Cos(phi), s * math.Sin(phi), cz}
+	default:
+		theta := 2 * math.Pi * t * 7
+		normal = [3]float64{math.Cos(theta), math.Sin(theta), 0}
+		p[2] = (2*t - 1) * o.Radius
+	}
+	p[0] = o.X + r*normal[0]
+	p[1] = o.Y + r*normal[1]
+	p[2] += o.Z + r*normal[2]
+	return p, normal
+}
+
+// MaxTangencySamples bounds the samples of TangencyResidual
+const MaxTangencySamples = 1 << 20
+
+// TangencyResidual samples n points on the object surface, spread as by
+// SurfacePoint, and measures the velocity through it relative to the
+// object, |(v - V)·n|/U∞ with V the object's velocity and U∞ the onset
+// speed, which is zero for an exact potential-flow body. The velocity is
+// that of the kernel the particles are advected with, elements, images,
+// channel and all, so the analytic spheres and cylinders meet it to
+// roundoff while the fitted torus, the panels of a box, a superposition
+// bounded by the object or a sphere imaged in a wall show the error of
+// their approximation. The fast arithmetic is sampled fastSurfaceOffset
+// outside the body, where its float32 points are.
+func (f *Flow) TangencyResidual(n int) (Residual, error) {
+	var res Residual
+	if err := f.Validate(); err != nil {
+		return res, err
+	}
+	u := math.Sqrt(f.onset2())
+	if err := checkFreeStream(u); err != nil {
+		return res, err
+	}
+	if n > MaxTangencySamples {
+		return res, Errorf(ErrBadArguments, "at most %d tangency samples are taken, got %d", MaxTangencySamples, n)
+	}
+	if f.Object.Radius == 0 || n < 1 {
+		return res, nil
+	}
+	k, v := f.kernel(), f.ObjectVelocity()
+	for i := 0; i < n; i++ {
+		p, nrm := f.Object.SurfacePoint(i, n)
+		if k.lean != nil {
+			// Out of the body in float32 as well, along the radial normal
+			for a := range p {
+				p[a] += f.Object.Radius * (fastSurfaceOffset - surfaceOffset) * nrm[a]
+			}
+		}
+		vx, vy, vz := k.velocityAt(p[0], p[1], p[2])
+		vx, vy, vz = vx-v[0], vy-v[1], vz-v[2]
+		res.add(math.Abs(vx*nrm[0]+vy*nrm[1]+vz*nrm[2])/u, p[0], p[1], p[2])
+	}
+	res.finish()
+	return res, nil
+}
+
+// Divergence estimates ∇·v at a point with central differences of step h.
+func (f *Flow) Divergence(x, y, z, h float64) float64 {
+	ax, _, _ := f.VelocityAt(x+h, y, z)
+	bx, _, _ := f.VelocityAt(x-h, y, z)
+	_, ay, _ := f.VelocityAt(x, y+h, z)
+	_, by, _ := f.VelocityAt(x, y-h, z)
+	_, _, az := f.VelocityAt(x, y, z+h)
+	_, _, bz := f.VelocityAt(x, y, z-h)
+	return (ax - bx + ay - by + az - bz) / (2 * h)
+}
+
+// Disturbance returns the magnitude of the velocity perturbation |v - U∞|
+// at a point.
+func (f *Flow) Disturbance(x, y, z float64) float64 {
+	vx, vy, vz := f.VelocityAt(x, y, z)
+	vx -= f.FreeStream
+	return math.Sqrt(vx*vx + vy*vy + vz*vz)
+}
+
+// CutoffFor returns the far-field cutoff, in radii, beyond which the
+// disturbance of an object of type t is below tolerance times the free
+// stream: |Δv|/U∞ ≤ (R/r)³ for spheres, (R/r)² for cylinders and
+// 2R/r + (R/r)² for the airfoil, whose circulation decays so slowly that
+// useful tolerances need cutoffs of hundreds of radii, and R/r + (R/r)²
+// for plates, of half chord R, at any incidence, and the airfoil's bound
+// for elliptic cylinders of semi-major axis R, with or without the Kutta
+// condition. Tori and boxes, whose disturbance depends on their shape,
+// have none. The cylinder's
+// non-potential vz term grows with |z| and is not covered; measure the
+// actual error with CutoffError.
+func CutoffFor(t ObjectType, tolerance float64) (float64, error) {
+	if !(tolerance > 0 && tolerance < 1) {
+		return 0, Errorf(ErrBadArguments, "tolerance must be between 0 and 1, got %g", tolerance)
+	}
+	switch t {
+	case Sphere:
+		return math.Max(math.Cbrt(1/tolerance), 1+1e-9), nil
+	case Cylinder:
+		return math.Max(math.Sqrt(1/tolerance), 1+1e-9), nil
+	case Airfoil:
+		return math.Max(1/(math.Sqrt(1+tolerance)-1), 1+1e-9), nil
+	case Plate:
+		return math.Max(2/(math.Sqrt(1+4*tolerance)-1), 1+1e-9), nil
+	case EllipticCylinder:
+		return math.Max(1/(math.Sqrt(1+tolerance)-1), 1+1e-9), nil
+	case Torus, Box:
+		return 0, Errorf(ErrUnsupported, "no cutoff bound for a %v; measure the error with CutoffError", t)
+	}
+	return 0, Errorf(ErrBadArguments, "unknown object type %v", t)
+}
+
+// CutoffError samples n points just beyond the far-field cutoff of f,
+// where the neglected disturbance is largest, and measures
+// |v - v_exact|/U∞ against the flow without cutoff. Cylinders and airfoil
+// sections are sampled over one radius of span.
+func (f *Flow) CutoffError(n int) (Residual, error) {
+	var res Residual
+	if err := f.Validate(); err != nil {
+		return res, err
+	}
+	if err := checkFreeStream(f.FreeStream); err != nil {
+		return res, err
+	}
+	if f.Cutoff == 0 || f.Object.Radius == 0 || n < 1 {
+		return res, nil
+	}
+	exact := *f
+	exact.Cutoff = 0
+	shell := f.Object
+	shell.Radius *= f.Cutoff * (1 + 1e-9)
+	if shell.Type == Plate || shell.Type == EllipticCylinder {
+		// The circle through the ends, not a longer body
+		shell.Type, shell.Alpha, shell.EdgeRadius, shell.SemiMinor, shell.Kutta = Cylinder, 0, 0, 0, false
+	}
+	if shell.Type == Torus || shell.Type == Box {
+		// The sphere the cutoff distance is measured in
+		shell.Type, shell.MinorRadius, shell.Collocation = Sphere, 0, 0
+		shell.Alpha, shell.EdgeRadius, shell.Size, shell.Panels = 0, 0, [3]float64{}, 0
+	}
+	for i := 0; i < n; i++ {
+		p, _ := shell.SurfacePoint(i, n)
+		if f.Object.planar() {
+			p[2] = f.Object.Z + (p[2]-f.Object.Z)/f.Cutoff
+		}
+		ax, ay, az := f.VelocityAt(p[0], p[1], p[2])
+		bx, by, bz := exact.VelocityAt(p[0], p[1], p[2])
+		dx, dy, dz := ax-bx, ay-by, az-bz
+		res.add(math.Sqrt(dx*dx+dy*dy+dz*dz)/math.Abs(f.FreeStream), p[0], p[1], p[2])
+	}
+	res.finish()
+	return res, nil
+}
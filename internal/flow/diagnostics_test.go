@@ -0,0 +1,57 @@
+package flow_test
+
+import (
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestCutoff(t *testing.T) {
+	forTypes(t, (*checker).checkCutoff, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+func TestTangencyResidual(t *testing.T) {
+	run(t, (*checker).checkTangencyResidual)
+}
+
+// checkCutoff verifies that the cutoff CutoffFor picks for a tolerance
+// keeps the error just beyond it within that tolerance
+func (c *checker) checkCutoff(t flow.ObjectType) error {
+	f := checkFlow(t)
+	cutoff, err := flow.CutoffFor(t, cutoffTolerance)
+	if err != nil {
+		return err
+	}
+	f.Cutoff = cutoff
+	res, err := f.CutoffError(4096)
+	if err != nil {
+		return err
+	}
+	c.report(t.String()+" far-field cutoff", res.Max <= cutoffTolerance*(1+cutoffSlack), "%.4g radii: max |Δv|/U = %.3g, want ≤ %g", cutoff, res.Max, cutoffTolerance)
+	return nil
+}
+
+// checkTangencyResidual measures the flow through a sphere relative to
+// it while it moves through fluid at rest, none, and through a sphere
+// imaged in a wall, some, largest on the side facing the wall
+func (c *checker) checkTangencyResidual() error {
+	moving := checkFlow(flow.Sphere)
+	moving.Frame, moving.Motion = flow.FrameLab, [3]float64{0.3, -0.4, 0.2}
+	still, err := moving.TangencyResidual(2048)
+	if err != nil {
+		return err
+	}
+	walled := checkFlow(flow.Sphere)
+	walled.Boundary = flow.Boundary{Mode: flow.BoundaryWall, Height: 1.5}
+	imaged, err := walled.TangencyResidual(2048)
+	if err != nil {
+		return err
+	}
+	rest := flow.Flow{Density: 1, Object: flow.ObjectSpec{Radius: 1}}
+	_, err = rest.TangencyResidual(16)
+	e, rejected := err.(*flow.Error)
+	rejected = rejected && e.Code == flow.ErrBadArguments
+	c.report("tangency residual", still.Max < tangencyTolerance && imaged.Max > 1e-3 && imaged.Max < 1 && imaged.Worst[2] > 0.5 && imaged.Samples == 2048 && rejected,
+		"moving sphere max %.3g; sphere by a wall max %.3g, rms %.3g, worst at %.3f; at rest rejected %v", still.Max, imaged.Max, imaged.RMS, imaged.Worst, rejected)
+	return nil
+}
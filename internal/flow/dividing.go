@@ -0,0 +1,288 @@
+package flow
+
+import "math"
+
+// DividingSurfaceLines is the number of streamlines by which
+// DividingStreamline traces the dividing streamsurface, spread evenly
+// about the incoming dividing streamline
+const DividingSurfaceLines = 16
+
+// stagnationTolerance is the speed, relative to the onset speed, below
+// which a point is a stagnation point
+const stagnationTolerance = 1e-6
+
+// stagnationResidual is the speed, relative to the onset speed, above
+// which the point DividingStreamline finds is no stagnation point
+const stagnationResidual = 1e-3
+
+// stagnationOffset is the distance from the stagnation point, in steps, at
+// which the dividing streamlines start
+const stagnationOffset = 1e-3
+
+// stagnationSteps bounds the steps DividingStreamline takes near the
+// stagnation point, on top of those at full length
+const stagnationSteps = 256
+
+// Dividing holds the dividing streamline of a flow and, for a
+// superposition without object, its dividing streamsurface
+type Dividing struct {
+	Stagnation [3]float64 // The front stagnation point
+	Residual   float64    // The speed there, m/s
+	Upstream   Polyline   // The streamline ending at Stagnation, which it ends with
+	Surface    []Polyline // Streamlines from around Stagnation downstream
+}
+
+// DividingStreamline locates the front stagnation point of f and traces
+// the streamline ending there, over length upstream of it. The stagnation
+// point is where the velocity along the free stream first vanishes on the
+// free stream line through the object, scanning downstream in steps of h
+// from length upstream of it, then bisecting; in a superposition, which
+// has no object solution, the line runs through the mean position of the
+// elements but uniform flows, and Newton's method then takes the point to
+// where the whole velocity vanishes. A body whose flow still moves where
+// that line meets it, as with circulation about a cylinder, has no
+// stagnation point there, and ErrUnsupported is returned.
+//
+// The streamlines are traced with RK4 on the unit velocity direction, in
+// arc length steps of h, except near the stagnation point, where the flow
+// slows to nothing: there the step is half the distance to it, so the
+// line starts stagnationOffset steps away and leaves it in a geometric
+// progression of steps rather than stalling. The upstream streamline
+// starts along the incoming direction, the eigenvector of the symmetric
+// part of ∇v of least eigenvalue, or against the free stream at a body,
+// and is returned in the direction of the flow.
+//
+// For a superposition without object, such as a source in a uniform
+// stream, the dividing streamsurface is the outline of the body the flow
+// makes, a Rankine half-body for the source: DividingSurfaceLines
+// streamlines start around the stagnation point, in the plane normal to
+// the incoming direction, and run length downstream along it. With an
+// object the surface is its own, and Surface is empty.
+func (f *Flow) DividingStreamline(length, h float64) (Dividing, error) {
+	var d Dividing
+	if err := f.Validate(); err != nil {
+		return d, err
+	}
+	if !(length > 0) || math.IsInf(length, 0) {
+		return d, Errorf(ErrBadArguments, "length must be positive, got %g", length)
+	}
+	if !(h > 0) || h > length {
+		return d, Errorf(ErrBadArguments, "stepSize must be positive and at most length, got %g", h)
+	}
+	o := &f.Object
+	anchor := [3]float64{o.X, o.Y, o.Z}
+	if f.Elements != nil {
+		n := 0
+		anchor = [3]float64{}
+		for _, e := range f.Elements.elements {
+			if e.Kind == ElementUniform {
+				continue
+			}
+			for a := range anchor {
+				anchor[a] += e.Position[a]
+			}
+			n++
+		}
+		if n == 0 {
+			return d, Errorf(ErrUnsupported, "the elements are all uniform flows, which divide nowhere")
+		}
+		for a := range anchor {
+			anchor[a] /= float64(n)
+		}
+	} else if o.Radius == 0 {
+		return d, Errorf(ErrUnsupported, "there is no object to divide the flow")
+	}
+
+	k := f.kernel()
+	g := f.onset()
+	dir := g.direction()
+	at := func(s float64) [3]float64 {
+		return [3]float64{anchor[0] - s*dir[0], anchor[1] - s*dir[1], anchor[2] - s*dir[2]}
+	}
+	// The free stream line, from the velocity where the scan starts
+	start := at(length)
+	vx, vy, vz := k.velocityAt(start[0], start[1], start[2])
+	dir, u := normalized([3]float64{vx, vy, vz})
+	if !(u > 0) {
+		return d, Errorf(ErrUnsupported, "the flow is at rest %g upstream", length)
+	}
+	ahead := func(s float64) bool {
+		p := at(s)
+		if o.Contains(p[0], p[1], p[2]) {
+			return false
+		}
+		vx, vy, vz := k.velocityAt(p[0], p[1], p[2])
+		return vx*dir[0]+vy*dir[1]+vz*dir[2] > 0
+	}
+	lo, hi, found := length, 0.0, false
+	for s := length - h; s > -h/2 && !found; s -= h {
+		if s = math.Max(s, 0); ahead(s) {
+			lo = s
+		} else {
+			hi, found = s, true
+		}
+	}
+	if !found {
+		return d, Errorf(ErrUnsupported, "the flow doesn't stop along the free stream line up to %v", anchor)
+	}
+	for j := 0; j < 60 && lo-hi > 1e-15*length; j++ {
+		if mid := (lo + hi) / 2; ahead(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	p := at(lo)
+	speed := func(p [3]float64) float64 {
+		vx, vy, vz := k.velocityAt(p[0], p[1], p[2])
+		return math.Sqrt(vx*vx + vy*vy + vz*vz)
+	}
+	incoming := [3]float64{-dir[0], -dir[1], -dir[2]}
+	if f.Elements != nil && o.Radius == 0 {
+		p = f.stagnation(p, stagnationTolerance*u)
+		if e, ok := f.incoming(p); ok {
+			incoming = e
+			if e[0]*dir[0]+e[1]*dir[1]+e[2]*dir[2] > 0 {
+				incoming = [3]float64{-e[0], -e[1], -e[2]}
+			}
+		}
+	}
+	d.Stagnation, d.Residual = p, speed(p)
+	if d.Residual > stagnationResidual*u {
+		return d, Errorf(ErrUnsupported, "the flow still moves at %g m/s where the free stream line meets the body at %v, so there is no stagnation point on it", d.Residual, p)
+	}
+
+	delta := stagnationOffset * h
+	seed := [3]float64{p[0] + delta*incoming[0], p[1] + delta*incoming[1], p[2] + delta*incoming[2]}
+	back := f.traceFrom(&k, seed, p, -1, length, h)
+	d.Upstream = make(Polyline, 0, len(back)+3)
+	for j := len(back) - 3; j >= 0; j -= 3 {
+		d.Upstream = append(d.Upstream, back[j], back[j+1], back[j+2])
+	}
+	d.Upstream = append(d.Upstream, float32(p[0]), float32(p[1]), float32(p[2]))
+	if f.Elements == nil || o.Radius != 0 {
+		return d, nil
+	}
+	_, a, b := normalAxes(incoming)
+	for j := 0; j < DividingSurfaceLines; j++ {
+		s, c := math.Sincos(2 * math.Pi * float64(j) / DividingSurfaceLines)
+		var q [3]float64
+		for i := range q {
+			q[i] = p[i] + delta*(c*a[i]+s*b[i])
+		}
+		d.Surface = append(d.Surface, f.traceFrom(&k, q, p, 1, length, h))
+	}
+	return d, nil
+}
+
+// stagnation refines p to where the velocity of f vanishes, by Newton's
+// method, damped for the directions along which a planar flow doesn't
+// change, until the speed drops below tolerance
+func (f *Flow) stagnation(p [3]float64, tolerance float64) [3]float64 {
+	for it := 0; it < 20; it++ {
+		vx, vy, vz := f.VelocityAt(p[0], p[1], p[2])
+		v := [3]float64{vx, vy, vz}
+		if _, s := normalized(v); s < tolerance {
+			break
+		}
+		// (JᵀJ + μI)Δ = -Jᵀv
+		j := f.VelocityGradient(p[0], p[1], p[2])
+		m := make([][]float64, 3)
+		rhs := make([]float64, 3)
+		norm := 0.0
+		for r := range j {
+			for c := range j {
+				norm += j[r][c] * j[r][c]
+			}
+		}
+		for r := 0; r < 3; r++ {
+			m[r] = make([]float64, 3)
+			for c := 0; c < 3; c++ {
+				for i := 0; i < 3; i++ {
+					m[r][c] += j[i][r] * j[i][c]
+				}
+			}
+			m[r][r] += 1e-12 * norm
+			for i := 0; i < 3; i++ {
+				rhs[r] -= j[i][r] * v[i]
+			}
+		}
+		if !eliminate(m, [][]float64{rhs}) {
+			break
+		}
+		for a := range p {
+			p[a] += rhs[a]
+		}
+	}
+	return p
+}
+
+// incoming returns the direction along which the flow of f comes into the
+// stagnation point p: the eigenvector of the least eigenvalue of the
+// symmetric part of ∇v there, of either sign
+func (f *Flow) incoming(p [3]float64) ([3]float64, bool) {
+	g := f.VelocityGradient(p[0], p[1], p[2])
+	var s [3][3]float64
+	for i := range g {
+		for j := range g {
+			s[i][j] = (g[i][j] + g[j][i]) / 2
+		}
+	}
+	l := symmetricEigenvalues(s)[0]
+	for i := range s {
+		s[i][i] -= l
+	}
+	// The rows of S - λI span the plane normal to the eigenvector
+	best, e := 0.0, [3]float64{}
+	for _, r := range [][2]int{{0, 1}, {0, 2}, {1, 2}} {
+		c, n := normalized(cross(s[r[0]], s[r[1]]))
+		if n > best {
+			best, e = n, c
+		}
+	}
+	return e, best > 0
+}
+
+// traceFrom traces the streamline of f through seed, near the stagnation
+// point p, downstream if sign is positive and upstream otherwise, over an
+// arc length of length, stepping h or half the distance to p if shorter,
+// as for DividingStreamline. It stops in the object, on crossing a plate,
+// where the flow stagnates or after stagnationSteps steps more than those
+// of h would take. The seed is the first point.
+func (f *Flow) traceFrom(k *kernel, seed, p [3]float64, sign, length, h float64) Polyline {
+	dir := func(q [3]float64) ([3]float64, bool) {
+		vx, vy, vz := k.velocityAt(q[0], q[1], q[2])
+		v, s := normalized([3]float64{vx, vy, vz})
+		if s < 1e-12 {
+			return v, false
+		}
+		return [3]float64{sign * v[0], sign * v[1], sign * v[2]}, true
+	}
+	along := func(q, d [3]float64, t float64) [3]float64 {
+		return [3]float64{q[0] + t*d[0], q[1] + t*d[1], q[2] + t*d[2]}
+	}
+	q := seed
+	line := Polyline{float32(q[0]), float32(q[1]), float32(q[2])}
+	steps := int(length/h) + stagnationSteps
+	for done := 0.0; done < length && steps > 0; steps-- {
+		_, r := normalized([3]float64{q[0] - p[0], q[1] - p[1], q[2] - p[2]})
+		t := math.Min(math.Min(h, r/2), length-done)
+		k1, ok1 := dir(q)
+		k2, ok2 := dir(along(q, k1, t/2))
+		k3, ok3 := dir(along(q, k2, t/2))
+		k4, ok4 := dir(along(q, k3, t))
+		if !(ok1 && ok2 && ok3 && ok4) {
+			break
+		}
+		prev := q
+		for a := 0; a < 3; a++ {
+			q[a] += t / 6 * (k1[a] + 2*k2[a] + 2*k3[a] + k4[a])
+		}
+		if f.Object.Contains(q[0], q[1], q[2]) || f.Object.Crosses(prev, q) {
+			break
+		}
+		line = append(line, float32(q[0]), float32(q[1]), float32(q[2]))
+		done += t
+	}
+	return line
+}
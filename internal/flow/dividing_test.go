@@ -0,0 +1,58 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestDividingStreamline(t *testing.T) {
+	run(t, (*checker).checkDividingStreamline)
+}
+
+// checkDividingStreamline finds the stagnation point of a source of
+// strength m in a uniform stream U a distance a = √(m/4πU) upstream of it
+// and traces its dividing streamsurface on the Rankine half-body, whose
+// radius is ϖ² = 2a²(1 + cos θ); it finds the front of a sphere too, and
+// no stagnation point on the free stream line of a cylinder with
+// circulation
+func (c *checker) checkDividingStreamline() error {
+	sp, err := flow.NewSuperposition([]flow.Element{{Kind: flow.ElementSource, Position: [3]float64{0.5, 0, 0}, Strength: 4 * math.Pi}})
+	if err != nil {
+		return err
+	}
+	f := flow.Flow{FreeStream: 1, Density: 1, Elements: sp}
+	d, err := f.DividingStreamline(10, 0.05)
+	if err != nil {
+		return err
+	}
+	stagnation := math.Hypot(d.Stagnation[0]+0.5, math.Hypot(d.Stagnation[1], d.Stagnation[2]))
+	n := d.Upstream.Len() - 1
+	upstream := d.Upstream[0] < -10 && d.Upstream[1] == 0 && d.Upstream[n*3] == float32(d.Stagnation[0])
+	outline, far := 0.0, math.Inf(1)
+	for _, l := range d.Surface {
+		for j := 1; j < l.Len(); j++ {
+			x, y, z := float64(l[j*3])-0.5, float64(l[j*3+1]), float64(l[j*3+2])
+			outline = math.Max(outline, math.Abs(math.Hypot(y, z)-math.Sqrt(2*(1+x/math.Sqrt(x*x+y*y+z*z)))))
+		}
+		far = math.Min(far, float64(l[len(l)-3]))
+	}
+	surface := len(d.Surface) == flow.DividingSurfaceLines && far > 7
+
+	sphere := checkFlow(flow.Sphere)
+	front, err := sphere.DividingStreamline(5, 0.1)
+	if err != nil {
+		return err
+	}
+	spinning := checkFlow(flow.Cylinder)
+	spinning.Object.Strengths = flow.Strengths{Circulation: 2, Set: flow.StrengthCirculation}
+	_, err = spinning.DividingStreamline(5, 0.1)
+	e, rejected := err.(*flow.Error)
+	rejected = rejected && e.Code == flow.ErrUnsupported
+	c.report("dividing streamline", stagnation < 1e-9 && upstream && outline < 1e-5 && surface && math.Abs(front.Stagnation[0]+1) < 1e-12 &&
+		rejected,
+		"half-body stagnation point off by %.3g, upstream line along the axis %v, outline off the half-body by %.3g, %d lines past x = %.3g; sphere front at %v; circulation %v",
+		stagnation, upstream, outline, len(d.Surface), far, front.Stagnation, err)
+	return nil
+}
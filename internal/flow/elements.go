@@ -0,0 +1,391 @@
+package flow
+
+import (
+	"math"
+	"strconv"
+)
+
+// Element kinds
+const (
+	ElementUniform    = "uniform"    // Uniform flow of speed strength along the orientation
+	ElementSource     = "source"     // Point source emitting strength, a volume per second
+	ElementSink       = "sink"       // Point sink absorbing strength
+	ElementDoublet    = "doublet"    // Line doublet along z, moment strength per unit span
+	ElementVortex     = "vortex"     // Infinite line vortex along the orientation, circulation strength
+	ElementVortexLine = "vortexLine" // Vortex segment from position to position + orientation
+	ElementDoublet3D  = "doublet3d"  // Point doublet, moment strength
+)
+
+// elementKinds lists the element kinds with their default orientations
+var elementKinds = []struct {
+	name        string
+	orientation [3]float64
+}{
+	{ElementUniform, [3]float64{1, 0, 0}},
+	{ElementSource, [3]float64{1, 0, 0}},
+	{ElementSink, [3]float64{1, 0, 0}},
+	{ElementDoublet, [3]float64{1, 0, 0}},
+	{ElementVortex, [3]float64{0, 0, 1}},
+	{ElementVortexLine, [3]float64{}},
+	{ElementDoublet3D, [3]float64{1, 0, 0}},
+}
+
+// MaxElements is the most elements a flow superposes
+const MaxElements = 256
+
+// Element is an elementary flow, superposed with others by a
+// Superposition.
+// Orientation is a unit vector, normalized on decoding, except for
+// vortexLine, where it is the segment itself. Doublets point their moment
+// along it: φ = μ/4π·(a·r)/r³ for doublet3d and φ = μ/2π·(a·r)/r² for the
+// line doublet, whose orientation is projected onto the xy plane, so that
+// a doublet of moment 2πU·R³ (2πU·R² per unit span) along the free stream
+// makes the sphere (cylinder) of radius R. Circulations are right-handed
+// about the orientation, and sources and sinks radiate equally in every
+// direction, so their orientation is unused.
+//
+// CoreRadius, if non-zero, smooths the singularity: the distance r in the
+// denominators becomes √(r² + CoreRadius²), a Plummer core for sources and
+// doublets and a Scully core for vortices.
+type Element struct {
+	Kind        string
+	Position    [3]float64
+	Orientation [3]float64
+	Strength    float64
+	CoreRadius  float64
+}
+
+// Validate checks the parameters of e
+func (e *Element) Validate() error {
+	found := false
+	for _, k := range elementKinds {
+		found = found || k.name == e.Kind
+	}
+	if !found {
+		return Errorf(ErrBadArguments, "unknown element kind %q", e.Kind)
+	}
+	for _, x := range append(append(e.Position[:], e.Orientation[:]...), e.Strength, e.CoreRadius) {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "%s element parameters must be finite", e.Kind)
+		}
+	}
+	if e.CoreRadius < 0 {
+		return Errorf(ErrBadArguments, "%s element coreRadius must be non-negative, got %g", e.Kind, e.CoreRadius)
+	}
+	if _, err := e.axis(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// axis returns the unit orientation of e, projected onto the xy plane for
+// a line doublet, or the segment of a vortexLine. The orientation of
+// sources and sinks is returned as is.
+func (e *Element) axis() ([3]float64, error) {
+	a := e.Orientation
+	if e.Kind == ElementSource || e.Kind == ElementSink {
+		return a, nil
+	}
+	if e.Kind == ElementDoublet {
+		a[2] = 0
+	}
+	if a == [3]float64{} {
+		return a, Errorf(ErrBadArguments, "%s element orientation must be non-zero", e.Kind)
+	}
+	if e.Kind == ElementVortexLine {
+		return a, nil
+	}
+	return unit(a, "orientation")
+}
+
+// Encode returns e in the generic form accepted by DecodeElements
+func (e *Element) Encode() map[string]interface{} {
+	p, a := e.Position, e.Orientation
+	return map[string]interface{}{
+		"kind":        e.Kind,
+		"position":    []interface{}{p[0], p[1], p[2]},
+		"orientation": []interface{}{a[0], a[1], a[2]},
+		"strength":    e.Strength,
+		"coreRadius":  e.CoreRadius,
+	}
+}
+
+// Superposition is a list of elements compiled for evaluation (see
+// Flow.Elements). It is immutable: build a new one to change the flow.
+type Superposition struct {
+	elements []Element
+	compiled []compiledElement
+}
+
+// NewSuperposition validates and compiles elements
+func NewSuperposition(elements []Element) (*Superposition, error) {
+	if len(elements) > MaxElements {
+		return nil, Errorf(ErrBadArguments, "at most %d elements are supported, got %d", MaxElements, len(elements))
+	}
+	for i := range elements {
+		if err := elements[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	sp := &Superposition{elements: append([]Element{}, elements...), compiled: make([]compiledElement, len(elements))}
+	for i := range sp.elements {
+		sp.compiled[i] = sp.elements[i].compile()
+	}
+	return sp, nil
+}
+
+// Elements returns a copy of the elements of sp
+func (sp *Superposition) Elements() []Element {
+	return append([]Element{}, sp.elements...)
+}
+
+// Len returns the number of elements of sp
+func (sp *Superposition) Len() int {
+	return len(sp.elements)
+}
+
+// Encode returns the elements of sp in the generic form accepted by
+// DecodeElements
+func (sp *Superposition) Encode() []interface{} {
+	out := make([]interface{}, len(sp.elements))
+	for i := range sp.elements {
+		out[i] = sp.elements[i].Encode()
+	}
+	return out
+}
+
+// DecodeElements decodes a list of elements from generic values (see
+// DecodeConfig), each
+//
+//	{kind, position: [x, y, z] = [0, 0, 0], orientation: [x, y, z], strength, coreRadius = 0}
+//
+// kind and strength are required, and so is the orientation of a
+// vortexLine; the others default to +x, or +z for a vortex.
+func DecodeElements(v interface{}) ([]Element, error) {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, Errorf(ErrBadArguments, "elements must be an array")
+	}
+	if len(a) > MaxElements {
+		return nil, Errorf(ErrBadArguments, "at most %d elements are supported, got %d", MaxElements, len(a))
+	}
+	elements := make([]Element, len(a))
+	for i, v := range a {
+		path := "elements[" + strconv.Itoa(i) + "]"
+		m, err := section(v, path, "kind", "position", "orientation", "strength", "coreRadius")
+		if err != nil {
+			return nil, err
+		}
+		e := &elements[i]
+		if e.Kind, ok = m["kind"].(string); !ok {
+			return nil, Errorf(ErrBadArguments, "%s.kind must be a string", path)
+		}
+		for _, k := range elementKinds {
+			if k.name == e.Kind {
+				e.Orientation = k.orientation
+			}
+		}
+		if v, ok := m["position"]; ok {
+			if e.Position, err = vector(v, path+".position"); err != nil {
+				return nil, err
+			}
+		}
+		if v, ok := m["orientation"]; ok {
+			if e.Orientation, err = vector(v, path+".orientation"); err != nil {
+				return nil, err
+			}
+		} else if e.Kind == ElementVortexLine {
+			return nil, Errorf(ErrBadArguments, "%s.orientation is required", path)
+		}
+		if _, ok := m["strength"]; !ok {
+			return nil, Errorf(ErrBadArguments, "%s.strength is required", path)
+		}
+		if err := numberKey(m, path, "strength", &e.Strength); err != nil {
+			return nil, err
+		}
+		if err := numberKey(m, path, "coreRadius", &e.CoreRadius); err != nil {
+			return nil, err
+		}
+		if err := e.Validate(); err != nil {
+			return nil, Errorf(ErrBadArguments, "%s: %v", path, err)
+		}
+		e.Orientation, _ = e.axis()
+	}
+	return elements, nil
+}
+
+// ElementDims lists the dimensional keys of an element (see
+// DecodeElements). Strengths, whose dimensions depend on the kind, are SI.
+var ElementDims = map[string]Dim{"position": DimLength, "coreRadius": DimLength}
+
+// Element codes of compiledElement
+const (
+	codeUniform = iota
+	codeSource
+	codeDoublet
+	codeVortex
+	codeVortexLine
+	codeDoublet3D
+)
+
+// compiledElement is an Element prepared for evaluation, with the
+// constant factors of its formula folded into c
+type compiledElement struct {
+	code int
+	p, a [3]float64
+	c    float64
+	rc2  float64 // CoreRadius²
+}
+
+// compile prepares e, which must be valid, for evaluation
+func (e *Element) compile() compiledElement {
+	a, _ := e.axis()
+	ce := compiledElement{p: e.Position, a: a, rc2: e.CoreRadius * e.CoreRadius}
+	switch e.Kind {
+	case ElementUniform:
+		ce.code, ce.c = codeUniform, e.Strength
+	case ElementSource:
+		ce.code, ce.c = codeSource, e.Strength/(4*math.Pi)
+	case ElementSink:
+		ce.code, ce.c = codeSource, -e.Strength/(4*math.Pi)
+	case ElementDoublet:
+		ce.code, ce.c = codeDoublet, e.Strength/(2*math.Pi)
+	case ElementVortex:
+		ce.code, ce.c = codeVortex, e.Strength/(2*math.Pi)
+	case ElementVortexLine:
+		ce.code, ce.c = codeVortexLine, e.Strength/(4*math.Pi)
+	case ElementDoublet3D:
+		ce.code, ce.c = codeDoublet3D, e.Strength/(4*math.Pi)
+	}
+	return ce
+}
+
+// velocity returns the velocity the element induces at (px, py, pz). At
+// the singular point of an element without core it is zero.
+func (e *compiledElement) velocity(px, py, pz float64) (vx, vy, vz float64) {
+	a := e.a
+	x, y, z := px-e.p[0], py-e.p[1], pz-e.p[2]
+	switch e.code {
+	case codeUniform:
+		return e.c * a[0], e.c * a[1], e.c * a[2]
+
+	case codeSource:
+		// m/4π·r/r³
+		r2 := x*x + y*y + z*z + e.rc2
+		if r2 == 0 {
+			return 0, 0, 0
+		}
+		s := e.c / (r2 * math.Sqrt(r2))
+		return s * x, s * y, s * z
+
+	case codeDoublet3D:
+		// ∇(μ/4π·(a·r)/r³)
+		r2 := x*x + y*y + z*z + e.rc2
+		if r2 == 0 {
+			return 0, 0, 0
+		}
+		d := e.c / (r2 * math.Sqrt(r2))
+		c := 3 * d * (a[0]*x + a[1]*y + a[2]*z) / r2
+		return d*a[0] - c*x, d*a[1] - c*y, d*a[2] - c*z
+
+	case codeDoublet:
+		// ∇(μ/2π·(a·r)/r²) in the xy plane
+		r2 := x*x + y*y + e.rc2
+		if r2 == 0 {
+			return 0, 0, 0
+		}
+		d := e.c / r2
+		c := 2 * d * (a[0]*x + a[1]*y) / r2
+		return d*a[0] - c*x, d*a[1] - c*y, 0
+
+	case codeVortex:
+		// Γ/2π·a×r/|r⊥|², r⊥ the offset from the axis
+		h := a[0]*x + a[1]*y + a[2]*z
+		x, y, z = x-h*a[0], y-h*a[1], z-h*a[2]
+		r2 := x*x + y*y + z*z + e.rc2
+		if r2 == 0 {
+			return 0, 0, 0
+		}
+		s := e.c / r2
+		return s * (a[1]*z - a[2]*y), s * (a[2]*x - a[0]*z), s * (a[0]*y - a[1]*x)
+
+	case codeVortexLine:
+		// Biot-Savart law of a straight segment from A = p to B = p + a:
+		// Γ/4π·(r1×r2)/|r1×r2|²·a·(r1/|r1| - r2/|r2|), r1 and r2 the
+		// offsets from A and B, with the core added to |r1×r2|² as
+		// |a|²·CoreRadius²
+		x2, y2, z2 := x-a[0], y-a[1], z-a[2]
+		cx, cy, cz := y*z2-z*y2, z*x2-x*z2, x*y2-y*x2
+		l1, l2 := math.Sqrt(x*x+y*y+z*z), math.Sqrt(x2*x2+y2*y2+z2*z2)
+		den := cx*cx + cy*cy + cz*cz + (a[0]*a[0]+a[1]*a[1]+a[2]*a[2])*e.rc2
+		if den == 0 || l1 == 0 || l2 == 0 {
+			return 0, 0, 0
+		}
+		s := e.c * (a[0]*(x/l1-x2/l2) + a[1]*(y/l1-y2/l2) + a[2]*(z/l1-z2/l2)) / den
+		return s * cx, s * cy, s * cz
+	}
+	return 0, 0, 0
+}
+
+// superposition is exact for a flow with Elements: the onset flow plus the
+// velocities of the elements, zero inside the object
+func (k *kernel) superposition(px, py, pz float64) (vx, vy, vz float64) {
+	if k.obj.Contains(px, py, pz) {
+		return 0, 0, 0
+	}
+	vx, vy, vz = k.free[0], k.free[1], k.free[2]
+	for i := range k.elements {
+		ex, ey, ez := k.elements[i].velocity(px, py, pz)
+		vx, vy, vz = vx+ex, vy+ey, vz+ez
+	}
+	return vx, vy, vz
+}
+
+// Preset returns the elements of the object solution of f, so that f with
+// their Superposition as Elements evaluates the same field outside the object up to
+// rounding: a doublet3d and, with a source strength, a source for a
+// sphere, and a doublet and, with a circulation, a vortex for a cylinder.
+// The cylinder's non-potential vz term, zero in the z = 0 plane, has no
+// element. The airfoil's lift model isn't a superposition of elementary
+// flows, nor are a cylinder with a source strength and the plate, so they
+// have no preset.
+func (f *Flow) Preset() ([]Element, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	o := f.Object
+	if o.Radius == 0 {
+		return []Element{}, nil
+	}
+	k := f.kernel()
+	if !k.strengths {
+		k.vortex = 0
+	}
+	center := [3]float64{o.X, o.Y, o.Z}
+	switch o.Type {
+	case Sphere:
+		axis := [3]float64{1, 0, 0}
+		if k.mode == frameRotated {
+			axis = k.e[0]
+		}
+		out := []Element{{Kind: ElementDoublet3D, Position: center, Orientation: axis, Strength: 4 * math.Pi * k.doublet}}
+		if k.source != 0 {
+			out = append(out, Element{Kind: ElementSource, Position: center, Orientation: [3]float64{1, 0, 0}, Strength: 4 * math.Pi * k.source})
+		}
+		return out, nil
+	case Cylinder:
+		if k.source != 0 {
+			break
+		}
+		axis := [3]float64{1, 0, 0}
+		if k.mode == frameCrossFlow {
+			axis = [3]float64{k.c, k.s, 0}
+		}
+		out := []Element{{Kind: ElementDoublet, Position: center, Orientation: axis, Strength: 2 * math.Pi * k.doublet}}
+		if k.vortex != 0 {
+			out = append(out, Element{Kind: ElementVortex, Position: center, Orientation: [3]float64{0, 0, 1}, Strength: 2 * math.Pi * k.vortex})
+		}
+		return out, nil
+	}
+	return nil, Errorf(ErrUnsupported, "the %v has no element preset", o.Type)
+}
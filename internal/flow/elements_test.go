@@ -0,0 +1,134 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestPreset(t *testing.T) {
+	forTypes(t, (*checker).checkPreset, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+func TestElements(t *testing.T) {
+	run(t, (*checker).checkElements)
+}
+
+// TestResetElements checks that Reset keeps the elements with the
+// configuration and removes them with it
+func TestResetElements(t *testing.T) {
+	for _, keep := range []bool{true, false} {
+		s := flow.NewSimulation(flow.DefaultConfig())
+		sp, err := flow.NewSuperposition([]flow.Element{{Kind: flow.ElementSource, Strength: 1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Elements = sp
+		if err := s.Reset(keep); err != nil {
+			t.Fatal(err)
+		}
+		if kept := s.Elements != nil; kept != keep {
+			t.Errorf("Reset(%v) left elements %v, want %v", keep, kept, keep)
+		}
+	}
+}
+
+// checkPreset verifies that the element preset of an object evaluates the
+// same field as the object solution outside it, for an oblique free
+// stream and overridden strengths, in the z = 0 plane of the object where
+// the cylinder's non-potential vz term vanishes, and that the airfoil has
+// no preset
+func (c *checker) checkPreset(t flow.ObjectType) error {
+	if t == flow.Airfoil {
+		f := checkFlow(t)
+		_, err := f.Preset()
+		e, ok := err.(*flow.Error)
+		c.report(t.String()+" preset", ok && e.Code == flow.ErrUnsupported, "unsupported: %v", err)
+		return nil
+	}
+	worst := 0.0
+	for i, st := range []flow.Strengths{{}, {Source: 0.4, Circulation: 1.5, Set: flow.StrengthSource | flow.StrengthCirculation}} {
+		f := checkFlow(t)
+		f.Direction = [3]float64{0.48, 0.6, 0.64}
+		f.Object.X, f.Object.Y, f.Object.Z = 0.1, -0.2, 0.05
+		if i == 1 {
+			if t == flow.Cylinder {
+				st.Set &^= flow.StrengthSource
+			} else {
+				st.Set &^= flow.StrengthCirculation
+			}
+			f.Object.Strengths = st
+		}
+		elements, err := f.Preset()
+		if err != nil {
+			return err
+		}
+		g := f
+		if g.Elements, err = flow.NewSuperposition(elements); err != nil {
+			return err
+		}
+		for j := 0; j < 900; j++ {
+			x, y := float64(j%30)/5-2.9, float64(j/30)/5-2.9
+			if f.Object.Contains(x, y, f.Object.Z) {
+				continue
+			}
+			ux, uy, uz := f.VelocityAt(x, y, f.Object.Z)
+			ex, ey, ez := g.VelocityAt(x, y, f.Object.Z)
+			worst = math.Max(worst, math.Max(math.Abs(ex-ux), math.Max(math.Abs(ey-uy), math.Abs(ez-uz))))
+		}
+	}
+	c.report(t.String()+" preset", worst < presetTolerance, "max |v_elements - v| = %.3g", worst)
+	return nil
+}
+
+// checkElements verifies the elementary flows against closed forms: the
+// flux of a source through a sphere around it, a sink cancelling it, and
+// a long vortex segment inducing the velocity of an infinite line vortex
+func (c *checker) checkElements() error {
+	const m, gamma = 1.7, 2.3
+	sp, err := flow.NewSuperposition([]flow.Element{{Kind: flow.ElementSource, Position: [3]float64{0.3, -0.1, 0.2}, Strength: m}})
+	if err != nil {
+		return err
+	}
+	f := flow.Flow{Density: 1, Elements: sp}
+	// Midpoint rule in cos θ and φ over a sphere of radius 2
+	const n = 200
+	flux := 0.0
+	for i := 0; i < n; i++ {
+		ct := -1 + (float64(i)+0.5)*2/n
+		st := math.Sqrt(1 - ct*ct)
+		for j := 0; j < n; j++ {
+			sp, cp := math.Sincos(2 * math.Pi * (float64(j) + 0.5) / n)
+			nx, ny, nz := st*cp, st*sp, ct
+			vx, vy, vz := f.VelocityAt(0.3+2*nx, -0.1+2*ny, 0.2+2*nz)
+			flux += (vx*nx + vy*ny + vz*nz) * 4 * (2.0 / n) * (2 * math.Pi / n)
+		}
+	}
+
+	pair, err := flow.NewSuperposition([]flow.Element{
+		{Kind: flow.ElementSource, Position: [3]float64{1, 2, 3}, Strength: m},
+		{Kind: flow.ElementSink, Position: [3]float64{1, 2, 3}, Strength: m},
+	})
+	if err != nil {
+		return err
+	}
+	f.Elements = pair
+	px, py, pz := f.VelocityAt(0.5, 0.5, 0.5)
+	cancel := math.Max(math.Abs(px), math.Max(math.Abs(py), math.Abs(pz)))
+
+	line, err := flow.NewSuperposition([]flow.Element{
+		{Kind: flow.ElementVortexLine, Position: [3]float64{0, 0, -1e4}, Orientation: [3]float64{0, 0, 2e4}, Strength: gamma},
+		{Kind: flow.ElementVortex, Position: [3]float64{0, 0, 0}, Orientation: [3]float64{0, 0, -1}, Strength: gamma},
+	})
+	if err != nil {
+		return err
+	}
+	f.Elements = line
+	lx, ly, lz := f.VelocityAt(0.6, 0.8, 0.3)
+	vortex := math.Sqrt(lx*lx+ly*ly+lz*lz) / (gamma / (2 * math.Pi))
+
+	ok := math.Abs(flux-m)/m < elementTolerance && cancel == 0 && vortex < elementTolerance
+	c.report("elements", ok, "source flux %.9g, want %g; source+sink max |v| = %.3g; segment - line vortex |Δv|/(Γ/2π) = %.3g", flux, m, cancel, vortex)
+	return nil
+}
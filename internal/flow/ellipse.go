@@ -0,0 +1,112 @@
+package flow
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// validateEllipse checks the elliptic cylinder parameters of o, and that
+// objects other than elliptic cylinders don't set them
+func (o *ObjectSpec) validateEllipse() error {
+	if b := o.SemiMinor; b < 0 || math.IsNaN(b) || math.IsInf(b, 0) {
+		return Errorf(ErrBadArguments, "semiMinor must be non-negative and finite, got %g", b)
+	}
+	if o.Type != EllipticCylinder {
+		if o.SemiMinor != 0 || o.Kutta {
+			return Errorf(ErrBadArguments, "semiMinor and kutta only apply to elliptic cylinders, not to a %v", o.Type)
+		}
+		return nil
+	}
+	if o.SemiMinor > o.Radius {
+		return Errorf(ErrBadArguments, "semiMinor must not exceed the semi-major axis %g, got %g; turn the ellipse with alpha instead", o.Radius, o.SemiMinor)
+	}
+	if o.Strengths.Set != 0 {
+		return Errorf(ErrUnsupported, "an elliptic cylinder has no strength overrides: its circulation is 0 or follows from the Kutta condition")
+	}
+	if o.CollisionRadius != 0 {
+		return Errorf(ErrBadArguments, "an elliptic cylinder has no collision radius: its inside is the ellipse")
+	}
+	return nil
+}
+
+// semiMinor returns the semi-minor axis of an elliptic cylinder
+func (o *ObjectSpec) semiMinor() float64 {
+	if o.SemiMinor != 0 {
+		return o.SemiMinor
+	}
+	return o.Radius
+}
+
+// insideEllipse reports whether the offset (x, y) from the center of an
+// elliptic cylinder is inside its section
+func (o *ObjectSpec) insideEllipse(x, y float64) bool {
+	t, n := o.chord()
+	a, b := o.Radius, o.semiMinor()
+	s, d := (x*t[0]+y*t[1])/a, (x*n[0]+y*n[1])/b
+	return s*s+d*d <= 1
+}
+
+// section returns the point of the cross-section of a cylinder, airfoil or
+// elliptic cylinder at parameter φ, relative to its axis, and the outward
+// unit normal there. The ellipse is parameterized by its eccentric angle
+// from the trailing end.
+func (o *ObjectSpec) section(phi float64) (p, n [2]float64) {
+	s, c := math.Sincos(phi)
+	if o.Type != EllipticCylinder {
+		return [2]float64{o.Radius * c, o.Radius * s}, [2]float64{c, s}
+	}
+	t, nrm := o.chord()
+	a, b := o.Radius, o.semiMinor()
+	p = [2]float64{a*c*t[0] + b*s*nrm[0], a*c*t[1] + b*s*nrm[1]}
+	// The gradient of (s/a)² + (d/b)²
+	gs, gd := c/a, s/b
+	g := math.Hypot(gs, gd)
+	return p, [2]float64{(gs*t[0] + gd*nrm[0]) / g, (gs*t[1] + gd*nrm[1]) / g}
+}
+
+// ellipseFrame prepares the elliptic cylinder constants of k: its
+// incidence, as for a plate, and the circle the Joukowski transformation
+// z = ζ + k²/ζ maps onto it, of radius (a + b)/2 with k² = (a² - b²)/4,
+// with the circulation placing a stagnation point on the trailing end
+// under the Kutta condition
+func (k *kernel) ellipseFrame() {
+	k.plateFrame()
+	a, b := k.obj.Radius, k.obj.semiMinor()
+	r := (a + b) / 2
+	k.jr2, k.jk2 = r*r, (a*a-b*b)/4
+	if k.obj.Kutta {
+		k.jcirc = 2 * k.u * r * k.ps
+	}
+}
+
+// ellipse is local for an elliptic cylinder: the flow past the circle |ζ|
+// = R at incidence α with a clockwise circulation Γ,
+//
+//	W(ζ) = U·(ζ·e^-iα + R²·e^iα/ζ) + iΓ/2π·ln ζ
+//
+// mapped to the ellipse by the Joukowski transformation, the complex
+// velocity being dW/dz = (dW/dζ)/(dz/dζ). Each point of the plane outside
+// the ellipse has the preimage ζ outside the circle. Γ is 0, or 4πUR·sin α
+// under the Kutta condition, which makes the trailing end, ζ = R, a
+// stagnation point. For a = b it is the circular cylinder, and as b goes
+// to 0 it tends to the plate of chord 2a.
+func (k *kernel) ellipse(x, y float64) (vx, vy, vz float64) {
+	px, py := x*k.pc-y*k.ps, x*k.ps+y*k.pc
+	a, b := k.obj.Radius, k.obj.semiMinor()
+	if (px/a)*(px/a)+(py/b)*(py/b) <= 1 {
+		return 0, 0, 0
+	}
+	z := complex(px, py)
+	root := cmplx.Sqrt(z*z - complex(4*k.jk2, 0))
+	zeta := (z + root) / 2
+	if other := (z - root) / 2; cmplx.Abs(other) > cmplx.Abs(zeta) {
+		zeta = other
+	}
+	rot := complex(k.pc, k.ps)
+	inv2 := 1 / (zeta * zeta)
+	dw := complex(k.u, 0)*(cmplx.Conj(rot)-complex(k.jr2, 0)*rot*inv2) + complex(0, k.jcirc)/zeta
+	w := dw / (1 - complex(k.jk2, 0)*inv2)
+	u, v := real(w), -imag(w)
+	// Back from the body frame, whose chord is (cos α, -sin α)
+	return u*k.pc + v*k.ps, -u*k.ps + v*k.pc, 0
+}
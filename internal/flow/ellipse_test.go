@@ -0,0 +1,66 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestEllipse(t *testing.T) {
+	run(t, (*checker).checkEllipse)
+}
+
+// checkEllipse verifies the elliptic cylinder: no flow through its surface
+// at incidence with the Kutta condition, a stagnation point at its
+// trailing end and a circulation of 4πU·(a + b)/2·sin α around it, the
+// circular cylinder's cross-flow for equal semi-axes at any alpha, and the
+// plate's flow away from the chord as the semi-minor axis goes to 0
+func (c *checker) checkEllipse() error {
+	f := checkFlow(flow.EllipticCylinder)
+	f.Object.SemiMinor, f.Object.Alpha, f.Object.Kutta = 0.4, 10*math.Pi/180, true
+	res, err := f.TangencyResidual(4096)
+	if err != nil {
+		return err
+	}
+	sin, cos := math.Sincos(f.Object.Alpha)
+	vx, vy, _ := f.VelocityAt(cos*(1+1e-9), -sin*(1+1e-9), 0)
+	stagnation := math.Hypot(vx, vy)
+	const n = 4096
+	gamma := 0.0
+	for i := 0; i < n; i++ {
+		st, ct := math.Sincos(2 * math.Pi * (float64(i) + 0.5) / n)
+		vx, vy, _ := f.VelocityAt(2*ct, 2*st, 0)
+		gamma += (-vx*st + vy*ct) * 2 * 2 * math.Pi / n
+	}
+	want := 4 * math.Pi * (1 + 0.4) / 2 * sin
+	circulation := math.Abs(-gamma-want) / want
+
+	circle, cylinder := checkFlow(flow.EllipticCylinder), checkFlow(flow.Cylinder)
+	circle.Object.Alpha = 0.3
+	plate, slender := checkFlow(flow.Plate), f
+	plate.Object.Alpha, plate.Object.EdgeRadius = f.Object.Alpha, 1e-12
+	slender.Object.SemiMinor = 1e-6
+	round, thin := 0.0, 0.0
+	for j := 0; j < 100; j++ {
+		x, y := float64(j%10)/2-2.2, float64(j/10)/2-2.3
+		if x*x+y*y <= 1.21 {
+			continue
+		}
+		ax, ay, _ := circle.VelocityAt(x, y, 0)
+		bx, by, _ := cylinder.VelocityAt(x, y, 0)
+		round = math.Max(round, math.Hypot(ax-bx, ay-by))
+		ax, ay, _ = slender.VelocityAt(x, y, 0)
+		bx, by, _ = plate.VelocityAt(x, y, 0)
+		thin = math.Max(thin, math.Hypot(ax-bx, ay-by))
+	}
+	o := f.Object
+	inside := o.Contains(0.99*cos, -0.99*sin, 5) && !o.Contains(1.01*cos, -1.01*sin, 0) &&
+		o.Contains(0.39*sin, 0.39*cos, 0) && !o.Contains(0.41*sin, 0.41*cos, 0)
+
+	ok := res.Max < tangencyTolerance && stagnation < kuttaTolerance && circulation < plateTolerance &&
+		round < ellipseTolerance && thin < slenderTolerance && inside
+	c.report("elliptic cylinder", ok, "max |v·n|/U %.3g; trailing end |v| %.3g; Γ relative %.3g; circle - cylinder %.3g; b → 0 - plate %.3g; inside %v",
+		res.Max, stagnation, circulation, round, thin, inside)
+	return nil
+}
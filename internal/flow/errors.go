@@ -0,0 +1,48 @@
+package flow
+
+import "fmt"
+
+// Error codes carried by *Error values
+const (
+	ErrBadArguments = "BAD_ARGUMENTS"
+	ErrBufferLength = "BUFFER_LENGTH"
+	ErrInternal     = "INTERNAL"
+	ErrCancelled    = "CANCELLED"
+	ErrUnsupported  = "UNSUPPORTED"
+	ErrLimit        = "LIMIT"
+	ErrStale        = "STALE"
+)
+
+// Error is an argument or state error with a machine-readable code
+type Error struct {
+	Code string
+	Msg  string
+}
+
+func (e *Error) Error() string { return e.Msg }
+
+// Errorf builds an *Error with the given code
+func Errorf(code string, format string, a ...interface{}) error {
+	return &Error{Code: code, Msg: fmt.Sprintf(format, a...)}
+}
+
+// CheckCount validates a particle count.
+func CheckCount(count int) error {
+	if count < 0 {
+		return Errorf(ErrBadArguments, "count must be non-negative, got %d", count)
+	}
+	return nil
+}
+
+// CheckBuffer verifies that a parallel array of length n holds at least
+// count*stride elements. Longer buffers are accepted and only the first
+// count*stride elements are read, so callers can reuse oversized pools.
+func CheckBuffer(name string, n int, count int, stride int) error {
+	if err := CheckCount(count); err != nil {
+		return err
+	}
+	if n < count*stride {
+		return Errorf(ErrBufferLength, "%s has %d elements, need %d for %d particles", name, n, count*stride, count)
+	}
+	return nil
+}
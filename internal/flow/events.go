@@ -0,0 +1,117 @@
+package flow
+
+// EventEnteredObject is the one simulation event: particles are never
+// respawned and the domain is unbounded, so a particle entering the object
+// is all a step can report. Names of events that can't occur aren't
+// accepted.
+const EventEnteredObject = "particleEnteredObject"
+
+// eventNames lists every event, in the order Drain reports them
+var eventNames = []string{EventEnteredObject}
+
+// Events returns the name of every event
+func Events() []string {
+	return append([]string{}, eventNames...)
+}
+
+// KnownEvent reports whether name is an event
+func KnownEvent(name string) bool {
+	for _, e := range eventNames {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxEventExamples is the number of particle indices kept per event
+const MaxEventExamples = 8
+
+// Event is the batch of one event type since the last Drain: how many
+// times it occurred and the particle indices of the first occurrences
+type Event struct {
+	Name     string
+	Count    int
+	Examples []int
+}
+
+// EventLog collects the events of Simulation steps while it is set as
+// Simulation.Events. The steps only count occurrences; nothing is reported
+// until the caller drains the log, so the particle loops stay free of
+// callbacks.
+type EventLog struct {
+	events []Event    // One per eventNames entry
+	inside []bool     // Whether each particle was inside the object before the step
+	from   []float32  // Positions before the step, for a plate
+	origin [3]float64 // Position of the plate before the step
+}
+
+// add records an occurrence of event name for particle i
+func (l *EventLog) add(name string, i int) {
+	if l.events == nil {
+		l.events = make([]Event, len(eventNames))
+	}
+	e := 0
+	for eventNames[e] != name {
+		e++
+	}
+	ev := &l.events[e]
+	ev.Count++
+	if len(ev.Examples) < MaxEventExamples {
+		ev.Examples = append(ev.Examples, i)
+	}
+}
+
+// before notes which particles are inside o before they move, or for a
+// plate, which has no inside, where they are
+func (l *EventLog) before(o *ObjectSpec, positions []float32, count int) {
+	if o.Type == Plate {
+		l.from = append(l.from[:0], positions[:count*3]...)
+		l.origin = [3]float64{o.X, o.Y, o.Z}
+		return
+	}
+	if cap(l.inside) < count {
+		l.inside = make([]bool, count)
+	}
+	l.inside = l.inside[:count]
+	for i := range l.inside {
+		l.inside[i] = o.Contains(float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2]))
+	}
+}
+
+// after records the particles that moved into o since before. A particle
+// stepping through a plate never ends up inside it; what counts is whether
+// it crossed the plate along its path relative to the plate, which may
+// have moved too.
+func (l *EventLog) after(o *ObjectSpec, positions []float32, count int) {
+	if o.Type == Plate {
+		d := [3]float64{o.X - l.origin[0], o.Y - l.origin[1], o.Z - l.origin[2]}
+		for i := 0; i < count; i++ {
+			p0 := [3]float64{float64(l.from[i*3]) + d[0], float64(l.from[i*3+1]) + d[1], float64(l.from[i*3+2]) + d[2]}
+			p1 := [3]float64{float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])}
+			if o.Crosses(p0, p1) {
+				l.add(EventEnteredObject, i)
+			}
+		}
+		return
+	}
+	for i := 0; i < count; i++ {
+		if !l.inside[i] && o.Contains(float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])) {
+			l.add(EventEnteredObject, i)
+		}
+	}
+}
+
+// Drain returns the events that occurred since the last call, in the
+// order of Events, and clears the log
+func (l *EventLog) Drain() []Event {
+	var out []Event
+	for i, ev := range l.events {
+		if ev.Count > 0 {
+			ev.Name = eventNames[i]
+			out = append(out, ev)
+		}
+		l.events[i] = Event{}
+	}
+	return out
+}
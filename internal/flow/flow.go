@@ -0,0 +1,1134 @@
+// Package flow implements the velocity potential kernels of the simulator.
+//
+// It has no dependency on syscall/js, so the math can be built and tested
+// on any platform; the WebAssembly bindings only marshal arguments into
+// the plain Go types used here.
+package flow
+
+import (
+	"fmt"
+	"math"
+)
+
+// ObjectType selects the body immersed in the flow
+type ObjectType int
+
+// Object types
+const (
+	Sphere           ObjectType = 0
+	Cylinder         ObjectType = 1
+	Airfoil          ObjectType = 2
+	Plate            ObjectType = 3
+	EllipticCylinder ObjectType = 4
+	Torus            ObjectType = 5
+	Box              ObjectType = 6
+)
+
+// objectTypeNames maps the names used in configuration files to types
+var objectTypeNames = map[string]ObjectType{
+	"sphere":           Sphere,
+	"cylinder":         Cylinder,
+	"airfoil":          Airfoil,
+	"plate":            Plate,
+	"ellipticCylinder": EllipticCylinder,
+	"torus":            Torus,
+	"box":              Box,
+}
+
+// known reports whether t is one of the object types
+func (t ObjectType) known() bool {
+	return t >= Sphere && t <= Box
+}
+
+// ParseObjectType looks up an object type by name
+func ParseObjectType(name string) (ObjectType, error) {
+	t, ok := objectTypeNames[name]
+	if !ok {
+		return 0, Errorf(ErrBadArguments, "unknown object type %q", name)
+	}
+	return t, nil
+}
+
+// String returns the configuration name of the type
+func (t ObjectType) String() string {
+	for name, v := range objectTypeNames {
+		if v == t {
+			return name
+		}
+	}
+	return fmt.Sprintf("ObjectType(%d)", int(t))
+}
+
+// Float is the element type of particle buffers. The kernels compute in
+// float64 either way; the type only sets the storage precision.
+type Float interface {
+	~float32 | ~float64
+}
+
+// ObjectSpec describes the body immersed in the flow
+type ObjectSpec struct {
+	Type    ObjectType
+	X, Y, Z float64 // Position of the object
+	Radius  float64 // Radius or characteristic length, half the chord of a plate, the semi-major axis of an elliptic cylinder, the major radius of a torus, the half diagonal of a box; 0 means no object
+
+	// Strengths overrides the strengths of the singularities the object
+	// solution is built from, which by default follow from Radius and the
+	// free stream speed
+	Strengths Strengths
+
+	// CollisionRadius, if non-zero, replaces Radius in the inside-body
+	// test, for bodies whose Strengths no longer match Radius
+	CollisionRadius float64
+
+	// Alpha is the angle of attack of a plate or elliptic cylinder in
+	// radians: its chord runs along (cos α, -sin α, 0) from the leading to
+	// the trailing edge, so a positive α pitches it nose up into a free
+	// stream along +x. The incidence the flow sees also turns with the
+	// cross-flow direction. It turns a box's first edge the same way.
+	Alpha float64
+
+	// EdgeRadius is the radius over which the velocity singularity at a
+	// plate's leading edge is regularized; 0 means DefaultEdgeFraction of
+	// the chord. For a box it rounds the flow round its edges instead; 0
+	// means DefaultBoxEdgeFraction of its shortest edge.
+	EdgeRadius float64
+
+	// SemiMinor is the semi-axis of an elliptic cylinder normal to its
+	// chord, at most Radius; 0 means Radius, a circle
+	SemiMinor float64
+
+	// Kutta sets the circulation of an elliptic cylinder so the flow
+	// leaves its trailing end smoothly; without it the circulation is 0
+	Kutta bool
+
+	// MinorRadius is the radius of the tube of a torus, whose axis is x
+	// and whose tube's center line has radius Radius; 0 means
+	// DefaultTubeFraction of Radius
+	MinorRadius float64
+
+	// Collocation is the number of points the ring sources of a torus are
+	// fitted at (see TorusFit); 0 means DefaultTorusCollocation
+	Collocation int
+
+	// Size holds the edge lengths of a box along its axes, which Alpha
+	// turns about z, scaled so its half diagonal is Radius; 0 means a cube
+	Size [3]float64
+
+	// Panels is the number of source panels along the longest edge of a
+	// box; 0 means DefaultBoxPanels
+	Panels int
+
+	// Splitter is the length of a flat splitter plate attached to the
+	// rear of a cylinder, along its x axis from its surface, which no
+	// flow crosses and particles don't pass; 0 means none. The Kutta
+	// condition at its tip sets the cylinder's circulation, so it takes
+	// no strength overrides.
+	Splitter float64
+}
+
+// Strengths holds explicit singularity strengths of an object. Only the
+// strengths whose bit is in Set are used; the others keep their defaults:
+//
+//	doublet:     2πU·R³ for spheres, 2πU·R² per unit span otherwise
+//	source:      0
+//	circulation: 4πU·R for airfoils, 0 for cylinders
+//
+// Cylinder and airfoil strengths are per unit span. The circulation is
+// about +z, and spheres have none. An airfoil's circulation enters its
+// lift model, Γ·sin θ, rather than a plain vortex.
+type Strengths struct {
+	Doublet     float64 // Doublet moment μ
+	Source      float64 // Source strength, the volume flux it emits
+	Circulation float64 // Circulation Γ
+	Set         int     // Bit mask of StrengthDoublet, StrengthSource and StrengthCirculation
+}
+
+// Bits of Strengths.Set
+const (
+	StrengthDoublet = 1 << iota
+	StrengthSource
+	StrengthCirculation
+)
+
+// bodyRadius returns the radius of the inside-body test
+func (o *ObjectSpec) bodyRadius() float64 {
+	if o.CollisionRadius != 0 {
+		return o.CollisionRadius
+	}
+	return o.Radius
+}
+
+// validateStrengths checks the strength overrides and collision radius
+func (o *ObjectSpec) validateStrengths() error {
+	st := o.Strengths
+	if st.Set&^(StrengthDoublet|StrengthSource|StrengthCirculation) != 0 {
+		return Errorf(ErrBadArguments, "unknown strength bits %#x", st.Set)
+	}
+	for _, x := range []float64{st.Doublet, st.Source, st.Circulation} {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "object strengths must be finite, got %+v", st)
+		}
+	}
+	if o.Type == Sphere && st.Set&StrengthCirculation != 0 && st.Circulation != 0 {
+		return Errorf(ErrBadArguments, "a sphere has no circulation")
+	}
+	if r := o.CollisionRadius; r < 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+		return Errorf(ErrBadArguments, "collisionRadius must be non-negative and finite, got %g", r)
+	}
+	return nil
+}
+
+// planar reports whether the object is a 2D section extending infinitely
+// along z: a cylinder, airfoil, plate or elliptic cylinder
+func (o *ObjectSpec) planar() bool {
+	return o.Type == Cylinder || o.Type == Airfoil || o.Type == Plate || o.Type == EllipticCylinder
+}
+
+// distance2 returns the squared distance of (px, py, pz) from the object:
+// from its center for spheres and from its axis for 2D sections
+func (o *ObjectSpec) distance2(px, py, pz float64) float64 {
+	x, y := px-o.X, py-o.Y
+	d2 := x*x + y*y
+	if !o.planar() {
+		z := pz - o.Z
+		d2 += z * z
+	}
+	return d2
+}
+
+// Flow holds the parameters of a velocity evaluation
+type Flow struct {
+	FreeStream float64    // Free stream speed
+	Direction  [3]float64 // Unit free stream direction; zero means +x
+	Density    float64    // Fluid density
+	Object     ObjectSpec
+
+	// Cutoff, if non-zero, is the distance from the object in radii beyond
+	// which the disturbance is neglected and points get the free stream
+	// velocity: the distance from the center for spheres and from the axis
+	// for cylinders and airfoils. See CutoffFor and CutoffError.
+	Cutoff float64
+
+	// Frame is the reference frame velocities are given in, FrameBody
+	// if empty
+	Frame string
+
+	// Arithmetic is that of the velocity kernels, ArithmeticAccurate if
+	// empty; ArithmeticFast evaluates the flows it covers in float32
+	Arithmetic string
+
+	// Profile makes the free stream speed vary with height, FreeStream
+	// being the speed at the profile's reference height, or the unit of
+	// the band speeds of a layered profile. The object
+	// solutions only exist for a uniform onset, so they are evaluated for
+	// the onset speed at the object's center and the difference of the
+	// background from it, U(y) - U(object y) along the stream, is added
+	// outside the object. This superposition is an approximation: it isn't
+	// a potential flow, the disturbance doesn't react to the profile and
+	// the surface is no longer exactly a streamline, but the background the
+	// particles are advected with is exact far away, and a uniform profile
+	// is the uniform flow. Near the object the disturbance is not damped:
+	// below a boundary layer's floor the velocity is only zero where it has
+	// decayed. Pressures, from Bernoulli's equation with the center onset
+	// as reference, carry a spurious ½ρ(U_center² - U(y)²) away from the
+	// object.
+	Profile Profile
+
+	// Swirl adds a tangential component about the free stream axis through
+	// the object's center to the onset flow, outside the object, so
+	// particles corkscrew past it. Like a Profile it is superimposed on
+	// the disturbance of the uniform onset, which doesn't react to it.
+	// Bernoulli's equation alone gives the swirl's pressure only where it
+	// is irrotational; AddSwirlPressures corrects the rest from the
+	// particle positions.
+	Swirl Swirl
+
+	// Motion is the instantaneous velocity of a prescribed motion of the
+	// object (see Motion), on top of its drift in the lab frame. The
+	// kernels see the onset flow relative to the moving body.
+	Motion [3]float64
+
+	// Elements, if set, replaces the object solution by the superposition
+	// of its elementary flows on the onset flow. The object then only
+	// bounds the region of zero velocity, and the far-field cutoff,
+	// measured from it, is off. Preset returns the elements of the object
+	// solution.
+	Elements *Superposition
+
+	// Boundary bounds the fluid from above by a wall or free surface,
+	// imaging the disturbance in it. It needs a sphere below the plane and
+	// an onset flow parallel to it, and turns the far-field cutoff off.
+	Boundary Boundary
+
+	// Channel confines the fluid to a converging-diverging channel, the
+	// free stream speed being its inlet speed. It needs a free stream
+	// along +x and the object between its walls, and turns the far-field
+	// cutoff off.
+	Channel Channel
+
+	// Bump bounds the fluid from below by a wall along y = 0 with a
+	// Gaussian bump on it. It needs a free stream along +x and no object,
+	// and turns the far-field cutoff off.
+	Bump Bump
+
+	// Rotor blows the downwash of a hovering rotor through fluid at rest,
+	// with FreeStream 0 and no object, and turns the far-field cutoff off.
+	Rotor Rotor
+}
+
+// Reference frames. In the body frame the object is at rest and the fluid
+// streams past it; in the lab frame the fluid is at rest far away and the
+// object moves through it at minus the free stream velocity, so velocities
+// are the disturbance alone. The two differ by a Galilean transform.
+const (
+	FrameBody = "body"
+	FrameLab  = "lab"
+)
+
+// CheckFrame rejects unknown reference frames; the empty string is the
+// body frame
+func CheckFrame(frame string) error {
+	if frame != "" && frame != FrameBody && frame != FrameLab {
+		return Errorf(ErrBadArguments, "frame must be %q or %q, got %q", FrameBody, FrameLab, frame)
+	}
+	return nil
+}
+
+// CheckDensity rejects negative fluid densities.
+func CheckDensity(density float64) error {
+	if density < 0 || math.IsNaN(density) {
+		return Errorf(ErrBadArguments, "fluidDensity must be non-negative, got %g", density)
+	}
+	return nil
+}
+
+// Validate checks the flow parameters. A radius of zero is valid and means
+// "no object": the flow is the undisturbed free stream. A free stream of
+// zero is also valid and yields a fluid at rest. Unknown object types are
+// rejected rather than evaluated as the free stream.
+func (f *Flow) Validate() error {
+	if t := f.Object.Type; !t.known() {
+		return Errorf(ErrBadArguments, "unknown object type %d", int(t))
+	}
+	if err := CheckDensity(f.Density); err != nil {
+		return err
+	}
+	if r := f.Object.Radius; r < 0 || math.IsNaN(r) {
+		return Errorf(ErrBadArguments, "objectRadius must be non-negative, got %g", r)
+	}
+	if err := f.Object.validateStrengths(); err != nil {
+		return err
+	}
+	if err := f.Object.validatePlate(); err != nil {
+		return err
+	}
+	if err := f.Object.validateEllipse(); err != nil {
+		return err
+	}
+	if err := f.validateTorus(); err != nil {
+		return err
+	}
+	if err := f.Object.validateBox(); err != nil {
+		return err
+	}
+	if err := f.Object.validateSplitter(); err != nil {
+		return err
+	}
+	if d := f.Direction; !f.aligned() {
+		if n := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2]); math.Abs(n-1) > 1e-9 {
+			return Errorf(ErrBadArguments, "free stream direction must be a unit vector, got %v", d)
+		}
+	}
+	if c := f.Cutoff; c != 0 && !(c > 1) {
+		return Errorf(ErrBadArguments, "farFieldCutoff must be 0 (off) or more than 1 radius, got %g", c)
+	}
+	for _, x := range f.Motion {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "object velocity must be finite, got %v", f.Motion)
+		}
+	}
+	if err := f.Profile.Validate(); err != nil {
+		return err
+	}
+	if err := f.Swirl.Validate(); err != nil {
+		return err
+	}
+	if err := f.validateBoundary(); err != nil {
+		return err
+	}
+	if err := f.validateChannel(); err != nil {
+		return err
+	}
+	if err := f.validateBump(); err != nil {
+		return err
+	}
+	if err := f.validateRotor(); err != nil {
+		return err
+	}
+	if err := CheckArithmetic(f.Arithmetic); err != nil {
+		return err
+	}
+	return CheckFrame(f.Frame)
+}
+
+// freeVelocity returns the free stream velocity vector, at the object's
+// center under a Profile
+func (f *Flow) freeVelocity() [3]float64 {
+	d := f.direction()
+	u := f.Profile.Speed(f.FreeStream, f.Object.Y)
+	return [3]float64{u * d[0], u * d[1], u * d[2]}
+}
+
+// direction returns the unit free stream direction
+func (f *Flow) direction() [3]float64 {
+	if f.aligned() {
+		return [3]float64{1, 0, 0}
+	}
+	return f.Direction
+}
+
+// ObjectVelocity returns the velocity of the object in the frame of f:
+// Motion, minus the free stream in the lab frame
+func (f *Flow) ObjectVelocity() [3]float64 {
+	v := f.Motion
+	if f.Frame == FrameLab {
+		u := f.freeVelocity()
+		v = [3]float64{v[0] - u[0], v[1] - u[1], v[2] - u[2]}
+	}
+	return v
+}
+
+// relative returns the velocity to add to velocities in the frame of f to
+// make them relative to the object, minus ObjectVelocity
+func (f *Flow) relative() [3]float64 {
+	v := f.ObjectVelocity()
+	return [3]float64{-v[0], -v[1], -v[2]}
+}
+
+// onset returns f with the free stream replaced by the uniform onset flow
+// relative to the object, the free stream at its center less Motion, as
+// seen in the body frame
+func (f *Flow) onset() Flow {
+	g := *f
+	if !f.Profile.Uniform() {
+		g.FreeStream, g.Profile = f.Profile.Speed(f.FreeStream, f.Object.Y), Profile{}
+	}
+	if f.Motion == [3]float64{} {
+		return g
+	}
+	u := f.freeVelocity()
+	w := [3]float64{u[0] - f.Motion[0], u[1] - f.Motion[1], u[2] - f.Motion[2]}
+	n := math.Sqrt(w[0]*w[0] + w[1]*w[1] + w[2]*w[2])
+	g.FreeStream, g.Direction, g.Motion = n, [3]float64{}, [3]float64{}
+	if n > 0 {
+		g.Direction = [3]float64{w[0] / n, w[1] / n, w[2] / n}
+	}
+	return g
+}
+
+// relative2 returns the squared speed relative to the object of velocity
+// (vx, vy, vz), given in the frame of f: what Bernoulli's equation takes in
+// either frame (see PressuresFor). onset2 is the squared onset speed, its
+// value far away.
+func (f *Flow) relative2(vx, vy, vz float64) float64 {
+	o := f.relative()
+	vx, vy, vz = vx+o[0], vy+o[1], vz+o[2]
+	return vx*vx + vy*vy + vz*vz
+}
+
+func (f *Flow) onset2() float64 {
+	g := f.onset()
+	return g.FreeStream * g.FreeStream
+}
+
+// aligned reports whether the free stream runs along +x, the frame the
+// object solutions are written in
+func (f *Flow) aligned() bool {
+	d := f.Direction
+	return d == [3]float64{} || d == [3]float64{1, 0, 0}
+}
+
+// Contains reports whether point (px, py, pz) is inside the object, where
+// the velocity is zero. Cylinders and airfoil sections extend infinitely
+// along z. A plate has no thickness, hence no inside; Crosses detects the
+// particles passing through it.
+func (o *ObjectSpec) Contains(px, py, pz float64) bool {
+	if o.Radius == 0 || o.Type == Plate {
+		return false
+	}
+	r := o.bodyRadius()
+	x, y, z := px-o.X, py-o.Y, pz-o.Z
+	switch o.Type {
+	case Cylinder, Airfoil:
+		return math.Sqrt(x*x+y*y) <= r
+	case EllipticCylinder:
+		return o.insideEllipse(x, y)
+	case Torus:
+		return o.insideTorus(x, y, z)
+	case Box:
+		return o.insideBox(x, y, z)
+	}
+	return math.Sqrt(x*x+y*y+z*z) <= r
+}
+
+// VelocityAt returns the velocity at point (px, py, pz). Loops over many
+// points should prepare the flow once with kernel instead.
+func (f *Flow) VelocityAt(px, py, pz float64) (vx, vy, vz float64) {
+	k := f.kernel()
+	return k.velocityAt(px, py, pz)
+}
+
+// Frames of a kernel
+const (
+	frameAligned   = iota // Free stream along +x: no rotation
+	frameCrossFlow        // Cylinder, airfoil, plate or elliptic cylinder: rotation about z plus an axial flow
+	frameRotated          // Sphere: full orthonormal frame
+)
+
+// kernel is a Flow prepared for evaluation: everything that depends only
+// on the flow parameters, not on the point, is computed once here so the
+// particle loops only do per-point work
+type kernel struct {
+	obj  ObjectSpec
+	mode int
+
+	u      float64 // Free stream speed along +x of the object frame
+	rho    float64
+	r2     float64 // objectRadius²
+	r3     float64 // objectRadius³
+	pRef   float64 // 0.5*u²
+	circ   float64 // u*4π*objectRadius, the airfoil circulation scale
+	vortex float64 // circ/2π, the airfoil vortex strength; Γ/2π with Strengths
+	body   float64 // Radius of the inside-body test
+
+	strengths bool    // Whether Strengths override the defaults; see superposed
+	doublet   float64 // μ/4π for spheres, μ/2π otherwise
+	source    float64 // m/4π for spheres, m/2π otherwise
+
+	elements []compiledElement // Flow.Elements; see superposition
+
+	c, s, axial float64       // frameCrossFlow rotation and axial speed
+	pc, ps      float64       // Cosine and sine of a plate's incidence on the cross-flow
+	edge2       float64       // Squared regularization radius of a plate's leading edge, or in radii of a box's edges
+	jr2, jk2    float64       // Squared circle radius and k² of an elliptic cylinder's Joukowski map
+	jcirc       float64       // Γ/2π of an elliptic cylinder, clockwise
+	rings       [][3]float64  // Axial position, radius and volume flux of the ring sources of a torus
+	panels      []boxPanel    // Source panels of a box, in radii
+	sigma       []float64     // Their strengths
+	boxMap      [3][3]float64 // Components of the kernel frame axes along the box axes
+	split       bool          // Whether a cylinder has a splitter plate
+	splitDir    [2]float64    // Its direction in the cross-flow frame
+	splitMid    float64       // Center of the plate the cylinder and splitter map to
+	splitHalf   float64       // Its half-length
+	splitCirc   float64       // Γ/2π of a cylinder with a splitter, clockwise
+	e           [3][3]float64 // frameRotated axes
+
+	cut2 float64    // Squared far-field cutoff distance; 0 if off
+	free [3]float64 // Onset velocity, returned beyond the cutoff
+
+	shift  [3]float64 // Object velocity, added to every velocity
+	moving bool       // Whether shift is non-zero
+
+	profile Profile    // Flow.Profile, if not uniform
+	u0, uc  float64    // Free stream speed at the reference height and the object's center
+	dir     [3]float64 // Unit free stream direction, along which the profile acts
+
+	swirl Swirl   // Flow.Swirl
+	su    float64 // Free stream speed at the object's center, which the swirl scales with
+
+	image   float64 // Sign of the boundary image, 1 for a wall, -1 for a free surface; 0 if none
+	surface float64 // Height of the boundary plane
+
+	channel Channel // Flow.Channel
+	cu      float64 // Its inlet speed; u is its speed at the object's center
+
+	bump         Bump          // Flow.Bump
+	bumpSolution *bumpSolution // Its panels, nil for a plain wall
+
+	rotor      Rotor       // Flow.Rotor
+	rotorRings []rotorRing // Its wake
+	rotorVi    float64     // Its induced velocity
+
+	lean *leanKernel[float32] // The kernel in float32 under ArithmeticFast, nil if it doesn't cover the flow
+}
+
+// kernel prepares f for evaluation
+func (f *Flow) kernel() kernel {
+	shift := f.ObjectVelocity()
+	profile, u0, dir := f.Profile, f.FreeStream, f.direction()
+	swirl, su := f.Swirl, f.Profile.Speed(f.FreeStream, f.Object.Y)
+	g := f.onset()
+	f = &g
+	k := kernel{obj: f.Object, u: f.FreeStream, rho: f.Density}
+	if f.Channel.enabled() {
+		k.channel, k.cu = f.Channel, f.FreeStream
+		k.u = f.Channel.speed(f.FreeStream, f.Object.X)
+	}
+	if !f.aligned() {
+		d := f.Direction
+		switch f.Object.Type {
+		case Cylinder, Airfoil, Plate, EllipticCylinder:
+			// Split the free stream into the cross-flow, rotated about the
+			// z axis into +x, and the axial flow, which an infinite body
+			// doesn't disturb
+			dxy := math.Sqrt(d[0]*d[0] + d[1]*d[1])
+			k.mode, k.c, k.s = frameCrossFlow, 1, 0
+			if dxy != 0 {
+				k.c, k.s = d[0]/dxy, d[1]/dxy
+			}
+			k.u, k.axial = f.FreeStream*dxy, f.FreeStream*d[2]
+		default:
+			// Work in an orthonormal frame whose first axis is the free
+			// stream
+			k.mode, k.e = frameRotated, frame(d)
+		}
+	}
+	r := f.Object.Radius
+	k.r2, k.r3 = r*r, r*r*r
+	switch f.Object.Type {
+	case Cylinder:
+		k.splitterFrame()
+	case Plate:
+		k.plateFrame()
+	case EllipticCylinder:
+		k.ellipseFrame()
+	case Torus:
+		k.torusFrame()
+	case Box:
+		k.boxFrame()
+	}
+	k.pRef = 0.5 * k.u * k.u
+	k.circ = k.u * 4 * math.Pi * r
+	k.vortex = k.circ / (2 * math.Pi)
+	k.body = f.Object.bodyRadius()
+	k.doublet = k.u * k.r2
+	if f.Object.Type == Sphere {
+		k.doublet = 0.5 * k.u * k.r3
+	}
+	if st := f.Object.Strengths; st.Set != 0 {
+		k.strengths = true
+		scale := 2 * math.Pi
+		switch f.Object.Type {
+		case Sphere:
+			scale, k.vortex = 4*math.Pi, 0
+		case Cylinder:
+			k.vortex = 0
+		}
+		if st.Set&StrengthDoublet != 0 {
+			k.doublet = st.Doublet / scale
+		}
+		if st.Set&StrengthSource != 0 {
+			k.source = st.Source / scale
+		}
+		if st.Set&StrengthCirculation != 0 {
+			k.vortex = st.Circulation / (2 * math.Pi)
+		}
+	}
+	k.image, k.surface = f.Boundary.image(), f.Boundary.Height
+	k.bumpFrame(f.Bump)
+	k.rotorFrame(f.Rotor, f.Density)
+	if f.Cutoff > 0 && f.Elements == nil && k.image == 0 && !k.channel.enabled() && !k.bump.enabled() && !k.rotor.enabled() {
+		k.cut2 = f.Cutoff * r * f.Cutoff * r
+	}
+	if f.Elements != nil {
+		k.elements = f.Elements.compiled
+	}
+	k.free = f.freeVelocity()
+	k.shift, k.moving = shift, shift != [3]float64{}
+	k.dir = dir
+	if !profile.Uniform() {
+		k.profile, k.u0, k.uc = profile, u0, profile.Speed(u0, f.Object.Y)
+	}
+	k.swirl, k.su = swirl, su
+	if f.Arithmetic == ArithmeticFast {
+		k.lean = lean[float32](&k)
+	}
+	return k
+}
+
+// far reports whether (px, py, pz) is beyond the far-field cutoff
+func (k *kernel) far(px, py, pz float64) bool {
+	return k.cut2 != 0 && k.obj.distance2(px, py, pz) > k.cut2
+}
+
+// velocityAt returns the velocity at point (px, py, pz)
+func (k *kernel) velocityAt(px, py, pz float64) (vx, vy, vz float64) {
+	if k.lean != nil {
+		x, y, z, _ := k.lean.velocity(float32(px), float32(py), float32(pz))
+		return float64(x), float64(y), float64(z)
+	}
+	if k.far(px, py, pz) {
+		vx, vy, vz = k.free[0], k.free[1], k.free[2]
+	} else {
+		vx, vy, vz = k.exact(px, py, pz)
+	}
+	return k.transform(k.overlaid(px, py, pz, vx, vy, vz))
+}
+
+// overlaid adds the flows laid over the object solution, the
+// channel, bump, rotor, profile and swirl, to (vx, vy, vz) at (px, py, pz)
+func (k *kernel) overlaid(px, py, pz, vx, vy, vz float64) (float64, float64, float64) {
+	vx, vy, vz = k.channeled(px, py, pz, vx, vy, vz)
+	vx, vy, vz = k.bumped(px, py, pz, vx, vy, vz)
+	vx, vy, vz = k.rotored(px, py, pz, vx, vy, vz)
+	vx, vy, vz = k.profiled(px, py, pz, vx, vy, vz)
+	return k.swirled(px, py, pz, vx, vy, vz)
+}
+
+// profiled adds the background's difference from the onset at the
+// object's center to (vx, vy, vz), evaluated at (px, py, pz), outside the
+// object
+func (k *kernel) profiled(px, py, pz, vx, vy, vz float64) (float64, float64, float64) {
+	if k.profile.Uniform() || k.excluded(px, py, pz) {
+		return vx, vy, vz
+	}
+	du := k.profile.Speed(k.u0, py) - k.uc
+	if k.profile.Type == ProfileShear {
+		// Exactly the same value, without the cancellation
+		du = k.profile.Rate * (py - k.obj.Y)
+	}
+	return vx + du*k.dir[0], vy + du*k.dir[1], vz + du*k.dir[2]
+}
+
+// swirled adds the swirl to (vx, vy, vz), evaluated at (px, py, pz),
+// outside the object
+func (k *kernel) swirled(px, py, pz, vx, vy, vz float64) (float64, float64, float64) {
+	if k.swirl.Number == 0 || k.excluded(px, py, pz) {
+		return vx, vy, vz
+	}
+	x, y, z := k.axisOffset(px, py, pz)
+	d := k.dir
+	w := k.swirl.rate(math.Sqrt(x*x+y*y+z*z), k.su)
+	return vx + w*(d[1]*z-d[2]*y), vy + w*(d[2]*x-d[0]*z), vz + w*(d[0]*y-d[1]*x)
+}
+
+// axisOffset returns the offset of (px, py, pz) from the free stream axis
+// through the object's center, perpendicular to it
+func (k *kernel) axisOffset(px, py, pz float64) (x, y, z float64) {
+	x, y, z = px-k.obj.X, py-k.obj.Y, pz-k.obj.Z
+	d := k.dir
+	a := x*d[0] + y*d[1] + z*d[2]
+	return x - a*d[0], y - a*d[1], z - a*d[2]
+}
+
+// axisDistance returns the distance of (px, py, pz) from the free stream
+// axis through the object's center
+func (k *kernel) axisDistance(px, py, pz float64) float64 {
+	x, y, z := k.axisOffset(px, py, pz)
+	return math.Sqrt(x*x + y*y + z*z)
+}
+
+// transform takes a velocity relative to the object to the frame of the
+// kernel
+func (k *kernel) transform(vx, vy, vz float64) (float64, float64, float64) {
+	if k.moving {
+		return vx + k.shift[0], vy + k.shift[1], vz + k.shift[2]
+	}
+	return vx, vy, vz
+}
+
+// excluded reports whether (px, py, pz) is outside the fluid: inside the
+// object, above the boundary plane, beyond the channel walls, below the
+// bump's wall or below the rotor's floor
+func (k *kernel) excluded(px, py, pz float64) bool {
+	return k.image != 0 && pz > k.surface || k.channel.outside(px, py) || k.bump.under(px, py) ||
+		k.rotor.belowFloor(pz) || k.obj.Contains(px, py, pz)
+}
+
+// exact is velocityAt without the far-field cutoff
+func (k *kernel) exact(px, py, pz float64) (vx, vy, vz float64) {
+	if k.image != 0 {
+		return k.imaged(px, py, pz)
+	}
+	return k.direct(px, py, pz)
+}
+
+// direct is exact without the boundary image
+func (k *kernel) direct(px, py, pz float64) (vx, vy, vz float64) {
+	if k.elements != nil {
+		return k.superposition(px, py, pz)
+	}
+
+	// Position relative to object
+	x := px - k.obj.X
+	y := py - k.obj.Y
+	z := pz - k.obj.Z
+
+	switch k.mode {
+	case frameCrossFlow:
+		if k.obj.Contains(px, py, pz) {
+			return 0, 0, 0
+		}
+		lx, ly := intoCrossFlow(k.c, k.s, x, y)
+		lx, ly, lz := k.local(lx, ly, z)
+		vx, vy = outOfCrossFlow(k.c, k.s, lx, ly)
+		return vx, vy, lz + k.axial
+
+	case frameRotated:
+		lx, ly, lz := k.local(intoFrame(&k.e, x, y, z))
+		return outOfFrame(&k.e, lx, ly, lz)
+	}
+	return k.local(x, y, z)
+}
+
+// local evaluates the object solution at (x, y, z) relative to the object
+// center, for the free stream of speed k.u along +x
+func (k *kernel) local(x, y, z float64) (vx, vy, vz float64) {
+	objectRadius := k.obj.Radius
+	freeStreamVelocity := k.u
+
+	// No object: keep the free stream
+	if objectRadius == 0 {
+		return freeStreamVelocity, 0, 0
+	}
+	if k.strengths {
+		return k.superposed(x, y, z)
+	}
+
+	switch k.obj.Type {
+	case Sphere:
+		// Velocity potential flow around sphere
+		r2 := x*x + y*y + z*z
+		r := math.Sqrt(r2)
+		if r <= k.body {
+			// Inside object, zero velocity
+			return 0, 0, 0
+		}
+		vx, vy, vz = sphereFlow(freeStreamVelocity, k.r3, r2, r, x, y, z)
+
+	case Cylinder:
+		// Velocity potential flow around cylinder (2D in XY plane)
+		rxy2 := x*x + y*y
+		if math.Sqrt(rxy2) <= k.body {
+			// Inside the cylinder
+			return 0, 0, 0
+		}
+		if k.split {
+			vx, vy = k.splitter(x, y)
+		} else {
+			vx, vy = cylinderFlow(freeStreamVelocity, k.r2, rxy2, x, y)
+		}
+		vz = cylinderSpan(k.rho, k.pRef, vx, vy, z)
+
+	case Plate:
+		return k.plate(x, y)
+
+	case EllipticCylinder:
+		return k.ellipse(x, y)
+
+	case Torus:
+		return k.torus(x, y, z)
+
+	case Box:
+		return k.box(x, y, z)
+
+	case Airfoil:
+		// Simplified airfoil model using doublet and vortex
+		rxy2 := x*x + y*y
+		if math.Sqrt(rxy2) <= k.body {
+			// Inside airfoil
+			return 0, 0, 0
+		}
+		vx, vy = airfoilFlow(freeStreamVelocity, k.r2, k.vortex, rxy2, x, y)
+
+		// The section is 2D: there is no spanwise (z) flow
+		vz = 0
+
+	default:
+		if math.Sqrt(x*x+y*y+z*z) <= k.body {
+			return 0, 0, 0
+		}
+		vx = freeStreamVelocity
+	}
+	return vx, vy, vz
+}
+
+// superposed is local for an object whose Strengths override the
+// defaults: the free stream plus a doublet along it and a source, and
+// for cylinders and airfoils a vortex, of the strengths of k. With the
+// default strengths it is the object solution of local, up to rounding.
+func (k *kernel) superposed(x, y, z float64) (vx, vy, vz float64) {
+	u := k.u
+	if k.obj.Type == Sphere {
+		r2 := x*x + y*y + z*z
+		r := math.Sqrt(r2)
+		if r <= k.body {
+			return 0, 0, 0
+		}
+		// ∇(μ/4π·x/r³) and m/4π·r̂/r²
+		r3 := r2 * r
+		d, s := k.doublet/r3, k.source/r3
+		c := 3 * d * x / r2
+		return u + d - c*x + s*x, -c*y + s*y, -c*z + s*z
+	}
+
+	rxy2 := x*x + y*y
+	if math.Sqrt(rxy2) <= k.body {
+		return 0, 0, 0
+	}
+	// ∇(μ/2π·x/r²) and m/2π·r̂/r
+	d, s := k.doublet/rxy2, k.source/rxy2
+	c := 2 * d / rxy2
+	vx = u + d - c*x*x + s*x
+	vy = -c*x*y + s*y
+	switch k.obj.Type {
+	case Cylinder:
+		// A vortex Γ/2π·θ̂/r, and the z adjustment of local
+		vx -= k.vortex * y / rxy2
+		vy += k.vortex * x / rxy2
+		vz = z * k.rho * (k.pRef - 0.5*(vx*vx+vy*vy)) * 0.01
+	case Airfoil:
+		// The lift model of local, Γ·sin θ
+		vy += k.vortex * y / rxy2
+	}
+	return vx, vy, vz
+}
+
+// Velocities evaluates the velocity of count particles.
+//
+// positions holds [x1,y1,z1,x2,y2,z2,...] and must contain at least
+// count*3 values; any extra trailing data is ignored. The result holds
+// count*3 velocity components in the same layout.
+func Velocities(positions []float32, count int, f Flow) ([]float32, error) {
+	if err := CheckCount(count); err != nil {
+		return nil, err
+	}
+	result := make([]float32, count*3)
+	if err := VelocitiesInto(result, positions, count, f); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// VelocitiesInto is Velocities writing into dst, which must hold at least
+// count*3 values. Positions and velocities may use different precisions.
+// Large batches are split across Workers goroutines.
+func VelocitiesInto[Out, In Float](dst []Out, positions []In, count int, f Flow) error {
+	_, err := VelocitiesIntoCounted(dst, positions, count, f)
+	return err
+}
+
+// VelocitiesIntoCounted is VelocitiesInto also returning how many
+// particles were beyond the far-field cutoff
+func VelocitiesIntoCounted[Out, In Float](dst []Out, positions []In, count int, f Flow) (far int, err error) {
+	return VelocitiesSpeedsInto(dst, nil, positions, count, f)
+}
+
+// VelocitiesSpeedsInto is VelocitiesIntoCounted also writing the speed of
+// each particle to speeds in the same pass. speeds may be nil; otherwise it
+// must hold at least count values.
+func VelocitiesSpeedsInto[Out, In Float](dst []Out, speeds []float32, positions []In, count int, f Flow) (far int, err error) {
+	return VelocitiesMasked(dst, speeds, positions, count, f, Mask{})
+}
+
+// VelocitiesMasked is VelocitiesSpeedsInto evaluating only the particles
+// active in m
+func VelocitiesMasked[Out, In Float](dst []Out, speeds []float32, positions []In, count int, f Flow, m Mask) (far int, err error) {
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return 0, err
+	}
+	if err := CheckBuffer("output", len(dst), count, 3); err != nil {
+		return 0, err
+	}
+	if speeds != nil {
+		if err := CheckBuffer("speeds", len(speeds), count, 1); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.check(count); err != nil {
+		return 0, err
+	}
+	if err := f.Validate(); err != nil {
+		return 0, err
+	}
+
+	k := f.kernel()
+	if chunks(count) <= 1 {
+		return velocitiesRange(dst, speeds, positions, 0, count, &k, &m), nil
+	}
+	return parallel(count, func(from, to int) (int, error) {
+		return velocitiesRange(dst, speeds, positions, from, to, &k, &m), nil
+	})
+}
+
+// velocitiesRange evaluates particles [from, to), and their speeds unless
+// speeds is nil, returning how many were beyond the far-field cutoff
+func velocitiesRange[Out, In Float](dst []Out, speeds []float32, positions []In, from, to int, k *kernel, m *Mask) (far int) {
+	for i := from; i < to; i++ {
+		idx := i * 3
+		if m.skip(i) {
+			if m.Zero {
+				dst[idx], dst[idx+1], dst[idx+2] = 0, 0, 0
+				if speeds != nil {
+					speeds[i] = 0
+				}
+			}
+			continue
+		}
+		if k.lean != nil {
+			vx, vy, vz, out := k.lean.velocity(float32(positions[idx]), float32(positions[idx+1]), float32(positions[idx+2]))
+			if out {
+				far++
+			}
+			dst[idx], dst[idx+1], dst[idx+2] = Out(vx), Out(vy), Out(vz)
+			if speeds != nil {
+				speeds[i] = sqrt(vx*vx + vy*vy + vz*vz)
+			}
+			continue
+		}
+		px, py, pz := float64(positions[idx]), float64(positions[idx+1]), float64(positions[idx+2])
+		var vx, vy, vz float64
+		if k.far(px, py, pz) {
+			vx, vy, vz = k.free[0], k.free[1], k.free[2]
+			far++
+		} else {
+			vx, vy, vz = k.exact(px, py, pz)
+		}
+		vx, vy, vz = k.transform(k.overlaid(px, py, pz, vx, vy, vz))
+		dst[idx] = Out(vx)
+		dst[idx+1] = Out(vy)
+		dst[idx+2] = Out(vz)
+		if speeds != nil {
+			speeds[i] = float32(math.Sqrt(vx*vx + vy*vy + vz*vz))
+		}
+	}
+	return far
+}
+
+// Pressures calculates the pressure of count particles from their
+// velocities using Bernoulli's equation. velocities must contain at least
+// count*3 values; any extra trailing data is ignored.
+func Pressures(velocities []float32, count int, freeStreamVelocity, fluidDensity float64) ([]float32, error) {
+	if err := CheckCount(count); err != nil {
+		return nil, err
+	}
+	result := make([]float32, count)
+	if err := PressuresInto(result, velocities, count, freeStreamVelocity, fluidDensity); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// PressuresInto is Pressures writing into dst, which must hold at least
+// count values. Velocities and pressures may use different precisions.
+func PressuresInto[Out, In Float](dst []Out, velocities []In, count int, freeStreamVelocity, fluidDensity float64) error {
+	return PressuresMasked(dst, velocities, count, freeStreamVelocity, fluidDensity, Mask{})
+}
+
+// PressuresMasked is PressuresInto evaluating only the particles active
+// in m
+func PressuresMasked[Out, In Float](dst []Out, velocities []In, count int, freeStreamVelocity, fluidDensity float64, m Mask) error {
+	return pressuresMasked(dst, velocities, count, freeStreamVelocity, fluidDensity, [3]float64{}, m)
+}
+
+// PressuresFor is PressuresMasked for velocities evaluated in the frame of
+// f. Where the object moves, in the lab frame or under a Motion, the flow
+// is unsteady, and the ∂φ/∂t = -V·v term of the unsteady Bernoulli
+// equation for a body moving at V is included: p = p∞ - ρ(∂φ/∂t + v²/2)
+// works out to the steady formula applied to the velocity relative to the
+// object, with the onset speed as reference, so pressures agree between
+// the frames.
+func PressuresFor[Out, In Float](dst []Out, velocities []In, count int, f *Flow, m Mask) error {
+	g := f.onset()
+	return pressuresMasked(dst, velocities, count, g.FreeStream, f.Density, f.relative(), m)
+}
+
+// pressuresMasked is PressuresMasked adding offset to every velocity
+func pressuresMasked[Out, In Float](dst []Out, velocities []In, count int, freeStreamVelocity, fluidDensity float64, offset [3]float64, m Mask) error {
+	if err := CheckBuffer("velocities", len(velocities), count, 3); err != nil {
+		return err
+	}
+	if err := CheckBuffer("output", len(dst), count, 1); err != nil {
+		return err
+	}
+	if err := m.check(count); err != nil {
+		return err
+	}
+	if err := CheckDensity(fluidDensity); err != nil {
+		return err
+	}
+
+	// Use Bernoulli's equation: p + 0.5*rho*v^2 = constant
+	// Assuming p_infinity + 0.5*rho*V_infinity^2 is our reference
+	pRef := 0.5 * fluidDensity * freeStreamVelocity * freeStreamVelocity
+
+	if chunks(count) <= 1 {
+		pressuresRange(dst, velocities, 0, count, pRef, fluidDensity, &offset, &m)
+		return nil
+	}
+	_, err := parallel(count, func(from, to int) (int, error) {
+		pressuresRange(dst, velocities, from, to, pRef, fluidDensity, &offset, &m)
+		return 0, nil
+	})
+	return err
+}
+
+// pressuresRange evaluates the pressures of particles [from, to), their
+// velocities shifted by offset
+func pressuresRange[Out, In Float](dst []Out, velocities []In, from, to int, pRef, fluidDensity float64, offset *[3]float64, m *Mask) {
+	for i := from; i < to; i++ {
+		if m.skip(i) {
+			if m.Zero {
+				dst[i] = 0
+			}
+			continue
+		}
+		idx := i * 3
+		vx := float64(velocities[idx]) + offset[0]
+		vy := float64(velocities[idx+1]) + offset[1]
+		vz := float64(velocities[idx+2]) + offset[2]
+
+		// Velocity magnitude squared
+		v2 := vx*vx + vy*vy + vz*vz
+
+		// Pressure from Bernoulli (p = pRef - 0.5*rho*v^2)
+		dst[i] = Out(pRef - 0.5*fluidDensity*v2)
+	}
+}
+
+// Advect moves count particles along their velocities for one explicit
+// Euler step of length dt. positions is updated in place.
+func Advect[P, V Float](positions []P, velocities []V, count int, dt float64) error {
+	return AdvectMasked(positions, velocities, count, dt, Mask{})
+}
+
+// AdvectMasked is Advect moving only the particles active in m; the others
+// stay where they are, whatever m.Zero
+func AdvectMasked[P, V Float](positions []P, velocities []V, count int, dt float64, m Mask) error {
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return err
+	}
+	if err := CheckBuffer("velocities", len(velocities), count, 3); err != nil {
+		return err
+	}
+	if err := m.check(count); err != nil {
+		return err
+	}
+	if chunks(count) <= 1 {
+		advectRange(positions, velocities, 0, count, dt, &m)
+		return nil
+	}
+	_, err := parallel(count, func(from, to int) (int, error) {
+		advectRange(positions, velocities, from, to, dt, &m)
+		return 0, nil
+	})
+	return err
+}
+
+// advectRange advances particles [from, to)
+func advectRange[P, V Float](positions []P, velocities []V, from, to int, dt float64, m *Mask) {
+	if m.Active == nil {
+		for i := from * 3; i < to*3; i++ {
+			positions[i] = P(float64(positions[i]) + float64(velocities[i])*dt)
+		}
+		return
+	}
+	for i := from; i < to; i++ {
+		if m.skip(i) {
+			continue
+		}
+		for j := i * 3; j < i*3+3; j++ {
+			positions[j] = P(float64(positions[j]) + float64(velocities[j])*dt)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestAnalytic(t *testing.T) {
+	for _, a := range []string{flow.ArithmeticAccurate, flow.ArithmeticFast} {
+		t.Run(a, func(t *testing.T) {
+			c := newChecker(t)
+			c.arithmetic = a
+			if err := c.checkAnalytic(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAirfoilTrig(t *testing.T) {
+	newChecker(t).checkAirfoilTrig()
+}
+
+func TestStrengths(t *testing.T) {
+	forTypes(t, (*checker).checkStrengths, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+func TestKuttaJoukowski(t *testing.T) {
+	newChecker(t).checkKuttaJoukowski()
+}
+
+func TestFrame(t *testing.T) {
+	forTypes(t, (*checker).checkFrame, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+// TestAirfoilSpanwise steps particles off the midplane past the airfoil
+// section, a 2D flow: no particle may ever get a spanwise velocity
+func TestAirfoilSpanwise(t *testing.T) {
+	config := flow.DefaultConfig()
+	config.Object.Type = flow.Airfoil
+	s := flow.NewSimulation(config)
+	if err := s.Seed(flow.SeedSpec{Kind: flow.SeedRandom, Count: 500, Seed: 103,
+		Grid: flow.Grid{Min: [3]float64{-4, -3, -2}, Max: [3]float64{4, 3, 2}}}); err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 20; k++ {
+		if err := s.Step(0.05); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < s.Count(); i++ {
+			if vz := s.Velocities[i*3+2]; vz != 0 {
+				t.Fatalf("step %d: particle %d at z = %g has vz = %g", k, i, s.Positions[i*3+2], vz)
+			}
+		}
+	}
+}
+
+// checkAnalytic checks the surface tangency, far-field decay, Cp extremes
+// and divergence of the sphere and cylinder in the arithmetic of c
+func (c *checker) checkAnalytic() error {
+	for _, t := range []flow.ObjectType{flow.Sphere, flow.Cylinder} {
+		f := c.flow(t)
+		res, err := f.TangencyResidual(4096)
+		if err != nil {
+			return err
+		}
+		c.report(c.named(t.String()+" tangency"), res.Max < c.tolerance(tangencyTolerance), "max |v·n|/U = %.3g, rms %.3g", res.Max, res.RMS)
+	}
+
+	c.checkDecay(flow.Sphere, [3]float64{1, 1, 1}, -3)
+	c.checkDecay(flow.Cylinder, [3]float64{1, 1, 0}, -2)
+	c.checkCp(flow.Sphere, 1, -1.25)
+	c.checkCp(flow.Cylinder, 1, -3)
+	c.checkDivergence(flow.Sphere, true)
+	c.checkDivergence(flow.Cylinder, false)
+	return nil
+}
+
+// checkDecay fits the far-field decay exponent of the disturbance velocity
+// along direction d between 10 and 20 radii.
+func (c *checker) checkDecay(t flow.ObjectType, d [3]float64, want float64) {
+	f := c.flow(t)
+	n := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	d1 := f.Disturbance(10*d[0]/n, 10*d[1]/n, 10*d[2]/n)
+	d2 := f.Disturbance(20*d[0]/n, 20*d[1]/n, 20*d[2]/n)
+	got := math.Log(d2/d1) / math.Log(2)
+	c.report(c.named(t.String()+" far-field decay"), math.Abs(got-want) < decayTolerance, "exponent %.4f, want %g", got, want)
+}
+
+// checkCp samples Cp just outside the surface in the z = 0 plane.
+func (c *checker) checkCp(t flow.ObjectType, wantMax, wantMin float64) {
+	f := c.flow(t)
+	const n = 3600
+	positions := make([]float32, 0, n*3)
+	for i := 0; i < n; i++ {
+		theta := 2 * math.Pi * float64(i) / n
+		r := 1 + 1e-7
+		positions = append(positions, float32(r*math.Cos(theta)), float32(r*math.Sin(theta)), 0)
+	}
+	velocities, _ := flow.Velocities(positions, n, f)
+	cp, _ := flow.PressureCoefficients(velocities, n, f.FreeStream)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range cp {
+		lo = math.Min(lo, float64(v))
+		hi = math.Max(hi, float64(v))
+	}
+	ok := math.Abs(hi-wantMax) < c.tolerance(cpTolerance) && math.Abs(lo-wantMin) < c.tolerance(cpTolerance)
+	c.report(c.named(t.String()+" Cp extremes"), ok, "max %.7f, min %.7f, want %g / %g", hi, lo, wantMax, wantMin)
+}
+
+// checkDivergence samples ∇·v on a shell between 1.5 and 5 radii. Models
+// with a non-potential correction term are reported but don't fail.
+func (c *checker) checkDivergence(t flow.ObjectType, strict bool) {
+	f := c.flow(t)
+	h, tolerance := 1e-4, divergenceTolerance
+	if c.fast() {
+		// Differences of float32 velocities need a wider stencil
+		h, tolerance = fastStencil, fastDivergence
+	}
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 2000, Seed: 7,
+		Grid: flow.Grid{Min: [3]float64{-5, -5, -5}, Max: [3]float64{5, 5, 5}}}
+	positions, _ := seed.Positions()
+	worst := 0.0
+	for i := 0; i < seed.Count; i++ {
+		x, y, z := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+		if r := math.Sqrt(x*x + y*y + z*z); r < 1.5 || r > 5 {
+			continue
+		}
+		worst = math.Max(worst, math.Abs(f.Divergence(x, y, z, h)))
+	}
+	if strict {
+		c.report(c.named(t.String()+" divergence"), worst < tolerance, "max |∇·v| = %.3g", worst)
+	} else {
+		c.note("KNOWN", c.named(t.String()+" divergence"), "max |∇·v| = %.3g (non-potential vz term)", worst)
+	}
+}
+
+// checkAirfoilTrig compares the airfoil kernel, written with double-angle
+// identities, against the trigonometric form it replaced
+func (c *checker) checkAirfoilTrig() {
+	f := checkFlow(flow.Airfoil)
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 4000, Seed: 143,
+		Grid: flow.Grid{Min: [3]float64{-4, -4, -1}, Max: [3]float64{4, 4, 1}}}
+	positions, _ := seed.Positions()
+	worst := 0.0
+	for i := 0; i < seed.Count; i++ {
+		x, y, z := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+		rxy := math.Hypot(x, y)
+		if rxy <= f.Object.Radius {
+			continue
+		}
+		u, r := f.FreeStream, f.Object.Radius
+		angle := math.Atan2(y, x)
+		circulation := u * 4 * math.Pi * r * math.Sin(angle)
+		factor := r * r / (rxy * rxy)
+		tx := u * (1 - factor*math.Cos(2*angle))
+		ty := u*(-factor*math.Sin(2*angle)) + circulation/(2*math.Pi*rxy)
+		vx, vy, vz := f.VelocityAt(x, y, z)
+		worst = math.Max(worst, math.Max(math.Abs(vx-tx), math.Max(math.Abs(vy-ty), math.Abs(vz))))
+	}
+	c.report("airfoil trig-free kernel", worst < trigTolerance, "max |v - v_trig| = %.3g", worst)
+}
+
+// checkStrengths verifies that an object without strength overrides, or
+// with a collision radius equal to its radius, is evaluated exactly as
+// before, that overriding every strength with its radius-derived default
+// changes the velocity by rounding only, and that the shader agrees with
+// the kernels for non-default strengths and a smaller collision radius
+func (c *checker) checkStrengths(t flow.ObjectType) error {
+	plain := checkFlow(t)
+	plain.Direction = [3]float64{0.48, 0.6, 0.64}
+	same := plain
+	same.Object.CollisionRadius = same.Object.Radius
+	r := plain.Object.Radius
+	defaults := plain
+	defaults.Object.Strengths = flow.Strengths{Doublet: 2 * math.Pi * r * r, Set: flow.StrengthDoublet | flow.StrengthSource}
+	switch t {
+	case flow.Sphere:
+		defaults.Object.Strengths.Doublet *= r
+	case flow.Airfoil:
+		defaults.Object.Strengths.Circulation = 4 * math.Pi * r
+		defaults.Object.Strengths.Set |= flow.StrengthCirculation
+	}
+	// The defaults scale with the cross-flow speed of cylinders and airfoils
+	if t != flow.Sphere {
+		d := plain.Direction
+		dxy := math.Hypot(d[0], d[1])
+		defaults.Object.Strengths.Doublet *= dxy
+		defaults.Object.Strengths.Circulation *= dxy
+	}
+	exact, worst := true, 0.0
+	for i := 0; i < 1000; i++ {
+		x, y, z := float64(i%10)-4.5, float64(i/10%10)-4.5, float64(i/100)-4.5
+		ux, uy, uz := plain.VelocityAt(x, y, z)
+		sx, sy, sz := same.VelocityAt(x, y, z)
+		exact = exact && ux == sx && uy == sy && uz == sz
+		dx, dy, dz := defaults.VelocityAt(x, y, z)
+		worst = math.Max(worst, math.Max(math.Abs(dx-ux), math.Max(math.Abs(dy-uy), math.Abs(dz-uz))))
+	}
+
+	f := plain
+	f.Object.X, f.Object.Y, f.Object.Z = 0.1, -0.2, 0.05
+	f.Object.Strengths = flow.Strengths{Doublet: 3, Source: 0.7, Set: flow.StrengthDoublet | flow.StrengthSource}
+	if t != flow.Sphere {
+		f.Object.Strengths.Circulation = -2
+		f.Object.Strengths.Set |= flow.StrengthCirculation
+	}
+	f.Object.CollisionRadius = 0.5
+	sh, err := flow.NewShader(f)
+	if err != nil {
+		return err
+	}
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 2000, Seed: 157,
+		Grid: flow.Grid{Min: [3]float64{-3, -3, -3}, Max: [3]float64{3, 3, 3}}}
+	positions, _ := seed.Positions()
+	shader := 0.0
+	for i := 0; i < seed.Count; i++ {
+		x, y, z := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+		ux, uy, uz := f.VelocityAt(x, y, z)
+		sx, sy, sz := sh.VelocityAt(x, y, z)
+		shader = math.Max(shader, math.Max(math.Abs(sx-ux), math.Max(math.Abs(sy-uy), math.Abs(sz-uz))))
+	}
+	ok := exact && worst < strengthTolerance && shader < shaderTolerance
+	c.report(t.String()+" strengths", ok, "defaults identical: %v; explicit defaults max |Δv| = %.3g; shader max |Δv| = %.3g", exact, worst, shader)
+	return nil
+}
+
+// checkKuttaJoukowski integrates the surface pressure of a cylinder with
+// an explicit circulation Γ, whose collision radius lies inside the
+// surface, and compares the lift per unit span with -ρUΓ, the
+// Kutta-Joukowski lift of a counterclockwise circulation
+func (c *checker) checkKuttaJoukowski() {
+	f := checkFlow(flow.Cylinder)
+	const gamma = 2.5
+	f.Object.Strengths = flow.Strengths{Circulation: gamma, Set: flow.StrengthCirculation}
+	f.Object.CollisionRadius = 0.5
+	const n = 4096
+	r := f.Object.Radius
+	lift := 0.0
+	for i := 0; i < n; i++ {
+		theta := 2 * math.Pi * float64(i) / n
+		sin, cos := math.Sincos(theta)
+		vx, vy, vz := f.VelocityAt(r*cos, r*sin, 0)
+		p := -0.5 * f.Density * (vx*vx + vy*vy + vz*vz)
+		lift -= p * sin * r * 2 * math.Pi / n
+	}
+	want := -f.Density * f.FreeStream * gamma
+	err := math.Abs(lift-want) / math.Abs(want)
+	c.report("kutta-joukowski", err < liftTolerance, "lift per span %.9g, want -ρUΓ = %g", lift, want)
+}
+
+// checkFrame verifies the Galilean transform between the frames: lab frame
+// velocities are the body frame ones minus the free stream, and the
+// pressures, which include the unsteady term in the lab frame, agree. A body
+// moving under a prescribed motion through fluid at rest must match the
+// lab frame exactly.
+func (c *checker) checkFrame(t flow.ObjectType) error {
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 2000, Seed: 149,
+		Grid: flow.Grid{Min: [3]float64{-3, -3, -3}, Max: [3]float64{3, 3, 3}}}
+	positions, _ := seed.Positions()
+	body := checkFlow(t)
+	body.Direction = [3]float64{0.48, 0.6, 0.64}
+	lab := body
+	lab.Frame = flow.FrameLab
+	u := lab.ObjectVelocity()
+	moving := body
+	moving.FreeStream, moving.Motion = 0, u
+	vb, vl, vm := make([]float64, 3*seed.Count), make([]float64, 3*seed.Count), make([]float64, 3*seed.Count)
+	pb, pl, pm := make([]float64, seed.Count), make([]float64, seed.Count), make([]float64, seed.Count)
+	for _, ev := range []struct {
+		f    *flow.Flow
+		v, p []float64
+	}{{&body, vb, pb}, {&lab, vl, pl}, {&moving, vm, pm}} {
+		if err := flow.VelocitiesInto(ev.v, positions, seed.Count, *ev.f); err != nil {
+			return err
+		}
+		if err := flow.PressuresFor(ev.p, ev.v, seed.Count, ev.f, flow.Mask{}); err != nil {
+			return err
+		}
+	}
+	dv, dp, dm := 0.0, 0.0, 0.0
+	for i := 0; i < seed.Count; i++ {
+		for a := 0; a < 3; a++ {
+			dv = math.Max(dv, math.Abs(vl[i*3+a]-(vb[i*3+a]+u[a])))
+			dm = math.Max(dm, math.Abs(vm[i*3+a]-vl[i*3+a]))
+		}
+		dp = math.Max(dp, math.Abs(pl[i]-pb[i]))
+		dm = math.Max(dm, math.Abs(pm[i]-pl[i]))
+	}
+	c.report(t.String()+" lab frame", dv < frameTolerance && dp < frameTolerance, "max |v_lab - v_body + U∞| = %.3g, |p_lab - p_body| = %.3g", dv, dp)
+	c.report(t.String()+" moving body", dm < frameTolerance, "max difference from the lab frame %.3g", dm)
+	return nil
+}
@@ -0,0 +1,236 @@
+package flow
+
+import "math"
+
+// Surface pressure quadrature resolutions, in cells from the upstream
+// stagnation point to the shoulder of the body
+const (
+	DefaultForceResolution = 32
+	MinForceResolution     = 2
+	MaxForceResolution     = 256
+)
+
+// forceTolerance is the change of a surface force from half the
+// resolution, relative to ½ρU² times the frontal area, past which it
+// counts as unresolved
+const forceTolerance = 1e-3
+
+// surfaceCell is a cell of a surface quadrature: its center, just outside
+// the body, its outward unit normal and its area
+type surfaceCell struct {
+	p, normal [3]float64
+	area      float64
+}
+
+// surfaceCells returns a midpoint quadrature of the surface of o with n
+// cells from the upstream stagnation point to the shoulder, about the
+// stream direction d, so that the halves facing the stream and facing
+// away from it are made of whole cells. The sphere's 2n rings of cells
+// are spaced evenly in polar angle from -d, 4n cells around, with their
+// exact areas; the cylinder's 4n cells go around one unit of span at o.Z,
+// d taken in the xy plane.
+func (o *ObjectSpec) surfaceCells(n int, d [3]float64) []surfaceCell {
+	r := o.Radius * (1 + surfaceOffset)
+	h := math.Pi / float64(2*n)
+	var cells []surfaceCell
+	switch o.Type {
+	case Sphere:
+		// e1, e2 complete d to a right-handed basis
+		e1 := [3]float64{-d[1], d[0], 0}
+		if math.Abs(d[2]) > 0.9 {
+			e1 = [3]float64{0, -d[2], d[1]}
+		}
+		s := math.Sqrt(e1[0]*e1[0] + e1[1]*e1[1] + e1[2]*e1[2])
+		e1 = [3]float64{e1[0] / s, e1[1] / s, e1[2] / s}
+		e2 := [3]float64{d[1]*e1[2] - d[2]*e1[1], d[2]*e1[0] - d[0]*e1[2], d[0]*e1[1] - d[1]*e1[0]}
+		m := 4 * n
+		dphi := 2 * math.Pi / float64(m)
+		for i := 0; i < 2*n; i++ {
+			st, ct := math.Sincos((float64(i) + 0.5) * h)
+			area := o.Radius * o.Radius * (math.Cos(float64(i)*h) - math.Cos(float64(i+1)*h)) * dphi
+			for j := 0; j < m; j++ {
+				sp, cp := math.Sincos((float64(j) + 0.5) * dphi)
+				var nrm [3]float64
+				for k := range nrm {
+					nrm[k] = -ct*d[k] + st*(cp*e1[k]+sp*e2[k])
+				}
+				cells = append(cells, surfaceCell{[3]float64{o.X + r*nrm[0], o.Y + r*nrm[1], o.Z + r*nrm[2]}, nrm, area})
+			}
+		}
+	case Cylinder:
+		a := math.Atan2(d[1], d[0]) + math.Pi/2
+		for i := 0; i < 4*n; i++ {
+			s, c := math.Sincos(a + (float64(i)+0.5)*h)
+			cells = append(cells, surfaceCell{[3]float64{o.X + r*c, o.Y + r*s, o.Z}, [3]float64{c, s, 0}, o.Radius * h})
+		}
+	}
+	return cells
+}
+
+// forceFrame checks that f supports surface force integration with
+// resolution n, and returns the onset stream direction, on which the
+// quadrature is aligned, and ½ρU² times the frontal area of the object,
+// per unit span for cylinders
+func (f *Flow) forceFrame(n int) (d [3]float64, scale float64, err error) {
+	if err := f.Validate(); err != nil {
+		return d, 0, err
+	}
+	o := &f.Object
+	if o.Radius == 0 {
+		return d, 0, Errorf(ErrBadArguments, "surface forces need an object, but the radius is 0")
+	}
+	if o.Type != Sphere && o.Type != Cylinder {
+		return d, 0, Errorf(ErrUnsupported, "surface forces are integrated on spheres and cylinders, not a %v", o.Type)
+	}
+	if n < MinForceResolution || n > MaxForceResolution {
+		return d, 0, Errorf(ErrBadArguments, "force resolution must be between %d and %d, got %d", MinForceResolution, MaxForceResolution, n)
+	}
+	g := f.onset()
+	d = g.freeVelocity()
+	if o.Type == Cylinder {
+		d[2] = 0
+	}
+	u := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	if err := checkFreeStream(u); err != nil {
+		return d, 0, err
+	}
+	d = [3]float64{d[0] / u, d[1] / u, d[2] / u}
+	scale = 0.5 * f.Density * u * u * 2 * o.Radius
+	if o.Type == Sphere {
+		scale = 0.5 * f.Density * u * u * math.Pi * o.Radius * o.Radius
+	}
+	return d, scale, nil
+}
+
+// pressureForces integrates the pressure force -(p - p∞)·n dA over the
+// surface of f's object with resolution n, from the velocity relative to
+// it as PressuresFor does, split between the half of the surface facing
+// the onset stream d and the half facing away from it, and its moment
+// about the object center
+func (f *Flow) pressureForces(n int, d [3]float64) (front, rear, moment [3]float64) {
+	q := 0.5 * f.Density
+	u2 := f.onset2()
+	o := &f.Object
+	for _, c := range o.surfaceCells(n, d) {
+		vx, vy, vz := f.VelocityAt(c.p[0], c.p[1], c.p[2])
+		p := q * (u2 - f.relative2(vx, vy, vz))
+		half := &rear
+		if c.normal[0]*d[0]+c.normal[1]*d[1]+c.normal[2]*d[2] < 0 {
+			half = &front
+		}
+		var df [3]float64
+		for k := range half {
+			df[k] = -p * c.normal[k] * c.area
+			half[k] += df[k]
+		}
+		x, y, z := c.p[0]-o.X, c.p[1]-o.Y, c.p[2]-o.Z
+		moment[0] += y*df[2] - z*df[1]
+		moment[1] += z*df[0] - x*df[2]
+		moment[2] += x*df[1] - y*df[0]
+	}
+	return front, rear, moment
+}
+
+// SurfaceForce is the pressure force on the object from a surface
+// quadrature, and its moment about the object center, per unit span for
+// cylinders. Resolved tells whether halving the resolution changes the
+// force by less than 1e-3 of ½ρU² times the frontal area; Error is that
+// change in the same measure.
+type SurfaceForce struct {
+	Force      [3]float64
+	Moment     [3]float64
+	Resolution int
+	Resolved   bool
+	Error      float64
+}
+
+// PressureForce integrates the surface pressure on the object of f with
+// resolution n, DefaultForceResolution being resolved for both spheres
+// and cylinders. In potential flow about a closed body the force is
+// d'Alembert's zero drag, and zero lift but for the circulation of a
+// cylinder; the pressure, normal to the surface of these bodies, has no
+// moment about their center. The pressure is the quasi-steady one of
+// PressuresFor, so an onset flow changing with time, under a Schedule or
+// an accelerating Motion, doesn't bring the added mass reaction.
+func (f *Flow) PressureForce(n int) (SurfaceForce, error) {
+	s := SurfaceForce{Resolution: n}
+	d, scale, err := f.forceFrame(n)
+	if err != nil {
+		return s, err
+	}
+	front, rear, moment := f.pressureForces(n, d)
+	cfront, crear, _ := f.pressureForces((n+1)/2, d)
+	s.Moment = moment
+	var e float64
+	for k := range s.Force {
+		s.Force[k] = front[k] + rear[k]
+		e = math.Max(e, math.Abs(s.Force[k]-cfront[k]-crear[k]))
+	}
+	s.Error = e / scale
+	s.Resolved = s.Error <= forceTolerance
+	return s, nil
+}
+
+// DragReport decomposes the pressure drag on a sphere or cylinder, the
+// force along the onset stream, into the contributions of the half of the
+// surface facing the stream and the half facing away. In potential flow
+// the front half pulls the body upstream, the suction around its shoulder
+// outweighing the stagnation pressure, by πρU²R²/16 on a sphere and
+// ρU²R/3 per unit span on a cylinder, and the rear pulls it downstream by
+// as much: d'Alembert's paradox. IdealNet, the net drag of the inviscid
+// flow, is zero; Net is the quadrature's, which differs from it only by
+// the quadrature error while no separated wake is modeled.
+type DragReport struct {
+	Front, Rear, Net float64
+	IdealNet         float64
+	Resolution       int
+	Resolved         bool
+	Error            float64 // Change of the front and net drag from half the resolution, relative to ½ρU²A
+}
+
+// DragReport returns the drag decomposition of the object of f, from the
+// same quadrature as PressureForce
+func (f *Flow) DragReport(n int) (DragReport, error) {
+	r := DragReport{Resolution: n}
+	d, scale, err := f.forceFrame(n)
+	if err != nil {
+		return r, err
+	}
+	drag := func(n int) (front, rear float64) {
+		a, b, _ := f.pressureForces(n, d)
+		return a[0]*d[0] + a[1]*d[1] + a[2]*d[2], b[0]*d[0] + b[1]*d[1] + b[2]*d[2]
+	}
+	r.Front, r.Rear = drag(n)
+	r.Net = r.Front + r.Rear
+	front, rear := drag((n + 1) / 2)
+	r.Error = math.Max(math.Abs(r.Front-front), math.Abs(r.Net-front-rear)) / scale
+	r.Resolved = r.Error <= forceTolerance
+	return r, nil
+}
+
+// pressureLoads is PressureForce without the resolution estimate, for
+// repeated sampling
+func (f *Flow) pressureLoads(n int) (force, moment [3]float64, err error) {
+	d, _, err := f.forceFrame(n)
+	if err != nil {
+		return force, moment, err
+	}
+	front, rear, moment := f.pressureForces(n, d)
+	for k := range force {
+		force[k] = front[k] + rear[k]
+	}
+	return force, moment, nil
+}
+
+// Encode returns r in generic form
+func (r DragReport) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"frontDrag":  r.Front,
+		"rearDrag":   r.Rear,
+		"netDrag":    r.Net,
+		"idealNet":   r.IdealNet,
+		"resolution": r.Resolution,
+		"resolved":   r.Resolved,
+		"error":      r.Error,
+	}
+}
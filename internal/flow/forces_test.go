@@ -0,0 +1,55 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestDrag(t *testing.T) {
+	run(t, (*checker).checkDrag)
+}
+
+// checkDrag verifies the pressure drag decomposition: front halves pulled
+// upstream by πρU²R²/16 on a sphere and ρU²R/3 on a cylinder, for an
+// oblique stream past a displaced body, rear halves cancelling them, the
+// default resolution resolved and a coarse one flagged, and the surface
+// quadrature giving the Kutta-Joukowski lift of a circulating cylinder
+func (c *checker) checkDrag() error {
+	for _, t := range []flow.ObjectType{flow.Sphere, flow.Cylinder} {
+		f := checkFlow(t)
+		f.Density, f.FreeStream = 1.2, 3
+		f.Direction = [3]float64{0.6, 0.8, 0}
+		f.Object.X, f.Object.Y, f.Object.Z = 0.5, -1, 2
+		want := -f.Density * f.FreeStream * f.FreeStream / 3
+		if t == flow.Sphere {
+			want = -math.Pi * f.Density * f.FreeStream * f.FreeStream / 16
+		}
+		r, err := f.DragReport(flow.DefaultForceResolution)
+		if err != nil {
+			return err
+		}
+		coarse, err := f.DragReport(4)
+		if err != nil {
+			return err
+		}
+		front := math.Abs(r.Front/want - 1)
+		net := math.Abs(r.Net-r.IdealNet) / math.Abs(want)
+		c.report(t.String()+" drag halves", front < dragTolerance && net < strengthTolerance && r.Resolved && !coarse.Resolved,
+			"front %.6g, want %.6g; rear %.6g; net %.3g; resolved %v, at 4 %v", r.Front, want, r.Rear, r.Net, r.Resolved, coarse.Resolved)
+	}
+
+	f := checkFlow(flow.Cylinder)
+	const gamma = 2.5
+	f.Object.Strengths = flow.Strengths{Circulation: gamma, Set: flow.StrengthCirculation}
+	s, err := f.PressureForce(flow.DefaultForceResolution)
+	if err != nil {
+		return err
+	}
+	want := -f.Density * f.FreeStream * gamma
+	lift := math.Abs(s.Force[1]/want - 1)
+	c.report("pressure force", lift < forceLiftTolerance && math.Abs(s.Force[0]) < strengthTolerance && s.Resolved,
+		"lift per span %.9g, want -ρUΓ = %g; drag %.3g", s.Force[1], want, s.Force[0])
+	return nil
+}
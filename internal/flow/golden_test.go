@@ -0,0 +1,142 @@
+package flow_test
+
+import (
+	"encoding/binary"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+// The golden snapshots pin the velocities and pressures of a deterministic
+// particle set bit for bit. After an intended change, regenerate them with
+//
+//	go test ./internal/flow -run TestGolden -update
+//
+// The suite also runs under GOOS=js, in Node:
+//
+//	GOOS=js GOARCH=wasm go test -exec="$(go env GOROOT)/lib/wasm/go_js_wasm_exec" ./internal/flow
+var (
+	update = flag.Bool("update", false, "regenerate the golden snapshots")
+	ulps   = flag.Int("ulps", 0, "allowed float32 ulp difference from the golden snapshots")
+)
+
+// goldenDir holds the golden snapshots, one little-endian float32 file per
+// object type
+var goldenDir = filepath.Join("testdata", "golden")
+
+// TestGolden compares each object type's snapshot with its testdata file,
+// allowing at most -ulps units in the last place of difference.
+func TestGolden(t *testing.T) {
+	forTypes(t, func(c *checker, ty flow.ObjectType) error {
+		got, err := goldenSnapshot(ty)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(goldenDir, ty.String()+".f32")
+		if *update {
+			if err := writeFloat32s(path, got); err != nil {
+				return err
+			}
+			c.note("WROTE", ty.String()+" golden", "%s", path)
+			return nil
+		}
+		want, err := readFloat32s(path)
+		if err != nil {
+			return err
+		}
+		if len(want) != len(got) {
+			c.report(ty.String()+" golden", false, "%s holds %d values, want %d", path, len(want), len(got))
+			return nil
+		}
+		worst, at := 0, 0
+		for i := range got {
+			if d := ulpDistance(got[i], want[i]); d > worst {
+				worst, at = d, i
+			}
+		}
+		c.report(ty.String()+" golden", worst <= *ulps, "max %d ulp (value %d), allowed %d", worst, at, *ulps)
+		return nil
+	}, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+// goldenParticles is the deterministic particle set of the snapshots
+func goldenParticles() []float32 {
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 64, Seed: 107,
+		Grid: flow.Grid{Min: [3]float64{-3, -3, -3}, Max: [3]float64{3, 3, 3}}}
+	positions, _ := seed.Positions()
+	return positions
+}
+
+// goldenSnapshot evaluates velocities followed by pressures for the
+// golden particle set around an off-center object of type t
+func goldenSnapshot(t flow.ObjectType) ([]float32, error) {
+	f := flow.Flow{FreeStream: 1.5, Density: 1.2,
+		Object: flow.ObjectSpec{Type: t, X: 0.1, Y: -0.2, Z: 0.05, Radius: 1}}
+	positions := goldenParticles()
+	count := len(positions) / 3
+	velocities, err := flow.Velocities(positions, count, f)
+	if err != nil {
+		return nil, err
+	}
+	pressures, err := flow.Pressures(velocities, count, f.FreeStream, f.Density)
+	if err != nil {
+		return nil, err
+	}
+	return append(velocities, pressures...), nil
+}
+
+// ulpDistance returns the number of representable float32 values between
+// a and b. NaNs only match NaNs.
+func ulpDistance(a, b float32) int {
+	if a != a || b != b {
+		if a != a && b != b {
+			return 0
+		}
+		return math.MaxInt32
+	}
+	ia, ib := orderedBits(a), orderedBits(b)
+	if ia > ib {
+		return int(ia - ib)
+	}
+	return int(ib - ia)
+}
+
+// orderedBits maps a float32 to an integer that is monotonic in its value
+func orderedBits(f float32) int64 {
+	b := int64(int32(math.Float32bits(f)))
+	if b < 0 {
+		b = math.MinInt32 - b
+	}
+	return b
+}
+
+func writeFloat32s(path string, data []float32) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(f, binary.LittleEndian, data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readFloat32s(path string) ([]float32, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]float32, len(raw)/4)
+	for i := range data {
+		data[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return data, nil
+}
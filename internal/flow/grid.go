@@ -0,0 +1,101 @@
+package flow
+
+import "math"
+
+// Grid is a structured grid of sample points over an axis-aligned box.
+// Points are ordered with x varying fastest, then y, then z.
+type Grid struct {
+	Min, Max [3]float64
+	N        [3]int // Points along each axis
+}
+
+// Validate checks that the grid has at least one point per axis and a
+// well-formed extent.
+func (g *Grid) Validate() error {
+	for a := 0; a < 3; a++ {
+		if g.N[a] < 1 {
+			return Errorf(ErrBadArguments, "grid resolution must be at least 1 along each axis, got %v", g.N)
+		}
+		if math.IsNaN(g.Min[a]) || math.IsNaN(g.Max[a]) || g.Max[a] < g.Min[a] {
+			return Errorf(ErrBadArguments, "grid extent is invalid: min %v, max %v", g.Min, g.Max)
+		}
+	}
+	return nil
+}
+
+// Len returns the total number of grid points
+func (g *Grid) Len() int {
+	return g.N[0] * g.N[1] * g.N[2]
+}
+
+// Spacing returns the distance between neighbouring points on each axis.
+// Axes with a single point have zero spacing.
+func (g *Grid) Spacing() [3]float64 {
+	var d [3]float64
+	for a := 0; a < 3; a++ {
+		if g.N[a] > 1 {
+			d[a] = (g.Max[a] - g.Min[a]) / float64(g.N[a]-1)
+		}
+	}
+	return d
+}
+
+// Point returns the position of grid point (i, j, k)
+func (g *Grid) Point(i, j, k int) (x, y, z float64) {
+	d := g.Spacing()
+	return g.Min[0] + float64(i)*d[0], g.Min[1] + float64(j)*d[1], g.Min[2] + float64(k)*d[2]
+}
+
+// Positions returns all grid points as [x1,y1,z1,x2,y2,z2,...]
+func (g *Grid) Positions() []float32 {
+	out := make([]float32, 0, g.Len()*3)
+	for k := 0; k < g.N[2]; k++ {
+		for j := 0; j < g.N[1]; j++ {
+			for i := 0; i < g.N[0]; i++ {
+				x, y, z := g.Point(i, j, k)
+				out = append(out, float32(x), float32(y), float32(z))
+			}
+		}
+	}
+	return out
+}
+
+// DecodeGrid decodes a grid description {min: [x, y, z], max: [x, y, z],
+// resolution: [nx, ny, nz]} from generic values. min and max default to the
+// origin and resolution to one point per axis.
+func DecodeGrid(v interface{}) (Grid, error) {
+	m, err := section(v, "grid", "min", "max", "resolution")
+	if err != nil {
+		return Grid{}, err
+	}
+	return gridKeys(m, "grid")
+}
+
+// gridKeys reads the min, max and resolution keys of m as a grid
+func gridKeys(m map[string]interface{}, path string) (Grid, error) {
+	g := Grid{N: [3]int{1, 1, 1}}
+	var err error
+	for _, e := range []struct {
+		key string
+		dst *[3]float64
+	}{{"min", &g.Min}, {"max", &g.Max}} {
+		if v, ok := m[e.key]; ok {
+			if *e.dst, err = vector(v, path+"."+e.key); err != nil {
+				return g, err
+			}
+		}
+	}
+	if v, ok := m["resolution"]; ok {
+		n, err := vector(v, path+".resolution")
+		if err != nil {
+			return g, err
+		}
+		for a := range n {
+			if n[a] != math.Trunc(n[a]) {
+				return g, Errorf(ErrBadArguments, "%s.resolution must hold integers", path)
+			}
+			g.N[a] = int(n[a])
+		}
+	}
+	return g, g.Validate()
+}
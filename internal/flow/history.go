@@ -0,0 +1,112 @@
+package flow
+
+import "math"
+
+// MaxForceSamples bounds the length of a ForceHistory
+const MaxForceSamples = 1 << 20
+
+// DefaultHistoryResolution is the surface quadrature resolution of a
+// ForceHistory unless a different one is given, coarser than
+// DefaultForceResolution since it is paid on every sampled step
+const DefaultHistoryResolution = 16
+
+// ForceSample is the pressure force and moment on the object at one step
+type ForceSample struct {
+	Time   float64
+	Force  [3]float64
+	Moment float64 // About the object center, along z
+}
+
+// ForceHistory samples the pressure force on the object of a Simulation
+// (see Flow.PressureForce) every Every steps into Go memory. Steps are
+// counted as the clock moves: the first sample is taken at the first
+// step, and a step at the time of the last one, where a step of dt 0
+// leaves the clock, isn't counted. Each sample is taken with the
+// velocities of its step, before the particles and the object move, so
+// its Time is the time the free stream schedule and the object motion
+// were evaluated at. Once MaxSamples are held it stops, or with Rolling
+// drops the oldest sample for each new one.
+type ForceHistory struct {
+	Every      int
+	MaxSamples int
+	Resolution int
+	Rolling    bool
+
+	Active bool
+	Err    error // Why sampling stopped early, if it did
+
+	samples []ForceSample // A ring once full and Rolling, oldest at next
+	next    int
+	steps   int
+	last    float64 // Time of the last counted step
+}
+
+// NewForceHistory validates the sampling parameters. resolution defaults
+// to DefaultHistoryResolution when zero.
+func NewForceHistory(every, maxSamples, resolution int, rolling bool) (*ForceHistory, error) {
+	if resolution == 0 {
+		resolution = DefaultHistoryResolution
+	}
+	if every < 1 || maxSamples < 1 || maxSamples > MaxForceSamples {
+		return nil, Errorf(ErrBadArguments, "everyNSteps must be positive and maxSamples between 1 and %d, got %d and %d", MaxForceSamples, every, maxSamples)
+	}
+	if resolution < MinForceResolution || resolution > MaxForceResolution {
+		return nil, Errorf(ErrBadArguments, "force resolution must be between %d and %d, got %d", MinForceResolution, MaxForceResolution, resolution)
+	}
+	return &ForceHistory{Every: every, MaxSamples: maxSamples, Resolution: resolution, Rolling: rolling, Active: true, last: math.NaN()}, nil
+}
+
+// Check fails if the object of s has no surface force integration
+func (h *ForceHistory) Check(s *Simulation) error {
+	f := s.Flow()
+	_, _, err := f.forceFrame(h.Resolution)
+	return err
+}
+
+// Capture samples the forces on the object of s if this step is due
+func (h *ForceHistory) Capture(s *Simulation) {
+	if !h.Active || s.Time == h.last {
+		return
+	}
+	h.last = s.Time
+	h.steps++
+	if (h.steps-1)%h.Every != 0 {
+		return
+	}
+	f := s.Flow()
+	force, moment, err := f.pressureLoads(h.Resolution)
+	if err != nil {
+		h.Active = false
+		h.Err = err
+		LogValue(LogWarn, "forces", "sampling stopped, samples kept", float64(len(h.samples)))
+		return
+	}
+	sample := ForceSample{Time: s.Time, Force: force, Moment: moment[2]}
+	switch {
+	case len(h.samples) < h.MaxSamples:
+		h.samples = append(h.samples, sample)
+	case h.Rolling:
+		h.samples[h.next] = sample
+		h.next = (h.next + 1) % h.MaxSamples
+	}
+	if len(h.samples) == h.MaxSamples && !h.Rolling {
+		h.Active = false
+	}
+}
+
+// Len returns the number of samples held
+func (h *ForceHistory) Len() int {
+	return len(h.samples)
+}
+
+// Samples returns the samples held in time order as parallel arrays of
+// the time, force components and moment
+func (h *ForceHistory) Samples() (t, fx, fy, fz, m []float64) {
+	n := len(h.samples)
+	t, fx, fy, fz, m = make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n), make([]float64, n)
+	for i := range h.samples {
+		p := &h.samples[(h.next+i)%n]
+		t[i], fx[i], fy[i], fz[i], m[i] = p.Time, p.Force[0], p.Force[1], p.Force[2], p.Moment
+	}
+	return t, fx, fy, fz, m
+}
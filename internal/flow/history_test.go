@@ -0,0 +1,57 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestForceHistory(t *testing.T) {
+	run(t, (*checker).checkForceHistory)
+}
+
+// checkForceHistory verifies the force history of a circulating cylinder
+// in a free stream oscillating on a schedule: every sample the lift -ρUΓ
+// of the speed scheduled at its time, the drag zero, samples every third
+// step counted from the first, a step of dt 0 not counted, and a rolling
+// history keeping the latest samples
+func (c *checker) checkForceHistory() error {
+	config := flow.DefaultConfig()
+	config.Object.Type = flow.Cylinder
+	const gamma = 2.5
+	config.Object.Strengths = flow.Strengths{Circulation: gamma, Set: flow.StrengthCirculation}
+	s := flow.NewSimulation(config)
+	s.Schedule = &flow.Schedule{Points: []flow.SchedulePoint{{T: 0, U: 1}, {T: 0.05, U: 2}, {T: 0.1, U: 0.5}, {T: 0.2, U: 1.5}}}
+	h, err := flow.NewForceHistory(3, 4, 0, true)
+	if err != nil {
+		return err
+	}
+	if err := h.Check(s); err != nil {
+		return err
+	}
+	s.Forces = h
+	const dt = 0.01
+	for i := 0; i < 20; i++ {
+		if i == 5 {
+			if err := s.Step(0); err != nil {
+				return err
+			}
+		}
+		if err := s.Step(dt); err != nil {
+			return err
+		}
+	}
+	t, fx, fy, _, m := h.Samples()
+	lift, drag := 0.0, 0.0
+	cadence := len(t) == 4
+	for i := range t {
+		want := -config.Density * s.Schedule.At(t[i]) * gamma
+		lift = math.Max(lift, math.Abs(fy[i]/want-1))
+		drag = math.Max(drag, math.Abs(fx[i])+math.Abs(m[i]))
+		cadence = cadence && math.Abs(t[i]-float64(9+3*i)*dt) < frameTolerance
+	}
+	c.report("force history", lift < forceLiftTolerance && drag < strengthTolerance && cadence && h.Active,
+		"lift against -ρUΓ %.3g, drag and moment %.3g; times %v", lift, drag, t)
+	return nil
+}
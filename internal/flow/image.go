@@ -0,0 +1,170 @@
+package flow
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+// Layout of RenderSliceImage, in pixels
+const (
+	imagePad      = 6
+	imageBarGap   = 10
+	imageBarWidth = 12
+	glyphScale    = 2
+	glyphW        = 4 * glyphScale // Advance per character
+	glyphH        = 5 * glyphScale
+)
+
+// glyphs is a 3×5 pixel font covering number labels and axis names; each
+// row is 3 bits, most significant on the left
+var glyphs = map[byte][5]uint8{
+	'0': {7, 5, 5, 5, 7}, '1': {2, 6, 2, 2, 7}, '2': {7, 1, 7, 4, 7},
+	'3': {7, 1, 7, 1, 7}, '4': {5, 5, 7, 1, 1}, '5': {7, 4, 7, 1, 7},
+	'6': {7, 4, 7, 5, 7}, '7': {7, 1, 1, 1, 1}, '8': {7, 5, 7, 5, 7},
+	'9': {7, 5, 7, 1, 7}, '.': {0, 0, 0, 0, 2}, '-': {0, 0, 7, 0, 0},
+	'+': {0, 2, 7, 2, 0}, 'e': {7, 4, 7, 4, 7}, ' ': {},
+	'x': {5, 5, 2, 5, 5}, 'y': {5, 5, 2, 2, 2}, 'z': {7, 1, 2, 4, 7},
+}
+
+var (
+	imageBackground = color.RGBA{255, 255, 255, 255}
+	imageInk        = color.RGBA{0, 0, 0, 255}
+	imageBody       = color.RGBA{128, 128, 128, 255}
+	imageStreamline = color.RGBA{255, 255, 255, 255}
+)
+
+// RenderSliceImage renders field over s as a PNG heatmap, with the values
+// mapped from [lo, hi] through colormap as in SliceTextureRGBA8. The body
+// is drawn in flat gray and lines, projected onto the plane, in white. The
+// plot is framed by its axis extents, and a colorbar labeled with lo and
+// hi runs to its right; the labels are in the units of u.
+func RenderSliceImage(f Flow, s Slice, field string, lo, hi float64, colormap string, lines []Polyline, u Units) ([]byte, error) {
+	texels, err := SliceTextureRGBA8(f, s, field, lo, hi, colormap)
+	if err != nil {
+		return nil, err
+	}
+	label := func(v float64) string { return strconv.FormatFloat(v, 'g', 4, 64) }
+	ax := planeAxes[s.Plane]
+	names := "xyz"
+	l, d := u.Factor(DimLength), FieldDim(field)
+	uMin, uMax, vMin, vMax := label(s.Min[0]/l), label(s.Max[0]/l), label(s.Min[1]/l), label(s.Max[1]/l)
+	loLabel, hiLabel := label(u.FromSI(d, lo)), label(u.FromSI(d, hi))
+
+	left := imagePad + max(len(vMin), len(vMax))*glyphW + imagePad
+	top := imagePad
+	barX := left + s.Width + imageBarGap
+	width := barX + imageBarWidth + imagePad + max(len(loLabel), len(hiLabel))*glyphW + imagePad
+	height := top + s.Height + imagePad + glyphH + imagePad
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		c := imageBackground
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+
+	// Field, with row 0 of the texels at the bottom
+	for j := 0; j < s.Height; j++ {
+		for i := 0; i < s.Width; i++ {
+			t := texels[(j*s.Width+i)*4:]
+			c := color.RGBA{t[0], t[1], t[2], 255}
+			if t[3] == 0 {
+				c = imageBody
+			}
+			img.SetRGBA(left+i, top+s.Height-1-j, c)
+		}
+	}
+	for _, l := range lines {
+		for k := 1; k < l.Len(); k++ {
+			x0, y0 := s.pixel(l, k-1, ax)
+			x1, y1 := s.pixel(l, k, ax)
+			drawLine(img, image.Rect(left, top, left+s.Width, top+s.Height), left, top, x0, y0, x1, y1)
+		}
+	}
+	drawFrame(img, image.Rect(left, top, left+s.Width, top+s.Height))
+
+	// Colorbar, hi at the top
+	stops := colormaps[colormap]
+	for r := 0; r < s.Height; r++ {
+		cr, cg, cb := colorAt(stops, 1-(float64(r)+0.5)/float64(s.Height))
+		for i := 0; i < imageBarWidth; i++ {
+			img.SetRGBA(barX+i, top+r, color.RGBA{cr, cg, cb, 255})
+		}
+	}
+	drawFrame(img, image.Rect(barX, top, barX+imageBarWidth, top+s.Height))
+
+	// Labels
+	below := top + s.Height + imagePad
+	drawText(img, left, below, uMin)
+	drawText(img, left+s.Width-len(uMax)*glyphW, below, uMax)
+	drawText(img, left+s.Width/2-glyphW/2, below, names[ax[0]:ax[0]+1])
+	drawText(img, left-imagePad-len(vMax)*glyphW, top, vMax)
+	drawText(img, left-imagePad-len(vMin)*glyphW, top+s.Height-glyphH, vMin)
+	drawText(img, left-imagePad-glyphW, top+s.Height/2-glyphH/2, names[ax[1]:ax[1]+1])
+	drawText(img, barX+imageBarWidth+imagePad, top, hiLabel)
+	drawText(img, barX+imageBarWidth+imagePad, top+s.Height-glyphH, loLabel)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, Errorf(ErrInternal, "encoding PNG: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// pixel projects point k of l onto the slice, in pixels from the top left
+// corner of the plot
+func (s *Slice) pixel(l Polyline, k int, ax [3]int) (x, y float64) {
+	u, v := float64(l[k*3+ax[0]]), float64(l[k*3+ax[1]])
+	return (u - s.Min[0]) / (s.Max[0] - s.Min[0]) * float64(s.Width),
+		(s.Max[1] - v) / (s.Max[1] - s.Min[1]) * float64(s.Height)
+}
+
+// drawLine draws a one pixel wide segment, clipped to clip, between plot
+// coordinates relative to (ox, oy)
+func drawLine(img *image.RGBA, clip image.Rectangle, ox, oy int, x0, y0, x1, y1 float64) {
+	n := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+	for k := 0; k <= n; k++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(k) / float64(n)
+		}
+		p := image.Pt(ox+int(math.Floor(x0+t*(x1-x0))), oy+int(math.Floor(y0+t*(y1-y0))))
+		if p.In(clip) {
+			img.SetRGBA(p.X, p.Y, imageStreamline)
+		}
+	}
+}
+
+// drawFrame draws a one pixel border just outside r
+func drawFrame(img *image.RGBA, r image.Rectangle) {
+	for x := r.Min.X - 1; x <= r.Max.X; x++ {
+		img.SetRGBA(x, r.Min.Y-1, imageInk)
+		img.SetRGBA(x, r.Max.Y, imageInk)
+	}
+	for y := r.Min.Y - 1; y <= r.Max.Y; y++ {
+		img.SetRGBA(r.Min.X-1, y, imageInk)
+		img.SetRGBA(r.Max.X, y, imageInk)
+	}
+}
+
+// drawText draws s with its top left corner at (x, y); characters without
+// a glyph are left blank
+func drawText(img *image.RGBA, x, y int, s string) {
+	for i := 0; i < len(s); i++ {
+		g := glyphs[s[i]]
+		for row := 0; row < 5; row++ {
+			for col := 0; col < 3; col++ {
+				if g[row]&(4>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < glyphScale; dy++ {
+					for dx := 0; dx < glyphScale; dx++ {
+						img.SetRGBA(x+i*glyphW+col*glyphScale+dx, y+row*glyphScale+dy, imageInk)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package flow
+
+import (
+	"math"
+	"sort"
+)
+
+// StreamInjection is the Random stream of the positions of injected
+// particles
+const StreamInjection = "injection"
+
+// DefaultParticleBudget is the particle count injection grows to before it
+// recycles dye particles, unless Injection.Budget says otherwise
+const DefaultParticleBudget = 100000
+
+// Injector is a dye nozzle: while Enabled, each step spawns Rate·dt
+// particles, uniformly at random inside the sphere of Radius about
+// Position and outside the object and walls, with the flow velocity there,
+// their source in Simulation.Sources set to ID. The fraction of a particle
+// left over carries to the next step, so a rate below one per step still
+// emits. Color is the renderer's color of the source, red, green and blue
+// from 0 to 1, kept for it.
+type Injector struct {
+	ID       uint8 // 1 to 255; 0 is the source of particles from no injector
+	Position [3]float64
+	Radius   float64
+	Rate     float64 // Particles per second
+	Color    [3]float64
+	Enabled  bool
+
+	pending float64 // Particles owed to the next step
+}
+
+// Validate checks the parameters of in
+func (in *Injector) Validate() error {
+	if in.ID == 0 {
+		return Errorf(ErrBadArguments, "injector id must be between 1 and 255")
+	}
+	for _, x := range in.Position {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "injector %d: position must be finite, got %v", in.ID, in.Position)
+		}
+	}
+	if !(in.Radius >= 0) || math.IsInf(in.Radius, 0) {
+		return Errorf(ErrBadArguments, "injector %d: radius must be a non-negative finite length, got %g", in.ID, in.Radius)
+	}
+	if !(in.Rate >= 0) || math.IsInf(in.Rate, 0) {
+		return Errorf(ErrBadArguments, "injector %d: rate must be a non-negative finite number, got %g", in.ID, in.Rate)
+	}
+	for _, x := range in.Color {
+		if !(x >= 0 && x <= 1) {
+			return Errorf(ErrBadArguments, "injector %d: color components must be between 0 and 1, got %v", in.ID, in.Color)
+		}
+	}
+	return nil
+}
+
+// Injection is the dye injection of a Simulation, a runtime setting. The
+// particle count grows with injected particles up to Budget; past it, each
+// new particle takes the slot of the oldest injected one still there, in
+// the order they were injected, whatever its injector. Particles from no
+// injector are never evicted: with no injected particle to recycle, the
+// new one is dropped and counted in Dropped.
+type Injection struct {
+	Injectors []*Injector // By ID
+	Budget    int
+	Dropped   int
+
+	next int // The slot to recycle first
+}
+
+// Injector returns the injector with id, or nil
+func (j *Injection) Injector(id uint8) *Injector {
+	i := sort.Search(len(j.Injectors), func(i int) bool { return j.Injectors[i].ID >= id })
+	if i < len(j.Injectors) && j.Injectors[i].ID == id {
+		return j.Injectors[i]
+	}
+	return nil
+}
+
+// Define adds in, or replaces the injector with its ID, carrying over the
+// particle owed
+func (j *Injection) Define(in Injector) error {
+	if err := in.Validate(); err != nil {
+		return err
+	}
+	if old := j.Injector(in.ID); old != nil {
+		in.pending = old.pending
+		*old = in
+		return nil
+	}
+	j.Injectors = append(j.Injectors, &in)
+	sort.Slice(j.Injectors, func(a, b int) bool { return j.Injectors[a].ID < j.Injectors[b].ID })
+	return nil
+}
+
+// Remove deletes the injector with id, reporting whether there was one.
+// Its particles keep their source.
+func (j *Injection) Remove(id uint8) bool {
+	for i, in := range j.Injectors {
+		if in.ID == id {
+			j.Injectors = append(j.Injectors[:i], j.Injectors[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// budget returns the particle budget
+func (j *Injection) budget() int {
+	if j.Budget > 0 {
+		return j.Budget
+	}
+	return DefaultParticleBudget
+}
+
+// syncSources makes Sources as long as the particle count, the particles
+// it doesn't cover from no injector
+func (s *Simulation) syncSources() {
+	n := s.Count()
+	if len(s.Sources) == n {
+		return
+	}
+	if len(s.Sources) > n {
+		s.Sources = s.Sources[:n]
+		return
+	}
+	s.Sources = append(s.Sources, make([]uint8, n-len(s.Sources))...)
+}
+
+// ParticleSources returns Sources, first made as long as the particle
+// count if the particles changed without it
+func (s *Simulation) ParticleSources() []uint8 {
+	s.syncSources()
+	return s.Sources
+}
+
+// inject spawns the particles of the enabled injectors for a step of dt
+func (s *Simulation) inject(dt float64) error {
+	j := &s.Injection
+	if dt <= 0 || len(j.Injectors) == 0 {
+		return nil
+	}
+	s.syncSources()
+	f := s.Flow()
+	f.Cutoff = s.Cutoff
+	k := f.kernel()
+	stream := s.Random.Stream(StreamInjection)
+	var slots []int
+	var points []float64
+	for _, in := range j.Injectors {
+		if !in.Enabled {
+			continue
+		}
+		in.pending += in.Rate * dt
+		n := int(in.pending)
+		in.pending -= float64(n)
+		for ; n > 0; n-- {
+			p, ok := injectionPoint(in, &k, stream)
+			if !ok {
+				j.Dropped++
+				continue
+			}
+			i := s.Count()
+			if i >= j.budget() {
+				if i = s.recycleSlot(); i < 0 {
+					j.Dropped++
+					continue
+				}
+			} else {
+				s.Positions = append(s.Positions, 0, 0, 0)
+				s.Velocities = append(s.Velocities, 0, 0, 0)
+				s.Pressures = append(s.Pressures, 0)
+				s.Sources = append(s.Sources, 0)
+				if len(s.precise) == i*3 && i > 0 {
+					s.precise = append(s.precise, 0, 0, 0)
+				}
+			}
+			s.Sources[i] = in.ID
+			for a := range p {
+				s.Positions[i*3+a] = float32(p[a])
+			}
+			if len(s.precise) == len(s.Positions) {
+				copy(s.precise[i*3:i*3+3], p[:])
+			}
+			slots, points = append(slots, i), append(points, p[:]...)
+		}
+	}
+	if len(slots) == 0 {
+		return nil
+	}
+
+	velocities := make([]float32, len(points))
+	pressures := make([]float32, len(slots))
+	if err := VelocitiesInto(velocities, points, len(slots), f); err != nil {
+		return err
+	}
+	if err := PressuresFor(pressures, velocities, len(slots), &f, Mask{}); err != nil {
+		return err
+	}
+	if err := AddSwirlPressures(pressures, points, len(slots), &f, Mask{}); err != nil {
+		return err
+	}
+	for n, i := range slots {
+		copy(s.Velocities[i*3:i*3+3], velocities[n*3:n*3+3])
+		s.Pressures[i] = pressures[n]
+	}
+	s.lod.invalidate()
+	return nil
+}
+
+// recycleSlot returns the slot of the oldest injected particle, or -1 if
+// there is none. Injected particles fill slots in order until the budget is
+// reached, then replace each other in turn, so the next one holding an
+// injected particle after the last recycled is the oldest.
+func (s *Simulation) recycleSlot() int {
+	n := s.Count()
+	j := &s.Injection
+	for k := 0; k < n; k++ {
+		i := (j.next + k) % n
+		if s.Sources[i] != 0 {
+			j.next = (i + 1) % n
+			return i
+		}
+	}
+	return -1
+}
+
+// injectionPoint draws a point uniformly in the sphere of in outside the
+// fluid's exclusions, giving up after a few tries
+func injectionPoint(in *Injector, k *kernel, stream *Stream) ([3]float64, bool) {
+	for try := 0; try < 8; try++ {
+		var d [3]float64
+		for {
+			for a := range d {
+				d[a] = 2*stream.Float64() - 1
+			}
+			if d[0]*d[0]+d[1]*d[1]+d[2]*d[2] <= 1 {
+				break
+			}
+		}
+		p := [3]float64{in.Position[0] + in.Radius*d[0], in.Position[1] + in.Radius*d[1], in.Position[2] + in.Radius*d[2]}
+		if !k.excluded(p[0], p[1], p[2]) {
+			return p, true
+		}
+	}
+	return [3]float64{}, false
+}
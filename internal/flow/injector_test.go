@@ -0,0 +1,136 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestInjection(t *testing.T) {
+	run(t, (*checker).checkInjection)
+}
+
+// checkInjection runs dye injectors over a few seeded particles: a slow
+// injector emitting a quarter of a particle per step must have spawned
+// one every four steps, each in its sphere; a fast one then filling the
+// budget must recycle the injected particles oldest first, leaving the
+// seeded ones and their zero source alone; and with nothing injected to
+// recycle, every spawn is dropped.
+func (c *checker) checkInjection() error {
+	const dt = 1.0 / 64
+	var positions []float32
+	for i := 0; i < 10; i++ {
+		positions = append(positions, -4+0.5*float32(i), -3, 2.5)
+	}
+	s := flow.NewSimulation(flow.DefaultConfig())
+	if err := s.SetParticles(positions, 10); err != nil {
+		return err
+	}
+	s.Injection.Budget = 30
+	slow := flow.Injector{ID: 1, Position: [3]float64{-4, 1, 1}, Radius: 0.2, Rate: 16, Enabled: true}
+	if err := s.Injection.Define(slow); err != nil {
+		return err
+	}
+	inside := true
+	for k := 1; k <= 40; k++ {
+		if err := s.Step(dt); err != nil {
+			return err
+		}
+		if k%4 == 0 && s.Count() == 10+k/4 {
+			i := s.Count() - 1
+			dx, dy, dz := float64(s.Positions[i*3])+4, float64(s.Positions[i*3+1])-1, float64(s.Positions[i*3+2])-1
+			inside = inside && math.Sqrt(dx*dx+dy*dy+dz*dz) <= slow.Radius+1e-6
+		}
+	}
+	sources := s.ParticleSources()
+	spawned := s.Count() - 10
+	emitted := s.Count() == 20 && len(sources) == 20
+	for i := 0; emitted && i < 20; i++ {
+		emitted = sources[i] == uint8(i/10)
+	}
+
+	fast := flow.Injector{ID: 2, Position: [3]float64{-4, -1, 1}, Radius: 0.2, Rate: 640, Enabled: true}
+	if err := s.Injection.Define(fast); err != nil {
+		return err
+	}
+	slow.Enabled = false
+	if err := s.Injection.Define(slow); err != nil {
+		return err
+	}
+	for k := 0; k < 5; k++ {
+		if err := s.Step(dt); err != nil {
+			return err
+		}
+	}
+	sources = s.ParticleSources()
+	recycled := s.Count() == 30 && len(sources) == 30 && s.Injection.Dropped == 0
+	for i := 0; recycled && i < 30; i++ {
+		recycled = sources[i] == 0 && i < 10 && s.Positions[i*3+1] < -2 || sources[i] == 2 && i >= 10
+	}
+
+	t := flow.NewSimulation(flow.DefaultConfig())
+	if err := t.SetParticles(positions, 10); err != nil {
+		return err
+	}
+	t.Injection.Budget = 10
+	if err := t.Injection.Define(fast); err != nil {
+		return err
+	}
+	if err := t.Step(dt); err != nil {
+		return err
+	}
+	dropped := t.Count() == 10 && t.Injection.Dropped == 10
+	c.report("dye injection", emitted && inside && recycled && dropped, "slow injector: %d particles after 40 steps, in its sphere %v; budget: %d particles, recycled oldest first %v; %d of 10 spawns dropped with nothing to recycle", spawned, inside, s.Count(), recycled, t.Injection.Dropped)
+	return nil
+}
+
+// TestStepManyInjection records frames while an injector grows the
+// particle count: each frame must hold the particles before the call at
+// the positions of its step, its count saying so, and match the same steps
+// run one by one
+func TestStepManyInjection(t *testing.T) {
+	const dt, n, every = 1.0 / 64, 8, 2
+	positions := []float32{-4, -3, 2.5, -3.5, -3, 2.5, -3, -3, 2.5}
+	start := func() *flow.Simulation {
+		s := flow.NewSimulation(flow.DefaultConfig())
+		if err := s.SetParticles(positions, 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Injection.Define(flow.Injector{ID: 1, Position: [3]float64{-4, 1, 1}, Radius: 0.2, Rate: 128, Enabled: true}); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := start()
+	frames := make([]float32, n/every*3*3)
+	counts := make([]uint32, n/every)
+	if err := s.StepMany(n, dt, every, frames, counts); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count() != 3+2*n {
+		t.Fatalf("count %d after the steps, want %d", s.Count(), 3+2*n)
+	}
+	ref := start()
+	for k := 1; k <= n; k++ {
+		if err := ref.Step(dt); err != nil {
+			t.Fatal(err)
+		}
+		if k%every != 0 {
+			continue
+		}
+		f := k/every - 1
+		if counts[f] != 3 {
+			t.Errorf("frame %d counts %d particles, want 3", f, counts[f])
+		}
+		for i := 0; i < 9; i++ {
+			if frames[f*9+i] != ref.Positions[i] {
+				t.Errorf("frame %d position[%d] = %g, want %g", f, i, frames[f*9+i], ref.Positions[i])
+			}
+		}
+	}
+	if err := start().StepMany(n, dt, every, frames, counts[:1]); err == nil {
+		t.Error("counts too short for the frames accepted")
+	}
+}
@@ -0,0 +1,109 @@
+package flow
+
+import "math"
+
+// Attribute is one vertex attribute of an interleaved layout
+type Attribute struct {
+	Name       string
+	Offset     int    // Byte offset within a vertex
+	Size       int    // Number of components
+	Type       string // "float32" or "uint8"
+	Normalized bool
+}
+
+// Layout describes an interleaved per-particle vertex buffer. Every layout
+// is a whole number of float32 words per particle, so buffers are
+// Float32Arrays; uint8 attributes are packed into the bytes of a word.
+type Layout struct {
+	Name       string
+	Stride     int // Bytes per particle
+	Attributes []Attribute
+}
+
+// Interleaved layouts
+const (
+	LayoutPositionColor  = "position-color"  // x, y, z, r, g, b as float32
+	LayoutPositionSpeed  = "position-speed"  // x, y, z, speed as float32
+	LayoutPositionColor8 = "position-color8" // x, y, z as float32, then RGBA8
+)
+
+var layouts = map[string]Layout{
+	LayoutPositionColor: {LayoutPositionColor, 24, []Attribute{
+		{"position", 0, 3, "float32", false},
+		{"color", 12, 3, "float32", false},
+	}},
+	LayoutPositionSpeed: {LayoutPositionSpeed, 16, []Attribute{
+		{"position", 0, 3, "float32", false},
+		{"speed", 12, 1, "float32", false},
+	}},
+	LayoutPositionColor8: {LayoutPositionColor8, 16, []Attribute{
+		{"position", 0, 3, "float32", false},
+		{"color", 12, 4, "uint8", true},
+	}},
+}
+
+// LookupLayout returns the named layout
+func LookupLayout(name string) (Layout, error) {
+	l, ok := layouts[name]
+	if !ok {
+		return l, Errorf(ErrBadArguments, "unknown interleaved layout %q", name)
+	}
+	return l, nil
+}
+
+// Layouts lists the layout names
+func Layouts() []string {
+	return []string{LayoutPositionColor, LayoutPositionSpeed, LayoutPositionColor8}
+}
+
+// Interleave writes count particles into dst in layout l, coloring by
+// speed mapped from [lo, hi] through colormap. dst must hold
+// count*l.Stride/4 floats.
+func Interleave[P, V Float](dst []float32, l Layout, positions []P, velocities []V, count int, lo, hi float64, colormap string) error {
+	return InterleaveMasked(dst, l, positions, velocities, count, lo, hi, colormap, Mask{})
+}
+
+// InterleaveMasked is Interleave writing only the particles active in m;
+// zeroing a particle clears its whole record, including the alpha of
+// "position-color8"
+func InterleaveMasked[P, V Float](dst []float32, l Layout, positions []P, velocities []V, count int, lo, hi float64, colormap string, m Mask) error {
+	words := l.Stride / 4
+	if err := CheckBuffer("target", len(dst), count, words); err != nil {
+		return err
+	}
+	if err := m.check(count); err != nil {
+		return err
+	}
+	stops, ok := colormaps[colormap]
+	if !ok {
+		return Errorf(ErrBadArguments, "unknown colormap %q", colormap)
+	}
+	if !(hi > lo) {
+		return Errorf(ErrBadArguments, "color range must be increasing, got [%g, %g]", lo, hi)
+	}
+	for i := 0; i < count; i++ {
+		d := dst[i*words : (i+1)*words]
+		if m.skip(i) {
+			if m.Zero {
+				clear(d)
+			}
+			continue
+		}
+		d[0], d[1], d[2] = float32(positions[i*3]), float32(positions[i*3+1]), float32(positions[i*3+2])
+		vx, vy, vz := float64(velocities[i*3]), float64(velocities[i*3+1]), float64(velocities[i*3+2])
+		speed := math.Sqrt(vx*vx + vy*vy + vz*vz)
+		switch l.Name {
+		case LayoutPositionSpeed:
+			d[3] = float32(speed)
+		case LayoutPositionColor:
+			r, g, b := colorAt(stops, (speed-lo)/(hi-lo))
+			d[3], d[4], d[5] = float32(r)/255, float32(g)/255, float32(b)/255
+		case LayoutPositionColor8:
+			r, g, b := colorAt(stops, (speed-lo)/(hi-lo))
+			// Little-endian, like WebAssembly memory and typed arrays on
+			// every platform browsers run on
+			d[3] = math.Float32frombits(uint32(r) | uint32(g)<<8 | uint32(b)<<16 | 255<<24)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package flow
+
+import "math"
+
+// DefaultGamma is the ratio of specific heats of air
+const DefaultGamma = 1.4
+
+// Isentropic holds the isentropic ratios of the local static pressure,
+// density and temperature to their free stream values, one per particle
+type Isentropic struct {
+	Pressure, Density, Temperature []float32
+
+	// Supersonic flags the particles whose local Mach number exceeds 1,
+	// where the isentropic relations of a shock-free flow don't hold; their
+	// ratios are those of the sonic state instead
+	Supersonic []bool
+	Count      int // Number of supersonic particles
+}
+
+// IsentropicRatios evaluates the isentropic relations for count particles
+// from their velocities, given the free stream speed U∞ and Mach number
+// M∞ and the ratio of specific heats gamma. The energy equation gives
+//
+//	T/T∞ = 1 + (γ-1)/2·M∞²·(1 - v²/U∞²)
+//
+// and p/p∞ = (T/T∞)^(γ/(γ-1)), ρ/ρ∞ = (T/T∞)^(1/(γ-1)). The velocities
+// are those of the incompressible solution, so the ratios are only as
+// good as it is at M∞: fine at low subsonic speeds, an estimate in the
+// high subsonic range. It rejects U∞ = 0 and M∞ outside [0, 1).
+func IsentropicRatios[In Float](velocities []In, count int, freeStream, mach, gamma float64) (Isentropic, error) {
+	var r Isentropic
+	if err := CheckBuffer("velocities", len(velocities), count, 3); err != nil {
+		return r, err
+	}
+	if err := checkFreeStream(freeStream); err != nil {
+		return r, err
+	}
+	if !(mach >= 0 && mach < 1) {
+		return r, Errorf(ErrBadArguments, "mach must be in [0, 1), got %g", mach)
+	}
+	if !(gamma > 1) || math.IsInf(gamma, 0) {
+		return r, Errorf(ErrBadArguments, "gamma must be a finite number above 1, got %g", gamma)
+	}
+
+	r.Pressure, r.Density, r.Temperature = make([]float32, count), make([]float32, count), make([]float32, count)
+	r.Supersonic = make([]bool, count)
+	k := (gamma - 1) / 2 * mach * mach
+	u2 := freeStream * freeStream
+	// The sonic state: T*/T∞ = (1 + k)/(1 + (γ-1)/2)
+	sonic := (1 + k) / (1 + (gamma-1)/2)
+	for i := 0; i < count; i++ {
+		vx := float64(velocities[i*3])
+		vy := float64(velocities[i*3+1])
+		vz := float64(velocities[i*3+2])
+		q := (vx*vx + vy*vy + vz*vz) / u2
+		t := 1 + k*(1-q)
+		// Local Mach number squared: M² = q·M∞²/(T/T∞)
+		if t <= 0 || q*mach*mach > t {
+			t = sonic
+			r.Supersonic[i] = true
+			r.Count++
+		}
+		r.Temperature[i] = float32(t)
+		r.Density[i] = float32(math.Pow(t, 1/(gamma-1)))
+		r.Pressure[i] = float32(math.Pow(t, gamma/(gamma-1)))
+	}
+	return r, nil
+}
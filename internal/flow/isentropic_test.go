@@ -0,0 +1,43 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestIsentropic(t *testing.T) {
+	forTypes(t, func(c *checker, ty flow.ObjectType) error {
+		c.checkIsentropic(ty)
+		return nil
+	}, flow.Sphere, flow.Cylinder)
+}
+
+// checkIsentropic verifies that at a low Mach number the isentropic
+// pressure ratios on the surface reduce to the incompressible ones,
+// p/p∞ - 1 = γM²/2·Cp, and that at M∞ = 0.8 the suction peak of both
+// bodies is flagged as supersonic while M∞ = 0.3 flags nothing
+func (c *checker) checkIsentropic(t flow.ObjectType) {
+	f := checkFlow(t)
+	const n = 360
+	positions := make([]float32, 0, n*3)
+	for i := 0; i < n; i++ {
+		theta := 2 * math.Pi * float64(i) / n
+		r := 1 + 1e-7
+		positions = append(positions, float32(r*math.Cos(theta)), float32(r*math.Sin(theta)), 0)
+	}
+	velocities, _ := flow.Velocities(positions, n, f)
+	cp, _ := flow.PressureCoefficients(velocities, n, f.FreeStream)
+	const low = 0.01
+	r, err := flow.IsentropicRatios(velocities, n, f.FreeStream, low, flow.DefaultGamma)
+	worst := 0.0
+	for i := range cp {
+		want := flow.DefaultGamma * low * low / 2 * float64(cp[i])
+		worst = math.Max(worst, math.Abs(float64(r.Pressure[i])-1-want)/(flow.DefaultGamma*low*low/2))
+	}
+	fast, err2 := flow.IsentropicRatios(velocities, n, f.FreeStream, 0.8, flow.DefaultGamma)
+	slow, err3 := flow.IsentropicRatios(velocities, n, f.FreeStream, 0.3, flow.DefaultGamma)
+	ok := err == nil && err2 == nil && err3 == nil && worst < isentropicTolerance && fast.Count > 0 && slow.Count == 0
+	c.report(t.String()+" isentropic", ok, "max |p/p∞ - 1 - γM²/2·Cp|/(γM²/2) = %.3g at M = %g; supersonic at M = 0.8: %d, at 0.3: %d", worst, low, fast.Count, slow.Count)
+}
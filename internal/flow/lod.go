@@ -0,0 +1,192 @@
+package flow
+
+import "math"
+
+// LOD configures the temporal level of detail of Simulation.Step. Particles
+// are bucketed by their distance from the object in radii, measured like
+// Flow.Cutoff: near ones are re-evaluated on every step, mid-range ones
+// every MidEvery steps and far ones every FarEvery steps, staggered so each
+// step refreshes an even share of every bucket. In between they keep their
+// cached velocities.
+//
+// A particle's bucket is updated whenever it is evaluated, so one drifting
+// inwards is caught up within a period. Every bucket is reassigned, and
+// every velocity refreshed, once the object has moved more than Rebucket
+// radii from where the buckets were last assigned, or when the particles,
+// the schedule or any flow parameter but the object velocity change.
+// LODError measures the resulting staleness for a moving object.
+type LOD struct {
+	Near, Far          float64 // Bucket boundaries in radii, Near ≤ Far
+	MidEvery, FarEvery int     // Refresh periods in steps
+	Rebucket           float64 // Object displacement in radii forcing a full refresh
+}
+
+// DefaultLOD is the schedule used when LOD is enabled without options
+var DefaultLOD = LOD{Near: 3, Far: 8, MidEvery: 2, FarEvery: 4, Rebucket: 0.25}
+
+// Validate checks the schedule
+func (l *LOD) Validate() error {
+	if !(l.Near >= 0) || !(l.Far >= l.Near) || math.IsInf(l.Far, 0) {
+		return Errorf(ErrBadArguments, "lod: need 0 ≤ near ≤ far, got near %g, far %g", l.Near, l.Far)
+	}
+	if l.MidEvery < 1 || l.FarEvery < 1 {
+		return Errorf(ErrBadArguments, "lod: midEvery and farEvery must be positive, got %d and %d", l.MidEvery, l.FarEvery)
+	}
+	if !(l.Rebucket > 0) {
+		return Errorf(ErrBadArguments, "lod: rebucket must be positive, got %g", l.Rebucket)
+	}
+	return nil
+}
+
+// Buckets of the level of detail schedule
+const (
+	lodNear uint8 = iota
+	lodMid
+	lodFar
+)
+
+// bucket returns the bucket of a particle at (px, py, pz). Without an
+// object every particle is far, since the flow is uniform.
+func (l *LOD) bucket(o *ObjectSpec, px, py, pz float64) uint8 {
+	d2, r2 := o.distance2(px, py, pz), o.Radius*o.Radius
+	switch {
+	case d2 < l.Near*l.Near*r2:
+		return lodNear
+	case d2 < l.Far*l.Far*r2:
+		return lodMid
+	}
+	return lodFar
+}
+
+// period returns the refresh period of bucket b
+func (l *LOD) period(b uint8) int {
+	switch b {
+	case lodMid:
+		return l.MidEvery
+	case lodFar:
+		return l.FarEvery
+	}
+	return 1
+}
+
+// lodCache is the state of the schedule between steps: the bucket of each
+// particle and what they were assigned for
+type lodCache struct {
+	buckets []uint8
+	due     []uint8 // Mask of the particles evaluated this step
+	valid   bool
+	lod     LOD
+	key     Flow // The flow of the assignment, object position and velocity zeroed
+	origin  [3]float64
+	calls   int
+}
+
+// invalidate forces a full refresh on the next step
+func (c *lodCache) invalidate() {
+	c.valid = false
+}
+
+// velocities brings the cached velocities of count particles up to date
+// for f under schedule l, returning the far-field count and the number of
+// particles whose cached velocity was kept
+func (c *lodCache) velocities(l LOD, f Flow, velocities, positions []float32, count int) (far, reused int, err error) {
+	if err := l.Validate(); err != nil {
+		return 0, 0, err
+	}
+	key := f
+	key.Object.X, key.Object.Y, key.Object.Z = 0, 0, 0
+	key.Motion = [3]float64{}
+	o := f.Object
+	dx, dy, dz := o.X-c.origin[0], o.Y-c.origin[1], o.Z-c.origin[2]
+	moved := math.Sqrt(dx*dx+dy*dy+dz*dz) > l.Rebucket*o.Radius
+	if !c.valid || len(c.buckets) != count || c.lod != l || c.key != key || moved {
+		Log(LogDebug, "lod", "full refresh")
+		if far, err = VelocitiesIntoCounted(velocities, positions, count, f); err != nil {
+			return 0, 0, err
+		}
+		if cap(c.buckets) < count {
+			c.buckets, c.due = make([]uint8, count), make([]uint8, count)
+		}
+		c.buckets, c.due = c.buckets[:count], c.due[:count]
+		for i := range c.buckets {
+			c.buckets[i] = l.bucket(&o, float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2]))
+		}
+		c.valid, c.lod, c.key, c.origin, c.calls = true, l, key, [3]float64{o.X, o.Y, o.Z}, 0
+		return far, 0, nil
+	}
+
+	c.calls++
+	for i, b := range c.buckets {
+		if (c.calls+i)%l.period(b) == 0 {
+			c.due[i] = 1
+		} else {
+			c.due[i] = 0
+			reused++
+		}
+	}
+	if far, err = VelocitiesMasked(velocities, nil, positions, count, f, Mask{Active: c.due}); err != nil {
+		return 0, 0, err
+	}
+	for i, d := range c.due {
+		if d != 0 {
+			c.buckets[i] = l.bucket(&o, float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2]))
+		}
+	}
+	return far, reused, nil
+}
+
+// LODError measures the staleness of schedule l for an object moving by
+// step every call: n fixed sample points, spread from just outside the
+// object to twice the far boundary, are evaluated under the schedule for
+// steps calls, and |v - v_exact|/U∞ is sampled against a full evaluation
+// at every call.
+func (f *Flow) LODError(l LOD, step [3]float64, steps, n int) (Residual, error) {
+	var res Residual
+	if err := f.Validate(); err != nil {
+		return res, err
+	}
+	if err := l.Validate(); err != nil {
+		return res, err
+	}
+	if err := checkFreeStream(f.FreeStream); err != nil {
+		return res, err
+	}
+	if f.Object.Radius == 0 || n < 1 {
+		return res, nil
+	}
+	positions := make([]float32, n*3)
+	outer := math.Max(2*l.Far, 2)
+	for i := 0; i < n; i++ {
+		shell := f.Object
+		scale := 1.05 + (outer-1.05)*(float64(i)+0.5)/float64(n)
+		shell.Radius *= scale
+		p, _ := shell.SurfacePoint(i, n)
+		if f.Object.Type != Sphere {
+			p[2] = f.Object.Z + (p[2]-f.Object.Z)/scale
+		}
+		positions[i*3], positions[i*3+1], positions[i*3+2] = float32(p[0]), float32(p[1]), float32(p[2])
+	}
+
+	var c lodCache
+	g := *f
+	cached, exact := make([]float32, n*3), make([]float32, n*3)
+	for k := 0; k < steps; k++ {
+		if _, _, err := c.velocities(l, g, cached, positions, n); err != nil {
+			return res, err
+		}
+		if err := VelocitiesInto(exact, positions, n, g); err != nil {
+			return res, err
+		}
+		for i := 0; i < n; i++ {
+			dx := float64(cached[i*3] - exact[i*3])
+			dy := float64(cached[i*3+1] - exact[i*3+1])
+			dz := float64(cached[i*3+2] - exact[i*3+2])
+			res.add(math.Sqrt(dx*dx+dy*dy+dz*dz)/math.Abs(f.FreeStream), float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2]))
+		}
+		g.Object.X += step[0]
+		g.Object.Y += step[1]
+		g.Object.Z += step[2]
+	}
+	res.finish()
+	return res, nil
+}
@@ -0,0 +1,23 @@
+package flow_test
+
+import (
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestLOD(t *testing.T) {
+	forTypes(t, (*checker).checkLOD, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+// checkLOD measures the staleness of the default level of detail schedule
+// for an object crossing the rebucketing threshold several times
+func (c *checker) checkLOD(t flow.ObjectType) error {
+	f := checkFlow(t)
+	res, err := f.LODError(flow.DefaultLOD, [3]float64{lodStep, 0, 0}, 100, 4096)
+	if err != nil {
+		return err
+	}
+	c.report(t.String()+" lod staleness", res.Max < lodTolerance, "object moving %g radii per step: max |Δv|/U = %.3g, rms %.3g", lodStep, res.Max, res.RMS)
+	return nil
+}
@@ -0,0 +1,156 @@
+package flow
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogLevel is the severity of a log record
+type LogLevel int
+
+// Log levels, from the most verbose. LogOff disables logging.
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+	LogOff
+)
+
+// DefaultLogLevel is the level logging starts at
+const DefaultLogLevel = LogWarn
+
+var logLevelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l LogLevel) String() string {
+	if l < LogDebug || l > LogOff {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel returns the level called name
+func ParseLogLevel(name string) (LogLevel, error) {
+	for i, n := range logLevelNames {
+		if n == name {
+			return LogLevel(i), nil
+		}
+	}
+	return 0, Errorf(ErrBadArguments, "unknown log level %q, expected one of %v", name, logLevelNames)
+}
+
+// LogRecord is one entry of the log. Value is an optional number attached
+// to the message, such as a count or a residual.
+type LogRecord struct {
+	Level     LogLevel
+	Time      time.Time
+	Subsystem string
+	Message   string
+	Value     float64
+	HasValue  bool
+}
+
+// LogCapacity is the number of records the log keeps; older ones are
+// overwritten
+const LogCapacity = 256
+
+// logger is the process-wide log: a fixed ring of records, so logging a
+// constant message never allocates
+var logger struct {
+	sync.Mutex
+	records [LogCapacity]LogRecord
+	next    int // Slot of the next record
+	n       int // Records held, at most LogCapacity
+	sink    func(LogRecord)
+	sinkMin LogLevel
+}
+
+// logLevel is the current level, read without locking by LogEnabled
+var logLevel atomic.Int32
+
+func init() {
+	logLevel.Store(int32(DefaultLogLevel))
+}
+
+// SetLogLevel sets the least severe level that is recorded
+func SetLogLevel(l LogLevel) error {
+	if l < LogDebug || l > LogOff {
+		return Errorf(ErrBadArguments, "invalid log level %d", int(l))
+	}
+	logLevel.Store(int32(l))
+	return nil
+}
+
+// CurrentLogLevel returns the level set by SetLogLevel
+func CurrentLogLevel() LogLevel {
+	return LogLevel(logLevel.Load())
+}
+
+// LogEnabled reports whether records of level l are kept. Call sites that
+// need work to build their message check it first.
+func LogEnabled(l LogLevel) bool {
+	return l < LogOff && int32(l) >= logLevel.Load()
+}
+
+// SetLogSink makes every record of level min or more also go to fn, or
+// stops mirroring if fn is nil
+func SetLogSink(fn func(LogRecord), min LogLevel) {
+	logger.Lock()
+	defer logger.Unlock()
+	logger.sink, logger.sinkMin = fn, min
+}
+
+// Log records a message, if level l is enabled
+func Log(l LogLevel, subsystem, message string) {
+	if LogEnabled(l) {
+		logRecord(LogRecord{Level: l, Subsystem: subsystem, Message: message})
+	}
+}
+
+// LogValue records a message with a number attached, if level l is enabled
+func LogValue(l LogLevel, subsystem, message string, value float64) {
+	if LogEnabled(l) {
+		logRecord(LogRecord{Level: l, Subsystem: subsystem, Message: message, Value: value, HasValue: true})
+	}
+}
+
+// Logf records a formatted message, if level l is enabled. Formatting
+// allocates, so hot paths use Log or LogValue instead.
+func Logf(l LogLevel, subsystem, format string, args ...interface{}) {
+	if LogEnabled(l) {
+		logRecord(LogRecord{Level: l, Subsystem: subsystem, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+func logRecord(r LogRecord) {
+	r.Time = time.Now()
+	logger.Lock()
+	logger.records[logger.next] = r
+	logger.next = (logger.next + 1) % LogCapacity
+	logger.n = min(logger.n+1, LogCapacity)
+	sink := logger.sink
+	if r.Level < logger.sinkMin {
+		sink = nil
+	}
+	logger.Unlock()
+	if sink != nil {
+		sink(r)
+	}
+}
+
+// Logs returns the records held, oldest first, and clears the log if clear
+// is set
+func Logs(clear bool) []LogRecord {
+	logger.Lock()
+	defer logger.Unlock()
+	out := make([]LogRecord, logger.n)
+	for i := range out {
+		out[i] = logger.records[(logger.next-logger.n+i+LogCapacity)%LogCapacity]
+	}
+	if clear {
+		logger.n = 0
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package flow
+
+// Mask selects the active particles of a batch for the *Masked kernels.
+// Particle i is skipped if Active[i] is 0; a nil Active keeps every
+// particle. The outputs of skipped particles are left untouched, or zeroed
+// if Zero is set, and they don't count towards the far-field count.
+type Mask struct {
+	Active []uint8
+	Zero   bool
+}
+
+// check verifies that the mask covers count particles
+func (m *Mask) check(count int) error {
+	if m.Active == nil {
+		return nil
+	}
+	return CheckBuffer("mask", len(m.Active), count, 1)
+}
+
+// skip reports whether particle i is masked out
+func (m *Mask) skip(i int) bool {
+	return m.Active != nil && m.Active[i] == 0
+}
+
+// Count returns the number of active particles among the first count
+func (m *Mask) Count(count int) int {
+	if m.Active == nil {
+		return count
+	}
+	n := 0
+	for _, a := range m.Active[:count] {
+		if a != 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestMassFlux(t *testing.T) {
+	run(t, (*checker).checkMassFlux)
+}
+
+// checkMassFlux integrates the mass flux past a sphere: the same through
+// planes upstream and downstream, none out of a closed box around it, the
+// sphere's section left out of a plane through its center, and as much
+// crossing a plane along the stream one way as the other
+func (c *checker) checkMassFlux() error {
+	f := flow.Flow{FreeStream: 1, Density: 1.2, Object: flow.ObjectSpec{Type: flow.Sphere, Radius: 1}}
+	flux := func(point, normal [3]float64, extent float64, n int) (flow.MassFlux, error) {
+		return f.MassFlux(flow.FluxPlane{Point: point, Normal: normal, Extent: [2]float64{extent, extent}, Resolution: [2]int{n, n}})
+	}
+	up, err := flux([3]float64{-5, 0, 0}, [3]float64{1, 0, 0}, 6, 200)
+	if err != nil {
+		return err
+	}
+	down, err := flux([3]float64{5, 0, 0}, [3]float64{1, 0, 0}, 6, 200)
+	if err != nil {
+		return err
+	}
+	through := math.Abs(down.Net/up.Net - 1)
+
+	// The faces of a cube of side 6 off the sphere's center, normals
+	// outward
+	out, center := 0.0, [3]float64{0.5, 0.2, -0.3}
+	for a := 0; a < 3; a++ {
+		for _, side := range []float64{-1, 1} {
+			p, n := center, [3]float64{}
+			p[a], n[a] = p[a]+3*side, side
+			m, err := flux(p, n, 6, 300)
+			if err != nil {
+				return err
+			}
+			out += m.Net
+		}
+	}
+	closed := math.Abs(out) / (1.2 * 36)
+
+	section, err := flux([3]float64{}, [3]float64{1, 0, 0}, 4, 200)
+	if err != nil {
+		return err
+	}
+	hole := math.Abs(section.ExcludedArea/math.Pi - 1)
+	area := math.Abs(section.Area+section.ExcludedArea-16) < 1e-9
+
+	along, err := flux([3]float64{0, 0.5, 0}, [3]float64{0, 1, 0}, 6, 200)
+	if err != nil {
+		return err
+	}
+	split := math.Abs(along.Positive+along.Negative) / along.Positive
+
+	ok := through < 1e-9 && closed < 1e-4 && hole < 0.02 && area && section.ExcludedCells > 0 && along.Positive > 0 && split < 1e-9
+	c.report("mass flux", ok, "downstream over upstream %.3g off 1; out of a closed box %.3g of ρUA; sphere section left out %.3g off πR², areas adding up %v; along the stream %.4g each way, net %.3g of it",
+		through, closed, hole, area, along.Positive, split)
+	return nil
+}
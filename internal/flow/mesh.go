@@ -0,0 +1,334 @@
+package flow
+
+import "math"
+
+// Mesh is an indexed triangle mesh. Triangles wind counter-clockwise seen
+// from outside the body.
+type Mesh struct {
+	Positions []float32 // x, y, z per vertex
+	Normals   []float32 // Outward unit normal per vertex
+	UVs       []float32 // u, v per vertex; only set by RenderMesh
+	Indices   []uint32  // Three vertex indices per triangle
+}
+
+// vertex appends a vertex at offset r·n from c and returns its index
+func (m *Mesh) vertex(c [3]float64, r float64, n [3]float64) uint32 {
+	m.Positions = append(m.Positions, float32(c[0]+r*n[0]), float32(c[1]+r*n[1]), float32(c[2]+r*n[2]))
+	m.Normals = append(m.Normals, float32(n[0]), float32(n[1]), float32(n[2]))
+	return uint32(len(m.Positions)/3 - 1)
+}
+
+// triangle appends one triangle
+func (m *Mesh) triangle(a, b, c uint32) {
+	m.Indices = append(m.Indices, a, b, c)
+}
+
+// SurfaceMesh triangulates the object surface with resolution segments
+// around it. Spheres are latitude/longitude meshes with single pole
+// vertices and every vertex shared, so the mesh is watertight. Cylinders and
+// airfoil sections, whose cross-section is the circle rxy = R, and
+// elliptic cylinders are extruded over span along z and closed with flat
+// caps; the cap rims duplicate the side vertices so both get sharp normals.
+// Plates and the splitter plate of a cylinder have no thickness: their two
+// faces share the same positions with opposite normals. Tori are grids
+// around their axis and their tube, every vertex shared. Boxes are their
+// six faces, each with its own corners so the normals stay sharp.
+func (o *ObjectSpec) SurfaceMesh(resolution int, span float64) (*Mesh, error) {
+	if resolution < 3 {
+		return nil, Errorf(ErrBadArguments, "mesh resolution must be at least 3, got %d", resolution)
+	}
+	if o.Radius == 0 {
+		return &Mesh{}, nil
+	}
+	c := [3]float64{o.X, o.Y, o.Z}
+	m := &Mesh{}
+
+	switch o.Type {
+	case Plate:
+		if !(span > 0) {
+			return nil, Errorf(ErrBadArguments, "mesh span must be positive, got %g", span)
+		}
+		m.plate(o, resolution, span, false)
+
+	case Torus:
+		m.torus(o, resolution, false)
+
+	case Box:
+		m.box(o, false)
+
+	case Sphere:
+		rings := (resolution + 1) / 2
+		north := m.vertex(c, o.Radius, [3]float64{0, 0, 1})
+		for j := 1; j < rings; j++ {
+			theta := math.Pi * float64(j) / float64(rings)
+			for i := 0; i < resolution; i++ {
+				phi := 2 * math.Pi * float64(i) / float64(resolution)
+				m.vertex(c, o.Radius, [3]float64{math.Sin(theta) * math.Cos(phi), math.Sin(theta) * math.Sin(phi), math.Cos(theta)})
+			}
+		}
+		south := m.vertex(c, o.Radius, [3]float64{0, 0, -1})
+		ring := func(j, i int) uint32 { return uint32(1 + (j-1)*resolution + i%resolution) }
+		for i := 0; i < resolution; i++ {
+			m.triangle(north, ring(1, i), ring(1, i+1))
+			for j := 1; j < rings-1; j++ {
+				m.triangle(ring(j, i), ring(j+1, i), ring(j+1, i+1))
+				m.triangle(ring(j, i), ring(j+1, i+1), ring(j, i+1))
+			}
+			m.triangle(ring(rings-1, i), south, ring(rings-1, i+1))
+		}
+
+	default:
+		if !(span > 0) {
+			return nil, Errorf(ErrBadArguments, "mesh span must be positive, got %g", span)
+		}
+		half := span / 2
+		side := func(z float64) uint32 {
+			first := uint32(len(m.Positions) / 3)
+			for i := 0; i < resolution; i++ {
+				m.rim(o, c, z, 2*math.Pi*float64(i)/float64(resolution), true)
+			}
+			return first
+		}
+		bottom, top := side(-half), side(half)
+		for i := 0; i < resolution; i++ {
+			j := (i + 1) % resolution
+			a, b := bottom+uint32(i), bottom+uint32(j)
+			d, e := top+uint32(i), top+uint32(j)
+			m.triangle(a, b, e)
+			m.triangle(a, e, d)
+		}
+		for _, z := range []float64{-half, half} {
+			n := [3]float64{0, 0, math.Copysign(1, z)}
+			center := m.vertex([3]float64{c[0], c[1], c[2] + z}, 0, n)
+			for i := 0; i < resolution; i++ {
+				m.rim(o, c, z, 2*math.Pi*float64(i)/float64(resolution), false)
+			}
+			for i := 0; i < resolution; i++ {
+				a, b := center+1+uint32(i), center+1+uint32((i+1)%resolution)
+				if z > 0 {
+					m.triangle(center, a, b)
+				} else {
+					m.triangle(center, b, a)
+				}
+			}
+		}
+		m.splitter(o, resolution, span, false)
+	}
+	return m, nil
+}
+
+// RenderMesh is SurfaceMesh for rendering: every vertex also carries
+// texture coordinates, so vertices on the texture seam (and the sphere
+// poles) are duplicated and the mesh is no longer watertight. Spheres map u
+// to longitude and v to colatitude; cylinder sides map u around and v along
+// the span, and caps map their disc onto the unit square. Plate faces map
+// u along the chord from the leading edge and v along the span, tori u
+// around their axis and v around their tube, and box faces each onto the
+// whole square.
+func (o *ObjectSpec) RenderMesh(resolution int, span float64) (*Mesh, error) {
+	if resolution < 3 {
+		return nil, Errorf(ErrBadArguments, "mesh resolution must be at least 3, got %d", resolution)
+	}
+	if o.Radius == 0 {
+		return &Mesh{}, nil
+	}
+	c := [3]float64{o.X, o.Y, o.Z}
+	m := &Mesh{}
+	uv := func(u, v float64) { m.UVs = append(m.UVs, float32(u), float32(v)) }
+	// grid triangulates a (cols+1)×(rows+1) block of vertices starting at
+	// first, laid out row by row
+	grid := func(first uint32, cols, rows int, skip func(j int) bool) {
+		at := func(i, j int) uint32 { return first + uint32(j*(cols+1)+i) }
+		for j := 0; j < rows; j++ {
+			for i := 0; i < cols; i++ {
+				if skip != nil && skip(j) {
+					continue
+				}
+				m.triangle(at(i, j), at(i, j+1), at(i+1, j+1))
+				m.triangle(at(i, j), at(i+1, j+1), at(i+1, j))
+			}
+		}
+	}
+
+	switch o.Type {
+	case Plate:
+		if !(span > 0) {
+			return nil, Errorf(ErrBadArguments, "mesh span must be positive, got %g", span)
+		}
+		m.plate(o, resolution, span, true)
+
+	case Torus:
+		m.torus(o, resolution, true)
+
+	case Box:
+		m.box(o, true)
+
+	case Sphere:
+		rings := (resolution + 1) / 2
+		for j := 0; j <= rings; j++ {
+			theta := math.Pi * float64(j) / float64(rings)
+			for i := 0; i <= resolution; i++ {
+				phi := 2 * math.Pi * float64(i) / float64(resolution)
+				m.vertex(c, o.Radius, [3]float64{math.Sin(theta) * math.Cos(phi), math.Sin(theta) * math.Sin(phi), math.Cos(theta)})
+				uv(float64(i)/float64(resolution), float64(j)/float64(rings))
+			}
+		}
+		// Quads touching a pole collapse into a single triangle
+		at := func(i, j int) uint32 { return uint32(j*(resolution+1) + i) }
+		for i := 0; i < resolution; i++ {
+			m.triangle(at(i, 0), at(i, 1), at(i+1, 1))
+			m.triangle(at(i, rings-1), at(i, rings), at(i+1, rings-1))
+		}
+		grid(0, resolution, rings, func(j int) bool { return j == 0 || j == rings-1 })
+
+	default:
+		if !(span > 0) {
+			return nil, Errorf(ErrBadArguments, "mesh span must be positive, got %g", span)
+		}
+		half := span / 2
+		// Top row first, so the grid winds outward
+		for j, z := range []float64{half, -half} {
+			for i := 0; i <= resolution; i++ {
+				m.rim(o, c, z, 2*math.Pi*float64(i)/float64(resolution), true)
+				uv(float64(i)/float64(resolution), float64(1-j))
+			}
+		}
+		grid(0, resolution, 1, nil)
+		for _, z := range []float64{-half, half} {
+			n := [3]float64{0, 0, math.Copysign(1, z)}
+			center := m.vertex([3]float64{c[0], c[1], c[2] + z}, 0, n)
+			uv(0.5, 0.5)
+			for i := 0; i < resolution; i++ {
+				phi := 2 * math.Pi * float64(i) / float64(resolution)
+				m.rim(o, c, z, phi, false)
+				uv(0.5+0.5*math.Cos(phi), 0.5+0.5*math.Sin(phi))
+			}
+			for i := 0; i < resolution; i++ {
+				a, b := center+1+uint32(i), center+1+uint32((i+1)%resolution)
+				if z > 0 {
+					m.triangle(center, a, b)
+				} else {
+					m.triangle(center, b, a)
+				}
+			}
+		}
+		m.splitter(o, resolution, span, true)
+	}
+	return m, nil
+}
+
+// plate appends the two faces of plate o over span along z, each split
+// into resolution strips along the chord, with texture coordinates if uvs
+func (m *Mesh) plate(o *ObjectSpec, resolution int, span float64, uvs bool) {
+	t, n := o.chord()
+	half := span / 2
+	for _, side := range []float64{1, -1} {
+		normal := [3]float64{side * n[0], side * n[1], 0}
+		first := uint32(len(m.Positions) / 3)
+		for _, z := range []float64{-half, half} {
+			for i := 0; i <= resolution; i++ {
+				u := float64(i) / float64(resolution)
+				s := (2*u - 1) * o.Radius
+				m.vertex([3]float64{o.X + s*t[0], o.Y + s*t[1], o.Z + z}, 0, normal)
+				if uvs {
+					m.UVs = append(m.UVs, float32(u), float32(z/span+0.5))
+				}
+			}
+		}
+		at := func(i, j int) uint32 { return first + uint32(j*(resolution+1)+i) }
+		for i := 0; i < resolution; i++ {
+			// Counter-clockwise seen from the side the face looks to
+			if side > 0 {
+				m.triangle(at(i, 0), at(i+1, 1), at(i+1, 0))
+				m.triangle(at(i, 0), at(i, 1), at(i+1, 1))
+			} else {
+				m.triangle(at(i, 0), at(i+1, 0), at(i+1, 1))
+				m.triangle(at(i, 0), at(i+1, 1), at(i, 1))
+			}
+		}
+	}
+}
+
+// splitter appends the splitter plate of cylinder o, if it has one, as a
+// plate along x from its surface
+func (m *Mesh) splitter(o *ObjectSpec, resolution int, span float64, uvs bool) {
+	if o.Splitter == 0 {
+		return
+	}
+	p := ObjectSpec{Type: Plate, X: o.X + o.Radius + o.Splitter/2, Y: o.Y, Z: o.Z, Radius: o.Splitter / 2}
+	m.plate(&p, resolution, span, uvs)
+}
+
+// rim appends the vertex of the cross-section of o at parameter φ and
+// height z above c, with the outward normal of the side or, for a cap,
+// the cap's
+func (m *Mesh) rim(o *ObjectSpec, c [3]float64, z, phi float64, side bool) uint32 {
+	p, n := o.section(phi)
+	normal := [3]float64{n[0], n[1], 0}
+	if !side {
+		normal = [3]float64{0, 0, math.Copysign(1, z)}
+	}
+	return m.vertex([3]float64{c[0] + p[0], c[1] + p[1], c[2] + z}, 0, normal)
+}
+
+// torus appends a torus, resolution segments around its axis and around
+// its tube. Without uvs the seams are shared, so the mesh is watertight;
+// with them u goes around the axis and v around the tube from its
+// upstream point.
+func (m *Mesh) torus(o *ObjectSpec, resolution int, uvs bool) {
+	c, a := [3]float64{o.X, o.Y, o.Z}, o.minorRadius()
+	n := resolution
+	if uvs {
+		n++
+	}
+	first := uint32(len(m.Positions) / 3)
+	for j := 0; j < n; j++ {
+		st, ct := math.Sincos(2 * math.Pi * float64(j) / float64(resolution))
+		for i := 0; i < n; i++ {
+			sp, cp := math.Sincos(2 * math.Pi * float64(i) / float64(resolution))
+			center := [3]float64{c[0], c[1] + o.Radius*cp, c[2] + o.Radius*sp}
+			m.vertex(center, a, [3]float64{ct, st * cp, st * sp})
+			if uvs {
+				m.UVs = append(m.UVs, float32(float64(i)/float64(resolution)), float32(float64(j)/float64(resolution)))
+			}
+		}
+	}
+	at := func(i, j int) uint32 { return first + uint32((j%n)*n+i%n) }
+	for j := 0; j < resolution; j++ {
+		for i := 0; i < resolution; i++ {
+			m.triangle(at(i, j), at(i, j+1), at(i+1, j+1))
+			m.triangle(at(i, j), at(i+1, j+1), at(i+1, j))
+		}
+	}
+}
+
+// box appends the six faces of box o, with texture coordinates if uvs
+func (m *Mesh) box(o *ObjectSpec, uvs bool) {
+	h, e := o.halfSize(), o.boxAxes()
+	corner := [4][2]float64{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}}
+	for a := 0; a < 3; a++ {
+		t1, t2 := (a+1)%3, (a+2)%3
+		for _, s := range []float64{-1, 1} {
+			normal := [3]float64{s * e[a][0], s * e[a][1], s * e[a][2]}
+			first := uint32(len(m.Positions) / 3)
+			for _, q := range corner {
+				p := [3]float64{o.X, o.Y, o.Z}
+				for c := range p {
+					p[c] += s*h[a]*e[a][c] + q[0]*h[t1]*e[t1][c] + q[1]*h[t2]*e[t2][c]
+				}
+				m.vertex(p, 0, normal)
+				if uvs {
+					m.UVs = append(m.UVs, float32(q[0]+1)/2, float32(q[1]+1)/2)
+				}
+			}
+			// Counter-clockwise about the outward normal
+			if s > 0 {
+				m.triangle(first, first+1, first+2)
+				m.triangle(first, first+2, first+3)
+			} else {
+				m.triangle(first, first+2, first+1)
+				m.triangle(first, first+3, first+2)
+			}
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package flow
+
+import "math"
+
+// Motion types
+const (
+	MotionLinear    = "linear"
+	MotionCircular  = "circular"
+	MotionWaypoints = "waypoints"
+)
+
+// Motion is a prescribed trajectory of the object, a function of the time
+// since it started. Every trajectory begins at Start, where the object was
+// when the motion was set, so nothing jumps:
+//
+//   - linear: Start + velocity·t + acceleration·t²/2
+//   - circular: Start rotated about the axis through center, with angular
+//     speed 2π/period; a negative period turns the other way
+//   - waypoints: a uniform Catmull-Rom spline from Start through points,
+//     every segment taking the same share of duration. It stops at the last
+//     point, or with loop set returns to Start and starts over.
+//
+// The motion is quasi-steady: the kernels see the body's instantaneous
+// velocity, but not the added-mass pressure of an accelerating body.
+type Motion struct {
+	Type  string
+	Start [3]float64
+	Time  float64 // Time since the motion started
+
+	Velocity, Acceleration [3]float64 // linear
+
+	Center [3]float64 // circular
+	Axis   [3]float64 // Unit axis, +z by default
+	Period float64
+
+	Points   [][3]float64 // waypoints
+	Duration float64
+	Loop     bool
+}
+
+// Validate checks the parameters of m
+func (m *Motion) Validate() error {
+	finite := func(v ...float64) bool {
+		for _, x := range v {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return false
+			}
+		}
+		return true
+	}
+	switch m.Type {
+	case MotionLinear:
+		if !finite(m.Velocity[:]...) || !finite(m.Acceleration[:]...) {
+			return Errorf(ErrBadArguments, "motion: velocity and acceleration must be finite")
+		}
+	case MotionCircular:
+		if !finite(m.Center[:]...) {
+			return Errorf(ErrBadArguments, "motion: center must be finite")
+		}
+		if n := math.Sqrt(m.Axis[0]*m.Axis[0] + m.Axis[1]*m.Axis[1] + m.Axis[2]*m.Axis[2]); math.Abs(n-1) > 1e-9 {
+			return Errorf(ErrBadArguments, "motion: axis must be a unit vector, got %v", m.Axis)
+		}
+		if m.Period == 0 || !finite(m.Period) {
+			return Errorf(ErrBadArguments, "motion: period must be a non-zero finite number, got %g", m.Period)
+		}
+	case MotionWaypoints:
+		if len(m.Points) == 0 {
+			return Errorf(ErrBadArguments, "motion: points must hold at least one point")
+		}
+		for _, p := range m.Points {
+			if !finite(p[:]...) {
+				return Errorf(ErrBadArguments, "motion: points must be finite")
+			}
+		}
+		if !(m.Duration > 0) || math.IsInf(m.Duration, 0) {
+			return Errorf(ErrBadArguments, "motion: duration must be a positive finite number, got %g", m.Duration)
+		}
+	default:
+		return Errorf(ErrBadArguments, "motion: type must be %q, %q or %q, got %q", MotionLinear, MotionCircular, MotionWaypoints, m.Type)
+	}
+	if !finite(m.Start[:]...) || !(m.Time >= 0) || math.IsInf(m.Time, 0) {
+		return Errorf(ErrBadArguments, "motion: start must be finite and time a non-negative finite number")
+	}
+	return nil
+}
+
+// At returns the position and velocity of the object t after the start
+func (m *Motion) At(t float64) (p, v [3]float64) {
+	switch m.Type {
+	case MotionLinear:
+		for a := range p {
+			p[a] = m.Start[a] + m.Velocity[a]*t + 0.5*m.Acceleration[a]*t*t
+			v[a] = m.Velocity[a] + m.Acceleration[a]*t
+		}
+
+	case MotionCircular:
+		// Rodrigues' rotation of the arm Start - center about the axis
+		w := 2 * math.Pi / m.Period
+		c, s := math.Cos(w*t), math.Sin(w*t)
+		k := m.Axis
+		r := [3]float64{m.Start[0] - m.Center[0], m.Start[1] - m.Center[1], m.Start[2] - m.Center[2]}
+		kr := k[0]*r[0] + k[1]*r[1] + k[2]*r[2]
+		kxr := cross(k, r)
+		var q [3]float64
+		for a := range q {
+			q[a] = r[a]*c + kxr[a]*s + k[a]*kr*(1-c)
+			p[a] = m.Center[a] + q[a]
+		}
+		wq := cross(k, q)
+		for a := range v {
+			v[a] = w * wq[a]
+		}
+
+	case MotionWaypoints:
+		return m.spline(t)
+	}
+	return p, v
+}
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+// spline evaluates the waypoint path at time t
+func (m *Motion) spline(t float64) (p, v [3]float64) {
+	pts := append([][3]float64{m.Start}, m.Points...)
+	segs := len(pts) - 1
+	if m.Loop {
+		segs++
+	}
+	if segs == 0 {
+		return m.Start, v
+	}
+	dt := m.Duration / float64(segs)
+	u := t / dt
+	if m.Loop {
+		u = math.Mod(u, float64(segs))
+	} else if u >= float64(segs) {
+		return pts[len(pts)-1], v
+	}
+	i := int(u)
+	s := u - float64(i)
+	at := func(j int) [3]float64 {
+		if m.Loop {
+			return pts[((j%len(pts))+len(pts))%len(pts)]
+		}
+		return pts[max(0, min(j, len(pts)-1))]
+	}
+	p0, p1, p2, p3 := at(i-1), at(i), at(i+1), at(i+2)
+	s2, s3 := s*s, s*s*s
+	for a := range p {
+		// The uniform Catmull-Rom basis, and its derivative with respect
+		// to s scaled to time
+		p[a] = 0.5 * (2*p1[a] + (p2[a]-p0[a])*s +
+			(2*p0[a]-5*p1[a]+4*p2[a]-p3[a])*s2 +
+			(3*p1[a]-p0[a]-3*p2[a]+p3[a])*s3)
+		v[a] = 0.5 * ((p2[a] - p0[a]) +
+			2*(2*p0[a]-5*p1[a]+4*p2[a]-p3[a])*s +
+			3*(3*p1[a]-p0[a]-3*p2[a]+p3[a])*s2) / dt
+	}
+	return p, v
+}
+
+// Progress returns the fraction of a waypoint path covered, in [0, 1]:
+// of the current lap with Loop set. Other motions report 0.
+func (m *Motion) Progress() float64 {
+	if m.Type != MotionWaypoints {
+		return 0
+	}
+	if m.Loop {
+		return math.Mod(m.Time, m.Duration) / m.Duration
+	}
+	return math.Min(m.Time/m.Duration, 1)
+}
+
+// Encode returns m in generic form: {type, params, start, time}
+func (m *Motion) Encode() map[string]interface{} {
+	vec := func(v [3]float64) []interface{} { return []interface{}{v[0], v[1], v[2]} }
+	params := map[string]interface{}{}
+	switch m.Type {
+	case MotionLinear:
+		params["velocity"], params["acceleration"] = vec(m.Velocity), vec(m.Acceleration)
+	case MotionCircular:
+		params["center"], params["axis"], params["period"] = vec(m.Center), vec(m.Axis), m.Period
+	case MotionWaypoints:
+		points := make([]interface{}, len(m.Points))
+		for i, p := range m.Points {
+			points[i] = vec(p)
+		}
+		params["points"], params["duration"], params["loop"] = points, m.Duration, m.Loop
+	}
+	return map[string]interface{}{"type": m.Type, "params": params, "start": vec(m.Start), "time": m.Time}
+}
+
+// DecodeMotion decodes a motion from generic values (see DecodeConfig):
+// {type, params, start, time}, where start, the object position by
+// default, and time, 0 by default, are only written by Encode. Unknown
+// keys are dropped with a warning if warnings is not nil, and rejected
+// otherwise.
+func DecodeMotion(v interface{}, start [3]float64, warnings *[]string) (*Motion, error) {
+	root, err := known(v, "motion", warnings, "type", "params", "start", "time")
+	if err != nil {
+		return nil, err
+	}
+	m := &Motion{Start: start, Axis: [3]float64{0, 0, 1}}
+	if m.Type, _ = root["type"].(string); m.Type == "" {
+		return nil, Errorf(ErrBadArguments, "motion.type must be a string")
+	}
+	if v, ok := root["start"]; ok {
+		if m.Start, err = vector(v, "motion.start"); err != nil {
+			return nil, err
+		}
+	}
+	if err := numberKey(root, "motion", "time", &m.Time); err != nil {
+		return nil, err
+	}
+	var params map[string]interface{}
+	if v, ok := root["params"]; ok {
+		allowed := map[string][]string{
+			MotionLinear:    {"velocity", "acceleration"},
+			MotionCircular:  {"center", "axis", "period"},
+			MotionWaypoints: {"points", "duration", "loop"},
+		}[m.Type]
+		if params, err = known(v, "motion.params", warnings, allowed...); err != nil {
+			return nil, err
+		}
+	}
+	vec := func(key string, dst *[3]float64) error {
+		if v, ok := params[key]; ok {
+			var err error
+			*dst, err = vector(v, "motion.params."+key)
+			return err
+		}
+		return nil
+	}
+	switch m.Type {
+	case MotionLinear:
+		if err := vec("velocity", &m.Velocity); err != nil {
+			return nil, err
+		}
+		if err := vec("acceleration", &m.Acceleration); err != nil {
+			return nil, err
+		}
+	case MotionCircular:
+		if err := vec("center", &m.Center); err != nil {
+			return nil, err
+		}
+		if err := vec("axis", &m.Axis); err != nil {
+			return nil, err
+		}
+		if m.Axis, err = unit(m.Axis, "motion.params.axis"); err != nil {
+			return nil, err
+		}
+		if err := numberKey(params, "motion.params", "period", &m.Period); err != nil {
+			return nil, err
+		}
+	case MotionWaypoints:
+		points, ok := params["points"].([]interface{})
+		if !ok {
+			return nil, Errorf(ErrBadArguments, "motion.params.points must be an array of [x, y, z] points")
+		}
+		m.Points = make([][3]float64, len(points))
+		for i, p := range points {
+			if m.Points[i], err = vector(p, "motion.params.points"); err != nil {
+				return nil, err
+			}
+		}
+		if err := numberKey(params, "motion.params", "duration", &m.Duration); err != nil {
+			return nil, err
+		}
+		if v, ok := params["loop"]; ok {
+			if m.Loop, ok = v.(bool); !ok {
+				return nil, Errorf(ErrBadArguments, "motion.params.loop must be a boolean")
+			}
+		}
+	}
+	return m, m.Validate()
+}
@@ -0,0 +1,153 @@
+package flow
+
+import (
+	"math"
+	"slices"
+)
+
+// NeighborGrid finds the points near a position without looking at the
+// others. The points are bucketed by the cubic cell of side Cell holding
+// them, the cells hashed into a table of about one bucket per point, so
+// building it and each query within a cell's distance take constant time
+// per point found, however far apart the points are. Non-finite points are
+// left out. A NeighborGrid is read-only once built, safe for concurrent
+// queries.
+type NeighborGrid struct {
+	Cell   float64
+	points []float64 // x, y, z of each point
+	mask   uint64    // Table size minus one
+	start  []int32   // Bucket b holds order[start[b]:start[b+1]]
+	order  []int32   // Point indices, by bucket
+}
+
+// NewNeighborGrid buckets count points of positions, x, y, z each, in
+// cells of side cell
+func NewNeighborGrid[T Float](positions []T, count int, cell float64) (*NeighborGrid, error) {
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return nil, err
+	}
+	if !(cell > 0) || math.IsInf(cell, 0) {
+		return nil, Errorf(ErrBadArguments, "the neighbor grid cell must be a positive finite size, got %g", cell)
+	}
+	size := uint64(1)
+	for size < uint64(count) {
+		size <<= 1
+	}
+	g := &NeighborGrid{Cell: cell, points: make([]float64, count*3), mask: size - 1, start: make([]int32, size+1)}
+	bucket := make([]int64, count)
+	for i := 0; i < count; i++ {
+		p := g.points[i*3 : i*3+3]
+		p[0], p[1], p[2] = float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+		ix, iy, iz, ok := g.cellOf(p[0], p[1], p[2])
+		if !ok {
+			bucket[i] = -1
+			continue
+		}
+		b := g.hash(ix, iy, iz)
+		bucket[i] = int64(b)
+		g.start[b+1]++
+	}
+	for b := uint64(0); b < size; b++ {
+		g.start[b+1] += g.start[b]
+	}
+	g.order = make([]int32, g.start[size])
+	next := append([]int32(nil), g.start[:size]...)
+	for i, b := range bucket {
+		if b >= 0 {
+			g.order[next[b]] = int32(i)
+			next[b]++
+		}
+	}
+	return g, nil
+}
+
+// cellOf returns the cell holding (x, y, z), or false if it is not finite
+// or so far out that its cell index overflows
+func (g *NeighborGrid) cellOf(x, y, z float64) (ix, iy, iz int64, ok bool) {
+	const limit = 1 << 62
+	fx, fy, fz := math.Floor(x/g.Cell), math.Floor(y/g.Cell), math.Floor(z/g.Cell)
+	if !(math.Abs(fx) < limit && math.Abs(fy) < limit && math.Abs(fz) < limit) {
+		return 0, 0, 0, false
+	}
+	return int64(fx), int64(fy), int64(fz), true
+}
+
+// hash returns the bucket of cell (ix, iy, iz)
+func (g *NeighborGrid) hash(ix, iy, iz int64) uint64 {
+	return uint64(ix*73856093^iy*19349663^iz*83492791) & g.mask
+}
+
+// Len returns the number of points, the finite ones and the others
+func (g *NeighborGrid) Len() int { return len(g.points) / 3 }
+
+// Bytes returns the memory the grid holds
+func (g *NeighborGrid) Bytes() int {
+	return len(g.points)*8 + (len(g.start)+len(g.order))*4
+}
+
+// Near calls fn with the index and squared distance of every point within
+// radius of (x, y, z), each once, in no particular order. It looks at the
+// cells within radius, 27 of them for a radius up to Cell, or at every
+// point if there are more cells than buckets.
+func (g *NeighborGrid) Near(x, y, z, radius float64, fn func(j int, r2 float64)) {
+	cx, cy, cz, ok := g.cellOf(x, y, z)
+	if !ok || !(radius >= 0) {
+		return
+	}
+	r2 := radius * radius
+	if cells := 2*math.Ceil(radius/g.Cell) + 1; cells*cells*cells > float64(len(g.start)-1) {
+		for _, j := range g.order {
+			p := g.points[j*3 : j*3+3]
+			ex, ey, ez := p[0]-x, p[1]-y, p[2]-z
+			if d := ex*ex + ey*ey + ez*ez; d <= r2 {
+				fn(int(j), d)
+			}
+		}
+		return
+	}
+	span := int64(math.Ceil(radius / g.Cell))
+	// Distinct cells may share a bucket. Up to 27 cells each bucket is
+	// scanned once; beyond, the points of a bucket are taken for the cell
+	// holding them only.
+	var seen [27]uint64
+	visited := seen[:0]
+	for dz := -span; dz <= span; dz++ {
+		for dy := -span; dy <= span; dy++ {
+		cells:
+			for dx := -span; dx <= span; dx++ {
+				b := g.hash(cx+dx, cy+dy, cz+dz)
+				if span <= 1 {
+					for _, v := range visited {
+						if v == b {
+							continue cells
+						}
+					}
+					visited = append(visited, b)
+				}
+				for _, j := range g.order[g.start[b]:g.start[b+1]] {
+					p := g.points[j*3 : j*3+3]
+					ex, ey, ez := p[0]-x, p[1]-y, p[2]-z
+					if d := ex*ex + ey*ey + ez*ez; d <= r2 {
+						if span > 1 {
+							if ix, iy, iz, _ := g.cellOf(p[0], p[1], p[2]); ix != cx+dx || iy != cy+dy || iz != cz+dz {
+								continue
+							}
+						}
+						fn(int(j), d)
+					}
+				}
+			}
+		}
+	}
+}
+
+// AppendNear appends to dst the indices of the points within radius of
+// (x, y, z) in increasing order
+func (g *NeighborGrid) AppendNear(dst []uint32, x, y, z, radius float64) []uint32 {
+	from := len(dst)
+	g.Near(x, y, z, radius, func(j int, r2 float64) {
+		dst = append(dst, uint32(j))
+	})
+	slices.Sort(dst[from:])
+	return dst
+}
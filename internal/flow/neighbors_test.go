@@ -0,0 +1,54 @@
+package flow_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestNeighborQuery(t *testing.T) {
+	run(t, (*checker).checkNeighborQuery)
+}
+
+// checkNeighborQuery compares the neighbor grid queries against a scan of
+// every particle, for radii within a cell, spanning several cells, where
+// distinct cells share buckets, and spanning more cells than buckets, with
+// a NaN particle never found
+func (c *checker) checkNeighborQuery() error {
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 500, Seed: 11, Grid: flow.Grid{Min: [3]float64{-4, -4, -4}, Max: [3]float64{4, 4, 4}}}
+	p, err := seed.Positions()
+	if err != nil {
+		return err
+	}
+	p = append(p, float32(math.NaN()), 0, 0)
+	n := len(p) / 3
+	g, err := flow.NewNeighborGrid(p, n, 0.5)
+	if err != nil {
+		return err
+	}
+	seed.Count, seed.Seed = 50, 12
+	queries, err := seed.Positions()
+	if err != nil {
+		return err
+	}
+	ok, found := true, 0
+	for _, radius := range []float64{0.3, 0.5, 1.7, 20} {
+		for q := 0; q < len(queries); q += 3 {
+			x, y, z := float64(queries[q]), float64(queries[q+1]), float64(queries[q+2])
+			var want []uint32
+			for j := 0; j < n; j++ {
+				dx, dy, dz := float64(p[j*3])-x, float64(p[j*3+1])-y, float64(p[j*3+2])-z
+				if dx*dx+dy*dy+dz*dz <= radius*radius {
+					want = append(want, uint32(j))
+				}
+			}
+			got := g.AppendNear(nil, x, y, z, radius)
+			ok = ok && reflect.DeepEqual(got, want)
+			found += len(got)
+		}
+	}
+	c.report("neighbor query", ok && g.Len() == n && g.Bytes() > 0, "%d neighbors found for 50 points at 4 radii, as a full scan %v; the grid of %d particles holds %d bytes", found, ok, g.Len(), g.Bytes())
+	return nil
+}
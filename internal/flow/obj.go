@@ -0,0 +1,66 @@
+package flow
+
+import "strconv"
+
+// OBJ writes m as Wavefront OBJ text with vertex normals. Faces reference
+// each vertex's position and normal by the same (1-based) index. Positions
+// are written in the length unit of u, named in a comment.
+func OBJ(m *Mesh, u Units) []byte {
+	b := make([]byte, 0, 64+len(m.Positions)*24+len(m.Indices)*8)
+	b = append(b, "# fluid simulation object surface\n# units: length "+u.Length+"\n"...)
+	scale := u.Factor(DimLength)
+	for i := 0; i < len(m.Positions); i += 3 {
+		b = appendOBJLine(b, "v", m.Positions[i:i+3], scale)
+	}
+	for i := 0; i < len(m.Normals); i += 3 {
+		b = appendOBJLine(b, "vn", m.Normals[i:i+3], 1)
+	}
+	for i := 0; i < len(m.Indices); i += 3 {
+		b = append(b, 'f')
+		for _, idx := range m.Indices[i : i+3] {
+			b = append(b, ' ')
+			b = strconv.AppendUint(b, uint64(idx)+1, 10)
+			b = append(b, "//"...)
+			b = strconv.AppendUint(b, uint64(idx)+1, 10)
+		}
+		b = append(b, '\n')
+	}
+	return b
+}
+
+// OBJLines writes lines as Wavefront OBJ text with one line element per
+// polyline, in the length unit of u
+func OBJLines(lines []Polyline, u Units) []byte {
+	b := []byte("# fluid simulation streamlines\n# units: length " + u.Length + "\n")
+	scale := u.Factor(DimLength)
+	for _, l := range lines {
+		for i := 0; i < l.Len(); i++ {
+			b = appendOBJLine(b, "v", l[i*3:i*3+3], scale)
+		}
+	}
+	first := 1
+	for _, l := range lines {
+		if l.Len() < 2 {
+			first += l.Len()
+			continue
+		}
+		b = append(b, 'l')
+		for i := 0; i < l.Len(); i++ {
+			b = append(b, ' ')
+			b = strconv.AppendInt(b, int64(first+i), 10)
+		}
+		b = append(b, '\n')
+		first += l.Len()
+	}
+	return b
+}
+
+// appendOBJLine appends a keyword followed by the values divided by scale
+func appendOBJLine(b []byte, keyword string, vals []float32, scale float64) []byte {
+	b = append(b, keyword...)
+	for _, v := range vals {
+		b = append(b, ' ')
+		b = strconv.AppendFloat(b, float64(float32(float64(v)/scale)), 'g', -1, 32)
+	}
+	return append(b, '\n')
+}
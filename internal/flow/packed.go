@@ -0,0 +1,120 @@
+package flow
+
+import "math"
+
+// ObjectStride is the number of floats of an object in the packed form:
+//
+//	[type, x, y, z, radius, p0, p1, p2, p3]
+//
+// type is the ObjectType code, x, y, z the position and radius the Radius
+// of ObjectSpec, lengths all. p0..p3 depend on the type; the unused ones
+// are 0:
+//
+//	sphere, airfoil:   none
+//	cylinder:          p0 Splitter
+//	plate:             p0 Alpha, p1 EdgeRadius
+//	ellipticCylinder:  p0 Alpha, p1 SemiMinor, p2 Kutta (non-zero for true)
+//	torus:             p0 MinorRadius
+//	box:               p0 Alpha, p1..p3 Size
+//
+// Alpha is in radians, the others are lengths. Object i takes floats
+// i*ObjectStride to (i+1)*ObjectStride. The other fields, strengths and
+// discretizations, aren't packed: unpacking keeps them.
+const ObjectStride = 9
+
+// packedParams lists the fields p0..p3 of each type, and whether each is a
+// length
+func packedParams(o *ObjectSpec) (p [4]*float64, length [4]bool) {
+	switch o.Type {
+	case Cylinder:
+		return [4]*float64{&o.Splitter}, [4]bool{true}
+	case Plate:
+		return [4]*float64{&o.Alpha, &o.EdgeRadius}, [4]bool{false, true}
+	case EllipticCylinder:
+		return [4]*float64{&o.Alpha, &o.SemiMinor}, [4]bool{false, true}
+	case Torus:
+		return [4]*float64{&o.MinorRadius}, [4]bool{true}
+	case Box:
+		return [4]*float64{&o.Alpha, &o.Size[0], &o.Size[1], &o.Size[2]}, [4]bool{false, true, true, true}
+	}
+	return p, length
+}
+
+// PackObjects writes objects to dst in the packed form, converting the
+// lengths to u, dst holding at least len(objects)*ObjectStride floats
+func PackObjects(dst []float32, objects []ObjectSpec, u *Units) {
+	for i := range objects {
+		o := objects[i]
+		b := dst[i*ObjectStride : (i+1)*ObjectStride]
+		clear(b)
+		b[0] = float32(o.Type)
+		for a, x := range [...]float64{o.X, o.Y, o.Z, o.Radius} {
+			b[1+a] = float32(u.FromSI(DimLength, x))
+		}
+		p, length := packedParams(&o)
+		for a := range p {
+			switch {
+			case p[a] == nil:
+			case length[a]:
+				b[5+a] = float32(u.FromSI(DimLength, *p[a]))
+			default:
+				b[5+a] = float32(*p[a])
+			}
+		}
+		if o.Type == EllipticCylinder && o.Kutta {
+			b[7] = 1
+		}
+	}
+}
+
+// UnpackObjects reads count objects of the packed form from buf into
+// objects, its lengths in u, keeping the fields it doesn't hold. An
+// unknown type code fails with the index of its object. objects is left
+// as it was unless every object unpacks.
+func UnpackObjects(buf []float32, count int, objects []ObjectSpec, u *Units) error {
+	if count < 0 || count > len(objects) {
+		return Errorf(ErrBadArguments, "packed objects: %d objects, %d expected at most", count, len(objects))
+	}
+	if len(buf) < count*ObjectStride {
+		return Errorf(ErrBufferLength, "packed objects have %d elements, need %d for %d objects", len(buf), count*ObjectStride, count)
+	}
+	out := make([]ObjectSpec, count)
+	for i := range out {
+		b := buf[i*ObjectStride : (i+1)*ObjectStride]
+		t, known := float64(b[0]), false
+		for _, v := range objectTypeNames {
+			known = known || float64(v) == t
+		}
+		if !known {
+			return Errorf(ErrBadArguments, "packed objects: unknown type code %g at index %d", t, i)
+		}
+		for _, x := range b {
+			if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+				return Errorf(ErrBadArguments, "packed objects: non-finite parameter at index %d", i)
+			}
+		}
+		o := objects[i]
+		if o.Type = ObjectType(t); o.Type != objects[i].Type {
+			// The parameters of the old type don't carry over
+			o.Splitter, o.Alpha, o.EdgeRadius, o.SemiMinor, o.Kutta, o.MinorRadius, o.Size = 0, 0, 0, 0, false, 0, [3]float64{}
+		}
+		o.X, o.Y, o.Z = u.ToSI(DimLength, float64(b[1])), u.ToSI(DimLength, float64(b[2])), u.ToSI(DimLength, float64(b[3]))
+		o.Radius = u.ToSI(DimLength, float64(b[4]))
+		p, length := packedParams(&o)
+		for a := range p {
+			switch {
+			case p[a] == nil:
+			case length[a]:
+				*p[a] = u.ToSI(DimLength, float64(b[5+a]))
+			default:
+				*p[a] = float64(b[5+a])
+			}
+		}
+		if o.Type == EllipticCylinder {
+			o.Kutta = b[7] != 0
+		}
+		out[i] = o
+	}
+	copy(objects, out)
+	return nil
+}
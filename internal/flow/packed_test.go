@@ -0,0 +1,69 @@
+package flow_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestPackedObjects(t *testing.T) {
+	run(t, (*checker).checkPackedObjects)
+}
+
+// checkPackedObjects round-trips every object type through the packed
+// form, in SI and in millimetres, and checks that an unknown type code
+// fails with its index and leaves the objects as they were.
+func (c *checker) checkPackedObjects() error {
+	objects := []flow.ObjectSpec{
+		{Type: flow.Sphere, X: 1, Y: -2, Z: 0.5, Radius: 0.75, CollisionRadius: 0.8},
+		{Type: flow.Cylinder, Radius: 1, Splitter: 2},
+		{Type: flow.Airfoil, Y: 0.25, Radius: 1.5, Strengths: flow.Strengths{Circulation: 3, Set: flow.StrengthCirculation}},
+		{Type: flow.Plate, Radius: 1, Alpha: 0.2, EdgeRadius: 0.05},
+		{Type: flow.EllipticCylinder, Radius: 1, Alpha: -0.1, SemiMinor: 0.4, Kutta: true},
+		{Type: flow.Torus, Radius: 2, MinorRadius: 0.5, Collocation: 24},
+		{Type: flow.Box, Radius: 1, Alpha: 0.3, Size: [3]float64{1, 2, 0.5}, Panels: 12},
+	}
+	mm := flow.Units{Length: "mm", Velocity: "m/s", Density: "kg/m3", Pressure: "Pa"}
+	roundTrip := true
+	for _, u := range []flow.Units{flow.SIUnits(), mm} {
+		buf := make([]float32, len(objects)*flow.ObjectStride)
+		flow.PackObjects(buf, objects, &u)
+		got := make([]flow.ObjectSpec, len(objects))
+		copy(got, objects)
+		for i := range got {
+			got[i].X, got[i].Radius = 9, 9
+		}
+		if err := flow.UnpackObjects(buf, len(got), got, &u); err != nil {
+			return err
+		}
+		for i := range got {
+			// Float32 storage rounds the parameters; compare them so
+			want, o := objects[i], got[i]
+			roundTrip = roundTrip && math.Abs(o.X-want.X) < 1e-6 && math.Abs(o.Radius-want.Radius) < 1e-6 &&
+				math.Abs(o.Alpha-want.Alpha) < 1e-6 && math.Abs(o.Size[1]-want.Size[1]) < 1e-6 &&
+				math.Abs(o.Splitter-want.Splitter) < 1e-6 && math.Abs(o.SemiMinor-want.SemiMinor) < 1e-6 &&
+				math.Abs(o.MinorRadius-want.MinorRadius) < 1e-6 && math.Abs(o.EdgeRadius-want.EdgeRadius) < 1e-6 &&
+				o.Type == want.Type && o.Kutta == want.Kutta && o.Strengths == want.Strengths &&
+				o.Collocation == want.Collocation && o.Panels == want.Panels && o.CollisionRadius == want.CollisionRadius
+		}
+		roundTrip = roundTrip && (u.Length != "mm" || buf[flow.ObjectStride+4] == 1000)
+	}
+
+	buf := make([]float32, 2*flow.ObjectStride)
+	flow.PackObjects(buf, objects[:2], &mm)
+	buf[flow.ObjectStride] = 7
+	got := []flow.ObjectSpec{objects[5], objects[6]}
+	err := flow.UnpackObjects(buf, 2, got, &mm)
+	e, indexed := err.(*flow.Error)
+	indexed = indexed && e.Code == flow.ErrBadArguments && strings.Contains(err.Error(), "index 1") &&
+		got[0] == objects[5] && got[1] == objects[6]
+	buf[flow.ObjectStride] = 1.5
+	indexed = indexed && flow.UnpackObjects(buf, 2, got, &mm) != nil
+	e, short := flow.UnpackObjects(buf[:flow.ObjectStride+3], 2, got, &mm).(*flow.Error)
+	indexed = indexed && short && e.Code == flow.ErrBufferLength
+
+	c.report("packed objects", roundTrip && indexed, "round trip %v; unknown types and short buffers rejected %v (%v)", roundTrip, indexed, err)
+	return nil
+}
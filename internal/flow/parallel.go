@@ -0,0 +1,76 @@
+package flow
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// workerCount is the number of goroutines the particle kernels split their
+// work across; 0 means GOMAXPROCS
+var workerCount atomic.Int32
+
+// minChunk is the smallest number of particles worth handing to a worker
+// of its own; smaller batches run on fewer workers
+const minChunk = 4096
+
+// SetWorkers sets the number of workers used by VelocitiesInto,
+// PressuresInto and Advect. 0 restores the default, GOMAXPROCS; 1 keeps
+// every kernel on the calling goroutine. On js/wasm builds without threads
+// GOMAXPROCS is 1, so the default stays single-threaded there.
+func SetWorkers(n int) error {
+	if n < 0 || n > MaxWorkers {
+		return Errorf(ErrBadArguments, "worker count must be between 0 and %d, got %d", MaxWorkers, n)
+	}
+	workerCount.Store(int32(n))
+	LogValue(LogInfo, "workers", "worker count set", float64(Workers()))
+	return nil
+}
+
+// MaxWorkers is the largest worker count accepted by SetWorkers
+const MaxWorkers = 256
+
+// Workers returns the effective number of workers
+func Workers() int {
+	if n := int(workerCount.Load()); n > 0 {
+		return n
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// chunks returns the number of chunks count particles are split into. The
+// kernels process a single chunk directly rather than through parallel, so
+// the single-threaded path allocates nothing.
+func chunks(count int) int {
+	return min(Workers(), (count+minChunk-1)/minChunk)
+}
+
+// parallel runs fn over [0, count) split into contiguous chunks, one per
+// worker, and returns the sum of the counts fn returns and the error of the
+// first failing chunk. Each particle is processed exactly once by the same
+// code whatever the chunking, so results don't depend on the worker count.
+func parallel(count int, fn func(from, to int) (int, error)) (int, error) {
+	w := chunks(count)
+	if w <= 1 {
+		return fn(0, count)
+	}
+	sums := make([]int, w)
+	errs := make([]error, w)
+	var wg sync.WaitGroup
+	for i := 0; i < w; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sums[i], errs[i] = fn(count*i/w, count*(i+1)/w)
+		}(i)
+	}
+	wg.Wait()
+	total := 0
+	for i := range sums {
+		if errs[i] != nil {
+			return 0, errs[i]
+		}
+		total += sums[i]
+	}
+	return total, nil
+}
@@ -0,0 +1,61 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestWorkers(t *testing.T) {
+	forTypes(t, (*checker).checkWorkers, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+// checkWorkers verifies that splitting the kernels across workers gives
+// bit-identical velocities, pressures and advected positions
+func (c *checker) checkWorkers(t flow.ObjectType) error {
+	defer flow.SetWorkers(flow.Workers())
+	f := checkFlow(t)
+	f.Cutoff = 8
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: workerParticles, Seed: 3,
+		Grid: flow.Grid{Min: [3]float64{-10, -10, -10}, Max: [3]float64{10, 10, 10}}}
+	var want []float32
+	far0 := 0
+	for _, w := range []int{1, 3, 8} {
+		flow.SetWorkers(w)
+		positions, err := seed.Positions()
+		if err != nil {
+			return err
+		}
+		count := len(positions) / 3
+		velocities := make([]float32, count*3)
+		far, err := flow.VelocitiesIntoCounted(velocities, positions, count, f)
+		if err != nil {
+			return err
+		}
+		pressures, err := flow.Pressures(velocities, count, f.FreeStream, f.Density)
+		if err != nil {
+			return err
+		}
+		if err := flow.Advect(positions, velocities, count, flow.DefaultDT); err != nil {
+			return err
+		}
+		got := append(append(velocities, pressures...), positions...)
+		if want == nil {
+			want, far0 = got, far
+			continue
+		}
+		diff := 0
+		for i := range got {
+			if math.Float32bits(got[i]) != math.Float32bits(want[i]) {
+				diff++
+			}
+		}
+		if diff > 0 || far != far0 {
+			c.report(t.String()+" workers", false, "%d workers: %d values differ from 1 worker, far-field count %d vs %d", w, diff, far, far0)
+			return nil
+		}
+	}
+	c.report(t.String()+" workers", true, "1, 3 and 8 workers agree bit for bit on %d particles", workerParticles)
+	return nil
+}
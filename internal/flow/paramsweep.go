@@ -0,0 +1,270 @@
+package flow
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Outputs of a ParameterSweep
+const (
+	SweepGrid      = "grid"      // Velocity on a Grid
+	SweepProbeLine = "probeLine" // Velocity at points evenly spaced along a line
+	SweepForces    = "forces"    // Pressure force and moment on the object
+)
+
+// Limits of a ParameterSweep
+const (
+	MaxSweepValues = 4096
+	MaxSweepOutput = 1 << 26 // Values of the stacked output
+)
+
+// ParameterSweep evaluates an output for each of Values set in turn at
+// Path in a simulation's configuration, a dotted path into its encoded
+// form (see Config.Encode) such as "object.radius" or "freeStream.speed",
+// with numbers for array indices, "object.position.1". The leaf must hold
+// a number. Each value gives Stride output values, stacked in value order.
+type ParameterSweep struct {
+	Path   string
+	Values []float64
+	Kind   string
+
+	Grid       Grid       // SweepGrid
+	From, To   [3]float64 // SweepProbeLine
+	Samples    int        // SweepProbeLine
+	Resolution int        // SweepForces, see PressureForce
+}
+
+// Validate checks the parameters of p
+func (p *ParameterSweep) Validate() error {
+	if p.Path == "" {
+		return Errorf(ErrBadArguments, "sweep.parameterPath must not be empty")
+	}
+	if n := len(p.Values); n < 1 || n > MaxSweepValues {
+		return Errorf(ErrBadArguments, "sweep.values needs 1 to %d values, got %d", MaxSweepValues, n)
+	}
+	for _, v := range p.Values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return Errorf(ErrBadArguments, "sweep.values must be finite")
+		}
+	}
+	switch p.Kind {
+	case SweepGrid:
+		if err := p.Grid.Validate(); err != nil {
+			return err
+		}
+	case SweepProbeLine:
+		if p.Samples < 1 {
+			return Errorf(ErrBadArguments, "sweep.output.spec.samples must be positive, got %d", p.Samples)
+		}
+	case SweepForces:
+		if p.Resolution < MinForceResolution || p.Resolution > MaxForceResolution {
+			return Errorf(ErrBadArguments, "force resolution must be between %d and %d, got %d", MinForceResolution, MaxForceResolution, p.Resolution)
+		}
+	default:
+		return Errorf(ErrBadArguments, "unknown sweep output %q, want %q, %q or %q", p.Kind, SweepGrid, SweepProbeLine, SweepForces)
+	}
+	if total := float64(len(p.Values)) * float64(p.Stride()); total > MaxSweepOutput {
+		return Errorf(ErrBadArguments, "the sweep output holds %g values, more than %d", total, MaxSweepOutput)
+	}
+	return nil
+}
+
+// Stride returns the number of output values per value of the sweep:
+// three velocity components per point for SweepGrid and SweepProbeLine,
+// and the force and moment, six components, for SweepForces
+func (p *ParameterSweep) Stride() int {
+	switch p.Kind {
+	case SweepGrid:
+		return 3 * p.Grid.Len()
+	case SweepProbeLine:
+		return 3 * p.Samples
+	}
+	return 6
+}
+
+// Flow returns the flow of s with value set at p.Path in its
+// configuration, s itself left as it is. The free stream schedule, motion
+// and elements of s apply as they do to its own flow, so a speed the
+// schedule drives can't be swept.
+func (p *ParameterSweep) Flow(s *Simulation, value float64) (Flow, error) {
+	if s.Schedule != nil && p.Path == "freeStream.speed" {
+		return Flow{}, Errorf(ErrBadArguments, "freeStream.speed follows the free stream schedule and can't be swept")
+	}
+	root := s.Config.Encode()
+	var node interface{} = root
+	keys := strings.Split(p.Path, ".")
+	for i, key := range keys {
+		path := strings.Join(keys[:i+1], ".")
+		var leaf interface{}
+		var set func(interface{})
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[key]
+			if !ok {
+				return Flow{}, Errorf(ErrBadArguments, "sweep: the configuration has no %s", path)
+			}
+			leaf, set = v, func(x interface{}) { n[key] = x }
+		case []interface{}:
+			j, err := strconv.Atoi(key)
+			if err != nil || j < 0 || j >= len(n) {
+				return Flow{}, Errorf(ErrBadArguments, "sweep: %s is not an index of an array of %d", path, len(n))
+			}
+			leaf, set = n[j], func(x interface{}) { n[j] = x }
+		default:
+			return Flow{}, Errorf(ErrBadArguments, "sweep: %s is not a section of the configuration", strings.Join(keys[:i], "."))
+		}
+		if i == len(keys)-1 {
+			if _, ok := leaf.(float64); !ok {
+				return Flow{}, Errorf(ErrBadArguments, "sweep: %s doesn't hold a number", path)
+			}
+			set(value)
+		}
+		node = leaf
+	}
+	c, err := DecodeConfig(root)
+	if err != nil {
+		return Flow{}, err
+	}
+	t := Simulation{Config: c, Schedule: s.Schedule, Motion: s.Motion, Elements: s.Elements, Time: s.Time}
+	f := t.Flow()
+	return f, f.Validate()
+}
+
+// Evaluate writes the output of p for f to out, which holds Stride values
+func (p *ParameterSweep) Evaluate(f Flow, out []float64) error {
+	switch p.Kind {
+	case SweepGrid:
+		return VelocitiesInto(out, p.Grid.Positions(), p.Grid.Len(), f)
+	case SweepProbeLine:
+		positions := make([]float64, 3*p.Samples)
+		for i := 0; i < p.Samples; i++ {
+			t := 0.0
+			if p.Samples > 1 {
+				t = float64(i) / float64(p.Samples-1)
+			}
+			for a := 0; a < 3; a++ {
+				positions[i*3+a] = p.From[a] + t*(p.To[a]-p.From[a])
+			}
+		}
+		return VelocitiesInto(out, positions, p.Samples, f)
+	}
+	force, moment, err := f.pressureLoads(p.Resolution)
+	if err != nil {
+		return err
+	}
+	copy(out, force[:])
+	copy(out[3:], moment[:])
+	return nil
+}
+
+// SweepDims lists the dimensional keys of a parameter sweep's output
+// spec (see DecodeParameterSweep); its values take the dimension of
+// their path in ConfigDims
+var SweepDims = map[string]Dim{
+	"output.spec.min":  DimLength,
+	"output.spec.max":  DimLength,
+	"output.spec.from": DimLength,
+	"output.spec.to":   DimLength,
+}
+
+// DecodeParameterSweep decodes a parameter sweep from generic values (see
+// DecodeConfig):
+//
+//	{parameterPath, values: [number, ...],
+//	 output: {kind: "grid", spec: {min, max, resolution}} |
+//	         {kind: "probeLine", spec: {from, to, samples = 100}} |
+//	         {kind: "forces", spec: {resolution = 32}}}
+//
+// values are in SI units; PathDim gives their dimension.
+func DecodeParameterSweep(v interface{}) (ParameterSweep, error) {
+	p := ParameterSweep{Samples: 100, Resolution: DefaultForceResolution}
+	m, err := section(v, "sweep", "parameterPath", "values", "output")
+	if err != nil {
+		return p, err
+	}
+	if p.Path, _ = m["parameterPath"].(string); p.Path == "" {
+		return p, Errorf(ErrBadArguments, "sweep.parameterPath must be a non-empty string")
+	}
+	list, ok := m["values"].([]interface{})
+	if !ok {
+		return p, Errorf(ErrBadArguments, "sweep.values must be an array")
+	}
+	if len(list) > MaxSweepValues {
+		return p, Errorf(ErrBadArguments, "sweep.values holds %d values, more than %d", len(list), MaxSweepValues)
+	}
+	p.Values = make([]float64, len(list))
+	for i, x := range list {
+		if p.Values[i], ok = x.(float64); !ok {
+			return p, Errorf(ErrBadArguments, "sweep.values[%d] must be a number", i)
+		}
+	}
+	out, err := section(m["output"], "sweep.output", "kind", "spec")
+	if err != nil {
+		return p, err
+	}
+	p.Kind, _ = out["kind"].(string)
+	spec := out["spec"]
+	if spec == nil {
+		spec = map[string]interface{}{}
+	}
+	const path = "sweep.output.spec"
+	switch p.Kind {
+	case SweepGrid:
+		s, err := section(spec, path, "min", "max", "resolution")
+		if err != nil {
+			return p, err
+		}
+		if p.Grid, err = gridKeys(s, path); err != nil {
+			return p, err
+		}
+	case SweepProbeLine:
+		s, err := section(spec, path, "from", "to", "samples")
+		if err != nil {
+			return p, err
+		}
+		for _, e := range []struct {
+			key string
+			dst *[3]float64
+		}{{"from", &p.From}, {"to", &p.To}} {
+			if *e.dst, err = vector(s[e.key], path+"."+e.key); err != nil {
+				return p, err
+			}
+		}
+		samples := float64(p.Samples)
+		if err := numberKey(s, path, "samples", &samples); err != nil {
+			return p, err
+		}
+		if samples != math.Trunc(samples) {
+			return p, Errorf(ErrBadArguments, "%s.samples must be an integer, got %g", path, samples)
+		}
+		p.Samples = int(samples)
+	case SweepForces:
+		s, err := section(spec, path, "resolution")
+		if err != nil {
+			return p, err
+		}
+		n := float64(p.Resolution)
+		if err := numberKey(s, path, "resolution", &n); err != nil {
+			return p, err
+		}
+		if n != math.Trunc(n) {
+			return p, Errorf(ErrBadArguments, "%s.resolution must be an integer, got %g", path, n)
+		}
+		p.Resolution = int(n)
+	}
+	return p, p.Validate()
+}
+
+// PathDim returns the dimension of the configuration value at a dotted
+// path, array indices dropped, DimNone if it has none
+func PathDim(path string) Dim {
+	keys := strings.Split(path, ".")
+	for len(keys) > 0 {
+		if _, err := strconv.Atoi(keys[len(keys)-1]); err != nil {
+			break
+		}
+		keys = keys[:len(keys)-1]
+	}
+	return ConfigDims[strings.Join(keys, ".")]
+}
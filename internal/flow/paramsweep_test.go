@@ -0,0 +1,104 @@
+package flow_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestParameterSweep(t *testing.T) {
+	run(t, (*checker).checkParameterSweep)
+}
+
+// checkParameterSweep verifies parameter sweeps of a simulation with an
+// oblique free stream: the stacked probe line velocities of a radius sweep
+// and grid velocities of a free stream speed sweep against the flows of
+// the configurations set by hand, the forces of a sweep of the sphere's
+// position against its unswept force, and the configuration left as it
+// was
+func (c *checker) checkParameterSweep() error {
+	s := flow.NewSimulation(flow.DefaultConfig())
+	s.Config.FreeStream.Direction = [3]float64{0.6, 0.64, 0.48}
+	before := s.Config.Encode()
+	worst := 0.0
+	for _, t := range []struct {
+		path   string
+		values []interface{}
+		output map[string]interface{}
+		set    func(*flow.Config, float64)
+	}{
+		{"object.radius", []interface{}{0.5, 1.0, 1.5}, map[string]interface{}{"kind": flow.SweepProbeLine, "spec": map[string]interface{}{"from": []interface{}{-3.0, 0.5, 0.2}, "to": []interface{}{3.0, -0.5, 1.2}, "samples": 17.0}},
+			func(c *flow.Config, v float64) { c.Object.Radius = v }},
+		{"freeStream.speed", []interface{}{0.5, 2.0}, map[string]interface{}{"kind": flow.SweepGrid, "spec": map[string]interface{}{"min": []interface{}{-2.0, -2.0, -2.0}, "max": []interface{}{2.0, 2.0, 2.0}, "resolution": []interface{}{5.0, 4.0, 3.0}}},
+			func(c *flow.Config, v float64) { c.FreeStream.Speed = v }},
+	} {
+		p, err := flow.DecodeParameterSweep(map[string]interface{}{"parameterPath": t.path, "values": t.values, "output": t.output})
+		if err != nil {
+			return err
+		}
+		var positions []float64
+		if p.Kind == flow.SweepGrid {
+			for _, x := range p.Grid.Positions() {
+				positions = append(positions, float64(x))
+			}
+		} else {
+			for i := 0; i < p.Samples; i++ {
+				u := float64(i) / float64(p.Samples-1)
+				for a := 0; a < 3; a++ {
+					positions = append(positions, p.From[a]+u*(p.To[a]-p.From[a]))
+				}
+			}
+		}
+		n := len(positions) / 3
+		stride := p.Stride()
+		got, want := make([]float64, stride), make([]float64, stride)
+		for _, v := range p.Values {
+			f, err := p.Flow(s, v)
+			if err != nil {
+				return err
+			}
+			if err := p.Evaluate(f, got); err != nil {
+				return err
+			}
+			config := s.Config
+			t.set(&config, v)
+			if err := flow.VelocitiesInto(want, positions, n, config.Flow()); err != nil {
+				return err
+			}
+			for i := range got {
+				worst = math.Max(worst, math.Abs(got[i]-want[i]))
+			}
+		}
+	}
+	p, err := flow.DecodeParameterSweep(map[string]interface{}{"parameterPath": "object.position.0", "values": []interface{}{-1.0, 2.0}, "output": map[string]interface{}{"kind": flow.SweepForces}})
+	if err != nil {
+		return err
+	}
+	f := s.Flow()
+	want, err := f.PressureForce(p.Resolution)
+	if err != nil {
+		return err
+	}
+	forces := 0.0
+	got := make([]float64, p.Stride())
+	for _, v := range p.Values {
+		g, err := p.Flow(s, v)
+		if err != nil {
+			return err
+		}
+		if err := p.Evaluate(g, got); err != nil {
+			return err
+		}
+		for k := 0; k < 3; k++ {
+			forces = math.Max(forces, math.Abs(got[k]-want.Force[k]))
+		}
+	}
+	p.Path = "object.position.3"
+	_, bad := p.Flow(s, 0)
+	unchanged := reflect.DeepEqual(before, s.Config.Encode())
+	ok := worst < trigTolerance && forces < trigTolerance && bad != nil && unchanged
+	c.report("parameter sweep", ok, "velocities against configured flows |Δ| %.3g; forces moving the sphere |Δ| %.3g; index out of range rejected %v; configuration unchanged %v", worst, forces, bad != nil, unchanged)
+	return nil
+}
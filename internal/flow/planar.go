@@ -0,0 +1,36 @@
+package flow
+
+// Layouts of the particle vector buffers exchanged with the API: the
+// interleaved [x0, y0, z0, x1, ...] the kernels work on, or the planar
+// structure of arrays, the count x components followed by the y and the z
+// components
+const (
+	VectorsInterleaved = "interleaved"
+	VectorsPlanar      = "planar"
+)
+
+// CheckVectorLayout rejects unknown vector layouts; the empty string is
+// VectorsInterleaved
+func CheckVectorLayout(l string) error {
+	if l != "" && l != VectorsInterleaved && l != VectorsPlanar {
+		return Errorf(ErrBadArguments, "layout must be %q or %q, got %q", VectorsInterleaved, VectorsPlanar, l)
+	}
+	return nil
+}
+
+// FromPlanar interleaves the count vectors of the planar src into dst
+func FromPlanar[T Float](dst, src []T, count int) {
+	x, y, z := src[:count], src[count:2*count], src[2*count:3*count]
+	for i := 0; i < count; i++ {
+		dst[i*3], dst[i*3+1], dst[i*3+2] = x[i], y[i], z[i]
+	}
+}
+
+// ToPlanar writes the count vectors of the interleaved src to dst in the
+// planar layout
+func ToPlanar[T Float](dst, src []T, count int) {
+	x, y, z := dst[:count], dst[count:2*count], dst[2*count:3*count]
+	for i := 0; i < count; i++ {
+		x[i], y[i], z[i] = src[i*3], src[i*3+1], src[i*3+2]
+	}
+}
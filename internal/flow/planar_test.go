@@ -0,0 +1,43 @@
+package flow_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestPlanar(t *testing.T) {
+	run(t, (*checker).checkPlanar)
+}
+
+// checkPlanar round-trips particle vectors through the planar layout and
+// checks that velocities evaluated from it match the interleaved ones.
+func (c *checker) checkPlanar() error {
+	const n = 257
+	p := make([]float64, 3*n)
+	for i := range p {
+		p[i] = 4 * math.Sin(float64(i)*0.37)
+	}
+	planar, back := make([]float64, 3*n), make([]float64, 3*n)
+	flow.ToPlanar(planar, p, n)
+	flow.FromPlanar(back, planar, n)
+	ok := reflect.DeepEqual(back, p) && planar[n+5] == p[5*3+1] && planar[2*n+7] == p[7*3+2]
+
+	f := checkFlow(flow.Sphere)
+	v, w := make([]float64, 3*n), make([]float64, 3*n)
+	if err := flow.VelocitiesInto(v, p, n, f); err != nil {
+		return err
+	}
+	flow.FromPlanar(back, planar, n)
+	if err := flow.VelocitiesInto(w, back, n, f); err != nil {
+		return err
+	}
+	ok = ok && reflect.DeepEqual(v, w)
+	e, rejected := flow.CheckVectorLayout("soa").(*flow.Error)
+	rejected = rejected && e.Code == flow.ErrBadArguments && flow.CheckVectorLayout(flow.VectorsPlanar) == nil && flow.CheckVectorLayout("") == nil
+
+	c.report("planar layout", ok && rejected, "round trip and velocities %v; unknown layouts rejected %v", ok, rejected)
+	return nil
+}
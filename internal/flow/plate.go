@@ -0,0 +1,174 @@
+package flow
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// DefaultEdgeFraction is the leading edge regularization radius of a
+// plate, as a fraction of its chord, when EdgeRadius is 0
+const DefaultEdgeFraction = 0.01
+
+// validatePlate checks the plate parameters of o, and that objects other
+// than plates don't set them
+func (o *ObjectSpec) validatePlate() error {
+	if math.IsNaN(o.Alpha) || math.IsInf(o.Alpha, 0) {
+		return Errorf(ErrBadArguments, "object alpha must be finite, got %g", o.Alpha)
+	}
+	if r := o.EdgeRadius; r < 0 || math.IsNaN(r) || math.IsInf(r, 0) {
+		return Errorf(ErrBadArguments, "edgeRadius must be non-negative and finite, got %g", r)
+	}
+	if o.Type != Plate {
+		if o.Alpha != 0 && o.Type != EllipticCylinder && o.Type != Box || o.EdgeRadius != 0 && o.Type != Box {
+			return Errorf(ErrBadArguments, "alpha only applies to plates, elliptic cylinders and boxes and edgeRadius to plates and boxes, not to a %v", o.Type)
+		}
+		return nil
+	}
+	if o.Strengths.Set != 0 {
+		return Errorf(ErrUnsupported, "a plate has no strength overrides: its circulation follows from the Kutta condition")
+	}
+	if o.CollisionRadius != 0 {
+		return Errorf(ErrBadArguments, "a plate has no collision radius: particles are stopped by crossing it")
+	}
+	return nil
+}
+
+// chord returns the unit chord direction of a plate, from the leading to
+// the trailing edge, and its unit normal, on the suction side at α = 0
+func (o *ObjectSpec) chord() (t, n [2]float64) {
+	s, c := math.Sincos(o.Alpha)
+	return [2]float64{c, -s}, [2]float64{s, c}
+}
+
+// edgeRadius returns the leading edge regularization radius of a plate
+func (o *ObjectSpec) edgeRadius() float64 {
+	if o.EdgeRadius != 0 {
+		return o.EdgeRadius
+	}
+	return DefaultEdgeFraction * 2 * o.Radius
+}
+
+// Crosses reports whether a particle moving in a straight line from p0 to
+// p1 passes through the object's surface. It is the inside test of a
+// plate, which has no thickness: the segment crosses the plate's plane,
+// extending along z, within the chord. Segments starting in the plane
+// don't cross it, so a particle landing exactly on the plate is counted
+// once. Other objects are crossed by segments ending up inside them, and
+// cylinders also by segments crossing their splitter plate.
+func (o *ObjectSpec) Crosses(p0, p1 [3]float64) bool {
+	if o.Type != Plate {
+		return !o.Contains(p0[0], p0[1], p0[2]) && o.Contains(p1[0], p1[1], p1[2]) || o.crossesSplitter(p0, p1)
+	}
+	if o.Radius == 0 {
+		return false
+	}
+	t, n := o.chord()
+	x0, y0, x1, y1 := p0[0]-o.X, p0[1]-o.Y, p1[0]-o.X, p1[1]-o.Y
+	d0, d1 := x0*n[0]+y0*n[1], x1*n[0]+y1*n[1]
+	if d0 == 0 || d1 != 0 && (d0 > 0) == (d1 > 0) {
+		return false
+	}
+	// Where the segment meets the plane, along the chord
+	s0, s1 := x0*t[0]+y0*t[1], x1*t[0]+y1*t[1]
+	s := s0 + (s1-s0)*d0/(d0-d1)
+	return math.Abs(s) <= o.Radius
+}
+
+// plateFrame prepares the plate constants of k: the incidence of the
+// plate on the cross-flow, whose direction turns it from Alpha, and the
+// squared edge radius
+func (k *kernel) plateFrame() {
+	c, s := 1.0, 0.0
+	if k.mode == frameCrossFlow {
+		c, s = k.c, k.s
+	}
+	sa, ca := math.Sincos(k.obj.Alpha)
+	k.pc, k.ps = ca*c-sa*s, sa*c+ca*s
+	e := k.obj.edgeRadius()
+	k.edge2 = e * e
+}
+
+// plate is local for a plate: the exact solution of a flat plate of chord
+// 2b at incidence α with the Kutta condition at its trailing edge. In the
+// plate frame, X along the chord and the plate on [-b, b], the complex
+// velocity is
+//
+//	u - iv = U·[cos α - i·sin α·√((Z - b)/(Z + b))]
+//
+// the branch cut lying on the plate, so the two sides see the two values
+// of the root. The √(1/|Z + b|) singularity of the leading edge, whose
+// suction force the plate's lift depends on, is regularized over the edge
+// radius ε as (|Z + b|² + ε²)^-¼.
+func (k *kernel) plate(x, y float64) (vx, vy, vz float64) {
+	b := k.obj.Radius
+	px, py := x*k.pc-y*k.ps, x*k.ps+y*k.pc
+	z := complex(px, py)
+	r2 := (px+b)*(px+b) + py*py
+	var q complex128
+	if r2 != 0 {
+		root := cmplx.Sqrt(z + complex(b, 0))
+		q = cmplx.Sqrt(z-complex(b, 0)) * cmplx.Conj(root) / complex(math.Sqrt(math.Sqrt(r2))*math.Sqrt(math.Sqrt(r2+k.edge2)), 0)
+	}
+	w := complex(k.u, 0) * (complex(k.pc, 0) - complex(0, k.ps)*q)
+	u, v := real(w), -imag(w)
+	// Back from the plate frame, whose chord is (cos α, -sin α)
+	return u*k.pc + v*k.ps, -u*k.ps + v*k.pc, 0
+}
+
+// PlateForces are the forces per unit span on a plate in the cross-flow
+// of its free stream, from the exact solution: the lift follows from the
+// Kutta-Joukowski theorem and splits into the pressure force normal to
+// the plate and the suction force its leading edge singularity pulls
+// forward along the chord. The edge regularization of the velocity field
+// doesn't enter them.
+type PlateForces struct {
+	Alpha       float64 // Incidence on the cross-flow, radians
+	CL          float64 // Lift coefficient 2π·sin α
+	CLThin      float64 // Thin-airfoil lift coefficient 2πα
+	CN          float64 // Normal force coefficient 2π·sin α·cos α
+	CS          float64 // Leading edge suction coefficient 2π·sin²α
+	Circulation float64 // Γ = π·U·c·sin α, clockwise seen from +z
+	Lift        float64 // ρ·U·Γ
+	Normal      float64 // ρ·U·Γ·cos α
+	Suction     float64 // ρ·U·Γ·sin α
+}
+
+// PlateForces returns the forces on the plate of f. The coefficients are
+// relative to ½ρU²c with the onset speed of the cross-flow; the thin
+// airfoil lift departs from the exact one as α grows, and in a real flow
+// the suction, which would need an infinitely sharp and attached leading
+// edge, is lost to separation.
+func (f *Flow) PlateForces() (PlateForces, error) {
+	var p PlateForces
+	if err := f.Validate(); err != nil {
+		return p, err
+	}
+	if f.Object.Type != Plate {
+		return p, Errorf(ErrBadArguments, "plate forces need a plate, not a %v", f.Object.Type)
+	}
+	k := f.kernel()
+	p.Alpha = math.Atan2(k.ps, k.pc)
+	sin, cos := k.ps, k.pc
+	c := 2 * f.Object.Radius
+	p.CL, p.CLThin = 2*math.Pi*sin, 2*math.Pi*p.Alpha
+	p.CN, p.CS = p.CL*cos, p.CL*sin
+	p.Circulation = math.Pi * k.u * c * sin
+	p.Lift = k.rho * k.u * p.Circulation
+	p.Normal, p.Suction = p.Lift*cos, p.Lift*sin
+	return p, nil
+}
+
+// Encode returns p in generic form, with the incidence in degrees
+func (p PlateForces) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"alpha":       p.Alpha * 180 / math.Pi,
+		"cl":          p.CL,
+		"clThin":      p.CLThin,
+		"cn":          p.CN,
+		"cs":          p.CS,
+		"circulation": p.Circulation,
+		"lift":        p.Lift,
+		"normalForce": p.Normal,
+		"suction":     p.Suction,
+	}
+}
@@ -0,0 +1,81 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestPlate(t *testing.T) {
+	run(t, (*checker).checkPlate)
+}
+
+// checkPlate verifies the flat plate: no flow through either face away
+// from the regularized leading edge, the Kutta condition's finite velocity
+// at the trailing edge, a circulation around it of π·U·c·sin α as
+// PlateForces reports, the same field for a free stream and plate turned
+// together, and the swept-segment crossing test
+func (c *checker) checkPlate() error {
+	f := checkFlow(flow.Plate)
+	f.Object.Alpha = 10 * math.Pi / 180
+	f.Object.EdgeRadius = 1e-12
+	forces, err := f.PlateForces()
+	if err != nil {
+		return err
+	}
+
+	tangency := 0.0
+	sin, cos := math.Sincos(f.Object.Alpha)
+	for i := 0; i < 200; i++ {
+		s := -0.99 + 1.98*(float64(i)+0.5)/200
+		for _, side := range []float64{1e-12, -1e-12} {
+			x, y := s*cos+side*sin, -s*sin+side*cos
+			vx, vy, _ := f.VelocityAt(x, y, 0)
+			tangency = math.Max(tangency, math.Abs(vx*sin+vy*cos))
+		}
+	}
+	// Both faces meet the trailing edge with the speed U·cos α
+	kutta := 0.0
+	for _, side := range []float64{1e-9, -1e-9} {
+		vx, vy, _ := f.VelocityAt(cos+side*sin, -sin+side*cos, 0)
+		kutta = math.Max(kutta, math.Abs(math.Hypot(vx, vy)-cos))
+	}
+
+	// Counterclockwise around a circle of 2 half chords
+	const n = 4096
+	gamma := 0.0
+	for i := 0; i < n; i++ {
+		st, ct := math.Sincos(2 * math.Pi * (float64(i) + 0.5) / n)
+		vx, vy, _ := f.VelocityAt(2*ct, 2*st, 0)
+		gamma += (-vx*st + vy*ct) * 2 * 2 * math.Pi / n
+	}
+	circulation := math.Abs(gamma+forces.Circulation) / forces.Circulation
+
+	// Turning the free stream by φ and the plate by -φ keeps the incidence
+	// and turns the field
+	g := f
+	phi := 0.7
+	sp, cp := math.Sincos(phi)
+	g.Direction = [3]float64{cp, sp, 0}
+	g.Object.Alpha -= phi
+	turned := 0.0
+	for j := 0; j < 100; j++ {
+		x, y := float64(j%10)/2-2.2, float64(j/10)/2-2.3
+		ax, ay, _ := f.VelocityAt(x, y, 0)
+		bx, by, _ := g.VelocityAt(cp*x-sp*y, sp*x+cp*y, 0)
+		turned = math.Max(turned, math.Hypot(bx-(cp*ax-sp*ay), by-(sp*ax+cp*ay)))
+	}
+
+	o := f.Object
+	crossing := o.Crosses([3]float64{0.5, 1, 3}, [3]float64{0.5, -1, 3}) &&
+		!o.Crosses([3]float64{1.5, 1, 0}, [3]float64{1.5, -1, 0}) &&
+		!o.Crosses([3]float64{-0.5, 1, 0}, [3]float64{0.5, 0.5, 0}) &&
+		!o.Contains(0, 0, 0)
+
+	cl := math.Abs(forces.CL-2*math.Pi*sin) + math.Abs(forces.CLThin-2*math.Pi*f.Object.Alpha) + math.Abs(forces.CS-2*math.Pi*sin*sin)
+	ok := tangency < plateTolerance && kutta < kuttaTolerance && circulation < plateTolerance && turned < plateTolerance && crossing && cl < plateTolerance
+	c.report("plate", ok, "max |v·n| %.3g; trailing edge |v| - U cos α %.3g; Γ relative %.3g; turned %.3g; crossings %v; cl %.6f, thin %.6f, cs %.6f",
+		tangency, kutta, circulation, turned, crossing, forces.CL, forces.CLThin, forces.CS)
+	return nil
+}
@@ -0,0 +1,49 @@
+package flow
+
+// Storage precisions of the particle positions of a Simulation (see
+// Config.Precision). Far from the origin a float32 position can't take
+// small steps: at 10⁴ its spacing is about 10⁻³, and a particle moving
+// less than half of that per step stays where it is, so long runs band
+// and stall there. Double precision keeps them moving at twice the memory
+// of the position store.
+const (
+	PrecisionSingle = "single"
+	PrecisionDouble = "double"
+)
+
+// advectPrecise is Advect on the float64 copy of the positions, rounded
+// into Positions after. The copy is taken from Positions when they were
+// replaced since the last step.
+func (s *Simulation) advectPrecise(dt float64, count int) {
+	if len(s.precise) != count*3 {
+		s.precise = s.precisePositions()
+	}
+	Advect(s.precise, s.Velocities, count, dt)
+	for i, x := range s.precise {
+		s.Positions[i] = float32(x)
+	}
+}
+
+// syncPrecise takes the particles that the boundaries, walls or splitter
+// of a step moved after advecting them, which work on Positions, back into
+// the float64 copy
+func (s *Simulation) syncPrecise(count int) {
+	for i, x := range s.Positions[:count*3] {
+		if x != float32(s.precise[i]) {
+			s.precise[i] = float64(x)
+		}
+	}
+}
+
+// precisePositions returns the positions in double precision: the float64
+// copy if it is current, else Positions converted
+func (s *Simulation) precisePositions() []float64 {
+	if len(s.precise) == len(s.Positions) && len(s.precise) > 0 {
+		return s.precise
+	}
+	p := make([]float64, len(s.Positions))
+	for i, x := range s.Positions {
+		p[i] = float64(x)
+	}
+	return p
+}
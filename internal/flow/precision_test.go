@@ -0,0 +1,72 @@
+package flow_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestPrecision(t *testing.T) {
+	run(t, (*checker).checkPrecision)
+}
+
+// checkPrecision steps a particle 10⁴ radii downstream of a sphere 10⁵
+// times with single and double precision positions against float64 Euler
+// steps of the same velocities: the steps of 10⁻⁴ radii are below half the
+// float32 spacing there, which leaves a single precision particle where it
+// started, while a double precision one follows the reference. A snapshot
+// must carry the double precision positions over.
+func (c *checker) checkPrecision() error {
+	const steps, dt = 100000, 1e-4
+	start := []float32{1e4, 0.5, -0.25}
+	errs := map[string]float64{}
+	var moved float64
+	var restored bool
+	for _, precision := range []string{flow.PrecisionSingle, flow.PrecisionDouble} {
+		config := flow.DefaultConfig()
+		config.Precision = precision
+		s := flow.NewSimulation(config)
+		if err := s.SetParticles(start, 1); err != nil {
+			return err
+		}
+		want := [3]float64{float64(start[0]), float64(start[1]), float64(start[2])}
+		f := s.Flow()
+		for i := 0; i < steps; i++ {
+			if err := s.Step(dt); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < steps; i++ {
+			vx, vy, vz := f.VelocityAt(want[0], want[1], want[2])
+			want[0], want[1], want[2] = want[0]+vx*dt, want[1]+vy*dt, want[2]+vz*dt
+		}
+		moved = want[0] - float64(start[0])
+		for a := range want {
+			errs[precision] = math.Max(errs[precision], math.Abs(float64(s.Positions[a])-want[a]))
+		}
+		if precision == flow.PrecisionDouble {
+			b, err := s.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			t := flow.NewSimulation(flow.DefaultConfig())
+			if err := t.UnmarshalBinary(b); err != nil {
+				return err
+			}
+			for i := 0; i < 1000; i++ {
+				if err := s.Step(dt); err != nil {
+					return err
+				}
+				if err := t.Step(dt); err != nil {
+					return err
+				}
+			}
+			restored = t.Config.Precision == flow.PrecisionDouble && reflect.DeepEqual(s.Positions, t.Positions)
+		}
+	}
+	ok := errs[flow.PrecisionSingle] > moved/2 && errs[flow.PrecisionDouble] < 1e-5*moved && restored
+	c.report("position precision", ok, "after %d steps moving %.4g: single precision off by %.3g, double by %.3g; snapshot keeps double precision %v", steps, moved, errs[flow.PrecisionSingle], errs[flow.PrecisionDouble], restored)
+	return nil
+}
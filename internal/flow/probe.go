@@ -0,0 +1,264 @@
+package flow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// MaxProbeRings bounds the rings of a FlowProbe
+const MaxProbeRings = 256
+
+// MaxProbeSamples is the length of the history of a FlowProbe, past which
+// each new sample drops the oldest
+const MaxProbeSamples = 1 << 16
+
+// ProbeSample is the volume flow rate through a probe at one step
+type ProbeSample struct {
+	Time float64
+	Rate float64 // m³/s
+}
+
+// FlowProbe is a virtual flow meter: a disk of Radius about Center,
+// normal to Normal, through which Flow.ProbeRate integrates the volume
+// flow rate ∫v·n dA. The disk is divided into Rings rings of equal width,
+// ring k into 4(2k+1) sectors, so all cells have the same area, each
+// evaluated at its center. Cells in the object, where the velocity is
+// zero, add nothing. A Simulation samples its probes every step into
+// their histories.
+type FlowProbe struct {
+	ID     int
+	Center [3]float64
+	Normal [3]float64 // Unit
+	Radius float64
+	Rings  int
+
+	samples []ProbeSample // A ring once full, oldest at next
+	next    int
+}
+
+// Validate checks the parameters of p, normalizing Normal
+func (p *FlowProbe) Validate() error {
+	if p.ID < 0 {
+		return Errorf(ErrBadArguments, "probe id must be non-negative, got %d", p.ID)
+	}
+	for _, x := range p.Center {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "probe %d: center must be finite, got %v", p.ID, p.Center)
+		}
+	}
+	n, l := normalized(p.Normal)
+	if !(l > 0) || math.IsInf(l, 0) {
+		return Errorf(ErrBadArguments, "probe %d: normal must be a non-zero finite vector, got %v", p.ID, p.Normal)
+	}
+	p.Normal = n
+	if !(p.Radius > 0) || math.IsInf(p.Radius, 0) {
+		return Errorf(ErrBadArguments, "probe %d: radius must be a positive finite length, got %g", p.ID, p.Radius)
+	}
+	if p.Rings < 1 || p.Rings > MaxProbeRings {
+		return Errorf(ErrBadArguments, "probe %d: rings must be between 1 and %d, got %d", p.ID, MaxProbeRings, p.Rings)
+	}
+	return nil
+}
+
+// ProbeRate returns the volume flow rate of f through the disk of p
+func (f *Flow) ProbeRate(p *FlowProbe) float64 {
+	k := f.kernel()
+	return k.probeRate(p)
+}
+
+// probeRate is ProbeRate with a prepared kernel
+func (k *kernel) probeRate(p *FlowProbe) float64 {
+	n, u, v := normalAxes(p.Normal)
+	h := p.Radius / float64(p.Rings)
+	cell := math.Pi * h * h / 4
+	rate := 0.0
+	for ring := 0; ring < p.Rings; ring++ {
+		r, sectors := (float64(ring)+0.5)*h, 4*(2*ring+1)
+		for j := 0; j < sectors; j++ {
+			s, c := math.Sincos(2 * math.Pi * (float64(j) + 0.5) / float64(sectors))
+			a, b := r*c, r*s
+			vx, vy, vz := k.velocityAt(p.Center[0]+a*u[0]+b*v[0], p.Center[1]+a*u[1]+b*v[1], p.Center[2]+a*u[2]+b*v[2])
+			rate += (vx*n[0] + vy*n[1] + vz*n[2]) * cell
+		}
+	}
+	return rate
+}
+
+// Len returns the number of samples held
+func (p *FlowProbe) Len() int {
+	return len(p.samples)
+}
+
+// History returns the samples held in time order as parallel arrays of
+// the time and the rate
+func (p *FlowProbe) History() (t, rate []float64) {
+	n := len(p.samples)
+	t, rate = make([]float64, n), make([]float64, n)
+	for i := range p.samples {
+		s := &p.samples[(p.next+i)%n]
+		t[i], rate[i] = s.Time, s.Rate
+	}
+	return t, rate
+}
+
+// Encode returns the definition of p in the generic form accepted by
+// DecodeProbes
+func (p *FlowProbe) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"id":     float64(p.ID),
+		"center": []interface{}{p.Center[0], p.Center[1], p.Center[2]},
+		"normal": []interface{}{p.Normal[0], p.Normal[1], p.Normal[2]},
+		"radius": p.Radius,
+		"rings":  float64(p.Rings),
+	}
+}
+
+// Probes are the flow probes of a Simulation, part of its scenarios
+// without their histories. Like a ForceHistory they are sampled with the
+// velocities of each step, before the particles and the object move, and
+// a step of dt 0, leaving the clock, adds no sample.
+type Probes struct {
+	List []*FlowProbe // By ID
+
+	last    float64 // Time of the last sample
+	sampled bool
+}
+
+// Probe returns the probe with id, or nil
+func (ps *Probes) Probe(id int) *FlowProbe {
+	i := sort.Search(len(ps.List), func(i int) bool { return ps.List[i].ID >= id })
+	if i < len(ps.List) && ps.List[i].ID == id {
+		return ps.List[i]
+	}
+	return nil
+}
+
+// Define adds p, or replaces the probe with its ID, carrying over its
+// history so that a probe moved around keeps one time line
+func (ps *Probes) Define(p FlowProbe) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if old := ps.Probe(p.ID); old != nil {
+		p.samples, p.next = old.samples, old.next
+		*old = p
+		return nil
+	}
+	ps.List = append(ps.List, &p)
+	sort.Slice(ps.List, func(a, b int) bool { return ps.List[a].ID < ps.List[b].ID })
+	return nil
+}
+
+// Remove deletes the probe with id, reporting whether there was one
+func (ps *Probes) Remove(id int) bool {
+	for i, p := range ps.List {
+		if p.ID == id {
+			ps.List = append(ps.List[:i], ps.List[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear empties the histories of the probes, keeping them
+func (ps *Probes) Clear() {
+	for _, p := range ps.List {
+		p.samples, p.next = nil, 0
+	}
+	ps.sampled = false
+}
+
+// Definitions returns copies of the probes without their histories
+func (ps *Probes) Definitions() []FlowProbe {
+	d := make([]FlowProbe, len(ps.List))
+	for i, p := range ps.List {
+		d[i] = FlowProbe{ID: p.ID, Center: p.Center, Normal: p.Normal, Radius: p.Radius, Rings: p.Rings}
+	}
+	return d
+}
+
+// Capture samples every probe of s if the clock moved since the last
+// sample
+func (ps *Probes) Capture(s *Simulation) {
+	if len(ps.List) == 0 || ps.sampled && s.Time == ps.last {
+		return
+	}
+	ps.last, ps.sampled = s.Time, true
+	f := s.Flow()
+	k := f.kernel()
+	for _, p := range ps.List {
+		sample := ProbeSample{Time: s.Time, Rate: k.probeRate(p)}
+		if len(p.samples) < MaxProbeSamples {
+			p.samples = append(p.samples, sample)
+			continue
+		}
+		p.samples[p.next] = sample
+		p.next = (p.next + 1) % MaxProbeSamples
+	}
+}
+
+// DecodeProbes decodes an array of probe definitions,
+//
+//	[{id, center: [x, y, z], normal: [x, y, z], radius, rings}]
+//
+// as found in scenarios, dropping unknown keys with a warning unless
+// warnings is nil
+func DecodeProbes(v interface{}, warnings *[]string) ([]FlowProbe, error) {
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, Errorf(ErrBadArguments, "probes must be an array")
+	}
+	probes := make([]FlowProbe, len(a))
+	for i, e := range a {
+		path := fmt.Sprintf("probes[%d]", i)
+		m, err := known(e, path, warnings, "id", "center", "normal", "radius", "rings")
+		if err != nil {
+			return nil, err
+		}
+		p := &probes[i]
+		var id, rings float64
+		for _, k := range []struct {
+			key string
+			dst *float64
+		}{{"id", &id}, {"radius", &p.Radius}, {"rings", &rings}} {
+			if _, ok := m[k.key]; !ok {
+				return nil, Errorf(ErrBadArguments, "%s.%s is required", path, k.key)
+			}
+			if err := numberKey(m, path, k.key, k.dst); err != nil {
+				return nil, err
+			}
+		}
+		if id != math.Trunc(id) || rings != math.Trunc(rings) {
+			return nil, Errorf(ErrBadArguments, "%s: id and rings must be integers", path)
+		}
+		p.ID, p.Rings = int(id), int(rings)
+		for _, k := range []struct {
+			key string
+			dst *[3]float64
+		}{{"center", &p.Center}, {"normal", &p.Normal}} {
+			v, ok := m[k.key]
+			if !ok {
+				return nil, Errorf(ErrBadArguments, "%s.%s is required", path, k.key)
+			}
+			if *k.dst, err = vector(v, path+"."+k.key); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return probes, nil
+}
+
+// probesState returns State().probes
+func (s *Simulation) probesState() []interface{} {
+	probes := make([]interface{}, len(s.Probes.List))
+	for i, p := range s.Probes.List {
+		st := p.Encode()
+		st["id"], st["rings"], st["samples"] = p.ID, p.Rings, p.Len()
+		probes[i] = st
+	}
+	return probes
+}
@@ -0,0 +1,69 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestFlowProbe(t *testing.T) {
+	run(t, (*checker).checkFlowProbe)
+}
+
+// checkFlowProbe meters a uniform stream exactly and more flow beside a
+// cylinder than upstream of it, and follows probes through steps, a move,
+// removal and a scenario
+func (c *checker) checkFlowProbe() error {
+	uniform := flow.Flow{FreeStream: 2, Density: 1}
+	p := flow.FlowProbe{Center: [3]float64{1, 2, 3}, Normal: [3]float64{1, 0, 0}, Radius: 0.5, Rings: 6}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	exact := math.Abs(uniform.ProbeRate(&p)/(2*math.Pi*0.25) - 1)
+
+	f := flow.Flow{FreeStream: 1, Density: 1, Object: flow.ObjectSpec{Type: flow.Cylinder, Radius: 1}}
+	beside, upstream := p, p
+	beside.Center, upstream.Center = [3]float64{0, 1.6, 0}, [3]float64{-20, 1.6, 0}
+	faster := f.ProbeRate(&beside) / f.ProbeRate(&upstream)
+
+	config := flow.DefaultConfig()
+	config.Object = f.Object
+	s := flow.NewSimulation(config)
+	if err := s.Probes.Define(upstream); err != nil {
+		return err
+	}
+	if err := s.Probes.Define(flow.FlowProbe{ID: 4, Normal: [3]float64{0, 0, 3}, Center: [3]float64{2, 0, 0}, Radius: 1, Rings: 2}); err != nil {
+		return err
+	}
+	for i := 0; i < 6; i++ {
+		dt := 0.1
+		if i == 3 {
+			if err := s.Probes.Define(beside); err != nil {
+				return err
+			}
+			dt = 0
+		}
+		if err := s.Step(dt); err != nil {
+			return err
+		}
+	}
+	t, rate := s.Probes.Probe(0).History()
+	history := len(t) == 5 && math.Abs(t[4]-0.4) < 1e-12 && rate[3] > rate[2]*1.1
+	sc := s.Scenario(false)
+	decoded, err := flow.DecodeProbes(sc.Encode()["probes"], nil)
+	if err != nil {
+		return err
+	}
+	rebuilt, err := sc.Simulation()
+	if err != nil {
+		return err
+	}
+	saved := len(decoded) == 2 && decoded[1].ID == 4 && decoded[1].Normal == [3]float64{0, 0, 1} && decoded[0].Center == beside.Center &&
+		len(rebuilt.Probes.List) == 2 && rebuilt.Probes.Probe(0).Len() == 0
+	removed := s.Probes.Remove(4) && !s.Probes.Remove(4) && len(s.Probes.List) == 1
+
+	c.report("flow probe", exact < 1e-12 && faster > 1.2 && history && saved && removed, "uniform stream off πr²U by %.3g; beside the cylinder %.3f times the rate upstream; history of 5 steps following the move %v; saved %v; removed %v",
+		exact, faster, history, saved, removed)
+	return nil
+}
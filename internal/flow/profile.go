@@ -0,0 +1,256 @@
+package flow
+
+import "math"
+
+// Free stream profiles
+const (
+	ProfileUniform = "uniform"
+	ProfileShear   = "shear"
+	ProfilePower   = "power"
+	ProfileLog     = "log"
+	ProfileLayered = "layered"
+)
+
+// MaxBands is the largest number of bands of a layered profile
+const MaxBands = 8
+
+// Profile is the variation of the free stream speed U with height y, the
+// background the object's disturbance is superimposed on (see
+// Flow.Profile). The free stream speed is the speed at the reference
+// height Ref:
+//
+//   - uniform, or the empty type: U everywhere
+//   - shear: U + Rate·(y - Ref)
+//   - power: U·(h/Ref)^Alpha, the power law of an atmospheric boundary layer
+//   - log: U·ln(h/Roughness)/ln(Ref/Roughness), the log law
+//   - layered: horizontal bands moving at Speeds[i]·U, from the bottom
+//     up, meeting at Heights, blended over Thickness:
+//     U·(s₀ + Σ (sᵢ₊₁ - sᵢ)·(1 + tanh((y - hᵢ)/δ))/2)
+//
+// where h = y - Floor is the height above the ground. The boundary layer
+// laws are 0 at and below the floor, and the log law within the roughness
+// length of it too. The layered bands are multiples of U so that the free
+// stream speed and its schedule scale them all; a thickness of 0 is a
+// sharp step, a vortex sheet, and an infinite one blends the bands into
+// the uniform stream of (s₀ + sₙ)/2·U, as do equal speeds into that of
+// their value.
+type Profile struct {
+	Type      string
+	Rate      float64 // shear
+	Ref       float64 // y0 of the shear, the reference height above the floor otherwise
+	Alpha     float64 // power
+	Roughness float64 // log, z0
+	Floor     float64 // power and log
+
+	Bands     int                   // layered, at most MaxBands
+	Speeds    [MaxBands]float64     // Over the free stream speed, bottom up
+	Heights   [MaxBands - 1]float64 // Of the interfaces between the bands, increasing
+	Thickness float64               // δ of the blending, 0 for a step
+}
+
+// Validate checks the parameters of p
+func (p *Profile) Validate() error {
+	for _, x := range []float64{p.Rate, p.Ref, p.Alpha, p.Roughness, p.Floor} {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "freeStream.profile parameters must be finite, got %+v", *p)
+		}
+	}
+	switch p.Type {
+	case "", ProfileUniform, ProfileShear:
+	case ProfileLayered:
+		return p.validateBands()
+	case ProfilePower:
+		if !(p.Ref > 0) || !(p.Alpha > 0) {
+			return Errorf(ErrBadArguments, "power law profile: zref and alpha must be positive, got %g and %g", p.Ref, p.Alpha)
+		}
+	case ProfileLog:
+		if !(p.Roughness > 0) || !(p.Ref > p.Roughness) {
+			return Errorf(ErrBadArguments, "log law profile: z0 must be positive and zref above it, got %g and %g", p.Roughness, p.Ref)
+		}
+	default:
+		return Errorf(ErrBadArguments, "freeStream.profile.type must be %q, %q, %q, %q or %q, got %q", ProfileUniform, ProfileShear, ProfilePower, ProfileLog, ProfileLayered, p.Type)
+	}
+	return nil
+}
+
+// validateBands checks the bands of a layered profile
+func (p *Profile) validateBands() error {
+	if p.Bands < 1 || p.Bands > MaxBands {
+		return Errorf(ErrBadArguments, "layered profile: there must be 1 to %d bands, got %d", MaxBands, p.Bands)
+	}
+	for i, u := range p.Speeds[:p.Bands] {
+		if math.IsNaN(u) || math.IsInf(u, 0) {
+			return Errorf(ErrBadArguments, "layered profile: speeds must be finite, got %g for band %d", u, i)
+		}
+	}
+	for i, h := range p.Heights[:p.Bands-1] {
+		if math.IsNaN(h) || math.IsInf(h, 0) {
+			return Errorf(ErrBadArguments, "layered profile: heights must be finite, got %g", h)
+		}
+		if i > 0 && !(h > p.Heights[i-1]) {
+			return Errorf(ErrBadArguments, "layered profile: heights must increase, got %g after %g", h, p.Heights[i-1])
+		}
+	}
+	if !(p.Thickness >= 0) {
+		return Errorf(ErrBadArguments, "layered profile: thickness must be non-negative, got %g", p.Thickness)
+	}
+	return nil
+}
+
+// Uniform reports whether p is a uniform stream, a shear of rate 0
+// included
+func (p *Profile) Uniform() bool {
+	switch p.Type {
+	case "", ProfileUniform:
+		return true
+	case ProfileShear:
+		return p.Rate == 0
+	case ProfileLayered:
+		for _, u := range p.Speeds[:p.Bands] {
+			if u != 1 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Speed returns the speed at height y of the free stream of speed u
+func (p *Profile) Speed(u, y float64) float64 {
+	switch p.Type {
+	case ProfileShear:
+		return u + p.Rate*(y-p.Ref)
+	case ProfilePower:
+		if h := y - p.Floor; h > 0 {
+			return u * math.Pow(h/p.Ref, p.Alpha)
+		}
+		return 0
+	case ProfileLog:
+		if h := y - p.Floor; h > p.Roughness {
+			return u * math.Log(h/p.Roughness) / math.Log(p.Ref/p.Roughness)
+		}
+		return 0
+	case ProfileLayered:
+		s := p.Speeds[0]
+		for i, h := range p.Heights[:p.Bands-1] {
+			step := 0.5
+			switch x := (y - h) / p.Thickness; {
+			case x > 0 && p.Thickness == 0:
+				step = 1
+			case x < 0 && p.Thickness == 0:
+				step = 0
+			case p.Thickness > 0:
+				step = (1 + math.Tanh(x)) / 2
+			}
+			s += (p.Speeds[i+1] - p.Speeds[i]) * step
+		}
+		return u * s
+	}
+	return u
+}
+
+// Encode returns p in generic form, with the keys of its type
+func (p *Profile) Encode() map[string]interface{} {
+	switch p.Type {
+	case ProfileShear:
+		return map[string]interface{}{"type": p.Type, "rate": p.Rate, "y0": p.Ref}
+	case ProfilePower:
+		return map[string]interface{}{"type": p.Type, "zref": p.Ref, "alpha": p.Alpha, "floor": p.Floor}
+	case ProfileLog:
+		return map[string]interface{}{"type": p.Type, "zref": p.Ref, "z0": p.Roughness, "floor": p.Floor}
+	case ProfileLayered:
+		speeds, heights := make([]interface{}, p.Bands), make([]interface{}, p.Bands-1)
+		for i := range speeds {
+			speeds[i] = p.Speeds[i]
+		}
+		for i := range heights {
+			heights[i] = p.Heights[i]
+		}
+		return map[string]interface{}{"type": p.Type, "speeds": speeds, "heights": heights, "thickness": p.Thickness}
+	}
+	return map[string]interface{}{"type": ProfileUniform}
+}
+
+// DecodeProfile decodes a profile from generic values (see DecodeConfig):
+// {type, ...}, with the keys Encode writes for the type: for a layered
+// profile the arrays speeds and heights, one shorter, and thickness. The
+// shear's y0, the floor and the thickness are 0 unless given; every other
+// parameter is required.
+func DecodeProfile(v interface{}) (Profile, error) {
+	var p Profile
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return p, Errorf(ErrBadArguments, "freeStream.profile must be an object")
+	}
+	if p.Type, _ = m["type"].(string); p.Type == "" {
+		return p, Errorf(ErrBadArguments, "freeStream.profile.type must be a string")
+	}
+	if p.Type == ProfileLayered {
+		return decodeBands(m)
+	}
+	type param struct {
+		key string
+		dst *float64
+	}
+	params, ok := map[string][]param{
+		ProfileUniform: {},
+		ProfileShear:   {{"rate", &p.Rate}, {"y0", &p.Ref}},
+		ProfilePower:   {{"zref", &p.Ref}, {"alpha", &p.Alpha}, {"floor", &p.Floor}},
+		ProfileLog:     {{"zref", &p.Ref}, {"z0", &p.Roughness}, {"floor", &p.Floor}},
+	}[p.Type]
+	if !ok {
+		return p, p.Validate()
+	}
+	names := []string{"type"}
+	for _, q := range params {
+		names = append(names, q.key)
+	}
+	if _, err := section(m, "freeStream.profile", names...); err != nil {
+		return p, err
+	}
+	for _, q := range params {
+		if _, ok := m[q.key]; !ok && q.key != "floor" && q.key != "y0" {
+			return p, Errorf(ErrBadArguments, "freeStream.profile.%s is required for a %s profile", q.key, p.Type)
+		}
+		if err := numberKey(m, "freeStream.profile", q.key, q.dst); err != nil {
+			return p, err
+		}
+	}
+	return p, p.Validate()
+}
+
+// decodeBands decodes a layered profile, {type, speeds, heights,
+// thickness}
+func decodeBands(m map[string]interface{}) (Profile, error) {
+	p := Profile{Type: ProfileLayered}
+	if _, err := section(m, "freeStream.profile", "type", "speeds", "heights", "thickness"); err != nil {
+		return p, err
+	}
+	speeds, _ := m["speeds"].([]interface{})
+	if len(speeds) < 1 || len(speeds) > MaxBands {
+		return p, Errorf(ErrBadArguments, "freeStream.profile.speeds must be an array of 1 to %d numbers", MaxBands)
+	}
+	p.Bands = len(speeds)
+	heights, _ := m["heights"].([]interface{})
+	if len(heights) != p.Bands-1 {
+		return p, Errorf(ErrBadArguments, "freeStream.profile.heights must be an array of %d numbers, one per interface between the bands", p.Bands-1)
+	}
+	for _, a := range []struct {
+		key string
+		src []interface{}
+		dst []float64
+	}{{"speeds", speeds, p.Speeds[:]}, {"heights", heights, p.Heights[:]}} {
+		for i, v := range a.src {
+			x, ok := v.(float64)
+			if !ok {
+				return p, Errorf(ErrBadArguments, "freeStream.profile.%s[%d] must be a number", a.key, i)
+			}
+			a.dst[i] = x
+		}
+	}
+	if err := numberKey(m, "freeStream.profile", "thickness", &p.Thickness); err != nil {
+		return p, err
+	}
+	return p, p.Validate()
+}
@@ -0,0 +1,134 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestProfile(t *testing.T) {
+	forTypes(t, func(c *checker, ty flow.ObjectType) error {
+		c.checkProfile(ty)
+		return nil
+	}, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+func TestLayeredProfile(t *testing.T) {
+	run(t, (*checker).checkLayeredProfile)
+}
+
+// checkProfile verifies that a zero shear rate gives the uniform flow bit
+// for bit and that far from the object the velocity is the background of
+// each profile
+func (c *checker) checkProfile(t flow.ObjectType) {
+	uniform := checkFlow(t)
+	zero := uniform
+	zero.Profile = flow.Profile{Type: flow.ProfileShear, Ref: 5}
+	same := true
+	for i := 0; i < 1000; i++ {
+		x, y, z := float64(i%10)-4.5, float64(i/10%10)-4.5, float64(i/100)-4.5
+		ux, uy, uz := uniform.VelocityAt(x, y, z)
+		zx, zy, zz := zero.VelocityAt(x, y, z)
+		same = same && ux == zx && uy == zy && uz == zz
+	}
+	for _, p := range []flow.Profile{
+		{Type: flow.ProfileShear, Rate: 0.2, Ref: -1},
+		{Type: flow.ProfilePower, Ref: 10, Alpha: 0.16, Floor: -2},
+		{Type: flow.ProfileLog, Ref: 10, Roughness: 0.05, Floor: -2},
+		{Type: flow.ProfileLayered, Bands: 3, Speeds: [flow.MaxBands]float64{0.5, 1, 1.75}, Heights: [flow.MaxBands - 1]float64{-1, 2}, Thickness: 0.4},
+	} {
+		f := uniform
+		f.Profile = p
+		worst := 0.0
+		for i := 0; i < 1000; i++ {
+			x, y, z := float64(i%10)-4.5, float64(i/10%10)-4.5, float64(i/100)-4.5
+			fx, fy, fz := f.VelocityAt(1e8*x, 1e8*y, z)
+			u := p.Speed(f.FreeStream, 1e8*y)
+			worst = math.Max(worst, math.Hypot(math.Hypot(fx-u, fy), fz)/math.Max(math.Abs(u), f.FreeStream))
+		}
+		c.report(t.String()+" "+p.Type+" profile", same && worst < profileTolerance, "rate 0 identical to uniform: %v; far field max |v - U(y)|/U(y) = %.3g", same, worst)
+	}
+}
+
+// checkLayeredProfile verifies that bands all at the free stream speed,
+// equal bands and an infinite blending thickness each give the uniform
+// stream of their speed bit for bit; that far from a sphere two bands
+// have their speeds, the sharp step its mean at the interface and the
+// blend its tanh, with the vorticity -dU/dy of the shear layer; and that
+// the table survives configurations and snapshots and is validated
+func (c *checker) checkLayeredProfile() error {
+	layered := func(thickness float64, y0 float64, speeds ...float64) flow.Profile {
+		p := flow.Profile{Type: flow.ProfileLayered, Bands: len(speeds), Thickness: thickness}
+		copy(p.Speeds[:], speeds)
+		for i := 1; i < len(speeds); i++ {
+			p.Heights[i-1] = y0 + float64(i-1)
+		}
+		return p
+	}
+	same := true
+	for _, k := range []struct {
+		p flow.Profile
+		u float64
+	}{{layered(0.5, 0, 1, 1, 1), 2}, {layered(0, 0.3, 1.5, 1.5), 3}, {layered(math.Inf(1), 0.3, 0.5, 1.5), 2}} {
+		f, g := checkFlow(flow.Sphere), checkFlow(flow.Sphere)
+		f.FreeStream, f.Profile = 2, k.p
+		g.FreeStream = k.u
+		for i := 0; i < 1000; i++ {
+			x, y, z := float64(i%10)-4.5, float64(i/10%10)-4.5, float64(i/100)-4.5
+			fx, fy, fz := f.VelocityAt(x, y, z)
+			gx, gy, gz := g.VelocityAt(x, y, z)
+			same = same && fx == gx && fy == gy && fz == gz
+		}
+	}
+
+	// U1 = 0.5 below y0 = 0.3, U2 = 2 above, far from the sphere
+	const u1, u2, y0, delta = 0.5, 2.0, 0.3, 0.2
+	worst := 0.0
+	for _, thickness := range []float64{0, delta} {
+		f := checkFlow(flow.Sphere)
+		f.Profile = layered(thickness, y0, u1, u2)
+		for _, k := range []float64{-3, -1, 0, 1, 3} {
+			want := u1 + (u2-u1)*(1+math.Tanh(k))/2
+			y := y0 + k*thickness
+			if thickness == 0 {
+				want, y = u1+(u2-u1)*(1+math.Copysign(1, k))/2, y0+k
+				if k == 0 {
+					want = (u1 + u2) / 2
+				}
+			}
+			vx, _, _ := f.VelocityAt(1e6, y, 0)
+			worst = math.Max(worst, math.Abs(vx-want))
+		}
+	}
+	f := checkFlow(flow.Sphere)
+	f.Profile = layered(delta, y0, u1, u2)
+	g := f.VelocityGradient(1e6, y0, 0)
+	vorticity := math.Abs((g[1][0]-g[0][1])/(-(u2-u1)/(2*delta)) - 1)
+
+	config := flow.DefaultConfig()
+	config.FreeStream.Profile = layered(delta, y0, 1, 0.25, 1.5)
+	decoded, err := flow.DecodeConfig(config.Encode())
+	if err != nil {
+		return err
+	}
+	s := flow.NewSimulation(config)
+	snapshot, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	restored := flow.NewSimulation(flow.DefaultConfig())
+	if err := restored.UnmarshalBinary(snapshot); err != nil {
+		return err
+	}
+	kept := decoded.FreeStream.Profile == config.FreeStream.Profile && restored.Config.FreeStream.Profile == config.FreeStream.Profile
+	falling := layered(0, 0, 1, 2, 3)
+	falling.Heights[1] = -1
+	_, short := flow.DecodeProfile(map[string]interface{}{"type": "layered", "speeds": []interface{}{1.0, 2.0}})
+	_, many := flow.DecodeProfile(map[string]interface{}{"type": "layered", "speeds": make([]interface{}, flow.MaxBands+1)})
+	rejected := falling.Validate() != nil && short != nil && many != nil
+
+	c.report("layered bands", same && worst < 1e-9 && vorticity < 1e-5 && kept && rejected, "uniform cases identical: %v; far field speeds off by %.3g; shear layer vorticity off by %.3g; kept in configurations and snapshots %v; invalid tables rejected %v",
+		same, worst, vorticity, kept, rejected)
+	return nil
+}
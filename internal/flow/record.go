@@ -0,0 +1,123 @@
+package flow
+
+import "math"
+
+// Recorded scalar fields
+const (
+	FieldNone     = ""
+	FieldPressure = "pressure"
+	FieldSpeed    = "speed"
+)
+
+// DefaultRecordingLimit caps the memory of a recording unless a different
+// limit is given
+const DefaultRecordingLimit = 256 << 20
+
+// Frame is one recorded step
+type Frame struct {
+	Time      float64
+	Positions []float32 // x, y, z of every recorded particle
+	Field     []float32 // One value per recorded particle; nil without a field
+}
+
+// Recorder snapshots the particles of a Simulation every Every steps into
+// frames held in Go memory. It records every Stride-th particle, and stops
+// once MaxFrames frames are stored or the next frame would take the
+// recording beyond MaxBytes.
+type Recorder struct {
+	Every     int
+	MaxFrames int
+	Stride    int
+	Field     string
+	MaxBytes  int
+
+	Frames []Frame
+	Active bool
+	Err    error // Why recording stopped early, if it did
+
+	steps int
+	bytes int
+}
+
+// NewRecorder validates the recording parameters. stride and maxBytes
+// default to 1 and DefaultRecordingLimit when zero.
+func NewRecorder(every, maxFrames, stride int, field string, maxBytes int) (*Recorder, error) {
+	if stride == 0 {
+		stride = 1
+	}
+	if maxBytes == 0 {
+		maxBytes = DefaultRecordingLimit
+	}
+	if every < 1 || maxFrames < 1 || stride < 1 || maxBytes < 0 {
+		return nil, Errorf(ErrBadArguments, "everyNSteps, maxFrames and downsample must be positive")
+	}
+	switch field {
+	case FieldNone, FieldPressure, FieldSpeed:
+	default:
+		return nil, Errorf(ErrBadArguments, "unknown field %q", field)
+	}
+	return &Recorder{Every: every, MaxFrames: maxFrames, Stride: stride, Field: field, MaxBytes: maxBytes, Active: true}, nil
+}
+
+// FrameBytes returns the memory one frame of count particles takes: 12
+// bytes per recorded particle, plus 4 with a field
+func (r *Recorder) FrameBytes(count int) int {
+	per := 12
+	if r.Field != FieldNone {
+		per += 4
+	}
+	return (count + r.Stride - 1) / r.Stride * per
+}
+
+// Bytes returns the memory held by the recorded frames
+func (r *Recorder) Bytes() int {
+	return r.bytes
+}
+
+// CheckLimit fails if a full recording of count particles wouldn't fit in
+// MaxBytes
+func (r *Recorder) CheckLimit(count int) error {
+	if need := r.FrameBytes(count) * r.MaxFrames; need > r.MaxBytes {
+		return Errorf(ErrLimit, "recording %d frames of %d particles takes %d bytes, over the limit of %d", r.MaxFrames, count, need, r.MaxBytes)
+	}
+	return nil
+}
+
+// Capture records the current state of s if this step is due
+func (r *Recorder) Capture(s *Simulation) {
+	if !r.Active {
+		return
+	}
+	r.steps++
+	if (r.steps-1)%r.Every != 0 {
+		return
+	}
+	count := s.Count()
+	if r.bytes+r.FrameBytes(count) > r.MaxBytes {
+		r.Active = false
+		r.Err = Errorf(ErrLimit, "recording stopped after %d frames: memory limit of %d bytes reached", len(r.Frames), r.MaxBytes)
+		LogValue(LogWarn, "record", "memory limit reached, frames kept", float64(len(r.Frames)))
+		return
+	}
+
+	n := (count + r.Stride - 1) / r.Stride
+	f := Frame{Time: s.Time, Positions: make([]float32, 0, n*3)}
+	if r.Field != FieldNone {
+		f.Field = make([]float32, 0, n)
+	}
+	for i := 0; i < count; i += r.Stride {
+		f.Positions = append(f.Positions, s.Positions[i*3:i*3+3]...)
+		switch r.Field {
+		case FieldPressure:
+			f.Field = append(f.Field, s.Pressures[i])
+		case FieldSpeed:
+			v := s.Velocities[i*3 : i*3+3]
+			f.Field = append(f.Field, float32(math.Sqrt(float64(v[0])*float64(v[0])+float64(v[1])*float64(v[1])+float64(v[2])*float64(v[2]))))
+		}
+	}
+	r.Frames = append(r.Frames, f)
+	r.bytes += r.FrameBytes(count)
+	if len(r.Frames) == r.MaxFrames {
+		r.Active = false
+	}
+}
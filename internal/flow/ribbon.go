@@ -0,0 +1,194 @@
+package flow
+
+import "math"
+
+// Ribbon orientations (see RibbonSpec)
+const (
+	RibbonUp        = "up"        // Width across the velocity and Up
+	RibbonCurvature = "curvature" // Width across the velocity and the curvature normal, the ribbon in the osculating plane
+)
+
+// RibbonSpec configures Flow.Ribbon. The ribbon is Width wide, centred on
+// the streamline, its width across the local flow direction and either Up
+// or the curvature normal. Where that is undefined, along Up or on a
+// straight stretch, the width direction of the point before carries on,
+// turned to stay across the flow, or of the first point where it is
+// defined back to the start; and it turns by at most 45° from one point
+// to the next, so it never reverses nor folds a quad flat. With Twist, the ribbon also turns about the streamline with
+// the fluid, at half the streamwise vorticity over the speed per unit
+// length, which only the swirling free streams have.
+type RibbonSpec struct {
+	Width       float64
+	Orientation string
+	Up          [3]float64
+	Twist       bool
+}
+
+// DefaultRibbonSpec is the ribbon of RibbonSpec options left out, but for
+// the width
+var DefaultRibbonSpec = RibbonSpec{Orientation: RibbonUp, Up: [3]float64{0, 0, 1}}
+
+// Validate checks the parameters of r
+func (r *RibbonSpec) Validate() error {
+	if !(r.Width > 0) || math.IsInf(r.Width, 0) {
+		return Errorf(ErrBadArguments, "ribbon width must be a positive finite length, got %g", r.Width)
+	}
+	switch r.Orientation {
+	case RibbonUp:
+		if _, n := normalized(r.Up); !(n > 0) || math.IsInf(n, 0) {
+			return Errorf(ErrBadArguments, "ribbon up must be a non-zero finite vector, got %v", r.Up)
+		}
+	case RibbonCurvature:
+	default:
+		return Errorf(ErrBadArguments, "ribbon orientation must be %q or %q, got %q", RibbonUp, RibbonCurvature, r.Orientation)
+	}
+	return nil
+}
+
+// maxRibbonTurn is the largest turn of the width direction of a ribbon
+// from one point to the next, before any twist
+const maxRibbonTurn = math.Pi / 4
+
+// normalized returns v over its length, and its length
+func normalized(v [3]float64) ([3]float64, float64) {
+	n := math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+	if n == 0 {
+		return v, 0
+	}
+	return [3]float64{v[0] / n, v[1] / n, v[2] / n}, n
+}
+
+// Ribbon extrudes line, a streamline of f, into a ribbon mesh: two
+// vertices per point, on either side of it, in triangle strip order, so
+// the vertices alone draw as a strip, and Indices listing the same
+// triangles two per segment, facing Normals. Points within a thousandth
+// of the width of the one before are skipped, so steps where the flow
+// nearly stagnates add no zero-area quads. A line of fewer than two
+// distinct points gives an empty mesh.
+func (f *Flow) Ribbon(line Polyline, r RibbonSpec) (Mesh, error) {
+	if err := r.Validate(); err != nil {
+		return Mesh{}, err
+	}
+	var points [][3]float64
+	for i := 0; i < line.Len(); i++ {
+		p := [3]float64{float64(line[i*3]), float64(line[i*3+1]), float64(line[i*3+2])}
+		if p[0]-p[0] != 0 || p[1]-p[1] != 0 || p[2]-p[2] != 0 {
+			break
+		}
+		if n := len(points); n > 0 {
+			q := points[n-1]
+			if _, d := normalized([3]float64{p[0] - q[0], p[1] - q[1], p[2] - q[2]}); d <= 1e-3*r.Width {
+				continue
+			}
+		}
+		points = append(points, p)
+	}
+	var m Mesh
+	n := len(points)
+	if n < 2 {
+		return m, nil
+	}
+
+	// Tangents by central differences of the points, one-sided at the ends
+	tangents := make([][3]float64, n)
+	for i := range points {
+		a, b := points[max(i-1, 0)], points[min(i+1, n-1)]
+		tangents[i], _ = normalized([3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]})
+	}
+	// Width directions across t and up, or the curvature normal, where
+	// they are defined: below tiny, t runs along Up, or the turn of the
+	// tangent is float32 noise on a straight stretch
+	up, _ := normalized(r.Up)
+	sides := make([][3]float64, n)
+	defined := make([]bool, n)
+	first := -1
+	for i, t := range tangents {
+		var s, tiny float64
+		if r.Orientation == RibbonCurvature {
+			a, c := tangents[max(i-1, 0)], tangents[min(i+1, n-1)]
+			sides[i], s = normalized(cross(t, [3]float64{c[0] - a[0], c[1] - a[1], c[2] - a[2]}))
+			tiny = 1e-3
+		} else {
+			sides[i], s = normalized(cross(t, up))
+			tiny = 1e-6
+		}
+		if defined[i] = s >= tiny; defined[i] && first < 0 {
+			first = i
+		}
+	}
+	if first < 0 {
+		first = 0
+		sides[0] = across([3]float64{1, 0, 0}, tangents[0])
+	}
+	// Elsewhere the direction of the point before carries over, or of the
+	// first defined one back to the start, turned across the tangent, and
+	// no direction turns by more than maxRibbonTurn from the one before
+	for i := first - 1; i >= 0; i-- {
+		sides[i] = across(sides[i+1], tangents[i])
+	}
+	for i := first + 1; i < n; i++ {
+		t := tangents[i]
+		b := across(sides[i-1], t)
+		if defined[i] {
+			to := sides[i]
+			if to[0]*b[0]+to[1]*b[1]+to[2]*b[2] < 0 {
+				to = [3]float64{-to[0], -to[1], -to[2]}
+			}
+			bt := cross(b, to)
+			angle := math.Atan2(bt[0]*t[0]+bt[1]*t[1]+bt[2]*t[2], to[0]*b[0]+to[1]*b[1]+to[2]*b[2])
+			b = rotateAbout(b, t, math.Max(-maxRibbonTurn, math.Min(angle, maxRibbonTurn)))
+		}
+		sides[i] = b
+	}
+
+	theta := 0.0
+	for i, p := range points {
+		t, b := tangents[i], sides[i]
+		if r.Twist && i > 0 {
+			q := points[i-1]
+			mid := [3]float64{(p[0] + q[0]) / 2, (p[1] + q[1]) / 2, (p[2] + q[2]) / 2}
+			_, ds := normalized([3]float64{p[0] - q[0], p[1] - q[1], p[2] - q[2]})
+			vx, vy, vz := f.VelocityAt(mid[0], mid[1], mid[2])
+			if _, speed := normalized([3]float64{vx, vy, vz}); speed > 1e-12 {
+				g := f.VelocityGradient(mid[0], mid[1], mid[2])
+				w := [3]float64{g[2][1] - g[1][2], g[0][2] - g[2][0], g[1][0] - g[0][1]}
+				theta += (w[0]*t[0] + w[1]*t[1] + w[2]*t[2]) / 2 / speed * ds
+			}
+		}
+		if theta != 0 {
+			b = rotateAbout(b, t, theta)
+		}
+		normal := cross(b, t)
+		for _, h := range []float64{-r.Width / 2, r.Width / 2} {
+			m.Positions = append(m.Positions, float32(p[0]+h*b[0]), float32(p[1]+h*b[1]), float32(p[2]+h*b[2]))
+			m.Normals = append(m.Normals, float32(normal[0]), float32(normal[1]), float32(normal[2]))
+		}
+	}
+	for i := uint32(0); i+1 < uint32(n); i++ {
+		v := 2 * i
+		m.Indices = append(m.Indices, v, v+1, v+2, v+1, v+3, v+2)
+	}
+	return m, nil
+}
+
+// across returns v made perpendicular to the unit vector t and normalized,
+// or an axis so made if v runs along t
+func across(v, t [3]float64) [3]float64 {
+	d := v[0]*t[0] + v[1]*t[1] + v[2]*t[2]
+	w, n := normalized([3]float64{v[0] - d*t[0], v[1] - d*t[1], v[2] - d*t[2]})
+	if n < 1e-6 {
+		if math.Abs(t[0]) < 0.9 {
+			return across([3]float64{1, 0, 0}, t)
+		}
+		return across([3]float64{0, 1, 0}, t)
+	}
+	return w
+}
+
+// rotateAbout turns b, perpendicular to the unit vector t, by angle about
+// it
+func rotateAbout(b, t [3]float64, angle float64) [3]float64 {
+	c, s := math.Cos(angle), math.Sin(angle)
+	tb := cross(t, b)
+	return [3]float64{c*b[0] + s*tb[0], c*b[1] + s*tb[1], c*b[2] + s*tb[2]}
+}
@@ -0,0 +1,101 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestRibbons(t *testing.T) {
+	run(t, (*checker).checkRibbons)
+}
+
+// checkRibbons extrudes streamlines into ribbons: a line grazing the
+// front stagnation point of the sphere and turning around it, in both
+// orientations, must give quads of full width and positive area whose
+// triangles face their vertex normals and whose width never reverses; a
+// line with repeated points must skip them; and on the axis of a solid
+// body swirl the twisted ribbon must turn against the untwisted one by
+// the integral of the angular velocity over the speed.
+func (c *checker) checkRibbons() error {
+	const width = 0.1
+	f := flow.NewSimulation(flow.DefaultConfig()).Flow()
+	line, err := f.Streamline([3]float64{-3, 0.03, 0.02}, 0.05, 400)
+	if err != nil {
+		return err
+	}
+	sound, worst := true, math.Inf(1)
+	for _, o := range []string{flow.RibbonUp, flow.RibbonCurvature} {
+		r := flow.DefaultRibbonSpec
+		r.Width, r.Orientation = width, o
+		m, err := f.Ribbon(line, r)
+		if err != nil {
+			return err
+		}
+		sound = sound && len(m.Positions) == 2*len(line) && len(m.Indices) == 6*(line.Len()-1)
+		vertex := func(i uint32) [3]float64 {
+			return [3]float64{float64(m.Positions[i*3]), float64(m.Positions[i*3+1]), float64(m.Positions[i*3+2])}
+		}
+		for t := 0; sound && t < len(m.Indices); t += 3 {
+			a, b, d := vertex(m.Indices[t]), vertex(m.Indices[t+1]), vertex(m.Indices[t+2])
+			n := cross3([3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}, [3]float64{d[0] - a[0], d[1] - a[1], d[2] - a[2]})
+			k := m.Indices[t] * 3
+			facing := n[0]*float64(m.Normals[k]) + n[1]*float64(m.Normals[k+1]) + n[2]*float64(m.Normals[k+2])
+			worst = math.Min(worst, facing/(width*0.05))
+		}
+		for i := uint32(0); sound && i < uint32(line.Len()); i++ {
+			l, r := vertex(2*i), vertex(2*i+1)
+			w := [3]float64{r[0] - l[0], r[1] - l[1], r[2] - l[2]}
+			sound = math.Abs(math.Sqrt(w[0]*w[0]+w[1]*w[1]+w[2]*w[2])-width) < 1e-5
+			if i > 0 {
+				pl, pr := vertex(2*i-2), vertex(2*i-1)
+				sound = sound && w[0]*(pr[0]-pl[0])+w[1]*(pr[1]-pl[1])+w[2]*(pr[2]-pl[2]) > 0
+			}
+		}
+	}
+	sound = sound && worst > 0.5
+
+	repeated := flow.Polyline{0, 0, 0, 0, 0, 0, 1, 0, 0, 1, 0, 0, 1 + 1e-7, 0, 0, 2, 0, 0}
+	m, err := f.Ribbon(repeated, flow.RibbonSpec{Width: width, Orientation: flow.RibbonUp, Up: [3]float64{0, 0, 1}})
+	if err != nil {
+		return err
+	}
+	skipped := len(m.Positions) == 6*3 && len(m.Indices) == 12
+
+	cfg := flow.DefaultConfig()
+	cfg.FreeStream.Swirl = flow.Swirl{Type: flow.SwirlSolid, Number: 0.5, Radius: 1}
+	s := flow.NewSimulation(cfg)
+	f = s.Flow()
+	axis, err := f.Streamline([3]float64{-20, 0, 0}, 0.05, 100)
+	if err != nil {
+		return err
+	}
+	r := flow.RibbonSpec{Width: width, Orientation: flow.RibbonUp, Up: [3]float64{0, 0, 1}}
+	plain, err := f.Ribbon(axis, r)
+	if err != nil {
+		return err
+	}
+	r.Twist = true
+	twisted, err := f.Ribbon(axis, r)
+	if err != nil {
+		return err
+	}
+	want := 0.0
+	for i := 1; i < axis.Len(); i++ {
+		x := float64(axis[i*3]+axis[i*3-3]) / 2
+		vx, _, _ := f.VelocityAt(x, 0, 0)
+		want += 0.5 * f.FreeStream / vx * float64(axis[i*3]-axis[i*3-3])
+	}
+	k := len(plain.Positions) - 6
+	side := func(p []float32) [3]float64 {
+		return [3]float64{float64(p[k+3] - p[k]), float64(p[k+4] - p[k+1]), float64(p[k+5] - p[k+2])}
+	}
+	b0, b1 := side(plain.Positions), side(twisted.Positions)
+	tb := cross3([3]float64{1, 0, 0}, b0)
+	got := math.Atan2(tb[0]*b1[0]+tb[1]*b1[1]+tb[2]*b1[2], b0[0]*b1[0]+b0[1]*b1[1]+b0[2]*b1[2])
+	twist := math.Abs(got-want) < 1e-3*want
+
+	c.report("streamline ribbons", sound && skipped && twist, "around the sphere: full width, no reversals %v, worst facing %.3g of the quad area; repeated points skipped %v; swirl twist %.5g rad, want %.5g", sound, worst, skipped, got, want)
+	return nil
+}
@@ -0,0 +1,206 @@
+package flow
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Streams of Random, one per stochastic subsystem
+const (
+	StreamSeeding = "seeding" // Seeds of random seeding without an explicit seed
+)
+
+// MaxSeed is the largest seed accepted by Random.SetSeed, the largest
+// integer a JS number or JSON holds exactly
+const MaxSeed = 1<<53 - 1
+
+// Random is the central random number generator of a Simulation. Every
+// subsystem draws from a stream of its own, identified by name, so using
+// one feature never shifts the sequence another one sees. Each stream is a
+// PCG32 generator whose sequence is selected by the hash of its name and
+// whose state is derived from the shared seed; the seed and the number of
+// values drawn from each stream, see RandomState, fully determine where
+// every sequence continues. The zero value uses seed 0.
+type Random struct {
+	seed    uint64
+	streams map[string]*Stream
+}
+
+// SetSeed restarts every stream from seed
+func (r *Random) SetSeed(seed uint64) error {
+	if seed > MaxSeed {
+		return Errorf(ErrBadArguments, "seed must be an integer between 0 and %d, got %d", uint64(MaxSeed), seed)
+	}
+	r.seed, r.streams = seed, nil
+	return nil
+}
+
+// Seed returns the seed of r
+func (r *Random) Seed() uint64 {
+	return r.seed
+}
+
+// Stream returns the stream called name, starting it if it hasn't been
+// used since the seed was set
+func (r *Random) Stream(name string) *Stream {
+	s := r.streams[name]
+	if s == nil {
+		s = newStream(r.seed, name)
+		if r.streams == nil {
+			r.streams = map[string]*Stream{}
+		}
+		r.streams[name] = s
+	}
+	return s
+}
+
+// RandomState is the position of a Random: its seed and the number of
+// 32-bit values drawn from each stream that has been used
+type RandomState struct {
+	Seed  uint64
+	Draws map[string]uint64
+}
+
+// State returns the position of r
+func (r *Random) State() RandomState {
+	st := RandomState{Seed: r.seed, Draws: map[string]uint64{}}
+	for name, s := range r.streams {
+		st.Draws[name] = s.draws
+	}
+	return st
+}
+
+// Restore moves r to the position st, jumping each stream ahead rather
+// than replaying it
+func (r *Random) Restore(st RandomState) error {
+	var t Random
+	if err := t.SetSeed(st.Seed); err != nil {
+		return err
+	}
+	for name, n := range st.Draws {
+		if name == "" {
+			return Errorf(ErrBadArguments, "random stream names must not be empty")
+		}
+		t.Stream(name).advance(n)
+	}
+	*r = t
+	return nil
+}
+
+// Encode returns st in generic form: {seed, streams: {name: draws}}
+func (st *RandomState) Encode() map[string]interface{} {
+	streams := map[string]interface{}{}
+	for name, n := range st.Draws {
+		streams[name] = float64(n)
+	}
+	return map[string]interface{}{"seed": float64(st.Seed), "streams": streams}
+}
+
+// decodeRandom decodes the random section of a scenario
+func decodeRandom(v interface{}, warnings *[]string) (RandomState, error) {
+	st := RandomState{Draws: map[string]uint64{}}
+	m, err := known(v, "random", warnings, "seed", "streams")
+	if err != nil {
+		return st, err
+	}
+	var seed float64
+	if err := numberKey(m, "random", "seed", &seed); err != nil {
+		return st, err
+	}
+	if st.Seed, err = safeUint("random.seed", seed); err != nil {
+		return st, err
+	}
+	if s, ok := m["streams"]; ok {
+		streams, ok := s.(map[string]interface{})
+		if !ok {
+			return st, Errorf(ErrBadArguments, "random.streams must be an object")
+		}
+		names := make([]string, 0, len(streams))
+		for name := range streams {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			n, ok := streams[name].(float64)
+			if !ok {
+				return st, Errorf(ErrBadArguments, "random.streams.%s must be a number", name)
+			}
+			if st.Draws[name], err = safeUint("random.streams."+name, n); err != nil {
+				return st, err
+			}
+		}
+	}
+	return st, nil
+}
+
+// safeUint converts x to an integer, checking that it is one between 0 and
+// MaxSeed
+func safeUint(name string, x float64) (uint64, error) {
+	if !(x >= 0 && x <= MaxSeed) || x != math.Trunc(x) {
+		return 0, Errorf(ErrBadArguments, "%s must be an integer between 0 and %d, got %g", name, uint64(MaxSeed), x)
+	}
+	return uint64(x), nil
+}
+
+// Stream is one sequence of a Random
+type Stream struct {
+	state, inc uint64
+	draws      uint64
+}
+
+// pcgMultiplier is the LCG multiplier of PCG32
+const pcgMultiplier = 6364136223846793005
+
+// newStream starts the stream called name for seed, as PCG32's srandom
+// with the FNV-1a hash of name as the sequence
+func newStream(seed uint64, name string) *Stream {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(name); i++ {
+		h = (h ^ uint64(name[i])) * 1099511628211
+	}
+	s := &Stream{inc: h<<1 | 1}
+	s.step()
+	s.state += seed
+	s.step()
+	return s
+}
+
+func (s *Stream) step() {
+	s.state = s.state*pcgMultiplier + s.inc
+}
+
+// Uint32 returns the next value of the stream
+func (s *Stream) Uint32() uint32 {
+	old := s.state
+	s.step()
+	s.draws++
+	return bits.RotateLeft32(uint32((old>>18^old)>>27), -int(old>>59))
+}
+
+// Float64 returns a uniform value in [0, 1) built from two draws
+func (s *Stream) Float64() float64 {
+	hi, lo := uint64(s.Uint32()>>5), uint64(s.Uint32()>>6)
+	return float64(hi<<26|lo) / (1 << 53)
+}
+
+// Draws returns the number of 32-bit values drawn so far
+func (s *Stream) Draws() uint64 {
+	return s.draws
+}
+
+// advance jumps the stream n draws ahead in O(log n) steps
+func (s *Stream) advance(n uint64) {
+	accMult, accPlus := uint64(1), uint64(0)
+	curMult, curPlus := uint64(pcgMultiplier), s.inc
+	for d := n; d > 0; d >>= 1 {
+		if d&1 != 0 {
+			accMult *= curMult
+			accPlus = accPlus*curMult + curPlus
+		}
+		curPlus = (curMult + 1) * curPlus
+		curMult *= curMult
+	}
+	s.state = accMult*s.state + accPlus
+	s.draws += n
+}
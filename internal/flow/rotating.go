@@ -0,0 +1,118 @@
+package flow
+
+import "math"
+
+// RotatingFrame views the flow from a frame turning at Omega, in rad/s,
+// right-handed about the axis through Center along Axis, as for the
+// blades of a turbomachine turning with it. The flow solution is
+// evaluated as it is, steady in the rotating frame, and the particles are
+// advected with the velocity relative to the frame, v - Ω×r, r the offset
+// from the axis. Simulation.Velocities report that relative velocity, or
+// the absolute v with Absolute set. With Potential set the pressures
+// include the potential of the centrifugal force, ½ρ|Ω×r|², so that
+// pressure coefficients compare with the rotating-frame Bernoulli
+// equation, p + ½ρ|v - Ω×r|² - ½ρ|Ω×r|² constant along a relative
+// streamline. The particles are tracers, without inertia, so the Coriolis
+// and centrifugal accelerations don't enter their motion. LOD is
+// suspended while the frame turns, the relative velocity changing
+// everywhere with the position. An Omega of 0 leaves everything as it is,
+// bit for bit. A runtime setting.
+type RotatingFrame struct {
+	Axis      [3]float64 // Unit axis
+	Center    [3]float64 // A point of the axis
+	Omega     float64    // rad/s
+	Absolute  bool       // Velocities report the absolute velocity
+	Potential bool       // Pressures include ½ρ|Ω×r|²
+}
+
+// Validate checks the parameters of r
+func (r *RotatingFrame) Validate() error {
+	for _, x := range append(r.Center[:], r.Omega) {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "rotating frame: center and omega must be finite, got %v and %g", r.Center, r.Omega)
+		}
+	}
+	if n := math.Sqrt(r.Axis[0]*r.Axis[0] + r.Axis[1]*r.Axis[1] + r.Axis[2]*r.Axis[2]); math.Abs(n-1) > 1e-9 {
+		return Errorf(ErrBadArguments, "rotating frame: axis must be a unit vector, got %v", r.Axis)
+	}
+	return nil
+}
+
+// active reports whether r is set and turns
+func (r *RotatingFrame) active() bool {
+	return r != nil && r.Omega != 0
+}
+
+// velocity returns the velocity Ω×r of the frame r at (x, y, z)
+func (r *RotatingFrame) velocity(x, y, z float64) [3]float64 {
+	w := [3]float64{r.Omega * r.Axis[0], r.Omega * r.Axis[1], r.Omega * r.Axis[2]}
+	return cross(w, [3]float64{x - r.Center[0], y - r.Center[1], z - r.Center[2]})
+}
+
+// SetRotatingFrame views the flow of s from r from the next evaluation
+// on, or from the frame of s.Config.Frame again if r is nil
+func (s *Simulation) SetRotatingFrame(r *RotatingFrame) error {
+	if r != nil {
+		if r.Axis == [3]float64{} {
+			r.Axis = [3]float64{0, 0, 1}
+		}
+		var err error
+		if r.Axis, err = unit(r.Axis, "rotating frame: axis"); err != nil {
+			return err
+		}
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	s.Rotating = r
+	return nil
+}
+
+// rotate makes the velocities of particles from to to relative to
+// s.Rotating, unless it reports absolute ones, and adds its potential to
+// their pressures if asked to, ρ being the density of the fluid
+func (s *Simulation) rotate(from, to int, rho float64) {
+	r := s.Rotating
+	if !r.active() || r.Absolute && !r.Potential {
+		return
+	}
+	for i := from; i < to; i++ {
+		u := r.velocity(float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), float64(s.Positions[i*3+2]))
+		if !r.Absolute {
+			for a := range u {
+				s.Velocities[i*3+a] -= float32(u[a])
+			}
+		}
+		if r.Potential {
+			s.Pressures[i] += float32(rho / 2 * (u[0]*u[0] + u[1]*u[1] + u[2]*u[2]))
+		}
+	}
+}
+
+// turn subtracts the velocity of s.Rotating from the absolute velocities
+// it reports, for advection
+func (s *Simulation) turn(count int) {
+	r := s.Rotating
+	for i := 0; i < count; i++ {
+		u := r.velocity(float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), float64(s.Positions[i*3+2]))
+		for a := range u {
+			s.Velocities[i*3+a] -= float32(u[a])
+		}
+	}
+}
+
+// rotatingState returns the rotatingFrame section of State
+func (s *Simulation) rotatingState() map[string]interface{} {
+	r := s.Rotating
+	if r == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled":   true,
+		"axis":      []interface{}{r.Axis[0], r.Axis[1], r.Axis[2]},
+		"center":    []interface{}{r.Center[0], r.Center[1], r.Center[2]},
+		"omega":     r.Omega,
+		"absolute":  r.Absolute,
+		"potential": r.Potential,
+	}
+}
@@ -0,0 +1,286 @@
+package flow
+
+import (
+	"math"
+	"sync"
+)
+
+// Rotor wake discretization
+const (
+	rotorWake      = 40   // Length of the wake without a ground, in radii
+	rotorFirstRing = 0.02 // Spacing of the rings at the disk, in radii
+	rotorRingMax   = 0.25 // Largest ring spacing, in radii
+	rotorGrowth    = 1.1  // Ratio of successive ring spacings
+)
+
+// Rotor is a hovering rotor, an actuator disk of radius Radius centered on
+// Position in a horizontal plane, blowing its downwash column along -z
+// through fluid otherwise at rest. Its wake is a vortex sheet shed from
+// the disk edge with the strength 2·vi of momentum theory, vi the induced
+// velocity at the disk, contracting as
+//
+//	r(d) = R/√(1 + d/√(d² + R²))
+//
+// at depth d below the disk, the radius in which continuity puts the flow
+// of a straight sheet's centerline, to R/√2 in the far wake, where the
+// flow reaches 2·vi. The sheet is prescribed rather than solved for, so
+// the flow through the disk comes out a little short of vi: 0.92·vi at
+// the hub and 0.79·vi averaged over the disk. It is modeled by vortex
+// rings, closer together near the disk, each with a core of half the
+// spacing and its circulation raised to make up for the core on the axis.
+//
+// Thrust T, in newtons whatever the units, gives vi = √(T/2ρπR²) instead.
+// A Ground below the hub ends the wake on a floor, imaged in it, so the
+// downwash turns and spreads out along the floor. Radius 0 means no rotor.
+type Rotor struct {
+	Radius          float64
+	InducedVelocity float64
+	Thrust          float64
+	Position        [3]float64
+	Ground          float64 // Height of the hub above the floor, 0 for none
+}
+
+// enabled reports whether r is a rotor
+func (r *Rotor) enabled() bool {
+	return r.Radius != 0
+}
+
+// Validate checks the parameters of r
+func (r *Rotor) Validate() error {
+	for _, v := range []struct {
+		name string
+		v    float64
+	}{
+		{"radius", r.Radius}, {"inducedVelocity", r.InducedVelocity}, {"thrust", r.Thrust},
+		{"position", r.Position[0]}, {"position", r.Position[1]}, {"position", r.Position[2]}, {"ground", r.Ground},
+	} {
+		if math.IsNaN(v.v) || math.IsInf(v.v, 0) {
+			return Errorf(ErrBadArguments, "rotor.%s must be finite, got %g", v.name, v.v)
+		}
+		if v.v < 0 && v.name != "position" {
+			return Errorf(ErrBadArguments, "rotor.%s must be non-negative, got %g", v.name, v.v)
+		}
+	}
+	if !r.enabled() {
+		if r.InducedVelocity != 0 || r.Thrust != 0 || r.Ground != 0 {
+			return Errorf(ErrBadArguments, "a rotor needs a positive radius")
+		}
+		return nil
+	}
+	if (r.InducedVelocity == 0) == (r.Thrust == 0) {
+		return Errorf(ErrBadArguments, "a rotor needs either an induced velocity or a thrust, got %g and %g", r.InducedVelocity, r.Thrust)
+	}
+	return nil
+}
+
+// validateRotor checks the rotor of f against the rest of the flow: fluid
+// at rest, and no object or other onset flow model
+func (f *Flow) validateRotor() error {
+	r := &f.Rotor
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if !r.enabled() {
+		return nil
+	}
+	switch {
+	case f.FreeStream != 0:
+		return Errorf(ErrBadArguments, "a hovering rotor has no free stream, got speed %g", f.FreeStream)
+	case f.Object.Radius != 0:
+		return Errorf(ErrUnsupported, "a rotor has no object; set its radius to 0")
+	case f.Elements != nil:
+		return Errorf(ErrUnsupported, "a rotor has no elementary flows")
+	case f.Boundary.image() != 0:
+		return Errorf(ErrUnsupported, "a rotor has no %s boundary; use its ground", f.Boundary.Mode)
+	case f.Channel.enabled():
+		return Errorf(ErrUnsupported, "a rotor has no channel")
+	case f.Bump.enabled():
+		return Errorf(ErrUnsupported, "a rotor has no bump")
+	}
+	return nil
+}
+
+// inducedVelocity returns vi for r in a fluid of density rho
+func (r *Rotor) inducedVelocity(rho float64) float64 {
+	if r.Thrust != 0 {
+		return math.Sqrt(r.Thrust / (2 * rho * math.Pi * r.Radius * r.Radius))
+	}
+	return r.InducedVelocity
+}
+
+// floor returns the height of the floor under r, and whether it has one
+func (r *Rotor) floor() (float64, bool) {
+	return r.Position[2] - r.Ground, r.enabled() && r.Ground != 0
+}
+
+// belowFloor reports whether height z is under the floor of r
+func (r *Rotor) belowFloor(z float64) bool {
+	h, ok := r.floor()
+	return ok && z < h
+}
+
+// rotorRing is a vortex ring of a rotor wake, in radii from the hub, for
+// an induced velocity of 1
+type rotorRing struct {
+	z, a  float64 // Height and radius
+	gamma float64 // Circulation, right-handed about +z
+	core2 float64 // Squared core radius
+}
+
+// lastRotor holds the rings of the last wake, since rotors differing
+// only in radius, position and induced velocity share them
+var lastRotor struct {
+	sync.Mutex
+	depth float64
+	rings []rotorRing
+}
+
+// rotorRings returns the rings of a wake depth radii long
+func rotorRings(depth float64) []rotorRing {
+	lastRotor.Lock()
+	defer lastRotor.Unlock()
+	if lastRotor.rings != nil && lastRotor.depth == depth {
+		return lastRotor.rings
+	}
+	var rings []rotorRing
+	for d, h := 0.0, rotorFirstRing; d < depth; d, h = d+h, math.Min(h*rotorGrowth, rotorRingMax) {
+		h = math.Min(h, depth-d)
+		mid := d + h/2
+		a := 1 / math.Sqrt(1+mid/math.Hypot(mid, 1))
+		core2 := h * h / 4
+		// A cored ring induces a²/(a² + core²) of its velocity, summed
+		// along the axis
+		rings = append(rings, rotorRing{z: -mid, a: a, gamma: -2 * h * (a*a + core2) / (a * a), core2: core2})
+	}
+	lastRotor.depth, lastRotor.rings = depth, rings
+	return rings
+}
+
+// ringVortex returns the axial and radial velocity at (r, z) of a vortex
+// ring of radius a at height z0 and circulation Γ, right-handed about +z,
+// with a core of squared radius core2 added to the squared distances A and
+// B from the ring's far and near side:
+//
+//	u_z = Γ/2π√A·(K(m) + (a² - r² - dz²)/B·E(m))
+//	u_r = Γ·dz/2πr√A·(-K(m) + (a² + r² + dz²)/B·E(m))
+//
+// with m = 4a·r/A
+func ringVortex(r, z, a, z0, gamma, core2 float64) (uz, ur float64) {
+	dz := z - z0
+	p := (r+a)*(r+a) + dz*dz + core2
+	q := (a-r)*(a-r) + dz*dz + core2
+	k, e := ellipticKE(4 * a * r / p)
+	s := gamma / (2 * math.Pi * math.Sqrt(p))
+	uz = s * (k + (a*a-r*r-dz*dz)/q*e)
+	if r > 1e-9*a {
+		ur = s * dz / r * (-k + (a*a+r*r+dz*dz)/q*e)
+	}
+	return uz, ur
+}
+
+// rotorFrame prepares the wake of k's rotor, ending it on the floor
+func (k *kernel) rotorFrame(r Rotor, rho float64) {
+	if !r.enabled() {
+		return
+	}
+	depth := float64(rotorWake)
+	if r.Ground != 0 {
+		depth = r.Ground / r.Radius
+	}
+	k.rotor, k.rotorRings = r, rotorRings(depth)
+	k.rotorVi = r.inducedVelocity(rho)
+}
+
+// rotored adds the flow of the rotor's wake to (vx, vy, vz), evaluated at
+// (px, py, pz), with the floor's image of it; below the floor the velocity
+// is zero
+func (k *kernel) rotored(px, py, pz, vx, vy, vz float64) (float64, float64, float64) {
+	r := &k.rotor
+	if !r.enabled() {
+		return vx, vy, vz
+	}
+	if k.excluded(px, py, pz) {
+		return 0, 0, 0
+	}
+	x, y, z := (px-r.Position[0])/r.Radius, (py-r.Position[1])/r.Radius, (pz-r.Position[2])/r.Radius
+	rho := math.Hypot(x, y)
+	floor := -r.Ground / r.Radius
+	var uz, ur float64
+	for i := range k.rotorRings {
+		g := &k.rotorRings[i]
+		a, b := ringVortex(rho, z, g.a, g.z, g.gamma, g.core2)
+		uz, ur = uz+a, ur+b
+		if r.Ground != 0 {
+			a, b = ringVortex(rho, z, g.a, 2*floor-g.z, -g.gamma, g.core2)
+			uz, ur = uz+a, ur+b
+		}
+	}
+	uz, ur = k.rotorVi*uz, k.rotorVi*ur
+	if rho > 0 {
+		vx, vy = vx+ur*x/rho, vy+ur*y/rho
+	}
+	return vx, vy, vz + uz
+}
+
+// reflectRotor mirrors the particles of s that went below the rotor's
+// floor back above it
+func (s *Simulation) reflectRotor(count int) {
+	h, ok := s.Config.Rotor.floor()
+	if !ok {
+		return
+	}
+	for i := 0; i < count; i++ {
+		if z := float64(s.Positions[i*3+2]); z < h {
+			s.Positions[i*3+2] = float32(2*h - z)
+		}
+	}
+}
+
+// Encode returns r in the generic form accepted by DecodeConfig under
+// rotor
+func (r Rotor) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"radius":          r.Radius,
+		"inducedVelocity": r.InducedVelocity,
+		"thrust":          r.Thrust,
+		"position":        []interface{}{r.Position[0], r.Position[1], r.Position[2]},
+		"ground":          r.Ground,
+	}
+}
+
+// DecodeRotor decodes the rotor section of a configuration,
+//
+//	{radius: number = 0, inducedVelocity: number = 0, thrust: number = 0,
+//	 position: [x, y, z] = [0, 0, 0], ground: number = 0}
+func DecodeRotor(v interface{}) (Rotor, error) {
+	var r Rotor
+	m, err := section(v, "rotor", "radius", "inducedVelocity", "thrust", "position", "ground")
+	if err != nil {
+		return r, err
+	}
+	for _, k := range []struct {
+		key string
+		dst *float64
+	}{{"radius", &r.Radius}, {"inducedVelocity", &r.InducedVelocity}, {"thrust", &r.Thrust}, {"ground", &r.Ground}} {
+		if err := numberKey(m, "rotor", k.key, k.dst); err != nil {
+			return r, err
+		}
+	}
+	if v, ok := m["position"]; ok {
+		if r.Position, err = vector(v, "rotor.position"); err != nil {
+			return r, err
+		}
+	}
+	return r, r.Validate()
+}
+
+// SetRotor replaces the rotor, checking it against the current flow
+func (s *Simulation) SetRotor(r Rotor) error {
+	f := s.Flow()
+	f.Rotor = r
+	if err := f.validateRotor(); err != nil {
+		return err
+	}
+	s.Config.Rotor = r
+	return nil
+}
@@ -0,0 +1,90 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestRotor(t *testing.T) {
+	run(t, (*checker).checkRotor)
+}
+
+// checkRotor verifies the hovering rotor: 2·vi in the far wake inside
+// radius/√2 and none outside it, a thrust standing for the induced
+// velocity of momentum theory, no flow through the floor and outwash
+// along it, particles drawn down through the disk and spread out along
+// the floor without crossing it, a free stream rejected, and the rotor
+// surviving a snapshot
+func (c *checker) checkRotor() error {
+	hub := [3]float64{1, -1, 5}
+	f := flow.Flow{Density: 1.2, Rotor: flow.Rotor{Radius: 2, InducedVelocity: 3, Position: hub}}
+	_, _, in := f.VelocityAt(hub[0]+0.4, hub[1], hub[2]-60)
+	_, _, out := f.VelocityAt(hub[0]+3, hub[1], hub[2]-60)
+	far := math.Abs(in/-6 - 1)
+	outside := math.Abs(out / 3)
+	thrust := f
+	thrust.Rotor.InducedVelocity, thrust.Rotor.Thrust = 0, 2*1.2*math.Pi*4*9
+	tx, ty, tz := thrust.VelocityAt(hub[0]+0.7, hub[1]+0.4, hub[2]-1)
+	vx, vy, vz := f.VelocityAt(hub[0]+0.7, hub[1]+0.4, hub[2]-1)
+	same := math.Abs(tx-vx)+math.Abs(ty-vy)+math.Abs(tz-vz) < strengthTolerance
+
+	g := f
+	g.Rotor.Ground = 4
+	floor := hub[2] - 4
+	normal, outwash := 0.0, math.Inf(1)
+	for _, r := range []float64{0, 0.5, 1.5, 3, 6} {
+		ux, _, uz := g.VelocityAt(hub[0]+r, hub[1], floor)
+		normal = math.Max(normal, math.Abs(uz))
+		if r >= 1.5 {
+			outwash = math.Min(outwash, ux)
+		}
+	}
+	bx, by, bz := g.VelocityAt(hub[0]+1, hub[1], floor-0.1)
+	below := bx == 0 && by == 0 && bz == 0
+
+	// A ring of particles over the disk
+	config := flow.DefaultConfig()
+	config.FreeStream.Speed, config.Object.Radius, config.Density, config.Rotor = 0, 0, 1.2, g.Rotor
+	s := flow.NewSimulation(config)
+	const n = 16
+	start := make([]float32, 0, 3*n)
+	for i := 0; i < n; i++ {
+		t := 2 * math.Pi * float64(i) / n
+		start = append(start, float32(hub[0]+1.2*math.Cos(t)), float32(hub[1]+1.2*math.Sin(t)), float32(hub[2]+0.5))
+	}
+	if err := s.SetParticles(start, n); err != nil {
+		return err
+	}
+	for i := 0; i < 100; i++ {
+		if err := s.Step(0.02); err != nil {
+			return err
+		}
+	}
+	spread := true
+	for i := 0; i < n; i++ {
+		p := s.Positions[i*3 : i*3+3]
+		r := math.Hypot(float64(p[0])-hub[0], float64(p[1])-hub[1])
+		spread = spread && r > 2 && float64(p[2]) >= floor && float64(p[2]) < hub[2]-2
+	}
+	snapshot, err := s.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	restored := flow.NewSimulation(flow.DefaultConfig())
+	if err := restored.UnmarshalBinary(snapshot); err != nil {
+		return err
+	}
+	spread = spread && restored.Config.Rotor == g.Rotor
+
+	stream := f
+	stream.FreeStream = 1
+	rejected := stream.Validate() != nil
+
+	ok := far < rotorTolerance && outside < rotorTolerance && same && normal < strengthTolerance && outwash > 0 &&
+		below && spread && rejected
+	c.report("rotor", ok, "far wake |w/2vi - 1| %.3g, outside %.3g; thrust as vi %v; floor |w| %.3g, least outwash %.3f; zero below %v; particles spread %v; free stream rejected %v",
+		far, outside, same, normal, outwash, below, spread, rejected)
+	return nil
+}
@@ -0,0 +1,407 @@
+package flow
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ScenarioVersion is the version written by Scenario.Encode. Scenarios with
+// a higher version are still read, ignoring the fields this version doesn't
+// know about.
+const ScenarioVersion = 1
+
+// Scenario is everything needed to reproduce a simulation: the
+// configuration, the particle seeding, the time step and the clock, and
+// optionally the particle positions themselves. The encoded form is
+//
+//	{
+//	  version: 1,
+//	  freeStream: {...}, fluid: {...},    // as in Config, plus freeStream.schedule
+//	  frame: "body" | "lab",              // as in Config
+//	  precision: "single" | "double",     // as in Config
+//	  arithmetic: "accurate" | "fast",    // as in Config
+//	  objects: [{..., motion}],           // Config.object, one entry
+//	  elements: [{kind, ...}],            // optional, as in DecodeElements
+//	  boundaries: {mode, height, clamp},  // as in Config
+//	  channel: {inletHeight, ...},        // as in Config
+//	  bump: {height, width, x, panels},   // as in Config
+//	  rotor: {radius, ...},               // as in Config
+//	  collision: {enabled, ...},          // as in Config
+//	  probes: [{id, center, normal, radius, rings}],      // optional
+//	  seeding: {kind, count, min, max, resolution, seed},  // optional
+//	  random: {seed, streams: {name: draws}},              // optional
+//	  dt: number, time: number,
+//	  particles: {positions: [x1, y1, z1, ...]}            // optional
+//	}
+//
+// On import, particles take precedence over seeding; their positions are
+// rounded to float32 whatever the precision. Without either the
+// simulation starts with no particles. random is the position of the
+// simulation's Random, so that a restored scenario continues with the same
+// random sequences; without it the generator starts from seed 0. motion is
+// the object's Motion in the form of Motion.Encode, present if it has one,
+// and schedule the free stream Schedule as an array of [t, U] pairs.
+// elements, present if the simulation has Elements, replaces the object
+// solution. probes, present if it has Probes, defines them, their
+// histories starting empty.
+type Scenario struct {
+	Config    Config
+	Seeding   *SeedSpec
+	Random    RandomState
+	Motion    *Motion
+	Schedule  *Schedule
+	Elements  *Superposition
+	Probes    []FlowProbe
+	DT        float64
+	Time      float64
+	Positions []float32 // nil unless the particles are included
+}
+
+// Scenario captures the state of s. Positions are included only if
+// includeParticles is set; without them, importing the scenario reseeds the
+// particles, which only reproduces s exactly if it hasn't been stepped.
+func (s *Simulation) Scenario(includeParticles bool) Scenario {
+	sc := Scenario{Config: s.Config, Seeding: s.Seeding, Random: s.Random.State(), Motion: s.Motion, Schedule: s.Schedule, Elements: s.Elements, Probes: s.Probes.Definitions(), DT: s.DT, Time: s.Time}
+	if includeParticles {
+		sc.Positions = append([]float32{}, s.Positions...)
+	}
+	return sc
+}
+
+// Simulation builds the simulation described by sc
+func (sc *Scenario) Simulation() (*Simulation, error) {
+	s := NewSimulation(sc.Config)
+	s.DT, s.Time = sc.DT, sc.Time
+	if err := s.Random.Restore(sc.Random); err != nil {
+		return nil, err
+	}
+	if sc.Motion != nil {
+		m := *sc.Motion
+		s.Motion = &m
+	}
+	s.Schedule, s.Elements = sc.Schedule, sc.Elements
+	for _, p := range sc.Probes {
+		if err := s.Probes.Define(p); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case sc.Positions != nil:
+		if err := s.SetParticles(sc.Positions, len(sc.Positions)/3); err != nil {
+			return nil, err
+		}
+		s.Seeding = sc.Seeding
+	case sc.Seeding != nil:
+		if err := s.Seed(*sc.Seeding); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Encode returns sc in generic form, suitable for JSON
+func (sc *Scenario) Encode() map[string]interface{} {
+	c := sc.Config.Encode()
+	if sc.Motion != nil {
+		c["object"].(map[string]interface{})["motion"] = sc.Motion.Encode()
+	}
+	if sc.Schedule != nil {
+		c["freeStream"].(map[string]interface{})["schedule"] = sc.Schedule.Encode()
+	}
+	m := map[string]interface{}{
+		"version":    ScenarioVersion,
+		"freeStream": c["freeStream"],
+		"fluid":      c["fluid"],
+		"frame":      c["frame"],
+		"precision":  c["precision"],
+		"arithmetic": c["arithmetic"],
+		"objects":    []interface{}{c["object"]},
+		"boundaries": c["boundaries"],
+		"channel":    c["channel"],
+		"bump":       c["bump"],
+		"rotor":      c["rotor"],
+		"collision":  c["collision"],
+		"random":     sc.Random.Encode(),
+		"dt":         sc.DT,
+		"time":       sc.Time,
+	}
+	if sp := sc.Seeding; sp != nil {
+		g := sp.Grid
+		m["seeding"] = map[string]interface{}{
+			"kind":       sp.Kind,
+			"count":      sp.Count,
+			"min":        []interface{}{g.Min[0], g.Min[1], g.Min[2]},
+			"max":        []interface{}{g.Max[0], g.Max[1], g.Max[2]},
+			"resolution": []interface{}{g.N[0], g.N[1], g.N[2]},
+			"seed":       float64(sp.Seed),
+		}
+	}
+	if sc.Elements != nil {
+		m["elements"] = sc.Elements.Encode()
+	}
+	if len(sc.Probes) > 0 {
+		probes := make([]interface{}, len(sc.Probes))
+		for i := range sc.Probes {
+			probes[i] = sc.Probes[i].Encode()
+		}
+		m["probes"] = probes
+	}
+	if sc.Positions != nil {
+		p := make([]interface{}, len(sc.Positions))
+		for i, x := range sc.Positions {
+			p[i] = float64(x)
+		}
+		m["particles"] = map[string]interface{}{"positions": p}
+	}
+	return m
+}
+
+// DecodeScenario decodes a scenario from generic values (see DecodeConfig).
+// Unknown fields don't fail the import: they are dropped and reported in
+// the returned warnings.
+func DecodeScenario(v interface{}) (Scenario, []string, error) {
+	sc := Scenario{Config: DefaultConfig(), DT: DefaultDT}
+	var warnings []string
+	root, err := known(v, "scenario", &warnings,
+		"version", "freeStream", "fluid", "frame", "precision", "arithmetic", "objects", "elements", "boundaries", "channel", "bump", "rotor", "collision", "probes", "seeding", "random", "dt", "time", "particles")
+	if err != nil {
+		return sc, nil, err
+	}
+
+	version, ok := root["version"].(float64)
+	if !ok || version < 1 || version != math.Trunc(version) {
+		return sc, nil, Errorf(ErrBadArguments, "scenario.version must be a positive integer")
+	}
+	if version > ScenarioVersion {
+		warnings = append(warnings, fmt.Sprintf("scenario version %g is newer than %d; fields this version doesn't know are ignored", version, ScenarioVersion))
+	}
+
+	config := map[string]interface{}{}
+	if v, ok := root["freeStream"]; ok {
+		fs, err := known(v, "freeStream", &warnings, "speed", "direction", "profile", "shear", "swirl", "schedule")
+		if err != nil {
+			return sc, nil, err
+		}
+		if v, ok := fs["schedule"]; ok {
+			if sc.Schedule, err = DecodeSchedule(v); err != nil {
+				return sc, nil, err
+			}
+			delete(fs, "schedule")
+		}
+		config["freeStream"] = fs
+	}
+	if v, ok := root["fluid"]; ok {
+		if config["fluid"], err = known(v, "fluid", &warnings, "density"); err != nil {
+			return sc, nil, err
+		}
+	}
+	if v, ok := root["frame"]; ok {
+		config["frame"] = v
+	}
+	if v, ok := root["precision"]; ok {
+		config["precision"] = v
+	}
+	if v, ok := root["arithmetic"]; ok {
+		config["arithmetic"] = v
+	}
+	if v, ok := root["boundaries"]; ok {
+		if config["boundaries"], err = known(v, "boundaries", &warnings, "mode", "height", "clamp"); err != nil {
+			return sc, nil, err
+		}
+	}
+	if v, ok := root["channel"]; ok {
+		if config["channel"], err = known(v, "channel", &warnings, "inletHeight", "throatHeight", "start", "inletLength", "convergeLength", "throatLength", "divergeLength"); err != nil {
+			return sc, nil, err
+		}
+	}
+	if v, ok := root["bump"]; ok {
+		if config["bump"], err = known(v, "bump", &warnings, "height", "width", "x", "panels"); err != nil {
+			return sc, nil, err
+		}
+	}
+	if v, ok := root["rotor"]; ok {
+		if config["rotor"], err = known(v, "rotor", &warnings, "radius", "inducedVelocity", "thrust", "position", "ground"); err != nil {
+			return sc, nil, err
+		}
+	}
+	if v, ok := root["collision"]; ok {
+		if config["collision"], err = known(v, "collision", &warnings, "enabled", "restitution", "friction"); err != nil {
+			return sc, nil, err
+		}
+	}
+	objects, ok := root["objects"].([]interface{})
+	if !ok || len(objects) == 0 {
+		return sc, nil, Errorf(ErrBadArguments, "scenario.objects must be a non-empty array")
+	}
+	if len(objects) > 1 {
+		warnings = append(warnings, fmt.Sprintf("scenario.objects: only one object is supported, ignoring %d more", len(objects)-1))
+	}
+	object, err := known(objects[0], "objects[0]", &warnings, "type", "position", "radius", "strengths", "collisionRadius", "alpha", "edgeRadius", "chord", "semiMinor", "semiAxes", "kutta", "minorRadius", "collocation", "size", "panels", "splitter", "motion")
+	if err != nil {
+		return sc, nil, err
+	}
+	motion, hasMotion := object["motion"]
+	delete(object, "motion")
+	config["object"] = object
+	if sc.Config, err = DecodeConfig(config); err != nil {
+		return sc, nil, err
+	}
+	if hasMotion {
+		o := sc.Config.Object
+		if sc.Motion, err = DecodeMotion(motion, [3]float64{o.X, o.Y, o.Z}, &warnings); err != nil {
+			return sc, nil, err
+		}
+	}
+
+	if v, ok := root["elements"]; ok {
+		elements, err := DecodeElements(v)
+		if err != nil {
+			return sc, nil, err
+		}
+		if sc.Elements, err = NewSuperposition(elements); err != nil {
+			return sc, nil, err
+		}
+	}
+
+	if v, ok := root["probes"]; ok {
+		if sc.Probes, err = DecodeProbes(v, &warnings); err != nil {
+			return sc, nil, err
+		}
+	}
+
+	if err := numberKey(root, "scenario", "dt", &sc.DT); err != nil {
+		return sc, nil, err
+	}
+	if err := numberKey(root, "scenario", "time", &sc.Time); err != nil {
+		return sc, nil, err
+	}
+	if sc.DT < 0 || math.IsInf(sc.DT, 0) {
+		return sc, nil, Errorf(ErrBadArguments, "scenario.dt must be a non-negative finite number")
+	}
+
+	if v, ok := root["seeding"]; ok {
+		spec, err := decodeSeeding(v, &warnings)
+		if err != nil {
+			return sc, nil, err
+		}
+		sc.Seeding = &spec
+	}
+
+	if v, ok := root["random"]; ok {
+		if sc.Random, err = decodeRandom(v, &warnings); err != nil {
+			return sc, nil, err
+		}
+	}
+
+	if v, ok := root["particles"]; ok {
+		p, err := known(v, "particles", &warnings, "positions")
+		if err != nil {
+			return sc, nil, err
+		}
+		a, ok := p["positions"].([]interface{})
+		if !ok || len(a)%3 != 0 {
+			return sc, nil, Errorf(ErrBadArguments, "particles.positions must be an array of x, y, z triples")
+		}
+		sc.Positions = make([]float32, len(a))
+		for i, e := range a {
+			x, ok := e.(float64)
+			if !ok {
+				return sc, nil, Errorf(ErrBadArguments, "particles.positions[%d] must be a number", i)
+			}
+			sc.Positions[i] = float32(x)
+		}
+	}
+	for _, w := range warnings {
+		Log(LogWarn, "scenario", w)
+	}
+	return sc, warnings, nil
+}
+
+// DecodeSeedSpec decodes a seeding description
+//
+//	{kind: "random" | "grid" = "random", count, min: [x, y, z], max: [x, y, z], resolution: [nx, ny, nz], seed}
+//
+// as found in scenarios, rejecting unknown keys. Random seeding without a
+// seed draws one from the simulation's StreamSeeding stream.
+func DecodeSeedSpec(v interface{}) (SeedSpec, error) {
+	return decodeSeeding(v, nil)
+}
+
+// decodeSeeding decodes the seeding section of a scenario, dropping unknown
+// keys with a warning unless warnings is nil
+func decodeSeeding(v interface{}, warnings *[]string) (SeedSpec, error) {
+	spec := SeedSpec{Kind: SeedRandom}
+	m, err := known(v, "seeding", warnings, "kind", "count", "min", "max", "resolution", "seed")
+	if err != nil {
+		return spec, err
+	}
+	if k, ok := m["kind"]; ok {
+		if spec.Kind, ok = k.(string); !ok {
+			return spec, Errorf(ErrBadArguments, "seeding.kind must be a string")
+		}
+	}
+	var count, seed float64
+	if err := numberKey(m, "seeding", "count", &count); err != nil {
+		return spec, err
+	}
+	if err := numberKey(m, "seeding", "seed", &seed); err != nil {
+		return spec, err
+	}
+	if count != math.Trunc(count) || seed != math.Trunc(seed) {
+		return spec, Errorf(ErrBadArguments, "seeding.count and seeding.seed must be integers")
+	}
+	spec.Count, spec.Seed = int(count), int64(seed)
+	_, hasSeed := m["seed"]
+	spec.DrawSeed = spec.Kind == SeedRandom && !hasSeed
+	for _, k := range []string{"min", "max", "resolution"} {
+		if v, ok := m[k]; ok {
+			p, err := vector(v, "seeding."+k)
+			if err != nil {
+				return spec, err
+			}
+			switch k {
+			case "min":
+				spec.Grid.Min = p
+			case "max":
+				spec.Grid.Max = p
+			default:
+				spec.Grid.N = [3]int{int(p[0]), int(p[1]), int(p[2])}
+			}
+		}
+	}
+	return spec, nil
+}
+
+// known is section for lenient decoding: unknown keys are dropped with a
+// warning instead of failing. With nil warnings it is as strict as section.
+func known(v interface{}, path string, warnings *[]string, allowed ...string) (map[string]interface{}, error) {
+	if warnings == nil {
+		return section(v, path, allowed...)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, Errorf(ErrBadArguments, "%s must be an object", path)
+	}
+	out := make(map[string]interface{}, len(m))
+	var unknown []string
+	for k, e := range m {
+		found := false
+		for _, a := range allowed {
+			if k == a {
+				found = true
+				break
+			}
+		}
+		if found {
+			out[k] = e
+		} else {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		*warnings = append(*warnings, fmt.Sprintf("%s: ignoring unknown field %q", path, k))
+	}
+	return out, nil
+}
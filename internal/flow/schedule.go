@@ -0,0 +1,112 @@
+package flow
+
+import (
+	"math"
+	"sort"
+)
+
+// MaxSchedulePoints bounds the size of a Schedule
+const MaxSchedulePoints = 4096
+
+// SchedulePoint is one entry of a Schedule: speed U at time T
+type SchedulePoint struct {
+	T, U float64
+}
+
+// Schedule is a free stream speed that follows the simulation clock,
+// interpolated linearly between its points, which are sorted by time.
+// Before the first point the first speed holds, after the last the last
+// one. The free stream direction is unaffected.
+type Schedule struct {
+	Points []SchedulePoint
+}
+
+// Ramp returns the schedule rising linearly from 0 to speed over duration
+func Ramp(speed, duration float64) (*Schedule, error) {
+	if !(duration > 0) || math.IsInf(duration, 0) {
+		return nil, Errorf(ErrBadArguments, "ramp duration must be a positive finite number, got %g", duration)
+	}
+	sc := &Schedule{Points: []SchedulePoint{{0, 0}, {duration, speed}}}
+	return sc, sc.Validate()
+}
+
+// Validate checks that the points are finite and in increasing time
+func (sc *Schedule) Validate() error {
+	n := len(sc.Points)
+	if n == 0 || n > MaxSchedulePoints {
+		return Errorf(ErrBadArguments, "a schedule needs 1 to %d points, got %d", MaxSchedulePoints, n)
+	}
+	for i, p := range sc.Points {
+		if math.IsNaN(p.T) || math.IsInf(p.T, 0) || math.IsNaN(p.U) || math.IsInf(p.U, 0) {
+			return Errorf(ErrBadArguments, "schedule point %d must be finite, got (%g, %g)", i, p.T, p.U)
+		}
+		if i > 0 && !(p.T > sc.Points[i-1].T) {
+			return Errorf(ErrBadArguments, "schedule times must increase, got %g after %g", p.T, sc.Points[i-1].T)
+		}
+	}
+	return nil
+}
+
+// At returns the speed at time t
+func (sc *Schedule) At(t float64) float64 {
+	p := sc.Points
+	i := sort.Search(len(p), func(i int) bool { return p[i].T > t })
+	switch {
+	case i == 0:
+		return p[0].U
+	case i == len(p):
+		return p[len(p)-1].U
+	}
+	a, b := p[i-1], p[i]
+	return a.U + (b.U-a.U)*(t-a.T)/(b.T-a.T)
+}
+
+// Encode returns sc in generic form, an array of [t, U] pairs
+func (sc *Schedule) Encode() []interface{} {
+	out := make([]interface{}, len(sc.Points))
+	for i, p := range sc.Points {
+		out[i] = []interface{}{p.T, p.U}
+	}
+	return out
+}
+
+// DecodeSchedule decodes a schedule from generic values (see DecodeConfig):
+// either an array of [t, U] pairs or {ramp: {speed, duration}}
+func DecodeSchedule(v interface{}) (*Schedule, error) {
+	if m, ok := v.(map[string]interface{}); ok {
+		root, err := section(m, "schedule", "ramp")
+		if err != nil {
+			return nil, err
+		}
+		r, err := section(root["ramp"], "schedule.ramp", "speed", "duration")
+		if err != nil {
+			return nil, err
+		}
+		var speed, duration float64
+		if err := numberKey(r, "schedule.ramp", "speed", &speed); err != nil {
+			return nil, err
+		}
+		if err := numberKey(r, "schedule.ramp", "duration", &duration); err != nil {
+			return nil, err
+		}
+		return Ramp(speed, duration)
+	}
+	a, ok := v.([]interface{})
+	if !ok {
+		return nil, Errorf(ErrBadArguments, "schedule must be an array of [t, U] pairs or {ramp: {speed, duration}}")
+	}
+	sc := &Schedule{Points: make([]SchedulePoint, len(a))}
+	for i, e := range a {
+		pair, ok := e.([]interface{})
+		if !ok || len(pair) != 2 {
+			return nil, Errorf(ErrBadArguments, "schedule[%d] must be a [t, U] pair", i)
+		}
+		t, okT := pair[0].(float64)
+		u, okU := pair[1].(float64)
+		if !okT || !okU {
+			return nil, Errorf(ErrBadArguments, "schedule[%d] must be a pair of numbers", i)
+		}
+		sc.Points[i] = SchedulePoint{t, u}
+	}
+	return sc, sc.Validate()
+}
@@ -0,0 +1,50 @@
+package flow
+
+import "math/rand"
+
+// Seeding kinds
+const (
+	SeedRandom = "random" // Uniformly random inside the box
+	SeedGrid   = "grid"   // Regular lattice over the box
+)
+
+// SeedSpec describes how to place the initial particles
+type SeedSpec struct {
+	Kind  string
+	Count int   // Number of particles (random seeding)
+	Grid  Grid  // Box for both kinds; N is only used by grid seeding
+	Seed  int64 // RNG seed (random seeding)
+
+	// DrawSeed makes Simulation.Seed draw Seed from its StreamSeeding
+	// stream instead. Decoding sets it when the seed is left out.
+	DrawSeed bool
+}
+
+// Positions generates the seeded particle positions
+func (s *SeedSpec) Positions() ([]float32, error) {
+	switch s.Kind {
+	case SeedGrid:
+		if err := s.Grid.Validate(); err != nil {
+			return nil, err
+		}
+		return s.Grid.Positions(), nil
+
+	case SeedRandom:
+		if err := CheckCount(s.Count); err != nil {
+			return nil, err
+		}
+		box := s.Grid
+		box.N = [3]int{1, 1, 1}
+		if err := box.Validate(); err != nil {
+			return nil, err
+		}
+		rng := rand.New(rand.NewSource(s.Seed))
+		out := make([]float32, s.Count*3)
+		for i := range out {
+			a := i % 3
+			out[i] = float32(box.Min[a] + rng.Float64()*(box.Max[a]-box.Min[a]))
+		}
+		return out, nil
+	}
+	return nil, Errorf(ErrBadArguments, "unknown seeding kind %q", s.Kind)
+}
@@ -0,0 +1,178 @@
+package flow
+
+import "math"
+
+// Parameters of VelocitySensitivity
+const (
+	ParamRadius     = "radius"     // Object.Radius
+	ParamFreeStream = "freeStream" // FreeStream
+)
+
+// ParamDims returns the dimension of param and of the derivative of the
+// velocity with respect to it
+func ParamDims(param string) (value, derivative Dim) {
+	if param == ParamRadius {
+		return DimLength, DimRate
+	}
+	return DimVelocity, DimNone
+}
+
+// sensitivitySteps are the finite difference steps VelocitySensitivity
+// tries, relative to the parameter
+var sensitivitySteps = []float64{1e-2, 1e-3, 1e-4, 1e-5, 1e-6, 1e-7}
+
+// Sensitivity is the derivative of the velocity with respect to a flow
+// parameter at a set of points, three components per point. Analytic
+// tells whether it is exact; otherwise Step is the central difference
+// step used, in the parameter's SI unit, and Error the change from the
+// next smaller step relative to the largest derivative, the estimate of
+// its error the step was chosen to minimize.
+type Sensitivity struct {
+	Values   []float32
+	Analytic bool
+	Step     float64
+	Error    float64
+}
+
+// sensitivityAnalytic reports whether the kernel k of f is a sphere or
+// cylinder solution alone, whose derivatives VelocitySensitivity takes in
+// closed form: no strengths, splitter, elements, image or overlaid flow,
+// and a still object
+func (f *Flow) sensitivityAnalytic(k *kernel) bool {
+	return (k.obj.Type == Sphere || k.obj.Type == Cylinder) && k.obj.Radius > 0 && f.FreeStream != 0 &&
+		k.elements == nil && !k.strengths && !k.split && k.image == 0 && !k.channel.enabled() && !k.bump.enabled() &&
+		!k.rotor.enabled() && k.profile.Uniform() && k.swirl.Number == 0 && f.Motion == [3]float64{}
+}
+
+// derivative returns the closed-form derivative of the velocity at (px,
+// py, pz) with respect to param, for a kernel that sensitivityAnalytic
+// accepts. The disturbance of a sphere goes as R³ and a cylinder's as R²,
+// so its radius derivative is 3 or 2 times the disturbance over R, and
+// the velocity is U times a field of the position, the object velocity of
+// the lab frame included, inside the object too, so its free stream
+// derivative is v/U. The cylinder's vz, z·ρ·(U² - |v|²)/200, scales as
+// U² instead, and takes the radius derivative of |v|².
+func (k *kernel) derivative(param string, u, px, py, pz float64) (dx, dy, dz float64) {
+	if k.far(px, py, pz) {
+		if param == ParamRadius {
+			return 0, 0, 0
+		}
+		return k.free[0] / u, k.free[1] / u, k.free[2] / u
+	}
+	inside := k.obj.Contains(px, py, pz)
+	if inside && param == ParamRadius {
+		return 0, 0, 0
+	}
+	var vx, vy, vz, lz float64
+	if !inside {
+		vx, vy, vz = k.direct(px, py, pz)
+		if k.obj.Type == Cylinder {
+			lz = vz - k.axial
+		}
+	}
+	if param == ParamFreeStream {
+		vx, vy, vz = k.transform(vx, vy, vz)
+		return vx / u, vy / u, (vz + lz) / u
+	}
+	r := k.obj.Radius
+	if k.obj.Type == Sphere {
+		return 3 * (vx - k.free[0]) / r, 3 * (vy - k.free[1]) / r, 3 * (vz - k.free[2]) / r
+	}
+	dx, dy = 2*(vx-k.free[0])/r, 2*(vy-k.free[1])/r
+	return dx, dy, -(pz - k.obj.Z) * k.rho * (vx*dx + vy*dy) * 0.01
+}
+
+// VelocitySensitivity returns the derivative of the velocity at count
+// positions with respect to param, ParamRadius or ParamFreeStream, in
+// closed form for a sphere or cylinder alone in a uniform free stream and
+// by central differences otherwise. The step of the differences is chosen
+// among 1e-2 to 1e-7 times the parameter as the one whose derivative
+// changes least at the next smaller step, the truncation error shrinking
+// with the step until rounding takes over. A point within a step of the
+// surface, which moves with the radius, gets a difference straddling it.
+func VelocitySensitivity[T Float](positions []T, count int, f Flow, param string) (Sensitivity, error) {
+	var s Sensitivity
+	if param != ParamRadius && param != ParamFreeStream {
+		return s, Errorf(ErrBadArguments, "unknown sensitivity parameter %q, want %q or %q", param, ParamRadius, ParamFreeStream)
+	}
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return s, err
+	}
+	if err := f.Validate(); err != nil {
+		return s, err
+	}
+	if param == ParamRadius && f.Object.Radius == 0 {
+		return s, Errorf(ErrBadArguments, "there is no object radius to vary")
+	}
+	s.Values = make([]float32, count*3)
+	k := f.kernel()
+	if f.sensitivityAnalytic(&k) {
+		s.Analytic = true
+		_, err := parallel(count, func(from, to int) (int, error) {
+			for i := from; i < to; i++ {
+				j := i * 3
+				dx, dy, dz := k.derivative(param, f.FreeStream, float64(positions[j]), float64(positions[j+1]), float64(positions[j+2]))
+				s.Values[j], s.Values[j+1], s.Values[j+2] = float32(dx), float32(dy), float32(dz)
+			}
+			return 0, nil
+		})
+		return s, err
+	}
+
+	value := &f.FreeStream
+	if param == ParamRadius {
+		value = &f.Object.Radius
+	}
+	scale := math.Abs(*value)
+	if scale == 0 {
+		scale = 1
+	}
+	// difference returns the central difference with step h
+	difference := func(h float64) ([]float64, error) {
+		p, m := f, f
+		if param == ParamRadius {
+			p.Object.Radius, m.Object.Radius = f.Object.Radius+h, f.Object.Radius-h
+		} else {
+			p.FreeStream, m.FreeStream = f.FreeStream+h, f.FreeStream-h
+		}
+		a, b := make([]float64, count*3), make([]float64, count*3)
+		if err := VelocitiesInto(a, positions, count, p); err != nil {
+			return nil, err
+		}
+		if err := VelocitiesInto(b, positions, count, m); err != nil {
+			return nil, err
+		}
+		for i := range a {
+			a[i] = (a[i] - b[i]) / (2 * h)
+		}
+		return a, nil
+	}
+	prev, err := difference(sensitivitySteps[0] * scale)
+	if err != nil {
+		return s, err
+	}
+	best, bestErr := prev, math.Inf(1)
+	for i := 1; i < len(sensitivitySteps); i++ {
+		next, err := difference(sensitivitySteps[i] * scale)
+		if err != nil {
+			return s, err
+		}
+		var change, size float64
+		for j := range next {
+			change = math.Max(change, math.Abs(next[j]-prev[j]))
+			size = math.Max(size, math.Abs(prev[j]))
+		}
+		if size != 0 {
+			change /= size
+		}
+		if change < bestErr {
+			best, bestErr, s.Step = prev, change, sensitivitySteps[i-1]*scale
+		}
+		prev = next
+	}
+	s.Error = bestErr
+	for i, v := range best {
+		s.Values[i] = float32(v)
+	}
+	return s, nil
+}
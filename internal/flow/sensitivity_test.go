@@ -0,0 +1,60 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestSensitivity(t *testing.T) {
+	run(t, (*checker).checkSensitivity)
+}
+
+// checkSensitivity verifies the velocity derivatives: the closed forms of
+// a sphere and a cylinder in an oblique free stream, seen from the lab
+// frame, against central differences of the velocity, and the chosen
+// differences of a moving sphere, which has no closed form, the same way
+func (c *checker) checkSensitivity() error {
+	points := []float64{1.5, 0.3, -0.2, -2, 1, 0.5, 0.2, -1.3, 0.9, 0.1, 0.2, 1.02, 4, -3, 2}
+	n := len(points) / 3
+	worst, chosen := 0.0, 0.0
+	analytic := true
+	for _, t := range []struct {
+		object flow.ObjectType
+		motion [3]float64
+	}{{flow.Sphere, [3]float64{}}, {flow.Cylinder, [3]float64{}}, {flow.Sphere, [3]float64{0.3, 0, 0}}} {
+		f := checkFlow(t.object)
+		f.FreeStream, f.Direction, f.Frame, f.Motion = 2, [3]float64{0.6, 0.64, 0.48}, flow.FrameLab, t.motion
+		for _, param := range []string{flow.ParamRadius, flow.ParamFreeStream} {
+			s, err := flow.VelocitySensitivity(points, n, f, param)
+			if err != nil {
+				return err
+			}
+			analytic = analytic && s.Analytic == (t.motion == [3]float64{})
+			chosen = math.Max(chosen, s.Error)
+			p, m := f, f
+			h := 1e-5 * f.Object.Radius
+			if param == flow.ParamRadius {
+				p.Object.Radius, m.Object.Radius = f.Object.Radius+h, f.Object.Radius-h
+			} else {
+				h = 1e-5 * f.FreeStream
+				p.FreeStream, m.FreeStream = f.FreeStream+h, f.FreeStream-h
+			}
+			var diff, size float64
+			for i := 0; i < n; i++ {
+				x, y, z := points[i*3], points[i*3+1], points[i*3+2]
+				ax, ay, az := p.VelocityAt(x, y, z)
+				bx, by, bz := m.VelocityAt(x, y, z)
+				for k, d := range []float64{(ax - bx) / (2 * h), (ay - by) / (2 * h), (az - bz) / (2 * h)} {
+					diff = math.Max(diff, math.Abs(float64(s.Values[i*3+k])-d))
+					size = math.Max(size, math.Abs(d))
+				}
+			}
+			worst = math.Max(worst, diff/size)
+		}
+	}
+	ok := worst < derivativeTolerance && analytic && chosen < derivativeTolerance
+	c.report("velocity sensitivity", ok, "against central differences %.3g relative; closed form where expected %v; largest step error estimate %.3g", worst, analytic, chosen)
+	return nil
+}
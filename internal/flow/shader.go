@@ -0,0 +1,610 @@
+package flow
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Shader languages
+const (
+	ShaderGLSL = "glsl" // GLSL ES 3.0
+	ShaderWGSL = "wgsl"
+)
+
+// shaderKernels are the object solutions of localVelocity written as
+// straight-line assignments over the local coordinates x, y, z. The
+// constants of kernel (U, R, R2, R3, rho, pRef, circ and vortex) and pi
+// with localVelocity; TestShader compares the two.
+// with localVelocity; the check mode of cmd/fluidsim compares the two.
+var shaderKernels = map[ObjectType][]string{
+	Sphere: {
+		"r2 = x*x + y*y + z*z",
+		"r = sqrt(r2)",
+		"factor = R3 / (r2*r)",
+		"c = 1.5*factor / r2",
+		"vx = U * (1 - c*x*x + 0.5*factor)",
+		"vy = -U * c * x * y",
+		"vz = -U * c * x * z",
+		"outside = r > R",
+	},
+	Cylinder: {
+		"rxy2 = x*x + y*y",
+		"factor = R2 / rxy2",
+		"c = 2*factor / rxy2",
+		"vx = U * (1 - c*x*x + factor)",
+		"vy = -U * c * x * y",
+		"pressure = rho * (pRef - 0.5*(vx*vx + vy*vy))",
+		"vz = z*pressure*0.01",
+		"outside = sqrt(rxy2) > R",
+	},
+	Airfoil: {
+		"rxy2 = x*x + y*y",
+		"cos2 = (x*x - y*y) / rxy2",
+		"sin2 = 2*x*y / rxy2",
+		"factor = R2 / rxy2",
+		"vx = U * (1 - factor*cos2)",
+		"vy = U*(-factor*sin2) + vortex*y/rxy2",
+		"vz = 0",
+		"outside = sqrt(rxy2) > R",
+	},
+}
+
+// superposedKernels are the solutions of superposed, for objects whose
+// Strengths override the defaults, in the form of shaderKernels. mu and
+// src are the doublet and source constants of kernel.
+var superposedKernels = map[ObjectType][]string{
+	Sphere: {
+		"r2 = x*x + y*y + z*z",
+		"r = sqrt(r2)",
+		"d = mu / (r2*r)",
+		"s = src / (r2*r)",
+		"c = 3*d*x / r2",
+		"vx = U + d - c*x + s*x",
+		"vy = -c*y + s*y",
+		"vz = -c*z + s*z",
+		"outside = r > R",
+	},
+	Cylinder: {
+		"rxy2 = x*x + y*y",
+		"d = mu / rxy2",
+		"s = src / rxy2",
+		"c = 2*d / rxy2",
+		"vx = U + d - c*x*x + s*x - vortex*y/rxy2",
+		"vy = -c*x*y + s*y + vortex*x/rxy2",
+		"vz = z*rho*(pRef - 0.5*(vx*vx + vy*vy))*0.01",
+		"outside = sqrt(rxy2) > R",
+	},
+	Airfoil: {
+		"rxy2 = x*x + y*y",
+		"d = mu / rxy2",
+		"s = src / rxy2",
+		"c = 2*d / rxy2",
+		"vx = U + d - c*x*x + s*x",
+		"vy = -c*x*y + s*y + vortex*y/rxy2",
+		"vz = 0",
+		"outside = sqrt(rxy2) > R",
+	},
+}
+
+// Shader is the velocity field of a Flow as straight-line code, which can
+// be emitted as GPU source or evaluated in Go. The result is (wx, wy, wz)
+// where outside holds and zero elsewhere.
+type Shader struct {
+	Flow Flow
+
+	// Units are those of the positions taken and the velocities returned
+	// by the Source function; SI if zero. VelocityAt always works in SI.
+	Units Units
+
+	lets    []shaderLet
+	outside bool // Whether an outside assignment masks the result
+}
+
+type shaderLet struct {
+	name string
+	e    *shaderNode
+}
+
+// NewShader compiles the velocity field of f with its parameters baked
+// in. The far-field cutoff is not applied: on the GPU the full kernel is
+// cheap enough. The free stream profile and swirl are added outside the
+// object as in the kernels. Where the object moves, in the lab frame or under a
+// Motion, its velocity (sx, sy, sz) is added at the end, inside the object
+// too, which then carries the points with it.
+func NewShader(f Flow) (*Shader, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	if f.Elements != nil {
+		return nil, Errorf(ErrUnsupported, "no shader kernel for elementary flows")
+	}
+	if f.Boundary.image() != 0 {
+		return nil, Errorf(ErrUnsupported, "no shader kernel for a %s boundary", f.Boundary.Mode)
+	}
+	if f.Channel.enabled() {
+		return nil, Errorf(ErrUnsupported, "no shader kernel for a channel")
+	}
+	if f.Bump.enabled() {
+		return nil, Errorf(ErrUnsupported, "no shader kernel for a bump")
+	}
+	if f.Rotor.enabled() {
+		return nil, Errorf(ErrUnsupported, "no shader kernel for a rotor")
+	}
+	if f.Object.Splitter != 0 {
+		return nil, Errorf(ErrUnsupported, "no shader kernel for a splitter plate")
+	}
+	o, k := f.Object, f.kernel()
+	consts := map[string]float64{
+		"ox": o.X, "oy": o.Y, "oz": o.Z,
+		"R": k.body, "R2": k.r2, "R3": k.r3,
+		"U": k.u, "rho": k.rho, "pRef": k.pRef, "circ": k.circ, "vortex": k.vortex, "pi": math.Pi,
+		"mu": k.doublet, "src": k.source,
+	}
+
+	var prologue, epilogue []string
+	offsets := []string{"gx = px - ox", "gy = py - oy", "gz = pz - oz"}
+	switch k.mode {
+	case frameAligned:
+		prologue = []string{"x = px - ox", "y = py - oy", "z = pz - oz"}
+		epilogue = []string{"wx = vx", "wy = vy", "wz = vz"}
+
+	case frameCrossFlow:
+		consts["cs"], consts["sn"], consts["axial"] = k.c, k.s, k.axial
+		prologue = append(offsets, "x = cs*gx + sn*gy", "y = -sn*gx + cs*gy", "z = gz")
+		epilogue = []string{"wx = cs*vx - sn*vy", "wy = sn*vx + cs*vy", "wz = vz + axial"}
+
+	default:
+		for i, axis := range []string{"e1", "e2", "e3"} {
+			consts[axis+"x"], consts[axis+"y"], consts[axis+"z"] = k.e[i][0], k.e[i][1], k.e[i][2]
+		}
+		prologue = append(offsets,
+			"x = e1x*gx + e1y*gy + e1z*gz",
+			"y = e2x*gx + e2y*gy + e2z*gz",
+			"z = e3x*gx + e3y*gy + e3z*gz")
+		epilogue = []string{
+			"wx = vx*e1x + vy*e2x + vz*e3x",
+			"wy = vx*e1y + vy*e2y + vz*e3y",
+			"wz = vx*e1z + vy*e2z + vz*e3z",
+		}
+	}
+	if p := k.profile; !p.Uniform() {
+		consts["dx"], consts["dy"], consts["dz"] = k.dir[0], k.dir[1], k.dir[2]
+		consts["U0"], consts["Uc"], consts["zr"], consts["flr"] = k.u0, k.uc, p.Ref, p.Floor
+		du := "dU = shr*(py - oy)"
+		switch p.Type {
+		case ProfileShear:
+			consts["shr"] = p.Rate
+		case ProfilePower:
+			consts["alp"] = p.Alpha
+			du = "dU = U0*pow(max(py - flr, 0)/zr, alp) - Uc"
+		case ProfileLog:
+			consts["z0"], consts["lzr"] = p.Roughness, math.Log(p.Ref/p.Roughness)
+			du = "dU = U0*log(max(py - flr, z0)/z0)/lzr - Uc"
+		case ProfileLayered:
+			// (1 + tanh x)/2 = 1/(1 + e^(-2x)), a step if sharp
+			consts["eul"], consts["lb"] = math.E, p.Speeds[0]
+			du = "dU = U0*(lb"
+			for i, h := range p.Heights[:p.Bands-1] {
+				n := strconv.Itoa(i)
+				consts["lh"+n], consts["ls"+n], consts["lk"+n] = h, p.Speeds[i+1]-p.Speeds[i], -2/p.Thickness
+				if p.Thickness == 0 {
+					du += " + ls" + n + "*(py > lh" + n + ")"
+				} else {
+					du += " + ls" + n + "/(1 + pow(eul, lk" + n + "*(py - lh" + n + ")))"
+				}
+			}
+			du += ") - Uc"
+		}
+		prologue = append(prologue, du)
+		for i, c := range []string{" + dU*dx", " + dU*dy", " + dU*dz"} {
+			epilogue[i] += c
+		}
+	}
+	if w := k.swirl; w.Number != 0 {
+		consts["dx"], consts["dy"], consts["dz"] = k.dir[0], k.dir[1], k.dir[2]
+		consts["swR"], consts["swc"] = w.Radius, w.Number*k.su
+		rate := "swr = swc/hm"
+		if w.Type == SwirlFree {
+			consts["swc"] *= w.Radius
+			rate = "swr = swc/(hm*hm)"
+		}
+		prologue = append(prologue,
+			"ha = (px - ox)*dx + (py - oy)*dy + (pz - oz)*dz",
+			"hx = px - ox - ha*dx", "hy = py - oy - ha*dy", "hz = pz - oz - ha*dz",
+			"hm = max(sqrt(hx*hx + hy*hy + hz*hz), swR)",
+			rate)
+		for i, c := range []string{" + swr*(dy*hz - dz*hy)", " + swr*(dz*hx - dx*hz)", " + swr*(dx*hy - dy*hx)"} {
+			epilogue[i] += c
+		}
+	}
+	if k.moving {
+		consts["sx"], consts["sy"], consts["sz"] = k.shift[0], k.shift[1], k.shift[2]
+		for i, c := range []string{" + sx", " + sy", " + sz"} {
+			epilogue[i] += c
+		}
+	}
+	kernel := []string{"vx = U", "vy = 0", "vz = 0"}
+	if o.Radius != 0 {
+		kernel = shaderKernels[o.Type]
+		if k.strengths {
+			kernel = superposedKernels[o.Type]
+		}
+		if kernel == nil {
+			return nil, Errorf(ErrUnsupported, "no shader kernel for object type %v", o.Type)
+		}
+	}
+
+	sh := &Shader{Flow: f}
+	vars := map[string]bool{"px": true, "py": true, "pz": true}
+	for _, line := range append(append(prologue, kernel...), epilogue...) {
+		eq := strings.IndexByte(line, '=')
+		name := strings.TrimSpace(line[:eq])
+		p := shaderParser{src: line[eq+1:], vars: vars, consts: consts}
+		e, err := p.parse()
+		if err != nil {
+			return nil, Errorf(ErrInternal, "shader kernel %q: %v", line, err)
+		}
+		sh.lets = append(sh.lets, shaderLet{name, e})
+		vars[name] = true
+		sh.outside = sh.outside || name == "outside"
+	}
+	return sh, nil
+}
+
+// frame returns an orthonormal frame whose first axis is the unit vector d
+func frame(d [3]float64) [3][3]float64 {
+	e1 := d
+	e2 := [3]float64{-d[1], d[0], 0}
+	if math.Abs(d[2]) > 0.9 {
+		e2 = [3]float64{0, -d[2], d[1]}
+	}
+	n := math.Sqrt(e2[0]*e2[0] + e2[1]*e2[1] + e2[2]*e2[2])
+	e2 = [3]float64{e2[0] / n, e2[1] / n, e2[2] / n}
+	e3 := [3]float64{e1[1]*e2[2] - e1[2]*e2[1], e1[2]*e2[0] - e1[0]*e2[2], e1[0]*e2[1] - e1[1]*e2[0]}
+	return [3][3]float64{e1, e2, e3}
+}
+
+// VelocityAt evaluates the compiled code at (px, py, pz) in float64, as a
+// reference for what the generated source computes
+func (sh *Shader) VelocityAt(px, py, pz float64) (vx, vy, vz float64) {
+	env := map[string]float64{"px": px, "py": py, "pz": pz}
+	for _, l := range sh.lets {
+		env[l.name] = l.e.eval(env)
+	}
+	if sh.outside && env["outside"] == 0 {
+		k := sh.Flow.kernel()
+		return k.transform(0, 0, 0)
+	}
+	return env["wx"], env["wy"], env["wz"]
+}
+
+// Source returns the code as a self-contained function
+// flowVelocity(p: vec3) -> vec3 in the given language. Constants are baked
+// in, so regenerate it whenever the configuration changes.
+func (sh *Shader) Source(language string) (string, error) {
+	var b strings.Builder
+	f, o := sh.Flow, sh.Flow.Object
+	d := f.Direction
+	if f.aligned() {
+		d = [3]float64{1, 0, 0}
+	}
+	fmt.Fprintf(&b, "// Potential flow velocity: %v of radius %g at (%g, %g, %g),\n", o.Type, o.Radius, o.X, o.Y, o.Z)
+	frame := ""
+	if f.Frame == FrameLab {
+		frame = ", lab frame"
+	}
+	fmt.Fprintf(&b, "// free stream %g along (%g, %g, %g), density %g%s. Generated; do not edit.\n", f.FreeStream, d[0], d[1], d[2], f.Density, frame)
+
+	// Convert the position to SI on the way in and the velocity from SI
+	// on the way out
+	num := func(x float64) string { return (&shaderNode{op: 'n', val: x}).source(language) }
+	scaleIn, scaleOut := "", ""
+	if u := sh.Units; u != (Units{}) {
+		if l := u.Factor(DimLength); l != 1 {
+			scaleIn = " * " + num(l)
+		}
+		if v := u.Factor(DimVelocity); v != 1 {
+			scaleOut = " * " + num(1/v)
+		}
+	}
+	if scaleIn != "" || scaleOut != "" {
+		fmt.Fprintf(&b, "// Takes p in %s and returns the velocity in %s; the parameters above are SI.\n", sh.Units.Length, sh.Units.Velocity)
+	}
+
+	var decl, vec, mask string
+	switch language {
+	case ShaderGLSL:
+		b.WriteString("vec3 flowVelocity(vec3 p) {\n")
+		decl, vec = "float", "vec3"
+	case ShaderWGSL:
+		b.WriteString("fn flowVelocity(p: vec3<f32>) -> vec3<f32> {\n")
+		decl, vec = "let", "vec3<f32>"
+	default:
+		return "", Errorf(ErrBadArguments, "unknown shader language %q", language)
+	}
+	if sh.outside {
+		// Inside the object the velocity is the object's own, zero unless
+		// it moves
+		inside := vec + "(0.0)"
+		if k := f.kernel(); k.moving {
+			v := make([]string, 3)
+			for i, x := range k.shift {
+				v[i] = (&shaderNode{op: 'n', val: x + 0}).source(language)
+			}
+			inside = vec + "(" + strings.Join(v, ", ") + ")"
+		}
+		if language == ShaderGLSL {
+			mask = "    return (outside ? vec3(wx, wy, wz) : " + inside + ")" + scaleOut + ";\n"
+		} else {
+			mask = "    return select(" + inside + ", vec3<f32>(wx, wy, wz), outside)" + scaleOut + ";\n"
+		}
+	}
+	for _, a := range []string{"x", "y", "z"} {
+		fmt.Fprintf(&b, "    %s p%s = p.%s%s;\n", decl, a, a, scaleIn)
+	}
+	for _, l := range sh.lets {
+		t := decl
+		if l.name == "outside" && language == ShaderGLSL {
+			t = "bool"
+		}
+		fmt.Fprintf(&b, "    %s %s = %s;\n", t, l.name, l.e.source(language))
+	}
+	if mask == "" {
+		mask = "    return " + vec + "(wx, wy, wz)" + scaleOut + ";\n"
+	}
+	b.WriteString(mask)
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// shaderNode is an expression of the kernel language: numbers, variables,
+// + - * / >, unary minus and calls of sqrt, sin, cos, atan2, pow, log and
+// max
+type shaderNode struct {
+	op   byte // 'n' number, 'v' variable, 'f' call, 'u' negation, or the binary operator
+	val  float64
+	name string
+	args []*shaderNode
+}
+
+// shaderFuncs maps the kernel functions to their arity
+var shaderFuncs = map[string]int{"sqrt": 1, "sin": 1, "cos": 1, "atan2": 2, "pow": 2, "log": 1, "max": 2}
+
+func (n *shaderNode) eval(env map[string]float64) float64 {
+	switch n.op {
+	case 'n':
+		return n.val
+	case 'v':
+		return env[n.name]
+	case 'u':
+		return -n.args[0].eval(env)
+	case 'f':
+		a := n.args[0].eval(env)
+		switch n.name {
+		case "sqrt":
+			return math.Sqrt(a)
+		case "sin":
+			return math.Sin(a)
+		case "cos":
+			return math.Cos(a)
+		case "log":
+			return math.Log(a)
+		case "pow":
+			return math.Pow(a, n.args[1].eval(env))
+		case "max":
+			return math.Max(a, n.args[1].eval(env))
+		}
+		return math.Atan2(a, n.args[1].eval(env))
+	}
+	a, b := n.args[0].eval(env), n.args[1].eval(env)
+	switch n.op {
+	case '+':
+		return a + b
+	case '-':
+		return a - b
+	case '*':
+		return a * b
+	case '/':
+		return a / b
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// precedence of the binary operators; everything else binds tighter
+func (n *shaderNode) precedence() int {
+	switch n.op {
+	case '>':
+		return 1
+	case '+', '-':
+		return 2
+	case '*', '/':
+		return 3
+	}
+	return 4
+}
+
+// source prints n with the parentheses its structure needs
+func (n *shaderNode) source(language string) string {
+	switch n.op {
+	case 'n':
+		s := strconv.FormatFloat(n.val, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		if n.val < 0 {
+			s = "(" + s + ")"
+		}
+		return s
+	case 'v':
+		return n.name
+	case 'u':
+		a := n.args[0].source(language)
+		if n.args[0].precedence() < 4 {
+			a = "(" + a + ")"
+		}
+		return "-" + a
+	case 'f':
+		name := n.name
+		if name == "atan2" && language == ShaderGLSL {
+			name = "atan"
+		}
+		args := make([]string, len(n.args))
+		for i, a := range n.args {
+			args[i] = a.source(language)
+		}
+		return name + "(" + strings.Join(args, ", ") + ")"
+	}
+	a, b := n.args[0].source(language), n.args[1].source(language)
+	if n.args[0].precedence() < n.precedence() {
+		a = "(" + a + ")"
+	}
+	// Keep the evaluation order of the tree: floating point operations
+	// don't associate
+	if n.args[1].precedence() <= n.precedence() {
+		b = "(" + b + ")"
+	}
+	return a + " " + string(n.op) + " " + b
+}
+
+// shaderParser parses a kernel expression, replacing constants by their
+// values. Names must be constants or assigned earlier.
+type shaderParser struct {
+	src    string
+	pos    int
+	vars   map[string]bool
+	consts map[string]float64
+}
+
+func (p *shaderParser) parse() (*shaderNode, error) {
+	n, err := p.comparison()
+	if err == nil && p.peek() != 0 {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	return n, err
+}
+
+// peek skips spaces and returns the next byte, or 0 at the end
+func (p *shaderParser) peek() byte {
+	for p.pos < len(p.src) && p.src[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos == len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *shaderParser) comparison() (*shaderNode, error) {
+	a, err := p.sum()
+	if err != nil || p.peek() != '>' {
+		return a, err
+	}
+	p.pos++
+	b, err := p.sum()
+	return &shaderNode{op: '>', args: []*shaderNode{a, b}}, err
+}
+
+func (p *shaderParser) sum() (*shaderNode, error) {
+	a, err := p.product()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := p.src[p.pos]
+		p.pos++
+		var b *shaderNode
+		b, err = p.product()
+		a = &shaderNode{op: op, args: []*shaderNode{a, b}}
+	}
+	return a, err
+}
+
+func (p *shaderParser) product() (*shaderNode, error) {
+	a, err := p.unary()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := p.src[p.pos]
+		p.pos++
+		var b *shaderNode
+		b, err = p.unary()
+		a = &shaderNode{op: op, args: []*shaderNode{a, b}}
+	}
+	return a, err
+}
+
+func (p *shaderParser) unary() (*shaderNode, error) {
+	if p.peek() == '-' {
+		p.pos++
+		a, err := p.unary()
+		return &shaderNode{op: 'u', args: []*shaderNode{a}}, err
+	}
+	return p.primary()
+}
+
+func (p *shaderParser) primary() (*shaderNode, error) {
+	c := p.peek()
+	start := p.pos
+	switch {
+	case c == '(':
+		p.pos++
+		n, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return n, nil
+
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		return &shaderNode{op: 'n', val: v}, err
+
+	case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+		for p.pos < len(p.src) && (p.src[p.pos] >= 'a' && p.src[p.pos] <= 'z' || p.src[p.pos] >= 'A' && p.src[p.pos] <= 'Z' || p.src[p.pos] >= '0' && p.src[p.pos] <= '9') {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if arity, ok := shaderFuncs[name]; ok {
+			return p.call(name, arity)
+		}
+		if v, ok := p.consts[name]; ok {
+			return &shaderNode{op: 'n', val: v}, nil
+		}
+		if !p.vars[name] {
+			return nil, fmt.Errorf("undefined name %q", name)
+		}
+		return &shaderNode{op: 'v', name: name}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", c)
+}
+
+// call parses the parenthesized arguments of a function call
+func (p *shaderParser) call(name string, arity int) (*shaderNode, error) {
+	n := &shaderNode{op: 'f', name: name}
+	for i := 0; i < arity; i++ {
+		sep := byte(',')
+		if i == 0 {
+			sep = '('
+		}
+		if p.peek() != sep {
+			return nil, fmt.Errorf("%s: expected %q", name, sep)
+		}
+		p.pos++
+		a, err := p.comparison()
+		if err != nil {
+			return nil, err
+		}
+		n.args = append(n.args, a)
+	}
+	if p.peek() != ')' {
+		return nil, fmt.Errorf("%s takes %d arguments", name, arity)
+	}
+	p.pos++
+	return n, nil
+}
@@ -0,0 +1,61 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestShader(t *testing.T) {
+	forTypes(t, (*checker).checkShader, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+// checkShader compares the generated shader code, evaluated in Go, with
+// the native kernel at random points, for the free stream along +x and
+// along an oblique direction, in both frames, the lab frame cases in shear
+// flow and in a power law boundary layer with a free vortex swirl, and in
+// blended and sharp layered streams
+func (c *checker) checkShader(t flow.ObjectType) error {
+	seed := flow.SeedSpec{Kind: flow.SeedRandom, Count: 2000, Seed: 129,
+		Grid: flow.Grid{Min: [3]float64{-3, -3, -3}, Max: [3]float64{3, 3, 3}}}
+	positions, _ := seed.Positions()
+	worst := 0.0
+	for i, d := range [][3]float64{{1, 0, 0}, {0.48, 0.6, 0.64}, {1, 0, 0}, {0.48, 0.6, 0.64}, {1, 0, 0}, {0.48, 0.6, 0.64}} {
+		f := checkFlow(t)
+		f.Direction = d
+		if i >= 4 {
+			f.Profile = flow.Profile{Type: flow.ProfileLayered, Bands: 3, Speeds: [flow.MaxBands]float64{0.5, 1, 1.75}, Heights: [flow.MaxBands - 1]float64{-1, 0.7}}
+			if i == 4 {
+				f.Profile.Thickness = 0.3
+			}
+		} else if i >= 2 {
+			f.Frame = flow.FrameLab
+			f.Profile = flow.Profile{Type: flow.ProfileShear, Rate: 0.3, Ref: -0.5}
+			if i == 3 {
+				f.Profile = flow.Profile{Type: flow.ProfilePower, Ref: 2, Alpha: 0.3, Floor: -2}
+				f.Swirl = flow.Swirl{Type: flow.SwirlFree, Number: 0.4, Radius: 1.5}
+			} else {
+				f.Swirl = flow.Swirl{Type: flow.SwirlSolid, Number: -0.3, Radius: 2}
+			}
+		}
+		f.Object.X, f.Object.Y, f.Object.Z = 0.1, -0.2, 0.05
+		sh, err := flow.NewShader(f)
+		if err != nil {
+			return err
+		}
+		for _, language := range []string{flow.ShaderGLSL, flow.ShaderWGSL} {
+			if _, err := sh.Source(language); err != nil {
+				return err
+			}
+		}
+		for i := 0; i < seed.Count; i++ {
+			x, y, z := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+			ux, uy, uz := f.VelocityAt(x, y, z)
+			sx, sy, sz := sh.VelocityAt(x, y, z)
+			worst = math.Max(worst, math.Max(math.Abs(sx-ux), math.Max(math.Abs(sy-uy), math.Abs(sz-uz))))
+		}
+	}
+	c.report(t.String()+" shader", worst < shaderTolerance, "max |v_shader - v| = %.3g", worst)
+	return nil
+}
@@ -0,0 +1,826 @@
+package flow
+
+import "math"
+
+// Simulation is the persistent state behind the stateful API: the
+// configuration, the simulation clock and the particle buffers, which are
+// stored as float32 like the arrays they are exchanged with.
+type Simulation struct {
+	Config Config
+	Time   float64
+	DT     float64 // Time step used when Step is not given one
+
+	// Seeding is how the particles were generated, or nil if they were
+	// loaded from an array
+	Seeding *SeedSpec
+
+	// Random is the source of every stochastic feature, saved and restored
+	// with scenarios and snapshots
+	Random Random
+
+	// Positions holds count*3 values. With Config.Precision
+	// PrecisionDouble, steps advance a float64 copy of them and round it
+	// into Positions, so increments too small to move a float32 far from
+	// the origin still add up.
+	Positions  []float32
+	Velocities []float32 // count*3 values, evaluated at the last step
+	Pressures  []float32 // count values, evaluated at the last step
+
+	Stats Stats
+
+	// Recorder, if set, captures each step once velocities and pressures
+	// are evaluated, before the particles move
+	Recorder *Recorder
+
+	// Forces, if set, samples the forces on the object at the steps it is
+	// due, like Recorder; a runtime setting
+	Forces *ForceHistory
+
+	// ObjectVersion is incremented whenever the object changes, so
+	// renderers can cheaply tell when to rebuild its mesh
+	ObjectVersion int
+
+	// Cutoff is the far-field cutoff used by Step (see Flow.Cutoff). Like
+	// the recorder it is a runtime setting, not part of scenarios or
+	// snapshots.
+	Cutoff float64
+
+	// Cursor is the particle UpdatePartial refreshes next. It is reset
+	// when the particles are replaced and, like Cutoff, not persisted.
+	Cursor int
+
+	// LOD, if set, is the temporal level of detail of Step; nil, the
+	// default, re-evaluates every particle on every step. A runtime
+	// setting like Cutoff.
+	LOD *LOD
+	lod lodCache
+
+	// Paused makes Step, StepMany and UpdatePartial no-ops, freezing the
+	// clock and everything driven by it; field queries are unaffected.
+	// TimeScale multiplies the dt of every step, 1 by default. Both are
+	// runtime settings.
+	Paused    bool
+	TimeScale float64
+
+	// Events, if set, collects the events of every step for the caller to
+	// drain; another runtime setting
+	Events *EventLog
+
+	// Motion, if set, is the prescribed trajectory the object follows,
+	// advanced by every step. Unlike the runtime settings it is part of
+	// scenarios.
+	Motion *Motion
+
+	// Target, if set, is the position the object is dragged toward, in
+	// place of a Motion; a runtime setting
+	Target *ObjectTarget
+
+	// Schedule, if set, drives the free stream speed from the clock in
+	// place of Config.FreeStream.Speed; part of scenarios like Motion
+	Schedule *Schedule
+
+	// Elements, if set, replaces the object solution by a superposition
+	// of elementary flows (see Flow.Elements); part of scenarios like
+	// Motion
+	Elements *Superposition
+
+	// Density, if set, merges and splits the particles to even out their
+	// density every few steps; a runtime setting
+	Density *DensityControl
+	remap   *Remap // Density control passes since the last TakeRemap
+
+	// Injection spawns dye particles every step from its injectors, whose
+	// IDs Sources holds for each particle, 0 for particles from none. A
+	// runtime setting; Sources follows the particles through density
+	// control and is cleared when they are replaced.
+	Injection Injection
+	Sources   []uint8
+
+	// Probes are the flow meters sampled every step; part of scenarios
+	// like Motion, without their histories
+	Probes Probes
+
+	// Turbulence, if set, adds synthetic turbulence to the advection of
+	// the particles; a runtime setting
+	Turbulence *Turbulence
+
+	// Rotating, if set, views the flow from a rotating frame; a runtime
+	// setting
+	Rotating *RotatingFrame
+
+	// Symmetry, if set, is a plane the flow is symmetric about, Step
+	// evaluating the particles on one side of it only; a runtime setting
+	Symmetry *Symmetry
+	mirrors  mirrorCache
+
+	from    []float32 // Positions before the step, for the splitter plate and collisions
+	calm    []float32 // Velocities reported, restored after advection with the Turbulence and RotatingFrame
+	precise []float64 // Positions in double precision, empty until the next step when replaced
+}
+
+// Stats accumulates counters over the life of a simulation
+type Stats struct {
+	Steps     int // Step calls that advanced the clock
+	Clamped   int // Particles moved back onto a clamping boundary plane
+	FarField  int // Particle evaluations beyond the far-field cutoff
+	LODReused int // Particle velocities kept from an earlier step by LOD
+	Mirrored  int // Particle velocities filled in by reflection under a Symmetry
+	Merged    int // Particles merged away by density control
+	Split     int // Particles split off by density control
+}
+
+// DefaultDT is the default time step of a Simulation
+const DefaultDT = 0.01
+
+// NewSimulation returns a simulation of c without particles
+func NewSimulation(c Config) *Simulation {
+	return &Simulation{Config: c, DT: DefaultDT, TimeScale: 1}
+}
+
+// Inherit copies into s the runtime settings of old, which s replaces: the
+// recorder, force history, cutoff, LOD schedule, pause state, time scale,
+// event log, density control, injection, turbulence, rotating frame and
+// symmetry plane, none of which scenarios or snapshots hold. The object
+// version moves past old's so renderers rebuild the mesh.
+func (s *Simulation) Inherit(old *Simulation) {
+	s.Recorder, s.Forces, s.Cutoff, s.LOD = old.Recorder, old.Forces, old.Cutoff, old.LOD
+	s.Paused, s.TimeScale, s.Events = old.Paused, old.TimeScale, old.Events
+	s.Density, s.Injection, s.Turbulence, s.Rotating = old.Density, old.Injection, old.Turbulence, old.Rotating
+	s.Symmetry = old.Symmetry
+	s.ObjectVersion = old.ObjectVersion + 1
+}
+
+// SetTimeScale sets the factor applied to the dt of every step
+func (s *Simulation) SetTimeScale(scale float64) error {
+	if !(scale >= 0) || math.IsInf(scale, 0) {
+		return Errorf(ErrBadArguments, "time scale must be a non-negative finite number, got %g", scale)
+	}
+	s.TimeScale = scale
+	return nil
+}
+
+// Reset rewinds s: the clock and the statistics restart at zero, the
+// random generator restarts from its seed and the particles are
+// regenerated from Seeding, or kept if they were loaded from an array,
+// with their velocities and pressures cleared. A Motion rewinds, taking the
+// object back to its start. Unless keepConfig is set the configuration and
+// time step return to their defaults as well, and the motion, free
+// stream schedule, elements and probes are removed; a kept schedule
+// restarts with the clock, kept probes with empty histories. A drag Target
+// is dropped and a Turbulence field goes back to its start; the other
+// runtime settings, including Paused, are kept.
+func (s *Simulation) Reset(keepConfig bool) error {
+	if !keepConfig {
+		s.SetConfig(DefaultConfig())
+		s.DT, s.Schedule, s.Elements = DefaultDT, nil, nil
+		s.Probes = Probes{}
+	}
+	s.Probes.Clear()
+	s.Time, s.Stats, s.Target = 0, Stats{}, nil
+	if t := s.Turbulence; t != nil {
+		t.Offset = [3]float64{}
+	}
+	if m := s.Motion; m != nil {
+		if keepConfig {
+			s.SetObjectPosition(m.Start[0], m.Start[1], m.Start[2])
+			m.Time = 0
+		} else {
+			s.Motion = nil
+		}
+	}
+	if err := s.Random.SetSeed(s.Random.Seed()); err != nil {
+		return err
+	}
+	if s.Seeding != nil {
+		return s.Seed(*s.Seeding)
+	}
+	return s.SetParticles(s.Positions, s.Count())
+}
+
+// Count returns the number of particles
+func (s *Simulation) Count() int {
+	return len(s.Positions) / 3
+}
+
+// SetParticles replaces the particles with the first count*3 values of
+// positions, resetting their velocities and pressures to zero
+func (s *Simulation) SetParticles(positions []float32, count int) error {
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return err
+	}
+	s.Positions = append(s.Positions[:0], positions[:count*3]...)
+	s.precise, s.remap = s.precise[:0], nil
+	s.Seeding = nil
+	s.Cursor = 0
+	s.lod.invalidate()
+	s.mirrors.paired = false
+	s.Velocities = resize(s.Velocities, count*3)
+	s.Pressures = resize(s.Pressures, count)
+	s.Sources = append(s.Sources[:0], make([]uint8, count)...)
+	return nil
+}
+
+// Seed replaces the particles with those generated by spec. With
+// spec.DrawSeed set, the seed is drawn from the StreamSeeding stream and
+// kept in Seeding, so the scenario reproduces the same particles.
+func (s *Simulation) Seed(spec SeedSpec) error {
+	var stream *Stream
+	var saved Stream
+	if spec.DrawSeed {
+		stream = s.Random.Stream(StreamSeeding)
+		saved = *stream
+		spec.Seed, spec.DrawSeed = int64(stream.Uint32()), false
+	}
+	p, err := spec.Positions()
+	if err != nil {
+		if stream != nil {
+			*stream = saved
+		}
+		return err
+	}
+	if err := s.SetParticles(p, len(p)/3); err != nil {
+		return err
+	}
+	s.Seeding = &spec
+	return nil
+}
+
+// resize returns a zeroed slice of length n, reusing buf if it's big enough
+func resize(buf []float32, n int) []float32 {
+	if cap(buf) < n {
+		return make([]float32, n)
+	}
+	buf = buf[:n]
+	for i := range buf {
+		buf[i] = 0
+	}
+	return buf
+}
+
+// Step evaluates velocities and pressures at the current positions, then
+// advances the positions by one explicit Euler step of length
+// dt*TimeScale and the clock by as much. Time is the one clock of the
+// simulation: it only moves here and in UpdatePartial, and only forwards.
+// A dt of zero only updates velocities and pressures; while Paused nothing
+// happens. With LOD set, particles not due under the schedule keep their
+// velocities; with a Symmetry, the mirror particles get the mirror images
+// of their partners' velocities.
+func (s *Simulation) Step(dt float64) error {
+	if dt < 0 || math.IsNaN(dt) || math.IsInf(dt, 0) {
+		return Errorf(ErrBadArguments, "dt must be a non-negative finite number, got %g", dt)
+	}
+	if s.Paused {
+		return nil
+	}
+	dt *= s.TimeScale
+	f := s.Flow()
+	f.Cutoff = s.Cutoff
+	count := s.Count()
+	var far, reused, mirrored int
+	var err error
+	if s.Symmetry != nil {
+		far, mirrored, err = s.mirrorVelocities(f, count)
+	} else if s.LOD != nil && !s.Rotating.active() {
+		far, reused, err = s.lod.velocities(*s.LOD, f, s.Velocities, s.Positions, count)
+	} else {
+		far, err = VelocitiesIntoCounted(s.Velocities, s.Positions, count, f)
+	}
+	if err != nil {
+		return err
+	}
+	s.Stats.FarField += far
+	s.Stats.LODReused += reused
+	s.Stats.Mirrored += mirrored
+	LogValue(LogDebug, "step", "far-field evaluations", float64(far))
+	if s.LOD != nil {
+		LogValue(LogDebug, "lod", "velocities reused", float64(reused))
+	}
+	if err := PressuresFor(s.Pressures, s.Velocities, count, &f, Mask{}); err != nil {
+		return err
+	}
+	if err := AddSwirlPressures(s.Pressures, s.Positions, count, &f, Mask{}); err != nil {
+		return err
+	}
+	s.rotate(0, count, f.Density)
+	if s.Recorder != nil {
+		s.Recorder.Capture(s)
+	}
+	if s.Forces != nil {
+		s.Forces.Capture(s)
+	}
+	s.Probes.Capture(s)
+	steps := s.Stats.Steps
+	s.advance(dt, count)
+	return s.settle(steps, dt)
+}
+
+// settle runs the particle passes that follow a step of dt that found the
+// step counter at steps: density control, then injection
+func (s *Simulation) settle(steps int, dt float64) error {
+	if err := s.controlDensity(steps); err != nil {
+		return err
+	}
+	return s.inject(dt)
+}
+
+// advance moves the particles, stirred by Turbulence and relative to a
+// RotatingFrame, and the clock by dt, and the object in the lab frame,
+// under a Motion or toward its Target, recording the particles entering
+// the object if Events is set and bouncing them off it if
+// Config.Collision is enabled or the object is dragged. The mirrors of a
+// Symmetry end up at the mirror images of their partners.
+func (s *Simulation) advance(dt float64, count int) {
+	if dt <= 0 {
+		return
+	}
+	if s.Events != nil {
+		s.Events.before(&s.Config.Object, s.Positions, count)
+	}
+	// The way the object is dragged this step, which the particles it
+	// overruns are pushed aside from
+	var drag [3]float64
+	if t := s.Target; t != nil {
+		o := &s.Config.Object
+		drag = t.displacement([3]float64{o.X, o.Y, o.Z}, dt)
+	}
+	dragged := drag != [3]float64{}
+	swept := s.Config.Collision.Enabled || s.Config.Object.Splitter != 0 || dragged
+	if swept {
+		s.from = append(s.from[:0], s.Positions[:count*3]...)
+	}
+	f := s.Flow()
+	stirred := s.stir(&f, count)
+	turning := s.Rotating.active() && s.Rotating.Absolute
+	if turning {
+		if !stirred {
+			s.calm = append(s.calm[:0], s.Velocities[:count*3]...)
+		}
+		s.turn(count)
+	}
+	double := s.Config.Precision == PrecisionDouble
+	if double {
+		s.advectPrecise(dt, count)
+	} else {
+		Advect(s.Positions, s.Velocities, count, dt)
+	}
+	if stirred || turning {
+		copy(s.Velocities, s.calm)
+	}
+	s.clampBoundary(count)
+	s.reflectChannel(count)
+	s.reflectBump(count)
+	s.reflectRotor(count)
+	if s.Config.Collision.Enabled || dragged {
+		s.collide(s.from, count, drag, dt)
+	} else if swept {
+		s.blockSplitter(s.from, count)
+	}
+	if double {
+		s.syncPrecise(count)
+	}
+	if s.Symmetry != nil && s.mirrors.used {
+		s.reflectMirrors(count)
+	}
+	// The lab frame drift, plus the prescribed motion moved along its
+	// trajectory rather than by its velocity, or the drag
+	s.carry(&f, dt)
+	f.Motion = [3]float64{}
+	d := f.ObjectVelocity()
+	for a := range d {
+		d[a] = d[a]*dt + drag[a]
+	}
+	if m := s.Motion; m != nil {
+		p0, _ := m.At(m.Time)
+		m.Time += dt
+		p1, _ := m.At(m.Time)
+		for a := range d {
+			d[a] += p1[a] - p0[a]
+		}
+	}
+	if d != [3]float64{} {
+		o := &s.Config.Object
+		s.SetObjectPosition(o.X+d[0], o.Y+d[1], o.Z+d[2])
+	}
+	if s.Events != nil {
+		s.Events.after(&s.Config.Object, s.Positions, count)
+	}
+	s.Time += dt
+	s.Stats.Steps++
+	if LogEnabled(LogWarn) {
+		if n := nonFinite(s.Positions[:count*3]); n > 0 {
+			LogValue(LogWarn, "step", "non-finite positions", float64(n))
+		}
+	}
+}
+
+// nonFinite returns the number of NaN or infinite values in buf
+func nonFinite(buf []float32) int {
+	n := 0
+	for _, x := range buf {
+		if x-x != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// StepMany runs n steps of length dt. If every is positive, the positions
+// after every every-th step are also written to frames, one count*3 block
+// per frame of the count before the steps; frames must hold n/every
+// blocks. Injection and density control change the particle count between
+// frames: a frame holds the first min(count, Count()) particles of its
+// step, the rest of its block zero, and if counts is not nil, counts[k]
+// gets the number of particles in frame k; counts must then hold n/every
+// elements. Nothing is stepped if the arguments are invalid.
+func (s *Simulation) StepMany(n int, dt float64, every int, frames []float32, counts []uint32) error {
+	if n < 0 {
+		return Errorf(ErrBadArguments, "nSubsteps must be non-negative, got %d", n)
+	}
+	if dt < 0 || math.IsNaN(dt) || math.IsInf(dt, 0) {
+		return Errorf(ErrBadArguments, "dt must be a non-negative finite number, got %g", dt)
+	}
+	count := s.Count()
+	if every > 0 {
+		if need := n / every * count * 3; len(frames) < need {
+			return Errorf(ErrBufferLength, "frames has %d elements, need %d for %d frames of %d particles", len(frames), need, n/every, count)
+		}
+		if counts != nil && len(counts) < n/every {
+			return Errorf(ErrBufferLength, "counts has %d elements, need %d for %d frames", len(counts), n/every, n/every)
+		}
+	}
+	for k := 1; k <= n; k++ {
+		if err := s.Step(dt); err != nil {
+			return err
+		}
+		if every > 0 && k%every == 0 {
+			frame := frames[(k/every-1)*count*3 : k/every*count*3]
+			m := min(count, s.Count())
+			copy(frame, s.Positions[:m*3])
+			clear(frame[m*3:])
+			if counts != nil {
+				counts[k/every-1] = uint32(m)
+			}
+		}
+	}
+	return nil
+}
+
+// PartialChunk is the number of particles UpdatePartial evaluates between
+// two checks of its budget
+const PartialChunk = 2048
+
+// UpdatePartial is Step refreshing only part of the velocities and
+// pressures: starting at Cursor, it evaluates chunks of PartialChunk
+// particles until expired reports that the time budget is spent or every
+// particle has been refreshed once, wrapping around at the end, and leaves
+// Cursor where it stopped. The other particles keep the velocities and
+// pressures of their last refresh, which also means a configuration change
+// reaches them only once the sweep gets there. At least one chunk is
+// evaluated per call, so every particle is eventually refreshed whatever
+// the budget. All particles are then advected by dt as in Step, and while
+// Paused nothing happens. Returns the number of particles refreshed.
+func (s *Simulation) UpdatePartial(dt float64, expired func() bool) (int, error) {
+	if dt < 0 || math.IsNaN(dt) || math.IsInf(dt, 0) {
+		return 0, Errorf(ErrBadArguments, "dt must be a non-negative finite number, got %g", dt)
+	}
+	if s.Paused {
+		return 0, nil
+	}
+	dt *= s.TimeScale
+	f := s.Flow()
+	f.Cutoff = s.Cutoff
+	if err := f.Validate(); err != nil {
+		return 0, err
+	}
+	count := s.Count()
+	if s.Cursor >= count {
+		s.Cursor = 0
+	}
+	s.mirrors.used = false
+	updated := 0
+	for updated < count {
+		n := min(PartialChunk, count-updated, count-s.Cursor)
+		from, to := s.Cursor, s.Cursor+n
+		far, err := VelocitiesIntoCounted(s.Velocities[from*3:to*3], s.Positions[from*3:to*3], n, f)
+		if err != nil {
+			return updated, err
+		}
+		s.Stats.FarField += far
+		if err := PressuresFor(s.Pressures[from:to], s.Velocities[from*3:to*3], n, &f, Mask{}); err != nil {
+			return updated, err
+		}
+		if err := AddSwirlPressures(s.Pressures[from:to], s.Positions[from*3:to*3], n, &f, Mask{}); err != nil {
+			return updated, err
+		}
+		s.rotate(from, to, f.Density)
+		updated += n
+		if s.Cursor = to; s.Cursor == count {
+			s.Cursor = 0
+		}
+		if expired() {
+			break
+		}
+	}
+	if s.Recorder != nil {
+		s.Recorder.Capture(s)
+	}
+	if s.Forces != nil {
+		s.Forces.Capture(s)
+	}
+	s.Probes.Capture(s)
+	steps := s.Stats.Steps
+	s.advance(dt, count)
+	return updated, s.settle(steps, dt)
+}
+
+// SetFreeStream changes the free stream speed and, unless direction is
+// zero, its direction, which is normalized
+func (s *Simulation) SetFreeStream(speed float64, direction [3]float64) error {
+	fs := s.Config.FreeStream
+	fs.Speed = speed
+	if math.IsNaN(speed) {
+		return Errorf(ErrBadArguments, "freeStream.speed must be a number")
+	}
+	if direction != [3]float64{} {
+		var err error
+		if fs.Direction, err = unit(direction, "freeStream.direction"); err != nil {
+			return err
+		}
+	}
+	s.Config.FreeStream = fs
+	return nil
+}
+
+// SetProfile changes the height profile of the free stream
+func (s *Simulation) SetProfile(p Profile) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	s.Config.FreeStream.Profile = p
+	return nil
+}
+
+// SetSwirl changes the swirl of the free stream
+func (s *Simulation) SetSwirl(w Swirl) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	s.Config.FreeStream.Swirl = w
+	return nil
+}
+
+// Flow returns the flow of the configuration at the current time, with
+// the scheduled free stream speed and the velocity of the object's Motion:
+// what Step evaluates apart from the far-field cutoff
+func (s *Simulation) Flow() Flow {
+	f := s.Config.Flow()
+	if s.Schedule != nil {
+		f.FreeStream = s.Schedule.At(s.Time)
+	}
+	if m := s.Motion; m != nil {
+		_, f.Motion = m.At(m.Time)
+	}
+	if t := s.Target; t != nil {
+		o := &s.Config.Object
+		f.Motion = t.velocity([3]float64{o.X, o.Y, o.Z})
+	}
+	f.Elements = s.Elements
+	return f
+}
+
+// SetMotion makes the object follow m from now on, starting where it is,
+// dropping its Target, or stops it if m is nil
+func (s *Simulation) SetMotion(m *Motion) error {
+	if m != nil {
+		o := s.Config.Object
+		m.Start, m.Time = [3]float64{o.X, o.Y, o.Z}, 0
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		s.Target = nil
+	}
+	s.Motion = m
+	return nil
+}
+
+// SetFrame switches the reference frame. Particles and the object stay
+// where they are, so nothing jumps; the velocities of the last evaluation
+// are transformed to the new frame so that particles not yet refreshed,
+// under LOD or UpdatePartial, move consistently with the others.
+func (s *Simulation) SetFrame(frame string) error {
+	if frame == "" {
+		frame = FrameBody
+	}
+	if err := CheckFrame(frame); err != nil {
+		return err
+	}
+	c := s.Config
+	c.Frame = frame
+	s.SetConfig(c)
+	return nil
+}
+
+// transformVelocities takes the velocities of the last evaluation from the
+// frame of flow fo to that of fc
+func (s *Simulation) transformVelocities(fo, fc *Flow) {
+	a, b := fo.relative(), fc.relative()
+	if a == b {
+		return
+	}
+	for i := 0; i < s.Count(); i++ {
+		for j := 0; j < 3; j++ {
+			s.Velocities[i*3+j] += float32(a[j] - b[j])
+		}
+	}
+}
+
+// SetConfig replaces the configuration, transforming the velocities of the
+// last evaluation if the frame changes (see SetFrame)
+func (s *Simulation) SetConfig(c Config) {
+	if c.Object != s.Config.Object {
+		s.ObjectVersion++
+	}
+	if c.Precision != PrecisionDouble {
+		s.precise = nil
+	}
+	fo := s.Flow()
+	s.Config = c
+	if fc := s.Flow(); fc.Frame != fo.Frame {
+		s.transformVelocities(&fo, &fc)
+	}
+}
+
+// SetObjectPosition moves the object
+func (s *Simulation) SetObjectPosition(x, y, z float64) {
+	s.Config.Object.X, s.Config.Object.Y, s.Config.Object.Z = x, y, z
+	s.ObjectVersion++
+}
+
+// State returns a snapshot of the simulation in the generic form used by
+// Config.Encode
+func (s *Simulation) State() map[string]interface{} {
+	o := s.Config.Object
+	d := s.Config.FreeStream.Direction
+	random := s.Random.State()
+	f := s.Flow()
+	v := f.ObjectVelocity()
+	freeStream := map[string]interface{}{
+		"model":     "uniform",
+		"speed":     f.FreeStream,
+		"direction": []interface{}{d[0], d[1], d[2]},
+	}
+	if p := s.Config.FreeStream.Profile; p.Type != "" && p.Type != ProfileUniform {
+		freeStream["model"], freeStream["profile"] = p.Type, p.Encode()
+	}
+	if w := s.Config.FreeStream.Swirl; w.Number != 0 {
+		freeStream["swirl"] = w.Encode()
+	}
+	if s.Schedule != nil {
+		freeStream["model"], freeStream["schedule"] = "schedule", s.Schedule.Encode()
+	}
+	var elements interface{}
+	if s.Elements != nil {
+		elements = s.Elements.Encode()
+	}
+	var target interface{}
+	if t := s.Target; t != nil {
+		target = map[string]interface{}{
+			"position":     []interface{}{t.Position[0], t.Position[1], t.Position[2]},
+			"timeConstant": t.TimeConstant,
+			"maxSpeed":     t.MaxSpeed,
+		}
+	}
+	var motion interface{}
+	if m := s.Motion; m != nil {
+		motion = map[string]interface{}{
+			"type":     m.Type,
+			"time":     m.Time,
+			"progress": m.Progress(),
+		}
+	}
+	return map[string]interface{}{
+		"time":  s.Time,
+		"count": s.Count(),
+		"objects": []interface{}{
+			map[string]interface{}{
+				"id":              0,
+				"type":            o.Type.String(),
+				"position":        []interface{}{o.X, o.Y, o.Z},
+				"radius":          o.Radius,
+				"strengths":       o.Strengths.Encode(),
+				"collisionRadius": o.CollisionRadius,
+				"alpha":           o.Alpha * 180 / math.Pi,
+				"edgeRadius":      o.EdgeRadius,
+				"semiMinor":       o.SemiMinor,
+				"kutta":           o.Kutta,
+				"minorRadius":     o.MinorRadius,
+				"collocation":     o.Collocation,
+				"size":            []interface{}{o.Size[0], o.Size[1], o.Size[2]},
+				"panels":          o.Panels,
+				"splitter":        o.Splitter,
+				"velocity":        []interface{}{v[0], v[1], v[2]},
+				"motion":          motion,
+				"target":          target,
+			},
+		},
+		"freeStream": freeStream,
+		"elements":   elements,
+		"frame":      s.Config.Frame,
+		"precision":  s.Config.Precision,
+		"arithmetic": s.Config.Arithmetic,
+		"boundaries": s.Config.Boundary.Encode(),
+		"channel":    s.Config.Channel.Encode(),
+		"bump":       s.Config.Bump.Encode(),
+		"rotor":      s.Config.Rotor.Encode(),
+		"collision":  s.Config.Collision.Encode(),
+		"farField": map[string]interface{}{
+			"cutoff": s.Cutoff,
+		},
+		"clock": map[string]interface{}{
+			"time":      s.Time,
+			"steps":     s.Stats.Steps,
+			"paused":    s.Paused,
+			"timeScale": s.TimeScale,
+		},
+		"partial": map[string]interface{}{
+			"cursor": s.Cursor,
+		},
+		"lod":           s.lodState(),
+		"density":       s.densityState(),
+		"injection":     s.injectionState(),
+		"probes":        s.probesState(),
+		"turbulence":    s.turbulenceState(),
+		"rotatingFrame": s.rotatingState(),
+		"symmetry":      s.symmetryState(),
+		"random":        random.Encode(),
+		"stats": map[string]interface{}{
+			"steps":     s.Stats.Steps,
+			"clamped":   s.Stats.Clamped,
+			"farField":  s.Stats.FarField,
+			"lodReused": s.Stats.LODReused,
+			"mirrored":  s.Stats.Mirrored,
+			"merged":    s.Stats.Merged,
+			"split":     s.Stats.Split,
+		},
+	}
+}
+
+// densityState returns the density section of State
+func (s *Simulation) densityState() map[string]interface{} {
+	if s.Density == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	d := s.Density
+	count := "fixed"
+	if d.Floating {
+		count = "floating"
+	}
+	return map[string]interface{}{
+		"enabled":    true,
+		"every":      d.Every,
+		"resolution": []interface{}{d.Resolution[0], d.Resolution[1], d.Resolution[2]},
+		"minPerCell": d.MinPerCell,
+		"maxPerCell": d.MaxPerCell,
+		"count":      count,
+		"minCount":   d.MinCount,
+		"maxCount":   d.MaxCount,
+		"jitter":     d.Jitter,
+	}
+}
+
+// injectionState returns the injection section of State
+func (s *Simulation) injectionState() map[string]interface{} {
+	j := &s.Injection
+	injectors := make([]interface{}, len(j.Injectors))
+	for i, in := range j.Injectors {
+		injectors[i] = map[string]interface{}{
+			"id":       int(in.ID),
+			"position": []interface{}{in.Position[0], in.Position[1], in.Position[2]},
+			"radius":   in.Radius,
+			"rate":     in.Rate,
+			"color":    []interface{}{in.Color[0], in.Color[1], in.Color[2]},
+			"enabled":  in.Enabled,
+		}
+	}
+	return map[string]interface{}{
+		"injectors": injectors,
+		"budget":    j.budget(),
+		"dropped":   j.Dropped,
+	}
+}
+
+// lodState returns the lod section of State
+func (s *Simulation) lodState() map[string]interface{} {
+	if s.LOD == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	l := s.LOD
+	return map[string]interface{}{
+		"enabled":  true,
+		"near":     l.Near,
+		"far":      l.Far,
+		"midEvery": l.MidEvery,
+		"farEvery": l.FarEvery,
+		"rebucket": l.Rebucket,
+	}
+}
@@ -0,0 +1,882 @@
+package flow
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"math"
+	"sort"
+)
+
+// Snapshot layout. All values are little-endian.
+//
+//	offset  size  contents
+//	0       4     magic "FSNP"
+//	4       4     uint32 version (SnapshotVersion)
+//	8       4     uint32 particle count n
+//	12      4     uint32 flags; bit 0 set if the seeding block is
+//	              meaningful, bit 1 if the configuration is in the lab
+//	              frame, bit 2 if the shear block is present, bit 3
+//	              if the boundary layer block is, bit 4 if the swirl
+//	              block is, bit 5 if the strengths block is, bit 6 if
+//	              the plate block is, bit 7 if the boundaries block is,
+//	              bit 8 if the ellipse block is, bit 9 if the torus block
+//	              is, bit 10 if the box block is, bit 11 if the splitter
+//	              block is, bit 12 if the channel block is, bit 13 if
+//	              the bump block is, bit 14 if the rotor block is, bit
+//	              15 if the positions are in double precision, bit 16
+//	              if the collision block is, bit 17 if the bands block
+//	              is, bit 18 if the arithmetic is fast, bit 19 if the
+//	              sources are present, bit 20 if the elements block is,
+//	              bit 21 if the motion block is, bit 22 if the schedule
+//	              block is, bit 23 if the probes block is, bit 24 if the
+//	              random block is
+//	16      8*14  config block: float64 speed, direction x, y, z, density,
+//	              object type, object x, y, z, radius, time, dt; int64
+//	              steps, clamped
+//	...     8*12  seeding block: int64 kind (0 random, 1 grid), count,
+//	              seed; float64 min x, y, z, max x, y, z; int64 nx, ny, nz
+//	...     8*2   shear block, only with flag bit 2: float64 rate, y0
+//	...     8*4   boundary layer block, only with flag bit 3: int64 law
+//	              (0 power, 1 log); float64 zref, alpha or z0, floor
+//	...     8*3   swirl block, only with flag bit 4: int64 type (0 solid,
+//	              1 free); float64 number, radius
+//	...     8*5   strengths block, only with flag bit 5: int64 set
+//	              (Strengths.Set); float64 doublet, source, circulation,
+//	              collision radius
+//	...     8*2   plate block, only with flag bit 6: float64 alpha
+//	              (radians), edge radius
+//	...     8*3   boundaries block, only with flag bit 7: int64 mode
+//	              (1 wall, 2 free surface); float64 height; int64 clamp
+//	...     8*2   ellipse block, only with flag bit 8: float64 semi-minor
+//	              axis; int64 kutta
+//	...     8*2   torus block, only with flag bit 9: float64 minor radius;
+//	              int64 collocation
+//	...     8*4   box block, only with flag bit 10: float64 size x, y, z;
+//	              int64 panels
+//	...     8     splitter block, only with flag bit 11: float64 length
+//	...     8*7   channel block, only with flag bit 12: float64 inlet
+//	              height, throat height, start, inlet, converging, throat
+//	              and diverging lengths
+//	...     8*4   bump block, only with flag bit 13: float64 height,
+//	              width, x; int64 panels
+//	...     8*7   rotor block, only with flag bit 14: float64 radius,
+//	              induced velocity, thrust, hub x, y, z, ground height
+//	...     8*2   collision block, only with flag bit 16: float64
+//	              restitution, friction
+//	...     8*17  bands block, only with flag bit 17: int64 bands;
+//	              float64 thickness, 8 speeds, 7 heights, the unused
+//	              ones 0
+//	...     8+72m elements block, only with flag bit 20: int64 count m;
+//	              per element int64 kind (index in the element kinds);
+//	              float64 position x, y, z, orientation x, y, z,
+//	              strength, core radius
+//	...     8*21  motion block, only with flag bit 21: int64 type (0
+//	        +24p  linear, 1 circular, 2 waypoints); float64 start x, y,
+//	              z, velocity x, y, z, acceleration x, y, z, center x,
+//	              y, z, axis x, y, z, time, period, duration; int64
+//	              loop, waypoint count p; p float64 waypoints x, y, z
+//	...     8+16p schedule block, only with flag bit 22: int64 count p;
+//	              p float64 times and speeds
+//	...     8+72p probes block, only with flag bit 23: int64 count p;
+//	              per probe int64 ID; float64 center x, y, z, normal x,
+//	              y, z, radius; int64 rings. Histories are not saved.
+//	...     16+   random block, only with flag bit 24: int64 seed,
+//	              stream count k; per stream in name order int64 draws,
+//	              name length l; the name, zero-padded to 8 bytes
+//	...     12n   float32 positions
+//	...     12n   float32 velocities
+//	...     4n    float32 pressures
+//	...     24n   float64 positions, only with flag bit 15
+//	...     n     uint8 sources (Simulation.Sources), only with flag bit 19
+//	...     4     uint32 CRC-32 (IEEE) of all preceding bytes
+const (
+	SnapshotMagic   = "FSNP"
+	SnapshotVersion = 2
+
+	snapshotHeader = 16
+	configSlots    = 14
+	seedingSlots   = 12
+	shearSlots     = 2
+	layerSlots     = 4
+	swirlSlots     = 3
+	strengthSlots  = 5
+	plateSlots     = 2
+	boundarySlots  = 3
+	ellipseSlots   = 2
+	torusSlots     = 2
+	boxSlots       = 4
+	splitterSlots  = 1
+	channelSlots   = 7
+	bumpSlots      = 4
+	rotorSlots     = 7
+	collisionSlots = 2
+	bandSlots      = 2*MaxBands + 1
+	elementSlots   = 9
+	motionSlots    = 20
+	scheduleSlots  = 2
+	probeSlots     = 9
+
+	// snapshotFlags are the flag bits this version knows
+	snapshotFlags = 1<<25 - 1
+)
+
+// motionTypes are the motion types by their index in snapshots
+var motionTypes = []string{MotionLinear, MotionCircular, MotionWaypoints}
+
+// snapshotFixed is the size of everything but the buffers and checksum
+const snapshotFixed = snapshotHeader + 8*(configSlots+seedingSlots)
+
+// particleSize returns the size of the buffers of n particles: the
+// float32 positions, velocities and pressures, and the double precision
+// positions and the sources if flags announce them
+func particleSize(flags uint32, n int) int {
+	size := 28 * n
+	if flags&32768 != 0 {
+		size += 24 * n
+	}
+	if flags&524288 != 0 {
+		size += n
+	}
+	return size
+}
+
+// profileFlags returns the flag bits of the block of a free stream profile
+func profileFlags(p *Profile) uint32 {
+	switch p.Type {
+	case ProfileShear:
+		return 4
+	case ProfilePower, ProfileLog:
+		return 8
+	case ProfileLayered:
+		return 131072
+	}
+	return 0
+}
+
+// optionalSize returns the size of the optional blocks flags announce
+func optionalSize(flags uint32) int {
+	size := 0
+	if flags&4 != 0 {
+		size += 8 * shearSlots
+	}
+	if flags&8 != 0 {
+		size += 8 * layerSlots
+	}
+	if flags&16 != 0 {
+		size += 8 * swirlSlots
+	}
+	if flags&32 != 0 {
+		size += 8 * strengthSlots
+	}
+	if flags&64 != 0 {
+		size += 8 * plateSlots
+	}
+	if flags&128 != 0 {
+		size += 8 * boundarySlots
+	}
+	if flags&256 != 0 {
+		size += 8 * ellipseSlots
+	}
+	if flags&512 != 0 {
+		size += 8 * torusSlots
+	}
+	if flags&1024 != 0 {
+		size += 8 * boxSlots
+	}
+	if flags&2048 != 0 {
+		size += 8 * splitterSlots
+	}
+	if flags&4096 != 0 {
+		size += 8 * channelSlots
+	}
+	if flags&8192 != 0 {
+		size += 8 * bumpSlots
+	}
+	if flags&16384 != 0 {
+		size += 8 * rotorSlots
+	}
+	if flags&65536 != 0 {
+		size += 8 * collisionSlots
+	}
+	if flags&131072 != 0 {
+		size += 8 * bandSlots
+	}
+	return size
+}
+
+// variableFlags returns the flag bits of the variable blocks s needs
+func (s *Simulation) variableFlags() uint32 {
+	var flags uint32
+	if s.Elements != nil {
+		flags |= 1048576
+	}
+	if s.Motion != nil {
+		flags |= 2097152
+	}
+	if s.Schedule != nil {
+		flags |= 4194304
+	}
+	if len(s.Probes.List) != 0 {
+		flags |= 8388608
+	}
+	if st := s.Random.State(); st.Seed != 0 || len(st.Draws) != 0 {
+		flags |= 16777216
+	}
+	return flags
+}
+
+// variableSize returns the size of the variable blocks of s flags announce
+func (s *Simulation) variableSize(flags uint32) int {
+	size := 0
+	if flags&1048576 != 0 {
+		size += 8 + 8*elementSlots*s.Elements.Len()
+	}
+	if flags&2097152 != 0 {
+		size += 8*(motionSlots+1) + 24*len(s.Motion.Points)
+	}
+	if flags&4194304 != 0 {
+		size += 8 + 8*scheduleSlots*len(s.Schedule.Points)
+	}
+	if flags&8388608 != 0 {
+		size += 8 + 8*probeSlots*len(s.Probes.List)
+	}
+	if flags&16777216 != 0 {
+		size += 16
+		for name := range s.Random.State().Draws {
+			size += 16 + padded(len(name))
+		}
+	}
+	return size
+}
+
+// snapshotVariableSize returns the size of the variable blocks flags
+// announce in b from offset at, walking their counts, or -1 if a count
+// runs past the end of b
+func snapshotVariableSize(b []byte, at int, flags uint32) int {
+	r := at
+	// count reads a count of items of size bytes each, which must fit in
+	// the rest of b
+	count := func(size int) int {
+		if r < 0 || len(b)-r < 8 {
+			r = -1
+			return 0
+		}
+		c := binary.LittleEndian.Uint64(b[r:])
+		r += 8
+		if c > uint64(len(b)-r)/uint64(size) {
+			r = -1
+			return 0
+		}
+		return int(c)
+	}
+	if flags&1048576 != 0 {
+		r += 8 * elementSlots * count(8*elementSlots)
+	}
+	if flags&2097152 != 0 && r >= 0 {
+		r += 8 * motionSlots
+		r += 24 * count(24)
+	}
+	if flags&4194304 != 0 && r >= 0 {
+		r += 8 * scheduleSlots * count(8*scheduleSlots)
+	}
+	if flags&8388608 != 0 && r >= 0 {
+		r += 8 * probeSlots * count(8*probeSlots)
+	}
+	if flags&16777216 != 0 && r >= 0 {
+		r += 8
+		for k := count(16); k > 0 && r >= 0; k-- {
+			r += 8
+			r += padded(count(1))
+		}
+	}
+	if r < 0 || r > len(b) {
+		return -1
+	}
+	return r - at
+}
+
+// padded rounds n up to a multiple of 8
+func padded(n int) int {
+	return (n + 7) &^ 7
+}
+
+// MarshalBinary encodes the full state of s as a snapshot, its elements,
+// motion, schedule, probes and random generator among it. The runtime
+// settings Inherit lists and the probe histories are not part of it.
+func (s *Simulation) MarshalBinary() ([]byte, error) {
+	n := s.Count()
+	profile, swirl := s.Config.FreeStream.Profile, s.Config.FreeStream.Swirl
+	flags := profileFlags(&profile)
+	if swirl != DefaultSwirl() {
+		flags |= 16
+	}
+	if o := s.Config.Object; o.Strengths.Set != 0 || o.CollisionRadius != 0 {
+		flags |= 32
+	}
+	if o := s.Config.Object; o.Alpha != 0 || o.EdgeRadius != 0 {
+		flags |= 64
+	}
+	if s.Config.Boundary.image() != 0 {
+		flags |= 128
+	}
+	if o := s.Config.Object; o.SemiMinor != 0 || o.Kutta {
+		flags |= 256
+	}
+	if o := s.Config.Object; o.MinorRadius != 0 || o.Collocation != 0 {
+		flags |= 512
+	}
+	if o := s.Config.Object; o.Size != [3]float64{} || o.Panels != 0 {
+		flags |= 1024
+	}
+	if s.Config.Object.Splitter != 0 {
+		flags |= 2048
+	}
+	if s.Config.Channel.enabled() {
+		flags |= 4096
+	}
+	if s.Config.Bump.enabled() {
+		flags |= 8192
+	}
+	if s.Config.Rotor.enabled() {
+		flags |= 16384
+	}
+	if s.Config.Precision == PrecisionDouble {
+		flags |= 32768
+	}
+	if s.Config.Collision.Enabled {
+		flags |= 65536
+	}
+	if s.Config.Arithmetic == ArithmeticFast {
+		flags |= 262144
+	}
+	flags |= s.variableFlags()
+	fixed := snapshotFixed + optionalSize(flags) + s.variableSize(flags)
+	sources := s.ParticleSources()
+	for _, id := range sources {
+		if id != 0 {
+			flags |= 524288
+			break
+		}
+	}
+	b := make([]byte, fixed+particleSize(flags, n)+4)
+	le := binary.LittleEndian
+	copy(b, SnapshotMagic)
+	le.PutUint32(b[4:], SnapshotVersion)
+	le.PutUint32(b[8:], uint32(n))
+
+	w := snapshotHeader
+	f64 := func(x float64) { le.PutUint64(b[w:], math.Float64bits(x)); w += 8 }
+	i64 := func(x int64) { le.PutUint64(b[w:], uint64(x)); w += 8 }
+
+	c := &s.Config
+	f64(c.FreeStream.Speed)
+	for _, d := range c.FreeStream.Direction {
+		f64(d)
+	}
+	f64(c.Density)
+	f64(float64(c.Object.Type))
+	f64(c.Object.X)
+	f64(c.Object.Y)
+	f64(c.Object.Z)
+	f64(c.Object.Radius)
+	f64(s.Time)
+	f64(s.DT)
+	i64(int64(s.Stats.Steps))
+	i64(int64(s.Stats.Clamped))
+
+	if c.Frame == FrameLab {
+		flags |= 2
+	}
+	var sp SeedSpec
+	if s.Seeding != nil {
+		sp = *s.Seeding
+		flags |= 1
+	}
+	le.PutUint32(b[12:], flags)
+	kind := int64(0)
+	if sp.Kind == SeedGrid {
+		kind = 1
+	}
+	i64(kind)
+	i64(int64(sp.Count))
+	i64(sp.Seed)
+	for _, x := range sp.Grid.Min {
+		f64(x)
+	}
+	for _, x := range sp.Grid.Max {
+		f64(x)
+	}
+	for _, x := range sp.Grid.N {
+		i64(int64(x))
+	}
+	if flags&4 != 0 {
+		f64(profile.Rate)
+		f64(profile.Ref)
+	}
+	if flags&8 != 0 {
+		law, x := int64(0), profile.Alpha
+		if profile.Type == ProfileLog {
+			law, x = 1, profile.Roughness
+		}
+		i64(law)
+		f64(profile.Ref)
+		f64(x)
+		f64(profile.Floor)
+	}
+	if flags&16 != 0 {
+		kind := int64(0)
+		if swirl.Type == SwirlFree {
+			kind = 1
+		}
+		i64(kind)
+		f64(swirl.Number)
+		f64(swirl.Radius)
+	}
+	if flags&32 != 0 {
+		o := &c.Object
+		i64(int64(o.Strengths.Set))
+		f64(o.Strengths.Doublet)
+		f64(o.Strengths.Source)
+		f64(o.Strengths.Circulation)
+		f64(o.CollisionRadius)
+	}
+	if flags&64 != 0 {
+		f64(c.Object.Alpha)
+		f64(c.Object.EdgeRadius)
+	}
+	if flags&128 != 0 {
+		mode, clamp := int64(1), int64(0)
+		if c.Boundary.Mode == BoundaryFreeSurface {
+			mode = 2
+		}
+		if c.Boundary.Clamp {
+			clamp = 1
+		}
+		i64(mode)
+		f64(c.Boundary.Height)
+		i64(clamp)
+	}
+	if flags&256 != 0 {
+		kutta := int64(0)
+		if c.Object.Kutta {
+			kutta = 1
+		}
+		f64(c.Object.SemiMinor)
+		i64(kutta)
+	}
+	if flags&512 != 0 {
+		f64(c.Object.MinorRadius)
+		i64(int64(c.Object.Collocation))
+	}
+	if flags&1024 != 0 {
+		for _, l := range c.Object.Size {
+			f64(l)
+		}
+		i64(int64(c.Object.Panels))
+	}
+	if flags&2048 != 0 {
+		f64(c.Object.Splitter)
+	}
+	if flags&4096 != 0 {
+		ch := &c.Channel
+		for _, x := range []float64{ch.InletHeight, ch.ThroatHeight, ch.Start, ch.InletLength, ch.ConvergeLength, ch.ThroatLength, ch.DivergeLength} {
+			f64(x)
+		}
+	}
+	if flags&8192 != 0 {
+		f64(c.Bump.Height)
+		f64(c.Bump.Width)
+		f64(c.Bump.X)
+		i64(int64(c.Bump.Panels))
+	}
+	if flags&16384 != 0 {
+		r := &c.Rotor
+		for _, x := range []float64{r.Radius, r.InducedVelocity, r.Thrust, r.Position[0], r.Position[1], r.Position[2], r.Ground} {
+			f64(x)
+		}
+	}
+	if flags&65536 != 0 {
+		f64(c.Collision.Restitution)
+		f64(c.Collision.Friction)
+	}
+	if flags&131072 != 0 {
+		i64(int64(profile.Bands))
+		f64(profile.Thickness)
+		for _, x := range profile.Speeds {
+			f64(x)
+		}
+		for _, x := range profile.Heights {
+			f64(x)
+		}
+	}
+	if flags&1048576 != 0 {
+		elements := s.Elements.Elements()
+		i64(int64(len(elements)))
+		for _, e := range elements {
+			kind := 0
+			for i, k := range elementKinds {
+				if k.name == e.Kind {
+					kind = i
+				}
+			}
+			i64(int64(kind))
+			for _, x := range append(append(e.Position[:], e.Orientation[:]...), e.Strength, e.CoreRadius) {
+				f64(x)
+			}
+		}
+	}
+	if flags&2097152 != 0 {
+		m := s.Motion
+		kind, loop := 0, int64(0)
+		for i, t := range motionTypes {
+			if t == m.Type {
+				kind = i
+			}
+		}
+		if m.Loop {
+			loop = 1
+		}
+		i64(int64(kind))
+		for _, v := range [][3]float64{m.Start, m.Velocity, m.Acceleration, m.Center, m.Axis} {
+			for _, x := range v {
+				f64(x)
+			}
+		}
+		f64(m.Time)
+		f64(m.Period)
+		f64(m.Duration)
+		i64(loop)
+		i64(int64(len(m.Points)))
+		for _, p := range m.Points {
+			for _, x := range p {
+				f64(x)
+			}
+		}
+	}
+	if flags&4194304 != 0 {
+		i64(int64(len(s.Schedule.Points)))
+		for _, p := range s.Schedule.Points {
+			f64(p.T)
+			f64(p.U)
+		}
+	}
+	if flags&8388608 != 0 {
+		probes := s.Probes.Definitions()
+		i64(int64(len(probes)))
+		for _, p := range probes {
+			i64(int64(p.ID))
+			for _, x := range append(append(p.Center[:], p.Normal[:]...), p.Radius) {
+				f64(x)
+			}
+			i64(int64(p.Rings))
+		}
+	}
+	if flags&16777216 != 0 {
+		st := s.Random.State()
+		names := make([]string, 0, len(st.Draws))
+		for name := range st.Draws {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		i64(int64(st.Seed))
+		i64(int64(len(names)))
+		for _, name := range names {
+			i64(int64(st.Draws[name]))
+			i64(int64(len(name)))
+			copy(b[w:], name)
+			w += padded(len(name))
+		}
+	}
+
+	for _, buf := range [][]float32{s.Positions, s.Velocities, s.Pressures} {
+		for _, x := range buf {
+			le.PutUint32(b[w:], math.Float32bits(x))
+			w += 4
+		}
+	}
+	if flags&32768 != 0 {
+		for _, x := range s.precisePositions() {
+			f64(x)
+		}
+	}
+	if flags&524288 != 0 {
+		w += copy(b[w:], sources)
+	}
+	le.PutUint32(b[w:], crc32.ChecksumIEEE(b[:w]))
+	return b, nil
+}
+
+// UnmarshalBinary restores s from a snapshot written by MarshalBinary. It
+// fails without touching s if the snapshot has another version or flags it
+// doesn't know, is truncated or doesn't match its checksum. The runtime
+// settings of s carry over as with Inherit; everything else, the
+// elements, motion and schedule among it, is that of the snapshot.
+func (s *Simulation) UnmarshalBinary(b []byte) error {
+	le := binary.LittleEndian
+	if len(b) < snapshotHeader || string(b[:4]) != SnapshotMagic {
+		return Errorf(ErrBadArguments, "not a simulation snapshot")
+	}
+	if v := le.Uint32(b[4:]); v != SnapshotVersion {
+		return Errorf(ErrUnsupported, "snapshot version %d is not supported; this build reads version %d", v, SnapshotVersion)
+	}
+	n := int(le.Uint32(b[8:]))
+	flags := le.Uint32(b[12:])
+	if unknown := flags &^ snapshotFlags; unknown != 0 {
+		return Errorf(ErrUnsupported, "snapshot has unknown flags %#x; it was written by a newer build", unknown)
+	}
+	fixed := snapshotFixed + optionalSize(flags)
+	if len(b) < fixed {
+		return Errorf(ErrBufferLength, "snapshot is truncated: %d bytes, expected at least %d", len(b), fixed)
+	}
+	variable := snapshotVariableSize(b, fixed, flags)
+	if variable < 0 {
+		return Errorf(ErrBufferLength, "snapshot is truncated: its blocks run past its %d bytes", len(b))
+	}
+	fixed += variable
+	if size := fixed + particleSize(flags, n) + 4; len(b) != size {
+		return Errorf(ErrBufferLength, "snapshot is truncated: %d bytes, expected %d", len(b), size)
+	}
+	end := len(b) - 4
+	if crc32.ChecksumIEEE(b[:end]) != le.Uint32(b[end:]) {
+		return Errorf(ErrBufferLength, "snapshot checksum mismatch: the data is corrupt")
+	}
+
+	r := snapshotHeader
+	f64 := func() float64 { x := math.Float64frombits(le.Uint64(b[r:])); r += 8; return x }
+	i64 := func() int64 { x := int64(le.Uint64(b[r:])); r += 8; return x }
+
+	var t Simulation
+	c := &t.Config
+	c.FreeStream.Speed = f64()
+	for i := range c.FreeStream.Direction {
+		c.FreeStream.Direction[i] = f64()
+	}
+	c.Density = f64()
+	c.Object.Type = ObjectType(f64())
+	c.Object.X, c.Object.Y, c.Object.Z = f64(), f64(), f64()
+	c.Object.Radius = f64()
+	t.Time, t.DT = f64(), f64()
+	t.Stats.Steps, t.Stats.Clamped = int(i64()), int(i64())
+	c.Frame = FrameBody
+	if flags&2 != 0 {
+		c.Frame = FrameLab
+	}
+	c.Precision = PrecisionSingle
+	if flags&32768 != 0 {
+		c.Precision = PrecisionDouble
+	}
+	c.Arithmetic = ArithmeticAccurate
+	if flags&262144 != 0 {
+		c.Arithmetic = ArithmeticFast
+	}
+
+	sp := SeedSpec{Kind: SeedRandom}
+	if i64() == 1 {
+		sp.Kind = SeedGrid
+	}
+	sp.Count, sp.Seed = int(i64()), i64()
+	for i := range sp.Grid.Min {
+		sp.Grid.Min[i] = f64()
+	}
+	for i := range sp.Grid.Max {
+		sp.Grid.Max[i] = f64()
+	}
+	for i := range sp.Grid.N {
+		sp.Grid.N[i] = int(i64())
+	}
+	if flags&4 != 0 {
+		p := &c.FreeStream.Profile
+		p.Type, p.Rate, p.Ref = ProfileShear, f64(), f64()
+	}
+	if flags&8 != 0 {
+		p := &c.FreeStream.Profile
+		p.Type = ProfilePower
+		if i64() == 1 {
+			p.Type = ProfileLog
+		}
+		p.Ref = f64()
+		if x := f64(); p.Type == ProfileLog {
+			p.Roughness = x
+		} else {
+			p.Alpha = x
+		}
+		p.Floor = f64()
+	}
+	c.FreeStream.Swirl = DefaultSwirl()
+	if flags&16 != 0 {
+		w := &c.FreeStream.Swirl
+		if i64() == 1 {
+			w.Type = SwirlFree
+		}
+		w.Number, w.Radius = f64(), f64()
+	}
+	if flags&32 != 0 {
+		o := &c.Object
+		o.Strengths.Set = int(i64())
+		o.Strengths.Doublet, o.Strengths.Source, o.Strengths.Circulation = f64(), f64(), f64()
+		o.CollisionRadius = f64()
+	}
+	if flags&64 != 0 {
+		c.Object.Alpha, c.Object.EdgeRadius = f64(), f64()
+	}
+	c.Boundary = Boundary{Mode: BoundaryNone}
+	if flags&128 != 0 {
+		b := &c.Boundary
+		b.Mode = BoundaryWall
+		if i64() == 2 {
+			b.Mode = BoundaryFreeSurface
+		}
+		b.Height, b.Clamp = f64(), i64() == 1
+	}
+	if flags&256 != 0 {
+		c.Object.SemiMinor, c.Object.Kutta = f64(), i64() == 1
+	}
+	if flags&512 != 0 {
+		c.Object.MinorRadius, c.Object.Collocation = f64(), int(i64())
+	}
+	if flags&1024 != 0 {
+		for i := range c.Object.Size {
+			c.Object.Size[i] = f64()
+		}
+		c.Object.Panels = int(i64())
+	}
+	if flags&2048 != 0 {
+		c.Object.Splitter = f64()
+	}
+	if flags&4096 != 0 {
+		ch := &c.Channel
+		for _, x := range []*float64{&ch.InletHeight, &ch.ThroatHeight, &ch.Start, &ch.InletLength, &ch.ConvergeLength, &ch.ThroatLength, &ch.DivergeLength} {
+			*x = f64()
+		}
+	}
+	if flags&8192 != 0 {
+		c.Bump.Height, c.Bump.Width, c.Bump.X = f64(), f64(), f64()
+		c.Bump.Panels = int(i64())
+	}
+	if flags&16384 != 0 {
+		r := &c.Rotor
+		for _, x := range []*float64{&r.Radius, &r.InducedVelocity, &r.Thrust, &r.Position[0], &r.Position[1], &r.Position[2], &r.Ground} {
+			*x = f64()
+		}
+	}
+	if flags&65536 != 0 {
+		c.Collision = Collision{Enabled: true, Restitution: f64(), Friction: f64()}
+		if err := c.Collision.Validate(); err != nil {
+			return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+		}
+	}
+	if flags&131072 != 0 {
+		p := &c.FreeStream.Profile
+		p.Type, p.Bands, p.Thickness = ProfileLayered, int(i64()), f64()
+		for i := range p.Speeds {
+			p.Speeds[i] = f64()
+		}
+		for i := range p.Heights {
+			p.Heights[i] = f64()
+		}
+	}
+	if flags&1048576 != 0 {
+		elements := make([]Element, i64())
+		for i := range elements {
+			e := &elements[i]
+			if k := i64(); k >= 0 && k < int64(len(elementKinds)) {
+				e.Kind = elementKinds[k].name
+			}
+			for _, x := range []*float64{&e.Position[0], &e.Position[1], &e.Position[2], &e.Orientation[0], &e.Orientation[1], &e.Orientation[2], &e.Strength, &e.CoreRadius} {
+				*x = f64()
+			}
+		}
+		sp, err := NewSuperposition(elements)
+		if err != nil {
+			return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+		}
+		t.Elements = sp
+	}
+	if flags&2097152 != 0 {
+		m := &Motion{}
+		if k := i64(); k >= 0 && k < int64(len(motionTypes)) {
+			m.Type = motionTypes[k]
+		}
+		for _, v := range []*[3]float64{&m.Start, &m.Velocity, &m.Acceleration, &m.Center, &m.Axis} {
+			for i := range v {
+				v[i] = f64()
+			}
+		}
+		m.Time, m.Period, m.Duration = f64(), f64(), f64()
+		m.Loop = i64() == 1
+		if p := i64(); p > 0 {
+			m.Points = make([][3]float64, p)
+		}
+		for i := range m.Points {
+			m.Points[i] = [3]float64{f64(), f64(), f64()}
+		}
+		if err := m.Validate(); err != nil {
+			return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+		}
+		t.Motion = m
+	}
+	if flags&4194304 != 0 {
+		sc := &Schedule{Points: make([]SchedulePoint, i64())}
+		for i := range sc.Points {
+			sc.Points[i] = SchedulePoint{T: f64(), U: f64()}
+		}
+		if err := sc.Validate(); err != nil {
+			return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+		}
+		t.Schedule = sc
+	}
+	if flags&8388608 != 0 {
+		for k := i64(); k > 0; k-- {
+			p := FlowProbe{ID: int(i64())}
+			for _, x := range []*float64{&p.Center[0], &p.Center[1], &p.Center[2], &p.Normal[0], &p.Normal[1], &p.Normal[2], &p.Radius} {
+				*x = f64()
+			}
+			p.Rings = int(i64())
+			if err := t.Probes.Define(p); err != nil {
+				return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+			}
+		}
+	}
+	if flags&16777216 != 0 {
+		st := RandomState{Seed: uint64(i64()), Draws: map[string]uint64{}}
+		for k := i64(); k > 0; k-- {
+			draws, l := uint64(i64()), int(i64())
+			st.Draws[string(b[r:r+l])] = draws
+			r += padded(l)
+		}
+		if err := t.Random.Restore(st); err != nil {
+			return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+		}
+	}
+	f := t.Config.Flow()
+	if err := f.Validate(); err != nil {
+		return Errorf(ErrBadArguments, "snapshot holds an invalid configuration: %v", err)
+	}
+	if flags&1 != 0 {
+		t.Seeding = &sp
+	}
+
+	t.Positions = make([]float32, 3*n)
+	t.Velocities = make([]float32, 3*n)
+	t.Pressures = make([]float32, n)
+	for _, buf := range [][]float32{t.Positions, t.Velocities, t.Pressures} {
+		for i := range buf {
+			buf[i] = math.Float32frombits(le.Uint32(b[r:]))
+			r += 4
+		}
+	}
+	if flags&32768 != 0 {
+		t.precise = make([]float64, 3*n)
+		for i := range t.precise {
+			t.precise[i] = f64()
+		}
+	}
+	if flags&524288 != 0 {
+		t.Sources = append([]uint8(nil), b[r:r+n]...)
+		r += n
+	}
+
+	t.Inherit(s)
+	*s = t
+	return nil
+}
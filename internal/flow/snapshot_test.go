@@ -0,0 +1,214 @@
+package flow_test
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"reflect"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+// snapshotRoundTrip encodes s and restores the snapshot into s
+func snapshotRoundTrip(t *testing.T, s *flow.Simulation) {
+	t.Helper()
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSnapshotElements imports a snapshot of a flow made of elements: the
+// superposition must survive, and the next step match the one without the
+// round trip
+func TestSnapshotElements(t *testing.T) {
+	sp, err := flow.NewSuperposition([]flow.Element{
+		{Kind: flow.ElementUniform, Orientation: [3]float64{1, 0, 0}, Strength: 1},
+		{Kind: flow.ElementSource, Position: [3]float64{-1, 0, 0}, Strength: 2},
+		{Kind: flow.ElementVortex, Position: [3]float64{1, 0.5, 0}, Orientation: [3]float64{0, 0, 1}, Strength: 1.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := []float32{-3, 0.4, 0.1, -2, -1, 0.2, 0.5, 2, -0.3}
+	run := func(roundTrip bool) *flow.Simulation {
+		s := flow.NewSimulation(flow.DefaultConfig())
+		s.Elements = sp
+		if err := s.SetParticles(start, len(start)/3); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Step(0.01); err != nil {
+			t.Fatal(err)
+		}
+		if roundTrip {
+			snapshotRoundTrip(t, s)
+		}
+		if err := s.Step(0.01); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	want, got := run(false), run(true)
+	if got.Elements == nil || !reflect.DeepEqual(got.Elements.Elements(), sp.Elements()) {
+		t.Fatal("the elements were not restored by the snapshot import")
+	}
+	for i := range want.Velocities {
+		if got.Velocities[i] != want.Velocities[i] {
+			t.Errorf("velocity[%d] = %g after the round trip, want %g", i, got.Velocities[i], want.Velocities[i])
+		}
+	}
+}
+
+// TestSnapshotScenario imports a snapshot into a simulation with other
+// elements, motion, schedule, probes and random position: those of the
+// snapshot must replace them, while the runtime settings stay
+func TestSnapshotScenario(t *testing.T) {
+	s := flow.NewSimulation(flow.DefaultConfig())
+	sp, err := flow.NewSuperposition([]flow.Element{
+		{Kind: flow.ElementUniform, Orientation: [3]float64{1, 0, 0}, Strength: 1},
+		{Kind: flow.ElementVortexLine, Position: [3]float64{0, -1, 0}, Orientation: [3]float64{0, 2, 0}, Strength: 0.5, CoreRadius: 0.1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Elements = sp
+	if err := s.SetMotion(&flow.Motion{Type: flow.MotionWaypoints, Points: [][3]float64{{1, 0, 0}, {1, 1, 0}}, Duration: 4, Loop: true}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Schedule, err = flow.Ramp(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Probes.Define(flow.FlowProbe{ID: 4, Center: [3]float64{-3, 0, 0}, Normal: [3]float64{1, 0, 0}, Radius: 0.5, Rings: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Random.SetSeed(77); err != nil {
+		t.Fatal(err)
+	}
+	s.Random.Stream(flow.StreamTurbulence).Uint32()
+	if err := s.Step(0.05); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := flow.NewSimulation(flow.DefaultConfig())
+	if r.Elements, err = flow.NewSuperposition([]flow.Element{{Kind: flow.ElementSource, Strength: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetMotion(&flow.Motion{Type: flow.MotionLinear, Velocity: [3]float64{1, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	if r.Schedule, err = flow.Ramp(5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Probes.Define(flow.FlowProbe{ID: 9, Normal: [3]float64{0, 1, 0}, Radius: 1, Rings: 1}); err != nil {
+		t.Fatal(err)
+	}
+	r.Cutoff = 12
+	if err := r.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if r.Elements == nil || !reflect.DeepEqual(r.Elements.Elements(), sp.Elements()) {
+		t.Error("the elements were not restored")
+	}
+	if !reflect.DeepEqual(r.Motion, s.Motion) {
+		t.Errorf("motion = %+v, want %+v", r.Motion, s.Motion)
+	}
+	if !reflect.DeepEqual(r.Schedule, s.Schedule) {
+		t.Errorf("schedule = %+v, want %+v", r.Schedule, s.Schedule)
+	}
+	if got, want := r.Probes.Definitions(), s.Probes.Definitions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("probes = %+v, want %+v", got, want)
+	}
+	if got, want := r.Random.State(), s.Random.State(); !reflect.DeepEqual(got, want) {
+		t.Errorf("random = %+v, want %+v", got, want)
+	}
+	if r.Cutoff != 12 {
+		t.Errorf("cutoff = %g after the import, want the runtime setting 12 kept", r.Cutoff)
+	}
+
+	for _, cut := range []int{8, 60, 400} {
+		if err := r.UnmarshalBinary(b[:len(b)-cut]); err == nil {
+			t.Errorf("snapshot cut short by %d bytes accepted", cut)
+		}
+	}
+}
+
+// TestSnapshotFlags rejects snapshots announcing blocks this build doesn't
+// know, even with a valid checksum
+func TestSnapshotFlags(t *testing.T) {
+	s := flow.NewSimulation(flow.DefaultConfig())
+	if err := s.SetParticles([]float32{-2, 0.5, 0}, 1); err != nil {
+		t.Fatal(err)
+	}
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	le := binary.LittleEndian
+	le.PutUint32(b[12:], le.Uint32(b[12:])|1<<31)
+	le.PutUint32(b[len(b)-4:], crc32.ChecksumIEEE(b[:len(b)-4]))
+	err = s.UnmarshalBinary(b)
+	if e, ok := err.(*flow.Error); !ok || e.Code != flow.ErrUnsupported {
+		t.Errorf("snapshot with flag bit 31 set: got %v, want %s", err, flow.ErrUnsupported)
+	}
+	if s.Count() != 1 || s.Positions[0] != -2 {
+		t.Error("the failed import changed the simulation")
+	}
+}
+
+// TestSnapshotSources round-trips the sources of dyed particles, so that
+// injection can recycle them after an import, and rejects a snapshot cut
+// short in the sources or with one of them changed
+func TestSnapshotSources(t *testing.T) {
+	s := flow.NewSimulation(flow.DefaultConfig())
+	if err := s.SetParticles([]float32{-4, -3, 2.5, -3, -3, 2.5}, 2); err != nil {
+		t.Fatal(err)
+	}
+	s.Injection.Budget = 12
+	if err := s.Injection.Define(flow.Injector{ID: 3, Position: [3]float64{-4, 1, 1}, Radius: 0.2, Rate: 320, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Step(1.0 / 64); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]uint8(nil), s.ParticleSources()...)
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := flow.NewSimulation(flow.DefaultConfig())
+	if err := r.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	got := r.ParticleSources()
+	if len(got) != len(want) {
+		t.Fatalf("%d sources after the round trip, want %d", len(got), len(want))
+	}
+	dyed := 0
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("source %d = %d, want %d", i, got[i], want[i])
+		}
+		if want[i] != 0 {
+			dyed++
+		}
+	}
+	if dyed != 5 {
+		t.Errorf("%d dyed particles before the round trip, want 5", dyed)
+	}
+
+	if err := r.UnmarshalBinary(b[:len(b)-5]); err == nil {
+		t.Error("snapshot cut short in the sources accepted")
+	}
+	b[len(b)-5] ^= 1
+	if err := r.UnmarshalBinary(b); err == nil {
+		t.Error("snapshot with a changed source accepted")
+	}
+}
@@ -0,0 +1,93 @@
+package flow
+
+import "math"
+
+// CubicSpline returns the M4 cubic spline kernel of smoothing length h at
+// distance r, the usual smoothed particle hydrodynamics kernel: with q =
+// r/h, σ(1 - 3q²/2 + 3q³/4) up to q = 1, σ(2 - q)³/4 up to q = 2 and 0
+// beyond, σ = 1/(πh³) normalizing its integral over space to 1
+func CubicSpline(r, h float64) float64 {
+	q := r / h
+	s := 1 / (math.Pi * h * h * h)
+	switch {
+	case q < 1:
+		return s * (1 - 1.5*q*q + 0.75*q*q*q)
+	case q < 2:
+		t := 2 - q
+		return s * 0.25 * t * t * t
+	}
+	return 0
+}
+
+// checkSmoothingLength rejects smoothing lengths that aren't positive and
+// finite
+func checkSmoothingLength(h float64) error {
+	if !(h > 0) || math.IsInf(h, 0) {
+		return Errorf(ErrBadArguments, "smoothingLength must be a positive finite length, got %g", h)
+	}
+	return nil
+}
+
+// ParticleDensity returns the kernel density estimate at each of count
+// particles of positions: the sum of CubicSpline over the particles within
+// 2h, itself included, the number density of particles of unit mass. A
+// particle with none within 2h gets its own contribution alone, 1/(πh³).
+// The neighbor search is a NeighborGrid of cell 2h, so the cost grows with
+// the particles and their neighbors, not with the square of the count.
+func ParticleDensity[T Float](positions []T, count int, h float64) ([]float32, error) {
+	if err := checkSmoothingLength(h); err != nil {
+		return nil, err
+	}
+	g, err := NewNeighborGrid(positions, count, 2*h)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]float32, count)
+	self := CubicSpline(0, h)
+	_, err = parallel(count, func(from, to int) (int, error) {
+		for i := from; i < to; i++ {
+			p := g.points[i*3 : i*3+3]
+			if p[0]-p[0] != 0 || p[1]-p[1] != 0 || p[2]-p[2] != 0 {
+				out[i] = float32(self)
+				continue
+			}
+			rho := 0.0
+			g.Near(p[0], p[1], p[2], 2*h, func(j int, r2 float64) {
+				rho += CubicSpline(math.Sqrt(r2), h)
+			})
+			out[i] = float32(rho)
+		}
+		return 0, nil
+	})
+	return out, err
+}
+
+// GridDensity returns the kernel density estimate of count particles of
+// positions at the points of grid, x varying fastest as in a 3D texture:
+// the sum of CubicSpline over the particles within 2h, 0 where there are
+// none
+func GridDensity[T Float](positions []T, count int, h float64, grid Grid) ([]float32, error) {
+	if err := checkSmoothingLength(h); err != nil {
+		return nil, err
+	}
+	if err := grid.Validate(); err != nil {
+		return nil, err
+	}
+	g, err := NewNeighborGrid(positions, count, 2*h)
+	if err != nil {
+		return nil, err
+	}
+	points := grid.Positions()
+	out := make([]float32, grid.Len())
+	_, err = parallel(len(out), func(from, to int) (int, error) {
+		for i := from; i < to; i++ {
+			rho := 0.0
+			g.Near(float64(points[i*3]), float64(points[i*3+1]), float64(points[i*3+2]), 2*h, func(j int, r2 float64) {
+				rho += CubicSpline(math.Sqrt(r2), h)
+			})
+			out[i] = float32(rho)
+		}
+		return 0, nil
+	})
+	return out, err
+}
@@ -0,0 +1,107 @@
+package flow
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// validateSplitter checks the splitter plate of o, which only cylinders
+// have
+func (o *ObjectSpec) validateSplitter() error {
+	if l := o.Splitter; l < 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+		return Errorf(ErrBadArguments, "splitter must be non-negative and finite, got %g", l)
+	}
+	if o.Splitter == 0 {
+		return nil
+	}
+	if o.Type != Cylinder {
+		return Errorf(ErrBadArguments, "splitter only applies to cylinders, not to a %v", o.Type)
+	}
+	if o.Strengths.Set != 0 {
+		return Errorf(ErrUnsupported, "a cylinder with a splitter has no strength overrides: its circulation follows from the Kutta condition")
+	}
+	return nil
+}
+
+// crossesSplitter reports whether a particle moving in a straight line
+// from p0 to p1 passes through the splitter plate of o, the strip y = Y
+// from X + Radius to X + Radius + Splitter, extending along z. As for a
+// plate, segments starting in its plane don't cross it.
+func (o *ObjectSpec) crossesSplitter(p0, p1 [3]float64) bool {
+	if o.Splitter == 0 || o.Radius == 0 {
+		return false
+	}
+	d0, d1 := p0[1]-o.Y, p1[1]-o.Y
+	if d0 == 0 || d1 != 0 && (d0 > 0) == (d1 > 0) {
+		return false
+	}
+	s := p0[0] + (p1[0]-p0[0])*d0/(d0-d1) - o.X
+	return s >= o.Radius && s <= o.Radius+o.Splitter
+}
+
+// splitterFrame prepares the splitter plate constants of k: the plate's
+// direction, and the flat plate the transformation J = ζ + R²/ζ maps the
+// cylinder and its splitter onto, from -2R to R + L + R²/(R + L), with the
+// circulation placing a stagnation point on the tip under the Kutta
+// condition
+func (k *kernel) splitterFrame() {
+	o := &k.obj
+	if o.Splitter == 0 || o.Radius == 0 {
+		return
+	}
+	c, s := 1.0, 0.0
+	if k.mode == frameCrossFlow {
+		c, s = k.c, k.s
+	}
+	tip := o.Radius + o.Splitter
+	tip += k.r2 / tip
+	k.split = true
+	k.splitDir = [2]float64{c, -s}
+	k.splitMid, k.splitHalf = (tip-2*o.Radius)/2, (tip+2*o.Radius)/2
+	k.splitCirc = k.u * k.splitHalf * s
+}
+
+// splitter is local for a cylinder with a splitter plate, in the frame of
+// the plate, ζ, where the free stream meets it at β. J = ζ + R²/ζ takes the
+// cylinder to the slit [-2R, 2R] and the splitter on to the rest of the
+// flat plate of center m and half-length h, and s = J - m = σ + h²/4σ takes
+// that plate to the circle |σ| = h/2, where the flow with a clockwise
+// circulation Γ is
+//
+//	W(σ) = U·(σ·e^-iβ + h²·e^iβ/4σ) + iΓ/2π·ln σ
+//
+// the complex velocity being dW/dζ = (dW/dσ)/(ds/dσ)·(dJ/dζ). Γ is 2πUh·sin
+// β, making the tip, σ = h/2, a stagnation point, so the flow leaves the
+// plate smoothly; the root, where dJ/dζ vanishes, is one too, the flow
+// coming to rest in the corners the plate makes with the cylinder. When the
+// plate runs along the stream the flow is that past the bare cylinder.
+func (k *kernel) splitter(x, y float64) (vx, vy float64) {
+	t := k.splitDir
+	zeta := complex(x*t[0]+y*t[1], y*t[0]-x*t[1])
+	s := zeta + complex(k.r2, 0)/zeta - complex(k.splitMid, 0)
+	root := cmplx.Sqrt(s*s - complex(k.splitHalf*k.splitHalf, 0))
+	sigma := (s + root) / 2
+	if other := (s - root) / 2; cmplx.Abs(other) > cmplx.Abs(sigma) {
+		sigma = other
+	}
+	rot := complex(t[0], t[1])
+	a2 := complex(k.splitHalf*k.splitHalf/4, 0)
+	inv2 := 1 / (sigma * sigma)
+	dw := complex(k.u, 0)*(rot-a2*cmplx.Conj(rot)*inv2) + complex(0, k.splitCirc)/sigma
+	w := dw / (1 - a2*inv2) * (1 - complex(k.r2, 0)/(zeta*zeta))
+	u, v := real(w), -imag(w)
+	return u*t[0] - v*t[1], u*t[1] + v*t[0]
+}
+
+// blockSplitter moves the particles that crossed the splitter plate of s
+// since from back where they were: the plate has no inside to stop them
+func (s *Simulation) blockSplitter(from []float32, count int) {
+	o := &s.Config.Object
+	for i := 0; i < count; i++ {
+		p0 := [3]float64{float64(from[i*3]), float64(from[i*3+1]), float64(from[i*3+2])}
+		p1 := [3]float64{float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), float64(s.Positions[i*3+2])}
+		if o.crossesSplitter(p0, p1) {
+			copy(s.Positions[i*3:i*3+3], from[i*3:i*3+3])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestSplitter(t *testing.T) {
+	run(t, (*checker).checkSplitter)
+}
+
+// checkSplitter verifies a cylinder with a splitter plate two radii long:
+// the bare cylinder's flow when the plate runs along the stream, and with
+// the stream turned, the tangency over cylinder and plate, stagnation at
+// its root, the Kutta condition at its tip and particles stopped from
+// crossing it
+func (c *checker) checkSplitter() error {
+	f := checkFlow(flow.Cylinder)
+	f.Object.Splitter = 2
+	bare := checkFlow(flow.Cylinder)
+	along := 0.0
+	for _, p := range [][2]float64{{-1.5, 0.3}, {0.4, 1.2}, {2, 0.1}, {3.5, -0.4}, {10, 3}} {
+		ax, ay, _ := f.VelocityAt(p[0], p[1], 0)
+		bx, by, _ := bare.VelocityAt(p[0], p[1], 0)
+		along = math.Max(along, math.Hypot(ax-bx, ay-by))
+	}
+
+	turned := f
+	turned.Direction = [3]float64{math.Cos(0.5), math.Sin(0.5), 0}
+	res, err := turned.TangencyResidual(720)
+	if err != nil {
+		return err
+	}
+	plate := 0.0
+	for i := 0; i < 200; i++ {
+		x := 1 + 2*(float64(i)+0.5)/200
+		for _, y := range []float64{1e-9, -1e-9} {
+			_, vy, _ := turned.VelocityAt(x, y, 0)
+			plate = math.Max(plate, math.Abs(vy))
+		}
+	}
+	rx, ry, _ := turned.VelocityAt(1+1e-6, 1e-6, 0)
+	root := math.Hypot(rx, ry)
+	_, tip, _ := turned.VelocityAt(3+1e-9, 0, 0)
+	tip = math.Abs(tip)
+
+	// Below the plate the turned stream carries a particle up through it
+	// over a long step; another, above it, moves on
+	config := flow.DefaultConfig()
+	config.Object.Type, config.Object.Splitter = flow.Cylinder, 2
+	config.FreeStream.Direction = turned.Direction
+	s := flow.NewSimulation(config)
+	start := []float32{1.5, -0.3, 0, 2, 0.5, 0}
+	if err := s.SetParticles(start, 2); err != nil {
+		return err
+	}
+	const step = 5
+	vx, vy, _ := turned.VelocityAt(1.5, -0.3, 0)
+	o := f.Object
+	through := o.Crosses([3]float64{1.5, -0.3, 0}, [3]float64{1.5 + vx*step, -0.3 + vy*step, 0})
+	if err := s.Step(step); err != nil {
+		return err
+	}
+	blocked := through && s.Positions[0] == start[0] && s.Positions[1] == start[1] && s.Positions[3] != start[3]
+	crossing := o.Crosses([3]float64{2, -0.1, 0}, [3]float64{2, 0.1, 5}) && !o.Crosses([3]float64{3.5, -0.1, 0}, [3]float64{3.5, 0.1, 0})
+	strengths := f
+	strengths.Object.Strengths = flow.Strengths{Set: flow.StrengthCirculation, Circulation: 1}
+	e, rejected := strengths.Validate().(*flow.Error)
+	rejected = rejected && e.Code == flow.ErrUnsupported
+
+	ok := along < splitterTolerance && res.Max < tangencyTolerance && plate < tangencyTolerance &&
+		root < kuttaTolerance && tip < kuttaTolerance && blocked && crossing && rejected
+	c.report("splitter plate", ok, "along the stream - bare cylinder %.3g; turned |v·n|/U cylinder %.3g, plate %.3g; root |v| %.3g; tip |v·n| %.3g; blocked %v; crossing %v; strengths rejected %v",
+		along, res.Max, plate, root, tip, blocked, crossing, rejected)
+	return nil
+}
@@ -0,0 +1,241 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// ParseSTL reads a binary or ASCII STL file into an unwelded mesh: three
+// fresh vertices per triangle, without normals. Binary files are
+// recognized by their size matching the triangle count in the header,
+// since some exporters start binary headers with "solid" too.
+func ParseSTL(b []byte) (*Mesh, error) {
+	if len(b) >= 84 {
+		n := int(binary.LittleEndian.Uint32(b[80:]))
+		if len(b) == 84+50*n {
+			return parseBinarySTL(b[84:], n), nil
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("solid")) {
+		return parseASCIISTL(b)
+	}
+	return nil, Errorf(ErrBadArguments, "not an STL file: %d bytes, neither binary nor ASCII", len(b))
+}
+
+// parseBinarySTL reads n 50-byte facet records
+func parseBinarySTL(b []byte, n int) *Mesh {
+	m := &Mesh{Positions: make([]float32, 0, n*9), Indices: make([]uint32, 0, n*3)}
+	for t := 0; t < n; t++ {
+		rec := b[t*50:]
+		for v := 0; v < 9; v++ {
+			m.Positions = append(m.Positions, math.Float32frombits(binary.LittleEndian.Uint32(rec[12+4*v:])))
+		}
+		i := uint32(t * 3)
+		m.triangle(i, i+1, i+2)
+	}
+	return m
+}
+
+// parseASCIISTL reads the vertex lines of an ASCII STL; facet normals are
+// ignored and recomputed from the winding
+func parseASCIISTL(b []byte) (*Mesh, error) {
+	m := &Mesh{}
+	for line, rest := 1, b; len(rest) > 0; line++ {
+		var l []byte
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			l, rest = rest[:i], rest[i+1:]
+		} else {
+			l, rest = rest, nil
+		}
+		f := bytes.Fields(l)
+		if len(f) == 0 || string(f[0]) != "vertex" {
+			continue
+		}
+		if len(f) != 4 {
+			return nil, Errorf(ErrBadArguments, "STL line %d: vertex needs 3 coordinates", line)
+		}
+		for _, s := range f[1:] {
+			x, err := strconv.ParseFloat(string(s), 32)
+			if err != nil {
+				return nil, Errorf(ErrBadArguments, "STL line %d: bad coordinate %q", line, s)
+			}
+			m.Positions = append(m.Positions, float32(x))
+		}
+	}
+	if len(m.Positions)%9 != 0 {
+		return nil, Errorf(ErrBadArguments, "STL has %d vertices, not a multiple of 3", len(m.Positions)/3)
+	}
+	for i := uint32(0); i < uint32(len(m.Positions)/3); i += 3 {
+		m.triangle(i, i+1, i+2)
+	}
+	return m, nil
+}
+
+// Weld merges vertices that fall into the same cell of a grid of spacing
+// tol, keeping the first, and drops triangles that become degenerate.
+// Vertices closer than tol that straddle a cell boundary are not merged.
+func (m *Mesh) Weld(tol float64) {
+	if !(tol > 0) {
+		tol = 1e-6
+	}
+	m.cluster(tol)
+}
+
+// cluster replaces every vertex by the first vertex in its grid cell
+func (m *Mesh) cluster(cell float64) {
+	type key [3]int64
+	index := make(map[key]uint32)
+	remap := make([]uint32, len(m.Positions)/3)
+	var positions []float32
+	for v := range remap {
+		p := m.Positions[v*3 : v*3+3]
+		k := key{int64(math.Floor(float64(p[0]) / cell)), int64(math.Floor(float64(p[1]) / cell)), int64(math.Floor(float64(p[2]) / cell))}
+		i, ok := index[k]
+		if !ok {
+			i = uint32(len(positions) / 3)
+			index[k] = i
+			positions = append(positions, p...)
+		}
+		remap[v] = i
+	}
+	indices := m.Indices[:0]
+	for t := 0; t < len(m.Indices); t += 3 {
+		a, b, c := remap[m.Indices[t]], remap[m.Indices[t+1]], remap[m.Indices[t+2]]
+		if a != b && b != c && a != c {
+			indices = append(indices, a, b, c)
+		}
+	}
+	m.Positions, m.Indices, m.Normals = positions, indices, nil
+}
+
+// Decimate coarsens the mesh by vertex clustering until it has at most
+// target triangles, searching for the smallest cell size that gets there.
+// The result is approximate and may open small holes in thin features.
+func (m *Mesh) Decimate(target int) {
+	if target < 1 || len(m.Indices)/3 <= target {
+		return
+	}
+	st := m.Stats()
+	lo, hi := 0.0, 0.0
+	for a := 0; a < 3; a++ {
+		hi = math.Max(hi, float64(st.Max[a]-st.Min[a]))
+	}
+	best := m.clone()
+	best.cluster(hi)
+	for iter := 0; iter < 30; iter++ {
+		mid := (lo + hi) / 2
+		c := m.clone()
+		c.cluster(mid)
+		if len(c.Indices)/3 <= target {
+			best, hi = c, mid
+		} else {
+			lo = mid
+		}
+	}
+	*m = *best
+}
+
+// clone returns a deep copy of the positions and indices
+func (m *Mesh) clone() *Mesh {
+	return &Mesh{
+		Positions: append([]float32(nil), m.Positions...),
+		Indices:   append([]uint32(nil), m.Indices...),
+	}
+}
+
+// ComputeNormals sets area-weighted vertex normals from the winding
+func (m *Mesh) ComputeNormals() {
+	n := make([]float64, len(m.Positions))
+	for t := 0; t < len(m.Indices); t += 3 {
+		a, b, c := m.Indices[t]*3, m.Indices[t+1]*3, m.Indices[t+2]*3
+		var e1, e2 [3]float64
+		for k := uint32(0); k < 3; k++ {
+			e1[k] = float64(m.Positions[b+k] - m.Positions[a+k])
+			e2[k] = float64(m.Positions[c+k] - m.Positions[a+k])
+		}
+		cross := [3]float64{e1[1]*e2[2] - e1[2]*e2[1], e1[2]*e2[0] - e1[0]*e2[2], e1[0]*e2[1] - e1[1]*e2[0]}
+		for _, v := range []uint32{a, b, c} {
+			for k := uint32(0); k < 3; k++ {
+				n[v+k] += cross[k]
+			}
+		}
+	}
+	m.Normals = make([]float32, len(n))
+	for v := 0; v < len(n); v += 3 {
+		l := math.Sqrt(n[v]*n[v] + n[v+1]*n[v+1] + n[v+2]*n[v+2])
+		if l > 0 {
+			m.Normals[v], m.Normals[v+1], m.Normals[v+2] = float32(n[v]/l), float32(n[v+1]/l), float32(n[v+2]/l)
+		}
+	}
+}
+
+// MeshStats summarizes the topology of a mesh
+type MeshStats struct {
+	Triangles int
+	Vertices  int
+	Min, Max  [3]float32
+
+	OpenEdges         int     // Edges used by a single triangle
+	NonManifoldEdges  int     // Edges used by more than two triangles
+	InconsistentEdges int     // Edges whose two triangles wind the same way
+	Volume            float64 // Signed volume; negative if the normals point inward
+}
+
+// Closed reports whether the mesh is a closed, consistently wound surface
+func (s *MeshStats) Closed() bool {
+	return s.OpenEdges == 0 && s.NonManifoldEdges == 0 && s.InconsistentEdges == 0
+}
+
+// Stats computes the mesh statistics
+func (m *Mesh) Stats() MeshStats {
+	st := MeshStats{Triangles: len(m.Indices) / 3, Vertices: len(m.Positions) / 3}
+	for a := 0; a < 3; a++ {
+		st.Min[a], st.Max[a] = float32(math.Inf(1)), float32(math.Inf(-1))
+	}
+	for v := 0; v < len(m.Positions); v += 3 {
+		for a := 0; a < 3; a++ {
+			st.Min[a] = float32(math.Min(float64(st.Min[a]), float64(m.Positions[v+a])))
+			st.Max[a] = float32(math.Max(float64(st.Max[a]), float64(m.Positions[v+a])))
+		}
+	}
+	if st.Vertices == 0 {
+		st.Min, st.Max = [3]float32{}, [3]float32{}
+	}
+
+	// Count each undirected edge's uses in either direction
+	type edge [2]uint32
+	uses := make(map[edge][2]int)
+	for t := 0; t < len(m.Indices); t += 3 {
+		tri := m.Indices[t : t+3]
+		for k := 0; k < 3; k++ {
+			a, b := tri[k], tri[(k+1)%3]
+			if a < b {
+				u := uses[edge{a, b}]
+				u[0]++
+				uses[edge{a, b}] = u
+			} else {
+				u := uses[edge{b, a}]
+				u[1]++
+				uses[edge{b, a}] = u
+			}
+		}
+		p := func(i uint32) [3]float64 {
+			return [3]float64{float64(m.Positions[i*3]), float64(m.Positions[i*3+1]), float64(m.Positions[i*3+2])}
+		}
+		a, b, c := p(tri[0]), p(tri[1]), p(tri[2])
+		st.Volume += (a[0]*(b[1]*c[2]-b[2]*c[1]) - a[1]*(b[0]*c[2]-b[2]*c[0]) + a[2]*(b[0]*c[1]-b[1]*c[0])) / 6
+	}
+	for _, u := range uses {
+		switch total := u[0] + u[1]; {
+		case total == 1:
+			st.OpenEdges++
+		case total > 2:
+			st.NonManifoldEdges++
+		case u[0] != 1:
+			st.InconsistentEdges++
+		}
+	}
+	return st
+}
@@ -0,0 +1,58 @@
+package flow
+
+import "math"
+
+// Polyline is a sequence of points [x1,y1,z1,x2,y2,z2,...]
+type Polyline []float32
+
+// Len returns the number of points
+func (p Polyline) Len() int { return len(p) / 3 }
+
+// Streamline traces the streamline through seed downstream with fixed
+// arc-length steps of h, using RK4 on the unit velocity direction. It stops
+// after maxSteps steps, inside the object or on crossing a plate, or where
+// the flow stagnates.
+// The seed is the first point.
+func (f *Flow) Streamline(seed [3]float64, h float64, maxSteps int) (Polyline, error) {
+	if !(h > 0) || math.IsInf(h, 0) {
+		return nil, Errorf(ErrBadArguments, "streamline step must be positive, got %g", h)
+	}
+	if maxSteps < 0 {
+		return nil, Errorf(ErrBadArguments, "maxSteps must be non-negative, got %d", maxSteps)
+	}
+	dir := func(p [3]float64) ([3]float64, bool) {
+		vx, vy, vz := f.VelocityAt(p[0], p[1], p[2])
+		s := math.Sqrt(vx*vx + vy*vy + vz*vz)
+		if s < 1e-12 {
+			return [3]float64{}, false
+		}
+		return [3]float64{vx / s, vy / s, vz / s}, true
+	}
+	along := func(p, d [3]float64, t float64) [3]float64 {
+		return [3]float64{p[0] + t*d[0], p[1] + t*d[1], p[2] + t*d[2]}
+	}
+
+	p := seed
+	line := Polyline{float32(p[0]), float32(p[1]), float32(p[2])}
+	if f.Object.Contains(p[0], p[1], p[2]) {
+		return line, nil
+	}
+	for n := 0; n < maxSteps; n++ {
+		k1, ok1 := dir(p)
+		k2, ok2 := dir(along(p, k1, h/2))
+		k3, ok3 := dir(along(p, k2, h/2))
+		k4, ok4 := dir(along(p, k3, h))
+		if !(ok1 && ok2 && ok3 && ok4) {
+			break
+		}
+		prev := p
+		for a := 0; a < 3; a++ {
+			p[a] += h / 6 * (k1[a] + 2*k2[a] + 2*k3[a] + k4[a])
+		}
+		if f.Object.Crosses(prev, p) {
+			break
+		}
+		line = append(line, float32(p[0]), float32(p[1]), float32(p[2]))
+	}
+	return line, nil
+}
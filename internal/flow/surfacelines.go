@@ -0,0 +1,134 @@
+package flow
+
+import "math"
+
+// DefaultSurfaceStep is the arc length of the steps of a surface
+// streamline, in object radii, unless another is given
+const DefaultSurfaceStep = 0.02
+
+// MaxSurfaceLines bounds the number of seeds of SurfaceStreamlines
+const MaxSurfaceLines = 4096
+
+// surfaceStagnation is the surface speed, relative to the onset speed,
+// below which a surface streamline has reached a stagnation point
+const surfaceStagnation = 1e-6
+
+// project returns the point of the surface of o nearest to p, just outside
+// it, and the outward normal there; ok is false at the center of a sphere
+// or on the axis of a cylinder, where there is no nearest point
+func (o *ObjectSpec) project(p [3]float64) (q, normal [3]float64, ok bool) {
+	x, y, z := p[0]-o.X, p[1]-o.Y, p[2]-o.Z
+	if o.Type == Cylinder {
+		z = 0
+	}
+	d := math.Sqrt(x*x + y*y + z*z)
+	if d == 0 {
+		return p, normal, false
+	}
+	normal = [3]float64{x / d, y / d, z / d}
+	r := o.Radius * (1 + surfaceOffset)
+	q = [3]float64{o.X + r*normal[0], o.Y + r*normal[1], o.Z + r*normal[2]}
+	if o.Type == Cylinder {
+		q[2] = p[2]
+	}
+	return q, normal, true
+}
+
+// surfaceDirection returns the projection of p on the surface of the
+// object of f and the unit direction there of the velocity tangent to the
+// surface, or false where its speed is below stagnation
+func (f *Flow) surfaceDirection(p [3]float64, stagnation float64) (q, t [3]float64, ok bool) {
+	q, n, ok := f.Object.project(p)
+	if !ok {
+		return q, t, false
+	}
+	vx, vy, vz := f.VelocityAt(q[0], q[1], q[2])
+	vn := vx*n[0] + vy*n[1] + vz*n[2]
+	t = [3]float64{vx - vn*n[0], vy - vn*n[1], vz - vn*n[2]}
+	s := math.Sqrt(t[0]*t[0] + t[1]*t[1] + t[2]*t[2])
+	if s <= stagnation {
+		return q, t, false
+	}
+	return q, [3]float64{t[0] / s, t[1] / s, t[2] / s}, true
+}
+
+// SurfaceStreamlines traces the limiting streamlines of the inviscid flow
+// on the surface of a sphere or cylinder, the lines its tangential
+// velocity follows, which an oil film would show, from n seeds spread
+// over the surface as by SurfacePoint. Each line is traced downstream and
+// upstream of its seed with RK4 on the unit tangential direction in arc
+// length steps of h, at most maxSteps each way, every stage projected back
+// onto the surface, and is returned in the flow's direction. A direction
+// ends at a stagnation point, where the surface speed drops below 1e-6 of
+// the onset speed or the direction turns back within a step, so lines
+// around a sphere run from the front stagnation point to the rear one, and
+// spiral between them in a swirling free stream. The cylinder's lines
+// follow its surface along z too.
+func (f *Flow) SurfaceStreamlines(n, maxSteps int, h float64) ([]Polyline, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	o := &f.Object
+	if o.Radius == 0 || (o.Type != Sphere && o.Type != Cylinder) {
+		return nil, Errorf(ErrUnsupported, "surface streamlines are traced on spheres and cylinders, not a %v", o.Type)
+	}
+	if n < 1 || n > MaxSurfaceLines {
+		return nil, Errorf(ErrBadArguments, "nSeeds must be between 1 and %d, got %d", MaxSurfaceLines, n)
+	}
+	if maxSteps < 0 {
+		return nil, Errorf(ErrBadArguments, "maxSteps must be non-negative, got %d", maxSteps)
+	}
+	if !(h > 0) || math.IsInf(h, 0) {
+		return nil, Errorf(ErrBadArguments, "surface streamline step must be positive, got %g", h)
+	}
+	stagnation := surfaceStagnation * math.Sqrt(f.onset2())
+	along := func(p, d [3]float64, t float64) [3]float64 {
+		return [3]float64{p[0] + t*d[0], p[1] + t*d[1], p[2] + t*d[2]}
+	}
+	dot := func(a, b [3]float64) float64 {
+		return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+	}
+	// trace returns the points after p along sign times the flow
+	trace := func(p [3]float64, sign float64) []float32 {
+		var points []float32
+		var last [3]float64
+		for i := 0; i < maxSteps; i++ {
+			var k [4][3]float64
+			var ok [4]bool
+			p, k[0], ok[0] = f.surfaceDirection(p, stagnation)
+			for s, t := range []float64{h / 2, h / 2, h} {
+				k[s] = [3]float64{sign * k[s][0], sign * k[s][1], sign * k[s][2]}
+				_, k[s+1], ok[s+1] = f.surfaceDirection(along(p, k[s], t), stagnation)
+			}
+			k[3] = [3]float64{sign * k[3][0], sign * k[3][1], sign * k[3][2]}
+			turned := i > 0 && dot(k[0], last) < 0
+			for s := 1; s < 4; s++ {
+				turned = turned || dot(k[s], k[0]) < 0
+			}
+			if !(ok[0] && ok[1] && ok[2] && ok[3]) || turned {
+				break
+			}
+			for a := 0; a < 3; a++ {
+				p[a] += h / 6 * (k[0][a] + 2*k[1][a] + 2*k[2][a] + k[3][a])
+			}
+			p, _, _ = o.project(p)
+			last = k[0]
+			points = append(points, float32(p[0]), float32(p[1]), float32(p[2]))
+		}
+		return points
+	}
+
+	lines := make([]Polyline, n)
+	for i := range lines {
+		seed, _ := o.SurfacePoint(i, n)
+		seed, _, _ = o.project(seed)
+		up, down := trace(seed, -1), trace(seed, 1)
+		line := make(Polyline, 0, len(up)+3+len(down))
+		for j := len(up) - 3; j >= 0; j -= 3 {
+			line = append(line, up[j:j+3]...)
+		}
+		line = append(line, float32(seed[0]), float32(seed[1]), float32(seed[2]))
+		lines[i] = append(line, down...)
+	}
+	return lines, nil
+}
@@ -0,0 +1,73 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestSurfaceStreamlines(t *testing.T) {
+	run(t, (*checker).checkSurfaceStreamlines)
+}
+
+// checkSurfaceStreamlines verifies the limiting streamlines of a sphere:
+// every point on the surface, every line from within two steps of the
+// front stagnation point to within two of the rear one in a meridian
+// plane, and turning about the stream axis in a swirling free stream
+func (c *checker) checkSurfaceStreamlines() error {
+	f := checkFlow(flow.Sphere)
+	f.Object.X, f.Object.Y, f.Object.Z = 1, -2, 0.5
+	const n, h = 32, 0.02
+	// turn returns the angle the points of l go through about the x axis
+	// of the sphere, and whether they stay on its surface
+	turn := func(l flow.Polyline) (float64, bool) {
+		on := true
+		total, last := 0.0, 0.0
+		for i := 0; i < l.Len(); i++ {
+			x, y, z := float64(l[i*3])-1, float64(l[i*3+1])+2, float64(l[i*3+2])-0.5
+			on = on && math.Abs(math.Sqrt(x*x+y*y+z*z)-1) < onSurfaceTolerance
+			a := math.Atan2(z, y)
+			if i > 0 {
+				total += math.Remainder(a-last, 2*math.Pi)
+			}
+			last = a
+		}
+		return total, on
+	}
+	lines, err := f.SurfaceStreamlines(n, 1000, h)
+	if err != nil {
+		return err
+	}
+	ends, on, meridian := true, true, 0.0
+	for _, l := range lines {
+		k := len(l) - 3
+		ends = ends && float64(l[0]) < 1-(1-2*h) && float64(l[k]) > 1+(1-2*h)
+		_, ok := turn(l)
+		on = on && ok
+		// Distance from the meridian plane through the widest point
+		var y0, z0 float64
+		for i := 0; i < l.Len(); i++ {
+			if y, z := float64(l[i*3+1])+2, float64(l[i*3+2])-0.5; math.Hypot(y, z) > math.Hypot(y0, z0) {
+				y0, z0 = y, z
+			}
+		}
+		r := math.Hypot(y0, z0)
+		for i := 0; i < l.Len(); i++ {
+			y, z := float64(l[i*3+1])+2, float64(l[i*3+2])-0.5
+			meridian = math.Max(meridian, math.Abs(y*z0-z*y0)/r)
+		}
+	}
+	f.Swirl = flow.Swirl{Type: flow.SwirlSolid, Number: 0.5, Radius: 1}
+	if lines, err = f.SurfaceStreamlines(n, 1000, h); err != nil {
+		return err
+	}
+	spiral := math.Inf(1)
+	for _, l := range lines {
+		t, ok := turn(l)
+		on, spiral = on && ok, math.Min(spiral, t)
+	}
+	c.report("surface streamlines", ends && on && meridian < onSurfaceTolerance && spiral > 1,
+		"stagnation to stagnation %v, on the surface %v; off the meridian %.3g; least turn with swirl %.3g", ends, on, meridian, spiral)
+	return nil
+}
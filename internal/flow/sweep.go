@@ -0,0 +1,167 @@
+package flow
+
+import "math"
+
+// MaxSweepSteps bounds the number of steps of an angle of attack sweep
+const MaxSweepSteps = 4096
+
+// LiftCurve is the lift and quarter chord moment coefficients of a section
+// over a sweep of its angle of attack, the moment nose up positive, with
+// the residual of the solve at each angle: the largest normal velocity
+// left at the control points relative to the onset speed, 0 for the
+// closed-form solutions. SectionCL holds the lift coefficient of each
+// section of a Tandem, indexed by section then angle.
+type LiftCurve struct {
+	Alpha     []float64 // Radians
+	CL, CM    []float64
+	Residual  []float64
+	SectionCL [][]float64
+}
+
+// SweepAngles returns the steps+1 angles evenly spaced from start to end
+func SweepAngles(start, end float64, steps int) ([]float64, error) {
+	if math.IsNaN(start) || math.IsInf(start, 0) || math.IsNaN(end) || math.IsInf(end, 0) {
+		return nil, Errorf(ErrBadArguments, "sweep angles must be finite, got %g and %g", start, end)
+	}
+	if steps < 1 || steps > MaxSweepSteps {
+		return nil, Errorf(ErrBadArguments, "nSteps must be between 1 and %d, got %d", MaxSweepSteps, steps)
+	}
+	alphas := make([]float64, steps+1)
+	for i := range alphas {
+		alphas[i] = start + (end-start)*float64(i)/float64(steps)
+	}
+	return alphas, nil
+}
+
+// newLiftCurve returns a curve over alphas with the coefficients to fill
+func newLiftCurve(alphas []float64) LiftCurve {
+	n := len(alphas)
+	return LiftCurve{Alpha: append([]float64(nil), alphas...), CL: make([]float64, n), CM: make([]float64, n), Residual: make([]float64, n)}
+}
+
+// LiftCurve returns the lift curve of the thin airfoil of s over alphas.
+// Only A0 depends on the angle of attack, so the curve is the straight
+// line cl = 2π(α - α_L0) with the constant moment of the solution; the
+// coefficients being exact, there is no residual.
+func (s *ThinAirfoilSolution) LiftCurve(alphas []float64) LiftCurve {
+	c := newLiftCurve(alphas)
+	for i, a := range alphas {
+		c.CL[i] = 2 * math.Pi * (a - s.ZeroLift)
+		c.CM[i] = s.CMQuarter
+	}
+	return c
+}
+
+// LiftCurve returns the lift curve of f's plate or elliptic cylinder over
+// alphas, the values of Object.Alpha, re-solving the Kutta circulation of
+// each incidence on the cross-flow: cl = 2π(1 + b/a)·sin α from the
+// circulation, 2π·sin α for the plate and 0 for an elliptic cylinder
+// without Kutta, and the moment of the exact solution, the Munk moment
+// π/2·(1 - b²/a²)·sin α·cos α about the center, less the lift's about the
+// quarter chord, which leaves a plate none. The coefficients are relative
+// to ½ρU²c with the onset speed of the cross-flow and the chord 2·Radius.
+func (f *Flow) LiftCurve(alphas []float64) (LiftCurve, error) {
+	c := newLiftCurve(alphas)
+	if f.Elements != nil {
+		return c, Errorf(ErrUnsupported, "a lift curve needs the object's own flow, not elements built over it")
+	}
+	if t := f.Object.Type; t != Plate && t != EllipticCylinder {
+		return c, Errorf(ErrUnsupported, "a lift curve needs a plate, an elliptic cylinder, a thin airfoil or a tandem, not a %v", t)
+	}
+	for i, a := range alphas {
+		g := *f
+		g.Object.Alpha = a
+		if err := g.Validate(); err != nil {
+			return c, err
+		}
+		k := g.kernel()
+		if err := checkFreeStream(k.u); err != nil {
+			return c, err
+		}
+		sin, cos := k.ps, k.pc
+		ratio := 0.0
+		if g.Object.Type == Plate {
+			c.CL[i] = 2 * math.Pi * sin
+		} else {
+			b := g.Object.semiMinor() / g.Object.Radius
+			// Γ = 2π·jcirc over ½·U·c
+			c.CL[i] = 4 * math.Pi * k.jcirc / (k.u * 2 * g.Object.Radius)
+			ratio = b * b
+		}
+		c.CM[i] = math.Pi/2*(1-ratio)*sin*cos - c.CL[i]*cos/4
+	}
+	return c, nil
+}
+
+// LiftCurve returns the lift curve of t in the free stream of f over
+// alphas, the angles of attack of its first section: the sections pitch
+// together as one rigid configuration, gap and stagger turning with them,
+// as the wing and tail of an aircraft do. Turning the configuration is
+// turning the onset flow the other way, which leaves the influence matrix
+// as it is, so it is eliminated once for every angle. cl and cm are those
+// of the whole configuration, relative to ½ρU²c with the onset speed and
+// chord of the first section, the moment about its quarter chord.
+func (t *Tandem) LiftCurve(f *Flow, alphas []float64) (LiftCurve, error) {
+	c := newLiftCurve(alphas)
+	sys, err := t.system(f)
+	if err != nil {
+		return c, err
+	}
+	bs := make([][]float64, len(alphas))
+	for i, a := range alphas {
+		s, co := math.Sincos(a - t.Sections[0].Alpha)
+		bs[i] = sys.rhs(sys.cx*co-sys.cy*s, sys.cy*co+sys.cx*s)
+	}
+	rhs := make([][]float64, len(bs))
+	for i := range bs {
+		rhs[i] = append([]float64(nil), bs[i]...)
+	}
+	if !eliminate(sys.matrix(), bs) {
+		return c, Errorf(ErrBadArguments, "tandem: the sections overlap, leaving no unique circulation")
+	}
+
+	np := t.Panels
+	first := &sys.sections[0]
+	u0, c0 := sys.onset[0], first.Chord
+	quarter := first.point(0.25, sys.cx, sys.cy)
+	c.SectionCL = make([][]float64, len(sys.sections))
+	for j := range c.SectionCL {
+		c.SectionCL[j] = make([]float64, len(alphas))
+	}
+	speed := 0.0
+	for _, u := range sys.onset {
+		speed = math.Max(speed, math.Abs(u))
+	}
+	for i, a := range alphas {
+		gamma := bs[i]
+		s, co := math.Sincos(a - t.Sections[0].Alpha)
+		// The lift direction, normal to the turned cross-flow
+		lx, ly := -(sys.cy*co + sys.cx*s), sys.cx*co-sys.cy*s
+		var lift, moment float64
+		for j, p := range sys.panels {
+			l := sys.onset[j/np] * gamma[j]
+			lift += l
+			rx, ry := p.vortex[0]-quarter[0], p.vortex[1]-quarter[1]
+			moment += rx*l*ly - ry*l*lx
+			if u := sys.onset[j/np]; u != 0 {
+				c.SectionCL[j/np][i] += 2 * gamma[j] / (u * sys.sections[j/np].Chord)
+			}
+		}
+		if u0 != 0 {
+			c.CL[i] = 2 * lift / (u0 * u0 * c0)
+			c.CM[i] = -2 * moment / (u0 * u0 * c0 * c0)
+		}
+		res := 0.0
+		for r, row := range sys.m {
+			v := -rhs[i][r]
+			for k, x := range row {
+				v += x * gamma[k]
+			}
+			res = math.Max(res, math.Abs(v))
+		}
+		if speed != 0 {
+			c.Residual[i] = res / speed
+		}
+	}
+	return c, nil
+}
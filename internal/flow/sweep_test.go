@@ -0,0 +1,86 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestAlphaSweep(t *testing.T) {
+	run(t, (*checker).checkAlphaSweep)
+}
+
+// checkAlphaSweep verifies the lift curves against solving each angle on
+// its own: the NACA 2412 thin airfoil, a tandem of two plates pitched
+// together, whose single elimination must leave no residual, and the
+// elliptic cylinder, against the cl and cm integrated from its Cp
+func (c *checker) checkAlphaSweep() error {
+	alphas, err := flow.SweepAngles(-4*math.Pi/180, 12*math.Pi/180, 8)
+	if err != nil {
+		return err
+	}
+	f := checkFlow(flow.Cylinder)
+	f.Object.Radius = 0
+	naca := flow.DefaultThinAirfoil()
+	naca.M, naca.P = 0.02, 0.4
+	s, err := naca.Solve(&f)
+	if err != nil {
+		return err
+	}
+	curve := s.LiftCurve(alphas)
+	thin := 0.0
+	for i, a := range alphas {
+		naca.Alpha = a
+		if s, err = naca.Solve(&f); err != nil {
+			return err
+		}
+		thin = math.Max(thin, math.Max(math.Abs(curve.CL[i]-s.CL), math.Abs(curve.CM[i]-s.CMQuarter)))
+	}
+
+	plate := flow.DefaultThinAirfoil()
+	plate.CoreRadius = 0.05
+	t := flow.Tandem{Sections: []flow.ThinAirfoil{plate, plate}, Panels: 4, Relative: true, Gap: 0.5, Stagger: 2}
+	if curve, err = t.LiftCurve(&f, alphas); err != nil {
+		return err
+	}
+	tandem, residual := 0.0, 0.0
+	for i, a := range alphas {
+		// The same configuration turned by a: the second section pitched
+		// with the first and moved round its leading edge
+		turned := t
+		turned.Sections = []flow.ThinAirfoil{plate, plate}
+		turned.Sections[0].Alpha, turned.Sections[1].Alpha = a, a
+		sin, cos := math.Sincos(a)
+		turned.Stagger, turned.Gap = 2*cos+0.5*sin, 0.5*cos-2*sin
+		s, err := turned.Solve(&f)
+		if err != nil {
+			return err
+		}
+		for j, sec := range s.Sections {
+			tandem = math.Max(tandem, math.Abs(curve.SectionCL[j][i]-sec.CL))
+		}
+		residual = math.Max(residual, curve.Residual[i])
+	}
+
+	e := checkFlow(flow.EllipticCylinder)
+	e.Object.SemiMinor, e.Object.Kutta = 0.12, true
+	if curve, err = e.LiftCurve(alphas); err != nil {
+		return err
+	}
+	ellipse := 0.0
+	for i, a := range alphas {
+		g := e
+		g.Object.Alpha = a
+		d, err := g.CpDistribution(flow.DefaultCpPoints)
+		if err != nil {
+			return err
+		}
+		ellipse = math.Max(ellipse, math.Max(math.Abs(curve.CL[i]-d.CL), math.Abs(curve.CM[i]-d.CM)))
+	}
+	// Relative to the lift slope 2π
+	ellipse /= 2 * math.Pi
+	ok := thin < sweepTolerance && tandem < sweepTolerance && residual < sweepTolerance && ellipse < forceLiftTolerance
+	c.report("alpha sweep", ok, "thin airfoil |Δ| %.3g; tandem |Δcl| %.3g, residual %.3g; ellipse against Cp |Δ| %.3g", thin, tandem, residual, ellipse)
+	return nil
+}
@@ -0,0 +1,140 @@
+package flow
+
+import "math"
+
+// Swirl models
+const (
+	SwirlSolid = "solid"
+	SwirlFree  = "free"
+)
+
+// Swirl is a tangential component of the onset flow about the free stream
+// axis through the object's center, right-handed about the free stream
+// direction (see Flow.Swirl). Number is the swirl number, the tangential
+// speed at distance Radius from the axis over the free stream speed U at
+// the object's center. Within Radius both models turn as a solid body,
+// u_θ = Number·U·r/Radius; beyond it
+//
+//   - solid, or the empty type: u_θ is clamped at Number·U
+//   - free: a free vortex, u_θ = Number·U·Radius/r
+//
+// so the free model is a Rankine vortex. Number 0 is no swirl.
+type Swirl struct {
+	Type   string
+	Number float64
+	Radius float64
+}
+
+// DefaultSwirl returns the swirl of DefaultConfig: none, with the defaults
+// of the other parameters
+func DefaultSwirl() Swirl {
+	return Swirl{Type: SwirlSolid, Radius: 1}
+}
+
+// Validate checks the parameters of w
+func (w *Swirl) Validate() error {
+	if math.IsNaN(w.Number) || math.IsInf(w.Number, 0) || math.IsNaN(w.Radius) || math.IsInf(w.Radius, 0) {
+		return Errorf(ErrBadArguments, "freeStream.swirl number and radius must be finite, got %g and %g", w.Number, w.Radius)
+	}
+	if w.Type != "" && w.Type != SwirlSolid && w.Type != SwirlFree {
+		return Errorf(ErrBadArguments, "freeStream.swirl.type must be %q or %q, got %q", SwirlSolid, SwirlFree, w.Type)
+	}
+	if w.Number != 0 && !(w.Radius > 0) {
+		return Errorf(ErrBadArguments, "freeStream.swirl.radius must be positive, got %g", w.Radius)
+	}
+	return nil
+}
+
+// rate returns u_θ/r at distance r from the axis, for the free stream
+// speed u: the angular velocity the swirl turns at there
+func (w *Swirl) rate(r, u float64) float64 {
+	r = math.Max(r, w.Radius)
+	if w.Type == SwirlFree {
+		return w.Number * u * w.Radius / (r * r)
+	}
+	return w.Number * u / r
+}
+
+// pressure returns the difference at distance r from the axis between the
+// pressure of the swirl in radial equilibrium, dp/dr = ρu_θ²/r, and what
+// Bernoulli's equation with a single constant gives, -½ρu_θ², for the free
+// stream speed u and density rho. The difference is the change of
+// Bernoulli's constant across the rotational part of the swirl, ρ∫u_θ·ω dr
+// with ω the axial vorticity: it is 0 where the free vortex is irrotational
+// and on the axis of the solid model, and grows with ln r beyond the
+// solid model's radius.
+func (w *Swirl) pressure(r, u, rho float64) float64 {
+	c := w.Number * u
+	if r < w.Radius {
+		if w.Type == SwirlFree {
+			return rho * c * c * (r*r/(w.Radius*w.Radius) - 1)
+		}
+		return rho * c * c * r * r / (w.Radius * w.Radius)
+	}
+	if w.Type == SwirlFree {
+		return 0
+	}
+	return rho * c * c * (1 + math.Log(r/w.Radius))
+}
+
+// Encode returns w in generic form
+func (w *Swirl) Encode() map[string]interface{} {
+	t := w.Type
+	if t == "" {
+		t = SwirlSolid
+	}
+	return map[string]interface{}{"type": t, "number": w.Number, "radius": w.Radius}
+}
+
+// DecodeSwirl decodes a swirl from generic values (see DecodeConfig):
+// {type = "solid", number = 0, radius = 1}
+func DecodeSwirl(v interface{}) (Swirl, error) {
+	w := DefaultSwirl()
+	m, err := section(v, "freeStream.swirl", "type", "number", "radius")
+	if err != nil {
+		return w, err
+	}
+	if v, ok := m["type"]; ok {
+		if w.Type, ok = v.(string); !ok {
+			return w, Errorf(ErrBadArguments, "freeStream.swirl.type must be a string")
+		}
+	}
+	if err := numberKey(m, "freeStream.swirl", "number", &w.Number); err != nil {
+		return w, err
+	}
+	if err := numberKey(m, "freeStream.swirl", "radius", &w.Radius); err != nil {
+		return w, err
+	}
+	return w, w.Validate()
+}
+
+// AddSwirlPressures adds to the pressures in dst, computed by PressuresFor
+// from velocities that include the swirl of f, the change of Bernoulli's
+// constant across the swirl at the positions of the particles, so that
+// the total is the swirl's radial equilibrium pressure far from the
+// object. The body disturbance is taken to carry the constant of the
+// swirl at each point along, which holds where it is small. It does
+// nothing without a swirl.
+func AddSwirlPressures[Out, In Float](dst []Out, positions []In, count int, f *Flow, m Mask) error {
+	if f.Swirl.Number == 0 {
+		return nil
+	}
+	if err := CheckBuffer("positions", len(positions), count, 3); err != nil {
+		return err
+	}
+	if err := CheckBuffer("output", len(dst), count, 1); err != nil {
+		return err
+	}
+	if err := m.check(count); err != nil {
+		return err
+	}
+	k := f.kernel()
+	for i := 0; i < count; i++ {
+		if m.skip(i) {
+			continue
+		}
+		px, py, pz := float64(positions[i*3]), float64(positions[i*3+1]), float64(positions[i*3+2])
+		dst[i] = Out(float64(dst[i]) + k.swirl.pressure(k.axisDistance(px, py, pz), k.su, k.rho))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestSwirl(t *testing.T) {
+	forTypes(t, (*checker).checkSwirl, flow.Sphere, flow.Cylinder, flow.Airfoil)
+}
+
+// checkSwirl verifies that a swirl number of 0 gives the unswirled flow
+// bit for bit and that, without the object, the pressures of both swirl
+// models satisfy the radial equilibrium dp/dr = ρu_θ²/r about the free
+// stream axis
+func (c *checker) checkSwirl(t flow.ObjectType) error {
+	plain := checkFlow(t)
+	plain.Direction = [3]float64{0.48, 0.6, 0.64}
+	zero := plain
+	zero.Swirl = flow.Swirl{Type: flow.SwirlFree, Radius: 2}
+	same := true
+	for i := 0; i < 1000; i++ {
+		x, y, z := float64(i%10)-4.5, float64(i/10%10)-4.5, float64(i/100)-4.5
+		ux, uy, uz := plain.VelocityAt(x, y, z)
+		zx, zy, zz := zero.VelocityAt(x, y, z)
+		same = same && ux == zx && uy == zy && uz == zz
+	}
+
+	// Points along a ray perpendicular to the axis, in pairs h apart
+	const h = 1e-4
+	e := [3]float64{0, 0.8, -0.6}
+	var positions []float64
+	for r := 0.25; r < 6; r += 0.5 {
+		for _, s := range []float64{r - h, r + h} {
+			positions = append(positions, s*e[0], s*e[1], s*e[2])
+		}
+	}
+	n := len(positions) / 3
+	worst := 0.0
+	for _, w := range []flow.Swirl{{Type: flow.SwirlSolid, Number: 0.5, Radius: 2}, {Type: flow.SwirlFree, Number: 0.5, Radius: 2}} {
+		f := plain
+		f.Object.Radius, f.Swirl = 0, w
+		velocities, pressures := make([]float64, 3*n), make([]float64, n)
+		if err := flow.VelocitiesInto(velocities, positions, n, f); err != nil {
+			return err
+		}
+		if err := flow.PressuresFor(pressures, velocities, n, &f, flow.Mask{}); err != nil {
+			return err
+		}
+		if err := flow.AddSwirlPressures(pressures, positions, n, &f, flow.Mask{}); err != nil {
+			return err
+		}
+		for i := 0; i < n; i += 2 {
+			r := 0.25 + 0.5*float64(i/2)
+			// The tangential speed at r, from the mean of the pair
+			var ut float64
+			for a := 0; a < 3; a++ {
+				v := (velocities[3*i+a] + velocities[3*i+3+a]) / 2
+				v -= f.FreeStream * f.Direction[a]
+				ut += v * v
+			}
+			dpdr := (pressures[i+1] - pressures[i]) / (2 * h)
+			worst = math.Max(worst, math.Abs(dpdr-f.Density*ut/r)/(f.Density*w.Number*w.Number))
+		}
+	}
+	c.report(t.String()+" swirl", same && worst < swirlTolerance, "number 0 identical to no swirl: %v; max |dp/dr - ρu_θ²/r|/ρS²U² = %.3g", same, worst)
+	return nil
+}
@@ -0,0 +1,308 @@
+package flow
+
+import "math"
+
+// DefaultSymmetryBand is the half-width, in metres, of the band about a
+// symmetry plane whose particles are evaluated in full by default
+const DefaultSymmetryBand = 1e-3
+
+// symmetryTolerance bounds, relative to 1 + the largest coordinate, how far
+// a mirror particle may be from the mirror image of its partner and stay
+// paired: a few rounding errors, not a particle moved by something else
+const symmetryTolerance = 1e-5
+
+// symmetryProbes is the number of points of each of the shells round the
+// object the flow is sampled at to check it is symmetric
+const symmetryProbes = 48
+
+// Symmetry is a plane the flow is mirror symmetric about, as about y = 0
+// past a sphere or a cylinder without circulation, which Simulation.Step
+// exploits to evaluate about half of the particles. The particles beyond
+// Band on the side Normal points to are paired, in order, with those
+// beyond it on the other side, and each mirror particle is moved onto the
+// mirror image of its partner; Step then evaluates the partners and the
+// particles left unpaired, those within Band of the plane among them, and
+// fills in the mirrors by reflecting the velocities of their partners, the
+// component along Normal negated. After each step the mirrors are moved
+// onto the mirror images of their partners again, so pairs stay exact. A
+// particle that something else moved away from its partner's mirror image,
+// density control or injection, leaves its pair and is evaluated on its
+// own; replaced particles are paired anew.
+//
+// Symmetry doesn't change what the flow is, only how much of it is
+// evaluated: it can only be set on a flow that is symmetric, and is
+// suspended, the particles evaluated in full, while a later change breaks
+// the symmetry. LOD is suspended while the symmetry is used, and
+// UpdatePartial evaluates its particles in full. A runtime setting.
+type Symmetry struct {
+	Normal [3]float64 // Unit normal, toward the side evaluated
+	Offset float64    // Normal·p of the points p of the plane, m
+	Band   float64    // Half-width of the band evaluated in full, m
+}
+
+// Validate checks the parameters of m
+func (m *Symmetry) Validate() error {
+	if math.IsNaN(m.Offset) || math.IsInf(m.Offset, 0) {
+		return Errorf(ErrBadArguments, "symmetry: offset must be finite, got %g", m.Offset)
+	}
+	if !(m.Band >= 0) || math.IsInf(m.Band, 0) {
+		return Errorf(ErrBadArguments, "symmetry: band must be a non-negative finite length, got %g", m.Band)
+	}
+	if n := math.Sqrt(dot(m.Normal, m.Normal)); math.Abs(n-1) > 1e-9 {
+		return Errorf(ErrBadArguments, "symmetry: normal must be a unit vector, got %v", m.Normal)
+	}
+	return nil
+}
+
+// side returns the signed distance of p from the plane of m, positive on
+// the side evaluated
+func (m *Symmetry) side(p [3]float64) float64 {
+	return dot(m.Normal, p) - m.Offset
+}
+
+// reflect returns the mirror image of the point p in the plane of m
+func (m *Symmetry) reflect(p [3]float64) [3]float64 {
+	d := 2 * m.side(p)
+	return [3]float64{p[0] - d*m.Normal[0], p[1] - d*m.Normal[1], p[2] - d*m.Normal[2]}
+}
+
+// reflectVelocity returns the mirror image of the velocity v in the plane
+// of m
+func (m *Symmetry) reflectVelocity(v [3]float64) [3]float64 {
+	d := 2 * dot(m.Normal, v)
+	return [3]float64{v[0] - d*m.Normal[0], v[1] - d*m.Normal[1], v[2] - d*m.Normal[2]}
+}
+
+// mirrorCache is the state of a Symmetry between steps: the pairs of
+// particles and whether the flow was found symmetric
+type mirrorCache struct {
+	pairs   []int32 // Evaluated particle, then its mirror
+	active  []uint8 // Mask of the particles evaluated
+	paired  bool    // The particles were paired since they were last replaced
+	key     Flow    // The flow last checked
+	checked bool
+	broken  error // Why the flow of key isn't symmetric, nil if it is
+
+	suspended error // Why the last step evaluated every particle, nil if it didn't
+	used      bool  // The last evaluation filled in the mirrors
+}
+
+// dot returns a · b
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// symmetric checks that f is mirror symmetric about the plane of m,
+// sampling it on shells round the object and comparing the velocity at
+// the mirror image of every sample with the mirror image of its velocity
+func (f *Flow) symmetric(m *Symmetry) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if st := f.Object.Strengths; st.Set&StrengthCirculation != 0 && st.Circulation != 0 && f.Object.Radius != 0 {
+		return Errorf(ErrUnsupported, "symmetry: a circulation of %g makes the flow asymmetric", st.Circulation)
+	}
+	o := f.Object
+	r := o.Radius
+	if r == 0 {
+		r = 1
+	}
+	k := f.kernel()
+	scale := math.Abs(f.FreeStream) + math.Sqrt(dot(f.Motion, f.Motion))
+	for _, shell := range []float64{1.25, 2, 4} {
+		sphere := ObjectSpec{Type: Sphere, X: o.X, Y: o.Y, Z: o.Z, Radius: shell * r}
+		for i := 0; i < symmetryProbes; i++ {
+			p, _ := sphere.SurfacePoint(i, symmetryProbes)
+			q := m.reflect(p)
+			var v, w [3]float64
+			v[0], v[1], v[2] = k.velocityAt(p[0], p[1], p[2])
+			w[0], w[1], w[2] = k.velocityAt(q[0], q[1], q[2])
+			v = m.reflectVelocity(v)
+			d := [3]float64{w[0] - v[0], w[1] - v[1], w[2] - v[2]}
+			if e := math.Sqrt(dot(d, d)); e > 1e-9*(scale+math.Sqrt(dot(v, v))) {
+				return Errorf(ErrUnsupported, "symmetry: the flow isn't symmetric about the plane: at (%.4g, %.4g, %.4g) the velocity is (%.4g, %.4g, %.4g), the mirror image of the one at (%.4g, %.4g, %.4g) (%.4g, %.4g, %.4g)",
+					q[0], q[1], q[2], w[0], w[1], w[2], v[0], v[1], v[2], p[0], p[1], p[2])
+			}
+		}
+	}
+	return nil
+}
+
+// symmetryBroken returns why the flow of s, f being what Step evaluates,
+// isn't symmetric about m, or nil if it is
+func (s *Simulation) symmetryBroken(m *Symmetry, f *Flow) error {
+	if t := s.Turbulence; t != nil && t.Intensity != 0 {
+		return Errorf(ErrUnsupported, "symmetry: turbulence makes the flow asymmetric")
+	}
+	if s.Rotating.active() {
+		return Errorf(ErrUnsupported, "symmetry: a turning frame makes the flow asymmetric")
+	}
+	c := &s.mirrors
+	if !c.checked || c.key != *f {
+		c.key, c.checked, c.broken = *f, true, f.symmetric(m)
+	}
+	return c.broken
+}
+
+// SetSymmetry exploits the symmetry of the flow about the plane of m from
+// the next step on, pairing the particles then, or evaluates every
+// particle again if m is nil. The normal defaults to +y, and m is refused
+// if the flow isn't symmetric about its plane.
+func (s *Simulation) SetSymmetry(m *Symmetry) error {
+	if m != nil {
+		if m.Normal == [3]float64{} {
+			m.Normal = [3]float64{0, 1, 0}
+		}
+		var err error
+		if m.Normal, err = unit(m.Normal, "symmetry: normal"); err != nil {
+			return err
+		}
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		s.mirrors.checked = false
+		f := s.Flow()
+		f.Cutoff = s.Cutoff
+		if err := s.symmetryBroken(m, &f); err != nil {
+			return err
+		}
+	}
+	s.Symmetry = m
+	s.mirrors.paired = false
+	s.mirrors.suspended, s.mirrors.used = nil, false
+	s.mirrors.pairs = s.mirrors.pairs[:0]
+	s.lod.invalidate()
+	return nil
+}
+
+// pair pairs the count particles beyond the band of s.Symmetry, those on
+// the side evaluated with those on the other in order, moving each mirror
+// onto the mirror image of its partner
+func (s *Simulation) pair(count int) {
+	m, c := s.Symmetry, &s.mirrors
+	var near, far []int32
+	for i := 0; i < count; i++ {
+		switch d := m.side(s.position(i)); {
+		case d > m.Band:
+			near = append(near, int32(i))
+		case d < -m.Band:
+			far = append(far, int32(i))
+		}
+	}
+	c.pairs = c.pairs[:0]
+	for k := 0; k < len(near) && k < len(far); k++ {
+		c.pairs = append(c.pairs, near[k], far[k])
+	}
+	c.paired = true
+	s.reflectMirrors(count)
+	LogValue(LogDebug, "symmetry", "particles paired", float64(len(c.pairs)/2))
+}
+
+// position returns the position of particle i, in double precision if the
+// steps keep it
+func (s *Simulation) position(i int) [3]float64 {
+	if len(s.precise) == len(s.Positions) && len(s.precise) > 0 {
+		return [3]float64{s.precise[i*3], s.precise[i*3+1], s.precise[i*3+2]}
+	}
+	return [3]float64{float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), float64(s.Positions[i*3+2])}
+}
+
+// prune drops the pairs of s.Symmetry beyond count particles or whose
+// mirror is no longer at the mirror image of its partner, and marks the
+// particles left to evaluate
+func (s *Simulation) prune(count int) {
+	m, c := s.Symmetry, &s.mirrors
+	if cap(c.active) < count {
+		c.active = make([]uint8, count)
+	}
+	c.active = c.active[:count]
+	for i := range c.active {
+		c.active[i] = 1
+	}
+	kept := c.pairs[:0]
+	for k := 0; k+1 < len(c.pairs); k += 2 {
+		i, j := int(c.pairs[k]), int(c.pairs[k+1])
+		if i >= count || j >= count {
+			continue
+		}
+		p, q := m.reflect(s.position(i)), s.position(j)
+		tolerance := symmetryTolerance * (1 + math.Max(math.Abs(p[0]), math.Max(math.Abs(p[1]), math.Abs(p[2]))))
+		if math.Abs(p[0]-q[0]) > tolerance || math.Abs(p[1]-q[1]) > tolerance || math.Abs(p[2]-q[2]) > tolerance {
+			continue
+		}
+		kept = append(kept, int32(i), int32(j))
+		c.active[j] = 0
+	}
+	c.pairs = kept
+}
+
+// mirrorVelocities evaluates the velocities of count particles in the flow
+// f under s.Symmetry, or of all of them if the flow isn't symmetric,
+// returning the far-field count and the number of velocities filled in by
+// reflection
+func (s *Simulation) mirrorVelocities(f Flow, count int) (far, mirrored int, err error) {
+	m, c := s.Symmetry, &s.mirrors
+	if broken := s.symmetryBroken(m, &f); broken != nil {
+		if c.suspended == nil {
+			Logf(LogWarn, "symmetry", "suspended: %v", broken)
+		}
+		c.suspended, c.used = broken, false
+		far, err = VelocitiesIntoCounted(s.Velocities, s.Positions, count, f)
+		return far, 0, err
+	}
+	if !c.paired {
+		s.pair(count)
+	}
+	s.prune(count)
+	if far, err = VelocitiesMasked(s.Velocities, nil, s.Positions, count, f, Mask{Active: c.active}); err != nil {
+		return 0, 0, err
+	}
+	for k := 0; k < len(c.pairs); k += 2 {
+		i, j := int(c.pairs[k]), int(c.pairs[k+1])
+		v := m.reflectVelocity([3]float64{float64(s.Velocities[i*3]), float64(s.Velocities[i*3+1]), float64(s.Velocities[i*3+2])})
+		for a := range v {
+			s.Velocities[j*3+a] = float32(v[a])
+		}
+	}
+	c.suspended, c.used = nil, true
+	return far, len(c.pairs) / 2, nil
+}
+
+// reflectMirrors moves the mirror particle of every pair of s.Symmetry
+// onto the mirror image of its partner, in double precision if the steps
+// keep it
+func (s *Simulation) reflectMirrors(count int) {
+	m, c := s.Symmetry, &s.mirrors
+	double := len(s.precise) == count*3 && count > 0
+	for k := 0; k < len(c.pairs); k += 2 {
+		i, j := int(c.pairs[k]), int(c.pairs[k+1])
+		q := m.reflect(s.position(i))
+		for a := range q {
+			s.Positions[j*3+a] = float32(q[a])
+			if double {
+				s.precise[j*3+a] = q[a]
+			}
+		}
+	}
+}
+
+// symmetryState returns the symmetry section of State
+func (s *Simulation) symmetryState() map[string]interface{} {
+	m := s.Symmetry
+	if m == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	var reason interface{}
+	if err := s.mirrors.suspended; err != nil {
+		reason = err.Error()
+	}
+	return map[string]interface{}{
+		"enabled":   true,
+		"normal":    []interface{}{m.Normal[0], m.Normal[1], m.Normal[2]},
+		"offset":    m.Offset,
+		"band":      m.Band,
+		"used":      s.mirrors.used,
+		"pairs":     len(s.mirrors.pairs) / 2,
+		"suspended": reason,
+	}
+}
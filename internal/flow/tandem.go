@@ -0,0 +1,400 @@
+package flow
+
+import (
+	"math"
+	"strconv"
+)
+
+// Limits of a Tandem
+const (
+	MaxSections      = 16
+	MaxSectionPanels = 64
+)
+
+// Tandem is a set of lifting sections, flat plates or cambered airfoils,
+// interfering with each other in the cross-flow of the free stream: a
+// biplane, a tandem wing or a cascade. Each section is split into Panels
+// lumped vortex panels, with the bound vortex at the quarter of the panel
+// and the control point, where the flow is made tangent to the panel, at
+// its three quarters, which satisfies the Kutta condition at the trailing
+// edge. The circulations of every panel of every section are solved for
+// together, so each section's vortices change the flow the others see.
+type Tandem struct {
+	// Sections are the sections, described as for thin-airfoil theory.
+	// Vortices and Terms are not used; a section without camber is a
+	// flat plate.
+	Sections []ThinAirfoil
+
+	Panels int // Lumped vortex panels per section
+
+	// Relative, for two sections, places the leading edge of the second
+	// from the first: Stagger downstream along the cross-flow and Gap
+	// normal to it, up on the suction side, whatever its Position
+	Relative     bool
+	Gap, Stagger float64
+}
+
+// TandemSection is the solution of one section of a Tandem
+type TandemSection struct {
+	Circulation float64 // Γ per unit span, clockwise seen from +z
+	Isolated    float64 // Γ of the section alone in the free stream
+	CL          float64 // Section lift coefficient 2Γ/(U·c)
+	IsolatedCL  float64 // CL of the section alone
+	Lift        float64 // ρ·U·Γ per unit span, normal to the cross-flow
+
+	// Interference is Γ/Γ_isolated, 0 if the isolated section carries no
+	// lift
+	Interference float64
+}
+
+// TandemSolution is the solution of a Tandem
+type TandemSolution struct {
+	Sections []TandemSection
+
+	// Elements are the bound vortices of every panel, with the core
+	// radius of their section
+	Elements []Element
+}
+
+// Validate checks the parameters of t
+func (t *Tandem) Validate() error {
+	n := len(t.Sections)
+	if n < 1 || n > MaxSections {
+		return Errorf(ErrBadArguments, "tandem.sections needs 1 to %d sections, got %d", MaxSections, n)
+	}
+	if t.Panels < 1 || t.Panels > MaxSectionPanels {
+		return Errorf(ErrBadArguments, "tandem.panels must be between 1 and %d, got %d", MaxSectionPanels, t.Panels)
+	}
+	if n*t.Panels > MaxElements {
+		return Errorf(ErrBadArguments, "tandem: %d sections of %d panels exceed %d vortices", n, t.Panels, MaxElements)
+	}
+	if t.Relative {
+		if n != 2 {
+			return Errorf(ErrBadArguments, "tandem: gap and stagger need exactly 2 sections, got %d", n)
+		}
+		for _, x := range []float64{t.Gap, t.Stagger} {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return Errorf(ErrBadArguments, "tandem gap and stagger must be finite, got %g and %g", t.Gap, t.Stagger)
+			}
+		}
+	}
+	for _, a := range t.Sections {
+		a.Vortices, a.Terms = DefaultAirfoilN, DefaultAirfoilTerms
+		if err := a.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tandemPanel is a panel of a Tandem: its bound vortex, control point and
+// unit normal, on the suction side, in the xy plane
+type tandemPanel struct {
+	vortex, control, normal [2]float64
+}
+
+// tandemSystem is the linear system of a Tandem in a free stream: its
+// sections as placed, their panels, the onset speed of each section and
+// the direction of the cross-flow, and the normal velocity m[i][j] at
+// control point i of a unit clockwise vortex at panel j, which doesn't
+// depend on the onset flow
+type tandemSystem struct {
+	sections []ThinAirfoil
+	panels   []tandemPanel
+	onset    []float64
+	cx, cy   float64
+	m        [][]float64
+}
+
+// system places the sections and panels of t in the free stream of f and
+// builds their influence matrix
+func (t *Tandem) system(f *Flow) (*tandemSystem, error) {
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	d := f.direction()
+	dxy := math.Sqrt(d[0]*d[0] + d[1]*d[1])
+	if dxy == 0 {
+		return nil, Errorf(ErrBadArguments, "tandem: the free stream runs along the span")
+	}
+	cx, cy := d[0]/dxy, d[1]/dxy
+
+	sections := append([]ThinAirfoil(nil), t.Sections...)
+	if t.Relative {
+		p := sections[0].Position
+		sections[1].Position = [3]float64{p[0] + t.Stagger*cx - t.Gap*cy, p[1] + t.Stagger*cy + t.Gap*cx, p[2]}
+	}
+	np := t.Panels
+	panels := make([]tandemPanel, len(sections)*np)
+	onset := make([]float64, len(sections))
+	for i := range sections {
+		a := &sections[i]
+		onset[i] = f.Profile.Speed(f.FreeStream, a.Position[1]) * dxy
+		for j := 0; j < np; j++ {
+			x := func(frac float64) float64 { return (float64(j) + frac) / float64(np) }
+			s, e := a.point(x(0), cx, cy), a.point(x(1), cx, cy)
+			tx, ty := e[0]-s[0], e[1]-s[1]
+			l := math.Hypot(tx, ty)
+			panels[i*np+j] = tandemPanel{
+				vortex:  a.point(x(0.25), cx, cy),
+				control: a.point(x(0.75), cx, cy),
+				normal:  [2]float64{-ty / l, tx / l},
+			}
+		}
+	}
+
+	n := len(panels)
+	m := make([][]float64, n)
+	for i, p := range panels {
+		m[i] = make([]float64, n)
+		for j, q := range panels {
+			dx, dy := p.control[0]-q.vortex[0], p.control[1]-q.vortex[1]
+			r2 := dx*dx + dy*dy
+			if r2 == 0 {
+				return nil, Errorf(ErrBadArguments, "tandem: the sections overlap, leaving no unique circulation")
+			}
+			m[i][j] = (dy*p.normal[0] - dx*p.normal[1]) / (2 * math.Pi * r2)
+		}
+	}
+	return &tandemSystem{sections, panels, onset, cx, cy, m}, nil
+}
+
+// rhs returns minus the normal velocity of the onset flow at each control
+// point, for a cross-flow along (cx, cy)
+func (s *tandemSystem) rhs(cx, cy float64) []float64 {
+	np := len(s.panels) / len(s.sections)
+	b := make([]float64, len(s.panels))
+	for i, p := range s.panels {
+		b[i] = -s.onset[i/np] * (cx*p.normal[0] + cy*p.normal[1])
+	}
+	return b
+}
+
+// matrix returns a copy of the influence matrix of s, for elimination
+func (s *tandemSystem) matrix() [][]float64 {
+	m := make([][]float64, len(s.m))
+	for i := range m {
+		m[i] = append([]float64(nil), s.m[i]...)
+	}
+	return m
+}
+
+// Solve solves t in the free stream of f. Like ThinAirfoil.Solve it takes
+// the cross-flow speed at each section's leading edge height as its onset
+// and doesn't linearize: the panels follow the camber line pitched by α,
+// so a flat plate alone gets the circulation π·U·c·sin α of the exact
+// solution with any number of panels. The isolated circulations solve
+// each section's panels alone.
+func (t *Tandem) Solve(f *Flow) (*TandemSolution, error) {
+	sys, err := t.system(f)
+	if err != nil {
+		return nil, err
+	}
+	sections, panels, onset, m := sys.sections, sys.panels, sys.onset, sys.m
+	np, n := t.Panels, len(panels)
+	rhs := sys.rhs(sys.cx, sys.cy)
+	// Each section alone only sees its own panels: the diagonal blocks
+	isolated := make([]float64, 0, n)
+	for i := range sections {
+		lo, hi := i*np, (i+1)*np
+		block := make([][]float64, np)
+		for r := range block {
+			block[r] = append([]float64(nil), m[lo+r][lo:hi]...)
+		}
+		x, err := solveLinear(block, append([]float64(nil), rhs[lo:hi]...))
+		if err != nil {
+			return nil, err
+		}
+		isolated = append(isolated, x...)
+	}
+	gamma, err := solveLinear(sys.matrix(), rhs)
+	if err != nil {
+		return nil, err
+	}
+
+	sol := &TandemSolution{Sections: make([]TandemSection, len(sections)), Elements: make([]Element, n)}
+	for i := range sections {
+		a := &sections[i]
+		lo, hi := i*np, (i+1)*np
+		s := &sol.Sections[i]
+		for j := lo; j < hi; j++ {
+			s.Circulation += gamma[j]
+			s.Isolated += isolated[j]
+			sol.Elements[j] = Element{
+				Kind:        ElementVortex,
+				Position:    [3]float64{panels[j].vortex[0], panels[j].vortex[1], a.Position[2]},
+				Orientation: [3]float64{0, 0, -1},
+				Strength:    gamma[j],
+				CoreRadius:  a.CoreRadius,
+			}
+		}
+		if u := onset[i]; u != 0 {
+			s.CL, s.IsolatedCL = 2*s.Circulation/(u*a.Chord), 2*s.Isolated/(u*a.Chord)
+		}
+		s.Lift = f.Density * onset[i] * s.Circulation
+		if s.Isolated != 0 {
+			s.Interference = s.Circulation / s.Isolated
+		}
+	}
+	return sol, nil
+}
+
+// solveLinear solves m·x = b by Gaussian elimination with partial
+// pivoting, overwriting m and returning x in place of b
+func solveLinear(m [][]float64, b []float64) ([]float64, error) {
+	if !eliminate(m, [][]float64{b}) {
+		return nil, Errorf(ErrBadArguments, "tandem: the sections overlap, leaving no unique circulation")
+	}
+	return b, nil
+}
+
+// eliminate solves m·x = b for every right-hand side b of bs at once, by
+// Gaussian elimination with partial pivoting, overwriting m and leaving
+// each solution in place of its b. It reports false if m is singular.
+func eliminate(m [][]float64, bs [][]float64) bool {
+	n := len(m)
+	for c := 0; c < n; c++ {
+		p := c
+		for r := c + 1; r < n; r++ {
+			if math.Abs(m[r][c]) > math.Abs(m[p][c]) {
+				p = r
+			}
+		}
+		if m[p][c] == 0 || math.IsNaN(m[p][c]) || math.IsInf(m[p][c], 0) {
+			return false
+		}
+		m[c], m[p] = m[p], m[c]
+		for _, b := range bs {
+			b[c], b[p] = b[p], b[c]
+		}
+		for r := c + 1; r < n; r++ {
+			k := m[r][c] / m[c][c]
+			if k == 0 {
+				continue
+			}
+			for j := c; j < n; j++ {
+				m[r][j] -= k * m[c][j]
+			}
+			for _, b := range bs {
+				b[r] -= k * b[c]
+			}
+		}
+	}
+	for _, b := range bs {
+		for r := n - 1; r >= 0; r-- {
+			s := b[r]
+			for j := r + 1; j < n; j++ {
+				s -= m[r][j] * b[j]
+			}
+			b[r] = s / m[r][r]
+		}
+	}
+	return true
+}
+
+// Encode returns s in generic form: {sections: [{circulation,
+// isolatedCirculation, cl, isolatedCl, lift, interference}, ...],
+// elements}
+func (s *TandemSolution) Encode() map[string]interface{} {
+	sections := make([]interface{}, len(s.Sections))
+	for i, sec := range s.Sections {
+		sections[i] = map[string]interface{}{
+			"circulation":         sec.Circulation,
+			"isolatedCirculation": sec.Isolated,
+			"cl":                  sec.CL,
+			"isolatedCl":          sec.IsolatedCL,
+			"lift":                sec.Lift,
+			"interference":        sec.Interference,
+		}
+	}
+	elements := make([]interface{}, len(s.Elements))
+	for i := range s.Elements {
+		elements[i] = s.Elements[i].Encode()
+	}
+	return map[string]interface{}{"sections": sections, "elements": elements}
+}
+
+// TandemDims lists the dimensional keys of a tandem (see DecodeTandem)
+// and of its encoded solution, whose circulations and lifts are SI
+var TandemDims = map[string]Dim{
+	"sections.chord":      DimLength,
+	"sections.position":   DimLength,
+	"sections.coreRadius": DimLength,
+	"gap":                 DimLength,
+	"stagger":             DimLength,
+	"elements.position":   DimLength,
+	"elements.coreRadius": DimLength,
+}
+
+// DecodeTandem decodes a tandem from generic values (see DecodeConfig):
+//
+//	{sections: [{type: "plate" | "airfoil" = "plate", chord = 1,
+//	             position: [x, y, z] = [0, 0, 0], alpha: degrees = 0,
+//	             camber | naca, coreRadius = chord/(4·panels)}, ...],
+//	 panels = 1, gap, stagger}
+//
+// Sections take the keys of DecodeThinAirfoil but its discretization;
+// plates have no camber, and an airfoil without one is a plate too. gap
+// and stagger, either of which makes the other default to 0, place the
+// second of two sections (see Tandem).
+func DecodeTandem(v interface{}) (Tandem, error) {
+	t := Tandem{Panels: 1}
+	m, err := section(v, "tandem", "sections", "panels", "gap", "stagger")
+	if err != nil {
+		return t, err
+	}
+	panels := float64(t.Panels)
+	if err := numberKey(m, "tandem", "panels", &panels); err != nil {
+		return t, err
+	}
+	if panels != math.Trunc(panels) || panels < 1 || panels > MaxSectionPanels {
+		return t, Errorf(ErrBadArguments, "tandem.panels must be an integer between 1 and %d, got %g", MaxSectionPanels, panels)
+	}
+	t.Panels = int(panels)
+	_, gap := m["gap"]
+	_, stagger := m["stagger"]
+	t.Relative = gap || stagger
+	if err := numberKey(m, "tandem", "gap", &t.Gap); err != nil {
+		return t, err
+	}
+	if err := numberKey(m, "tandem", "stagger", &t.Stagger); err != nil {
+		return t, err
+	}
+
+	list, ok := m["sections"].([]interface{})
+	if !ok {
+		return t, Errorf(ErrBadArguments, "tandem.sections must be an array")
+	}
+	if len(list) > MaxSections {
+		return t, Errorf(ErrBadArguments, "tandem.sections holds %d sections, more than %d", len(list), MaxSections)
+	}
+	t.Sections = make([]ThinAirfoil, len(list))
+	for i, v := range list {
+		path := "tandem.sections[" + strconv.Itoa(i) + "]"
+		a, err := decodeThinAirfoil(v, path, "type")
+		if err != nil {
+			return t, err
+		}
+		s := v.(map[string]interface{})
+		switch s["type"] {
+		case nil, "plate":
+			if _, ok := s["camber"]; ok {
+				return t, Errorf(ErrBadArguments, "%s: a plate has no camber", path)
+			}
+			if _, ok := s["naca"]; ok {
+				return t, Errorf(ErrBadArguments, "%s: a plate has no camber", path)
+			}
+		case "airfoil":
+		default:
+			return t, Errorf(ErrBadArguments, "%s.type must be \"plate\" or \"airfoil\", got %v", path, s["type"])
+		}
+		if _, ok := s["coreRadius"]; !ok {
+			a.CoreRadius = a.Chord / float64(4*t.Panels)
+		}
+		t.Sections[i] = a
+	}
+	return t, t.Validate()
+}
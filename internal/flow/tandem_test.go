@@ -0,0 +1,63 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestTandem(t *testing.T) {
+	run(t, (*checker).checkTandem)
+}
+
+// checkTandem verifies the interference of lifting sections: a flat plate
+// alone gets the circulation of the plate object with one panel or many,
+// two plates far apart recover it, a biplane's sections lose lift to each
+// other, and in tandem the front section gains lift from the rear one's
+// upwash while the rear one loses it to the front one's downwash
+func (c *checker) checkTandem() error {
+	f := checkFlow(flow.Plate)
+	f.Object.Alpha = 8 * math.Pi / 180
+	forces, err := f.PlateForces()
+	if err != nil {
+		return err
+	}
+	plate := flow.DefaultThinAirfoil()
+	plate.Chord, plate.Alpha = 2, f.Object.Alpha
+	solve := func(panels int, gap, stagger float64) ([]flow.TandemSection, error) {
+		t := flow.Tandem{Sections: []flow.ThinAirfoil{plate, plate}, Panels: panels, Relative: true, Gap: gap, Stagger: stagger}
+		s, err := t.Solve(&f)
+		if err != nil {
+			return nil, err
+		}
+		return s.Sections, nil
+	}
+	isolated := 0.0
+	for _, panels := range []int{1, 8} {
+		s, err := solve(panels, 1e6, 0)
+		if err != nil {
+			return err
+		}
+		isolated = math.Max(isolated, math.Abs(s[0].Isolated-forces.Circulation)/forces.Circulation)
+	}
+	far, err := solve(1, 1e6, 0)
+	if err != nil {
+		return err
+	}
+	biplane, err := solve(1, 2, 0)
+	if err != nil {
+		return err
+	}
+	tandem, err := solve(1, 0, 4)
+	if err != nil {
+		return err
+	}
+	farOff := math.Max(math.Abs(far[0].Interference-1), math.Abs(far[1].Interference-1))
+	ok := isolated < strengthTolerance && farOff < tandemTolerance &&
+		biplane[0].Interference < 1 && biplane[1].Interference < 1 &&
+		tandem[0].Interference > 1 && tandem[1].Interference < 1
+	c.report("tandem", ok, "isolated Γ relative to the plate %.3g; far apart |factor - 1| %.3g; biplane %.4f, %.4f; tandem %.4f, %.4f",
+		isolated, farOff, biplane[0].Interference, biplane[1].Interference, tandem[0].Interference, tandem[1].Interference)
+	return nil
+}
@@ -0,0 +1,89 @@
+package flow
+
+import "math"
+
+// DefaultTargetTimeConstant is the time constant, in seconds, with which
+// the object approaches an ObjectTarget by default
+const DefaultTargetTimeConstant = 0.1
+
+// ObjectTarget is a position the object is dragged toward, as by the
+// pointer: every step the object covers 1 - e^(-dt/TimeConstant) of the
+// way there, at most MaxSpeed·dt. A TimeConstant of 0 goes all the way, so
+// MaxSpeed alone sets the pace, and a MaxSpeed of 0 leaves the speed
+// unbounded; both 0 jump to the target on the next step. The object stays
+// at the target once there, until the target is removed.
+//
+// Like a Motion, the drag is quasi-steady: the kernels see the velocity of
+// the continuous approach, (target - position)/TimeConstant bounded by
+// MaxSpeed. Particles the body overruns during a step are pushed aside
+// onto its surface rather than left inside it (see Simulation.collide).
+// A runtime setting, cleared by Reset and not carried to a simulation
+// replacing the one it was set on.
+type ObjectTarget struct {
+	Position     [3]float64
+	TimeConstant float64 // s
+	MaxSpeed     float64 // m/s, 0 for unbounded
+}
+
+// Validate checks the parameters of t
+func (t *ObjectTarget) Validate() error {
+	for _, x := range t.Position {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "target: position must be finite, got %v", t.Position)
+		}
+	}
+	if !(t.TimeConstant >= 0) || math.IsInf(t.TimeConstant, 0) {
+		return Errorf(ErrBadArguments, "target: timeConstant must be a non-negative finite number, got %g", t.TimeConstant)
+	}
+	if !(t.MaxSpeed >= 0) || math.IsInf(t.MaxSpeed, 0) {
+		return Errorf(ErrBadArguments, "target: maxSpeed must be a non-negative finite number, got %g", t.MaxSpeed)
+	}
+	return nil
+}
+
+// velocity returns the velocity with which the object at p approaches t,
+// zero for a jump
+func (t *ObjectTarget) velocity(p [3]float64) [3]float64 {
+	d, l := normalized([3]float64{t.Position[0] - p[0], t.Position[1] - p[1], t.Position[2] - p[2]})
+	speed := t.MaxSpeed
+	if t.TimeConstant > 0 && (speed == 0 || l/t.TimeConstant < speed) {
+		speed = l / t.TimeConstant
+	}
+	if l == 0 {
+		speed = 0
+	}
+	return [3]float64{speed * d[0], speed * d[1], speed * d[2]}
+}
+
+// displacement returns how far the object at p moves toward t in a step
+// of dt
+func (t *ObjectTarget) displacement(p [3]float64, dt float64) [3]float64 {
+	d, l := normalized([3]float64{t.Position[0] - p[0], t.Position[1] - p[1], t.Position[2] - p[2]})
+	move := l
+	if t.TimeConstant > 0 {
+		move = l * -math.Expm1(-dt/t.TimeConstant)
+	}
+	if t.MaxSpeed > 0 {
+		move = math.Min(move, t.MaxSpeed*dt)
+	}
+	scale := 1 + math.Max(math.Abs(t.Position[0]), math.Max(math.Abs(t.Position[1]), math.Abs(t.Position[2])))
+	if l-move <= collisionGap*scale {
+		// Exactly onto the target rather than ever closer, so the object
+		// comes to rest
+		return [3]float64{t.Position[0] - p[0], t.Position[1] - p[1], t.Position[2] - p[2]}
+	}
+	return [3]float64{move * d[0], move * d[1], move * d[2]}
+}
+
+// SetObjectTarget makes the object of s approach t from the next step on,
+// stopping its Motion, or leaves it where it is if t is nil
+func (s *Simulation) SetObjectTarget(t *ObjectTarget) error {
+	if t != nil {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		s.Motion = nil
+	}
+	s.Target = t
+	return nil
+}
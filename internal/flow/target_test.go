@@ -0,0 +1,118 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestObjectTarget(t *testing.T) {
+	run(t, (*checker).checkObjectTarget)
+}
+
+// checkObjectTarget drags a sphere through a column of particles: at its
+// maximum speed, the object must never move more than maxSpeed·dt a step,
+// must come to rest exactly on the target and show its velocity to the
+// kernels, and no particle may be left inside it, where jumping the object
+// there instead strands some. With a time constant each step must cover
+// 1 - e^(-dt/τ) of the way left. getState must report the target until a
+// motion replaces it.
+func (c *checker) checkObjectTarget() error {
+	var start []float32
+	for y := 1.25; y < 6; y += 0.25 {
+		for x := -0.75; x <= 0.75; x += 0.25 {
+			for _, z := range []float64{-0.5, 0, 0.5} {
+				start = append(start, float32(x), float32(y), float32(z))
+			}
+		}
+	}
+	const dt, speed = 0.05, 5
+	goal := [3]float64{0, 6, 0}
+	inside := func(s *flow.Simulation) int {
+		o := s.Config.Object
+		n := 0
+		for i := 0; i < s.Count(); i++ {
+			d := [3]float64{float64(s.Positions[i*3]) - o.X, float64(s.Positions[i*3+1]) - o.Y, float64(s.Positions[i*3+2]) - o.Z}
+			if dot3(d, d) < (o.Radius-1e-5)*(o.Radius-1e-5) {
+				n++
+			}
+		}
+		return n
+	}
+	run := func(t *flow.ObjectTarget, steps int, each func(s *flow.Simulation, from [3]float64)) (*flow.Simulation, error) {
+		s := flow.NewSimulation(flow.DefaultConfig())
+		if err := s.SetParticles(start, len(start)/3); err != nil {
+			return nil, err
+		}
+		if err := s.SetObjectTarget(t); err != nil {
+			return nil, err
+		}
+		for j := 0; j < steps; j++ {
+			o := s.Config.Object
+			if err := s.Step(dt); err != nil {
+				return nil, err
+			}
+			each(s, [3]float64{o.X, o.Y, o.Z})
+		}
+		return s, nil
+	}
+
+	over, stranded := 0.0, 0
+	s, err := run(&flow.ObjectTarget{Position: goal, MaxSpeed: speed}, 40, func(s *flow.Simulation, from [3]float64) {
+		o := s.Config.Object
+		d := [3]float64{o.X - from[0], o.Y - from[1], o.Z - from[2]}
+		over = math.Max(over, math.Sqrt(dot3(d, d))-speed*dt)
+		stranded += inside(s)
+	})
+	if err != nil {
+		return err
+	}
+	o := s.Config.Object
+	rest := o.X == goal[0] && o.Y == goal[1] && o.Z == goal[2]
+	st := s.State()["objects"].([]interface{})[0].(map[string]interface{})
+	reported := false
+	if t, ok := st["target"].(map[string]interface{}); ok {
+		p := t["position"].([]interface{})
+		reported = p[1] == goal[1] && t["maxSpeed"] == float64(speed)
+	}
+	moving := flow.NewSimulation(flow.DefaultConfig())
+	if err := moving.SetObjectTarget(&flow.ObjectTarget{Position: goal, MaxSpeed: speed}); err != nil {
+		return err
+	}
+	f := moving.Flow()
+	seen := math.Abs(f.ObjectVelocity()[1]-speed) < 1e-12
+
+	// The same path jumped along without the target
+	jumped := flow.NewSimulation(flow.DefaultConfig())
+	if err := jumped.SetParticles(start, len(start)/3); err != nil {
+		return err
+	}
+	stuck := 0
+	for j := 0; j < 40; j++ {
+		o := jumped.Config.Object
+		if err := jumped.Step(dt); err != nil {
+			return err
+		}
+		jumped.SetObjectPosition(0, math.Min(o.Y+speed*dt, goal[1]), 0)
+		stuck += inside(jumped)
+	}
+
+	const tau = 0.2
+	decay := 0.0
+	if _, err := run(&flow.ObjectTarget{Position: goal, TimeConstant: tau}, 10, func(s *flow.Simulation, from [3]float64) {
+		left := (goal[1] - s.Config.Object.Y) / (goal[1] - from[1])
+		decay = math.Max(decay, math.Abs(left-math.Exp(-dt/tau)))
+	}); err != nil {
+		return err
+	}
+	if err := s.SetMotion(&flow.Motion{Type: flow.MotionLinear}); err != nil {
+		return err
+	}
+	dropped := s.Target == nil && s.State()["objects"].([]interface{})[0].(map[string]interface{})["target"] == nil
+	bad := (&flow.ObjectTarget{Position: goal, MaxSpeed: -1}).Validate() != nil
+
+	c.report("object target", over < 1e-9 && rest && stranded == 0 && stuck > 0 && decay < 1e-9 && seen && reported && dropped && bad, "step beyond maxSpeed·dt by %.3g; at rest on the target %v; particles left inside %d, %d when jumped; time constant decay off by %.3g; velocity seen %v; target reported %v, dropped by a motion %v; rejects a negative speed %v",
+		over, rest, stranded, stuck, decay, seen, reported, dropped, bad)
+	return nil
+}
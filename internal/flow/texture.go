@@ -0,0 +1,209 @@
+package flow
+
+import "math"
+
+// Velocity component fields
+const (
+	FieldVX = "vx"
+	FieldVY = "vy"
+	FieldVZ = "vz"
+)
+
+// Scalar evaluates a scalar field at a point: speed, pressure, cp, vx, vy,
+// vz, or the q and lambda2 vortex criteria (see VortexCriterion). inside reports whether the point is in the object, beyond the
+// walls of the channel, below the bump's wall or below the rotor's floor.
+func (f *Flow) Scalar(field string, x, y, z float64) (v float64, inside bool) {
+	vx, vy, vz := f.VelocityAt(x, y, z)
+	inside = f.solid(x, y, z)
+	v2, rel2 := vx*vx+vy*vy+vz*vz, f.relative2(vx, vy, vz)
+	switch field {
+	case FieldVX:
+		return vx, inside
+	case FieldVY:
+		return vy, inside
+	case FieldVZ:
+		return vz, inside
+	case FieldPressure:
+		return 0.5 * f.Density * (f.onset2() - rel2), inside
+	case FieldCp:
+		return 1 - rel2/f.onset2(), inside
+	case FieldQ, FieldLambda2:
+		return vortexCriterion(field, f.VelocityGradient(x, y, z)), inside
+	}
+	return math.Sqrt(v2), inside
+}
+
+// solid reports whether a point is out of the fluid: in the object, beyond
+// the walls of the channel, below the bump's wall or below the rotor's
+// floor
+func (f *Flow) solid(x, y, z float64) bool {
+	return f.Object.Contains(x, y, z) || f.Channel.outside(x, y) || f.Bump.under(x, y) || f.Rotor.belowFloor(z)
+}
+
+// ScalarFields returns the names of the fields Scalar evaluates, used by
+// slice textures, images and recordings
+func ScalarFields() []string {
+	return []string{FieldSpeed, FieldPressure, FieldCp, FieldVX, FieldVY, FieldVZ, FieldQ, FieldLambda2}
+}
+
+// checkScalarField validates a field name for Scalar
+func (f *Flow) checkScalarField(field string) error {
+	switch field {
+	case FieldSpeed, FieldPressure, FieldVX, FieldVY, FieldVZ, FieldQ, FieldLambda2:
+		return nil
+	case FieldCp:
+		return f.checkOnset()
+	}
+	return Errorf(ErrBadArguments, "unknown field %q", field)
+}
+
+// Slice is a rectangle of an axis-aligned plane sampled at Width×Height
+// texel centers. Plane is "xy", "xz" or "yz"; Offset is the coordinate
+// along the remaining axis, and Min/Max bound the two in-plane coordinates
+// in the order named.
+type Slice struct {
+	Plane         string
+	Offset        float64
+	Min, Max      [2]float64
+	Width, Height int
+}
+
+// Validate checks the slice geometry
+func (s *Slice) Validate() error {
+	if _, ok := planeAxes[s.Plane]; !ok {
+		return Errorf(ErrBadArguments, `plane must be "xy", "xz" or "yz", got %q`, s.Plane)
+	}
+	if s.Width < 1 || s.Height < 1 {
+		return Errorf(ErrBadArguments, "texture size must be positive, got %dx%d", s.Width, s.Height)
+	}
+	if !(s.Max[0] > s.Min[0]) || !(s.Max[1] > s.Min[1]) {
+		return Errorf(ErrBadArguments, "slice extent is empty: min %v, max %v", s.Min, s.Max)
+	}
+	return nil
+}
+
+// SliceExtent returns the default bounds of a slice of plane: 4 radii,
+// or 4 units without an object, around the object center
+func (o *ObjectSpec) SliceExtent(plane string) (min, max [2]float64) {
+	ext := 4 * o.Radius
+	if ext == 0 {
+		ext = 4
+	}
+	c := [3]float64{o.X, o.Y, o.Z}
+	ax := planeAxes[plane]
+	return [2]float64{c[ax[0]] - ext, c[ax[1]] - ext}, [2]float64{c[ax[0]] + ext, c[ax[1]] + ext}
+}
+
+// planeAxes gives the in-plane axes and the normal axis of each plane
+var planeAxes = map[string][3]int{"xy": {0, 1, 2}, "xz": {0, 2, 1}, "yz": {1, 2, 0}}
+
+// point returns the center of texel (i, j)
+func (s *Slice) point(i, j int) (x, y, z float64) {
+	return s.At(s.Min[0]+(float64(i)+0.5)*(s.Max[0]-s.Min[0])/float64(s.Width),
+		s.Min[1]+(float64(j)+0.5)*(s.Max[1]-s.Min[1])/float64(s.Height))
+}
+
+// At returns the point of the plane with in-plane coordinates (u, v)
+func (s *Slice) At(u, v float64) (x, y, z float64) {
+	ax := planeAxes[s.Plane]
+	var p [3]float64
+	p[ax[0]], p[ax[1]], p[ax[2]] = u, v, s.Offset
+	return p[0], p[1], p[2]
+}
+
+// SliceTextureFloat samples field over s into RGBA32F texel data: the raw
+// value in R, zero G and B, and alpha 1 in the fluid and 0 inside the
+// body. Row 0 is at Min[1], matching texImage2D without UNPACK_FLIP_Y.
+func SliceTextureFloat(f Flow, s Slice, field string) ([]float32, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := f.checkScalarField(field); err != nil {
+		return nil, err
+	}
+	out := make([]float32, s.Width*s.Height*4)
+	for j := 0; j < s.Height; j++ {
+		for i := 0; i < s.Width; i++ {
+			x, y, z := s.point(i, j)
+			v, inside := f.Scalar(field, x, y, z)
+			t := out[(j*s.Width+i)*4:]
+			t[0] = float32(v)
+			if !inside {
+				t[3] = 1
+			}
+		}
+	}
+	return out, nil
+}
+
+// SliceTextureRGBA8 is SliceTextureFloat with the values mapped from
+// [lo, hi] through colormap into RGBA8 texels
+func SliceTextureRGBA8(f Flow, s Slice, field string, lo, hi float64, colormap string) ([]byte, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if err := f.checkScalarField(field); err != nil {
+		return nil, err
+	}
+	stops, ok := colormaps[colormap]
+	if !ok {
+		return nil, Errorf(ErrBadArguments, "unknown colormap %q", colormap)
+	}
+	if !(hi > lo) {
+		return nil, Errorf(ErrBadArguments, "rangeMax must be greater than rangeMin, got [%g, %g]", lo, hi)
+	}
+	out := make([]byte, s.Width*s.Height*4)
+	for j := 0; j < s.Height; j++ {
+		for i := 0; i < s.Width; i++ {
+			x, y, z := s.point(i, j)
+			v, inside := f.Scalar(field, x, y, z)
+			t := out[(j*s.Width+i)*4:]
+			r, g, b := colorAt(stops, (v-lo)/(hi-lo))
+			t[0], t[1], t[2] = r, g, b
+			if !inside {
+				t[3] = 255
+			}
+		}
+	}
+	return out, nil
+}
+
+// colormaps holds evenly spaced RGB stops of each colormap
+var colormaps = map[string][][3]float64{
+	"grayscale": {{0, 0, 0}, {1, 1, 1}},
+	"viridis": {
+		{0.267, 0.005, 0.329}, {0.283, 0.141, 0.458}, {0.254, 0.265, 0.530},
+		{0.207, 0.372, 0.553}, {0.164, 0.471, 0.558}, {0.128, 0.567, 0.551},
+		{0.135, 0.659, 0.518}, {0.267, 0.749, 0.441}, {0.478, 0.821, 0.318},
+		{0.741, 0.873, 0.150}, {0.993, 0.906, 0.144},
+	},
+	"coolwarm": {
+		{0.230, 0.299, 0.754}, {0.552, 0.690, 0.996}, {0.866, 0.866, 0.866},
+		{0.956, 0.604, 0.484}, {0.706, 0.016, 0.150},
+	},
+}
+
+// Colormaps lists the colormap names
+func Colormaps() []string {
+	return []string{"viridis", "coolwarm", "grayscale"}
+}
+
+// colorAt interpolates the stops at t, clamped to [0, 1]
+func colorAt(stops [][3]float64, t float64) (r, g, b byte) {
+	if !(t > 0) {
+		t = 0
+	} else if t > 1 {
+		t = 1
+	}
+	x := t * float64(len(stops)-1)
+	k := int(x)
+	if k >= len(stops)-1 {
+		k = len(stops) - 2
+	}
+	u := x - float64(k)
+	var c [3]byte
+	for a := range c {
+		c[a] = byte(math.Round(255 * (stops[k][a] + u*(stops[k+1][a]-stops[k][a]))))
+	}
+	return c[0], c[1], c[2]
+}
@@ -0,0 +1,365 @@
+package flow
+
+import (
+	"math"
+	"strconv"
+)
+
+// Limits of a ThinAirfoil
+const (
+	MaxCamberPoints     = 1024
+	MaxAirfoilTerms     = 200
+	DefaultAirfoilN     = 50 // Sheet vortices
+	DefaultAirfoilTerms = 40
+)
+
+// ThinAirfoil describes a thin airfoil section for thin-airfoil theory:
+// the camber line of a chord c, from the leading edge at Position along
+// the free stream, pitched nose up by Alpha, is replaced by a vortex sheet
+// whose strength makes it a streamline to first order. The section is 2D,
+// extending along z, like the airfoil object, and sees the cross-flow
+// part of the free stream.
+type ThinAirfoil struct {
+	Chord    float64
+	Position [3]float64 // Leading edge
+	Alpha    float64    // Angle of attack in radians
+
+	// Camber is the camber line as (x/c, z/c) points, x from 0 to 1 in
+	// increasing order, interpolated linearly. If nil, M and P give a
+	// NACA 4-digit camber line: maximum camber M/c at P/c, a flat plate
+	// if M is 0.
+	Camber [][2]float64
+	M, P   float64
+
+	Vortices   int     // Number of point vortices the sheet is lumped into
+	CoreRadius float64 // Core radius of the sheet vortices (see Element)
+	Terms      int     // Fourier terms of the sheet strength
+}
+
+// DefaultThinAirfoil returns a flat plate of unit chord at the origin with
+// the default discretization
+func DefaultThinAirfoil() ThinAirfoil {
+	return ThinAirfoil{Chord: 1, Vortices: DefaultAirfoilN, CoreRadius: 1.0 / DefaultAirfoilN, Terms: DefaultAirfoilTerms}
+}
+
+// ThinAirfoilSolution is the solution of thin-airfoil theory for a
+// ThinAirfoil in a free stream of speed U
+type ThinAirfoilSolution struct {
+	// A holds the Glauert coefficients A0 ... A(Terms) of the sheet
+	// strength γ(θ) = 2U[A0·(1 + cos θ)/sin θ + Σ An·sin nθ], where
+	// x/c = (1 - cos θ)/2
+	A []float64
+
+	CL          float64 // Section lift coefficient 2π(A0 + A1/2)
+	CMQuarter   float64 // Moment coefficient about the quarter chord, π/4·(A2 - A1), nose up positive
+	Circulation float64 // Γ = ½·c·U·CL per unit span, clockwise seen from +z
+	ZeroLift    float64 // Angle of attack of zero lift, in radians
+
+	// Elements are the sheet vortices: one per panel of equal steps in θ,
+	// at the panel's midpoint on the camber line, holding the circulation
+	// of the sheet over it
+	Elements []Element
+}
+
+// Validate checks the parameters of a
+func (a *ThinAirfoil) Validate() error {
+	for _, x := range append(a.Position[:], a.Chord, a.Alpha, a.M, a.P, a.CoreRadius) {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return Errorf(ErrBadArguments, "thin airfoil parameters must be finite")
+		}
+	}
+	if !(a.Chord > 0) {
+		return Errorf(ErrBadArguments, "thinAirfoil.chord must be positive, got %g", a.Chord)
+	}
+	if a.Vortices < 1 || a.Vortices > MaxElements {
+		return Errorf(ErrBadArguments, "thinAirfoil.vortices must be between 1 and %d, got %d", MaxElements, a.Vortices)
+	}
+	if a.Terms < 2 || a.Terms > MaxAirfoilTerms {
+		return Errorf(ErrBadArguments, "thinAirfoil.terms must be between 2 and %d, got %d", MaxAirfoilTerms, a.Terms)
+	}
+	if a.CoreRadius < 0 {
+		return Errorf(ErrBadArguments, "thinAirfoil.coreRadius must be non-negative, got %g", a.CoreRadius)
+	}
+	if a.Camber == nil {
+		if a.M != 0 && !(a.P > 0 && a.P < 1) {
+			return Errorf(ErrBadArguments, "thinAirfoil: the position of maximum camber must be between 0 and 1, got %g", a.P)
+		}
+		return nil
+	}
+	n := len(a.Camber)
+	if n < 2 || n > MaxCamberPoints {
+		return Errorf(ErrBadArguments, "thinAirfoil.camber needs 2 to %d points, got %d", MaxCamberPoints, n)
+	}
+	for i, p := range a.Camber {
+		if math.IsNaN(p[0]) || math.IsInf(p[0], 0) || math.IsNaN(p[1]) || math.IsInf(p[1], 0) {
+			return Errorf(ErrBadArguments, "thinAirfoil.camber[%d] must be finite", i)
+		}
+		if i > 0 && !(p[0] > a.Camber[i-1][0]) {
+			return Errorf(ErrBadArguments, "thinAirfoil.camber x must increase, got %g after %g", p[0], a.Camber[i-1][0])
+		}
+	}
+	if a.Camber[0][0] != 0 || a.Camber[n-1][0] != 1 {
+		return Errorf(ErrBadArguments, "thinAirfoil.camber must run from x/c = 0 to 1")
+	}
+	return nil
+}
+
+// camberPiece is a stretch of the camber line over [t1, t2] in θ whose
+// slope dz/dx is a + b·cos θ: constant between the points of a Camber,
+// linear in x, hence in cos θ, on either side of a NACA line's maximum
+type camberPiece struct {
+	t1, t2, a, b float64
+}
+
+// pieces returns the camber line of a as camberPieces covering [0, π]
+func (a *ThinAirfoil) pieces() []camberPiece {
+	theta := func(x float64) float64 { return math.Acos(1 - 2*x) }
+	if a.Camber != nil {
+		out := make([]camberPiece, len(a.Camber)-1)
+		for i := range out {
+			p, q := a.Camber[i], a.Camber[i+1]
+			out[i] = camberPiece{theta(p[0]), theta(q[0]), (q[1] - p[1]) / (q[0] - p[0]), 0}
+		}
+		return out
+	}
+	if a.M == 0 {
+		return []camberPiece{{0, math.Pi, 0, 0}}
+	}
+	// dz/dx = 2M/P²·(P - x) ahead of P and 2M/(1-P)²·(P - x) behind it,
+	// with x = (1 - cos θ)/2
+	m, p := a.M, a.P
+	fore, aft := 2*m/(p*p), 2*m/((1-p)*(1-p))
+	return []camberPiece{
+		{0, theta(p), fore * (p - 0.5), fore / 2},
+		{theta(p), math.Pi, aft * (p - 0.5), aft / 2},
+	}
+}
+
+// height returns the camber z/c at x/c
+func (a *ThinAirfoil) height(x float64) float64 {
+	if a.Camber != nil {
+		c := a.Camber
+		for i := 1; i < len(c); i++ {
+			if x <= c[i][0] || i == len(c)-1 {
+				return c[i-1][1] + (c[i][1]-c[i-1][1])*(x-c[i-1][0])/(c[i][0]-c[i-1][0])
+			}
+		}
+	}
+	m, p := a.M, a.P
+	if m == 0 {
+		return 0
+	}
+	if x < p {
+		return m / (p * p) * (2*p*x - x*x)
+	}
+	return m / ((1 - p) * (1 - p)) * (1 - 2*p + 2*p*x - x*x)
+}
+
+// sines returns ∫cos kθ dθ over [t1, t2]
+func sines(k int, t1, t2 float64) float64 {
+	if k == 0 {
+		return t2 - t1
+	}
+	n := float64(k)
+	return (math.Sin(n*t2) - math.Sin(n*t1)) / n
+}
+
+// Solve solves thin-airfoil theory for a in the free stream of f, taking
+// the speed of its cross-flow at the leading edge's height like the
+// airfoil object does at its center. The Glauert coefficients of the
+// camber line are exact for its pieces; the sheet is truncated to Terms
+// Fourier terms.
+func (a *ThinAirfoil) Solve(f *Flow) (*ThinAirfoilSolution, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	d := f.direction()
+	dxy := math.Sqrt(d[0]*d[0] + d[1]*d[1])
+	if dxy == 0 {
+		return nil, Errorf(ErrBadArguments, "thin airfoil: the free stream runs along the span")
+	}
+	cx, cy := d[0]/dxy, d[1]/dxy
+	u := f.Profile.Speed(f.FreeStream, a.Position[1]) * dxy
+
+	// A0 = α - 1/π·∫dz/dx dθ and An = 2/π·∫dz/dx·cos nθ dθ over [0, π],
+	// with ∫cos θ·cos nθ = ½∫(cos (n-1)θ + cos (n+1)θ)
+	s := &ThinAirfoilSolution{A: make([]float64, a.Terms+1)}
+	for _, p := range a.pieces() {
+		for n := range s.A {
+			v := p.a*sines(n, p.t1, p.t2) + p.b*0.5*(sines(absInt(n-1), p.t1, p.t2)+sines(n+1, p.t1, p.t2))
+			if n == 0 {
+				s.A[0] -= v / math.Pi
+			} else {
+				s.A[n] += 2 * v / math.Pi
+			}
+		}
+	}
+	// α_L0 = -(A0 at α = 0) - A1/2, before α is added
+	s.ZeroLift = -s.A[0] - s.A[1]/2
+	s.A[0] += a.Alpha
+	s.CL = 2 * math.Pi * (s.A[0] + s.A[1]/2)
+	s.CMQuarter = math.Pi / 4 * (s.A[2] - s.A[1])
+	s.Circulation = 0.5 * a.Chord * u * s.CL
+
+	// The circulation of panel [t1, t2] is ∫γ·dx = c·U·∫[A0·(1 + cos θ) +
+	// Σ An·sin nθ·sin θ] dθ, with sin nθ·sin θ = ½(cos (n-1)θ - cos (n+1)θ)
+	s.Elements = make([]Element, a.Vortices)
+	for i := range s.Elements {
+		t1, t2 := math.Pi*float64(i)/float64(a.Vortices), math.Pi*float64(i+1)/float64(a.Vortices)
+		g := s.A[0] * (sines(0, t1, t2) + sines(1, t1, t2))
+		for n := 1; n < len(s.A); n++ {
+			g += s.A[n] * 0.5 * (sines(n-1, t1, t2) - sines(n+1, t1, t2))
+		}
+		p := a.point((1-math.Cos((t1+t2)/2))/2, cx, cy)
+		s.Elements[i] = Element{
+			Kind:        ElementVortex,
+			Position:    [3]float64{p[0], p[1], a.Position[2]},
+			Orientation: [3]float64{0, 0, -1},
+			Strength:    a.Chord * u * g,
+			CoreRadius:  a.CoreRadius,
+		}
+	}
+	return s, nil
+}
+
+// point returns the point of the camber line at x/c in the xy plane, for
+// a cross-flow along (cx, cy)
+func (a *ThinAirfoil) point(x, cx, cy float64) [2]float64 {
+	z := a.height(x)
+	// Along the chord, pitched nose up by α, and normal to it in the frame
+	// of the free stream, rotated into its cross-flow direction
+	sa, ca := math.Sincos(a.Alpha)
+	lx, ly := a.Chord*(x*ca+z*sa), a.Chord*(-x*sa+z*ca)
+	return [2]float64{a.Position[0] + cx*lx - cy*ly, a.Position[1] + cy*lx + cx*ly}
+}
+
+// absInt returns |n|
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Encode returns s in generic form: {cl, cmQuarter, circulation,
+// zeroLiftAlpha (degrees), coefficients, elements}
+func (s *ThinAirfoilSolution) Encode() map[string]interface{} {
+	a := make([]interface{}, len(s.A))
+	for i, x := range s.A {
+		a[i] = x
+	}
+	elements := make([]interface{}, len(s.Elements))
+	for i := range s.Elements {
+		elements[i] = s.Elements[i].Encode()
+	}
+	return map[string]interface{}{
+		"cl":            s.CL,
+		"cmQuarter":     s.CMQuarter,
+		"circulation":   s.Circulation,
+		"zeroLiftAlpha": s.ZeroLift * 180 / math.Pi,
+		"coefficients":  a,
+		"elements":      elements,
+	}
+}
+
+// ThinAirfoilDims lists the dimensional keys of a thin airfoil (see
+// DecodeThinAirfoil) and of its encoded solution, whose circulation, a
+// strength, is SI
+var ThinAirfoilDims = map[string]Dim{
+	"chord":               DimLength,
+	"position":            DimLength,
+	"coreRadius":          DimLength,
+	"elements.position":   DimLength,
+	"elements.coreRadius": DimLength,
+}
+
+// DecodeThinAirfoil decodes a thin airfoil from generic values (see
+// DecodeConfig):
+//
+//	{chord = 1, position: [x, y, z] = [0, 0, 0], alpha: degrees = 0,
+//	 camber: [[x/c, z/c], ...] | naca: "2412", vortices = 50,
+//	 coreRadius = chord/vortices, terms = 40}
+//
+// naca takes the camber line of a NACA 4-digit section, whose first two
+// digits are the maximum camber in percent of the chord and its position
+// in tenths; the thickness digits are ignored. Without camber or naca the
+// section is a flat plate.
+func DecodeThinAirfoil(v interface{}) (ThinAirfoil, error) {
+	return decodeThinAirfoil(v, "thinAirfoil", "vortices", "terms")
+}
+
+// decodeThinAirfoil is DecodeThinAirfoil for the section at path, which
+// takes the keys of the discretization given in extra
+func decodeThinAirfoil(v interface{}, path string, extra ...string) (ThinAirfoil, error) {
+	a := DefaultThinAirfoil()
+	m, err := section(v, path, append([]string{"chord", "position", "alpha", "camber", "naca", "coreRadius"}, extra...)...)
+	if err != nil {
+		return a, err
+	}
+	if err := numberKey(m, path, "chord", &a.Chord); err != nil {
+		return a, err
+	}
+	if v, ok := m["position"]; ok {
+		if a.Position, err = vector(v, path+".position"); err != nil {
+			return a, err
+		}
+	}
+	alpha := 0.0
+	if err := numberKey(m, path, "alpha", &alpha); err != nil {
+		return a, err
+	}
+	a.Alpha = alpha * math.Pi / 180
+	n, terms := float64(a.Vortices), float64(a.Terms)
+	if err := numberKey(m, path, "vortices", &n); err != nil {
+		return a, err
+	}
+	if err := numberKey(m, path, "terms", &terms); err != nil {
+		return a, err
+	}
+	if n != math.Trunc(n) || terms != math.Trunc(terms) {
+		return a, Errorf(ErrBadArguments, "%s.vortices and terms must be integers", path)
+	}
+	a.Vortices, a.Terms = int(n), int(terms)
+	a.CoreRadius = a.Chord / n
+	if err := numberKey(m, path, "coreRadius", &a.CoreRadius); err != nil {
+		return a, err
+	}
+
+	_, hasCamber := m["camber"]
+	code, hasNACA := m["naca"]
+	switch {
+	case hasCamber && hasNACA:
+		return a, Errorf(ErrBadArguments, "%s: give camber or naca, not both", path)
+	case hasNACA:
+		s, ok := code.(string)
+		if !ok || len(s) != 4 {
+			return a, Errorf(ErrBadArguments, "%s.naca must be a 4-digit string", path)
+		}
+		d, err := strconv.Atoi(s)
+		if err != nil || d < 0 {
+			return a, Errorf(ErrBadArguments, "%s.naca must be a 4-digit string, got %q", path, s)
+		}
+		a.M, a.P = float64(d/1000)/100, float64(d/100%10)/10
+	case hasCamber:
+		pts, ok := m["camber"].([]interface{})
+		if !ok {
+			return a, Errorf(ErrBadArguments, "%s.camber must be an array of [x/c, z/c] pairs", path)
+		}
+		a.Camber = make([][2]float64, len(pts))
+		for i, p := range pts {
+			pair, ok := p.([]interface{})
+			if !ok || len(pair) != 2 {
+				return a, Errorf(ErrBadArguments, "%s.camber[%d] must be an [x/c, z/c] pair", path, i)
+			}
+			for j := range pair {
+				if a.Camber[i][j], ok = pair[j].(float64); !ok {
+					return a, Errorf(ErrBadArguments, "%s.camber[%d] must hold numbers", path, i)
+				}
+			}
+		}
+	}
+	return a, a.Validate()
+}
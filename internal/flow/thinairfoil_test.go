@@ -0,0 +1,78 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestThinAirfoil(t *testing.T) {
+	run(t, (*checker).checkThinAirfoil)
+}
+
+// checkThinAirfoil verifies thin-airfoil theory: a flat plate has cl =
+// 2πα and no quarter-chord moment, the NACA 2412 has its published angle
+// of zero lift of -2.077° and the same coefficients as its camber line
+// sampled at points, the sheet vortices add up to the circulation, and
+// their cores keep the velocity bounded along the chord
+func (c *checker) checkThinAirfoil() error {
+	f := checkFlow(flow.Cylinder)
+	f.Object.Radius = 0
+	alpha := 5 * math.Pi / 180
+
+	plate := flow.DefaultThinAirfoil()
+	plate.Alpha = alpha
+	p, err := plate.Solve(&f)
+	if err != nil {
+		return err
+	}
+	ok := math.Abs(p.CL-2*math.Pi*alpha) < airfoilTolerance && math.Abs(p.CMQuarter) < airfoilTolerance
+	c.report("thin airfoil plate", ok, "cl %.15g, want 2πα = %.15g; cm_c/4 %.3g", p.CL, 2*math.Pi*alpha, p.CMQuarter)
+
+	naca := flow.DefaultThinAirfoil()
+	naca.M, naca.P, naca.Alpha = 0.02, 0.4, alpha
+	s, err := naca.Solve(&f)
+	if err != nil {
+		return err
+	}
+	sampled := naca
+	sampled.Camber = make([][2]float64, 1001)
+	for i := range sampled.Camber {
+		x := float64(i) / 1000
+		z := 0.02 / 0.16 * (0.8*x - x*x)
+		if x >= 0.4 {
+			z = 0.02 / 0.36 * (0.2 + 0.8*x - x*x)
+		}
+		sampled.Camber[i] = [2]float64{x, z}
+	}
+	q, err := sampled.Solve(&f)
+	if err != nil {
+		return err
+	}
+	zeroLift := s.ZeroLift * 180 / math.Pi
+	camber := math.Max(math.Abs(q.CL-s.CL), math.Abs(q.CMQuarter-s.CMQuarter))
+	ok = math.Abs(zeroLift+2.077) < zeroLiftTolerance && camber < camberTolerance
+	c.report("thin airfoil naca 2412", ok, "α_L0 %.4f°, want -2.077°; cl %.6f, cm_c/4 %.6f; sampled camber max |Δ| = %.3g", zeroLift, s.CL, s.CMQuarter, camber)
+
+	total := 0.0
+	for _, e := range s.Elements {
+		total += e.Strength
+	}
+	sheet := math.Abs(total-s.Circulation) / s.Circulation
+
+	sp, err := flow.NewSuperposition(s.Elements)
+	if err != nil {
+		return err
+	}
+	f.Elements = sp
+	speed := 0.0
+	for i := 0; i <= 1000; i++ {
+		x := float64(i) / 1000
+		vx, vy, vz := f.VelocityAt(x*math.Cos(alpha), -x*math.Sin(alpha), 0)
+		speed = math.Max(speed, math.Sqrt(vx*vx+vy*vy+vz*vz)/f.FreeStream)
+	}
+	ok = sheet < airfoilTolerance && speed < sheetSpeedLimit
+	c.report("thin airfoil sheet", ok, "Σ vortices - Γ relative %.3g; max |v|/U along the chord %.3g", sheet, speed)
+	return nil
+}
@@ -0,0 +1,276 @@
+package flow
+
+import (
+	"math"
+	"sync"
+)
+
+// Torus fit parameters
+const (
+	DefaultTubeFraction      = 0.25 // Minor radius of a torus, as a fraction of its major radius, when MinorRadius is 0
+	DefaultTorusCollocation  = 64
+	MinTorusCollocation      = 8
+	MaxTorusCollocation      = 512
+	torusRingsPerCollocation = 4   // Collocation points per ring source
+	torusRingFraction        = 0.6 // Radius of the circle of ring sources, in minor radii
+)
+
+// validateTorus checks the torus parameters of the object of f, that
+// objects other than tori don't set them, and that a torus sees an onset
+// flow along its axis, x, and can be fitted
+func (f *Flow) validateTorus() error {
+	o := &f.Object
+	if a := o.MinorRadius; a < 0 || math.IsNaN(a) || math.IsInf(a, 0) {
+		return Errorf(ErrBadArguments, "minorRadius must be non-negative and finite, got %g", a)
+	}
+	if n := o.Collocation; n != 0 && (n < MinTorusCollocation || n > MaxTorusCollocation) {
+		return Errorf(ErrBadArguments, "collocation must be 0 (default %d) or between %d and %d, got %d", DefaultTorusCollocation, MinTorusCollocation, MaxTorusCollocation, n)
+	}
+	if o.Type != Torus {
+		if o.MinorRadius != 0 || o.Collocation != 0 {
+			return Errorf(ErrBadArguments, "minorRadius and collocation only apply to tori, not to a %v", o.Type)
+		}
+		return nil
+	}
+	if o.MinorRadius >= o.Radius && o.Radius != 0 {
+		return Errorf(ErrBadArguments, "a torus needs a minorRadius below its radius %g, got %g", o.Radius, o.MinorRadius)
+	}
+	if o.Strengths.Set != 0 {
+		return Errorf(ErrUnsupported, "a torus has no strength overrides: its ring sources are fitted to its surface")
+	}
+	if o.CollisionRadius != 0 {
+		return Errorf(ErrBadArguments, "a torus has no collision radius: its inside is the tube")
+	}
+	if g := f.onset(); !g.aligned() && g.FreeStream != 0 {
+		return Errorf(ErrUnsupported, "a torus needs the flow along its axis, x; the onset direction is %v", g.Direction)
+	}
+	if o.Radius == 0 {
+		return nil
+	}
+	_, err := torusFit(o.minorRadius()/o.Radius, o.collocation())
+	return err
+}
+
+// minorRadius returns the radius of the tube of a torus
+func (o *ObjectSpec) minorRadius() float64 {
+	if o.MinorRadius != 0 {
+		return o.MinorRadius
+	}
+	return DefaultTubeFraction * o.Radius
+}
+
+// collocation returns the number of collocation points of a torus
+func (o *ObjectSpec) collocation() int {
+	if o.Collocation != 0 {
+		return o.Collocation
+	}
+	return DefaultTorusCollocation
+}
+
+// insideTorus reports whether the offset (x, y, z) from the center of a
+// torus, whose axis is x, is inside its tube: within the minor radius of
+// the circle of radius Radius in the yz plane
+func (o *ObjectSpec) insideTorus(x, y, z float64) bool {
+	d := math.Sqrt(y*y+z*z) - o.Radius
+	a := o.minorRadius()
+	return x*x+d*d <= a*a
+}
+
+// TorusFit is the approximate potential flow past a torus in a stream
+// along its axis: the free stream plus the flow of ring sources on a circle
+// inside the tube, coaxial with the torus, whose strengths are fitted so
+// the flow is tangent to the tube at Collocation points around it, in the
+// least squares sense and with no net outflow. The flow is exactly
+// axisymmetric, so fitting the meridional section suffices. Max and RMS
+// measure the normal velocity |v·n|/U left on the surface, halfway between
+// the collocation points: about 1e-3 with the default Collocation, falling
+// by orders of magnitude each time it doubles. The fit has no circulation
+// around the tube.
+type TorusFit struct {
+	Ratio       float64 // Minor over major radius
+	Collocation int
+	Rings       int
+	Max, RMS    float64
+
+	x, r, q []float64 // Ring positions, in major radii, and strengths, the volume flux over U·R²
+}
+
+// lastTorus holds the last fit, since tori differing only in size and
+// speed share their fit and a flow's kernel is prepared often
+var lastTorus struct {
+	sync.Mutex
+	fit *TorusFit
+}
+
+// torusFit returns the fit of a torus of minor to major radius ratio over
+// n collocation points
+func torusFit(ratio float64, n int) (*TorusFit, error) {
+	lastTorus.Lock()
+	defer lastTorus.Unlock()
+	if t := lastTorus.fit; t != nil && t.Ratio == ratio && t.Collocation == n {
+		return t, nil
+	}
+	m := n / torusRingsPerCollocation
+	t := &TorusFit{Ratio: ratio, Collocation: n, Rings: m, x: make([]float64, m), r: make([]float64, m)}
+	for j := range t.x {
+		s, c := math.Sincos(2 * math.Pi * (float64(j) + 0.5) / float64(m))
+		t.x[j], t.r[j] = torusRingFraction*ratio*c, 1+torusRingFraction*ratio*s
+	}
+	// The normal velocity of every ring at the surface point at angle θ
+	// around the tube, from the upstream point
+	normal := func(theta float64) ([]float64, float64) {
+		s, c := math.Sincos(theta)
+		row := make([]float64, m)
+		for j := range row {
+			ux, ur := ringSource(ratio*c, 1+ratio*s, t.x[j], t.r[j])
+			row[j] = ux*c + ur*s
+		}
+		return row, c
+	}
+	// Normal equations of the fit, bordered by the constraint Σ q = 0
+	a := make([][]float64, m+1)
+	for i := range a {
+		a[i] = make([]float64, m+1)
+	}
+	b := make([]float64, m+1)
+	for i := 0; i < n; i++ {
+		row, free := normal(2 * math.Pi * float64(i) / float64(n))
+		for j := range row {
+			for k := range row {
+				a[j][k] += row[j] * row[k]
+			}
+			b[j] -= row[j] * free
+		}
+	}
+	for j := 0; j < m; j++ {
+		a[j][m], a[m][j] = 1, 1
+	}
+	q, err := solveLinear(a, b)
+	if err != nil {
+		return nil, Errorf(ErrBadArguments, "torus fit: %v", err)
+	}
+	t.q = q[:m]
+	sum := 0.0
+	for i := 0; i < n; i++ {
+		row, free := normal(2 * math.Pi * (float64(i) + 0.5) / float64(n))
+		v := free
+		for j, w := range row {
+			v += w * t.q[j]
+		}
+		t.Max = math.Max(t.Max, math.Abs(v))
+		sum += v * v
+	}
+	t.RMS = math.Sqrt(sum / float64(n))
+	lastTorus.fit = t
+	return t, nil
+}
+
+// ringSource returns the axial and radial velocity at axial position x
+// and distance r from the axis of a ring source of unit volume flux at x0
+// of radius r0. Its elements, point sources Q·dθ/2π at distance D, add up
+// to
+//
+//	u_x = Q/8π²·(x - x0)·I₁,  u_r = Q/8π²·(r·I₁ - r0·I_c)
+//
+// with I₁ = ∫dθ/D³ = 4E(m)/(B·√A) and I_c = ∫cos θ dθ/D³ =
+// 4(P·E(m)/B - K(m))/(2r·r0·√A), where P = (x - x0)² + r² + r0², A and B
+// are P ± 2r·r0 and m = 4r·r0/A.
+func ringSource(x, r, x0, r0 float64) (ux, ur float64) {
+	dx := x - x0
+	if r < 1e-9*r0 {
+		// On the axis, where only the axial part is left
+		d2 := dx*dx + r0*r0
+		return dx / (4 * math.Pi * d2 * math.Sqrt(d2)), 0
+	}
+	p := dx*dx + r*r + r0*r0
+	q := 2 * r * r0
+	a, b := p+q, p-q
+	k, e := ellipticKE(2 * q / a)
+	sa := math.Sqrt(a)
+	i1 := 4 * e / (b * sa)
+	ic := 4 * (p*e/b - k) / (q * sa)
+	const scale = 1 / (8 * math.Pi * math.Pi)
+	return scale * dx * i1, scale * (r*i1 - r0*ic)
+}
+
+// ellipticKE returns the complete elliptic integrals of the first and
+// second kind of parameter m < 1, by the arithmetic-geometric mean
+func ellipticKE(m float64) (k, e float64) {
+	a, b := 1.0, math.Sqrt(1-m)
+	sum, pow := m/2, 0.5
+	for i := 0; i < 32; i++ {
+		c := (a - b) / 2
+		a, b = (a+b)/2, math.Sqrt(a*b)
+		pow *= 2
+		sum += pow * c * c
+		if c < 1e-17*a {
+			break
+		}
+	}
+	k = math.Pi / (2 * a)
+	return k, k * (1 - sum)
+}
+
+// torusFrame prepares the ring sources of k, scaled to the torus and the
+// onset speed. A fit that fails, which Validate reports, leaves none.
+func (k *kernel) torusFrame() {
+	o := &k.obj
+	if o.Radius == 0 {
+		return
+	}
+	t, err := torusFit(o.minorRadius()/o.Radius, o.collocation())
+	if err != nil {
+		return
+	}
+	r := o.Radius
+	k.rings = make([][3]float64, t.Rings)
+	for j := range k.rings {
+		k.rings[j] = [3]float64{t.x[j] * r, t.r[j] * r, t.q[j] * k.u * r * r}
+	}
+}
+
+// torus is local for a torus about the x axis: the free stream plus its
+// ring sources
+func (k *kernel) torus(x, y, z float64) (vx, vy, vz float64) {
+	if k.obj.insideTorus(x, y, z) {
+		return 0, 0, 0
+	}
+	r := math.Sqrt(y*y + z*z)
+	vx = k.u
+	ur := 0.0
+	for _, ring := range k.rings {
+		ux, uy := ringSource(x, r, ring[0], ring[1])
+		vx += ring[2] * ux
+		ur += ring[2] * uy
+	}
+	if r == 0 {
+		return vx, 0, 0
+	}
+	return vx, ur * y / r, ur * z / r
+}
+
+// TorusFit returns the fit of the ring sources of the torus of f
+func (f *Flow) TorusFit() (TorusFit, error) {
+	if err := f.Validate(); err != nil {
+		return TorusFit{}, err
+	}
+	o := &f.Object
+	if o.Type != Torus || o.Radius == 0 {
+		return TorusFit{}, Errorf(ErrBadArguments, "a torus fit needs a torus, not a %v", o.Type)
+	}
+	t, err := torusFit(o.minorRadius()/o.Radius, o.collocation())
+	if err != nil {
+		return TorusFit{}, err
+	}
+	return *t, nil
+}
+
+// Encode returns t in generic form
+func (t TorusFit) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"ratio":       t.Ratio,
+		"collocation": t.Collocation,
+		"rings":       t.Rings,
+		"residual":    map[string]interface{}{"max": t.Max, "rms": t.RMS},
+	}
+}
@@ -0,0 +1,41 @@
+package flow_test
+
+import (
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestTorus(t *testing.T) {
+	run(t, (*checker).checkTorus)
+}
+
+// checkTorus verifies the torus fit: its residual with the default
+// collocation, the tangency of the whole surface with a finer one, the
+// flow speeding up through the hole, the tube as the inside, and the
+// rejection of a stream across the axis
+func (c *checker) checkTorus() error {
+	f := checkFlow(flow.Torus)
+	fit, err := f.TorusFit()
+	if err != nil {
+		return err
+	}
+	fine := f
+	fine.Object.Collocation = 128
+	res, err := fine.TangencyResidual(4096)
+	if err != nil {
+		return err
+	}
+	throat, _, _ := fine.VelocityAt(0, 0, 0)
+	o := f.Object
+	inside := !o.Contains(0, 0, 0) && o.Contains(0, 1, 0) && o.Contains(0, 0, -1.24) && !o.Contains(0, 0, -1.26)
+	across := f
+	across.Direction = [3]float64{0, 1, 0}
+	e, rejected := across.Validate().(*flow.Error)
+	rejected = rejected && e.Code == flow.ErrUnsupported
+
+	ok := fit.Max < torusTolerance && res.Max < torusFineTolerance && throat > 1 && inside && rejected
+	c.report("torus", ok, "fit residual max %.3g, rms %.3g over %d rings; tangency at 128 points %.3g; throat u/U %.4f; inside %v; cross-flow rejected %v",
+		fit.Max, fit.RMS, fit.Rings, res.Max, throat, inside, rejected)
+	return nil
+}
@@ -0,0 +1,212 @@
+package flow
+
+import "math"
+
+// StreamTurbulence names the stream, seeded by Turbulence.Seed rather than
+// by the simulation's Random, that draws the modes of a Turbulence
+const StreamTurbulence = "turbulence"
+
+// Limits of Turbulence
+const (
+	DefaultTurbulenceModes = 256
+	MaxTurbulenceModes     = 4096
+)
+
+// vonKarmanPeak is k_e·L, the wavenumber of the peak of the von Kármán
+// spectrum times the integral length scale
+const vonKarmanPeak = 0.747
+
+// The smallest and largest wavenumbers of the modes, relative to k_e:
+// below the range the spectrum holds a millionth of the energy, above it
+// some percent, the dissipation range the particles wouldn't resolve
+const (
+	turbulenceLow  = 0.1
+	turbulenceHigh = 100
+)
+
+// Turbulence is a frozen field of velocity fluctuations added to the
+// particles' velocities by Simulation.Step: a sum of Modes random Fourier
+// modes, drawn from the von Kármán energy spectrum
+//
+//	E(k) ∝ (k/k_e)⁴/(1 + (k/k_e)²)^(17/6), k_e = 0.747/Scale
+//
+// of integral length scale Scale, the wavenumbers spaced logarithmically
+// from k_e/10 to 100·k_e, each with a random direction, phase and
+// orientation. Each mode's amplitude is normal to its wavenumber, so the
+// field is divergence-free by construction, and the amplitudes are scaled
+// for an rms fluctuation of Intensity times the free stream speed along
+// every axis. The same Seed draws the same field. By Taylor's hypothesis
+// the field is carried past the object by the free stream, in the body
+// frame, and is fixed in the fluid at rest in the lab frame: Offset is how
+// far it has moved.
+//
+// The fluctuation moves the particles outside the object, but their
+// velocities keep those of the mean flow once advected, so LOD and
+// UpdatePartial can reuse them and pressures are those of the mean flow.
+// An Intensity of 0 leaves the simulation as it is bit for bit. A runtime
+// setting; Reset brings the field back to its start.
+type Turbulence struct {
+	Intensity float64 // Rms fluctuation over the free stream speed, per axis
+	Scale     float64 // Integral length scale, m
+	Modes     int
+	Seed      uint64
+	Offset    [3]float64 // How far the field has been carried, m
+
+	modes []turbulenceMode
+}
+
+// turbulenceMode is the mode a·cos(k·x + phase) of a Turbulence, for an
+// rms of 1 along every axis
+type turbulenceMode struct {
+	k, a  [3]float64
+	phase float64
+}
+
+// Validate checks the parameters of t
+func (t *Turbulence) Validate() error {
+	if !(t.Intensity >= 0) || math.IsInf(t.Intensity, 0) {
+		return Errorf(ErrBadArguments, "turbulence: intensity must be a non-negative finite number, got %g", t.Intensity)
+	}
+	if !(t.Scale > 0) || math.IsInf(t.Scale, 0) {
+		return Errorf(ErrBadArguments, "turbulence: scale must be a positive finite length, got %g", t.Scale)
+	}
+	if t.Modes < 1 || t.Modes > MaxTurbulenceModes {
+		return Errorf(ErrBadArguments, "turbulence: modes must be between 1 and %d, got %d", MaxTurbulenceModes, t.Modes)
+	}
+	if t.Seed > MaxSeed {
+		return Errorf(ErrBadArguments, "turbulence: seed must be an integer between 0 and %d, got %d", uint64(MaxSeed), t.Seed)
+	}
+	return nil
+}
+
+// draw draws the modes of t from its seed
+func (t *Turbulence) draw() {
+	r := newStream(t.Seed, StreamTurbulence)
+	ke := vonKarmanPeak / t.Scale
+	span := math.Log(turbulenceHigh / turbulenceLow)
+	t.modes = make([]turbulenceMode, t.Modes)
+	energy := 0.0
+	for i := range t.modes {
+		m := &t.modes[i]
+		// Logarithmic spacing, dk = k·span/Modes
+		q := turbulenceLow * math.Exp(span*(float64(i)+0.5)/float64(t.Modes))
+		e := q * q * q * q / math.Pow(1+q*q, 17.0/6)
+		amp := math.Sqrt(e * q * span / float64(t.Modes))
+		energy += amp * amp
+
+		// A direction uniform on the sphere, and one normal to it
+		cz := 2*r.Float64() - 1
+		sz := math.Sqrt(1 - cz*cz)
+		s, c := math.Sincos(2 * math.Pi * r.Float64())
+		dir := [3]float64{sz * c, sz * s, cz}
+		_, u, v := normalAxes(dir)
+		s, c = math.Sincos(2 * math.Pi * r.Float64())
+		for a := range dir {
+			m.k[a] = q * ke * dir[a]
+			m.a[a] = amp * (c*u[a] + s*v[a])
+		}
+		m.phase = 2 * math.Pi * r.Float64()
+	}
+	// ⟨|u|²⟩ = Σ a²/2, 3 for an rms of 1 along every axis
+	f := math.Sqrt(6 / energy)
+	for i := range t.modes {
+		for a := range t.modes[i].a {
+			t.modes[i].a[a] *= f
+		}
+	}
+}
+
+// At returns the fluctuation of t at (x, y, z) for an rms of 1 along every
+// axis, the field carried by Offset
+func (t *Turbulence) At(x, y, z float64) [3]float64 {
+	if len(t.modes) != t.Modes {
+		t.draw()
+	}
+	x, y, z = x-t.Offset[0], y-t.Offset[1], z-t.Offset[2]
+	var v [3]float64
+	for i := range t.modes {
+		m := &t.modes[i]
+		c := math.Cos(m.k[0]*x + m.k[1]*y + m.k[2]*z + m.phase)
+		v[0] += m.a[0] * c
+		v[1] += m.a[1] * c
+		v[2] += m.a[2] * c
+	}
+	return v
+}
+
+// SetTurbulence adds the fluctuations of t to the flow of s from the next
+// step on, its field starting where it is, or removes them if t is nil
+func (s *Simulation) SetTurbulence(t *Turbulence) error {
+	if t != nil {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		t.Offset = [3]float64{}
+		t.draw()
+	}
+	s.Turbulence = t
+	return nil
+}
+
+// stir adds the fluctuations of s.Turbulence to the velocities of the
+// particles outside the object of f, for advection, keeping the mean flow
+// velocities in calm to restore them with; false if there are none
+func (s *Simulation) stir(f *Flow, count int) bool {
+	t := s.Turbulence
+	if t == nil || t.Intensity == 0 {
+		return false
+	}
+	u := f.freeVelocity()
+	rms := t.Intensity * math.Sqrt(u[0]*u[0]+u[1]*u[1]+u[2]*u[2])
+	if rms == 0 {
+		return false
+	}
+	if len(t.modes) != t.Modes {
+		t.draw()
+	}
+	s.calm = append(s.calm[:0], s.Velocities[:count*3]...)
+	parallel(count, func(from, to int) (int, error) {
+		for i := from; i < to; i++ {
+			x, y, z := float64(s.Positions[i*3]), float64(s.Positions[i*3+1]), float64(s.Positions[i*3+2])
+			if f.solid(x, y, z) {
+				continue
+			}
+			d := t.At(x, y, z)
+			for a := range d {
+				s.Velocities[i*3+a] += float32(rms * d[a])
+			}
+		}
+		return 0, nil
+	})
+	return true
+}
+
+// carry moves the field of s.Turbulence with the free stream of f over
+// dt: along it in the body frame, not at all in the lab frame, where the
+// fluid far away is at rest
+func (s *Simulation) carry(f *Flow, dt float64) {
+	t := s.Turbulence
+	if t == nil || f.Frame == FrameLab {
+		return
+	}
+	u := f.freeVelocity()
+	for a := range t.Offset {
+		t.Offset[a] += u[a] * dt
+	}
+}
+
+// turbulenceState returns the turbulence section of State
+func (s *Simulation) turbulenceState() map[string]interface{} {
+	t := s.Turbulence
+	if t == nil {
+		return map[string]interface{}{"enabled": false}
+	}
+	return map[string]interface{}{
+		"enabled":   true,
+		"intensity": t.Intensity,
+		"scale":     t.Scale,
+		"modes":     t.Modes,
+		"seed":      t.Seed,
+		"offset":    []interface{}{t.Offset[0], t.Offset[1], t.Offset[2]},
+	}
+}
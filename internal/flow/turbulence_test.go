@@ -0,0 +1,120 @@
+package flow_test
+
+import (
+	"math"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestTurbulence(t *testing.T) {
+	run(t, (*checker).checkTurbulence)
+}
+
+// checkTurbulence verifies that the synthetic turbulence is
+// divergence-free, by central differences, with an rms near 1 per axis
+// over many integral scales; that the same seed draws the same field and
+// another a different one; that intensity 0 steps exactly as without it;
+// that the field moves particles but not their velocities; and that it is
+// carried by the free stream in the body frame and stays put in the lab
+// frame
+func (c *checker) checkTurbulence() error {
+	t := flow.Turbulence{Intensity: 0.1, Scale: 1, Modes: flow.DefaultTurbulenceModes, Seed: 7}
+	const n, h = 20000, 1e-6
+	var sum [3]float64
+	divergence, gradient := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		x, y, z := 50*math.Mod(float64(i)*0.6180339887, 1), 50*math.Mod(float64(i)*0.7548776662, 1), 50*math.Mod(float64(i)*0.5698402910, 1)
+		v := t.At(x, y, z)
+		for a := range v {
+			sum[a] += v[a] * v[a]
+		}
+		if i%20 != 0 {
+			continue
+		}
+		div := 0.0
+		for a := 0; a < 3; a++ {
+			var d [3]float64
+			d[a] = h
+			p, m := t.At(x+d[0], y+d[1], z+d[2]), t.At(x-d[0], y-d[1], z-d[2])
+			for b := range p {
+				gradient = math.Max(gradient, math.Abs(p[b]-m[b])/(2*h))
+			}
+			div += (p[a] - m[a]) / (2 * h)
+		}
+		divergence = math.Max(divergence, math.Abs(div))
+	}
+	rms := 0.0
+	for a := range sum {
+		rms = math.Max(rms, math.Abs(math.Sqrt(sum[a]/n)-1))
+	}
+	same := t
+	other := flow.Turbulence{Intensity: 0.1, Scale: 1, Modes: flow.DefaultTurbulenceModes, Seed: 8}
+	p := [3]float64{0.3, -1.2, 2.5}
+	a, b, o := t.At(p[0], p[1], p[2]), (&same).At(p[0], p[1], p[2]), other.At(p[0], p[1], p[2])
+	seeded := a == b && a != o
+
+	start := []float32{-4, 0.5, 0.2, -3, -1.5, 0.7, -5, 2, -1, 2, 2, 2}
+	run := func(frame string, tu *flow.Turbulence) (*flow.Simulation, error) {
+		config := flow.DefaultConfig()
+		config.Frame = frame
+		s := flow.NewSimulation(config)
+		if err := s.SetParticles(start, len(start)/3); err != nil {
+			return nil, err
+		}
+		if err := s.SetTurbulence(tu); err != nil {
+			return nil, err
+		}
+		for j := 0; j < 10; j++ {
+			if err := s.Step(0.02); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	}
+	calm, err := run(flow.FrameBody, nil)
+	if err != nil {
+		return err
+	}
+	zero, err := run(flow.FrameBody, &flow.Turbulence{Scale: 1, Modes: 16})
+	if err != nil {
+		return err
+	}
+	stirred, err := run(flow.FrameBody, &flow.Turbulence{Intensity: 0.2, Scale: 0.5, Modes: 64, Seed: 3})
+	if err != nil {
+		return err
+	}
+	exact, moved, kept := true, 0.0, true
+	for i := range start {
+		exact = exact && zero.Positions[i] == calm.Positions[i] && zero.Velocities[i] == calm.Velocities[i]
+		moved = math.Max(moved, math.Abs(float64(stirred.Positions[i]-calm.Positions[i])))
+	}
+	// The velocities of the last step, evaluated where the particles were
+	// before it, are those of the mean flow there
+	again := flow.NewSimulation(flow.DefaultConfig())
+	before := append([]float32(nil), stirred.Positions...)
+	if err := again.SetParticles(before, len(before)/3); err != nil {
+		return err
+	}
+	if err := again.Step(0.02); err != nil {
+		return err
+	}
+	if err := stirred.Step(0.02); err != nil {
+		return err
+	}
+	for i := range start {
+		kept = kept && stirred.Velocities[i] == again.Velocities[i]
+	}
+	carried := math.Abs(stirred.Turbulence.Offset[0]-0.22) < 1e-12 && stirred.Turbulence.Offset[1] == 0
+	lab, err := run(flow.FrameLab, &flow.Turbulence{Intensity: 0.2, Scale: 0.5, Modes: 64})
+	if err != nil {
+		return err
+	}
+	still := lab.Turbulence.Offset == [3]float64{}
+	bad := (&flow.Turbulence{Intensity: 0.1, Scale: 0, Modes: 16}).Validate() != nil && (&flow.Turbulence{Intensity: 0.1, Scale: 1}).Validate() != nil
+
+	c.report("turbulence", divergence < 1e-6*gradient && rms < 0.1 && seeded && exact && moved > 1e-3 && kept && carried && still && bad,
+		"max |∇·u| %.3g against gradients up to %.3g; rms per axis off 1 by %.3g; seeded %v; intensity 0 exact %v; particles moved by up to %.3g, velocities kept %v; carried by the stream %v, still in the lab frame %v; invalid rejected %v",
+		divergence, gradient, rms, seeded, exact, moved, kept, carried, still, bad)
+	return nil
+}
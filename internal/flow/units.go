@@ -0,0 +1,375 @@
+package flow
+
+import (
+	"math"
+	"sort"
+)
+
+// Dim is the physical dimension of a quantity exchanged with the API
+type Dim int
+
+// Dimensions converted by Units. Time is always in seconds, so a rate is a
+// velocity over a length and an acceleration is converted as a velocity.
+const (
+	DimNone Dim = iota
+	DimLength
+	DimVelocity
+	DimDensity
+	DimPressure
+	DimRate  // Velocity per length, e.g. a shear rate
+	DimRate2 // Squared rate, e.g. a vortex criterion
+)
+
+// unitFactors maps the unit names of each dimension to their size in SI
+// units
+var unitFactors = map[Dim]map[string]float64{
+	DimLength: {
+		"m": 1, "mm": 1e-3, "cm": 1e-2, "km": 1e3, "in": 0.0254, "ft": 0.3048,
+	},
+	DimVelocity: {
+		"m/s": 1, "mm/s": 1e-3, "km/h": 1 / 3.6, "kn": 1852.0 / 3600, "mph": 0.44704, "ft/s": 0.3048,
+	},
+	DimDensity: {
+		"kg/m3": 1, "g/cm3": 1e3, "g/L": 1, "lb/ft3": 16.018463373960138,
+	},
+	DimPressure: {
+		"Pa": 1, "hPa": 1e2, "kPa": 1e3, "MPa": 1e6, "mbar": 1e2, "bar": 1e5, "atm": 101325, "psi": 6894.757293168361,
+	},
+}
+
+// Units is the unit system of the values exchanged with the API, one unit
+// name per dimension (see UnitNames). The simulation itself works in SI
+// units: values are multiplied by Factor on the way in and divided by it
+// on the way out.
+type Units struct {
+	Length, Velocity, Density, Pressure string
+}
+
+// SIUnits returns the SI unit system, the default
+func SIUnits() Units {
+	return Units{Length: "m", Velocity: "m/s", Density: "kg/m3", Pressure: "Pa"}
+}
+
+// UnitNames returns the unit names known for d, sorted
+func UnitNames(d Dim) []string {
+	names := make([]string, 0, len(unitFactors[d]))
+	for name := range unitFactors[d] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks that every unit of u is known
+func (u *Units) Validate() error {
+	for _, e := range []struct {
+		key, name string
+		d         Dim
+	}{{"length", u.Length, DimLength}, {"velocity", u.Velocity, DimVelocity}, {"density", u.Density, DimDensity}, {"pressure", u.Pressure, DimPressure}} {
+		if _, ok := unitFactors[e.d][e.name]; !ok {
+			return Errorf(ErrBadArguments, "units.%s: unknown unit %q, expected one of %v", e.key, e.name, UnitNames(e.d))
+		}
+	}
+	return nil
+}
+
+// Factor returns the size of u's unit of d in SI units, 1 for DimNone
+func (u *Units) Factor(d Dim) float64 {
+	switch d {
+	case DimLength:
+		return unitFactors[d][u.Length]
+	case DimVelocity:
+		return unitFactors[d][u.Velocity]
+	case DimDensity:
+		return unitFactors[d][u.Density]
+	case DimPressure:
+		return unitFactors[d][u.Pressure]
+	case DimRate:
+		return u.Factor(DimVelocity) / u.Factor(DimLength)
+	case DimRate2:
+		r := u.Factor(DimRate)
+		return r * r
+	}
+	return 1
+}
+
+// Name returns the name of u's unit of d, "" for DimNone
+func (u *Units) Name(d Dim) string {
+	switch d {
+	case DimLength:
+		return u.Length
+	case DimVelocity:
+		return u.Velocity
+	case DimDensity:
+		return u.Density
+	case DimPressure:
+		return u.Pressure
+	case DimRate:
+		return u.Velocity + "/" + u.Length
+	case DimRate2:
+		return "(" + u.Velocity + "/" + u.Length + ")^2"
+	}
+	return ""
+}
+
+// ToSI converts x from u's unit of d to SI
+func (u *Units) ToSI(d Dim, x float64) float64 { return x * u.Factor(d) }
+
+// FromSI converts x from SI to u's unit of d
+func (u *Units) FromSI(d Dim, x float64) float64 { return x / u.Factor(d) }
+
+// Encode returns u in generic form: the unit names and, under factors,
+// their sizes in SI units
+func (u *Units) Encode() map[string]interface{} {
+	return map[string]interface{}{
+		"length":   u.Length,
+		"velocity": u.Velocity,
+		"density":  u.Density,
+		"pressure": u.Pressure,
+		"factors": map[string]interface{}{
+			"length":   u.Factor(DimLength),
+			"velocity": u.Factor(DimVelocity),
+			"density":  u.Factor(DimDensity),
+			"pressure": u.Factor(DimPressure),
+		},
+	}
+}
+
+// DecodeUnits decodes a unit system from generic values (see
+// DecodeConfig): {length = "m", velocity = "m/s", density = "kg/m3",
+// pressure = "Pa"}, so that single overrides keep SI for the rest
+func DecodeUnits(v interface{}) (Units, error) {
+	u := SIUnits()
+	m, err := section(v, "units", "length", "velocity", "density", "pressure")
+	if err != nil {
+		return u, err
+	}
+	for _, e := range []struct {
+		key string
+		dst *string
+	}{{"length", &u.Length}, {"velocity", &u.Velocity}, {"density", &u.Density}, {"pressure", &u.Pressure}} {
+		if v, ok := m[e.key]; ok {
+			if *e.dst, ok = v.(string); !ok {
+				return u, Errorf(ErrBadArguments, "units.%s must be a string", e.key)
+			}
+		}
+	}
+	return u, u.Validate()
+}
+
+// Label returns name followed by u's unit of d in brackets, e.g. "x [mm]",
+// or name alone for DimNone
+func (u *Units) Label(name string, d Dim) string {
+	if d == DimNone {
+		return name
+	}
+	return name + " [" + u.Name(d) + "]"
+}
+
+// Scale multiplies the values of buf by factor in place, skipping the
+// work for a factor of 1
+func Scale[T Float](buf []T, factor float64) {
+	if factor == 1 {
+		return
+	}
+	for i := range buf {
+		buf[i] = T(float64(buf[i]) * factor)
+	}
+}
+
+// Convert scales the numbers of the generic value v (see DecodeConfig)
+// whose dot-separated key path from the root is listed in paths, between
+// u and SI: to SI if toSI, from SI otherwise. Arrays are scaled element by
+// element under the path of the array, so "object.position" covers all
+// three coordinates and "objects.radius" the radius of every entry of an
+// objects array. Maps are modified in place; the converted value is
+// returned.
+func (u *Units) Convert(v interface{}, paths map[string]Dim, toSI bool) interface{} {
+	return u.convert(v, "", paths, toSI)
+}
+
+func (u *Units) convert(v interface{}, path string, paths map[string]Dim, toSI bool) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			x[k] = u.convert(e, p, paths, toSI)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = u.convert(e, path, paths, toSI)
+		}
+	case float64:
+		if d, ok := paths[path]; ok && !math.IsNaN(x) {
+			if toSI {
+				return u.ToSI(d, x)
+			}
+			return u.FromSI(d, x)
+		}
+	}
+	return v
+}
+
+// FieldDim returns the dimension of a scalar field (see ScalarFields)
+func FieldDim(field string) Dim {
+	switch field {
+	case FieldSpeed, FieldVX, FieldVY, FieldVZ:
+		return DimVelocity
+	case FieldPressure:
+		return DimPressure
+	case FieldQ, FieldLambda2:
+		return DimRate2
+	}
+	return DimNone
+}
+
+// ConfigDims lists the dimensional keys of a configuration (see Config)
+var ConfigDims = map[string]Dim{
+	"freeStream.speed":             DimVelocity,
+	"freeStream.profile.rate":      DimRate,
+	"freeStream.profile.y0":        DimLength,
+	"freeStream.profile.zref":      DimLength,
+	"freeStream.profile.z0":        DimLength,
+	"freeStream.profile.floor":     DimLength,
+	"freeStream.profile.heights":   DimLength,
+	"freeStream.profile.thickness": DimLength,
+	"freeStream.shear.rate":        DimRate,
+	"freeStream.shear.y0":          DimLength,
+	"freeStream.swirl.radius":      DimLength,
+	"fluid.density":                DimDensity,
+	"object.position":              DimLength,
+	"object.radius":                DimLength,
+	"object.collisionRadius":       DimLength,
+	"object.edgeRadius":            DimLength,
+	"object.chord":                 DimLength,
+	"object.semiMinor":             DimLength,
+	"object.semiAxes":              DimLength,
+	"object.minorRadius":           DimLength,
+	"object.size":                  DimLength,
+	"object.splitter":              DimLength,
+	"boundaries.height":            DimLength,
+	"channel.inletHeight":          DimLength,
+	"channel.throatHeight":         DimLength,
+	"channel.start":                DimLength,
+	"channel.inletLength":          DimLength,
+	"channel.convergeLength":       DimLength,
+	"channel.throatLength":         DimLength,
+	"channel.divergeLength":        DimLength,
+	"bump.height":                  DimLength,
+	"bump.width":                   DimLength,
+	"bump.x":                       DimLength,
+	"rotor.radius":                 DimLength,
+	"rotor.inducedVelocity":        DimVelocity,
+	"rotor.position":               DimLength,
+	"rotor.ground":                 DimLength,
+}
+
+// ProfileDims lists the dimensional keys of a profile (see DecodeProfile)
+var ProfileDims = map[string]Dim{
+	"rate":      DimRate,
+	"y0":        DimLength,
+	"zref":      DimLength,
+	"z0":        DimLength,
+	"floor":     DimLength,
+	"heights":   DimLength,
+	"thickness": DimLength,
+}
+
+// MotionDims lists the dimensional keys of a motion (see DecodeMotion).
+// Periods and durations are in seconds.
+var MotionDims = map[string]Dim{
+	"params.velocity":     DimVelocity,
+	"params.acceleration": DimVelocity,
+	"params.center":       DimLength,
+	"params.points":       DimLength,
+	"start":               DimLength,
+}
+
+// BoundaryDims lists the dimensional keys of a boundary (see
+// DecodeBoundary)
+var BoundaryDims = map[string]Dim{"height": DimLength}
+
+// ChannelDims lists the dimensional keys of a channel (see DecodeChannel)
+var ChannelDims = map[string]Dim{
+	"inletHeight":    DimLength,
+	"throatHeight":   DimLength,
+	"start":          DimLength,
+	"inletLength":    DimLength,
+	"convergeLength": DimLength,
+	"throatLength":   DimLength,
+	"divergeLength":  DimLength,
+}
+
+// BumpDims lists the dimensional keys of a bump (see DecodeBump)
+var BumpDims = map[string]Dim{"height": DimLength, "width": DimLength, "x": DimLength}
+
+// RotorDims lists the dimensional keys of a rotor (see DecodeRotor); its
+// thrust is always in newtons
+var RotorDims = map[string]Dim{"radius": DimLength, "inducedVelocity": DimVelocity, "position": DimLength, "ground": DimLength}
+
+// GridDims lists the dimensional keys of a grid or seeding spec (see
+// DecodeGrid and DecodeSeedSpec)
+var GridDims = map[string]Dim{"min": DimLength, "max": DimLength}
+
+// StateDims lists the dimensional keys of Simulation.State, besides the
+// free stream schedule, whose pairs mix seconds and speeds
+var StateDims = map[string]Dim{
+	"objects.position":             DimLength,
+	"objects.radius":               DimLength,
+	"objects.collisionRadius":      DimLength,
+	"objects.edgeRadius":           DimLength,
+	"objects.semiMinor":            DimLength,
+	"objects.minorRadius":          DimLength,
+	"objects.size":                 DimLength,
+	"objects.splitter":             DimLength,
+	"objects.velocity":             DimVelocity,
+	"objects.target.position":      DimLength,
+	"objects.target.maxSpeed":      DimVelocity,
+	"turbulence.scale":             DimLength,
+	"turbulence.offset":            DimLength,
+	"rotatingFrame.center":         DimLength,
+	"symmetry.offset":              DimLength,
+	"symmetry.band":                DimLength,
+	"freeStream.speed":             DimVelocity,
+	"freeStream.profile.rate":      DimRate,
+	"freeStream.profile.y0":        DimLength,
+	"freeStream.profile.zref":      DimLength,
+	"freeStream.profile.z0":        DimLength,
+	"freeStream.profile.floor":     DimLength,
+	"freeStream.profile.heights":   DimLength,
+	"freeStream.profile.thickness": DimLength,
+	"freeStream.swirl.radius":      DimLength,
+	"elements.position":            DimLength,
+	"elements.coreRadius":          DimLength,
+	"boundaries.height":            DimLength,
+	"channel.inletHeight":          DimLength,
+	"channel.throatHeight":         DimLength,
+	"channel.start":                DimLength,
+	"channel.inletLength":          DimLength,
+	"channel.convergeLength":       DimLength,
+	"channel.throatLength":         DimLength,
+	"channel.divergeLength":        DimLength,
+	"bump.height":                  DimLength,
+	"bump.width":                   DimLength,
+	"bump.x":                       DimLength,
+	"rotor.radius":                 DimLength,
+	"rotor.inducedVelocity":        DimVelocity,
+	"rotor.position":               DimLength,
+	"rotor.ground":                 DimLength,
+	"injection.injectors.position": DimLength,
+	"injection.injectors.radius":   DimLength,
+	"probes.center":                DimLength,
+	"probes.radius":                DimLength,
+}
+
+// ScaledSchedule returns a copy of sc with its speeds multiplied by factor
+func ScaledSchedule(sc *Schedule, factor float64) *Schedule {
+	out := &Schedule{Points: make([]SchedulePoint, len(sc.Points))}
+	for i, p := range sc.Points {
+		out.Points[i] = SchedulePoint{T: p.T, U: p.U * factor}
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+package flow_test
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"fluid_simulation/internal/flow"
+)
+
+func TestUnits(t *testing.T) {
+	run(t, (*checker).checkUnits)
+}
+
+// checkUnits verifies that a configuration given in mm, knots and hPa
+// decodes to its SI equivalent and encodes back unchanged, and that a CSV
+// export in those units labels its columns and matches the SI export
+func (c *checker) checkUnits() error {
+	u, err := flow.DecodeUnits(map[string]interface{}{"length": "mm", "velocity": "kn", "pressure": "hPa"})
+	if err != nil {
+		return err
+	}
+	in := map[string]interface{}{
+		"freeStream": map[string]interface{}{"speed": 20.0, "shear": map[string]interface{}{"rate": 0.01, "y0": 100.0}},
+		"object":     map[string]interface{}{"type": "sphere", "position": []interface{}{0.0, 250.0, 0.0}, "radius": 500.0},
+	}
+	config, err := flow.DecodeConfig(u.Convert(in, flow.ConfigDims, true))
+	if err != nil {
+		return err
+	}
+	kn := 1852.0 / 3600
+	rel := func(got, want float64) float64 { return math.Abs(got-want) / math.Abs(want) }
+	worst := math.Max(rel(config.FreeStream.Speed, 20*kn), rel(config.Object.Radius, 0.5))
+	worst = math.Max(worst, math.Max(rel(config.Object.Y, 0.25), rel(config.FreeStream.Profile.Rate, 0.01*kn/1e-3)))
+	worst = math.Max(worst, rel(config.FreeStream.Profile.Ref, 0.1))
+	back := u.Convert(config.Encode(), flow.ConfigDims, false).(map[string]interface{})
+	fs, obj := back["freeStream"].(map[string]interface{}), back["object"].(map[string]interface{})
+	worst = math.Max(worst, math.Max(rel(fs["speed"].(float64), 20), rel(obj["radius"].(float64), 500)))
+	worst = math.Max(worst, rel(fs["profile"].(map[string]interface{})["rate"].(float64), 0.01))
+
+	sim := flow.NewSimulation(config)
+	spec, err := flow.DecodeCSVSpec(flow.CSVRake, map[string]interface{}{
+		"from": []interface{}{-2.0, 1.0, 0.0}, "to": []interface{}{2.0, 1.0, 0.0}, "samples": 5.0,
+	})
+	if err != nil {
+		return err
+	}
+	si, err := flow.ExportCSV(spec, sim)
+	if err != nil {
+		return err
+	}
+	spec.Units = u
+	converted, err := flow.ExportCSV(spec, sim)
+	if err != nil {
+		return err
+	}
+	siRows, rows := strings.Split(string(si), "\n"), strings.Split(string(converted), "\n")
+	header := rows[0] == "s [mm],x [mm],y [mm],z [mm],vx [kn],vy [kn],vz [kn],p [hPa]"
+	factors := []float64{1e3, 1e3, 1e3, 1e3, 1 / kn, 1 / kn, 1 / kn, 1e-2}
+	for i := 1; i < len(rows) && rows[i] != ""; i++ {
+		a, b := strings.Split(siRows[i], ","), strings.Split(rows[i], ",")
+		for j := range a {
+			x, _ := strconv.ParseFloat(a[j], 64)
+			y, _ := strconv.ParseFloat(b[j], 64)
+			if x != 0 {
+				worst = math.Max(worst, rel(y, x*factors[j]))
+			}
+		}
+	}
+	c.report("units", header && worst < unitsTolerance, "max relative error %.3g over config round trip and CSV; header %q", worst, rows[0])
+	return nil
+}
@@ -0,0 +1,162 @@
+package flow
+
+import "strconv"
+
+// VTK point-data fields
+const (
+	VTKVelocity = "velocity"
+	VTKPressure = "pressure"
+	VTKInside   = "inside" // 1 inside the object, 0 in the fluid
+)
+
+// VTKStructuredPoints samples the fields on g and writes them as an ASCII
+// legacy VTK STRUCTURED_POINTS file. Points are written with x varying
+// fastest, as VTK expects; axes with a single point get unit spacing.
+// Coordinates and fields are written in the units of u, named in the
+// title line.
+func VTKStructuredPoints(f Flow, g Grid, fields []string, u Units) ([]byte, error) {
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		fields = []string{VTKVelocity, VTKPressure, VTKInside}
+	}
+	for _, name := range fields {
+		switch name {
+		case VTKVelocity, VTKPressure, VTKInside:
+		default:
+			return nil, Errorf(ErrBadArguments, "unknown VTK field %q", name)
+		}
+	}
+
+	n := g.Len()
+	positions := g.Positions()
+	velocities, err := Velocities(positions, n, f)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, 256+n*40*len(fields))
+	b = append(b, "# vtk DataFile Version 3.0\nfluid simulation flow field ("...)
+	b = append(b, vtkUnits(u, DimLength, DimVelocity, DimPressure)...)
+	b = append(b, ")\nASCII\nDATASET STRUCTURED_POINTS\n"...)
+	b = appendVTKInts(b, "DIMENSIONS", g.N[0], g.N[1], g.N[2])
+	scale := u.Factor(DimLength)
+	b = appendVTKLine(b, "ORIGIN", g.Min[0]/scale, g.Min[1]/scale, g.Min[2]/scale)
+	d := g.Spacing()
+	for a := range d {
+		d[a] /= scale
+		if g.N[a] == 1 {
+			d[a] = 1
+		}
+	}
+	b = appendVTKLine(b, "SPACING", d[0], d[1], d[2])
+	b = appendVTKInts(b, "POINT_DATA", n)
+
+	for _, name := range fields {
+		switch name {
+		case VTKVelocity:
+			b = append(b, "VECTORS velocity float\n"...)
+			Scale(velocities, 1/u.Factor(DimVelocity))
+			for i := 0; i < n; i++ {
+				v := velocities[i*3 : i*3+3]
+				b = appendVTKLine(b, "", float64(v[0]), float64(v[1]), float64(v[2]))
+			}
+		case VTKPressure:
+			pressures := make([]float32, n)
+			if err := PressuresFor(pressures, velocities, n, &f, Mask{}); err != nil {
+				return nil, err
+			}
+			if err := AddSwirlPressures(pressures, positions, n, &f, Mask{}); err != nil {
+				return nil, err
+			}
+			b = append(b, "SCALARS pressure float 1\nLOOKUP_TABLE default\n"...)
+			Scale(pressures, 1/u.Factor(DimPressure))
+			for _, p := range pressures {
+				b = appendVTKLine(b, "", float64(p))
+			}
+		case VTKInside:
+			b = append(b, "SCALARS inside unsigned_char 1\nLOOKUP_TABLE default\n"...)
+			for i := 0; i < n; i++ {
+				p := positions[i*3 : i*3+3]
+				if f.Object.Contains(float64(p[0]), float64(p[1]), float64(p[2])) {
+					b = append(b, "1\n"...)
+				} else {
+					b = append(b, "0\n"...)
+				}
+			}
+		}
+	}
+	return b, nil
+}
+
+// VTKPolyLines writes lines as an ASCII legacy VTK POLYDATA file with one
+// LINES cell per polyline, with the points in the length unit of u
+func VTKPolyLines(lines []Polyline, u Units) []byte {
+	points, size := 0, 0
+	for _, l := range lines {
+		points += l.Len()
+		size += l.Len() + 1
+	}
+	b := make([]byte, 0, 128+points*40)
+	b = append(b, "# vtk DataFile Version 3.0\nfluid simulation streamlines ("...)
+	b = append(b, vtkUnits(u, DimLength)...)
+	b = append(b, ")\nASCII\nDATASET POLYDATA\n"...)
+	scale := u.Factor(DimLength)
+	b = append(b, "POINTS "...)
+	b = strconv.AppendInt(b, int64(points), 10)
+	b = append(b, " float\n"...)
+	for _, l := range lines {
+		for i := 0; i < l.Len(); i++ {
+			b = appendVTKLine(b, "", float64(l[i*3])/scale, float64(l[i*3+1])/scale, float64(l[i*3+2])/scale)
+		}
+	}
+	b = appendVTKInts(b, "LINES", len(lines), size)
+	first := 0
+	for _, l := range lines {
+		ids := make([]int, l.Len())
+		for i := range ids {
+			ids[i] = first + i
+		}
+		b = appendVTKInts(b, strconv.Itoa(l.Len()), ids...)
+		first += l.Len()
+	}
+	return b
+}
+
+// vtkUnits names the units of u for the dimensions ds, e.g. "length mm,
+// velocity kn", for the title line
+func vtkUnits(u Units, ds ...Dim) string {
+	names := map[Dim]string{DimLength: "length", DimVelocity: "velocity", DimPressure: "pressure"}
+	s := "units:"
+	for i, d := range ds {
+		if i > 0 {
+			s += ","
+		}
+		s += " " + names[d] + " " + u.Name(d)
+	}
+	return s
+}
+
+// appendVTKLine appends an optional keyword followed by space-separated
+// values, as float32
+func appendVTKLine(b []byte, keyword string, vals ...float64) []byte {
+	b = append(b, keyword...)
+	for i, v := range vals {
+		if i > 0 || keyword != "" {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendFloat(b, float64(float32(v)), 'g', -1, 32)
+	}
+	return append(b, '\n')
+}
+
+// appendVTKInts is appendVTKLine for integers
+func appendVTKInts(b []byte, keyword string, vals ...int) []byte {
+	b = append(b, keyword...)
+	for _, v := range vals {
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, int64(v), 10)
+	}
+	return append(b, '\n')
+}
@@ -0,0 +1,192 @@
+//go:build js && wasm
+// +build js,wasm
+
+// introspect.go - State and capability discovery
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// functions lists the exported functions, as reported by getCapabilities
+var functions []interface{}
+
+func init() {
+	for _, e := range exports {
+		functions = append(functions, map[string]interface{}{"name": e.name, "async": e.async})
+	}
+}
+
+// getState() returns the state of the stateful API: simulation time,
+// particle count, objects, free stream, boundary mode, statistics, the
+// buffer pool and heap allocation counters (see pool.go), the active
+// count of the last one-shot evaluation (see mask.go), the grid of
+// buildNeighborGrid and the units of setUnits with their factors to SI, in
+// which the state is reported
+func getState(args []js.Value) (interface{}, error) {
+	st := units.Convert(sim.State(), flow.StateDims, false).(map[string]interface{})
+	if sim.Schedule != nil {
+		sc := flow.ScaledSchedule(sim.Schedule, 1/units.Factor(flow.DimVelocity))
+		st["freeStream"].(map[string]interface{})["schedule"] = sc.Encode()
+	}
+	st["units"] = units.Encode()
+	st["farField"].(map[string]interface{})["lastCall"] = farFieldLast
+	st["allocations"] = allocationState()
+	st["neighborGrid"] = neighborGridState()
+	st["mask"] = map[string]interface{}{
+		"lastCall": map[string]interface{}{"count": activeLast.count, "active": activeLast.active},
+	}
+	return js.ValueOf(st), nil
+}
+
+// getCapabilities() describes what this build supports: object types and
+// parameter ranges, the exported functions, the units setUnits knows and
+// optional features, so a frontend can build its controls without
+// hardcoding them. compiler is "gc" or "tinygo". features.inducedDrag is
+// false: a Trefftz-plane survey of induced drag needs finite wings,
+// horseshoe or lifting-line models shedding trailing vortices, and the
+// lifting sections here, plates, airfoils and tandems, are two-dimensional.
+// features.dragPolar is false for the same reason: a CL-CD polar and its
+// Oswald efficiency come from a wing's induced drag, while runAlphaSweep
+// covers the lift curve of the sections. features.multiObject is false:
+// there is one analytic object (maxObjects), so there is no registry of
+// bodies for a spatial hash to cull per particle. The elements of
+// buildFlow (up to maxElements) and the sections of solveTandem (up to
+// maxSections) superpose in one flow, every one evaluated for every
+// particle.
+func getCapabilities(args []js.Value) (interface{}, error) {
+	c := flow.Capabilities()
+	c["functions"] = functions
+	c["compiler"] = runtime.Compiler
+	colormaps := []interface{}{}
+	for _, name := range flow.Colormaps() {
+		colormaps = append(colormaps, name)
+	}
+	c["colormaps"] = colormaps
+	layouts := []interface{}{}
+	for _, name := range flow.Layouts() {
+		layouts = append(layouts, name)
+	}
+	c["interleavedLayouts"] = layouts
+	events := []interface{}{}
+	for _, name := range flow.Events() {
+		events = append(events, name)
+	}
+	c["events"] = events
+	unitNames := map[string]interface{}{}
+	for key, d := range map[string]flow.Dim{"length": flow.DimLength, "velocity": flow.DimVelocity, "density": flow.DimDensity, "pressure": flow.DimPressure} {
+		names := []interface{}{}
+		for _, name := range flow.UnitNames(d) {
+			names = append(names, name)
+		}
+		unitNames[key] = names
+	}
+	c["units"] = unitNames
+	c["features"] = map[string]interface{}{
+		"sharedArrayBuffer": !js.Global().Get("SharedArrayBuffer").IsUndefined(),
+		"chunked":           true,
+		"workerMessages":    true,
+		"stlImport":         true,
+		"panelSolver":       true,
+		"multiObject":       false,
+		"objectMotion":      true,
+		"elements":          true,
+		"thinAirfoil":       true,
+		"tandem":            true,
+		"torus":             true,
+		"box":               true,
+		"splitter":          true,
+		"channel":           true,
+		"bump":              true,
+		"rotor":             true,
+		"inducedDrag":       false,
+		"dragPolar":         false,
+	}
+	return js.ValueOf(c), nil
+}
+
+// version and revision identify the build. They are set at link time,
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.revision=$(git rev-parse HEAD)"
+//
+// as build-wasm.ps1 does. Without -X, revision falls back to the VCS stamp
+// the Go toolchain embeds when building from a checkout.
+var (
+	version  = "dev"
+	revision = ""
+)
+
+// getBuildInfo() describes this particular build, for feature detection and
+// bug reports: {version, revision, modified, compiler, goVersion, goos,
+// goarch, buildTags, objectTypes, fields, functions, threads,
+// sharedArrayBuffer: {compiled, available}, limits}. Everything is read
+// from the binary itself, so builds with other toolchains or tags report
+// what they actually contain. buildTags are the tags given with -tags,
+// when the toolchain records them. limits holds numeric limits:
+// recordingBytes and recordingParticles (the default recording budget and
+// the most particles a single frame of it holds), maxWorkers, maxSeed,
+// eventExamples and panels (0: this build has no panel solver).
+func getBuildInfo(args []js.Value) (interface{}, error) {
+	rev, modified, tags := revision, false, []interface{}{}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if rev == "" {
+					rev = s.Value
+				}
+			case "vcs.modified":
+				modified = s.Value == "true"
+			case "-tags":
+				for _, t := range strings.Split(s.Value, ",") {
+					if t != "" {
+						tags = append(tags, t)
+					}
+				}
+			}
+		}
+	}
+	types := []interface{}{}
+	for _, t := range flow.Capabilities()["objectTypes"].([]interface{}) {
+		types = append(types, t.(map[string]interface{})["name"])
+	}
+	fields := []interface{}{}
+	for _, name := range flow.ScalarFields() {
+		fields = append(fields, name)
+	}
+	compiledSAB := false
+	for _, e := range functions {
+		compiledSAB = compiledSAB || e.(map[string]interface{})["name"] == "attachBuffers"
+	}
+	return js.ValueOf(map[string]interface{}{
+		"version":     version,
+		"revision":    rev,
+		"modified":    modified,
+		"compiler":    runtime.Compiler,
+		"goVersion":   runtime.Version(),
+		"goos":        runtime.GOOS,
+		"goarch":      runtime.GOARCH,
+		"buildTags":   tags,
+		"objectTypes": types,
+		"fields":      fields,
+		"functions":   functions,
+		"threads":     runtime.GOMAXPROCS(0) > 1,
+		"sharedArrayBuffer": map[string]interface{}{
+			"compiled":  compiledSAB,
+			"available": !js.Global().Get("SharedArrayBuffer").IsUndefined(),
+		},
+		"limits": map[string]interface{}{
+			"recordingBytes":     flow.DefaultRecordingLimit,
+			"recordingParticles": flow.DefaultRecordingLimit / 12,
+			"maxWorkers":         flow.MaxWorkers,
+			"maxSeed":            float64(flow.MaxSeed),
+			"eventExamples":      flow.MaxEventExamples,
+			"panels":             0,
+		},
+	}), nil
+}
@@ -0,0 +1,87 @@
+// fluid_sim_client.js - Main-thread client for fluid_sim_worker.js
+//
+//     const sim = new FluidSimClient('js/fluid_sim_worker.js');
+//     await sim.ready;
+//     const velocities = await sim.updateVelocities(positions, count, 1, 1, 0, 0, 0, 0, 1);
+//
+// Any function exported by the Go module can be called as an async method;
+// there is no list to keep in sync. Errors reject with an Error carrying
+// the Go side's code. Result buffers are transferred from the worker; pass
+// input buffers to callTransfer to transfer them in the other direction.
+// Functions (such as onProgress callbacks) can't cross the worker boundary.
+
+class FluidSimClient {
+    constructor(workerUrl = 'js/fluid_sim_worker.js') {
+        this.worker = new Worker(workerUrl);
+        this.nextId = 1;
+        this.pending = new Map();
+        this.ready = new Promise((resolve, reject) => {
+            this.resolveReady = resolve;
+            this.rejectReady = reject;
+        });
+        this.worker.onmessage = (event) => this.receive(event.data);
+
+        // Unknown properties become remote method calls
+        return new Proxy(this, {
+            get: (target, name) => {
+                if (name in target || typeof name !== 'string') {
+                    return target[name];
+                }
+                return (...args) => target.call(name, args);
+            },
+        });
+    }
+
+    // Call a Go function by name; resolves with its result
+    call(method, args = []) {
+        return this.callTransfer(method, args, []);
+    }
+
+    // Like call, transferring the given buffers to the worker
+    callTransfer(method, args, transfer) {
+        const id = this.nextId++;
+        return new Promise((resolve, reject) => {
+            this.pending.set(id, { resolve, reject });
+            this.worker.postMessage({ id, method, args }, transfer);
+        });
+    }
+
+    receive(message) {
+        if ('ready' in message) {
+            if (message.ready) {
+                this.resolveReady();
+            } else {
+                this.rejectReady(FluidSimClient.toError(message.error));
+            }
+            return;
+        }
+        const request = this.pending.get(message.id);
+        if (!request) {
+            return;
+        }
+        this.pending.delete(message.id);
+        if (message.error) {
+            request.reject(FluidSimClient.toError(message.error));
+        } else {
+            request.resolve(message.result);
+        }
+    }
+
+    static toError({ code, message }) {
+        const err = new Error(message);
+        err.code = code;
+        return err;
+    }
+
+    terminate() {
+        this.worker.terminate();
+        for (const request of this.pending.values()) {
+            request.reject(FluidSimClient.toError({ code: 'CANCELLED', message: 'worker terminated' }));
+        }
+        this.pending.clear();
+    }
+}
+
+if (typeof module !== 'undefined' && module.exports) {
+    module.exports = FluidSimClient;
+}
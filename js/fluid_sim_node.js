@@ -0,0 +1,69 @@
+// fluid_sim_node.js - Headless loader for Node.js 18+
+//
+// Loads wasm/fluid_sim.wasm through the bundled wasm_exec.js and exposes the
+// simulation functions as promises:
+//
+//     const { loadFluidSim } = require('./js/fluid_sim_node.js');
+//     const sim = await loadFluidSim();
+//     const velocities = await sim.updateVelocities(positions, count, 1, 1, 0, 0, 0, 0, 1);
+//
+// Errors returned by the Go side (Error objects with a code property)
+// reject the promise instead of being returned as values.
+
+'use strict';
+
+const fs = require('fs');
+const path = require('path');
+
+// Provide the globals wasm_exec.js expects from a browser
+function installGlobals() {
+    globalThis.require ??= require;
+    globalThis.fs ??= fs;
+    globalThis.path ??= path;
+    globalThis.TextEncoder ??= require('util').TextEncoder;
+    globalThis.TextDecoder ??= require('util').TextDecoder;
+    globalThis.performance ??= require('perf_hooks').performance;
+    globalThis.crypto ??= require('crypto').webcrypto;
+}
+
+// Load and start the module. Options:
+//   wasmPath:     path to the .wasm file (default ../wasm/fluid_sim.wasm)
+//   wasmExecPath: path to the matching wasm_exec.js (default ./wasm_exec.js)
+async function loadFluidSim(options = {}) {
+    if (typeof process === 'undefined' || !process.versions || !process.versions.node) {
+        throw new Error('fluid_sim_node.js only runs under Node.js; use main.js in the browser');
+    }
+    const wasmPath = options.wasmPath || path.join(__dirname, '..', 'wasm', 'fluid_sim.wasm');
+    const wasmExecPath = options.wasmExecPath || path.join(__dirname, 'wasm_exec.js');
+
+    installGlobals();
+    require(wasmExecPath);
+
+    const go = new Go();
+    const { instance } = await WebAssembly.instantiate(fs.readFileSync(wasmPath), go.importObject);
+
+    // main runs synchronously until it blocks, so registration is done
+    // (and fluidSimReady set) once run() has returned its exit promise
+    const exited = go.run(instance);
+    if (!globalThis.fluidSimReady) {
+        throw new Error('fluid_sim module did not signal readiness');
+    }
+    const api = await globalThis.fluidSimReady;
+
+    // Functions marked async on the Go side already return promises
+    const sim = { exited, api };
+    for (const name of Object.keys(api)) {
+        const fn = api[name];
+        sim[name] = (...args) => new Promise((resolve, reject) => {
+            const result = fn(...args);
+            if (result instanceof Error) {
+                reject(result);
+            } else {
+                resolve(result);
+            }
+        });
+    }
+    return sim;
+}
+
+module.exports = { loadFluidSim };
@@ -0,0 +1,31 @@
+// fluid_sim_worker.js - Web Worker hosting the WebAssembly module
+//
+// Start it with new Worker('js/fluid_sim_worker.js') (optionally adding
+// ?wasm=<url> to load another build) and talk to it through
+// FluidSimClient in fluid_sim_client.js. Requests that arrive before the
+// module is ready are queued.
+
+importScripts('wasm_exec.js');
+
+const pending = [];
+self.onmessage = (event) => pending.push(event.data);
+
+(async () => {
+    const wasmUrl = new URL(self.location).searchParams.get('wasm') ||
+        new URL('../wasm/fluid_sim.wasm', self.location).href;
+    try {
+        const go = new Go();
+        const { instance } = await WebAssembly.instantiateStreaming(fetch(wasmUrl), go.importObject);
+        go.run(instance);
+        await self.fluidSimReady;
+    } catch (err) {
+        self.postMessage({ ready: false, error: { code: 'INTERNAL', message: String(err) } });
+        return;
+    }
+
+    self.onmessage = (event) => self.handleMessage(event.data);
+    for (const request of pending.splice(0)) {
+        self.handleMessage(request);
+    }
+    self.postMessage({ ready: true });
+})();
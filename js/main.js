@@ -355,6 +355,11 @@ function updateParticles() {
                 1.0 // Object radius
             );
             
+            // Argument errors come back as Error objects rather than throwing
+            if (velocityResults instanceof Error) {
+                throw velocityResults;
+            }
+            
             for (let i = 0; i < simulationParams.particleCount; i++) {
                 const idx = i * 3;
                 velocities[idx] = velocityResults[idx];
@@ -535,8 +540,8 @@ function updateVelocitiesJS() {
                         vx = freeStreamVelocity * (1 - factor*Math.cos(2*angle));
                         vy = freeStreamVelocity*(-factor*Math.sin(2*angle)) + circulation/(2*Math.PI*rxyA);
                         
-                        // Scale z velocity based on xz plane - exactly matching WASM
-                        vz = 0.1 * z * (vx*vx + vy*vy) / (radius * freeStreamVelocity);
+                        // 2D section: no spanwise (z) flow - matching WASM
+                        vz = 0;
                     } else {
                         // Inside airfoil
                         vx = 0;
@@ -584,7 +589,9 @@ async function loadWasmModule() {
         try {
             // Use wasm_exec.js bridge
             const go = new Go();
-            const response = await fetch('wasm/fluid_sim.wasm');
+            // TinyGo builds are loaded by pointing this at wasm/fluid_sim_tinygo.wasm
+            // (together with TinyGo's own wasm_exec.js, see build-wasm.ps1)
+            const response = await fetch(window.FLUID_SIM_WASM_URL || 'wasm/fluid_sim.wasm');
             
             if (!response.ok) {
                 throw new Error(`Failed to fetch WASM file: ${response.status} ${response.statusText}`);
@@ -595,6 +602,8 @@ async function loadWasmModule() {
             wasmModule = result.instance;
             go.run(wasmModule);
             
+            // The Go side resolves this once every function is registered
+            await window.fluidSimReady;
             wasmLoaded = true;
             console.log("WebAssembly module loaded successfully");
         } catch (loadError) {
@@ -0,0 +1,45 @@
+// node-smoke.js - Headless smoke test of the WebAssembly module
+//
+// Usage: node js/test/node-smoke.js [path/to/fluid_sim.wasm] [path/to/wasm_exec.js]
+
+'use strict';
+
+const assert = require('assert');
+const { loadFluidSim } = require('../fluid_sim_node.js');
+
+async function main() {
+    const sim = await loadFluidSim({ wasmPath: process.argv[2], wasmExecPath: process.argv[3] });
+
+    // 1000 particles on a line far upstream, plus two inside the sphere
+    const count = 1000;
+    const positions = new Float32Array(count * 3);
+    for (let i = 0; i < count - 2; i++) {
+        positions[i * 3] = -50;
+        positions[i * 3 + 1] = (i / (count - 2) - 0.5) * 10;
+    }
+    positions[(count - 2) * 3] = 0.5;
+    positions[(count - 1) * 3 + 2] = -0.25;
+
+    const velocities = await sim.updateVelocities(positions, count, 2, 1, 0, 0, 0, 0, 1);
+    assert(velocities instanceof Float32Array, 'velocities is a Float32Array');
+    assert.strictEqual(velocities.length, count * 3);
+    for (let i = 0; i < count - 2; i++) {
+        assert(Math.abs(velocities[i * 3] - 2) < 1e-4, `far-field particle ${i} moves with the free stream`);
+    }
+    for (let i = count - 2; i < count; i++) {
+        assert.deepStrictEqual(Array.from(velocities.subarray(i * 3, i * 3 + 3)), [0, 0, 0], 'inside particles are at rest');
+    }
+
+    const pressures = await sim.calculatePressure(velocities, count, 2, 1);
+    assert.strictEqual(pressures.length, count);
+    assert(pressures.every(Number.isFinite), 'pressures are finite');
+
+    await assert.rejects(sim.updateVelocities(positions, count + 1, 2, 1, 0, 0, 0, 0, 1), { code: 'BUFFER_LENGTH' });
+
+    console.log('node smoke test passed');
+}
+
+main().catch((err) => {
+    console.error(err);
+    process.exit(1);
+});
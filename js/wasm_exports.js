@@ -0,0 +1,146 @@
+// wasm_exports.js - Loader for the go:wasmexport build of the simulator
+//
+// wasm/fluid_sim_exports.wasm is built with GOOS=wasip1 -buildmode=c-shared
+// (see exports.go). It does not need wasm_exec.js: the small WASI shim below
+// is enough, so the module also runs in workers, Node and other hosts.
+//
+// Buffers live in the module's linear memory. Allocate them once with
+// allocFloat32, fill them through their .array view and pass the handles
+// to the kernels; no data is copied per call.
+
+(function (root) {
+    'use strict';
+
+    const STATUS_CODES = { 1: 'BAD_ARGUMENTS', 2: 'BUFFER_LENGTH' };
+    const WASI_EBADF = 8;
+    const WASI_ENOSYS = 52;
+
+    // Minimal WASI preview1 imports needed by the Go runtime
+    function wasiImports(getMemory) {
+        const decoder = new TextDecoder();
+        const known = {
+            proc_exit: (code) => { throw new Error(`fluid_sim exited with code ${code}`); },
+            sched_yield: () => 0,
+            args_sizes_get: (argc, bufSize) => {
+                const view = new DataView(getMemory().buffer);
+                view.setUint32(argc, 0, true);
+                view.setUint32(bufSize, 0, true);
+                return 0;
+            },
+            args_get: () => 0,
+            environ_sizes_get: (count, bufSize) => {
+                const view = new DataView(getMemory().buffer);
+                view.setUint32(count, 0, true);
+                view.setUint32(bufSize, 0, true);
+                return 0;
+            },
+            environ_get: () => 0,
+            clock_time_get: (id, precision, out) => {
+                const now = BigInt(Math.round((id === 0 ? Date.now() : performance.now()) * 1e6));
+                new DataView(getMemory().buffer).setBigUint64(out, now, true);
+                return 0;
+            },
+            random_get: (ptr, len) => {
+                crypto.getRandomValues(new Uint8Array(getMemory().buffer, ptr, len));
+                return 0;
+            },
+            fd_write: (fd, iovs, iovsLen, written) => {
+                const view = new DataView(getMemory().buffer);
+                let text = '', total = 0;
+                for (let i = 0; i < iovsLen; i++) {
+                    const ptr = view.getUint32(iovs + i * 8, true);
+                    const len = view.getUint32(iovs + i * 8 + 4, true);
+                    text += decoder.decode(new Uint8Array(getMemory().buffer, ptr, len));
+                    total += len;
+                }
+                (fd === 2 ? console.error : console.log)(text.replace(/\n$/, ''));
+                view.setUint32(written, total, true);
+                return 0;
+            },
+            fd_prestat_get: () => WASI_EBADF,
+        };
+        return new Proxy(known, {
+            get: (target, name) => target[name] || (() => WASI_ENOSYS),
+        });
+    }
+
+    class FluidSimExports {
+        constructor(instance) {
+            this.exports = instance.exports;
+            this.memory = instance.exports.memory;
+        }
+
+        // Instantiate the module from a URL, Response, or bytes
+        static async load(source = 'wasm/fluid_sim_exports.wasm') {
+            let memory = null;
+            const imports = { wasi_snapshot_preview1: wasiImports(() => memory) };
+            let result;
+            if (source instanceof ArrayBuffer || ArrayBuffer.isView(source)) {
+                result = await WebAssembly.instantiate(source, imports);
+            } else {
+                const response = typeof source === 'string' ? fetch(source) : source;
+                result = await WebAssembly.instantiateStreaming(response, imports);
+            }
+            memory = result.instance.exports.memory;
+            result.instance.exports._initialize();
+            return new FluidSimExports(result.instance);
+        }
+
+        // Allocate n floats in linear memory. The returned handle's .array
+        // is a Float32Array view, recreated if the memory has grown.
+        allocFloat32(n) {
+            const ptr = this.exports.alloc(n);
+            const memory = this.memory;
+            let view = null;
+            return {
+                ptr,
+                length: n,
+                get array() {
+                    if (!view || view.buffer !== memory.buffer) {
+                        view = new Float32Array(memory.buffer, ptr, n);
+                    }
+                    return view;
+                },
+            };
+        }
+
+        // Release a buffer returned by allocFloat32
+        release(handle) {
+            this.exports.release(handle.ptr);
+        }
+
+        // Throw an Error with a code property for a non-zero status
+        check(status) {
+            if (status === 0) {
+                return;
+            }
+            const ptr = this.exports.lastErrorPtr();
+            const len = this.exports.lastErrorLen();
+            const message = new TextDecoder().decode(new Uint8Array(this.memory.buffer, ptr, len));
+            const err = new Error(message);
+            err.code = STATUS_CODES[status] || 'BAD_ARGUMENTS';
+            throw err;
+        }
+
+        // Same arguments as the syscall/js updateVelocities, with buffer
+        // handles for positions and the output velocities
+        updateVelocities(positions, count, freeStreamVelocity, fluidDensity,
+                         objectX, objectY, objectZ, objectType, objectRadius, out) {
+            this.check(this.exports.updateVelocities(positions.ptr, count, freeStreamVelocity, fluidDensity,
+                objectX, objectY, objectZ, objectType, objectRadius, out.ptr));
+            return out.array;
+        }
+
+        // Same arguments as the syscall/js calculatePressure, with buffer
+        // handles for velocities and the output pressures
+        calculatePressure(velocities, count, freeStreamVelocity, fluidDensity, out) {
+            this.check(this.exports.calculatePressure(velocities.ptr, count, freeStreamVelocity, fluidDensity, out.ptr));
+            return out.array;
+        }
+    }
+
+    root.FluidSimExports = FluidSimExports;
+    if (typeof module !== 'undefined' && module.exports) {
+        module.exports = FluidSimExports;
+    }
+})(typeof globalThis !== 'undefined' ? globalThis : this);
@@ -0,0 +1,92 @@
+//go:build js && wasm
+// +build js,wasm
+
+// log.go - Debug log access
+//
+// The module keeps the last flow.LogCapacity log records in a ring buffer
+// (see internal/flow/log.go). Records at or above the current level are
+// kept, from "warn" by default: kernels report non-finite particles and
+// early recording stops, imports their warnings, and at "info" and
+// "debug" every failing call and per-step statistics are added.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// setLogLevel(level[, options])
+//
+// Sets the least severe level recorded, one of "debug", "info", "warn",
+// "error" and "off", and returns the previous one. With options.console
+// set, records of level "warn" and above are also written to console.warn
+// or console.error as they happen; console: false stops that.
+func setLogLevel(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setLogLevel", args, 1); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeString {
+		return nil, flow.Errorf(flow.ErrBadArguments, "level must be a string")
+	}
+	l, err := flow.ParseLogLevel(args[0].String())
+	if err != nil {
+		return nil, err
+	}
+	prev := flow.CurrentLogLevel()
+	if err := flow.SetLogLevel(l); err != nil {
+		return nil, err
+	}
+	if opts := optionalArg(args, 1); opts.Type() == js.TypeObject {
+		if v := opts.Get("console"); !v.IsUndefined() {
+			if v.Truthy() {
+				flow.SetLogSink(consoleSink, flow.LogWarn)
+			} else {
+				flow.SetLogSink(nil, flow.LogWarn)
+			}
+		}
+	}
+	return prev.String(), nil
+}
+
+// consoleSink mirrors a log record to the console
+func consoleSink(r flow.LogRecord) {
+	method := "warn"
+	if r.Level >= flow.LogError {
+		method = "error"
+	}
+	msg := "[fluid_sim " + r.Subsystem + "] " + r.Message
+	if r.HasValue {
+		js.Global().Get("console").Call(method, msg, r.Value)
+		return
+	}
+	js.Global().Get("console").Call(method, msg)
+}
+
+// getLogs([options])
+//
+// Returns the records in the log, oldest first, as an array of {level,
+// time, subsystem, message, value}: time is in milliseconds since the Unix
+// epoch and value is only present on records carrying a number. With
+// options.clear set the log is emptied afterwards.
+func getLogs(args []js.Value) (interface{}, error) {
+	clear := false
+	if opts := optionalArg(args, 0); opts.Type() == js.TypeObject {
+		clear = opts.Get("clear").Truthy()
+	}
+	records := flow.Logs(clear)
+	out := make([]interface{}, len(records))
+	for i, r := range records {
+		m := map[string]interface{}{
+			"level":     r.Level.String(),
+			"time":      float64(r.Time.UnixNano()) / 1e6,
+			"subsystem": r.Subsystem,
+			"message":   r.Message,
+		}
+		if r.HasValue {
+			m["value"] = r.Value
+		}
+		out[i] = m
+	}
+	return out, nil
+}
@@ -0,0 +1,287 @@
+//go:build js && wasm
+// +build js,wasm
+
+// marshal.go - Conversion between JavaScript values and Go types
+package main
+
+import (
+	"math"
+	"syscall/js"
+	"unsafe"
+
+	"fluid_simulation/internal/flow"
+)
+
+// jsError converts err into a JavaScript Error carrying a code property.
+// Errors are returned rather than thrown: a panic inside js.FuncOf would
+// take the whole Go runtime down with it.
+func jsError(err error) js.Value {
+	// A type assertion rather than errors.As keeps reflection out of the
+	// binary, which TinyGo builds can't fully support
+	code := flow.ErrBadArguments
+	if fe, ok := err.(*flow.Error); ok {
+		code = fe.Code
+	}
+	jsErr := js.Global().Get("Error").New(err.Error())
+	jsErr.Set("code", code)
+	return jsErr
+}
+
+// checkArgs verifies that a callback received at least n arguments.
+func checkArgs(name string, args []js.Value, n int) error {
+	if len(args) < n {
+		return flow.Errorf(flow.ErrBadArguments, "%s: expected %d arguments, got %d", name, n, len(args))
+	}
+	return nil
+}
+
+// intArg reads a numeric argument as an int.
+func intArg(name string, v js.Value) (int, error) {
+	if v.Type() != js.TypeNumber {
+		return 0, flow.Errorf(flow.ErrBadArguments, "%s must be a number", name)
+	}
+	return v.Int(), nil
+}
+
+// floatArg reads a numeric argument
+func floatArg(name string, v js.Value) (float64, error) {
+	if v.Type() != js.TypeNumber {
+		return 0, flow.Errorf(flow.ErrBadArguments, "%s must be a number", name)
+	}
+	return v.Float(), nil
+}
+
+// vectorArg reads an [x, y, z] array
+func vectorArg(name string, v js.Value) ([3]float64, error) {
+	var d [3]float64
+	if !js.Global().Get("Array").Call("isArray", v).Bool() || v.Length() != 3 {
+		return d, flow.Errorf(flow.ErrBadArguments, "%s must be an array of 3 numbers", name)
+	}
+	for i := range d {
+		var err error
+		if d[i], err = floatArg(name, v.Index(i)); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// directionArg reads a non-zero [x, y, z] array, normalized
+func directionArg(v js.Value) ([3]float64, error) {
+	d, err := vectorArg("direction", v)
+	if err != nil {
+		return d, err
+	}
+	n := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	if !(n > 0) || math.IsInf(n, 0) {
+		return d, flow.Errorf(flow.ErrBadArguments, "direction must be a non-zero vector")
+	}
+	return [3]float64{d[0] / n, d[1] / n, d[2] / n}, nil
+}
+
+// objectArg reads the id of the object a function applies to, 0 being the
+// only one
+func objectArg(name string, v js.Value) error {
+	id, err := intArg("objectId", v)
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		return flow.Errorf(flow.ErrBadArguments, "%s: no object with id %d", name, id)
+	}
+	return nil
+}
+
+// optionalArg returns args[i], or undefined if it wasn't passed
+func optionalArg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// countArg reads a particle count argument.
+func countArg(v js.Value) (int, error) {
+	count, err := intArg("count", v)
+	if err != nil {
+		return 0, err
+	}
+	return count, flow.CheckCount(count)
+}
+
+// Typed array constructors, looked up once since every js.Global().Get
+// allocates a new reference on the Go side
+var (
+	float32Array = js.Global().Get("Float32Array")
+	float64Array = js.Global().Get("Float64Array")
+	uint8Array   = js.Global().Get("Uint8Array")
+)
+
+// Precisions of typed arrays exchanged with JavaScript
+const (
+	float32Precision = "float32"
+	float64Precision = "float64"
+)
+
+// precisionOf returns the precision of a typed array. Plain arrays are
+// treated as float32, the precision of the original API.
+func precisionOf(v js.Value) string {
+	if v.InstanceOf(float64Array) {
+		return float64Precision
+	}
+	return float32Precision
+}
+
+// precisionOption reads an outputPrecision option, defaulting to def
+func precisionOption(opts js.Value, def string) (string, error) {
+	if opts.Type() != js.TypeObject {
+		return def, nil
+	}
+	p := opts.Get("outputPrecision")
+	if p.IsUndefined() {
+		return def, nil
+	}
+	if s := p.String(); p.Type() == js.TypeString && (s == float32Precision || s == float64Precision) {
+		return s, nil
+	}
+	return "", flow.Errorf(flow.ErrBadArguments, `outputPrecision must be "float32" or "float64"`)
+}
+
+// goValue converts a plain JavaScript value (objects, arrays, numbers,
+// strings, booleans) into the generic form read by flow.DecodeConfig. Keys
+// listed in skip are left out of the top-level object; undefined and null
+// properties are dropped so that they take their defaults.
+func goValue(v js.Value, skip ...string) interface{} {
+	switch v.Type() {
+	case js.TypeNumber:
+		return v.Float()
+	case js.TypeString:
+		return v.String()
+	case js.TypeBoolean:
+		return v.Bool()
+	case js.TypeObject:
+		if js.Global().Get("Array").Call("isArray", v).Bool() {
+			a := make([]interface{}, v.Length())
+			for i := range a {
+				a[i] = goValue(v.Index(i))
+			}
+			return a
+		}
+		keys := js.Global().Get("Object").Call("keys", v)
+		m := make(map[string]interface{}, keys.Length())
+	keys:
+		for i := 0; i < keys.Length(); i++ {
+			k := keys.Index(i).String()
+			for _, s := range skip {
+				if k == s {
+					continue keys
+				}
+			}
+			if e := v.Get(k); !e.IsUndefined() && !e.IsNull() {
+				m[k] = goValue(e)
+			}
+		}
+		return m
+	}
+	return nil
+}
+
+// floatsFromJS copies the first count*stride elements of a typed array (or
+// plain array) into a Go slice from the buffer pool; callers on hot paths
+// hand it back with putBuffer. Arrays of T's precision are copied in bulk.
+func floatsFromJS[T flow.Float](name string, v js.Value, count int, stride int) ([]T, error) {
+	if v.Type() != js.TypeObject || v.Get("length").Type() != js.TypeNumber {
+		return nil, flow.Errorf(flow.ErrBadArguments, "%s must be an array or typed array", name)
+	}
+	if err := flow.CheckBuffer(name, v.Length(), count, stride); err != nil {
+		return nil, err
+	}
+
+	out := getBuffer[T](count * stride)
+	copyFromJS(out, v)
+	return out, nil
+}
+
+// typedArrayOf returns the typed array constructor matching T
+func typedArrayOf[T flow.Float]() js.Value {
+	var zero T
+	if unsafe.Sizeof(zero) == 8 {
+		return float64Array
+	}
+	return float32Array
+}
+
+// bytesOf views a float slice as raw bytes
+func bytesOf[T flow.Float](data []T) []byte {
+	var zero T
+	return unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*int(unsafe.Sizeof(zero)))
+}
+
+// copyFromJS fills dst from the start of a typed array of T's precision (in
+// bulk) or of any other array (element by element). The array must be long
+// enough.
+func copyFromJS[T flow.Float](dst []T, v js.Value) {
+	n := len(dst)
+	if n == 0 {
+		return
+	}
+	if v.InstanceOf(typedArrayOf[T]()) {
+		raw := bytesOf(dst)
+		bytes := uint8Array.New(v.Get("buffer"), v.Get("byteOffset"), len(raw))
+		js.CopyBytesToGo(raw, bytes)
+		return
+	}
+	for i := 0; i < n; i++ {
+		dst[i] = T(v.Index(i).Float())
+	}
+}
+
+// copyToJS writes src to the start of an existing typed array of T's
+// precision
+func copyToJS[T flow.Float](v js.Value, src []T) {
+	if len(src) == 0 {
+		return
+	}
+	raw := bytesOf(src)
+	bytes := uint8Array.New(v.Get("buffer"), v.Get("byteOffset"), len(raw))
+	js.CopyBytesToJS(bytes, raw)
+}
+
+// floatsToJS copies a Go slice into a new Float32Array or Float64Array
+func floatsToJS[T flow.Float](data []T) js.Value {
+	result := typedArrayOf[T]().New(len(data))
+	copyToJS(result, data)
+	return result
+}
+
+// uint8sToJS copies a Go slice into a new Uint8Array
+func uint8sToJS(data []uint8) js.Value {
+	result := uint8Array.New(len(data))
+	js.CopyBytesToJS(result, data)
+	return result
+}
+
+// uint32sToJS copies a Go slice into a new Uint32Array
+func uint32sToJS(data []uint32) js.Value {
+	result := js.Global().Get("Uint32Array").New(len(data))
+	copyUint32sToJS(result, data)
+	return result
+}
+
+// copyUint32sToJS writes src to the start of an existing Uint32Array
+func copyUint32sToJS(v js.Value, src []uint32) {
+	if len(src) > 0 {
+		raw := unsafe.Slice((*byte)(unsafe.Pointer(&src[0])), len(src)*4)
+		js.CopyBytesToJS(uint8Array.New(v.Get("buffer"), v.Get("byteOffset"), len(raw)), raw)
+	}
+}
+
+// int32sToJS copies a Go slice into a new Int32Array
+func int32sToJS(data []int32) js.Value {
+	result := js.Global().Get("Int32Array").New(len(data))
+	if len(data) > 0 {
+		raw := unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*4)
+		js.CopyBytesToJS(uint8Array.New(result.Get("buffer")), raw)
+	}
+	return result
+}
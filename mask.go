@@ -0,0 +1,74 @@
+//go:build js && wasm
+// +build js,wasm
+
+// mask.go - Active particle masks
+//
+// updateVelocities (including its speeds and interleave outputs),
+// calculatePressure and advectPositions take a mask option, a Uint8Array
+// with one byte per particle, 0 for particles to skip (culled or expired)
+// and anything else for active ones. Skipped particles cost nothing and
+// their slots in caller-provided buffers (speeds, target, positions
+// advected in place) are left untouched, or zeroed with zeroMasked: true.
+// Arrays created by the call have zeros there. Far-field counts only
+// include active particles, and getState().mask.lastCall reports the
+// particle and active counts of the last evaluation.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// maskScratch holds the Go copy of the last mask, grown as needed
+var maskScratch []uint8
+
+// activeLast is the particle and active count of the last masked-capable
+// evaluation
+var activeLast struct{ count, active int }
+
+// maskOption reads {mask, zeroMasked} from opts. Without a mask every
+// particle is active.
+func maskOption(opts js.Value, count int) (flow.Mask, error) {
+	if opts.Type() != js.TypeObject {
+		return flow.Mask{}, nil
+	}
+	v := opts.Get("mask")
+	if v.IsUndefined() {
+		return flow.Mask{}, nil
+	}
+	if !v.InstanceOf(uint8Array) {
+		return flow.Mask{}, flow.Errorf(flow.ErrBadArguments, "mask must be a Uint8Array")
+	}
+	if err := flow.CheckBuffer("mask", v.Length(), count, 1); err != nil {
+		return flow.Mask{}, err
+	}
+	if cap(maskScratch) < count {
+		maskScratch = make([]uint8, count)
+	}
+	m := flow.Mask{Active: maskScratch[:count], Zero: opts.Get("zeroMasked").Truthy()}
+	js.CopyBytesToGo(m.Active, v)
+	return m, nil
+}
+
+// recordActive sets activeLast for an evaluation of count particles
+func recordActive(count int, m *flow.Mask) {
+	activeLast.count, activeLast.active = count, m.Count(count)
+}
+
+// preserve fills dst from the caller's buffer v before a masked kernel
+// writes into it, so that the skipped particles keep their values when dst
+// is copied back
+func preserve[T flow.Float](dst []T, v js.Value, m *flow.Mask) {
+	if m.Active != nil && !m.Zero {
+		copyFromJS(dst, v)
+	}
+}
+
+// maskRange returns the part of m covering particles [from, to)
+func maskRange(m flow.Mask, from, to int) flow.Mask {
+	if m.Active != nil {
+		m.Active = m.Active[from:to]
+	}
+	return m
+}
@@ -0,0 +1,89 @@
+//go:build js && wasm
+// +build js,wasm
+
+// massflux.go - Mass flux through a plane
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// computeMassFlux(planePoint, planeNormal, extent, resolution[, freeStreamVelocity, fluidDensity, objectX, objectY, objectZ, objectType, objectRadius])
+//
+// Integrates the mass flux ρ(v·n) through the rectangle of the plane
+// through planePoint = [x, y, z] normal to planeNormal, which need not be
+// a unit vector: extent = [width, height] centred on planePoint, width
+// along the horizontal in-plane axis, across z and the normal (x if the
+// plane is horizontal), height along the normal crossed with the width
+// axis. resolution, a number or [nu, nv], sets the cells along each side,
+// up to 4096, evaluated at their centres. The seven flow parameters are
+// those of updateVelocities; without them the flow is the stateful API's.
+// Returns {net, positive, negative, area, excludedArea, excludedCells}:
+// the net flux along the normal, the fluxes of the cells crossed along it
+// and against it, the latter not positive, the area of the cells in the
+// fluid, and the area and number of those left out, their centre inside
+// the object, beyond the channel walls or below the bump's wall or the
+// rotor's floor. By continuity planes spanning the same stream tube
+// upstream and downstream of the object see the same net flux. Points and
+// extents are in the length unit of setUnits, areas in its square and
+// fluxes in its density unit times its velocity unit times its area; see
+// flow.Flow.MassFlux.
+func computeMassFlux(args []js.Value) (interface{}, error) {
+	if err := checkArgs("computeMassFlux", args, 4); err != nil {
+		return nil, err
+	}
+	var p flow.FluxPlane
+	var err error
+	if p.Point, err = vectorArg("planePoint", args[0]); err != nil {
+		return nil, err
+	}
+	p.Point = vectorToSI(p.Point, flow.DimLength)
+	if p.Normal, err = vectorArg("planeNormal", args[1]); err != nil {
+		return nil, err
+	}
+	if !js.Global().Get("Array").Call("isArray", args[2]).Bool() || args[2].Length() != 2 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "extent must be an array of 2 numbers")
+	}
+	for a := range p.Extent {
+		if p.Extent[a], err = dimArg("extent", flow.DimLength, args[2].Index(a)); err != nil {
+			return nil, err
+		}
+	}
+	if r := args[3]; js.Global().Get("Array").Call("isArray", r).Bool() {
+		if r.Length() != 2 {
+			return nil, flow.Errorf(flow.ErrBadArguments, "resolution must be a number or an array of 2 numbers")
+		}
+		for a := range p.Resolution {
+			if p.Resolution[a], err = intArg("resolution", r.Index(a)); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		n, err := intArg("resolution", r)
+		if err != nil {
+			return nil, err
+		}
+		p.Resolution = [2]int{n, n}
+	}
+
+	f := sim.Flow()
+	if len(args) > 4 {
+		if err := checkArgs("computeMassFlux", args, 11); err != nil {
+			return nil, err
+		}
+		if f, err = flowArgs(args[4:11]); err != nil {
+			return nil, err
+		}
+	}
+	m, err := f.MassFlux(p)
+	if err != nil {
+		return nil, err
+	}
+	l := units.Factor(flow.DimLength)
+	flux := units.Factor(flow.DimDensity) * units.Factor(flow.DimVelocity) * l * l
+	m.Net, m.Positive, m.Negative = m.Net/flux, m.Positive/flux, m.Negative/flux
+	m.Area, m.ExcludedArea = m.Area/(l*l), m.ExcludedArea/(l*l)
+	return js.ValueOf(m.Encode()), nil
+}
@@ -0,0 +1,64 @@
+//go:build js && wasm
+// +build js,wasm
+
+// mesh.go - Renderable object geometry
+package main
+
+import (
+	"syscall/js"
+	"unsafe"
+
+	"fluid_simulation/internal/flow"
+)
+
+// getObjectMesh(objectId[, params])
+//
+// Returns the surface of the object as {positions, normals, uvs, indices,
+// version}: Float32Arrays of x,y,z, normal and u,v per vertex and a
+// Uint32Array of triangle indices, built from the same definition of the
+// body as the kernels. params is {resolution, span} as for
+// exportOBJ("surface"). version is the value of getObjectVersion the mesh
+// was built for. Object 0 is the analytic body; the IDs returned by
+// importSTL give the imported mesh verbatim, without UVs. Positions and
+// span are in the length unit of setUnits.
+func getObjectMesh(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getObjectMesh", args, 1); err != nil {
+		return nil, err
+	}
+	id, err := intArg("objectId", args[0])
+	if err != nil {
+		return nil, err
+	}
+	m, ok := panelBodies[id]
+	if !ok {
+		if id != 0 {
+			return nil, flow.Errorf(flow.ErrBadArguments, "getObjectMesh: no object %d", id)
+		}
+		o := sim.Config.Object
+		resolution, span, err := meshParams(optionalArg(args, 1), o)
+		if err != nil {
+			return nil, err
+		}
+		if m, err = o.RenderMesh(resolution, span); err != nil {
+			return nil, err
+		}
+	}
+	indices := js.Global().Get("Uint32Array").New(len(m.Indices))
+	if len(m.Indices) > 0 {
+		raw := unsafe.Slice((*byte)(unsafe.Pointer(&m.Indices[0])), len(m.Indices)*4)
+		js.CopyBytesToJS(js.Global().Get("Uint8Array").New(indices.Get("buffer")), raw)
+	}
+	return map[string]interface{}{
+		"positions": floatsToJSIn(m.Positions, flow.DimLength),
+		"normals":   floatsToJS(m.Normals),
+		"uvs":       floatsToJS(m.UVs),
+		"indices":   indices,
+		"version":   sim.ObjectVersion,
+	}, nil
+}
+
+// getObjectVersion() returns a counter that changes whenever the object
+// does; poll it to know when to call getObjectMesh again
+func getObjectVersion(args []js.Value) (interface{}, error) {
+	return sim.ObjectVersion, nil
+}
@@ -0,0 +1,153 @@
+//go:build js && wasm
+// +build js,wasm
+
+// neighbors.go - Spatial queries over a particle cloud
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// neighbors holds the grid of the last buildNeighborGrid call
+var neighbors struct {
+	grid       *flow.NeighborGrid
+	generation int     // Builds so far
+	buildMs    float64 // Duration of the last build
+	scratch    []uint32
+}
+
+// neighborGridState returns getState().neighborGrid
+func neighborGridState() map[string]interface{} {
+	st := map[string]interface{}{"built": neighbors.grid != nil, "generation": neighbors.generation}
+	if g := neighbors.grid; g != nil {
+		st["count"], st["cellSize"], st["bytes"], st["buildMs"] = g.Len(), g.Cell, g.Bytes(), neighbors.buildMs
+	}
+	return st
+}
+
+// buildNeighborGrid(positions, count, cellSize)
+//
+// Buckets count particles, an array of x, y, z positions, on a hashed
+// uniform grid of cubic cells of side cellSize, the grid computeDensity
+// uses, for queryNeighbors and queryNeighborsBatch. The grid keeps a copy
+// of the positions: it answers for them until the next build, however the
+// array changes. Queries within a cell look at 27 cells, so pick cellSize
+// about the query radius. Each build advances a generation counter; pass
+// it to the queries to have them fail with code STALE once the grid was
+// rebuilt from other positions. Returns getState().neighborGrid:
+// {built, generation, count, cellSize, bytes, buildMs}, bytes the memory
+// the grid holds and buildMs the build time. Positions and cellSize are in
+// any one length unit, that of the queries too.
+func buildNeighborGrid(args []js.Value) (interface{}, error) {
+	if err := checkArgs("buildNeighborGrid", args, 3); err != nil {
+		return nil, err
+	}
+	n, err := countArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	cell, err := floatArg("cellSize", args[2])
+	if err != nil {
+		return nil, err
+	}
+	p, err := floatsFromJS[float64]("positions", args[0], n, 3)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(p)
+	start := now()
+	g, err := flow.NewNeighborGrid(p, n, cell)
+	if err != nil {
+		return nil, err
+	}
+	neighbors.grid, neighbors.buildMs = g, now()-start
+	neighbors.generation++
+	return js.ValueOf(neighborGridState()), nil
+}
+
+// neighborGrid returns the grid for a query, checking the generation
+// option if opts has one
+func neighborGrid(name string, opts js.Value) (*flow.NeighborGrid, error) {
+	if neighbors.grid == nil {
+		return nil, flow.Errorf(flow.ErrBadArguments, "%s: no neighbor grid; call buildNeighborGrid first", name)
+	}
+	if opts.Type() == js.TypeObject {
+		if v := opts.Get("generation"); !v.IsUndefined() {
+			gen, err := intArg("generation", v)
+			if err != nil {
+				return nil, err
+			}
+			if gen != neighbors.generation {
+				return nil, flow.Errorf(flow.ErrStale, "%s: the neighbor grid is at generation %d, not %d", name, neighbors.generation, gen)
+			}
+		}
+	}
+	return neighbors.grid, nil
+}
+
+// queryNeighbors(point, radius[, options])
+//
+// Returns a Uint32Array of the indices, in increasing order, of the
+// particles of the last buildNeighborGrid within radius of point = [x, y,
+// z]. Options:
+//   - generation: the generation the caller built the grid at; the query
+//     fails with code STALE if it has been rebuilt since
+func queryNeighbors(args []js.Value) (interface{}, error) {
+	if err := checkArgs("queryNeighbors", args, 2); err != nil {
+		return nil, err
+	}
+	g, err := neighborGrid("queryNeighbors", optionalArg(args, 2))
+	if err != nil {
+		return nil, err
+	}
+	p, err := vectorArg("point", args[0])
+	if err != nil {
+		return nil, err
+	}
+	radius, err := floatArg("radius", args[1])
+	if err != nil {
+		return nil, err
+	}
+	neighbors.scratch = g.AppendNear(neighbors.scratch[:0], p[0], p[1], p[2], radius)
+	return uint32sToJS(neighbors.scratch), nil
+}
+
+// queryNeighborsBatch(points, count, radius[, options])
+//
+// Runs queryNeighbors for count query points, an array of x, y, z, at
+// once. Returns {offsets, indices}: the indices of the neighbors of every
+// point concatenated in a Uint32Array, each point's in increasing order,
+// and a Uint32Array of count+1 offsets where those of point i span
+// [offsets[i], offsets[i+1]). Options as for queryNeighbors.
+func queryNeighborsBatch(args []js.Value) (interface{}, error) {
+	if err := checkArgs("queryNeighborsBatch", args, 3); err != nil {
+		return nil, err
+	}
+	g, err := neighborGrid("queryNeighborsBatch", optionalArg(args, 3))
+	if err != nil {
+		return nil, err
+	}
+	n, err := countArg(args[1])
+	if err != nil {
+		return nil, err
+	}
+	radius, err := floatArg("radius", args[2])
+	if err != nil {
+		return nil, err
+	}
+	p, err := floatsFromJS[float64]("points", args[0], n, 3)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(p)
+	offsets := make([]uint32, n+1)
+	indices := neighbors.scratch[:0]
+	for i := 0; i < n; i++ {
+		indices = g.AppendNear(indices, p[i*3], p[i*3+1], p[i*3+2], radius)
+		offsets[i+1] = uint32(len(indices))
+	}
+	neighbors.scratch = indices
+	return map[string]interface{}{"offsets": uint32sToJS(offsets), "indices": uint32sToJS(indices)}, nil
+}
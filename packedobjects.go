@@ -0,0 +1,90 @@
+//go:build js && wasm
+// +build js,wasm
+
+// packedobjects.go - Object parameters as one Float32Array
+//
+// Setting the object through setConfig or the positional parameters reads
+// every property through js.Value every frame. The packed form holds each
+// object in flow.ObjectStride floats, [type, x, y, z, radius, p0..p3] (see
+// flow.ObjectStride for p0..p3), and crosses with one copy either way, so
+// the Worker protocol and the SharedArrayBuffer modes can carry object
+// updates cheaply. The analytic object is the only one
+// (features.multiObject is false, maxObjects is 1), so the object count is
+// 1 for now; the layout takes more objects once a registry exists.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// setObjectsPacked(objects, count)
+//
+// Sets the object of the stateful API from objects, a Float32Array of at
+// least count*9 floats in the packed form (getCapabilities().objectStride
+// floats per object). count must be 1, the only object. Unknown type
+// codes and non-finite parameters fail, naming the index of the object;
+// a failing call changes nothing. Strengths, the collision radius and the
+// discretizations aren't packed and stay as they are, except that a change
+// of type clears the parameters of the old one. Drops the target of
+// setObjectTarget, like setObjectPosition. Lengths are in the unit of
+// setUnits, angles in radians.
+func setObjectsPacked(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setObjectsPacked", args, 2); err != nil {
+		return nil, err
+	}
+	if !args[0].InstanceOf(float32Array) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "setObjectsPacked: objects must be a Float32Array")
+	}
+	count, err := intArg("count", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if count != 1 {
+		return nil, flow.Errorf(flow.ErrUnsupported, "setObjectsPacked: got %d objects, but this build has one analytic object (maxObjects is 1)", count)
+	}
+	if n := args[0].Length(); n < count*flow.ObjectStride {
+		return nil, flow.Errorf(flow.ErrBufferLength, "objects has %d elements, need %d for %d objects", n, count*flow.ObjectStride, count)
+	}
+	var buf [flow.ObjectStride]float32
+	copyFromJS(buf[:], args[0])
+
+	c := sim.Config
+	objects := []flow.ObjectSpec{c.Object}
+	if err := flow.UnpackObjects(buf[:], count, objects, &units); err != nil {
+		return nil, err
+	}
+	c.Object = objects[0]
+	f := sim.Flow()
+	f.Object = c.Object
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	sim.Target = nil
+	sim.SetConfig(c)
+	return nil, nil
+}
+
+// getObjectsPacked([target])
+//
+// Returns the object of the stateful API in the packed form of
+// setObjectsPacked, a Float32Array of 9 floats, the one object; with
+// target, a Float32Array of at least 9 floats, fills and returns it
+// instead, to reuse one buffer across frames.
+func getObjectsPacked(args []js.Value) (interface{}, error) {
+	var buf [flow.ObjectStride]float32
+	flow.PackObjects(buf[:], []flow.ObjectSpec{sim.Config.Object}, &units)
+	target := optionalArg(args, 0)
+	if target.IsUndefined() || target.IsNull() {
+		return floatsToJS(buf[:]), nil
+	}
+	if !target.InstanceOf(float32Array) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "getObjectsPacked: target must be a Float32Array")
+	}
+	if n := target.Length(); n < len(buf) {
+		return nil, flow.Errorf(flow.ErrBufferLength, "target has %d elements, need %d for 1 object", n, len(buf))
+	}
+	copyToJS(target, buf[:])
+	return target, nil
+}
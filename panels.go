@@ -0,0 +1,130 @@
+//go:build js && wasm
+// +build js,wasm
+
+// panels.go - Imported panel geometry
+//
+// STL meshes imported here are kept as panel bodies with IDs from 1 up (ID
+// 0 is the analytic object). The panel solver, which getCapabilities
+// reports as features.panelSolver, solves the source panels of the box
+// object and the bump's wall (see flow's box.go and bump.go); it takes
+// rectangles of known faces, not triangle meshes, so the bodies don't
+// affect the flow yet. getObjectMesh returns their meshes verbatim.
+//
+// The analytic object is the only body the kernels evaluate, so there is
+// no per-particle object lookup to cull (features.multiObject is false):
+// the one influence check is the far-field cutoff of setFarFieldCutoff.
+// A spatial hash over body influence spheres belongs with a registry of
+// several analytic objects, once it exists.
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// panelBodies maps panel body IDs to their meshes
+var panelBodies = map[int]*flow.Mesh{}
+
+// nextPanelID is the ID of the next imported body
+var nextPanelID = 1
+
+// importSTL(bytes[, options])
+//
+// Parses a binary or ASCII STL from a Uint8Array, welds vertices closer
+// than options.weldTolerance (default 1e-6), optionally decimates to
+// options.targetPanels triangles, and registers the mesh as a panel body.
+// Returns {id, triangles, vertices, bbox: {min, max}, openEdges,
+// nonManifoldEdges, inconsistentEdges, volume, affectsFlow, warnings}.
+// affectsFlow is false, with a warning saying so: the body is kept for
+// display and export, but the kernels don't evaluate it. Meshes that are
+// not closed and consistently wound are still registered, with warnings.
+// The STL coordinates, weldTolerance, bbox and volume are in the length
+// unit of setUnits; the mesh is stored in SI.
+func importSTL(args []js.Value) (interface{}, error) {
+	if err := checkArgs("importSTL", args, 1); err != nil {
+		return nil, err
+	}
+	if !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "importSTL: expected a Uint8Array")
+	}
+	tol, target := 1e-6, 0
+	if opts := optionalArg(args, 1); opts.Type() == js.TypeObject {
+		var err error
+		if v := opts.Get("weldTolerance"); !v.IsUndefined() {
+			if tol, err = floatArg("weldTolerance", v); err != nil {
+				return nil, err
+			}
+		}
+		if v := opts.Get("targetPanels"); !v.IsUndefined() {
+			if target, err = intArg("targetPanels", v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	b := make([]byte, args[0].Length())
+	js.CopyBytesToGo(b, args[0])
+	m, err := flow.ParseSTL(b)
+	if err != nil {
+		return nil, err
+	}
+	m.Weld(tol)
+	m.Decimate(target)
+	m.ComputeNormals()
+	st := m.Stats()
+	if st.Triangles == 0 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "importSTL: the mesh has no non-degenerate triangles")
+	}
+	toSI(m.Positions, flow.DimLength)
+
+	warnings := []interface{}{"imported bodies don't affect the flow: the panel solver takes the box and the bump only"}
+	if st.OpenEdges > 0 {
+		warnings = append(warnings, fmt.Sprintf("mesh is not watertight: %d open edges", st.OpenEdges))
+	}
+	if st.NonManifoldEdges > 0 {
+		warnings = append(warnings, fmt.Sprintf("mesh has %d non-manifold edges", st.NonManifoldEdges))
+	}
+	if st.InconsistentEdges > 0 {
+		warnings = append(warnings, fmt.Sprintf("mesh winding is inconsistent across %d edges", st.InconsistentEdges))
+	}
+	if st.Closed() && st.Volume < 0 {
+		warnings = append(warnings, "mesh normals point inward")
+	}
+
+	id := nextPanelID
+	nextPanelID++
+	panelBodies[id] = m
+	return map[string]interface{}{
+		"id":        id,
+		"triangles": st.Triangles,
+		"vertices":  st.Vertices,
+		"bbox": map[string]interface{}{
+			"min": []interface{}{st.Min[0], st.Min[1], st.Min[2]},
+			"max": []interface{}{st.Max[0], st.Max[1], st.Max[2]},
+		},
+		"openEdges":         st.OpenEdges,
+		"nonManifoldEdges":  st.NonManifoldEdges,
+		"inconsistentEdges": st.InconsistentEdges,
+		"volume":            st.Volume,
+		"affectsFlow":       false,
+		"warnings":          warnings,
+	}, nil
+}
+
+// removePanelBody(id) forgets an imported body
+func removePanelBody(args []js.Value) (interface{}, error) {
+	if err := checkArgs("removePanelBody", args, 1); err != nil {
+		return nil, err
+	}
+	id, err := intArg("id", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := panelBodies[id]; !ok {
+		return nil, flow.Errorf(flow.ErrBadArguments, "removePanelBody: no panel body %d", id)
+	}
+	delete(panelBodies, id)
+	return nil, nil
+}
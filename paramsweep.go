@@ -0,0 +1,91 @@
+//go:build js && wasm
+// +build js,wasm
+
+// paramsweep.go - Sweeps of a configuration parameter
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// runParameterSweep(sweep[, options])
+//
+// Evaluates an output of the stateful API's flow for each of a list of
+// values of one configuration parameter, returning a Promise for the
+// stacked results. sweep is
+//
+//	{parameterPath, values: [number, ...],
+//	 output: {kind: "grid", spec: {min, max, resolution}} |
+//	         {kind: "probeLine", spec: {from, to, samples = 100}} |
+//	         {kind: "forces", spec: {resolution = 32}}}
+//
+// parameterPath is a dotted path into the configuration of getConfig
+// holding a number, such as "object.radius", "freeStream.speed" or
+// "object.position.1", and values are in its unit in getConfig, degrees
+// for object.alpha and the length or velocity unit of setUnits. The
+// flows of every value are built when the call starts from the
+// configuration, schedule, motion and elements current then, and the
+// simulation itself is never changed, so the view and the particles carry
+// on as they were and later calls don't affect the sweep. options are
+// those of updateVelocitiesChunked, {chunkSize, onProgress, signal},
+// counting values, one per chunk by default.
+//
+// The Promise resolves with {values, shape, kind, parameterPath,
+// parameterValues}: values is a Float64Array of shape [values.length,
+// stride], the output of each parameter value in turn; the velocity at
+// the grid points, in the order of exportVTK, or at the samples of the
+// probe line, evenly spaced from its from to its to, three components
+// each in the velocity unit, or the pressure force and moment on the
+// object, [fx, fy, fz, mx, my, mz] in newtons and newton meters (see
+// getPlateForces) whatever the units. See flow.ParameterSweep.
+func runParameterSweep(args []js.Value) (interface{}, error) {
+	if err := checkArgs("runParameterSweep", args, 1); err != nil {
+		return nil, err
+	}
+	p, err := flow.DecodeParameterSweep(goValueSI(args[0], flow.SweepDims))
+	if err != nil {
+		return nil, err
+	}
+	toSI(p.Values, flow.PathDim(p.Path))
+	options := optionalArg(args, 1)
+	opts, err := chunkOptionsArg(options)
+	if err != nil {
+		return nil, err
+	}
+	if options.Type() != js.TypeObject || options.Get("chunkSize").IsUndefined() {
+		opts.chunkSize = 1
+	}
+
+	flows := make([]flow.Flow, len(p.Values))
+	for i, v := range p.Values {
+		if flows[i], err = p.Flow(sim, v); err != nil {
+			return nil, err
+		}
+	}
+	stride := p.Stride()
+	out := make([]float64, len(p.Values)*stride)
+	err = runChunked(len(p.Values), opts, func(from, to int) error {
+		for i := from; i < to; i++ {
+			if err := p.Evaluate(flows[i], out[i*stride:(i+1)*stride]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	d := flow.DimVelocity
+	if p.Kind == flow.SweepForces {
+		d = flow.DimNone
+	}
+	return map[string]interface{}{
+		"values":          floatsToJSIn(out, d),
+		"shape":           []interface{}{len(p.Values), stride},
+		"kind":            p.Kind,
+		"parameterPath":   p.Path,
+		"parameterValues": floatsToJSIn(p.Values, flow.PathDim(p.Path)),
+	}, nil
+}
@@ -0,0 +1,202 @@
+//go:build js && wasm
+// +build js,wasm
+
+// planar.go - Planar (structure-of-arrays) particle buffers
+//
+// The calls exchanging particle positions or velocities with JavaScript,
+// updateVelocities (and its speed colors), updateVelocitiesChunked,
+// calculatePressure, calculateIsentropic and advectPositions, take a
+// layout option: "interleaved", the default, is [x0, y0, z0, x1, ...];
+// "planar" keeps the components apart, for GPU pipelines with separate
+// attribute buffers, in either of two forms:
+//
+//   - three typed arrays [xs, ys, zs] of at least count elements each, of
+//     the same precision
+//   - one typed array of at least 3*count elements holding the blocks one
+//     after the other: x at offset 0, y at offset count and z at offset
+//     2*count
+//
+// The layout of a call applies to all of its vector buffers, and in the
+// planar layout they must all take the same form; planar results take the
+// form of the positions (the velocities for advectPositions' target). An
+// array of three arrays without layout: "planar", or buffers of
+// different forms, fail validation, so layouts never mix silently. The
+// conversion to the interleaved order the kernels work on happens in Go,
+// within the one bulk copy of each buffer.
+package main
+
+import (
+	"fmt"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// vectors is a particle vector buffer argument in the layout of its call:
+// one array, interleaved or with the planar blocks concatenated, or the
+// array of the three planar component arrays if split
+type vectors struct {
+	v      js.Value
+	planar bool
+	split  bool
+}
+
+// layoutOption reads the optional layout of opts
+func layoutOption(opts js.Value) (string, error) {
+	if opts.Type() != js.TypeObject {
+		return flow.VectorsInterleaved, nil
+	}
+	v := opts.Get("layout")
+	if v.IsUndefined() {
+		return flow.VectorsInterleaved, nil
+	}
+	if v.Type() != js.TypeString {
+		return "", flow.Errorf(flow.ErrBadArguments, "layout must be a string")
+	}
+	if err := flow.CheckVectorLayout(v.String()); err != nil {
+		return "", err
+	}
+	if v.String() == "" {
+		return flow.VectorsInterleaved, nil
+	}
+	return v.String(), nil
+}
+
+// componentArrays reports whether v is an array of three arrays, the split
+// planar form
+func componentArrays(v js.Value) bool {
+	return js.Global().Get("Array").Call("isArray", v).Bool() && v.Length() == 3 && v.Index(0).Type() == js.TypeObject
+}
+
+// vectorsArg reads a vector buffer argument in layout
+func vectorsArg(name string, v js.Value, layout string) (vectors, error) {
+	if v.Type() != js.TypeObject || v.Get("length").Type() != js.TypeNumber {
+		return vectors{}, flow.Errorf(flow.ErrBadArguments, "%s must be an array or typed array", name)
+	}
+	a := vectors{v: v, planar: layout == flow.VectorsPlanar, split: componentArrays(v)}
+	if !a.split {
+		return a, nil
+	}
+	if !a.planar {
+		return vectors{}, flow.Errorf(flow.ErrBadArguments, `%s holds three component arrays, the planar layout; pass layout: "planar"`, name)
+	}
+	for k := 0; k < 3; k++ {
+		c := v.Index(k)
+		if !c.InstanceOf(float32Array) && !c.InstanceOf(float64Array) {
+			return vectors{}, flow.Errorf(flow.ErrBadArguments, "%s: the components must be Float32Arrays or Float64Arrays", name)
+		}
+		if !c.InstanceOf(v.Index(0).Get("constructor")) {
+			return vectors{}, flow.Errorf(flow.ErrBadArguments, "%s: the components must have the same precision", name)
+		}
+	}
+	return a, nil
+}
+
+// sameForm checks that b takes the form of a, both named for the error
+func (a vectors) sameForm(name string, b vectors, nameB string) error {
+	if a.split != b.split {
+		return flow.Errorf(flow.ErrBadArguments, "%s and %s must both be three component arrays or both one array in the planar layout", name, nameB)
+	}
+	return nil
+}
+
+// precision returns the precision of the buffer
+func (a vectors) precision() string {
+	if a.split {
+		return precisionOf(a.v.Index(0))
+	}
+	return precisionOf(a.v)
+}
+
+// typed reports whether the buffer is made of typed arrays
+func (a vectors) typed() bool {
+	if a.split {
+		return true
+	}
+	return a.v.InstanceOf(float32Array) || a.v.InstanceOf(float64Array)
+}
+
+// size returns the number of vectors the buffer holds
+func (a vectors) size() int {
+	if a.split {
+		n := a.v.Index(0).Length()
+		for k := 1; k < 3; k++ {
+			n = min(n, a.v.Index(k).Length())
+		}
+		return n
+	}
+	return a.v.Length() / 3
+}
+
+// check verifies that the buffer holds count vectors
+func (a vectors) check(name string, count int) error {
+	if !a.split {
+		return flow.CheckBuffer(name, a.v.Length(), count, 3)
+	}
+	for k := 0; k < 3; k++ {
+		if err := flow.CheckBuffer(fmt.Sprintf("%s[%d]", name, k), a.v.Index(k).Length(), count, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// vectorsFromJS is floatsFromJS for a vector buffer: it returns the first
+// count vectors, interleaved, in a pooled buffer the caller hands back with
+// putBuffer
+func vectorsFromJS[T flow.Float](name string, a vectors, count int) ([]T, error) {
+	if !a.planar {
+		return floatsFromJS[T](name, a.v, count, 3)
+	}
+	if err := a.check(name, count); err != nil {
+		return nil, err
+	}
+	blocks := getBuffer[T](count * 3)
+	defer putBuffer(blocks)
+	if a.split {
+		for k := 0; k < 3; k++ {
+			copyFromJS(blocks[k*count:(k+1)*count], a.v.Index(k))
+		}
+	} else {
+		copyFromJS(blocks, a.v)
+	}
+	out := getBuffer[T](count * 3)
+	flow.FromPlanar(out, blocks, count)
+	return out, nil
+}
+
+// vectorsToJS returns the count interleaved vectors of data in new typed
+// arrays of T's precision, in the layout and form of a
+func vectorsToJS[T flow.Float](a vectors, data []T, count int) js.Value {
+	if !a.planar {
+		return floatsToJS(data)
+	}
+	blocks := getBuffer[T](count * 3)
+	defer putBuffer(blocks)
+	flow.ToPlanar(blocks, data, count)
+	if !a.split {
+		return floatsToJS(blocks)
+	}
+	return js.ValueOf([]interface{}{
+		floatsToJS(blocks[:count]), floatsToJS(blocks[count : 2*count]), floatsToJS(blocks[2*count:]),
+	})
+}
+
+// copyVectorsToJS writes the count interleaved vectors of data to the
+// existing typed arrays of a, in its layout and form
+func copyVectorsToJS[T flow.Float](a vectors, data []T, count int) {
+	if !a.planar {
+		copyToJS(a.v, data)
+		return
+	}
+	blocks := getBuffer[T](count * 3)
+	defer putBuffer(blocks)
+	flow.ToPlanar(blocks, data, count)
+	if !a.split {
+		copyToJS(a.v, blocks)
+		return
+	}
+	for k := 0; k < 3; k++ {
+		copyToJS(a.v.Index(k), blocks[k*count:(k+1)*count])
+	}
+}
@@ -0,0 +1,28 @@
+//go:build js && wasm
+// +build js,wasm
+
+// plate.go - Forces on a flat plate
+package main
+
+import "syscall/js"
+
+// getPlateForces()
+//
+// Returns the forces per unit span on the simulation's plate (object type
+// "plate", configured with its chord or radius, alpha and edgeRadius) from
+// the exact flat-plate solution: {alpha, cl, clThin, cn, cs, circulation,
+// lift, normalForce, suction}, the incidence on the cross-flow in degrees,
+// the exact lift coefficient 2π·sin α next to the thin-airfoil 2πα, the
+// normal force and leading edge suction coefficients, the clockwise
+// circulation and the lift, normal and suction forces. The suction, which
+// grows as sin²α and needs a flow staying attached around an infinitely
+// sharp leading edge, marks where thin-airfoil theory stops describing a
+// real plate. Circulation and forces are SI; see flow.PlateForces.
+func getPlateForces(args []js.Value) (interface{}, error) {
+	f := sim.Flow()
+	p, err := f.PlateForces()
+	if err != nil {
+		return nil, err
+	}
+	return js.ValueOf(p.Encode()), nil
+}
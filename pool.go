@@ -0,0 +1,139 @@
+//go:build js && wasm
+// +build js,wasm
+
+// pool.go - Scratch buffer pool
+//
+// The one-shot functions copy their input arrays into Go slices and
+// evaluate into Go slices before copying the result out. Those bulk
+// buffers come from a pool keyed by length, so a render loop calling with
+// the same particle count every frame reuses the same buffers instead of
+// feeding the garbage collector. releaseBuffers drops the pooled buffers,
+// e.g. after the particle count shrinks. getState().allocations reports
+// the pool counters and, while timing is enabled, the heap allocations of
+// the last call. In steady state those are a handful of small allocations
+// for the references syscall/js creates for JavaScript values, a few
+// hundred bytes whatever the particle count.
+//
+// Calls run on the single JavaScript thread, so the pool needs no locking.
+package main
+
+import (
+	"runtime"
+	"syscall/js"
+	"unsafe"
+
+	"fluid_simulation/internal/flow"
+)
+
+// maxPooled is the number of free buffers kept per length
+const maxPooled = 4
+
+// bufferPool holds free buffers of one element type by length
+type bufferPool[T flow.Float] map[int][][]T
+
+// pools holds the pooled buffers and their counters
+var pools struct {
+	f32    bufferPool[float32]
+	f64    bufferPool[float64]
+	hits   int // getBuffer calls served from the pool
+	misses int // getBuffer calls that allocated
+	bytes  int // Bytes held by free buffers
+}
+
+// poolOf returns the pool of T
+func poolOf[T flow.Float]() *bufferPool[T] {
+	var zero T
+	if unsafe.Sizeof(zero) == 8 {
+		return any(&pools.f64).(*bufferPool[T])
+	}
+	return any(&pools.f32).(*bufferPool[T])
+}
+
+// getBuffer returns a slice of n elements, reusing a pooled buffer of that
+// length if there is one. Its contents are undefined.
+func getBuffer[T flow.Float](n int) []T {
+	if n == 0 {
+		return nil
+	}
+	p := poolOf[T]()
+	if free := (*p)[n]; len(free) > 0 {
+		b := free[len(free)-1]
+		(*p)[n] = free[:len(free)-1]
+		pools.hits++
+		pools.bytes -= len(b) * int(unsafe.Sizeof(b[0]))
+		return b
+	}
+	pools.misses++
+	return make([]T, n)
+}
+
+// putBuffer returns b to the pool; it must not be used afterwards
+func putBuffer[T flow.Float](b []T) {
+	if len(b) == 0 {
+		return
+	}
+	p := poolOf[T]()
+	if *p == nil {
+		*p = bufferPool[T]{}
+	}
+	if len((*p)[len(b)]) >= maxPooled {
+		return
+	}
+	(*p)[len(b)] = append((*p)[len(b)], b)
+	pools.bytes += len(b) * int(unsafe.Sizeof(b[0]))
+}
+
+// releaseBuffers() drops every pooled buffer and runs the garbage
+// collector, returning the number of bytes released
+func releaseBuffers(args []js.Value) (interface{}, error) {
+	released := pools.bytes
+	pools.f32, pools.f64, pools.bytes = nil, nil, 0
+	runtime.GC()
+	return released, nil
+}
+
+// allocation is the heap allocation of one exported call
+type allocation struct {
+	function      string
+	allocs, bytes uint64
+}
+
+// lastAllocation is measured by call while timing is enabled
+var lastAllocation allocation
+
+// measureAllocations runs fn and records its heap allocations as
+// lastAllocation
+func measureAllocations(name string, fn func()) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	fn()
+	runtime.ReadMemStats(&after)
+	lastAllocation = allocation{name, after.Mallocs - before.Mallocs, after.TotalAlloc - before.TotalAlloc}
+}
+
+// allocationState returns the allocations section of getState
+func allocationState() map[string]interface{} {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	st := map[string]interface{}{
+		"pool": map[string]interface{}{
+			"hits":   pools.hits,
+			"misses": pools.misses,
+			"bytes":  pools.bytes,
+		},
+		"heap": map[string]interface{}{
+			"allocs":   m.Mallocs,
+			"bytes":    m.TotalAlloc,
+			"inUse":    m.HeapAlloc,
+			"gcCycles": m.NumGC,
+		},
+	}
+	if lastAllocation.function != "" {
+		st["lastCall"] = map[string]interface{}{
+			"function": lastAllocation.function,
+			"allocs":   lastAllocation.allocs,
+			"bytes":    lastAllocation.bytes,
+		}
+	}
+	return st
+}
@@ -0,0 +1,106 @@
+//go:build js && wasm
+// +build js,wasm
+
+// probe.go - Virtual flow meters
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// probesState returns getState().probes
+func probesState() js.Value {
+	st := units.Convert(map[string]interface{}{"probes": sim.State()["probes"]}, flow.StateDims, false)
+	return js.ValueOf(st.(map[string]interface{})["probes"])
+}
+
+// probeArg reads the id of an existing probe
+func probeArg(name string, v js.Value) (*flow.FlowProbe, error) {
+	id, err := intArg("id", v)
+	if err != nil {
+		return nil, err
+	}
+	p := sim.Probes.Probe(id)
+	if p == nil {
+		return nil, flow.Errorf(flow.ErrBadArguments, "%s: no probe %d", name, id)
+	}
+	return p, nil
+}
+
+// defineFlowProbe(id, center, normal, radius, nRings)
+//
+// Adds flow probe id, a non-negative integer, or replaces it: a virtual
+// flow meter, the disk of radius about center = [x, y, z] facing normal,
+// which need not be a unit vector, through which every step integrates the
+// volume flow rate ∫v·n dA over nRings rings of equal width, up to 256,
+// split into cells of equal area. The rates accumulate into the probe's
+// history, read with getProbeHistory; redefining a probe, to drag it
+// around, keeps its history. Scenarios save the probes without their
+// histories, and reset empties them. Returns getState().probes: {id,
+// center, normal, radius, rings, samples} for each probe. center and
+// radius are in the length unit of setUnits.
+func defineFlowProbe(args []js.Value) (interface{}, error) {
+	if err := checkArgs("defineFlowProbe", args, 5); err != nil {
+		return nil, err
+	}
+	var p flow.FlowProbe
+	var err error
+	if p.ID, err = intArg("id", args[0]); err != nil {
+		return nil, err
+	}
+	if p.Center, err = vectorArg("center", args[1]); err != nil {
+		return nil, err
+	}
+	p.Center = vectorToSI(p.Center, flow.DimLength)
+	if p.Normal, err = vectorArg("normal", args[2]); err != nil {
+		return nil, err
+	}
+	if p.Radius, err = dimArg("radius", flow.DimLength, args[3]); err != nil {
+		return nil, err
+	}
+	if p.Rings, err = intArg("nRings", args[4]); err != nil {
+		return nil, err
+	}
+	if err := sim.Probes.Define(p); err != nil {
+		return nil, err
+	}
+	return probesState(), nil
+}
+
+// removeFlowProbe(id)
+//
+// Removes probe id and its history. Returns whether there was one.
+func removeFlowProbe(args []js.Value) (interface{}, error) {
+	if err := checkArgs("removeFlowProbe", args, 1); err != nil {
+		return nil, err
+	}
+	id, err := intArg("id", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return sim.Probes.Remove(id), nil
+}
+
+// getProbeHistory(id)
+//
+// Returns the history of probe id as {time, rate}: parallel Float64Arrays
+// of the time of each step, in seconds, and the volume flow rate through
+// the probe then, along its normal, oldest first. Sampling starts with the
+// first step after the probe is defined; the latest 65536 samples are
+// kept. Rates are in the velocity unit of setUnits times the square of
+// its length unit.
+func getProbeHistory(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getProbeHistory", args, 1); err != nil {
+		return nil, err
+	}
+	p, err := probeArg("getProbeHistory", args[0])
+	if err != nil {
+		return nil, err
+	}
+	t, rate := p.History()
+	l := units.Factor(flow.DimLength)
+	flow.Scale(rate, 1/(units.Factor(flow.DimVelocity)*l*l))
+	return map[string]interface{}{"time": floatsToJS(t), "rate": floatsToJS(rate)}, nil
+}
@@ -0,0 +1,139 @@
+//go:build js && wasm
+// +build js,wasm
+
+// record.go - Frame recording and playback
+//
+// While a recording is active, every step of the stateful API snapshots the
+// particle positions (and optionally a scalar field) into Go memory, so
+// frames can be played back at a steady rate regardless of how long each
+// step took.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// recorder is the current recording, or nil
+var recorder *flow.Recorder
+
+// startRecording(everyNSteps, maxFrames[, options])
+//
+// Starts a new recording, discarding any previous one. Options:
+//   - field: "pressure" or "speed" to record a value per particle
+//   - downsample: record every kth particle (default 1)
+//   - maxBytes: memory limit (default 256 MiB)
+//
+// Each frame takes 12 bytes per recorded particle (16 with a field). Fails
+// with a LIMIT error if maxFrames frames of the current particles wouldn't
+// fit; otherwise returns the projected size in bytes. If the particle
+// count grows during the recording, it stops early once the limit is hit,
+// as reported by getRecordingInfo.
+func startRecording(args []js.Value) (interface{}, error) {
+	if err := checkArgs("startRecording", args, 2); err != nil {
+		return nil, err
+	}
+	every, err := intArg("everyNSteps", args[0])
+	if err != nil {
+		return nil, err
+	}
+	maxFrames, err := intArg("maxFrames", args[1])
+	if err != nil {
+		return nil, err
+	}
+	field, stride, maxBytes := flow.FieldNone, 0, 0
+	if opts := optionalArg(args, 2); opts.Type() == js.TypeObject {
+		if v := opts.Get("field"); !v.IsUndefined() {
+			field = v.String()
+		}
+		if v := opts.Get("downsample"); !v.IsUndefined() {
+			if stride, err = intArg("downsample", v); err != nil {
+				return nil, err
+			}
+		}
+		if v := opts.Get("maxBytes"); !v.IsUndefined() {
+			if maxBytes, err = intArg("maxBytes", v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r, err := flow.NewRecorder(every, maxFrames, stride, field, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.CheckLimit(sim.Count()); err != nil {
+		return nil, err
+	}
+	recorder = r
+	sim.Recorder = r
+	return r.FrameBytes(sim.Count()) * maxFrames, nil
+}
+
+// stopRecording() stops recording, keeping the frames, and returns their
+// number
+func stopRecording(args []js.Value) (interface{}, error) {
+	if recorder == nil {
+		return 0, nil
+	}
+	recorder.Active = false
+	sim.Recorder = nil
+	return len(recorder.Frames), nil
+}
+
+// clearRecording() stops recording and frees the frames
+func clearRecording(args []js.Value) (interface{}, error) {
+	recorder = nil
+	sim.Recorder = nil
+	return nil, nil
+}
+
+// getRecordingInfo() returns {active, frames, bytes, maxBytes, everyNSteps,
+// downsample, field, error}; error is the reason recording stopped early,
+// or null
+func getRecordingInfo(args []js.Value) (interface{}, error) {
+	if recorder == nil {
+		return map[string]interface{}{"active": false, "frames": 0, "bytes": 0}, nil
+	}
+	r := recorder
+	var stopped interface{}
+	if r.Err != nil {
+		stopped = jsError(r.Err)
+	}
+	return map[string]interface{}{
+		"active":      r.Active,
+		"frames":      len(r.Frames),
+		"bytes":       r.Bytes(),
+		"maxBytes":    r.MaxBytes,
+		"everyNSteps": r.Every,
+		"downsample":  r.Stride,
+		"field":       r.Field,
+		"error":       stopped,
+	}, nil
+}
+
+// getRecordedFrame(i) returns frame i as {time, positions, field}, with
+// field omitted when none was recorded, both in the units of setUnits.
+// Playback iterates i from 0 to getRecordingInfo().frames - 1.
+func getRecordedFrame(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getRecordedFrame", args, 1); err != nil {
+		return nil, err
+	}
+	i, err := intArg("i", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if recorder == nil || i < 0 || i >= len(recorder.Frames) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "getRecordedFrame: no frame %d", i)
+	}
+	f := recorder.Frames[i]
+	out := map[string]interface{}{
+		"time":      f.Time,
+		"positions": floatsToJSIn(f.Positions, flow.DimLength),
+	}
+	if f.Field != nil {
+		out["field"] = floatsToJSIn(f.Field, flow.FieldDim(recorder.Field))
+	}
+	return out, nil
+}
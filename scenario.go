@@ -0,0 +1,109 @@
+//go:build js && wasm
+// +build js,wasm
+
+// scenario.go - Scenario and snapshot export and import
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// exportScenario([options])
+//
+// Returns the state of the stateful API as a JSON string (see flow.Scenario
+// for the layout). With options.includeParticles set the particle positions
+// are included, which makes the string large but lets importScenario
+// reproduce a simulation that has already been stepped. Scenarios are
+// always in SI units, whatever setUnits says, so they load the same
+// anywhere.
+func exportScenario(args []js.Value) (interface{}, error) {
+	include := false
+	if opts := optionalArg(args, 0); opts.Type() == js.TypeObject {
+		include = opts.Get("includeParticles").Truthy()
+	}
+	sc := sim.Scenario(include)
+	return js.Global().Get("JSON").Call("stringify", js.ValueOf(sc.Encode())), nil
+}
+
+// importScenario(json)
+//
+// Replaces the state of the stateful API with the scenario in json and
+// returns an array of warnings about fields that were ignored. Registered
+// arrays are dropped; read the particles back with getParticles.
+func importScenario(args []js.Value) (interface{}, error) {
+	if err := checkArgs("importScenario", args, 1); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeString {
+		return nil, flow.Errorf(flow.ErrBadArguments, "importScenario: expected a JSON string")
+	}
+	v, err := parseJSON(args[0])
+	if err != nil {
+		return nil, err
+	}
+	sc, warnings, err := flow.DecodeScenario(goValue(v))
+	if err != nil {
+		return nil, err
+	}
+	s, err := sc.Simulation()
+	if err != nil {
+		return nil, err
+	}
+	s.Inherit(sim)
+	sim = s
+	registered.positions, registered.velocities, registered.pressures = js.Undefined(), js.Undefined(), js.Undefined()
+
+	out := make([]interface{}, len(warnings))
+	for i, w := range warnings {
+		out[i] = w
+	}
+	return out, nil
+}
+
+// parseJSON parses a JSON string with JSON.parse, turning a SyntaxError into
+// an error
+func parseJSON(s js.Value) (v js.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = flow.Errorf(flow.ErrBadArguments, "invalid JSON: %v", r)
+		}
+	}()
+	return js.Global().Get("JSON").Call("parse", s), nil
+}
+
+// exportSnapshot() returns the full state of the stateful API (config,
+// clock, statistics, seeding, elements, motion, schedule, probes, random
+// generator and particle buffers) as a Uint8Array in the
+// binary layout documented in internal/flow/snapshot.go, in SI units as
+// scenarios are
+func exportSnapshot(args []js.Value) (interface{}, error) {
+	b, err := sim.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return bytesToJS(b), nil
+}
+
+// importSnapshot(bytes)
+//
+// Restores the state saved by exportSnapshot from a Uint8Array and returns
+// the particle count. Snapshots of another version fail with UNSUPPORTED,
+// truncated or corrupt ones with BUFFER_LENGTH. As with importScenario,
+// registered arrays are dropped.
+func importSnapshot(args []js.Value) (interface{}, error) {
+	if err := checkArgs("importSnapshot", args, 1); err != nil {
+		return nil, err
+	}
+	if !args[0].InstanceOf(js.Global().Get("Uint8Array")) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "importSnapshot: expected a Uint8Array")
+	}
+	b := make([]byte, args[0].Length())
+	js.CopyBytesToGo(b, args[0])
+	if err := sim.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+	registered.positions, registered.velocities, registered.pressures = js.Undefined(), js.Undefined(), js.Undefined()
+	return sim.Count(), nil
+}
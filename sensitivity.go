@@ -0,0 +1,54 @@
+//go:build js && wasm
+// +build js,wasm
+
+// sensitivity.go - Derivatives of the velocity field with respect to flow parameters
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// evaluateSensitivity(points, n, parameter)
+//
+// Returns the derivative of the velocity of the stateful API's flow with
+// respect to parameter, "radius" (the object's) or "freeStream", at n
+// points, an array of x, y, z positions: {values, analytic, step, error}.
+// values is a Float32Array of ∂v/∂p in the layout of the velocity arrays,
+// three components per point. A sphere or cylinder alone in a uniform
+// free stream gets it in closed form, analytic true and step 0; any other
+// flow gets central differences, step being the one chosen among 1e-2 to
+// 1e-7 times the parameter, where the derivative changes least at the
+// next smaller step, and error that change relative to the largest
+// derivative. Points and the radius step are in the length unit of
+// setUnits, the free stream step in its velocity unit; radius derivatives
+// are in its velocity per length, free stream ones dimensionless. See
+// flow.VelocitySensitivity.
+func evaluateSensitivity(args []js.Value) (interface{}, error) {
+	if err := checkArgs("evaluateSensitivity", args, 3); err != nil {
+		return nil, err
+	}
+	n, err := intArg("n", args[1])
+	if err != nil {
+		return nil, err
+	}
+	p, err := floatsFromJS[float64]("points", args[0], n, 3)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(p)
+	toSI(p, flow.DimLength)
+	param := args[2].String()
+	s, err := flow.VelocitySensitivity(p, n, sim.Flow(), param)
+	if err != nil {
+		return nil, err
+	}
+	value, derivative := flow.ParamDims(param)
+	return map[string]interface{}{
+		"values":   floatsToJSIn(s.Values, derivative),
+		"analytic": s.Analytic,
+		"step":     units.FromSI(value, s.Step),
+		"error":    s.Error,
+	}, nil
+}
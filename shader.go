@@ -0,0 +1,53 @@
+//go:build js && wasm
+// +build js,wasm
+
+// shader.go - GPU source for the velocity field
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// generateShader(language[, config])
+//
+// Returns {language, entryPoint, source, version}: source is a
+// self-contained "glsl" (GLSL ES 3.0) or "wgsl" function
+// flowVelocity(p) -> vec3 computing the same velocity field as the kernels,
+// for config (see flow.Config) or by default the stateful API's
+// configuration, including the velocity of a moving object. The parameters
+// are baked in as constants, so regenerate the function when the
+// configuration changes or the object moves; version is the value of
+// getObjectVersion it was built for. Imported panel bodies don't affect the
+// flow and are not part of it. config and the function's p and result are
+// in the units of setUnits.
+func generateShader(args []js.Value) (interface{}, error) {
+	if err := checkArgs("generateShader", args, 1); err != nil {
+		return nil, err
+	}
+	f := sim.Flow()
+	if v := optionalArg(args, 1); !v.IsUndefined() && !v.IsNull() {
+		config, err := flow.DecodeConfig(goValueSI(v, flow.ConfigDims))
+		if err != nil {
+			return nil, err
+		}
+		f = config.Flow()
+	}
+	sh, err := flow.NewShader(f)
+	if err != nil {
+		return nil, err
+	}
+	sh.Units = units
+	language := args[0].String()
+	source, err := sh.Source(language)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"language":   language,
+		"entryPoint": "flowVelocity",
+		"source":     source,
+		"version":    sim.ObjectVersion,
+	}, nil
+}
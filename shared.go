@@ -0,0 +1,211 @@
+//go:build js && wasm
+// +build js,wasm
+
+// shared.go - SharedArrayBuffer mode
+//
+// attachBuffers registers Float32Arrays backed by SharedArrayBuffers once;
+// each stepShared call then updates them in place and returns only a frame
+// counter, so no arrays are allocated or passed per frame.
+//
+// syscall/js can't address JavaScript memory directly, so every frame still
+// moves each buffer with a single bulk CopyBytes into reused Go scratch
+// space; there are no per-element calls and no allocations on either side.
+//
+// Synchronization contract: stepShared writes the buffers while it runs.
+// When it runs on the thread that reads them, simply don't read during the
+// call. When another thread reads them (stepShared running in a Worker),
+// pass [front, back] pairs for velocities and pressures plus a control
+// Int32Array: Go writes into the buffer not currently published, then sets
+// control[1] to its index (0 or 1) and control[0] to the frame number with
+// Atomics.store, and calls Atomics.notify on control[0]. Readers use the
+// pair entry named by Atomics.load(control, 1).
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// sharedState holds the attached buffers and the Go-side scratch space
+type sharedState struct {
+	attached   bool
+	count      int
+	positions  js.Value
+	velocities [2]js.Value // Second entry is undefined unless double-buffered
+	pressures  [2]js.Value
+	control    js.Value
+	front      int // Index of the published velocity/pressure buffer
+	frame      int
+
+	positionsGo  []float32
+	velocitiesGo []float32
+	pressuresGo  []float32
+}
+
+var shared sharedState
+
+// sharedView validates a Float32Array view over a SharedArrayBuffer holding
+// at least n floats
+func sharedView(name string, v js.Value, n int) error {
+	if !v.InstanceOf(js.Global().Get("Float32Array")) {
+		return flow.Errorf(flow.ErrBadArguments, "%s must be a Float32Array", name)
+	}
+	if !v.Get("buffer").InstanceOf(js.Global().Get("SharedArrayBuffer")) {
+		return flow.Errorf(flow.ErrBadArguments, "%s must be backed by a SharedArrayBuffer", name)
+	}
+	return flow.CheckBuffer(name, v.Length(), n, 1)
+}
+
+// sharedPair reads a single shared view or a [front, back] pair
+func sharedPair(name string, v js.Value, n int) ([2]js.Value, error) {
+	pair := [2]js.Value{v, js.Undefined()}
+	if js.Global().Get("Array").Call("isArray", v).Bool() {
+		if v.Length() != 2 {
+			return pair, flow.Errorf(flow.ErrBadArguments, "%s must be a Float32Array or a [front, back] pair", name)
+		}
+		pair = [2]js.Value{v.Index(0), v.Index(1)}
+	}
+	for _, b := range pair {
+		if b.IsUndefined() {
+			continue
+		}
+		if err := sharedView(name, b, n); err != nil {
+			return pair, err
+		}
+	}
+	return pair, nil
+}
+
+// attachBuffers(positions, velocities, pressures, count[, control])
+//
+// Registers the shared buffers updated by stepShared. velocities and
+// pressures may each be a [front, back] pair, in which case control (an
+// Int32Array of length 2 over a SharedArrayBuffer) is required. Fails with
+// an UNSUPPORTED error when SharedArrayBuffer is unavailable, i.e. the page
+// is not cross-origin isolated.
+func attachBuffers(args []js.Value) (interface{}, error) {
+	if js.Global().Get("SharedArrayBuffer").IsUndefined() {
+		return nil, flow.Errorf(flow.ErrUnsupported,
+			"SharedArrayBuffer is not available; serve the page with COOP/COEP headers or use updateVelocities")
+	}
+	if err := checkArgs("attachBuffers", args, 4); err != nil {
+		return nil, err
+	}
+	count, err := countArg(args[3])
+	if err != nil {
+		return nil, err
+	}
+	if err := sharedView("positions", args[0], count*3); err != nil {
+		return nil, err
+	}
+	velocities, err := sharedPair("velocities", args[1], count*3)
+	if err != nil {
+		return nil, err
+	}
+	pressures, err := sharedPair("pressures", args[2], count)
+	if err != nil {
+		return nil, err
+	}
+
+	control := optionalArg(args, 4)
+	double := !velocities[1].IsUndefined() || !pressures[1].IsUndefined()
+	if double && (velocities[1].IsUndefined() || pressures[1].IsUndefined()) {
+		return nil, flow.Errorf(flow.ErrBadArguments, "velocities and pressures must both be pairs when double-buffering")
+	}
+	if !control.IsUndefined() {
+		if !control.InstanceOf(js.Global().Get("Int32Array")) || control.Length() < 2 ||
+			!control.Get("buffer").InstanceOf(js.Global().Get("SharedArrayBuffer")) {
+			return nil, flow.Errorf(flow.ErrBadArguments, "control must be an Int32Array of length 2 over a SharedArrayBuffer")
+		}
+	} else if double {
+		return nil, flow.Errorf(flow.ErrBadArguments, "double-buffering requires a control Int32Array")
+	}
+
+	shared = sharedState{
+		attached:     true,
+		count:        count,
+		positions:    args[0],
+		velocities:   velocities,
+		pressures:    pressures,
+		control:      control,
+		positionsGo:  make([]float32, count*3),
+		velocitiesGo: make([]float32, count*3),
+		pressuresGo:  make([]float32, count),
+	}
+	return nil, nil
+}
+
+// detachBuffers forgets the attached buffers
+func detachBuffers(args []js.Value) (interface{}, error) {
+	shared = sharedState{}
+	return nil, nil
+}
+
+// stepShared(freeStreamVelocity, fluidDensity, objectX, objectY, objectZ, objectType, objectRadius[, dt])
+//
+// Evaluates velocities and pressures for the attached positions and writes
+// them to the shared buffers. With dt > 0 the positions are also advanced
+// by one explicit Euler step of the new velocities. The parameters and
+// buffers are in the units of setUnits, dt in seconds. Returns the frame
+// number.
+func stepShared(args []js.Value) (interface{}, error) {
+	if !shared.attached {
+		return nil, flow.Errorf(flow.ErrBadArguments, "stepShared: call attachBuffers first")
+	}
+	if err := checkArgs("stepShared", args, 7); err != nil {
+		return nil, err
+	}
+	f, err := flowArgs(args[:7])
+	if err != nil {
+		return nil, err
+	}
+	dt := 0.0
+	if v := optionalArg(args, 7); !v.IsUndefined() {
+		if dt, err = floatArg("dt", v); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &shared
+	copyFromJS(s.positionsGo, s.positions)
+	toSI(s.positionsGo, flow.DimLength)
+	err = timed("stepShared", func() error {
+		if err := flow.VelocitiesInto(s.velocitiesGo, s.positionsGo, s.count, f); err != nil {
+			return err
+		}
+		if err := flow.PressuresInto(s.pressuresGo, s.velocitiesGo, s.count, f.FreeStream, f.Density); err != nil {
+			return err
+		}
+		if dt > 0 {
+			flow.Advect(s.positionsGo, s.velocitiesGo, s.count, dt)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fromSI(s.velocitiesGo, flow.DimVelocity)
+	fromSI(s.pressuresGo, flow.DimPressure)
+	if dt > 0 {
+		fromSI(s.positionsGo, flow.DimLength)
+		copyToJS(s.positions, s.positionsGo)
+	}
+
+	back := 0
+	if !s.velocities[1].IsUndefined() {
+		back = 1 - s.front
+	}
+	copyToJS(s.velocities[back], s.velocitiesGo)
+	copyToJS(s.pressures[back], s.pressuresGo)
+	s.front = back
+	s.frame++
+
+	if !s.control.IsUndefined() {
+		atomics := js.Global().Get("Atomics")
+		atomics.Call("store", s.control, 1, s.front)
+		atomics.Call("store", s.control, 0, s.frame)
+		atomics.Call("notify", s.control, 0)
+	}
+	return s.frame, nil
+}
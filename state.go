@@ -0,0 +1,1032 @@
+//go:build js && wasm
+// +build js,wasm
+
+// state.go - Stateful API
+//
+// configure stores the flow description in Go once; registerParticles hands
+// over the particle buffers, and each step(dt) call advects them and
+// refreshes their velocities and pressures. The setters change single
+// fields cheaply, e.g. from UI sliders, and getConfig returns the effective
+// configuration with every default filled in.
+//
+// Go owns the particle state: after each step it is copied back into the
+// registered arrays, and changes JavaScript makes to them in the meantime
+// are overwritten unless registerParticles is called again.
+//
+// Every value is exchanged in the units of setUnits and kept in SI.
+package main
+
+import (
+	"math"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// sim is the state behind the stateful API
+var sim = flow.NewSimulation(flow.DefaultConfig())
+
+// registered holds the arrays updated by step; velocities and pressures
+// are undefined unless they were registered. count is the particle count
+// last written to them.
+var registered struct {
+	positions, velocities, pressures js.Value
+	count                            int
+}
+
+// configure(config) replaces the configuration with config (see flow.Config
+// for the schema) and returns the effective configuration
+func configure(args []js.Value) (interface{}, error) {
+	if err := checkArgs("configure", args, 1); err != nil {
+		return nil, err
+	}
+	c, err := flow.DecodeConfig(goValueSI(args[0], flow.ConfigDims))
+	if err != nil {
+		return nil, err
+	}
+	sim.SetConfig(c)
+	return getConfig(nil)
+}
+
+// getConfig() returns the full effective configuration
+func getConfig(args []js.Value) (interface{}, error) {
+	return js.ValueOf(units.Convert(sim.Config.Encode(), flow.ConfigDims, false)), nil
+}
+
+// registerParticles(positions[, velocities[, pressures[, count]]])
+//
+// Loads the first count particles, all of them by default, from positions
+// (a Float32Array of x,y,z triples) and registers the arrays that step
+// updates: positions itself, velocities (capacity*3 floats) and pressures
+// (capacity floats), all Float32Arrays, where the capacity is
+// positions.length/3. Room past count takes the particles injection and
+// density control add: step writes the first getState().count particles,
+// zeroes the slots of removed ones and drops the arrays only once the
+// count exceeds the capacity, so arrays sized to the particle budget of
+// setParticleBudget are kept for good. Returns the particle count.
+func registerParticles(args []js.Value) (interface{}, error) {
+	if err := checkArgs("registerParticles", args, 1); err != nil {
+		return nil, err
+	}
+	positions := args[0]
+	if err := float32View("positions", positions, 0); err != nil {
+		return nil, err
+	}
+	capacity := positions.Length() / 3
+	velocities, pressures := optionalArg(args, 1), optionalArg(args, 2)
+	if !velocities.IsUndefined() {
+		if err := float32View("velocities", velocities, capacity*3); err != nil {
+			return nil, err
+		}
+	}
+	if !pressures.IsUndefined() {
+		if err := float32View("pressures", pressures, capacity); err != nil {
+			return nil, err
+		}
+	}
+	count := capacity
+	if v := optionalArg(args, 3); !v.IsUndefined() {
+		var err error
+		if count, err = countArg(v); err != nil {
+			return nil, err
+		}
+		if count > capacity {
+			return nil, flow.Errorf(flow.ErrBufferLength, "registerParticles: count %d exceeds the %d particles positions holds", count, capacity)
+		}
+	}
+
+	p := make([]float32, count*3)
+	copyFromJS(p, positions)
+	toSI(p, flow.DimLength)
+	if err := sim.SetParticles(p, count); err != nil {
+		return nil, err
+	}
+	registered.positions, registered.velocities, registered.pressures = positions, velocities, pressures
+	registered.count = count
+	return count, nil
+}
+
+// float32View checks that v is a Float32Array of at least n floats
+func float32View(name string, v js.Value, n int) error {
+	if !v.InstanceOf(float32Array) {
+		return flow.Errorf(flow.ErrBadArguments, "%s must be a Float32Array", name)
+	}
+	return flow.CheckBuffer(name, v.Length(), n, 1)
+}
+
+// seedParticles(spec)
+//
+// Replaces the particles with generated ones (see flow.DecodeSeedSpec for
+// spec) and returns their count. Random seeding without a seed draws one
+// from the generator of setSeed. The registered arrays are dropped, as they
+// no longer match; read the particles back with getParticles.
+func seedParticles(args []js.Value) (interface{}, error) {
+	if err := checkArgs("seedParticles", args, 1); err != nil {
+		return nil, err
+	}
+	spec, err := flow.DecodeSeedSpec(goValueSI(args[0], flow.GridDims))
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.Seed(spec); err != nil {
+		return nil, err
+	}
+	registered.positions, registered.velocities, registered.pressures = js.Undefined(), js.Undefined(), js.Undefined()
+	return sim.Count(), nil
+}
+
+// setSeed(seed)
+//
+// Restarts the random generator behind every stochastic feature (see
+// flow.Random) from seed, an integer between 0 and 2^53-1, and returns it.
+// Each subsystem draws from a stream of its own, so runs from the same seed
+// see the same sequences whatever else is enabled; getState().random
+// reports the seed and the values drawn from each stream, and scenarios
+// save and restore both. Random seeding without an explicit seed draws
+// from the "seeding" stream.
+func setSeed(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setSeed", args, 1); err != nil {
+		return nil, err
+	}
+	seed, err := floatArg("seed", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if !(seed >= 0) || seed != math.Trunc(seed) || seed > flow.MaxSeed {
+		return nil, flow.Errorf(flow.ErrBadArguments, "seed must be an integer between 0 and %d, got %g", uint64(flow.MaxSeed), seed)
+	}
+	if err := sim.Random.SetSeed(uint64(seed)); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// getParticles() returns copies of the particle state as
+// {positions, velocities, pressures} Float32Arrays, with sources, a
+// Uint8Array of the injector of each particle, 0 for none (see
+// defineInjector)
+func getParticles(args []js.Value) (interface{}, error) {
+	return map[string]interface{}{
+		"positions":  floatsToJSIn(sim.Positions, flow.DimLength),
+		"velocities": floatsToJSIn(sim.Velocities, flow.DimVelocity),
+		"pressures":  floatsToJSIn(sim.Pressures, flow.DimPressure),
+		"sources":    uint8sToJS(sim.ParticleSources()),
+	}, nil
+}
+
+// step([dt])
+//
+// Evaluates velocities and pressures at the current positions, then
+// advances the particles and the clock by dt (default: the configured time
+// step) times the time scale of setTimeScale and writes the results to the
+// registered arrays. While paused it does nothing. Returns the simulation
+// time.
+func step(args []js.Value) (interface{}, error) {
+	dt := sim.DT
+	if v := optionalArg(args, 0); !v.IsUndefined() {
+		var err error
+		if dt, err = floatArg("dt", v); err != nil {
+			return nil, err
+		}
+	}
+	far := sim.Stats.FarField
+	if err := timed("step", func() error { return sim.Step(dt) }); err != nil {
+		return nil, err
+	}
+	farFieldLast = sim.Stats.FarField - far
+	writeBack()
+	if err := dispatchEvents(); err != nil {
+		return nil, err
+	}
+	return sim.Time, nil
+}
+
+// writeBack copies the particle state to the registered arrays, zeroing
+// the slots of the particles removed since the last copy, and drops them
+// once density control or injection grew the particle count past their
+// capacity
+func writeBack() {
+	n := sim.Count()
+	if !registered.positions.IsUndefined() && registered.positions.Length()/3 < n {
+		registered.positions, registered.velocities, registered.pressures = js.Undefined(), js.Undefined(), js.Undefined()
+	}
+	if !registered.positions.IsUndefined() {
+		copyToJSIn(registered.positions, sim.Positions, flow.DimLength)
+	}
+	if !registered.velocities.IsUndefined() {
+		copyToJSIn(registered.velocities, sim.Velocities, flow.DimVelocity)
+	}
+	if !registered.pressures.IsUndefined() {
+		copyToJSIn(registered.pressures, sim.Pressures, flow.DimPressure)
+	}
+	if n < registered.count {
+		for _, r := range []struct {
+			v      js.Value
+			stride int
+		}{{registered.positions, 3}, {registered.velocities, 3}, {registered.pressures, 1}} {
+			if !r.v.IsUndefined() {
+				r.v.Call("fill", 0, n*r.stride, registered.count*r.stride)
+			}
+		}
+	}
+	registered.count = n
+}
+
+// stepMany(nSubsteps[, dt[, options]])
+//
+// Runs nSubsteps steps of length dt (default: the configured time step)
+// in one call, exactly as that many step calls would, and writes only the
+// final state to the registered arrays. Options:
+//   - every, frames: also copies the positions after every every-th
+//     substep into frames, a Float32Array of at least
+//     floor(nSubsteps/every)*count*3 floats, e.g. for motion blur
+//   - counts: a Uint32Array of at least floor(nSubsteps/every) elements
+//     receiving the number of particles of each frame. count is the
+//     particle count at the call; injection and density control change it
+//     between substeps, and a frame holds the first particles of its
+//     substep up to count, the rest of its block zero
+//
+// Returns the simulation time. Invalid arguments leave the state untouched.
+func stepMany(args []js.Value) (interface{}, error) {
+	if err := checkArgs("stepMany", args, 1); err != nil {
+		return nil, err
+	}
+	n, err := intArg("nSubsteps", args[0])
+	if err != nil {
+		return nil, err
+	}
+	dt := sim.DT
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		if dt, err = floatArg("dt", v); err != nil {
+			return nil, err
+		}
+	}
+	every, framesJS, countsJS := 0, js.Undefined(), js.Undefined()
+	var counts []uint32
+	if opts := optionalArg(args, 2); opts.Type() == js.TypeObject {
+		if v := opts.Get("every"); !v.IsUndefined() {
+			if every, err = intArg("every", v); err != nil {
+				return nil, err
+			}
+			if every < 1 {
+				return nil, flow.Errorf(flow.ErrBadArguments, "every must be positive, got %d", every)
+			}
+			framesJS = opts.Get("frames")
+			if err := float32View("frames", framesJS, 0); err != nil {
+				return nil, err
+			}
+			if countsJS = opts.Get("counts"); !countsJS.IsUndefined() {
+				if !countsJS.InstanceOf(js.Global().Get("Uint32Array")) {
+					return nil, flow.Errorf(flow.ErrBadArguments, "counts must be a Uint32Array")
+				}
+				counts = make([]uint32, min(countsJS.Length(), n/every))
+			}
+		}
+	}
+
+	var frames []float32
+	count := sim.Count()
+	if every > 0 {
+		frames = make([]float32, min(framesJS.Length(), n/every*count*3))
+	}
+	far := sim.Stats.FarField
+	if err := timed("step", func() error { return sim.StepMany(n, dt, every, frames, counts) }); err != nil {
+		return nil, err
+	}
+	farFieldLast = sim.Stats.FarField - far
+	writeBack()
+	if every > 0 {
+		copyToJSIn(framesJS, frames[:n/every*count*3], flow.DimLength)
+	}
+	if counts != nil {
+		copyUint32sToJS(countsJS, counts)
+	}
+	if err := dispatchEvents(); err != nil {
+		return nil, err
+	}
+	return sim.Time, nil
+}
+
+// pause() stops the simulation clock: step, stepMany and updatePartial
+// become no-ops until resume, while the one-shot functions and field
+// queries keep working. Returns the simulation time.
+func pause(args []js.Value) (interface{}, error) {
+	sim.Paused = true
+	return sim.Time, nil
+}
+
+// resume() restarts the clock stopped by pause and returns the simulation
+// time
+func resume(args []js.Value) (interface{}, error) {
+	sim.Paused = false
+	return sim.Time, nil
+}
+
+// reset([keepConfig])
+//
+// Rewinds the stateful API to time zero: statistics are cleared, the
+// random generator restarts from its seed and the particles are reseeded
+// from their seeding spec (particles registered from an array are kept).
+// With keepConfig false the configuration and time step return to their
+// defaults too, and the motion, schedule, elements of buildFlow and probes
+// are removed; it defaults to true. Pause and time scale are kept.
+// Returns the particle count.
+func reset(args []js.Value) (interface{}, error) {
+	keep := true
+	if v := optionalArg(args, 0); !v.IsUndefined() {
+		keep = v.Truthy()
+	}
+	if err := sim.Reset(keep); err != nil {
+		return nil, err
+	}
+	writeBack()
+	return sim.Count(), nil
+}
+
+// setTimeScale(scale) multiplies the dt of every subsequent step by scale,
+// e.g. 0.25 for slow motion, and returns it. 0 freezes the motion while
+// still evaluating the field.
+func setTimeScale(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setTimeScale", args, 1); err != nil {
+		return nil, err
+	}
+	scale, err := floatArg("scale", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.SetTimeScale(scale); err != nil {
+		return nil, err
+	}
+	return scale, nil
+}
+
+// updatePartial(budgetMs[, dt])
+//
+// A step for when a full velocity update doesn't fit in the frame: it
+// refreshes the velocities and pressures of as many particles as fit in
+// budgetMs milliseconds, checking the clock every flow.PartialChunk
+// particles, then advects every particle by dt (default: the configured
+// time step) as step does. The next call resumes where this one stopped,
+// wrapping around, so every particle is refreshed in turn; the others keep
+// their previous velocities. getState().partial.cursor is the next
+// particle to refresh; it restarts at 0 when the particles are replaced.
+//
+// Returns the fraction of the particles refreshed by this call, 1 once a
+// call covers all of them, so the app can adapt its particle count.
+func updatePartial(args []js.Value) (interface{}, error) {
+	if err := checkArgs("updatePartial", args, 1); err != nil {
+		return nil, err
+	}
+	budget, err := floatArg("budgetMs", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if budget < 0 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "budgetMs must be non-negative, got %g", budget)
+	}
+	dt := sim.DT
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		if dt, err = floatArg("dt", v); err != nil {
+			return nil, err
+		}
+	}
+	deadline := now() + budget
+	far, updated := sim.Stats.FarField, 0
+	if err := timed("partial", func() (err error) {
+		updated, err = sim.UpdatePartial(dt, func() bool { return now() >= deadline })
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	farFieldLast = sim.Stats.FarField - far
+	writeBack()
+	if err := dispatchEvents(); err != nil {
+		return nil, err
+	}
+	if sim.Count() == 0 {
+		return 1.0, nil
+	}
+	return float64(updated) / float64(sim.Count()), nil
+}
+
+// setFreeStream(speed[, direction])
+//
+// Changes the free stream speed and, if given as an [x, y, z] array, its
+// direction
+func setFreeStream(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFreeStream", args, 1); err != nil {
+		return nil, err
+	}
+	speed, err := dimArg("speed", flow.DimVelocity, args[0])
+	if err != nil {
+		return nil, err
+	}
+	var direction [3]float64
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		if direction, err = directionArg(v); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetFreeStream(speed, direction)
+}
+
+// setFreeStreamShear(rate[, y0])
+//
+// Makes the free stream a linear shear flow, U(y) = speed + rate·(y - y0)
+// along its direction, y0 defaulting to 0; a rate of 0 restores the
+// uniform stream. It is short for setFreeStreamProfile({type: "shear",
+// rate, y0}).
+func setFreeStreamShear(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFreeStreamShear", args, 1); err != nil {
+		return nil, err
+	}
+	p := flow.Profile{Type: flow.ProfileShear}
+	var err error
+	if p.Rate, err = dimArg("rate", flow.DimRate, args[0]); err != nil {
+		return nil, err
+	}
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		if p.Ref, err = dimArg("y0", flow.DimLength, v); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetProfile(p)
+}
+
+// setFreeStreamProfile(profile)
+//
+// Makes the free stream speed vary with height y, the free stream speed
+// being the speed at the reference height: profile is {type: "uniform"},
+// {type: "shear", rate, y0}, the power law of an atmospheric boundary
+// layer {type: "power", zref, alpha, floor}, U·((y - floor)/zref)^alpha,
+// the log law {type: "log", zref, z0, floor},
+// U·ln((y - floor)/z0)/ln(zref/z0), both 0 below the floor, which
+// defaults to 0, or horizontal bands {type: "layered", speeds, heights,
+// thickness}: up to 8 bands moving at speeds[i]·U, from the bottom up,
+// meeting at heights, one fewer, blended by tanh over thickness, by
+// default 0 for a sharp shear layer. {speeds: [U1/U, U2/U], heights: [y0],
+// thickness: δ} is U1 below y0 and U2 above; an infinite thickness or
+// equal speeds give a uniform stream. null restores the uniform stream.
+// The object sees the onset speed at its center, see flow.Flow.Profile for
+// the approximation. The config form is freeStream.profile.
+func setFreeStreamProfile(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFreeStreamProfile", args, 1); err != nil {
+		return nil, err
+	}
+	var p flow.Profile
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if p, err = flow.DecodeProfile(goValueSI(v, flow.ProfileDims)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetProfile(p)
+}
+
+// setFreeStreamSwirl(number[, radius[, type]])
+//
+// Adds a swirl about the free stream axis through the object's center,
+// right-handed about the free stream direction: number is the tangential
+// speed at radius, default 1, over the free stream speed at the object's
+// center. Within radius the fluid turns as a solid body; beyond it the
+// tangential speed is clamped for type "solid", the default, and decays
+// as a free vortex for "free". A number of 0 removes the swirl. Pressures
+// include the swirl's radial equilibrium, see flow.AddSwirlPressures. The
+// config form is freeStream.swirl: {type, number, radius}.
+func setFreeStreamSwirl(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFreeStreamSwirl", args, 1); err != nil {
+		return nil, err
+	}
+	w := flow.DefaultSwirl()
+	var err error
+	if w.Number, err = floatArg("number", args[0]); err != nil {
+		return nil, err
+	}
+	if v := optionalArg(args, 1); !v.IsUndefined() {
+		if w.Radius, err = dimArg("radius", flow.DimLength, v); err != nil {
+			return nil, err
+		}
+	}
+	if v := optionalArg(args, 2); !v.IsUndefined() {
+		if v.Type() != js.TypeString {
+			return nil, flow.Errorf(flow.ErrBadArguments, "type must be a string")
+		}
+		w.Type = v.String()
+	}
+	return nil, sim.SetSwirl(w)
+}
+
+// setFreeStreamSchedule(schedule)
+//
+// Makes the free stream speed follow the simulation clock: schedule is an
+// array of [t, U] pairs, or a Float64Array of t0, U0, t1, U1, ..., with
+// increasing times, interpolated linearly and held before the first and
+// after the last entry, or {ramp: {speed, duration}} for a linear start-up
+// from 0. The direction is unchanged. Steps, field queries and exports all
+// use the instantaneous speed, which getState().freeStream.speed reports;
+// reset restarts the schedule with the clock and scenarios save it. While
+// a schedule is set, setFreeStream only changes the direction and the
+// speed used once it is removed with null. There is no oscillating free
+// stream mode to combine it with in this build. Speeds are in the
+// velocity unit of setUnits, times in seconds.
+func setFreeStreamSchedule(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFreeStreamSchedule", args, 1); err != nil {
+		return nil, err
+	}
+	v := args[0]
+	if v.IsNull() || v.IsUndefined() {
+		sim.Schedule = nil
+		return nil, nil
+	}
+	var sc *flow.Schedule
+	if v.Type() == js.TypeObject && !v.Get("BYTES_PER_ELEMENT").IsUndefined() {
+		if v.Length()%2 != 0 {
+			return nil, flow.Errorf(flow.ErrBadArguments, "schedule must hold t, U pairs, got %d values", v.Length())
+		}
+		table, err := floatsFromJS[float64]("schedule", v, v.Length()/2, 2)
+		if err != nil {
+			return nil, err
+		}
+		defer putBuffer(table)
+		sc = &flow.Schedule{Points: make([]flow.SchedulePoint, len(table)/2)}
+		for i := range sc.Points {
+			sc.Points[i] = flow.SchedulePoint{T: table[2*i], U: table[2*i+1]}
+		}
+		if err := sc.Validate(); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if sc, err = flow.DecodeSchedule(goValue(v)); err != nil {
+			return nil, err
+		}
+	}
+	sim.Schedule = flow.ScaledSchedule(sc, units.Factor(flow.DimVelocity))
+	return nil, nil
+}
+
+// setFrame(frame)
+//
+// Switches the reference frame of the stateful API: "body", the default,
+// keeps the object at rest in a uniform stream; "lab" keeps the far-field
+// fluid at rest and moves the object by minus the free stream velocity on
+// every step, so the object position reported by getState advances.
+// Particles stay where they are, and the velocities of the last step are
+// transformed, so switching mid-run doesn't make anything jump. Pressures
+// are the same in both frames.
+func setFrame(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFrame", args, 1); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeString {
+		return nil, flow.Errorf(flow.ErrBadArguments, "frame must be a string")
+	}
+	return nil, sim.SetFrame(args[0].String())
+}
+
+// setArithmetic(arithmetic)
+//
+// Selects the arithmetic of the velocity kernels: "accurate", the default,
+// evaluates them in float64, "fast" in float32, which costs measurably less
+// on mobile devices and stays within a few 1e-6 of the free stream of the
+// accurate velocities, plenty for visualization; computeArithmeticError
+// measures the difference for the current flow. The fast kernels cover the
+// analytic sphere, cylinder and airfoil and the bare free stream; other
+// flows, with strengths, elements, overlays or other objects, stay
+// accurate whatever the arithmetic. The config form is arithmetic, saved
+// in scenarios and snapshots. Returns {arithmetic, fast}, fast telling
+// whether the current flow is evaluated in float32.
+func setArithmetic(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setArithmetic", args, 1); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeString {
+		return nil, flow.Errorf(flow.ErrBadArguments, "arithmetic must be a string")
+	}
+	a := args[0].String()
+	if err := flow.CheckArithmetic(a); err != nil {
+		return nil, err
+	}
+	if a == "" {
+		a = flow.ArithmeticAccurate
+	}
+	c := sim.Config
+	c.Arithmetic = a
+	sim.SetConfig(c)
+	f := sim.Flow()
+	return map[string]interface{}{"arithmetic": a, "fast": f.Fast()}, nil
+}
+
+// setRotatingFrame(axis, omega[, options])
+//
+// Views the flow from a frame turning at omega, in rad/s, right-handed
+// about axis = [x, y, z] through options.center = [0, 0, 0], as for a
+// blade row turning with it: particles are advected with the velocity
+// relative to the frame, v - Ω×r, which the velocity buffer reports, or
+// the absolute velocity v with options.absolute = true. With
+// options.potential = true the pressures include ½ρ|Ω×r|², the potential
+// of the centrifugal force, so pressure coefficients follow the
+// rotating-frame Bernoulli equation. The particles are tracers, without
+// inertia, so the Coriolis and centrifugal accelerations don't act on
+// them. omega 0 leaves everything exactly as in the frame of setFrame,
+// and null for axis removes the rotating frame. It suspends the LOD of
+// setLOD while it turns. A runtime setting, not saved in scenarios; see
+// flow.RotatingFrame. Returns getState().rotatingFrame: {enabled, axis,
+// center, omega, absolute, potential}.
+func setRotatingFrame(args []js.Value) (interface{}, error) {
+	if len(args) >= 1 && (args[0].IsNull() || args[0].IsUndefined()) {
+		sim.Rotating = nil
+		return rotatingState(), nil
+	}
+	if err := checkArgs("setRotatingFrame", args, 2); err != nil {
+		return nil, err
+	}
+	var r flow.RotatingFrame
+	var err error
+	if r.Axis, err = vectorArg("axis", args[0]); err != nil {
+		return nil, err
+	}
+	if r.Omega, err = floatArg("omega", args[1]); err != nil {
+		return nil, err
+	}
+	if opts := optionalArg(args, 2); opts.Type() == js.TypeObject {
+		if v := opts.Get("center"); !v.IsUndefined() {
+			if r.Center, err = vectorArg("center", v); err != nil {
+				return nil, err
+			}
+			r.Center = vectorToSI(r.Center, flow.DimLength)
+		}
+		r.Absolute, r.Potential = opts.Get("absolute").Truthy(), opts.Get("potential").Truthy()
+	}
+	if err := sim.SetRotatingFrame(&r); err != nil {
+		return nil, err
+	}
+	return rotatingState(), nil
+}
+
+// rotatingState returns getState().rotatingFrame
+func rotatingState() js.Value {
+	st := units.Convert(map[string]interface{}{"rotatingFrame": sim.State()["rotatingFrame"]}, flow.StateDims, false)
+	return js.ValueOf(st.(map[string]interface{})["rotatingFrame"])
+}
+
+// setBoundary(boundary)
+//
+// Bounds the fluid from above by the plane z = height: boundary is {mode:
+// "wall" | "freeSurface" | "none", height = 0, clamp = false}. The sphere
+// below it is imaged in the plane, with the same sign for a rigid wall,
+// which the flow then runs along, and the opposite sign for a free
+// surface at high Froude number, on which the disturbance potential
+// vanishes instead, so setting the two modes side by side shows the flow
+// pulled up towards a wall and pushed away from a free surface. Above the
+// plane the velocity is zero, so particles crossing it stop there, or,
+// with clamp, are put back on the plane and slide along it. It needs a
+// sphere below the plane, or no object, and a free stream and object
+// motion parallel to the plane, and turns the far-field cutoff off;
+// generateShader doesn't support it. null removes the boundary. The
+// config form is boundaries.
+func setBoundary(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setBoundary", args, 1); err != nil {
+		return nil, err
+	}
+	b := flow.Boundary{Mode: flow.BoundaryNone}
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if b, err = flow.DecodeBoundary(goValueSI(v, flow.BoundaryDims)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetBoundary(b)
+}
+
+// setChannel(channel)
+//
+// Puts the flow through a converging-diverging channel along x, a
+// Venturi: channel is {inletHeight, throatHeight, start = 0, inletLength
+// = 0, convergeLength = 0, throatLength = 0, divergeLength = 0}. The
+// walls, symmetric about y = 0, close in from inletHeight to
+// throatHeight and open out again, and the flow, the free stream at the
+// inlet, speeds up through the throat by continuity, so its pressure
+// drops there. Beyond the walls the velocity is zero, and particles
+// leaving the channel are reflected back in. It needs a free stream
+// along +x and the object, if any, between the walls, and has no
+// profile, swirl, elements, boundary or object motion; generateShader
+// doesn't support it. getChannelWalls returns the wall outline. null
+// removes the channel.
+func setChannel(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setChannel", args, 1); err != nil {
+		return nil, err
+	}
+	var c flow.Channel
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if c, err = flow.DecodeChannel(goValueSI(v, flow.ChannelDims)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetChannel(c)
+}
+
+// setBump(bump)
+//
+// Bounds the fluid from below by a wall along y = 0 with a Gaussian bump
+// on it: bump is {height = 0, width, x = 0, panels = 0}, the wall rising
+// to height at x over a standard deviation of width. The flow, solved with
+// source panels along the bump, speeds up over the crest, where the
+// pressure is lowest, as over the upper surface of a wing; height 0 leaves
+// the plain wall. Below the wall the velocity is zero, and particles
+// crossing it are reflected back up. It needs a free stream along +x and
+// no object, so set the object's radius to 0 first; generateShader
+// doesn't support it. getBumpSurface returns the wall outline. null
+// removes the wall.
+func setBump(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setBump", args, 1); err != nil {
+		return nil, err
+	}
+	var b flow.Bump
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if b, err = flow.DecodeBump(goValueSI(v, flow.BumpDims)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetBump(b)
+}
+
+// setRotor(rotor)
+//
+// Hovers a rotor in fluid at rest: rotor is {radius, inducedVelocity |
+// thrust, position = [0, 0, 0], ground = 0}, an actuator disk in the
+// horizontal plane through position blowing its downwash down along -z.
+// Either the induced velocity vi at the disk or the thrust, in newtons
+// whatever the units, sets its strength. Particles are drawn down through
+// the disk and speed up to 2·vi as the wake contracts to radius/√2. With
+// ground, the height of the disk above a floor, the wake ends on the floor
+// and spreads out along it; particles crossing the floor are reflected
+// back up. It needs a free stream of speed 0 and no object, so set both
+// first; generateShader doesn't support it. null removes the rotor.
+func setRotor(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setRotor", args, 1); err != nil {
+		return nil, err
+	}
+	var r flow.Rotor
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if r, err = flow.DecodeRotor(goValueSI(v, flow.RotorDims)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetRotor(r)
+}
+
+// setCollision(collision)
+//
+// Bounces the particles off the object: collision is {enabled = true,
+// restitution = 0, friction = 0}, both coefficients between 0 and 1. A
+// particle whose step meets the surface stops on it, and the rest of the
+// step goes on with its normal part reversed and scaled by restitution
+// and its tangential part scaled by 1 - friction: restitution 0 and
+// friction 0 slide the particles along the surface, restitution 1 bounces
+// them like light off a mirror. The surface is found along the whole
+// step, so fast particles don't tunnel through a plate. Scenarios and
+// snapshots keep it. null restores the default, particles stopping inside
+// solid objects and held back by plates.
+func setCollision(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setCollision", args, 1); err != nil {
+		return nil, err
+	}
+	var c flow.Collision
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if c, err = flow.DecodeCollision(goValue(v)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, sim.SetCollision(c)
+}
+
+// setObjectPosition(x, y, z) moves the object, dropping the target of
+// setObjectTarget
+func setObjectPosition(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setObjectPosition", args, 3); err != nil {
+		return nil, err
+	}
+	var p [3]float64
+	for i, name := range [...]string{"x", "y", "z"} {
+		var err error
+		if p[i], err = dimArg(name, flow.DimLength, args[i]); err != nil {
+			return nil, err
+		}
+	}
+	sim.Target = nil
+	sim.SetObjectPosition(p[0], p[1], p[2])
+	return nil, nil
+}
+
+// setObjectMotion(objectId, motion)
+//
+// Makes the object follow a prescribed path, advanced by every step:
+// motion is {type, params} with type "linear" (params {velocity,
+// acceleration}), "circular" ({center, axis = [0, 0, 1], period}) or
+// "waypoints" ({points, duration, loop}), see flow.Motion. The path starts
+// where the object is, and the kernels see the flow relative to the moving
+// body, so particles it sweeps through are pushed aside or, once inside,
+// carried along. getState().objects[0] reports the velocity and, in
+// motion, the time and waypoint progress. pause freezes the motion with
+// the clock and reset rewinds it; scenarios save it. null stops the
+// object. objectId is 0, the only object.
+func setObjectMotion(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setObjectMotion", args, 2); err != nil {
+		return nil, err
+	}
+	id, err := intArg("objectId", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if id != 0 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "no object with id %d", id)
+	}
+	if args[1].IsNull() || args[1].IsUndefined() {
+		return nil, sim.SetMotion(nil)
+	}
+	o := sim.Config.Object
+	m, err := flow.DecodeMotion(goValueSI(args[1], flow.MotionDims), [3]float64{o.X, o.Y, o.Z}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return nil, sim.SetMotion(m)
+}
+
+// setObjectTarget(objectId, position[, options])
+//
+// Drags the object toward position = [x, y, z], as when the user drags it
+// with the pointer: every step it covers 1 - e^(-dt/timeConstant) of the
+// way, at most maxSpeed·dt, with options {timeConstant = 0.1, maxSpeed =
+// 0}; timeConstant 0 leaves the pace to maxSpeed, maxSpeed 0 leaves the
+// speed unbounded (see flow.ObjectTarget). Call it again as the pointer
+// moves. The kernels see the velocity of the approach, and particles the
+// body overruns during a step are pushed aside onto its surface rather
+// than left inside it. Setting a target stops a motion; setObjectMotion,
+// setObjectPosition and reset drop the target, as does null, which leaves
+// the object where it is. getState().objects[0] reports the target, as
+// {position, timeConstant, maxSpeed} or null, beside the actual position.
+// Targets are runtime settings, not saved in scenarios. objectId is 0, the
+// only object; position and maxSpeed are in the units of setUnits.
+func setObjectTarget(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setObjectTarget", args, 2); err != nil {
+		return nil, err
+	}
+	if err := objectArg("setObjectTarget", args[0]); err != nil {
+		return nil, err
+	}
+	if args[1].IsNull() || args[1].IsUndefined() {
+		return nil, sim.SetObjectTarget(nil)
+	}
+	t := flow.ObjectTarget{TimeConstant: flow.DefaultTargetTimeConstant}
+	var err error
+	if t.Position, err = vectorArg("position", args[1]); err != nil {
+		return nil, err
+	}
+	t.Position = vectorToSI(t.Position, flow.DimLength)
+	if opts := optionalArg(args, 2); opts.Type() == js.TypeObject {
+		for _, o := range []struct {
+			key string
+			d   flow.Dim
+			dst *float64
+		}{{"timeConstant", flow.DimNone, &t.TimeConstant}, {"maxSpeed", flow.DimVelocity, &t.MaxSpeed}} {
+			if v := opts.Get(o.key); !v.IsUndefined() {
+				if *o.dst, err = dimArg(o.key, o.d, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nil, sim.SetObjectTarget(&t)
+}
+
+// setFarFieldCutoff(cutoff)
+//
+// Sets the far-field cutoff of step, in object radii (0 turns it off; see
+// flow.Flow.Cutoff), or, given {tolerance}, the cutoff beyond which the
+// neglected disturbance is estimated to stay below tolerance times the free
+// stream for the configured object type. Returns {cutoff, maxError,
+// rmsError}: the cutoff set and the relative velocity error measured just
+// beyond it.
+func setFarFieldCutoff(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setFarFieldCutoff", args, 1); err != nil {
+		return nil, err
+	}
+	var cutoff float64
+	var err error
+	if v := args[0]; v.Type() == js.TypeObject {
+		tolerance, err := floatArg("tolerance", v.Get("tolerance"))
+		if err != nil {
+			return nil, err
+		}
+		if cutoff, err = flow.CutoffFor(sim.Config.Object.Type, tolerance); err != nil {
+			return nil, err
+		}
+	} else if cutoff, err = floatArg("cutoff", v); err != nil {
+		return nil, err
+	}
+
+	f := sim.Flow()
+	f.Cutoff = cutoff
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	var res flow.Residual
+	if f.FreeStream != 0 {
+		if res, err = f.CutoffError(4096); err != nil {
+			return nil, err
+		}
+	}
+	sim.Cutoff = cutoff
+	return map[string]interface{}{"cutoff": cutoff, "maxError": res.Max, "rmsError": res.RMS}, nil
+}
+
+// setLOD(options)
+//
+// Turns on the temporal level of detail of step (see flow.LOD), or turns
+// it off if options is null or false. Options, in radii and steps:
+//   - near = 3, far = 8: the bucket boundaries
+//   - midEvery = 2, farEvery = 4: how often mid-range and far particles
+//     are re-evaluated
+//   - rebucket = 0.25: the object displacement forcing a full refresh
+//   - objectStep = [0.02, 0, 0]: the object displacement per step assumed
+//     by the error estimate
+//
+// Returns getState().lod together with {maxError, rmsError}, the relative
+// velocity staleness flow.Flow.LODError measures over 100 steps of an
+// object moving by objectStep, or zeros when turned off.
+func setLOD(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setLOD", args, 1); err != nil {
+		return nil, err
+	}
+	opts := args[0]
+	if !opts.Truthy() {
+		sim.LOD = nil
+		return lodResult(flow.Residual{}), nil
+	}
+	if opts.Type() != js.TypeObject {
+		return nil, flow.Errorf(flow.ErrBadArguments, "setLOD: options must be an object, null or false")
+	}
+	l := flow.DefaultLOD
+	for _, e := range []struct {
+		key string
+		dst *float64
+	}{{"near", &l.Near}, {"far", &l.Far}, {"rebucket", &l.Rebucket}} {
+		if v := opts.Get(e.key); !v.IsUndefined() {
+			var err error
+			if *e.dst, err = floatArg(e.key, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, e := range []struct {
+		key string
+		dst *int
+	}{{"midEvery", &l.MidEvery}, {"farEvery", &l.FarEvery}} {
+		if v := opts.Get(e.key); !v.IsUndefined() {
+			var err error
+			if *e.dst, err = intArg(e.key, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+	step := [3]float64{0.02, 0, 0}
+	if v := opts.Get("objectStep"); !v.IsUndefined() {
+		if !js.Global().Get("Array").Call("isArray", v).Bool() || v.Length() != 3 {
+			return nil, flow.Errorf(flow.ErrBadArguments, "objectStep must be an array of 3 numbers")
+		}
+		for i := range step {
+			var err error
+			if step[i], err = floatArg("objectStep", v.Index(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f := sim.Flow()
+	f.Cutoff = sim.Cutoff
+	var res flow.Residual
+	if f.FreeStream != 0 {
+		r := f.Object.Radius
+		var err error
+		if res, err = f.LODError(l, [3]float64{step[0] * r, step[1] * r, step[2] * r}, 100, 4096); err != nil {
+			return nil, err
+		}
+	}
+	sim.LOD = &l
+	return lodResult(res), nil
+}
+
+// lodResult is the result of setLOD
+func lodResult(res flow.Residual) interface{} {
+	st := sim.State()["lod"].(map[string]interface{})
+	st["maxError"], st["rmsError"] = res.Max, res.RMS
+	return js.ValueOf(st)
+}
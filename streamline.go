@@ -0,0 +1,246 @@
+//go:build js && wasm
+// +build js,wasm
+
+// streamline.go - Streamline tracing
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// streamlines holds the lines of the last traceStreamlines call, which the
+// geometry exports write out
+var streamlines []flow.Polyline
+
+// traceStreamlines(seeds[, options])
+//
+// Traces one streamline downstream from each x,y,z triple of seeds through
+// the flow of the stateful API. Options:
+//   - step: arc length of each RK4 step (default 0.05 m)
+//   - maxSteps: steps per line (default 1000)
+//   - ribbon: also extrude each line into a ribbon (see flow.Ribbon), true
+//     or {width = 4 steps, orientation = "up", up = [0, 0, 1], twist =
+//     false}: the width across the flow and up, or with orientation
+//     "curvature" the curvature normal, twist turning it with the fluid
+//     about the line in a swirling free stream
+//
+// Returns {points, offsets}: all lines concatenated in a Float32Array, and
+// a Uint32Array of n+1 point indices where line i spans
+// [offsets[i], offsets[i+1]). With ribbon it also holds ribbon =
+// {positions, normals, indices, offsets, indexOffsets}: the vertices of
+// all ribbons concatenated, two per point in triangle strip order, their
+// unit normals, the triangles as a Uint32Array of indices into them, and
+// n+1 vertex and index offsets delimiting ribbon i as for the lines; a
+// line of a single point has an empty ribbon. Seeds, step, width and
+// points are in the length unit of setUnits.
+func traceStreamlines(args []js.Value) (interface{}, error) {
+	if err := checkArgs("traceStreamlines", args, 1); err != nil {
+		return nil, err
+	}
+	if args[0].Type() != js.TypeObject || args[0].Get("length").Type() != js.TypeNumber {
+		return nil, flow.Errorf(flow.ErrBadArguments, "seeds must be an array or typed array")
+	}
+	n := args[0].Length() / 3
+	seeds, err := floatsFromJS[float64]("seeds", args[0], n, 3)
+	if err != nil {
+		return nil, err
+	}
+	defer putBuffer(seeds)
+	toSI(seeds, flow.DimLength)
+	h, maxSteps := 0.05, 1000
+	var ribbon *flow.RibbonSpec
+	opts := optionalArg(args, 1)
+	if opts.Type() == js.TypeObject {
+		if v := opts.Get("step"); !v.IsUndefined() {
+			if h, err = dimArg("step", flow.DimLength, v); err != nil {
+				return nil, err
+			}
+		}
+		if v := opts.Get("maxSteps"); !v.IsUndefined() {
+			if maxSteps, err = intArg("maxSteps", v); err != nil {
+				return nil, err
+			}
+		}
+		if v := opts.Get("ribbon"); v.Truthy() {
+			if ribbon, err = ribbonArg(v, 4*h); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	f := sim.Flow()
+	lines := make([]flow.Polyline, n)
+	for i := range lines {
+		if lines[i], err = f.Streamline([3]float64{seeds[i*3], seeds[i*3+1], seeds[i*3+2]}, h, maxSteps); err != nil {
+			return nil, err
+		}
+	}
+	streamlines = lines
+	result := polylinesToJS(lines)
+	if ribbon != nil {
+		var all flow.Mesh
+		offsets, indexOffsets := make([]uint32, n+1), make([]uint32, n+1)
+		for i, l := range lines {
+			m, err := f.Ribbon(l, *ribbon)
+			if err != nil {
+				return nil, err
+			}
+			for _, j := range m.Indices {
+				all.Indices = append(all.Indices, offsets[i]+j)
+			}
+			all.Positions = append(all.Positions, m.Positions...)
+			all.Normals = append(all.Normals, m.Normals...)
+			offsets[i+1], indexOffsets[i+1] = uint32(len(all.Positions)/3), uint32(len(all.Indices))
+		}
+		result["ribbon"] = map[string]interface{}{
+			"positions":    floatsToJSIn(all.Positions, flow.DimLength),
+			"normals":      floatsToJS(all.Normals),
+			"indices":      uint32sToJS(all.Indices),
+			"offsets":      uint32sToJS(offsets),
+			"indexOffsets": uint32sToJS(indexOffsets),
+		}
+	}
+	return result, nil
+}
+
+// ribbonArg reads the ribbon option of traceStreamlines, true or an
+// object, its width defaulting to width
+func ribbonArg(v js.Value, width float64) (*flow.RibbonSpec, error) {
+	r := flow.DefaultRibbonSpec
+	r.Width = width
+	if v.Type() == js.TypeObject {
+		var err error
+		if w := v.Get("width"); !w.IsUndefined() {
+			if r.Width, err = dimArg("width", flow.DimLength, w); err != nil {
+				return nil, err
+			}
+		}
+		if o := v.Get("orientation"); !o.IsUndefined() {
+			if o.Type() != js.TypeString {
+				return nil, flow.Errorf(flow.ErrBadArguments, "ribbon orientation must be a string")
+			}
+			r.Orientation = o.String()
+		}
+		if u := v.Get("up"); !u.IsUndefined() {
+			if r.Up, err = vectorArg("up", u); err != nil {
+				return nil, err
+			}
+		}
+		r.Twist = v.Get("twist").Truthy()
+	}
+	return &r, r.Validate()
+}
+
+// computeSurfaceStreamlines(objectId, nSeeds, maxSteps[, options])
+//
+// Traces the limiting streamlines of the flow of the stateful API on the
+// surface of its sphere or cylinder, the pattern of an oil-flow
+// visualization, through nSeeds seeds spread over the surface, up to
+// maxSteps steps upstream and downstream of each. Options:
+//   - step: arc length of each RK4 step (default 0.02 radii)
+//
+// The lines are projected onto the surface at every stage and end within
+// a step of the stagnation points; on a sphere they run from the front
+// stagnation point to the rear one, spiralling in a swirling free stream.
+// Returns {points, offsets} as traceStreamlines does, the lines in the
+// direction of the flow; the geometry exports keep the lines of
+// traceStreamlines. objectId is 0, the only object. Step and points are in
+// the length unit of setUnits; see flow.SurfaceStreamlines.
+func computeSurfaceStreamlines(args []js.Value) (interface{}, error) {
+	if err := checkArgs("computeSurfaceStreamlines", args, 3); err != nil {
+		return nil, err
+	}
+	if err := objectArg("computeSurfaceStreamlines", args[0]); err != nil {
+		return nil, err
+	}
+	n, err := intArg("nSeeds", args[1])
+	if err != nil {
+		return nil, err
+	}
+	maxSteps, err := intArg("maxSteps", args[2])
+	if err != nil {
+		return nil, err
+	}
+	f := sim.Flow()
+	h := flow.DefaultSurfaceStep * f.Object.Radius
+	if opts := optionalArg(args, 3); opts.Type() == js.TypeObject {
+		if v := opts.Get("step"); !v.IsUndefined() {
+			if h, err = dimArg("step", flow.DimLength, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	lines, err := f.SurfaceStreamlines(n, maxSteps, h)
+	if err != nil {
+		return nil, err
+	}
+	return polylinesToJS(lines), nil
+}
+
+// getDividingStreamline(objectId, length, stepSize)
+//
+// Locates the front stagnation point of the flow of the stateful API,
+// where the flow stops on the free stream line through the object, and
+// traces the dividing streamline ending there back over length upstream,
+// in steps of stepSize that shrink to half the distance to the stagnation
+// point near it, so the slowing flow doesn't stall the line. Without an
+// object, the flow of elements such as a source in a uniform stream, the
+// line runs through the mean position of the elements, the stagnation
+// point is refined off it, and the dividing streamsurface is traced too:
+// 16 streamlines leaving the stagnation point around the incoming line,
+// over length downstream, the outline of the body the flow makes, a
+// Rankine half-body for the source. Returns {points, offsets, stagnation,
+// residual}: the lines as for traceStreamlines, the dividing streamline
+// first, in the direction of the flow and ending at the stagnation point,
+// then those of the surface; the stagnation point [x, y, z], and the speed
+// there. Fails where the flow moves on the body where the line meets it,
+// as about a cylinder with circulation, or doesn't stop along it, as
+// through a torus. objectId is 0, the only object. Lengths are in the
+// length unit of setUnits, the residual in its velocity unit; see
+// flow.Flow.DividingStreamline.
+func getDividingStreamline(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getDividingStreamline", args, 3); err != nil {
+		return nil, err
+	}
+	if err := objectArg("getDividingStreamline", args[0]); err != nil {
+		return nil, err
+	}
+	length, err := dimArg("length", flow.DimLength, args[1])
+	if err != nil {
+		return nil, err
+	}
+	h, err := dimArg("stepSize", flow.DimLength, args[2])
+	if err != nil {
+		return nil, err
+	}
+	f := sim.Flow()
+	d, err := f.DividingStreamline(length, h)
+	if err != nil {
+		return nil, err
+	}
+	result := polylinesToJS(append([]flow.Polyline{d.Upstream}, d.Surface...))
+	p := d.Stagnation
+	result["stagnation"] = []interface{}{units.FromSI(flow.DimLength, p[0]), units.FromSI(flow.DimLength, p[1]), units.FromSI(flow.DimLength, p[2])}
+	result["residual"] = units.FromSI(flow.DimVelocity, d.Residual)
+	return result, nil
+}
+
+// polylinesToJS returns lines as {points, offsets}: their points
+// concatenated in a Float32Array, in the length unit of setUnits, and a
+// Uint32Array of len(lines)+1 point indices where line i spans
+// [offsets[i], offsets[i+1])
+func polylinesToJS(lines []flow.Polyline) map[string]interface{} {
+	var points []float32
+	offsets := make([]uint32, len(lines)+1)
+	for i, l := range lines {
+		points = append(points, l...)
+		offsets[i+1] = uint32(len(points) / 3)
+	}
+	jsOffsets := js.Global().Get("Uint32Array").New(len(offsets))
+	for i, o := range offsets {
+		jsOffsets.SetIndex(i, o)
+	}
+	return map[string]interface{}{"points": floatsToJSIn(points, flow.DimLength), "offsets": jsOffsets}
+}
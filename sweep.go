@@ -0,0 +1,85 @@
+//go:build js && wasm
+// +build js,wasm
+
+// sweep.go - Angle of attack sweeps
+package main
+
+import (
+	"math"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// runAlphaSweep(objectId, alphaStart, alphaEnd, nSteps)
+//
+// Solves the simulation's lifting section at nSteps + 1 angles of attack
+// evenly spaced from alphaStart to alphaEnd, in degrees, and returns its
+// lift curve without changing the flow or touching the particles, so a
+// chart can follow while the view stays at the current angle: the thin
+// airfoil of solveThinAirfoil or the tandem of solveTandem while their
+// flow is the simulation's, pitched as a rigid configuration by its first
+// section's angle, else a plate or an elliptic cylinder at each value of
+// its alpha. The tandem's influence matrix is eliminated once for every
+// angle. Returns {alpha, cl, cm, residual, sectionCl}: parallel
+// Float64Arrays of the angles in degrees, the lift coefficient, the
+// quarter-chord moment coefficient, nose up positive, and the residual of
+// the solve relative to the onset speed, to flag bad points, 0 for the
+// closed-form solutions, and for a tandem one Float64Array of lift
+// coefficients per section, else null; see flow.LiftCurve.
+func runAlphaSweep(args []js.Value) (interface{}, error) {
+	if err := checkArgs("runAlphaSweep", args, 4); err != nil {
+		return nil, err
+	}
+	if err := objectArg("runAlphaSweep", args[0]); err != nil {
+		return nil, err
+	}
+	start, err := floatArg("alphaStart", args[1])
+	if err != nil {
+		return nil, err
+	}
+	end, err := floatArg("alphaEnd", args[2])
+	if err != nil {
+		return nil, err
+	}
+	steps, err := intArg("nSteps", args[3])
+	if err != nil {
+		return nil, err
+	}
+	alphas, err := flow.SweepAngles(start*math.Pi/180, end*math.Pi/180, steps)
+	if err != nil {
+		return nil, err
+	}
+	f := sim.Flow()
+	var c flow.LiftCurve
+	switch airfoil, tandem := builtSections(); {
+	case airfoil != nil:
+		c = airfoil.LiftCurve(alphas)
+	case tandem != nil:
+		c, err = tandem.LiftCurve(&f, alphas)
+	default:
+		c, err = f.LiftCurve(alphas)
+	}
+	if err != nil {
+		return nil, err
+	}
+	degrees := make([]float64, len(c.Alpha))
+	for i, a := range c.Alpha {
+		degrees[i] = a * 180 / math.Pi
+	}
+	var sectionCL interface{}
+	if c.SectionCL != nil {
+		list := make([]interface{}, len(c.SectionCL))
+		for i, cl := range c.SectionCL {
+			list[i] = floatsToJS(cl)
+		}
+		sectionCL = list
+	}
+	return map[string]interface{}{
+		"alpha":     floatsToJS(degrees),
+		"cl":        floatsToJS(c.CL),
+		"cm":        floatsToJS(c.CM),
+		"residual":  floatsToJS(c.Residual),
+		"sectionCl": sectionCL,
+	}, nil
+}
@@ -0,0 +1,79 @@
+//go:build js && wasm
+// +build js,wasm
+
+// symmetry.go - Symmetry plane
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// symmetryState returns getState().symmetry
+func symmetryState() js.Value {
+	st := units.Convert(map[string]interface{}{"symmetry": sim.State()["symmetry"]}, flow.StateDims, false)
+	return js.ValueOf(st.(map[string]interface{})["symmetry"])
+}
+
+// setSymmetry(plane)
+//
+// Exploits a plane the flow is mirror symmetric about, as y = 0 past a
+// sphere or a cylinder without circulation, to evaluate about half of the
+// particles, or evaluates them all again if plane is null or false. plane
+// is {normal = [0, 1, 0], offset = 0, band = 1 mm}, the plane of the
+// points p with normal·p = offset: the particles beyond band of it on the
+// side normal points to are paired with those on the other side, each
+// moved onto the mirror image of its partner, and step evaluates one of
+// each pair and the particles left over, those within band of the plane
+// among them, filling in the others by reflection, the normal velocity
+// component negated. The results match evaluating every particle to float
+// precision.
+//
+// It refuses a flow that isn't symmetric about the plane, with a
+// circulation, an object or free stream off the plane, a profile across
+// it, turbulence or a turning frame among others; a later change that
+// breaks the symmetry suspends it, every particle evaluated, until the
+// symmetry is back. Particles replaced by seeding are paired again, those
+// moved by density control or injection leave their pairs. It suspends
+// the LOD of setLOD, and updatePartial evaluates every particle it
+// refreshes. A runtime setting, not saved in scenarios; see flow.Symmetry.
+// Returns getState().symmetry: {enabled, normal, offset, band, used,
+// pairs, suspended}, suspended being why the last step evaluated every
+// particle, or null. offset and band are in the length unit of setUnits.
+func setSymmetry(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setSymmetry", args, 1); err != nil {
+		return nil, err
+	}
+	plane := args[0]
+	if !plane.Truthy() {
+		if err := sim.SetSymmetry(nil); err != nil {
+			return nil, err
+		}
+		return symmetryState(), nil
+	}
+	if plane.Type() != js.TypeObject {
+		return nil, flow.Errorf(flow.ErrBadArguments, "setSymmetry: plane must be an object, null or false")
+	}
+	m := flow.Symmetry{Band: flow.DefaultSymmetryBand}
+	var err error
+	if v := plane.Get("normal"); !v.IsUndefined() {
+		if m.Normal, err = vectorArg("normal", v); err != nil {
+			return nil, err
+		}
+	}
+	if v := plane.Get("offset"); !v.IsUndefined() {
+		if m.Offset, err = dimArg("offset", flow.DimLength, v); err != nil {
+			return nil, err
+		}
+	}
+	if v := plane.Get("band"); !v.IsUndefined() {
+		if m.Band, err = dimArg("band", flow.DimLength, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := sim.SetSymmetry(&m); err != nil {
+		return nil, err
+	}
+	return symmetryState(), nil
+}
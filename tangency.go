@@ -0,0 +1,91 @@
+//go:build js && wasm
+// +build js,wasm
+
+// tangency.go - Quality of the body models
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// computeTangencyResidual(objectId, nSamples)
+//
+// Measures how well the flow of the stateful API keeps off its object:
+// samples nSamples points, up to 1048576, on the object's surface, spread
+// as for the surface streamlines' seeds, and evaluates the velocity
+// through the surface relative to the object, |v·n|/U∞, from the same
+// kernel step advects the particles with. It vanishes to roundoff for the
+// analytic sphere and cylinder; the fitted torus, the panels of a box,
+// elements standing in for the object and a sphere imaged in a wall show
+// the error of their approximation. Returns {max, rms, worst, samples}:
+// the largest and RMS residual, the point [x, y, z] of the largest, in the
+// length unit of setUnits, and the number of samples, 0 without an
+// object. Fails for a flow at rest; see flow.Flow.TangencyResidual.
+func computeTangencyResidual(args []js.Value) (interface{}, error) {
+	if err := checkArgs("computeTangencyResidual", args, 2); err != nil {
+		return nil, err
+	}
+	if err := objectArg("computeTangencyResidual", args[0]); err != nil {
+		return nil, err
+	}
+	n, err := intArg("nSamples", args[1])
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "nSamples must be positive, got %d", n)
+	}
+	f := sim.Flow()
+	f.Cutoff = sim.Cutoff
+	res, err := f.TangencyResidual(n)
+	if err != nil {
+		return nil, err
+	}
+	w := res.Worst
+	return map[string]interface{}{
+		"max":     res.Max,
+		"rms":     res.RMS,
+		"worst":   []interface{}{units.FromSI(flow.DimLength, w[0]), units.FromSI(flow.DimLength, w[1]), units.FromSI(flow.DimLength, w[2])},
+		"samples": res.Samples,
+	}, nil
+}
+
+// computeArithmeticError(nSamples)
+//
+// Measures what the fast arithmetic of setArithmetic costs in accuracy for
+// the flow of the stateful API, whichever arithmetic is selected: samples
+// nSamples points, up to 1048576, on shells round the object from just
+// outside its surface to ten radii away, and evaluates |v_fast -
+// v_accurate|/U∞ between the float32 and float64 kernels. Returns {max,
+// rms, worst, samples, fast} as computeTangencyResidual, fast telling
+// whether the fast kernels cover the flow at all; if not, the error is 0.
+// Fails for a flow at rest; see flow.Flow.ArithmeticError.
+func computeArithmeticError(args []js.Value) (interface{}, error) {
+	if err := checkArgs("computeArithmeticError", args, 1); err != nil {
+		return nil, err
+	}
+	n, err := intArg("nSamples", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if n < 1 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "nSamples must be positive, got %d", n)
+	}
+	f := sim.Flow()
+	f.Cutoff = sim.Cutoff
+	res, err := f.ArithmeticError(n)
+	if err != nil {
+		return nil, err
+	}
+	f.Arithmetic = flow.ArithmeticFast
+	w := res.Worst
+	return map[string]interface{}{
+		"max":     res.Max,
+		"rms":     res.RMS,
+		"worst":   []interface{}{units.FromSI(flow.DimLength, w[0]), units.FromSI(flow.DimLength, w[1]), units.FromSI(flow.DimLength, w[2])},
+		"samples": res.Samples,
+		"fast":    f.Fast(),
+	}, nil
+}
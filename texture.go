@@ -0,0 +1,188 @@
+//go:build js && wasm
+// +build js,wasm
+
+// texture.go - Field slices as WebGL texture data and PNG images
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// getSliceTexture(plane, offset, width, height, field, rangeMin, rangeMax, colormap[, options])
+//
+// Samples field ("speed", "pressure", "cp", "vx", "vy", "vz", or the
+// vortex criteria "q" and "lambda2", see getVortexCriterion) of the
+// stateful API's flow on the plane "xy", "xz" or "yz" at offset along the
+// normal axis, at width×height texel centers, ready for gl.texImage2D.
+// Options:
+//   - format: "rgba8" (default) maps [rangeMin, rangeMax] through colormap
+//     ("viridis", "coolwarm" or "grayscale") into a Uint8Array; "float32"
+//     writes raw values in R of an RGBA32F Float32Array and ignores the
+//     range and colormap
+//   - min, max: [u, v] bounds of the slice in the plane's axes, by default
+//     4 radii (or 4 units) around the object
+//   - target: an existing array of the right type and width*height*4
+//     length to fill instead of allocating one
+//
+// Texels inside the body get alpha 0. Row 0 lies at min[1].
+//
+// offset, the bounds, the range and the float32 values are in the units of
+// setUnits.
+func getSliceTexture(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getSliceTexture", args, 8); err != nil {
+		return nil, err
+	}
+	s := flow.Slice{Plane: args[0].String()}
+	var err error
+	if s.Offset, err = dimArg("offset", flow.DimLength, args[1]); err != nil {
+		return nil, err
+	}
+	if s.Width, err = intArg("width", args[2]); err != nil {
+		return nil, err
+	}
+	if s.Height, err = intArg("height", args[3]); err != nil {
+		return nil, err
+	}
+	field := args[4].String()
+	lo, err := dimArg("rangeMin", flow.FieldDim(field), args[5])
+	if err != nil {
+		return nil, err
+	}
+	hi, err := dimArg("rangeMax", flow.FieldDim(field), args[6])
+	if err != nil {
+		return nil, err
+	}
+	colormap := args[7].String()
+
+	f := sim.Flow()
+	s.Min, s.Max = f.Object.SliceExtent(s.Plane)
+
+	format, target := "rgba8", js.Undefined()
+	if opts := optionalArg(args, 8); opts.Type() == js.TypeObject {
+		if v := opts.Get("format"); !v.IsUndefined() {
+			format = v.String()
+		}
+		if err := sliceBounds(opts, &s); err != nil {
+			return nil, err
+		}
+		target = opts.Get("target")
+	}
+
+	switch format {
+	case "float32":
+		data, err := flow.SliceTextureFloat(f, s, field)
+		if err != nil {
+			return nil, err
+		}
+		if scale := 1 / units.Factor(flow.FieldDim(field)); scale != 1 {
+			for i := 0; i < len(data); i += 4 {
+				data[i] *= float32(scale)
+			}
+		}
+		if target.IsUndefined() {
+			return floatsToJS(data), nil
+		}
+		if err := float32View("target", target, len(data)); err != nil {
+			return nil, err
+		}
+		copyToJS(target, data)
+		return target, nil
+
+	case "rgba8":
+		data, err := flow.SliceTextureRGBA8(f, s, field, lo, hi, colormap)
+		if err != nil {
+			return nil, err
+		}
+		if target.IsUndefined() {
+			return bytesToJS(data), nil
+		}
+		if !target.InstanceOf(js.Global().Get("Uint8Array")) && !target.InstanceOf(js.Global().Get("Uint8ClampedArray")) {
+			return nil, flow.Errorf(flow.ErrBadArguments, "target must be a Uint8Array for rgba8 output")
+		}
+		if err := flow.CheckBuffer("target", target.Length(), len(data), 1); err != nil {
+			return nil, err
+		}
+		js.CopyBytesToJS(target, data)
+		return target, nil
+	}
+	return nil, flow.Errorf(flow.ErrBadArguments, `format must be "rgba8" or "float32"`)
+}
+
+// sliceBounds reads the optional [u, v] min and max bounds of a slice
+func sliceBounds(opts js.Value, s *flow.Slice) error {
+	for _, e := range []struct {
+		key string
+		dst *[2]float64
+	}{{"min", &s.Min}, {"max", &s.Max}} {
+		if v := opts.Get(e.key); !v.IsUndefined() {
+			if !js.Global().Get("Array").Call("isArray", v).Bool() || v.Length() != 2 {
+				return flow.Errorf(flow.ErrBadArguments, "%s must be an array of 2 numbers", e.key)
+			}
+			for a := 0; a < 2; a++ {
+				var err error
+				if e.dst[a], err = dimArg(e.key, flow.DimLength, v.Index(a)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// renderSliceImage(plane, offset, width, height, field, [rangeMin, rangeMax], colormap[, options])
+//
+// Renders the same slice as getSliceTexture as a PNG heatmap and returns
+// the encoded file as a Uint8Array: the width×height plot with the body
+// in gray, framed by its axis extents, and a colorbar. Options:
+//   - min, max: as for getSliceTexture
+//   - streamlines: true to overlay the lines of the last traceStreamlines
+//     call, projected onto the plane
+func renderSliceImage(args []js.Value) (interface{}, error) {
+	if err := checkArgs("renderSliceImage", args, 7); err != nil {
+		return nil, err
+	}
+	s := flow.Slice{Plane: args[0].String()}
+	var err error
+	if s.Offset, err = dimArg("offset", flow.DimLength, args[1]); err != nil {
+		return nil, err
+	}
+	if s.Width, err = intArg("width", args[2]); err != nil {
+		return nil, err
+	}
+	if s.Height, err = intArg("height", args[3]); err != nil {
+		return nil, err
+	}
+	field := args[4].String()
+	r := args[5]
+	if !js.Global().Get("Array").Call("isArray", r).Bool() || r.Length() != 2 {
+		return nil, flow.Errorf(flow.ErrBadArguments, "range must be an array of 2 numbers")
+	}
+	lo, err := dimArg("range", flow.FieldDim(field), r.Index(0))
+	if err != nil {
+		return nil, err
+	}
+	hi, err := dimArg("range", flow.FieldDim(field), r.Index(1))
+	if err != nil {
+		return nil, err
+	}
+	colormap := args[6].String()
+
+	f := sim.Flow()
+	s.Min, s.Max = f.Object.SliceExtent(s.Plane)
+	var lines []flow.Polyline
+	if opts := optionalArg(args, 7); opts.Type() == js.TypeObject {
+		if err := sliceBounds(opts, &s); err != nil {
+			return nil, err
+		}
+		if opts.Get("streamlines").Truthy() {
+			lines = streamlines
+		}
+	}
+	data, err := flow.RenderSliceImage(f, s, field, lo, hi, colormap, lines, units)
+	if err != nil {
+		return nil, err
+	}
+	return bytesToJS(data), nil
+}
@@ -0,0 +1,26 @@
+//go:build js && wasm
+// +build js,wasm
+
+// torus.go - Quality of the torus approximation
+package main
+
+import "syscall/js"
+
+// getTorusFit()
+//
+// Returns the fit behind the simulation's torus (object type "torus",
+// configured with radius, minorRadius and collocation): {ratio,
+// collocation, rings, residual: {max, rms}}, the minor over major radius,
+// the number of collocation points on the tube and of ring sources
+// inside it, and the normal velocity |v·n|/U left on the tube halfway
+// between the collocation points. Exact potential flow past a torus has
+// no closed form, so the residual tells how far the flow leaks through
+// the surface; raise collocation to reduce it. See flow.TorusFit.
+func getTorusFit(args []js.Value) (interface{}, error) {
+	f := sim.Flow()
+	t, err := f.TorusFit()
+	if err != nil {
+		return nil, err
+	}
+	return js.ValueOf(t.Encode()), nil
+}
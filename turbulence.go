@@ -0,0 +1,78 @@
+//go:build js && wasm
+// +build js,wasm
+
+// turbulence.go - Synthetic turbulence
+package main
+
+import (
+	"math"
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// turbulenceState returns getState().turbulence
+func turbulenceState() js.Value {
+	st := units.Convert(map[string]interface{}{"turbulence": sim.State()["turbulence"]}, flow.StateDims, false)
+	return js.ValueOf(st.(map[string]interface{})["turbulence"])
+}
+
+// setTurbulence(options)
+//
+// Adds synthetic turbulence to the flow the particles are advected with,
+// or removes it if options is null or false: a frozen, divergence-free
+// field of velocity fluctuations, the sum of random Fourier modes drawn
+// from the von Kármán spectrum, carried past the object by the free
+// stream (Taylor's hypothesis; see flow.Turbulence). Options:
+//   - intensity: the rms fluctuation along each axis over the free stream
+//     speed, e.g. 0.05 for 5%; 0 leaves the flow exactly as it is
+//   - scale: the integral length scale
+//   - modes = 256: the number of Fourier modes, up to 4096
+//   - seed = 0: the seed of the modes, the same seed drawing the same field
+//
+// The fluctuations move the particles outside the object; their
+// velocities and pressures stay those of the mean flow. Calling it again
+// restarts the field, as does reset. Turbulence is a runtime setting, not
+// saved in scenarios. Returns getState().turbulence: {enabled, intensity,
+// scale, modes, seed, offset}, offset being how far the field has been
+// carried. scale and offset are in the length unit of setUnits.
+func setTurbulence(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setTurbulence", args, 1); err != nil {
+		return nil, err
+	}
+	opts := args[0]
+	if !opts.Truthy() {
+		sim.Turbulence = nil
+		return turbulenceState(), nil
+	}
+	if opts.Type() != js.TypeObject {
+		return nil, flow.Errorf(flow.ErrBadArguments, "setTurbulence: options must be an object, null or false")
+	}
+	t := flow.Turbulence{Modes: flow.DefaultTurbulenceModes}
+	var err error
+	if t.Intensity, err = floatArg("intensity", opts.Get("intensity")); err != nil {
+		return nil, err
+	}
+	if t.Scale, err = dimArg("scale", flow.DimLength, opts.Get("scale")); err != nil {
+		return nil, err
+	}
+	if v := opts.Get("modes"); !v.IsUndefined() {
+		if t.Modes, err = intArg("modes", v); err != nil {
+			return nil, err
+		}
+	}
+	if v := opts.Get("seed"); !v.IsUndefined() {
+		seed, err := floatArg("seed", v)
+		if err != nil {
+			return nil, err
+		}
+		if !(seed >= 0) || seed != math.Trunc(seed) || seed > flow.MaxSeed {
+			return nil, flow.Errorf(flow.ErrBadArguments, "seed must be an integer between 0 and %d, got %g", uint64(flow.MaxSeed), seed)
+		}
+		t.Seed = uint64(seed)
+	}
+	if err := sim.SetTurbulence(&t); err != nil {
+		return nil, err
+	}
+	return turbulenceState(), nil
+}
@@ -0,0 +1,107 @@
+//go:build js && wasm
+// +build js,wasm
+
+// units.go - Unit system of the JavaScript API
+//
+// The kernels and the simulation work in SI units throughout. setUnits
+// records the units JavaScript works in, and every binding converts its
+// dimensional arguments to SI on the way in and its results back on the
+// way out with the helpers below. Times are always in seconds, so dt and
+// durations are never converted, and ratios such as Cp, radii multiples
+// and the isentropic ratios are unitless. Scenarios and snapshots are
+// stored in SI whatever the units, so they load the same anywhere.
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// units is the unit system of the values exchanged with JavaScript
+var units = flow.SIUnits()
+
+// setUnits(units)
+//
+// Sets the units of every dimensional argument and result of the API:
+// units is {length, velocity, density, pressure}, each key defaulting to
+// its SI unit, so {pressure: "hPa"} reports pressures in hPa and keeps
+// the rest in SI. The known units are
+//   - length: m, mm, cm, km, in, ft
+//   - velocity: m/s, mm/s, km/h, kn, mph, ft/s
+//   - density: kg/m3, g/cm3, g/L, lb/ft3
+//   - pressure: Pa, hPa, kPa, MPa, mbar, bar, atm, psi
+//
+// Rates such as the shear rate are in velocity units per length unit.
+// Object strengths (config object.strengths), whose dimensions depend on
+// the object type, are always SI.
+// null restores SI. The simulation state is kept in SI, so switching
+// units changes only how it is read and written. Returns the units with
+// their conversion factors to SI, as getState().units reports them.
+func setUnits(args []js.Value) (interface{}, error) {
+	if err := checkArgs("setUnits", args, 1); err != nil {
+		return nil, err
+	}
+	u := flow.SIUnits()
+	if v := args[0]; !v.IsNull() && !v.IsUndefined() {
+		var err error
+		if u, err = flow.DecodeUnits(goValue(v)); err != nil {
+			return nil, err
+		}
+	}
+	units = u
+	return js.ValueOf(units.Encode()), nil
+}
+
+// dimArg reads a numeric argument of dimension d, converted to SI
+func dimArg(name string, d flow.Dim, v js.Value) (float64, error) {
+	x, err := floatArg(name, v)
+	return units.ToSI(d, x), err
+}
+
+// toSI converts the values of buf, of dimension d, to SI in place
+func toSI[T flow.Float](buf []T, d flow.Dim) {
+	flow.Scale(buf, units.Factor(d))
+}
+
+// fromSI converts the values of buf, of dimension d, from SI in place
+func fromSI[T flow.Float](buf []T, d flow.Dim) {
+	flow.Scale(buf, 1/units.Factor(d))
+}
+
+// floatsToJSIn is floatsToJS for SI values of dimension d, converted on
+// the way out; data itself is left in SI
+func floatsToJSIn[T flow.Float](data []T, d flow.Dim) js.Value {
+	if units.Factor(d) == 1 {
+		return floatsToJS(data)
+	}
+	buf := getBuffer[T](len(data))
+	defer putBuffer(buf)
+	copy(buf, data)
+	fromSI(buf, d)
+	return floatsToJS(buf)
+}
+
+// copyToJSIn is copyToJS for SI values of dimension d, converted on the
+// way out; src itself is left in SI
+func copyToJSIn[T flow.Float](v js.Value, src []T, d flow.Dim) {
+	if units.Factor(d) == 1 {
+		copyToJS(v, src)
+		return
+	}
+	buf := getBuffer[T](len(src))
+	defer putBuffer(buf)
+	copy(buf, src)
+	fromSI(buf, d)
+	copyToJS(v, buf)
+}
+
+// goValueSI is goValue with the keys listed in dims converted to SI
+func goValueSI(v js.Value, dims map[string]flow.Dim, skip ...string) interface{} {
+	return units.Convert(goValue(v, skip...), dims, true)
+}
+
+// vectorToSI converts an [x, y, z] of dimension d to SI
+func vectorToSI(p [3]float64, d flow.Dim) [3]float64 {
+	return [3]float64{units.ToSI(d, p[0]), units.ToSI(d, p[1]), units.ToSI(d, p[2])}
+}
@@ -0,0 +1,132 @@
+//go:build js && wasm
+// +build js,wasm
+
+// vortex.go - Vortex criteria
+package main
+
+import (
+	"syscall/js"
+
+	"fluid_simulation/internal/flow"
+)
+
+// getVortexCriterion(field[, where])
+//
+// Evaluates the vortex criterion field, "q" or "lambda2", of the stateful
+// API's flow from its velocity gradient: Q = ½(|Ω|² - |S|²), and λ2, the
+// middle eigenvalue of S² + Ω², S and Ω the strain rate and rotation
+// tensors. where is a grid {min, max, resolution}, as for exportVTK, with
+// x varying fastest, or an array of x, y, z positions; without it the
+// simulation's particles are used. Returns a Float32Array of one value per
+// point. λ2 is negative inside vortex cores, such as those of vortex
+// elements with a coreRadius, and never in potential flow, where Ω is
+// zero. Positions and grid bounds are in the length unit of setUnits, the
+// values in its (velocity/length)²; see flow.VortexCriterion.
+func getVortexCriterion(args []js.Value) (interface{}, error) {
+	if err := checkArgs("getVortexCriterion", args, 1); err != nil {
+		return nil, err
+	}
+	field := args[0].String()
+	f := sim.Flow()
+	var values []float32
+	var err error
+	switch where := optionalArg(args, 1); {
+	case where.IsUndefined():
+		values, err = flow.VortexCriterion(sim.Positions, sim.Count(), f, field)
+	case where.Type() != js.TypeObject:
+		return nil, flow.Errorf(flow.ErrBadArguments, "getVortexCriterion: where must be a grid or an array of positions")
+	case where.Get("length").Type() == js.TypeNumber:
+		n := where.Length() / 3
+		var p []float64
+		if p, err = floatsFromJS[float64]("positions", where, n, 3); err != nil {
+			return nil, err
+		}
+		defer putBuffer(p)
+		toSI(p, flow.DimLength)
+		values, err = flow.VortexCriterion(p, n, f, field)
+	default:
+		var g flow.Grid
+		if g, err = flow.DecodeGrid(goValueSI(where, flow.GridDims)); err != nil {
+			return nil, err
+		}
+		values, err = flow.VortexCriterion(g.Positions(), g.Len(), f, field)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return floatsToJSIn(values, flow.FieldDim(field)), nil
+}
+
+// extractVortexCores(grid[, options])
+//
+// Traces the core lines of the vortices of the stateful API's flow, seeded
+// at the local maxima of the vorticity magnitude on grid {min, max,
+// resolution}, within whose box the lines stay, then marched along the
+// vorticity and recentred at every step on the minimum of λ2 in the plane
+// normal to it. Options:
+//   - step: arc length of the steps (default half the finest grid spacing)
+//   - maxSteps: steps each way from a seed (default 256)
+//   - maxLines: lines traced at most, up to 256 (default 256)
+//   - minVorticity: |ω| below which a line ends and no seed starts one
+//     (default a tenth of the largest on the grid)
+//   - radius: of the loops along which the circulation is integrated
+//     (default the coarsest grid spacing)
+//
+// Lines also end where λ2 turns non-negative, in the object and within a
+// grid cell of another line. Returns {points, offsets, circulation,
+// closed}: the lines as for traceStreamlines, each along the vorticity,
+// strongest first, a Float32Array of the circulation about each point,
+// positive about the line's direction, and an array of whether each line
+// closes on itself, a vortex ring, repeating its first point. Lengths are
+// in the length unit of setUnits, minVorticity in its velocity unit per
+// length unit and circulations in its velocity unit times its length
+// unit; see flow.Flow.CoreLines.
+func extractVortexCores(args []js.Value) (interface{}, error) {
+	if err := checkArgs("extractVortexCores", args, 1); err != nil {
+		return nil, err
+	}
+	var spec flow.CoreLineSpec
+	var err error
+	if spec.Grid, err = flow.DecodeGrid(goValueSI(args[0], flow.GridDims)); err != nil {
+		return nil, err
+	}
+	if opts := optionalArg(args, 1); opts.Type() == js.TypeObject {
+		for _, o := range []struct {
+			key string
+			d   flow.Dim
+			dst *float64
+		}{{"step", flow.DimLength, &spec.Step}, {"minVorticity", flow.DimRate, &spec.MinVorticity}, {"radius", flow.DimLength, &spec.Radius}} {
+			if v := opts.Get(o.key); !v.IsUndefined() {
+				if *o.dst, err = dimArg(o.key, o.d, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, o := range []struct {
+			key string
+			dst *int
+		}{{"maxSteps", &spec.MaxSteps}, {"maxLines", &spec.MaxLines}} {
+			if v := opts.Get(o.key); !v.IsUndefined() {
+				if *o.dst, err = intArg(o.key, v); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	f := sim.Flow()
+	cores, err := f.CoreLines(spec)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]flow.Polyline, len(cores))
+	var circulation []float32
+	closed := make([]interface{}, len(cores))
+	for i, c := range cores {
+		lines[i], closed[i] = c.Points, c.Closed
+		circulation = append(circulation, c.Circulation...)
+	}
+	flow.Scale(circulation, 1/(units.Factor(flow.DimVelocity)*units.Factor(flow.DimLength)))
+	result := polylinesToJS(lines)
+	result["circulation"], result["closed"] = floatsToJS(circulation), closed
+	return result, nil
+}